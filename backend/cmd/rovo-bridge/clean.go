@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/example/rovobridge/internal/history"
+	"github.com/example/rovobridge/internal/janitor"
+	"github.com/example/rovobridge/internal/ws"
+)
+
+// runClean implements "rovo-bridge clean": one sweep of the janitor a running bridge
+// starts, with the same paths and limits, for scripts and for bridges run with
+// -janitor-interval 0
+func runClean(args []string) {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	recordDir := fs.String("record-dir", "", "Directory the bridge records sessions in (empty = none)")
+	inputRecordDir := fs.String("record-input-dir", "", "Directory the bridge records session input in (empty = none)")
+	sessionLogDir := fs.String("session-log-dir", ws.DefaultSessionLogDir(), "Directory per-session event logs are kept in")
+	historyPath := fs.String("history-path", "", "History file whose corrupted copies are removed (default: the one the bridge uses)")
+	maxAge := fs.String("max-age", "30d", "Remove files older than this age, e.g. 7d or 36h (0 = no limit)")
+	maxBytes := fs.Int64("max-bytes", janitor.DefaultMaxBytes, "Disk space each kind of file may take before the oldest are removed (0 = no limit)")
+	dryRun := fs.Bool("dry-run", false, "Report what would be removed without removing it")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(args)
+
+	age, err := parseAge(*maxAge)
+	if err != nil {
+		log.Fatalf("clean: -max-age: %v", err)
+	}
+	hist, err := history.OpenHistoryManager(history.BackendFile, *historyPath)
+	if err != nil {
+		log.Fatalf("clean: %v", err)
+	}
+	paths := janitor.Paths{RecordDir: *recordDir, InputRecordDir: *inputRecordDir, SessionLogDir: *sessionLogDir, HistoryFile: hist.GetHistoryFilePath()}
+	rep := janitor.Sweep(janitor.Rules(paths, age, *maxBytes), time.Now(), *dryRun)
+	if *asJSON {
+		writeJSONResult(os.Stdout, rep)
+	} else {
+		verb := "removed"
+		if *dryRun {
+			verb = "would remove"
+		}
+		for _, rr := range rep.Rules {
+			fmt.Printf("%-15s %s %d files (%s), kept %d (%s) in %s\n", rr.Name, verb, rr.Removed, formatBytes(rr.ReclaimedBytes), rr.Files, formatBytes(rr.Bytes), rr.Dir)
+			for _, e := range rr.Errors {
+				fmt.Printf("%-15s error: %s\n", "", e)
+			}
+		}
+		fmt.Printf("%s %d files, %s in total\n", verb, rep.Removed, formatBytes(rep.ReclaimedBytes))
+	}
+	for _, rr := range rep.Rules {
+		if len(rr.Errors) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// formatBytes formats a size in the largest binary unit it reaches
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/rovobridge/internal/history"
+)
+
+const historyUsage = `usage: rovo-bridge history <command> [flags]
+
+Commands:
+  list            Print the newest prompts
+  search QUERY    Print the newest prompts containing QUERY
+  export          Print every matching prompt as JSON or NDJSON
+  prune           Remove prompts older than an age or beyond a count
+`
+
+// runHistory implements "rovo-bridge history": it reads and prunes the prompt history
+// through the storage backend and locks a running bridge uses, for scripts and support
+func runHistory(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, historyUsage)
+		os.Exit(2)
+	}
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet("history "+cmd, flag.ExitOnError)
+	historyStore := fs.String("history-store", history.BackendFile, "Prompt history backend: file, sqlite or memory")
+	historyPath := fs.String("history-path", "", "History file or database (default: the one the bridge uses)")
+	project := fs.String("project", "", "Only prompts sent from this project directory")
+	path := fs.String("path", "", "Only prompts referencing this file")
+	since := fs.String("since", "", "Only prompts sent within this age, e.g. 36h or 7d")
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	var limit, keep *int
+	var format, olderThan *string
+	var dryRun *bool
+	switch cmd {
+	case "list", "search":
+		limit = fs.Int("limit", 20, "Maximum number of prompts, newest first (0 = all)")
+	case "export":
+		format = fs.String("format", "json", "Export format: json or ndjson")
+	case "prune":
+		olderThan = fs.String("older-than", "", "Remove prompts older than this age, e.g. 90d")
+		keep = fs.Int("keep", 0, "Keep only this many newest prompts")
+		dryRun = fs.Bool("dry-run", false, "List the prompts that would be removed without removing them")
+	default:
+		fmt.Fprintf(os.Stderr, "unknown history command %q\n\n%s", cmd, historyUsage)
+		os.Exit(2)
+	}
+	_ = fs.Parse(args)
+
+	filter := history.Filter{Project: *project, Path: *path}
+	if *since != "" {
+		age, err := parseAge(*since)
+		if err != nil {
+			log.Fatalf("history: -since: %v", err)
+		}
+		filter.Since = time.Now().Add(-age)
+	}
+	hist, err := history.OpenHistoryManager(*historyStore, *historyPath)
+	if err != nil {
+		log.Fatalf("history: %v", err)
+	}
+	defer hist.Close()
+
+	switch cmd {
+	case "list", "search":
+		if cmd == "search" {
+			if fs.NArg() != 1 {
+				log.Fatalf("usage: rovo-bridge history search [flags] QUERY")
+			}
+			filter.Query = fs.Arg(0)
+		}
+		entries := loadHistoryEntries(hist, filter)
+		// Newest first, like the prompt history popup
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+		if *limit > 0 && len(entries) > *limit {
+			entries = entries[:*limit]
+		}
+		if *asJSON {
+			writeJSONResult(os.Stdout, entries)
+			return
+		}
+		for _, e := range entries {
+			fmt.Println(historyLine(e))
+		}
+	case "export":
+		entries := loadHistoryEntries(hist, filter)
+		if err := exportHistory(os.Stdout, entries, *format); err != nil {
+			log.Fatalf("history: %v", err)
+		}
+	case "prune":
+		opts := history.PruneOptions{Filter: filter, Keep: *keep, DryRun: *dryRun}
+		if *olderThan != "" {
+			age, err := parseAge(*olderThan)
+			if err != nil {
+				log.Fatalf("history: -older-than: %v", err)
+			}
+			opts.Before = time.Now().Add(-age)
+		}
+		removed, err := hist.Prune(opts)
+		if err != nil {
+			log.Fatalf("history: %v", err)
+		}
+		if *asJSON {
+			writeJSONResult(os.Stdout, removed)
+			return
+		}
+		verb := "removed"
+		if *dryRun {
+			verb = "would remove"
+			for _, e := range removed {
+				fmt.Println(historyLine(e))
+			}
+		}
+		fmt.Printf("%s %d prompts from %s\n", verb, len(removed), hist.GetHistoryFilePath())
+	}
+}
+
+// loadHistoryEntries loads the entries passing filter, oldest first
+func loadHistoryEntries(hist *history.HistoryManager, filter history.Filter) []history.PromptHistoryEntry {
+	entries, err := hist.LoadHistory()
+	if err != nil {
+		log.Fatalf("history: %v", err)
+	}
+	return filter.Apply(entries)
+}
+
+// exportHistory writes entries as a history file, which the bridge can read back with
+// -history-path, or as one entry per line
+func exportHistory(w io.Writer, entries []history.PromptHistoryEntry, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(history.HistoryFile{Version: "1.0", Entries: entries})
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (json or ndjson)", format)
+}
+
+// historyLine is an entry on one tab-separated line: id, time, project and the start of
+// the prompt
+func historyLine(e history.PromptHistoryEntry) string {
+	text := strings.Join(strings.Fields(e.SerializedContent), " ")
+	if r := []rune(text); len(r) > 80 {
+		text = string(r[:79]) + "…"
+	}
+	when := time.UnixMilli(e.Timestamp).Format("2006-01-02 15:04")
+	return strings.Join([]string{e.ID, when, e.ProjectCwd, text}, "\t")
+}
+
+// parseAge parses a duration, also accepting a number of days such as 30d
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
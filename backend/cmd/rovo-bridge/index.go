@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/example/rovobridge/internal/index"
+)
+
+const indexUsage = `usage: rovo-bridge index <command> [flags]
+
+Commands:
+  build                 Scan the workspace and report, or print, the index
+  stats                 Summarize the index and the watches it needs
+  search PATTERN        Search the index like the file picker
+  verify-ignores PATH   Explain why a path is or is not indexed
+`
+
+// runIndex implements "rovo-bridge index": it builds the file index of a workspace
+// headlessly with the scanner, search and ignore rules of the server, to debug why a file
+// does not show up in the picker
+func runIndex(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, indexUsage)
+		os.Exit(2)
+	}
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet("index "+cmd, flag.ExitOnError)
+	root := fs.String("root", ".", "Workspace root to index")
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	switch cmd {
+	case "build":
+		format := fs.String("format", "", "Print every entry of the index instead: json or ndjson")
+		_ = fs.Parse(args)
+		start := time.Now()
+		snap := index.Scan(*root)
+		took := time.Since(start)
+		switch *format {
+		case "json":
+			if err := snap.WriteJSON(os.Stdout, *root); err != nil {
+				log.Fatalf("index: %v", err)
+			}
+		case "ndjson":
+			if err := snap.WriteNDJSON(os.Stdout); err != nil {
+				log.Fatalf("index: %v", err)
+			}
+		case "":
+			st := snap.Stats()
+			if *asJSON {
+				writeJSONResult(os.Stdout, map[string]any{"root": *root, "files": st.Files, "dirs": st.Dirs, "scanMs": took.Milliseconds()})
+				return
+			}
+			fmt.Printf("indexed %d files and %d directories under %s in %s\n", st.Files, st.Dirs, *root, took.Round(time.Millisecond))
+		default:
+			log.Fatalf("index: unknown format %q (json or ndjson)", *format)
+		}
+	case "stats":
+		_ = fs.Parse(args)
+		start := time.Now()
+		snap := index.Scan(*root)
+		took := time.Since(start)
+		st := snap.Stats()
+		if *asJSON {
+			writeJSONResult(os.Stdout, struct {
+				Root   string `json:"root"`
+				ScanMs int64  `json:"scanMs"`
+				index.Stats
+			}{*root, took.Milliseconds(), st})
+			return
+		}
+		printIndexStats(os.Stdout, *root, took, st)
+	case "search":
+		limit := fs.Int("limit", 20, "Maximum number of results")
+		profile := fs.String("profile", "", "Ranking profile: default, picker or mention")
+		_ = fs.Parse(args)
+		if fs.NArg() != 1 {
+			log.Fatalf("usage: rovo-bridge index search [flags] PATTERN")
+		}
+		res, _ := index.Scan(*root).SearchWithProfile(fs.Arg(0), *limit, nil, index.ProfileByName(*profile))
+		if *asJSON {
+			out := make([]index.ExportEntry, 0, len(res))
+			for _, e := range res {
+				out = append(out, index.ExportEntry{Path: e.Path, Name: e.Name, Short: e.Short, IsDir: e.IsDir})
+			}
+			writeJSONResult(os.Stdout, out)
+			return
+		}
+		for _, e := range res {
+			fmt.Println(e.Path)
+		}
+	case "verify-ignores":
+		_ = fs.Parse(args)
+		if fs.NArg() == 0 {
+			log.Fatalf("usage: rovo-bridge index verify-ignores [flags] PATH...")
+		}
+		excluded := false
+		var reports []index.IgnoreReport
+		for _, p := range fs.Args() {
+			rep, err := index.VerifyIgnores(*root, p)
+			if err != nil {
+				log.Fatalf("index: %v", err)
+			}
+			excluded = excluded || !rep.Indexed
+			reports = append(reports, rep)
+		}
+		if *asJSON {
+			writeJSONResult(os.Stdout, reports)
+		} else {
+			for _, rep := range reports {
+				fmt.Println(describeIgnoreReport(rep))
+			}
+		}
+		if excluded {
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown index command %q\n\n%s", cmd, indexUsage)
+		os.Exit(2)
+	}
+}
+
+// writeJSONResult writes the indented JSON result of a subcommand
+func writeJSONResult(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// printIndexStats prints the stats of an index for people
+func printIndexStats(w io.Writer, root string, took time.Duration, st index.Stats) {
+	fmt.Fprintf(w, "root:          %s\n", root)
+	fmt.Fprintf(w, "scan:          %s\n", took.Round(time.Millisecond))
+	fmt.Fprintf(w, "files:         %d\n", st.Files)
+	fmt.Fprintf(w, "directories:   %d (deepest %d levels)\n", st.Dirs, st.MaxDepth)
+	fmt.Fprintf(w, ".gitignore:    %d\n", st.IgnoreFiles)
+	watches := fmt.Sprintf("%d of %d", st.Watches, st.WatchCap)
+	if st.Watches > st.WatchCap {
+		watches += " (over the cap: changes in the rest of the tree are seen on rescans only)"
+	}
+	fmt.Fprintf(w, "watches:       %s\n", watches)
+	if len(st.TopDirs) > 0 {
+		fmt.Fprintln(w, "largest directories:")
+		for _, d := range st.TopDirs {
+			fmt.Fprintf(w, "  %8d  %s\n", d.Files, d.Dir)
+		}
+	}
+}
+
+// describeIgnoreReport explains in one line why a path is or is not indexed
+func describeIgnoreReport(rep index.IgnoreReport) string {
+	switch rep.Reason {
+	case "":
+		return rep.Path + ": indexed"
+	case index.ExcludedOutsideRoot:
+		return rep.Path + ": not indexed: outside the workspace root"
+	case index.ExcludedMissing:
+		return rep.Path + ": not indexed: no such file or directory"
+	case index.ExcludedVCS:
+		return fmt.Sprintf("%s: not indexed: inside the git directory %s", rep.Path, rep.Excluded)
+	case index.ExcludedSymlink:
+		return fmt.Sprintf("%s: not indexed: %s is a symbolic link, which the index does not follow", rep.Path, rep.Excluded)
+	case index.ExcludedGitignore:
+		what := "it"
+		if rep.Excluded != rep.Path {
+			what = rep.Excluded
+		}
+		return fmt.Sprintf("%s: not indexed: %s matches %q in %s", rep.Path, what, rep.Pattern, rep.IgnoreFile)
+	}
+	return rep.Path + ": not indexed: " + rep.Reason
+}
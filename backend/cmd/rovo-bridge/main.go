@@ -1,26 +1,49 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/example/rovobridge/internal/doctor"
+	"github.com/example/rovobridge/internal/history"
 	"github.com/example/rovobridge/internal/httpapi"
+	"github.com/example/rovobridge/internal/janitor"
+	"github.com/example/rovobridge/internal/listen"
+	"github.com/example/rovobridge/internal/parentwatch"
+	"github.com/example/rovobridge/internal/policy"
+	"github.com/example/rovobridge/internal/protocol"
+	"github.com/example/rovobridge/internal/redact"
+	"github.com/example/rovobridge/internal/selfupdate"
 	"github.com/example/rovobridge/internal/ws"
 )
 
+// connSchemaVersion is the schemaVersion of the connection JSON. Wrapper tools may rely
+// on its fields; new fields are added without changing it, and it changes only when one
+// is removed or changes meaning.
+const connSchemaVersion = 1
+
+// connInfo is the connection JSON printed with -print-conn-json and written to -conn-file
 type connInfo struct {
-	Port   int    `json:"port"`
-	Token  string `json:"token"`
-	UIBase string `json:"uiBase"`
+	SchemaVersion int    `json:"schemaVersion"`
+	Port          int    `json:"port"`
+	Token         string `json:"token"`
+	UIBase        string `json:"uiBase"`
+	MetricsURL    string `json:"metricsUrl"` // session and connection counters, authorized with the token
 }
 
 func randToken() string {
@@ -29,18 +52,327 @@ func randToken() string {
 	return base64.RawURLEncoding.EncodeToString(b)
 }
 
+// authorized requires Authorization: Bearer <token>; the token is not accepted in the URL or other locations.
+func authorized(r *http.Request, token string) bool {
+	return r.Header.Get("Authorization") == "Bearer "+token
+}
+
+// listenFailed exits on a listen error. With -print-conn-json the error is also printed
+// to stdout as {"error": {"code", "message", ...}} in place of the connection JSON, so IDE
+// plugins can tell a port conflict from other failures.
+func listenFailed(err error, printConn bool) {
+	var le *listen.Error
+	if printConn && errors.As(err, &le) {
+		_ = json.NewEncoder(os.Stdout).Encode(map[string]any{"error": le})
+	}
+	log.Fatalf("listen error: %v", err)
+}
+
+// runDoctor implements "rovo-bridge doctor": it prints the environment checks and exits
+// non-zero if any failed
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the report as JSON for support tickets")
+	customCmd := fs.String("cmd", "", "Check this command instead of the default 'acli rovodev run'")
+	historyStore := fs.String("history-store", history.BackendFile, "Check the prompt history kept by this backend: file, sqlite or memory")
+	historyPath := fs.String("history-path", "", "Check this history file or database instead of the default")
+	_ = fs.Parse(args)
+
+	hist, err := history.OpenHistoryManager(*historyStore, *historyPath)
+	if err != nil {
+		log.Fatalf("doctor: %v", err)
+	}
+	defer hist.Close()
+	rep := doctor.Run(context.Background(), doctor.Options{Command: *customCmd, History: hist})
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+	} else {
+		_ = rep.Print(os.Stdout)
+	}
+	if rep.Worst() == doctor.Fail {
+		os.Exit(1)
+	}
+}
+
+// runSelfUpdate implements "rovo-bridge self-update": it installs the latest verified
+// release over the running binary, or with -check only reports whether there is one
+func runSelfUpdate(args []string) {
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	check := fs.Bool("check", false, "Only report whether a newer release is available")
+	releaseURL := fs.String("release-url", os.Getenv("ROVOBRIDGE_RELEASE_URL"), "Release manifest URL (defaults to the one built in)")
+	pubKey := fs.String("public-key", os.Getenv("ROVOBRIDGE_RELEASE_KEY"), "Base64 ed25519 key release signatures are checked against (defaults to the one built in)")
+	_ = fs.Parse(args)
+
+	u, err := selfupdate.New(*releaseURL, *pubKey)
+	if err != nil {
+		log.Fatalf("self-update: %v", err)
+	}
+	ctx := context.Background()
+	if *check {
+		info, err := u.Check(ctx)
+		if err != nil {
+			log.Fatalf("self-update: %v", err)
+		}
+		_ = json.NewEncoder(os.Stdout).Encode(info)
+		return
+	}
+	info, err := u.Apply(ctx)
+	if err != nil {
+		log.Fatalf("self-update: %v", err)
+	}
+	if !info.Available {
+		fmt.Printf("rovo-bridge %s is up to date\n", info.Current)
+		return
+	}
+	fmt.Printf("updated rovo-bridge %s -> %s; restart it to use the new version\n", info.Current, info.Latest)
+}
+
+// runBench implements the hidden "rovo-bridge bench": it pushes synthetic session output
+// through the Router and WebSocket stack and reports throughput and latency
+func runBench(args []string) {
+	def := ws.DefaultBenchConfig()
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	sessions := fs.Int("sessions", def.Sessions, "Synthetic sessions producing output")
+	clients := fs.Int("clients", def.Clients, "WebSocket connections the sessions are spread over")
+	rate := fs.Int("rate", def.BytesPerSec, "Output bytes per second of each session")
+	line := fs.Int("line", def.LineBytes, "Output line length in bytes")
+	duration := fs.Duration("duration", def.Duration, "How long sessions produce output")
+	batch := fs.Bool("batch", false, "Negotiate batched frames")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(args)
+
+	rep, err := ws.RunBench(context.Background(), ws.BenchConfig{
+		Sessions: *sessions, Clients: *clients, BytesPerSec: *rate, LineBytes: *line, Duration: *duration, Batch: *batch,
+	})
+	if err != nil {
+		log.Fatalf("bench: %v", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+		return
+	}
+	rep.Print(os.Stdout)
+}
+
+// runSchema implements "rovo-bridge schema": it prints the JSON Schema of the WebSocket
+// messages, or with -openapi the OpenAPI document of the REST endpoints, for code
+// generators that run without a bridge
+func runSchema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	openapi := fs.Bool("openapi", false, "Print the OpenAPI document of the REST endpoints instead")
+	_ = fs.Parse(args)
+
+	doc := protocol.Schema()
+	if *openapi {
+		doc = protocol.OpenAPI()
+	}
+	writeSchema(os.Stdout, doc)
+}
+
+// writeSchema writes an indented schema document
+func writeSchema(w io.Writer, doc map[string]any) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(doc)
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			runDoctor(os.Args[2:])
+			return
+		case "self-update":
+			runSelfUpdate(os.Args[2:])
+			return
+		case "bench":
+			runBench(os.Args[2:])
+			return
+		case "schema":
+			runSchema(os.Args[2:])
+			return
+		case "index":
+			runIndex(os.Args[2:])
+			return
+		case "history":
+			runHistory(os.Args[2:])
+			return
+		case "clean":
+			runClean(os.Args[2:])
+			return
+		case "replay-input":
+			runReplayInput(os.Args[2:])
+			return
+		}
+	}
 	addr := flag.String("http", "127.0.0.1:0", "HTTP listen address (loopback only)")
+	portRange := flag.String("port-range", "", "Ports to try in order when -http has port 0, e.g. 8700-8799 (empty = any free port)")
+	bindFamily := flag.String("bind-family", "", "Loopback addresses to bind instead of the -http host: v4 (127.0.0.1), v6 (::1) or dual (both)")
+	connFile := flag.String("conn-file", "", "File the connection JSON is written to; the port it records is reused on the next start when free")
+	readTimeout := flag.Duration("read-timeout", 30*time.Second, "Longest time to read an HTTP request, body included (0 = no limit)")
+	writeTimeout := flag.Duration("write-timeout", time.Minute, "Longest time to write an HTTP response; WebSocket and proxied dev server traffic is exempt (0 = no limit)")
+	idleTimeout := flag.Duration("idle-timeout", 2*time.Minute, "How long an idle keep-alive HTTP connection stays open (0 = use -read-timeout)")
+	maxHeaderBytes := flag.Int("max-header-bytes", 64<<10, "Largest HTTP request header accepted")
+	wsHandshake := flag.Duration("ws-handshake-timeout", ws.DefaultHandshakeTimeout, "Longest time a WebSocket upgrade may take (0 = no limit)")
 	serveUI := flag.Bool("serve-ui", true, "Serve embedded web UI")
 	printConn := flag.Bool("print-conn-json", true, "Print connection JSON to stdout on start")
 	customCmd := flag.String("cmd", "", "Custom command to execute (overrides default 'acli rovodev run')")
+	policyPath := flag.String("policy", policy.DefaultPath(), "Command allow/deny list restricting what sessions may launch (ignored if missing)")
+	stdinDefaults := ws.DefaultStdinLimits()
+	stdinMax := flag.Int("stdin-max-bytes", stdinDefaults.MaxMessageBytes, "Largest stdin message a client may send (0 = unlimited)")
+	stdinRate := flag.Int("stdin-rate", stdinDefaults.BytesPerSecond, "Stdin bytes per second a client may send to a session (0 = unlimited)")
+	stdinBurst := flag.Int("stdin-burst", stdinDefaults.BurstBytes, "Stdin bytes a client may send at once before -stdin-rate applies")
+	maxPayload := flag.Int("max-payload-bytes", ws.DefaultMaxPayloadBytes, "Largest prompt text plus file contents one send or injectFiles may write into a session (0 = unlimited)")
+	promptLint := flag.String("prompt-lint", strings.Join(ws.DefaultPromptLint, ","), "Checks run on each send, holding it back with promptLint warnings until acknowledged: empty, placeholder, missingFile, staleFile (empty = off)")
+	timeoutDefaults := ws.DefaultTimeouts()
+	fileReadTimeout := flag.Duration("file-read-timeout", timeoutDefaults.FileRead, "Longest time reading one file for injection may take, unless the message sets timeoutMs")
+	historyTimeout := flag.Duration("history-timeout", timeoutDefaults.History, "Longest time a prompt history load, save or query may take (0 = no limit)")
+	clipboardTimeout := flag.Duration("clipboard-timeout", timeoutDefaults.Clipboard, "Longest time a clipboard read or write may take; injection then types the text instead (0 = no limit)")
+	searchTimeout := flag.Duration("search-timeout", timeoutDefaults.Search, "Longest time an index search or context selection may take (0 = no limit)")
+	workers := flag.Int("workers", ws.DefaultWorkers(), "How many WebSocket messages are handled at once; each session's messages stay in order")
+	recordDir := flag.String("record-dir", "", "Record sessions as asciicast files in this directory for replay (empty = off)")
+	recordInputDir := flag.String("record-input-dir", "", "Record the exact bytes written to each session's stdin, with their times and secrets masked, in this directory for replay-input (empty = off)")
+	warmSessions := flag.Int("warm-sessions", 0, "Agent processes started ahead so openSession attaches at once, replaced after use (0 = off, at most 4)")
+	collapseSpinners := flag.Bool("collapse-spinners", false, "Drop spinner and progress redraws replaced by a later frame from snapshots and recordings")
+	downgradeTerm := flag.Bool("downgrade-term", false, "Start sessions whose env sets an unknown TERM with xterm-256color instead; a termWarning reports unknown ones either way")
+	releaseURL := flag.String("release-url", os.Getenv("ROVOBRIDGE_RELEASE_URL"), "Release manifest URL for checkUpdate (defaults to the one built in)")
+	releaseKey := flag.String("release-public-key", os.Getenv("ROVOBRIDGE_RELEASE_KEY"), "Base64 ed25519 key release signatures are checked against")
+	browserDeny := flag.String("browser-deny", "", "Capabilities withheld from connections opened by a web page, while the IDE keeps full control: command, writeFiles (comma-separated, empty = none)")
+	crashLog := flag.String("crash-log", ws.DefaultCrashLogPath(), "File recovered panics are appended to, with stack traces (empty = log only to stderr)")
+	sessionLog := flag.Bool("session-log", false, "Write each session's lifecycle events (opens, resizes, send digests, exits) as JSON lines under -session-log-dir")
+	sessionLogDir := flag.String("session-log-dir", ws.DefaultSessionLogDir(), "Directory per-session event logs are kept in, rotated at 1 MiB")
+	crashEndpoint := flag.Bool("crash-report-endpoint", false, "Serve the last crash report at /crash-report for IDE plugins")
+	redactOn := flag.Bool("redact", false, "Mask secrets (API keys, tokens, passwords) in session output, snapshots and recordings")
+	redactFile := flag.String("redact-patterns", "", "File of extra regular expressions to mask, one per line")
+	quotaSent := flag.Int64("quota-sent-bytes", 0, "Bytes per minute sent to one client before it is warned and logged (0 = no quota)")
+	quotaReceived := flag.Int64("quota-received-bytes", 0, "Bytes per minute received from one client before it is warned and logged (0 = no quota)")
+	historyStore := flag.String("history-store", history.BackendFile, "Where prompt history is kept: file (JSON, works anywhere), sqlite (database for large desktop histories) or memory (lost on exit)")
+	historyRenames := flag.Bool("rewrite-history-on-rename", false, "Point the file chips of stored prompts at the new place of files the index sees renamed or moved, instead of waiting for rewriteHistoryPaths")
+	historyPath := flag.String("history-path", "", "History file or database (empty = ~/.rovobridge, or ~/.rovobridge.db for sqlite)")
+	janitorInterval := flag.Duration("janitor-interval", janitor.DefaultInterval, "How often aged recordings, session logs, corrupted history backups and stale temporary files are removed (0 = never)")
+	cacheMaxAge := flag.Duration("cache-max-age", janitor.DefaultMaxAge, "Age after which the janitor removes recordings, session logs and corrupted history backups (0 = no limit)")
+	cacheMaxBytes := flag.Int64("cache-max-bytes", janitor.DefaultMaxBytes, "Disk space each of recordings, session logs and corrupted history backups may take before the oldest are removed (0 = no limit)")
+	parentPid := flag.Int("parent-pid", 0, "Shut down, ending sessions, once this process exits; IDE plugins pass their own pid so no bridge outlives an IDE crash (0 = off)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 5*time.Second, "Longest time shutdown waits for sessions to flush their last output and exit")
+	mock := flag.Bool("mock", false, "Serve scripted sessions, a synthetic file index and sample history for frontend development; no process is started")
 	flag.Parse()
 
+	host, listenPort, err := listen.SplitAddr(*addr)
+	if err != nil {
+		listenFailed(err, *printConn)
+	}
+	hosts := []string{host}
+	if *bindFamily != "" {
+		family, err := listen.ParseFamily(*bindFamily)
+		if err != nil {
+			listenFailed(err, *printConn)
+		}
+		hosts = family.Hosts()
+	}
+	rng, err := listen.ParseRange(*portRange)
+	if err != nil {
+		listenFailed(err, *printConn)
+	}
+
+	var parentGone <-chan struct{}
+	if *parentPid != 0 {
+		if parentGone, err = parentwatch.Watch(*parentPid); err != nil {
+			log.Fatalf("-parent-pid: %v", err)
+		}
+	}
+
+	// A policy that fails to load must not silently permit everything
+	pol, err := policy.Load(*policyPath)
+	if err != nil {
+		log.Fatalf("policy error: %v", err)
+	}
+
+	patterns := redact.DefaultPatterns
+	if *redactFile != "" {
+		extra, err := redact.LoadPatterns(*redactFile)
+		if err != nil {
+			log.Fatalf("redaction error: %v", err)
+		}
+		patterns = append(append([]string(nil), patterns...), extra...)
+	}
+	redaction, err := redact.New(patterns)
+	if err != nil {
+		log.Fatalf("redaction error: %v", err)
+	}
+
 	token := randToken()
 
 	mux := http.NewServeMux()
 	wss := ws.NewServer(token)
-	router := ws.NewRouter(*customCmd)
+	wss.Upgrader.HandshakeTimeout = *wsHandshake
+	wss.Quota = ws.Quota{SentBytes: *quotaSent, ReceivedBytes: *quotaReceived}
+	var router *ws.Router
+	var historyFile string
+	if *mock {
+		// Mock history and drafts live in a scratch directory removed on exit
+		dataDir, err := os.MkdirTemp("", "rovo-bridge-mock-")
+		if err != nil {
+			log.Fatalf("mock error: %v", err)
+		}
+		defer os.RemoveAll(dataDir)
+		if router, err = ws.NewMockRouter(dataDir); err != nil {
+			log.Fatalf("mock error: %v", err)
+		}
+		log.Printf("mock mode: sessions, index and history are synthetic")
+	} else {
+		hist, err := history.OpenHistoryManager(*historyStore, *historyPath)
+		if err != nil {
+			log.Fatalf("history error: %v", err)
+		}
+		defer hist.Close()
+		if *historyStore == history.BackendFile {
+			historyFile = hist.GetHistoryFilePath()
+		}
+		router = ws.NewRouter(*customCmd)
+		router.SetPolicy(pol)
+		router.SetHistoryManager(hist)
+	}
+	router.SetStdinLimits(ws.StdinLimits{MaxMessageBytes: *stdinMax, BytesPerSecond: *stdinRate, BurstBytes: *stdinBurst})
+	router.SetMaxPayloadBytes(*maxPayload)
+	if err := router.SetPromptLint(strings.Split(*promptLint, ",")); err != nil {
+		log.Fatalf("prompt lint error: %v", err)
+	}
+	router.SetTimeouts(ws.Timeouts{FileRead: *fileReadTimeout, History: *historyTimeout, Clipboard: *clipboardTimeout, Search: *searchTimeout})
+	router.SetWorkers(*workers)
+	router.SetRecordingDir(*recordDir)
+	router.SetInputRecordingDir(*recordInputDir)
+	router.SetCollapseSpinners(*collapseSpinners)
+	router.SetDowngradeTerm(*downgradeTerm)
+	router.SetHistoryRenameRewrite(*historyRenames)
+	router.SetCrashLog(*crashLog)
+	if *browserDeny != "" {
+		if err := router.SetBrowserRestrictions(strings.Split(*browserDeny, ",")); err != nil {
+			log.Fatalf("-browser-deny: %v", err)
+		}
+	}
+	if *sessionLog {
+		router.SetSessionLogDir(*sessionLogDir)
+	}
+	router.SetRedaction(redaction, *redactOn)
+	router.SetWarmSessions(*warmSessions)
+	// Update checks are off unless a release URL and key are built in or given
+	if u, err := selfupdate.New(*releaseURL, *releaseKey); err == nil {
+		router.SetUpdater(u)
+	} else if *releaseURL != "" || *releaseKey != "" {
+		log.Fatalf("release config error: %v", err)
+	}
+	if *janitorInterval > 0 {
+		paths := janitor.Paths{RecordDir: *recordDir, InputRecordDir: *recordInputDir, SessionLogDir: *sessionLogDir, HistoryFile: historyFile}
+		jan := janitor.New(janitor.Rules(paths, *cacheMaxAge, *cacheMaxBytes), *janitorInterval)
+		jan.Start()
+		defer jan.Close()
+		router.SetJanitor(jan)
+	}
 	router.Attach(wss)
 	mux.HandleFunc("/ws", wss.HandleWS)
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -48,10 +380,7 @@ func main() {
 		_, _ = w.Write([]byte("ok"))
 	})
 	mux.HandleFunc("/font-size", func(w http.ResponseWriter, r *http.Request) {
-		// Require Authorization: Bearer <token>; do not accept token in URL or other locations
-		auth := r.Header.Get("Authorization")
-		authorized := (auth == "Bearer "+token)
-		if !authorized {
+		if !authorized(r, token) {
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
@@ -59,6 +388,67 @@ func main() {
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(map[string]int{"fontSize": fontSize})
 	})
+	mux.HandleFunc("/index", func(w http.ResponseWriter, r *http.Request) {
+		// Export the gitignore-aware file index; ?format=ndjson streams one entry per line
+		if !authorized(r, token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		router.WriteIndexExport(w, r.URL.Query().Get("format"))
+	})
+	mux.HandleFunc("/recordings", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		router.WriteRecordingList(w)
+	})
+	mux.HandleFunc("/recordings/", func(w http.ResponseWriter, r *http.Request) {
+		// Stream one recording; ?seek=<seconds> starts part way in, ?speed=<factor> rescales timing
+		if !authorized(r, token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		q := r.URL.Query()
+		seek, _ := strconv.ParseFloat(q.Get("seek"), 64)
+		speed, _ := strconv.ParseFloat(q.Get("speed"), 64)
+		router.WriteRecording(w, strings.TrimPrefix(r.URL.Path, "/recordings/"), seek, speed)
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		// Session and connection counters for wrapper tools, found through metricsUrl
+		if !authorized(r, token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		router.WriteMetrics(w)
+	})
+	mux.HandleFunc("/schema", func(w http.ResponseWriter, r *http.Request) {
+		// JSON Schema of the WebSocket messages, for generating and validating client code
+		if !authorized(r, token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		writeSchema(w, protocol.Schema())
+	})
+	mux.HandleFunc("/schema/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeSchema(w, protocol.OpenAPI())
+	})
+	if *crashEndpoint {
+		mux.HandleFunc("/crash-report", func(w http.ResponseWriter, r *http.Request) {
+			// The last panic recovered from a message handler, for attaching to bug reports
+			if !authorized(r, token) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			router.WriteLastCrash(w)
+		})
+	}
 	var cwd string
 	if d, err := os.Getwd(); err == nil {
 		cwd = d
@@ -67,17 +457,50 @@ func main() {
 		mux.Handle("/", httpapi.UIHandlerWithCwd(token, cwd))
 	}
 
-	ln, err := net.Listen("tcp", *addr)
+	ln, err := listen.Listen(hosts, listenPort, rng, listen.PreviousPort(*connFile))
 	if err != nil {
-		log.Fatalf("listen error: %v", err)
+		listenFailed(err, *printConn)
+	}
+
+	// Dev server previews get a listener, and so an origin, of their own: a previewed page
+	// runs the dev server's scripts, which must not reach the UI, its storage or the API
+	proxyMux := http.NewServeMux()
+	proxyMux.HandleFunc("/proxy/", func(w http.ResponseWriter, r *http.Request) {
+		// The router also accepts its proxy cookie and tickets
+		router.ServeProxy(w, r, authorized(r, token))
+	})
+	proxyLn, err := listen.Listen(hosts, 0, listen.PortRange{}, 0)
+	if err != nil {
+		listenFailed(err, *printConn)
+	}
+	proxySrv := &http.Server{
+		Handler:        proxyMux,
+		ReadTimeout:    *readTimeout,
+		WriteTimeout:   *writeTimeout,
+		IdleTimeout:    *idleTimeout,
+		MaxHeaderBytes: *maxHeaderBytes,
+	}
+	go func() {
+		_ = proxySrv.Serve(proxyLn)
+	}()
+	router.SetProxyBase(listen.BaseURL(proxyLn.Addr()))
+
+	// Timeouts keep a wedged local client from holding connections forever; WebSocket
+	// connections are hijacked and keep only their own deadlines
+	srv := &http.Server{
+		Handler:        mux,
+		ReadTimeout:    *readTimeout,
+		WriteTimeout:   *writeTimeout,
+		IdleTimeout:    *idleTimeout,
+		MaxHeaderBytes: *maxHeaderBytes,
 	}
-	srv := &http.Server{Handler: mux}
 	go func() {
 		_ = srv.Serve(ln)
 	}()
 
 	port := ln.Addr().(*net.TCPAddr).Port
-	info := connInfo{Port: port, Token: token, UIBase: fmt.Sprintf("http://127.0.0.1:%d/", port)}
+	base := listen.BaseURL(ln.Addr())
+	info := connInfo{SchemaVersion: connSchemaVersion, Port: port, Token: token, UIBase: base, MetricsURL: base + "metrics"}
 	if *serveUI {
 		log.Printf("UI available at %s", info.UIBase)
 	}
@@ -85,10 +508,24 @@ func main() {
 		enc := json.NewEncoder(os.Stdout)
 		_ = enc.Encode(info)
 	}
+	if *connFile != "" {
+		if err := listen.WriteConnFile(*connFile, info); err != nil {
+			log.Printf("conn file error: %v", err)
+		}
+	}
 
-	// wait for signal
+	// wait for a signal, or for the process that spawned the bridge to exit
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
+	select {
+	case <-c:
+	case <-parentGone:
+		log.Printf("parent process %d exited, shutting down", *parentPid)
+	}
+	router.DrainSessions(*shutdownTimeout)
+	router.FlushDrafts()
+	router.FlushUsage()
+	router.CloseWarmSessions()
 	_ = srv.Close()
+	_ = proxySrv.Close()
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/example/rovobridge/internal/recording"
+	"github.com/gorilla/websocket"
+)
+
+const replayInputUsage = `usage: rovo-bridge replay-input FILE -into SESSION [flags]
+
+Writes the input recorded with -record-input-dir into a session of a running bridge,
+with the recorded timing, to reproduce an agent run.
+`
+
+// runReplayInput implements "rovo-bridge replay-input": it connects to a running bridge
+// like an IDE plugin and writes each recorded input event to the session's stdin after
+// the pause that preceded it
+func runReplayInput(args []string) {
+	fs := flag.NewFlagSet("replay-input", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), replayInputUsage)
+		fs.PrintDefaults()
+	}
+	into := fs.String("into", "", "Session to write the input to, by id or alias")
+	connFile := fs.String("conn-file", "", "Connection file of the bridge, written with its -conn-file")
+	port := fs.Int("port", 0, "Port of the bridge, when there is no connection file")
+	token := fs.String("token", os.Getenv("ROVOBRIDGE_TOKEN"), "Auth token of the bridge, when there is no connection file")
+	speed := fs.Float64("speed", 1, "Replay speed; 2 halves the pauses, 0 writes everything at once")
+	maxGap := fs.Duration("max-gap", 0, "Longest pause between two writes, shortening idle stretches (0 = as recorded)")
+	dryRun := fs.Bool("dry-run", false, "Print the recorded input instead of writing it")
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	// The file comes first, as in the usage; flags may follow it
+	file := fs.Arg(0)
+	_ = fs.Parse(fs.Args()[1:])
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		log.Fatalf("replay-input: %v", err)
+	}
+	h, events, err := recording.ReadInput(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("replay-input: %s: %v", file, err)
+	}
+	if *dryRun {
+		for _, e := range events {
+			fmt.Printf("%10.3f  %s\n", e.T, strconv.Quote(string(e.Data)))
+		}
+		fmt.Printf("%d writes recorded from session %s\n", len(events), h.SessionID)
+		return
+	}
+	if *into == "" {
+		log.Fatalf("replay-input: -into is required")
+	}
+	if *connFile != "" {
+		if *port, *token, err = readConnFile(*connFile); err != nil {
+			log.Fatalf("replay-input: %v", err)
+		}
+	}
+	if *port <= 0 || *token == "" {
+		log.Fatalf("replay-input: -conn-file, or -port and -token, are required")
+	}
+
+	d := websocket.Dialer{Subprotocols: []string{"auth.bearer." + *token}, HandshakeTimeout: 10 * time.Second}
+	conn, _, err := d.Dial(fmt.Sprintf("ws://127.0.0.1:%d/ws", *port), nil)
+	if err != nil {
+		log.Fatalf("replay-input: %v", err)
+	}
+	defer conn.Close()
+	// The bridge answers a write it refuses with an error, e.g. for an unknown session
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			var m map[string]any
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			if m["type"] == "error" {
+				msg, _ := m["message"].(string)
+				failed <- errors.New(msg)
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	var prev float64
+	var sent int
+	for _, e := range events {
+		if *speed > 0 {
+			pause := time.Duration((e.T - prev) / *speed * float64(time.Second))
+			if *maxGap > 0 {
+				pause = min(pause, *maxGap)
+			}
+			select {
+			case err := <-failed:
+				log.Fatalf("replay-input: %v", err)
+			case <-time.After(pause):
+			}
+		}
+		prev = e.T
+		msg := map[string]any{"type": "stdin", "sessionId": *into, "dataBase64": base64.StdEncoding.EncodeToString(e.Data)}
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Fatalf("replay-input: %v", err)
+		}
+		sent += len(e.Data)
+	}
+	// Give the bridge a moment to refuse the last writes
+	select {
+	case err := <-failed:
+		log.Fatalf("replay-input: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	fmt.Printf("replayed %d writes (%d bytes) into %s in %s\n", len(events), sent, *into, time.Since(start).Round(time.Millisecond))
+}
+
+// readConnFile reads the port and token of a bridge from its connection file
+func readConnFile(path string) (int, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, "", err
+	}
+	var info connInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return 0, "", fmt.Errorf("%s: %v", path, err)
+	}
+	return info.Port, info.Token, nil
+}
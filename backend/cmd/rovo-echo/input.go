@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// input reads stdin on one goroutine for the whole run, so a keys step that times out
+// does not leave a read behind that swallows what the next step should see
+type input struct {
+	chunks chan []byte // closed at EOF or on a read error
+	buf    []byte      // read but not yet consumed
+}
+
+func newInput(r io.Reader) *input {
+	in := &input{chunks: make(chan []byte, 16)}
+	go func() {
+		defer close(in.chunks)
+		for {
+			buf := make([]byte, 4096)
+			n, err := r.Read(buf)
+			if n > 0 {
+				in.chunks <- buf[:n]
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return in
+}
+
+// chunk returns the next read, or what is left of it; ok is false at EOF or on timeout
+func (in *input) chunk(timeout <-chan time.Time) (data []byte, ok bool) {
+	if len(in.buf) > 0 {
+		data, in.buf = in.buf, nil
+		return data, true
+	}
+	select {
+	case data, ok = <-in.chunks:
+		return data, ok
+	case <-timeout:
+		return nil, false
+	}
+}
+
+// line returns the next line without its line ending; ok is false at EOF, with any
+// unterminated text still returned
+func (in *input) line() (string, bool) {
+	var line []byte
+	for {
+		if i := bytes.IndexByte(in.buf, '\n'); i >= 0 {
+			line = append(line, in.buf[:i]...)
+			in.buf = in.buf[i+1:]
+			return string(bytes.TrimSuffix(line, []byte("\r"))), true
+		}
+		line = append(line, in.buf...)
+		in.buf = nil
+		data, ok := <-in.chunks
+		if !ok {
+			return string(line), false
+		}
+		in.buf = data
+	}
+}
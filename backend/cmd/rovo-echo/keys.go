@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// csiKeys names the final bytes and "~" codes of CSI key sequences
+var csiKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left", "H": "home", "F": "end",
+	"Z": "shift+tab", "P": "f1", "Q": "f2", "R": "f3", "S": "f4",
+	"2~": "insert", "3~": "delete", "5~": "pageup", "6~": "pagedown",
+	"15~": "f5", "17~": "f6", "18~": "f7", "19~": "f8", "20~": "f9", "21~": "f10", "23~": "f11", "24~": "f12",
+}
+
+// modifierNames decodes the xterm modifier parameter of "ESC [ 1 ; m X"
+var modifierNames = map[string]string{
+	"2": "shift+", "3": "alt+", "4": "alt+shift+", "5": "ctrl+", "6": "ctrl+shift+", "7": "ctrl+alt+", "8": "ctrl+alt+shift+",
+}
+
+// decodeKeys names the keys in one read from a raw-mode terminal. A read holds what
+// one key press or paste produced, so an ESC at its end is the escape key itself.
+func decodeKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		name, n := decodeKey(b)
+		keys = append(keys, name)
+		b = b[n:]
+	}
+	return keys
+}
+
+func decodeKey(b []byte) (string, int) {
+	c := b[0]
+	switch {
+	case c == 0x1b:
+		return decodeEscape(b)
+	case c == '\r' || c == '\n':
+		return "enter", 1
+	case c == '\t':
+		return "tab", 1
+	case c == 0x7f || c == 0x08:
+		return "backspace", 1
+	case c == 0:
+		return "ctrl+space", 1
+	case c < 0x20:
+		return "ctrl+" + string(rune('a'+c-1)), 1
+	}
+	r, size := utf8.DecodeRune(b)
+	if r == utf8.RuneError && size <= 1 {
+		return fmt.Sprintf("0x%02x", c), 1
+	}
+	if r == ' ' {
+		return "space", size
+	}
+	return string(r), size
+}
+
+func decodeEscape(b []byte) (string, int) {
+	if len(b) == 1 {
+		return "esc", 1
+	}
+	switch b[1] {
+	case '[':
+		// Bracketed paste: report the size, not the content
+		if strings.HasPrefix(string(b), "\033[200~") {
+			rest := b[6:]
+			if end := strings.Index(string(rest), "\033[201~"); end >= 0 {
+				return fmt.Sprintf("paste(%d bytes)", end), 6 + end + 6
+			}
+			return fmt.Sprintf("paste(%d bytes)", len(rest)), len(b)
+		}
+		for j := 2; j < len(b); j++ {
+			if b[j] >= 0x40 && b[j] <= 0x7e {
+				return csiKeyName(string(b[2:j]), b[j]), j + 1
+			}
+		}
+		return "esc[", len(b)
+	case 'O': // SS3: arrows in application mode and f1-f4
+		if len(b) > 2 {
+			if name, ok := csiKeys[string(b[2])]; ok {
+				return name, 3
+			}
+			return "ss3+" + string(b[2]), 3
+		}
+		return "alt+O", 2
+	}
+	name, n := decodeKey(b[1:])
+	if name == "esc" {
+		return "esc", 1
+	}
+	return "alt+" + name, 1 + n
+}
+
+func csiKeyName(params string, final byte) string {
+	mod := ""
+	if first, m, ok := strings.Cut(params, ";"); ok {
+		mod = modifierNames[m]
+		params = first
+	}
+	key := string(final)
+	if final == '~' {
+		key = params + "~"
+	}
+	if name, ok := csiKeys[key]; ok {
+		return mod + name
+	}
+	return "csi+" + params + string(final)
+}
+
+// readKeys reports keys read from in until one of k's limits is reached or input ends.
+// The terminal is switched to raw mode for the duration when stdin is one, so keys
+// arrive unprocessed and lines must end in CRLF.
+func readKeys(out io.Writer, in *input, k Keys) {
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		if old, err := term.MakeRaw(fd); err == nil {
+			defer term.Restore(fd, old)
+		}
+	}
+	fmt.Fprint(out, "[keys]\r\n")
+	var timeout <-chan time.Time
+	if k.TimeoutMs > 0 {
+		timeout = time.After(time.Duration(k.TimeoutMs) * time.Millisecond)
+	}
+	count := 0
+	for {
+		data, ok := in.chunk(timeout)
+		if !ok {
+			break
+		}
+		for len(data) > 0 {
+			name, n := decodeKey(data)
+			data = data[n:]
+			fmt.Fprintf(out, "key: %s\r\n", name)
+			count++
+			if name == k.Until || k.Count > 0 && count >= k.Count {
+				in.buf = data // what followed in the same read is left to the next step
+				fmt.Fprintf(out, "[keys done %d]\r\n", count)
+				return
+			}
+		}
+	}
+	fmt.Fprintf(out, "[keys done %d]\r\n", count)
+}
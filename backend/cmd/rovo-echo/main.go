@@ -1,10 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/mattn/go-runewidth"
 )
 
 func clearScreen() {
@@ -38,13 +40,11 @@ func drawInterface(width int, echoHistory []string, content []string, cursorRow,
 	for i := 0; i < height-2; i++ {
 		fmt.Print("│")
 		if i < len(content) {
-			line := content[i]
-			if len(line) > width-2 {
-				line = line[:width-2]
-			}
+			// Truncate and pad by terminal cells: CJK and emoji take two, combining marks none.
+			// Truncate never splits a wide rune, so a line may end one cell short and is padded.
+			line := runewidth.Truncate(content[i], width-2, "")
 			fmt.Print(line)
-			// Pad with spaces
-			for j := len(line); j < width-2; j++ {
+			for j := runewidth.StringWidth(line); j < width-2; j++ {
 				fmt.Print(" ")
 			}
 		} else {
@@ -75,9 +75,32 @@ func drawInterface(width int, echoHistory []string, content []string, cursorRow,
 }
 
 func main() {
-	// Enable raw mode for better terminal control
-	// For simplicity, we'll use line-based input but with custom rendering
+	scenarioPath := flag.String("scenario", "", "Run the scripted scenario in this JSON file (see scenario.go)")
+	keys := flag.Bool("keys", false, "Report every key in raw mode until ctrl+d")
+	flag.Parse()
+
+	in := newInput(os.Stdin)
+	switch {
+	case *keys:
+		readKeys(os.Stdout, in, Keys{Until: "ctrl+d"})
+		return
+	case *scenarioPath != "":
+		sc, err := loadScenario(*scenarioPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		code := (&runner{out: os.Stdout, in: in}).run(sc)
+		if code >= 0 || !sc.Interactive {
+			os.Exit(max(code, 0))
+		}
+	}
+	echoLoop(in)
+}
 
+// echoLoop is the default interactive mode: an input box whose lines are echoed back.
+// A line ending in a backslash continues on the next one and "/exit" quits.
+func echoLoop(in *input) {
 	width, _ := getTerminalSize()
 	if width < 20 {
 		width = 80
@@ -113,22 +136,20 @@ func main() {
 
 		// Calculate cursor position
 		cursorRow := len(displayLines) - 1
-		cursorCol := len(currentDisplay)
+		cursorCol := runewidth.StringWidth(currentDisplay)
+		if cursorCol > width-2 {
+			cursorCol = width - 2
+		}
 
 		// Draw the interface
 		drawInterface(width, echoHistory, displayLines, cursorRow, cursorCol)
 
 		// Read input
-		reader := bufio.NewReader(os.Stdin)
-		input, err := reader.ReadString('\n')
-		if err != nil {
+		input, ok := in.line()
+		if !ok {
 			break
 		}
 
-		// Remove newline
-		input = strings.TrimSuffix(input, "\n")
-		input = strings.TrimSuffix(input, "\r")
-
 		// Check for exit command
 		fullInput := strings.Join(append(inputLines, currentLine+input), "\n")
 		if strings.TrimSpace(fullInput) == "/exit" {
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Scenario is a scripted rovo-echo run read from a JSON file with -scenario. Steps run in
+// order; each does exactly one thing, so a scenario reads as a transcript of what the
+// bridge will see:
+//
+//	{"steps": [
+//	  {"title": "build"},
+//	  {"cwd": "/tmp/project"},
+//	  {"print": "\u001b[32mok\u001b[0m\n"},
+//	  {"flood": {"lines": 5000, "width": 120, "bytesPerSec": 200000}},
+//	  {"prompt": {"text": "continue? ", "delayMs": 500}},
+//	  {"keys": {"until": "ctrl+d"}},
+//	  {"exit": 2}
+//	]}
+type Scenario struct {
+	Steps []Step `json:"steps"`
+	// Interactive continues with the regular echo prompt after the last step
+	Interactive bool `json:"interactive,omitempty"`
+}
+
+// Step is one scenario action; exactly one field is set
+type Step struct {
+	Print     string  `json:"print,omitempty"`     // written as is, escape sequences included
+	SleepMs   int     `json:"sleepMs,omitempty"`   // pause
+	Title     string  `json:"title,omitempty"`     // OSC 0 window title
+	Cwd       string  `json:"cwd,omitempty"`       // OSC 7 working directory
+	Colors    bool    `json:"colors,omitempty"`    // SGR exercise: attributes, 16, 256 and 24-bit colors
+	Cursor    bool    `json:"cursor,omitempty"`    // cursor exercise: absolute moves, save/restore, erase
+	AltScreen *bool   `json:"altScreen,omitempty"` // enter (true) or leave (false) the alternate screen
+	Flood     *Flood  `json:"flood,omitempty"`
+	Prompt    *Prompt `json:"prompt,omitempty"`
+	Keys      *Keys   `json:"keys,omitempty"`
+	Exit      *int    `json:"exit,omitempty"` // exit code; ends the scenario
+}
+
+// Flood writes numbered lines as fast as allowed, then a "[flood done N]" marker
+type Flood struct {
+	Lines       int `json:"lines"`
+	Width       int `json:"width,omitempty"`       // line length without the newline; default 80
+	BytesPerSec int `json:"bytesPerSec,omitempty"` // 0 = unthrottled
+}
+
+// Prompt shows Text after DelayMs, reads a line and echoes it back
+type Prompt struct {
+	Text    string `json:"text"`
+	DelayMs int    `json:"delayMs,omitempty"`
+}
+
+// Keys puts the terminal in raw mode and reports every key as "key: <name>" until Count
+// keys were read, the Until key is pressed or TimeoutMs passes; at least one must be set
+type Keys struct {
+	Count     int    `json:"count,omitempty"`
+	Until     string `json:"until,omitempty"` // key name as reported, e.g. "q" or "ctrl+d"
+	TimeoutMs int    `json:"timeoutMs,omitempty"`
+}
+
+// loadScenario reads and validates a scenario file
+func loadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, st := range sc.Steps {
+		if err := st.validate(); err != nil {
+			return nil, fmt.Errorf("%s: step %d: %w", path, i+1, err)
+		}
+	}
+	return &sc, nil
+}
+
+func (st Step) validate() error {
+	set := 0
+	for _, ok := range []bool{st.Print != "", st.SleepMs != 0, st.Title != "", st.Cwd != "", st.Colors, st.Cursor,
+		st.AltScreen != nil, st.Flood != nil, st.Prompt != nil, st.Keys != nil, st.Exit != nil} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set == 0:
+		return errors.New("no action")
+	case set > 1:
+		return errors.New("more than one action")
+	case st.SleepMs < 0:
+		return errors.New("negative sleepMs")
+	case st.Flood != nil && (st.Flood.Lines <= 0 || st.Flood.Width < 0 || st.Flood.BytesPerSec < 0):
+		return errors.New("flood needs a positive line count")
+	case st.Keys != nil && st.Keys.Count <= 0 && st.Keys.Until == "" && st.Keys.TimeoutMs <= 0:
+		return errors.New("keys needs count, until or timeoutMs")
+	}
+	return nil
+}
+
+// runner executes scenario steps against the terminal
+type runner struct {
+	out io.Writer
+	in  *input
+}
+
+// run executes the steps and returns the exit code of an exit step, or -1 when the
+// scenario ran to its end
+func (r *runner) run(sc *Scenario) int {
+	for _, st := range sc.Steps {
+		if code, done := r.step(st); done {
+			return code
+		}
+	}
+	return -1
+}
+
+func (r *runner) step(st Step) (int, bool) {
+	switch {
+	case st.Print != "":
+		fmt.Fprint(r.out, st.Print)
+	case st.SleepMs > 0:
+		time.Sleep(time.Duration(st.SleepMs) * time.Millisecond)
+	case st.Title != "":
+		fmt.Fprintf(r.out, "\033]0;%s\007", st.Title)
+	case st.Cwd != "":
+		fmt.Fprintf(r.out, "\033]7;%s\007", cwdURL(st.Cwd))
+	case st.Colors:
+		writeColors(r.out)
+	case st.Cursor:
+		writeCursorMoves(r.out)
+	case st.AltScreen != nil:
+		if *st.AltScreen {
+			fmt.Fprint(r.out, "\033[?1049h\033[H")
+		} else {
+			fmt.Fprint(r.out, "\033[?1049l")
+		}
+	case st.Flood != nil:
+		flood(r.out, *st.Flood)
+	case st.Prompt != nil:
+		time.Sleep(time.Duration(st.Prompt.DelayMs) * time.Millisecond)
+		fmt.Fprint(r.out, st.Prompt.Text)
+		line, ok := r.in.line()
+		if !ok && line == "" {
+			return 0, true
+		}
+		fmt.Fprintf(r.out, "Echo: %s\n", line)
+	case st.Keys != nil:
+		readKeys(r.out, r.in, *st.Keys)
+	case st.Exit != nil:
+		return *st.Exit, true
+	}
+	return 0, false
+}
+
+// cwdURL formats dir as the file URL of an OSC 7 report
+func cwdURL(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	p := filepath.ToSlash(dir)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // C:/x -> /C:/x
+	}
+	host, _ := os.Hostname()
+	return (&url.URL{Scheme: "file", Host: host, Path: p}).String()
+}
+
+func writeColors(w io.Writer) {
+	fmt.Fprint(w, "\033[1mbold\033[0m \033[2mdim\033[0m \033[3mitalic\033[0m \033[4munderline\033[0m \033[7mreverse\033[0m \033[9mstrike\033[0m\n")
+	for i := 0; i < 16; i++ {
+		code := 30 + i
+		if i >= 8 {
+			code = 90 + i - 8
+		}
+		fmt.Fprintf(w, "\033[%dm%3d\033[0m", code, i)
+	}
+	fmt.Fprintln(w)
+	for i := 16; i < 256; i++ {
+		fmt.Fprintf(w, "\033[48;5;%dm \033[0m", i)
+		if (i-15)%36 == 0 {
+			fmt.Fprintln(w)
+		}
+	}
+	for i := 0; i < 72; i++ {
+		fmt.Fprintf(w, "\033[48;2;%d;%d;%dm \033[0m", i*255/71, 128, 255-i*255/71)
+	}
+	fmt.Fprintln(w)
+}
+
+func writeCursorMoves(w io.Writer) {
+	fmt.Fprint(w, "\0337")                            // save
+	fmt.Fprint(w, "\033[?25l")                        // hide
+	fmt.Fprint(w, "\033[1;1H┌\033[1;20H┐")            // absolute moves
+	fmt.Fprint(w, "\033[5;1H└\033[5;20H┘")            // corners of a box
+	fmt.Fprint(w, "\033[3;3Hcentered\033[3;6H\033[K") // erase to end of line
+	fmt.Fprint(w, "\033[2;2H\033[3C*\033[2D+")        // relative moves
+	fmt.Fprint(w, "\033[?25h")                        // show
+	fmt.Fprint(w, "\0338")                            // restore
+	fmt.Fprint(w, "\r\033[2Kline erased and rewritten\n")
+}
+
+func flood(w io.Writer, f Flood) {
+	width := f.Width
+	if width <= 0 {
+		width = 80
+	}
+	bw := bufio.NewWriterSize(w, 32*1024)
+	start := time.Now()
+	var sent int
+	for i := 1; i <= f.Lines; i++ {
+		line := fmt.Sprintf("flood %07d ", i)
+		if len(line) < width {
+			line += strings.Repeat(string(rune('a'+i%26)), width-len(line))
+		}
+		line = line[:width] + "\n"
+		bw.WriteString(line)
+		sent += len(line)
+		if f.BytesPerSec > 0 {
+			// Sleep until the bytes sent so far are due at the configured rate
+			due := start.Add(time.Duration(float64(sent) / float64(f.BytesPerSec) * float64(time.Second)))
+			if d := time.Until(due); d > 10*time.Millisecond {
+				bw.Flush()
+				time.Sleep(d)
+			}
+		}
+	}
+	fmt.Fprintf(bw, "[flood done %d]\n", f.Lines)
+	bw.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	cases := map[string]string{
+		"a":                        "a",
+		"漢":                        "漢",
+		"\r":                       "enter",
+		"\x7f":                     "backspace",
+		"\x03":                     "ctrl+c",
+		"\x1b":                     "esc",
+		"\x1bx":                    "alt+x",
+		"\x1b[A":                   "up",
+		"\x1bOB":                   "down",
+		"\x1b[1;5C":                "ctrl+right",
+		"\x1b[3~":                  "delete",
+		"\x1b[15~":                 "f5",
+		"\x1b[200~pasted\x1b[201~": "paste(6 bytes)",
+		"ab\x1b[D ":                "a b left space",
+	}
+	for in, want := range cases {
+		if got := strings.Join(decodeKeys([]byte(in)), " "); got != want {
+			t.Errorf("decodeKeys(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLoadScenario_Validates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(body string) string {
+		p := filepath.Join(dir, "s.json")
+		if err := os.WriteFile(p, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	for _, bad := range []string{
+		`{"steps": [{}]}`,
+		`{"steps": [{"print": "x", "sleepMs": 5}]}`,
+		`{"steps": [{"flood": {"lines": 0}}]}`,
+		`{"steps": [{"keys": {}}]}`,
+		`{"steps": [{"typo": 1}]}`,
+	} {
+		if _, err := loadScenario(write(bad)); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+	sc, err := loadScenario(write(`{"steps": [{"print": "x"}, {"exit": 0}], "interactive": true}`))
+	if err != nil || len(sc.Steps) != 2 || !sc.Interactive {
+		t.Fatalf("loadScenario: %+v, %v", sc, err)
+	}
+}
+
+func TestRunner_RunsSteps(t *testing.T) {
+	exit := 4
+	sc := &Scenario{Steps: []Step{
+		{Title: "demo"},
+		{Cwd: "/tmp/work"},
+		{Print: "hello\n"},
+		{Flood: &Flood{Lines: 3, Width: 20}},
+		{Prompt: &Prompt{Text: "name? "}},
+		{Keys: &Keys{Until: "q"}},
+		{Prompt: &Prompt{Text: "again? "}},
+		{Exit: &exit},
+		{Print: "not reached"},
+	}}
+	var out bytes.Buffer
+	in := newInput(strings.NewReader("bob\nx\x1b[Aq" + "rest\n"))
+	if code := (&runner{out: &out, in: in}).run(sc); code != 4 {
+		t.Fatalf("exit code %d", code)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"\x1b]0;demo\x07",
+		"\x1b]7;file://",
+		"/tmp/work\x07",
+		"hello\n",
+		"flood 0000003 dddddd\n[flood done 3]\n",
+		"name? Echo: bob\n",
+		"key: x\r\nkey: up\r\nkey: q\r\n[keys done 3]\r\n",
+		"again? Echo: rest\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output lacks %q:\n%q", want, got)
+		}
+	}
+	if strings.Contains(got, "not reached") {
+		t.Fatalf("steps ran after exit:\n%q", got)
+	}
+}
@@ -0,0 +1,401 @@
+// Package e2e drives a real rovo-bridge binary over HTTP and WebSocket and compares the
+// protocol exchange with golden transcripts, so changes that would break the IDE
+// plugins show up as transcript diffs. Run with -update to rewrite the transcripts.
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/example/rovobridge/internal/protocol"
+)
+
+var update = flag.Bool("update", false, "rewrite golden transcripts")
+
+// echoEnv makes the test binary act as the scripted session command (see TestHelperEcho)
+const echoEnv = "ROVO_E2E_ECHO=1"
+
+// readyMarker is printed by the echo helper whenever it waits for input
+const readyMarker = "[ready]"
+
+// readyPattern makes the session ready once the helper first prints readyMarker
+const readyPattern = `^\[ready\]$`
+
+// TestHelperEcho is not a test: started by the bridge with echoEnv set, it echoes every
+// input line, prints readyMarker after each complete (non-continued) input and exits
+// with code 3 on "/exit".
+func TestHelperEcho(t *testing.T) {
+	if os.Getenv("ROVO_E2E_ECHO") != "1" {
+		return
+	}
+	fmt.Println(readyMarker)
+	sc := bufio.NewScanner(os.Stdin)
+	sc.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "/exit" {
+			fmt.Println("bye")
+			os.Exit(3)
+		}
+		fmt.Printf("echo: %s\n", line)
+		if !strings.HasSuffix(line, "\\") {
+			fmt.Println(readyMarker)
+		}
+	}
+	os.Exit(0)
+}
+
+// bridge is a running rovo-bridge process
+type bridge struct {
+	port  int
+	token string
+	work  string
+}
+
+func startBridge(t *testing.T) *bridge {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds and runs the bridge binary")
+	}
+	tmp := t.TempDir()
+	exe := filepath.Join(tmp, "rovo-bridge")
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	build := exec.Command("go", "build", "-o", exe, "github.com/example/rovobridge/cmd/rovo-bridge")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	work := filepath.Join(tmp, "work")
+	home := filepath.Join(tmp, "home")
+	for _, d := range []string{work, home} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(exe, "-serve-ui=false")
+	cmd.Dir = work
+	cmd.Env = append(os.Environ(), "HOME="+home, "USERPROFILE="+home)
+	var logs bytes.Buffer
+	cmd.Stderr = &logs
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		if t.Failed() {
+			t.Logf("bridge log:\n%s", logs.String())
+		}
+	})
+
+	var info struct {
+		Port  int    `json:"port"`
+		Token string `json:"token"`
+	}
+	// Indexer progress is printed to stdout as well; the connection info is the JSON line
+	sc := bufio.NewScanner(stdout)
+	for info.Port == 0 {
+		if !sc.Scan() {
+			t.Fatalf("bridge exited without connection info: %v", sc.Err())
+		}
+		_ = json.Unmarshal(sc.Bytes(), &info)
+	}
+	go func() { _, _ = io.Copy(io.Discard, stdout) }()
+	return &bridge{port: info.Port, token: info.Token, work: work}
+}
+
+// dial opens a WebSocket with the given token (empty: no auth subprotocol)
+func (b *bridge) dial(token string) (*websocket.Conn, int, error) {
+	d := websocket.Dialer{}
+	if token != "" {
+		d.Subprotocols = []string{"auth.bearer." + token}
+	}
+	h := http.Header{}
+	h.Set("Origin", "http://localhost")
+	c, resp, err := d.Dial(fmt.Sprintf("ws://127.0.0.1:%d/ws", b.port), h)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	return c, status, err
+}
+
+func (b *bridge) get(path, token string) int {
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d%s", b.port, path), nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// transcript records the protocol exchange in a normalized, comparable form
+type transcript struct {
+	t     *testing.T
+	b     *bridge
+	lines []string
+}
+
+func (tr *transcript) add(dir string, v any) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		tr.t.Fatal(err)
+	}
+	s := strings.ReplaceAll(strings.TrimSuffix(buf.String(), "\n"), tr.b.work, "$WORK")
+	tr.lines = append(tr.lines, dir+" "+s)
+}
+
+func (tr *transcript) send(c *websocket.Conn, m map[string]any) {
+	rec := make(map[string]any, len(m))
+	for k, v := range m {
+		rec[k] = v
+	}
+	tr.add(">", normalize(rec))
+	tr.t.Helper()
+	tr.validate(protocol.FromClient, m)
+	if err := c.WriteJSON(m); err != nil {
+		tr.t.Fatalf("send %v: %v", m["type"], err)
+	}
+}
+
+// expect reads until a message of type typ arrives and records it. Output-related
+// events are skipped; stdout must be awaited with expectOutput.
+func (tr *transcript) expect(c *websocket.Conn, typ string) map[string]any {
+	tr.t.Helper()
+	for {
+		m := tr.read(c)
+		if m["type"] == typ {
+			tr.add("<", normalize(m))
+			return m
+		}
+	}
+}
+
+// expectOutput collects stdout until it contains marker and records it as one message
+func (tr *transcript) expectOutput(c *websocket.Conn, sid, marker string) {
+	tr.t.Helper()
+	var out []byte
+	for !bytes.Contains(out, []byte(marker)) {
+		m := tr.read(c)
+		if m["type"] != "stdout" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(m["dataBase64"].(string))
+		if err != nil {
+			tr.t.Fatalf("bad stdout payload: %v", err)
+		}
+		out = append(out, data...)
+	}
+	tr.add("<", map[string]any{"type": "stdout", "sessionId": sid, "text": string(out)})
+}
+
+func (tr *transcript) read(c *websocket.Conn) map[string]any {
+	tr.t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	_, data, err := c.ReadMessage()
+	var m map[string]any
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		tr.t.Fatalf("read: %v\ntranscript so far:\n%s", err, strings.Join(tr.lines, "\n"))
+	}
+	tr.validate(protocol.FromServer, m)
+	return m
+}
+
+// validate checks a message against the published JSON Schema
+func (tr *transcript) validate(dir protocol.Direction, m map[string]any) {
+	tr.t.Helper()
+	buf, err := json.Marshal(m)
+	if err == nil {
+		err = protocol.ValidateMessage(dir, buf)
+	}
+	if err != nil {
+		tr.t.Errorf("%s message does not match the schema: %v", strings.ToLower(string(dir)), err)
+	}
+}
+
+// normalize replaces values that differ between runs (process ids, session ids, sequence
+// numbers, stream offsets and sizes that depend on temporary paths)
+func normalize(m map[string]any) map[string]any {
+	if sid, ok := m["sessionId"].(string); ok && strings.HasPrefix(sid, "ses_") {
+		m["sessionId"] = "<session>"
+	}
+	for _, k := range []string{"pid", "seq", "lastSeq", "offset", "afterMs"} {
+		if _, ok := m[k]; ok {
+			m[k] = "<n>"
+		}
+	}
+	if data, ok := m["dataBase64"].(string); ok {
+		if b, err := base64.StdEncoding.DecodeString(data); err == nil {
+			delete(m, "dataBase64")
+			m["text"] = string(b)
+		}
+	}
+	if files, ok := m["files"].([]any); ok {
+		for _, f := range files {
+			if item, ok := f.(map[string]any); ok {
+				for _, k := range []string{"bytes", "tokens"} {
+					if _, ok := item[k]; ok {
+						item[k] = "<n>"
+					}
+				}
+			}
+		}
+	}
+	return m
+}
+
+// check compares the transcript with testdata/<name>.golden
+func (tr *transcript) check(name string) {
+	tr.t.Helper()
+	got := strings.Join(tr.lines, "\n") + "\n"
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			tr.t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tr.t.Fatalf("missing golden transcript (run with -update): %v", err)
+	}
+	if got != strings.ReplaceAll(string(want), "\r\n", "\n") {
+		tr.t.Errorf("transcript differs from %s (run with -update to accept):\n--- got\n%s--- want\n%s", path, got, want)
+	}
+}
+
+func TestProtocol_Auth(t *testing.T) {
+	b := startBridge(t)
+	tr := &transcript{t: t, b: b}
+
+	for _, tc := range []struct{ name, token string }{{"no token", ""}, {"wrong token", "wrong"}} {
+		_, status, err := b.dial(tc.token)
+		tr.add("ws", map[string]any{"case": tc.name, "connected": err == nil, "status": status})
+	}
+	c, status, err := b.dial(b.token)
+	if err != nil {
+		t.Fatalf("dial with valid token: %v (status %d)", err, status)
+	}
+	c.Close()
+	tr.add("ws", map[string]any{"case": "valid token", "connected": true, "status": status})
+
+	for _, tc := range []struct{ path, token string }{
+		{"/health", ""},
+		{"/font-size", ""},
+		{"/font-size", "wrong"},
+		{"/font-size", b.token},
+		{"/index", ""},
+		{"/schema", ""},
+		{"/schema", b.token},
+	} {
+		auth := "none"
+		switch tc.token {
+		case b.token:
+			auth = "valid"
+		case "wrong":
+			auth = "wrong"
+		}
+		tr.add("http", map[string]any{"path": tc.path, "auth": auth, "status": b.get(tc.path, tc.token)})
+	}
+	tr.check("auth")
+}
+
+func TestProtocol_Session(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("transcripts record forward-slash paths")
+	}
+	b := startBridge(t)
+	tr := &transcript{t: t, b: b}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b.work, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _, err := b.dial(b.token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.send(c, map[string]any{"type": "hello"})
+	tr.expect(c, "welcome")
+
+	open := map[string]any{
+		"type": "openSession", "id": "e2e", "cwd": b.work, "pty": false, "useClipboard": false,
+		"cmd": exe, "args": []string{"-test.run=^TestHelperEcho$"}, "env": []string{echoEnv}, "readyPattern": readyPattern,
+	}
+	// The helper path differs per run; record the message without it
+	tr.add(">", map[string]any{"type": "openSession", "id": "e2e", "cwd": b.work, "pty": false, "useClipboard": false, "cmd": "<echo helper>", "readyPattern": readyPattern})
+	if err := c.WriteJSON(open); err != nil {
+		t.Fatal(err)
+	}
+	tr.expect(c, "opened")
+	tr.expectOutput(c, "e2e", readyMarker)
+	tr.expect(c, "ready")
+
+	tr.send(c, map[string]any{"type": "stdin", "sessionId": "e2e", "dataBase64": base64.StdEncoding.EncodeToString([]byte("hi\n"))})
+	tr.expectOutput(c, "e2e", readyMarker)
+
+	// Detach and resume: the session survives and its output is replayed
+	c.Close()
+	c, _, err = b.dial(b.token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tr.send(c, map[string]any{"type": "openSession", "id": "e2e", "resume": true})
+	tr.expect(c, "opened")
+	tr.expect(c, "snapshot")
+	tr.send(c, map[string]any{"type": "snapshot", "sessionId": "e2e"})
+	tr.expect(c, "snapshot")
+
+	// Send a prompt with a file; the missing one holds it back until acknowledged, then
+	// without the clipboard it is typed with escaped newlines
+	send := map[string]any{
+		"type": "send", "sessionId": "e2e",
+		"dataBase64": base64.StdEncoding.EncodeToString([]byte("review this")),
+		"paths":      []string{filepath.Join(b.work, "main.go"), filepath.Join(b.work, "missing.go")},
+	}
+	tr.send(c, send)
+	tr.expect(c, "promptLint")
+	send["lintAcknowledged"] = true
+	tr.send(c, send)
+	tr.expect(c, "injectResult")
+	tr.send(c, map[string]any{"type": "stdin", "sessionId": "e2e", "dataBase64": base64.StdEncoding.EncodeToString([]byte("\n"))})
+	tr.expectOutput(c, "e2e", readyMarker)
+
+	tr.send(c, map[string]any{"type": "stdin", "sessionId": "e2e", "dataBase64": base64.StdEncoding.EncodeToString([]byte("/exit\n"))})
+	tr.expect(c, "exit")
+	tr.check("session")
+}
@@ -0,0 +1,72 @@
+// Package diagnostics recognizes compiler and test-runner error lines in plain-text
+// terminal output, so the UI can render them as clickable, sendable errors.
+package diagnostics
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a file/line reference with a message extracted from an output line
+type Diagnostic struct {
+	Tool     string `json:"tool"` // "go", "typescript", "pytest", "gradle"
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // "error" or "warning"
+	Message  string `json:"message"`
+}
+
+// matcher maps the submatches of one output format onto a Diagnostic
+type matcher struct {
+	tool string
+	re   *regexp.Regexp
+	// submatch indexes; 0 means absent
+	file, line, col, severity, msg int
+}
+
+var matchers = []matcher{
+	// tsc: src/a.ts(12,5): error TS2322: Type ... / src/a.ts:12:5 - error TS2322: Type ...
+	{tool: "typescript", re: regexp.MustCompile(`^(\S+\.[cm]?tsx?)\((\d+),(\d+)\): (error|warning) (TS\d+: .+)$`), file: 1, line: 2, col: 3, severity: 4, msg: 5},
+	{tool: "typescript", re: regexp.MustCompile(`^(\S+\.[cm]?tsx?):(\d+):(\d+) - (error|warning) (TS\d+: .+)$`), file: 1, line: 2, col: 3, severity: 4, msg: 5},
+	// go build/vet/test: ./pkg/a.go:12:5: undefined: x / a_test.go:30: expected 1, got 2
+	{tool: "go", re: regexp.MustCompile(`^\s*(\S+\.go):(\d+):(\d+): (.+)$`), file: 1, line: 2, col: 3, msg: 4},
+	{tool: "go", re: regexp.MustCompile(`^\s*(\S+\.go):(\d+): (.+)$`), file: 1, line: 2, msg: 3},
+	// pytest: tests/test_a.py:12: AssertionError / FAILED tests/test_a.py::test_x - assert 1 == 2
+	{tool: "pytest", re: regexp.MustCompile(`^(\S+\.py):(\d+): (\w*(?:Error|Exception|Failed)\b.*)$`), file: 1, line: 2, msg: 3},
+	{tool: "pytest", re: regexp.MustCompile(`^(?:FAILED|ERROR) (\S+\.py)::(\S+(?: - .+)?)$`), file: 1, msg: 2},
+	// gradle (kotlinc/javac): e: file:///src/A.kt:10:5 Unresolved reference / A.java:10: error: ...
+	{tool: "gradle", re: regexp.MustCompile(`^([ew]): (?:file://)?(\S+\.kts?):(\d+):(\d+) (.+)$`), severity: 1, file: 2, line: 3, col: 4, msg: 5},
+	{tool: "gradle", re: regexp.MustCompile(`^([ew]): (\S+\.kts?): \((\d+), (\d+)\): (.+)$`), severity: 1, file: 2, line: 3, col: 4, msg: 5},
+	{tool: "gradle", re: regexp.MustCompile(`^(\S+\.java):(\d+): (error|warning): (.+)$`), file: 1, line: 2, severity: 3, msg: 4},
+}
+
+// Parse recognizes a single plain-text output line. It reports false for lines that
+// are not diagnostics of a supported tool.
+func Parse(line string) (Diagnostic, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if len(line) < 6 || len(line) > 2000 {
+		return Diagnostic{}, false
+	}
+	for _, m := range matchers {
+		sm := m.re.FindStringSubmatch(line)
+		if sm == nil {
+			continue
+		}
+		d := Diagnostic{Tool: m.tool, File: sm[m.file], Message: sm[m.msg], Severity: "error"}
+		if m.line > 0 {
+			d.Line, _ = strconv.Atoi(sm[m.line])
+		}
+		if m.col > 0 {
+			d.Column, _ = strconv.Atoi(sm[m.col])
+		}
+		if m.severity > 0 {
+			if s := sm[m.severity]; s == "warning" || s == "w" {
+				d.Severity = "warning"
+			}
+		}
+		return d, true
+	}
+	return Diagnostic{}, false
+}
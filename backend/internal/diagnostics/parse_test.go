@@ -0,0 +1,45 @@
+package diagnostics
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		line string
+		want Diagnostic
+	}{
+		{"./internal/ws/router.go:120:5: undefined: foo",
+			Diagnostic{Tool: "go", File: "./internal/ws/router.go", Line: 120, Column: 5, Severity: "error", Message: "undefined: foo"}},
+		{"    search_test.go:42: expected 3 results, got 2",
+			Diagnostic{Tool: "go", File: "search_test.go", Line: 42, Severity: "error", Message: "expected 3 results, got 2"}},
+		{"src/ui/app.ts(10,7): error TS2322: Type 'string' is not assignable to type 'number'.",
+			Diagnostic{Tool: "typescript", File: "src/ui/app.ts", Line: 10, Column: 7, Severity: "error", Message: "TS2322: Type 'string' is not assignable to type 'number'."}},
+		{"src/ui/app.tsx:3:1 - warning TS6133: 'x' is declared but its value is never read.",
+			Diagnostic{Tool: "typescript", File: "src/ui/app.tsx", Line: 3, Column: 1, Severity: "warning", Message: "TS6133: 'x' is declared but its value is never read."}},
+		{"tests/test_api.py:27: AssertionError",
+			Diagnostic{Tool: "pytest", File: "tests/test_api.py", Line: 27, Severity: "error", Message: "AssertionError"}},
+		{"FAILED tests/test_api.py::test_login - assert 401 == 200",
+			Diagnostic{Tool: "pytest", File: "tests/test_api.py", Severity: "error", Message: "test_login - assert 401 == 200"}},
+		{"e: file:///home/u/app/src/Main.kt:12:9 Unresolved reference: foo",
+			Diagnostic{Tool: "gradle", File: "/home/u/app/src/Main.kt", Line: 12, Column: 9, Severity: "error", Message: "Unresolved reference: foo"}},
+		{"w: src/Main.kt: (4, 2): Parameter 'x' is never used",
+			Diagnostic{Tool: "gradle", File: "src/Main.kt", Line: 4, Column: 2, Severity: "warning", Message: "Parameter 'x' is never used"}},
+		{"src/main/java/App.java:15: error: cannot find symbol",
+			Diagnostic{Tool: "gradle", File: "src/main/java/App.java", Line: 15, Severity: "error", Message: "cannot find symbol"}},
+	}
+	for _, c := range cases {
+		got, ok := Parse(c.line)
+		if !ok {
+			t.Errorf("Parse(%q) did not match", c.line)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", c.line, got, c.want)
+		}
+	}
+
+	for _, line := range []string{"", "ok  \tgithub.com/x/y\t0.1s", "Building project...", "see http://x.go:80: nothing"} {
+		if d, ok := Parse(line); ok {
+			t.Errorf("Parse(%q) unexpectedly matched: %+v", line, d)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package doctor checks the environment the bridge depends on (PTY support, clipboard
+// utilities, file watching limits, the agent CLI and the history file) and reports the
+// results as a pass/warn/fail list that can be attached to support tickets.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/example/rovobridge/internal/history"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// versionTimeout bounds running the agent CLI to ask for its version
+const versionTimeout = 5 * time.Second
+
+// Check is the result of one environment check
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Report is the result of all checks
+type Report struct {
+	OS        string  `json:"os"`
+	Arch      string  `json:"arch"`
+	GoVersion string  `json:"goVersion"`
+	Checks    []Check `json:"checks"`
+}
+
+// Options selects what is checked
+type Options struct {
+	Command string                  // the session command; empty checks the default agent CLI
+	History *history.HistoryManager // nil checks the default history file
+}
+
+// Run performs every check
+func Run(ctx context.Context, opts Options) Report {
+	rep := Report{OS: runtime.GOOS, Arch: runtime.GOARCH, GoVersion: runtime.Version()}
+	rep.Checks = append(rep.Checks, checkPTY()...)
+	rep.Checks = append(rep.Checks,
+		checkClipboard(),
+		checkWatchLimits(),
+		checkCommand(ctx, opts.Command),
+		checkHistory(opts.History),
+	)
+	return rep
+}
+
+// Worst returns the most severe status in the report
+func (r Report) Worst() Status {
+	worst := Pass
+	for _, c := range r.Checks {
+		if c.Status == Fail {
+			return Fail
+		}
+		if c.Status == Warn {
+			worst = Warn
+		}
+	}
+	return worst
+}
+
+// Print writes the report as an aligned table
+func (r Report) Print(w io.Writer) error {
+	fmt.Fprintf(w, "rovo-bridge doctor (%s/%s, %s)\n\n", r.OS, r.Arch, r.GoVersion)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range r.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.ToUpper(string(c.Status)), c.Name, c.Detail)
+	}
+	return tw.Flush()
+}
+
+func checkClipboard() Check {
+	var groups [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		groups = [][]string{{"pbcopy", "pbpaste"}}
+	case "windows":
+		groups = [][]string{{"powershell"}}
+	default:
+		groups = [][]string{{"wl-copy", "wl-paste"}, {"xclip"}, {"xsel"}}
+	}
+	var tried []string
+	for _, tools := range groups {
+		ok := true
+		for _, t := range tools {
+			if _, err := exec.LookPath(t); err != nil {
+				ok = false
+			}
+		}
+		if ok {
+			return Check{Name: "clipboard", Status: Pass, Detail: "using " + strings.Join(tools, "/")}
+		}
+		tried = append(tried, strings.Join(tools, "/"))
+	}
+	return Check{Name: "clipboard", Status: Warn, Detail: "no clipboard utility found (tried " + strings.Join(tried, ", ") + "); prompts are typed into the session instead of pasted"}
+}
+
+func checkCommand(ctx context.Context, command string) Check {
+	name, args := "acli", []string{"--version"}
+	if fields := strings.Fields(command); len(fields) > 0 {
+		name, args = fields[0], nil
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return Check{Name: "agent", Status: Fail, Detail: fmt.Sprintf("%s not found in PATH", name)}
+	}
+	if args == nil {
+		return Check{Name: "agent", Status: Pass, Detail: path}
+	}
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if err != nil {
+		return Check{Name: "agent", Status: Warn, Detail: fmt.Sprintf("%s found but %s --version failed: %v", path, name, err)}
+	}
+	return Check{Name: "agent", Status: Pass, Detail: fmt.Sprintf("%s (%s)", path, version)}
+}
+
+func checkHistory(h *history.HistoryManager) Check {
+	if h == nil {
+		h = history.NewHistoryManager()
+	}
+	path := h.GetHistoryFilePath()
+	if path == "" {
+		return Check{Name: "history", Status: Pass, Detail: "kept in memory; nothing is saved across restarts"}
+	}
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Check{Name: "history", Status: Pass, Detail: path + " does not exist yet"}
+	}
+	if err == nil {
+		err = h.ValidateHistoryFile()
+	}
+	if err != nil {
+		return Check{Name: "history", Status: Warn, Detail: fmt.Sprintf("%s: %v; it will be restored from a backup or reset", path, err)}
+	}
+	return Check{Name: "history", Status: Pass, Detail: fmt.Sprintf("%s (%d bytes)", path, fi.Size())}
+}
@@ -0,0 +1,76 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReport_WorstAndPrint(t *testing.T) {
+	rep := Report{OS: "linux", Arch: "amd64", GoVersion: "go1", Checks: []Check{
+		{Name: "pty", Status: Pass, Detail: "ok"},
+		{Name: "clipboard", Status: Warn, Detail: "none"},
+	}}
+	if rep.Worst() != Warn {
+		t.Fatalf("worst = %s, want warn", rep.Worst())
+	}
+	rep.Checks = append(rep.Checks, Check{Name: "agent", Status: Fail, Detail: "missing"})
+	if rep.Worst() != Fail {
+		t.Fatalf("worst = %s, want fail", rep.Worst())
+	}
+	var buf bytes.Buffer
+	if err := rep.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "FAIL  agent      missing") || !strings.Contains(out, "(linux/amd64, go1)") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	if c := checkCommand(context.Background(), "definitely-not-a-rovobridge-command --flag"); c.Status != Fail {
+		t.Fatalf("missing command: %+v", c)
+	}
+	if c := checkCommand(context.Background(), "go run"); c.Status != Pass || !strings.Contains(c.Detail, "go") {
+		t.Fatalf("custom command: %+v", c)
+	}
+}
+
+func TestCheckHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	if c := checkHistory(nil); c.Status != Pass || !strings.Contains(c.Detail, "does not exist") {
+		t.Fatalf("missing history: %+v", c)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".rovobridge"), []byte(`{"version": "1.0", "entries": [`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if c := checkHistory(nil); c.Status != Warn {
+		t.Fatalf("corrupted history: %+v", c)
+	}
+}
+
+func TestRun_ReportsEveryCheck(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	rep := Run(context.Background(), Options{Command: "go"})
+	names := map[string]bool{}
+	for _, c := range rep.Checks {
+		if c.Status != Pass && c.Status != Warn && c.Status != Fail {
+			t.Fatalf("bad status in %+v", c)
+		}
+		names[c.Name] = true
+	}
+	for _, want := range []string{"clipboard", "fsnotify", "agent", "history"} {
+		if !names[want] {
+			t.Fatalf("missing %s check in %+v", want, rep.Checks)
+		}
+	}
+	if !names["pty"] && !names["conpty"] {
+		t.Fatalf("missing pty check in %+v", rep.Checks)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package doctor
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/creack/pty"
+)
+
+// minInotifyWatches is the watch limit below which large workspaces fall back to polling
+const minInotifyWatches = 10000
+
+func checkPTY() []Check {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return []Check{{Name: "pty", Status: Fail, Detail: "cannot open a pseudo-terminal: " + err.Error() + "; sessions run without a PTY"}}
+	}
+	name := tty.Name()
+	ptmx.Close()
+	tty.Close()
+	return []Check{{Name: "pty", Status: Pass, Detail: "opened " + name}}
+}
+
+func checkWatchLimits() Check {
+	if runtime.GOOS != "linux" {
+		return Check{Name: "fsnotify", Status: Pass, Detail: "no inotify limits on " + runtime.GOOS}
+	}
+	data, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return Check{Name: "fsnotify", Status: Warn, Detail: "cannot read inotify limits: " + err.Error()}
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return Check{Name: "fsnotify", Status: Warn, Detail: "cannot parse fs.inotify.max_user_watches"}
+	}
+	detail := "fs.inotify.max_user_watches = " + strconv.Itoa(n)
+	if n < minInotifyWatches {
+		return Check{Name: "fsnotify", Status: Warn, Detail: detail + "; raise it to at least " + strconv.Itoa(minInotifyWatches) + " or large workspaces are indexed by polling"}
+	}
+	return Check{Name: "fsnotify", Status: Pass, Detail: detail}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package doctor
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// conptyMinBuild is the first Windows 10 build (1809) with the ConPTY API
+const conptyMinBuild = 17763
+
+func checkPTY() []Check {
+	v := windows.RtlGetVersion()
+	build := fmt.Sprintf("Windows %d.%d build %d", v.MajorVersion, v.MinorVersion, v.BuildNumber)
+	if v.BuildNumber < conptyMinBuild {
+		return []Check{{Name: "conpty", Status: Fail, Detail: fmt.Sprintf("%s predates ConPTY (build %d); sessions run without a PTY", build, conptyMinBuild)}}
+	}
+	if err := windows.NewLazySystemDLL("kernel32.dll").NewProc("CreatePseudoConsole").Find(); err != nil {
+		return []Check{{Name: "conpty", Status: Fail, Detail: build + ": CreatePseudoConsole unavailable: " + err.Error()}}
+	}
+	return []Check{{Name: "conpty", Status: Pass, Detail: build}}
+}
+
+func checkWatchLimits() Check {
+	return Check{Name: "fsnotify", Status: Pass, Detail: "no watch limits on windows"}
+}
@@ -0,0 +1,162 @@
+package fileutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultReadTimeout bounds reading a single file, e.g. on a dead network mount
+	DefaultReadTimeout = 10 * time.Second
+	// DefaultReadConcurrency is the number of files read in parallel
+	DefaultReadConcurrency = 4
+	// maxReadConcurrency caps client-requested concurrency
+	maxReadConcurrency = 16
+)
+
+// FileResult is the outcome of reading one file for injection
+type FileResult struct {
+	Path      string
+	Content   string // formatted content; empty when Err is set
+	Err       error
+	Language  string
+	Truncated bool // content was summarized or had duplicate blocks elided
+}
+
+// EstimateTokens approximates the token count of injected text (~4 bytes per token)
+func EstimateTokens(content string) int {
+	if content == "" {
+		return 0
+	}
+	return len(content)/4 + 1
+}
+
+// ReadFiles reads files concurrently with a bounded pool and a per-file timeout, and
+// returns one result per path in the same order. Per-file failures are reported in the
+// results rather than as inline text. A read that times out keeps running in the
+// background until the OS returns, but no longer holds up the batch.
+func ReadFiles(paths []string, opts ReadOptions) []FileResult {
+	return ReadFilesContext(context.Background(), paths, opts)
+}
+
+// ReadFilesContext is ReadFiles that also gives up on the files not read yet when ctx
+// ends, reporting ctx's error for them.
+func ReadFilesContext(ctx context.Context, paths []string, opts ReadOptions) []FileResult {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultReadTimeout
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultReadConcurrency
+	} else if concurrency > maxReadConcurrency {
+		concurrency = maxReadConcurrency
+	}
+
+	results := make([]FileResult, len(paths))
+	read := make([]fileLines, len(paths))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		results[i].Path = path
+		if path == "" {
+			results[i].Err = errors.New("empty file path")
+			continue
+		}
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			read[i], results[i].Err = readFileLinesWithTimeout(ctx, path, opts, timeout)
+		}(i, path)
+	}
+	wg.Wait()
+
+	// Formatting runs in order, since duplicate elision refers to earlier files
+	var dups *dupTracker
+	if opts.ElideDuplicates {
+		dups = newDupTracker()
+	}
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		var elisions []elision
+		if dups != nil {
+			elisions = dups.elide(results[i].Path, read[i].lines, read[i].first)
+		}
+		results[i].Content = formatFileContent(results[i].Path, read[i], elisions)
+		results[i].Language = read[i].language
+		results[i].Truncated = read[i].truncated || len(elisions) > 0
+	}
+	return results
+}
+
+func readFileLinesWithTimeout(ctx context.Context, path string, opts ReadOptions, timeout time.Duration) (fileLines, error) {
+	if err := ctx.Err(); err != nil {
+		return fileLines{}, err
+	}
+	type outcome struct {
+		fl  fileLines
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		fl, err := readFileLines(path, opts)
+		done <- outcome{fl, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.fl, o.err
+	case <-timer.C:
+		return fileLines{}, fmt.Errorf("timed out after %s reading %s", timeout, path)
+	case <-ctx.Done():
+		return fileLines{}, ctx.Err()
+	}
+}
+
+// DefaultPreamble introduces injected file contents unless ReadOptions.Preamble is set
+const DefaultPreamble = "The referenced content is provided below. There is no need to read it again."
+
+// FormatFileResults joins the successfully read files under the injection header, in the
+// single-element form returned by ReadMultipleFiles. Failed files are left out; it
+// returns an empty slice when nothing could be read.
+func FormatFileResults(results []FileResult, opts ReadOptions) []string {
+	var parts, paths []string
+	for _, res := range results {
+		if res.Err == nil {
+			parts = append(parts, res.Content, "")
+			paths = append(paths, res.Path)
+		}
+	}
+	if len(parts) == 0 {
+		return []string{}
+	}
+	return []string{strings.Join(append(injectionHeader(opts, paths), parts...), "\n")}
+}
+
+// injectionHeader returns the lines that introduce the injected contents of paths
+func injectionHeader(opts ReadOptions, paths []string) []string {
+	// Add header (with newline at the beginning as requested)
+	header := []string{"", "", ""}
+	if opts.NoPreamble {
+		return header
+	}
+	preamble := opts.Preamble
+	if preamble == "" {
+		preamble = DefaultPreamble
+	}
+	preamble = strings.NewReplacer(
+		"{count}", strconv.Itoa(len(paths)),
+		"{paths}", strings.Join(paths, ", "),
+	).Replace(preamble)
+	return append(header, preamble, "", "---", "")
+}
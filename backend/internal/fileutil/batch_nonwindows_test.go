@@ -0,0 +1,43 @@
+//go:build !windows
+
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReadFiles_Timeout(t *testing.T) {
+	dir := t.TempDir()
+	// Opening a FIFO without a writer blocks, like a read on a dead mount
+	fifo := filepath.Join(dir, "stuck")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("mkfifo unavailable: %v", err)
+	}
+	ok := filepath.Join(dir, "ok.txt")
+	if err := os.WriteFile(ok, []byte("fine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		// Release the blocked reader
+		if w, err := os.OpenFile(fifo, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			w.Close()
+		}
+	}()
+
+	start := time.Now()
+	results := ReadFiles([]string{fifo, ok}, ReadOptions{Timeout: 100 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("ReadFiles did not honor the timeout, took %s", elapsed)
+	}
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %+v", results[0])
+	}
+	if results[1].Err != nil || !strings.Contains(results[1].Content, "fine") {
+		t.Errorf("expected other files to be read, got %+v", results[1])
+	}
+}
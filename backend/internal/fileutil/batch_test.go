@@ -0,0 +1,96 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFiles_OrderAndStructuredErrors(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 12; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("f%02d.txt", i))
+		if err := os.WriteFile(p, []byte(fmt.Sprintf("content %d\n", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+	paths = append(paths, missing, "")
+
+	results := ReadFiles(paths, ReadOptions{Concurrency: 3})
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results, got %d", len(paths), len(results))
+	}
+	for i := 0; i < 12; i++ {
+		if results[i].Err != nil || !strings.Contains(results[i].Content, fmt.Sprintf("content %d", i)) {
+			t.Errorf("result %d out of order or failed: %+v", i, results[i])
+		}
+	}
+	if results[12].Path != missing || results[12].Err == nil || results[12].Content != "" {
+		t.Errorf("expected structured error for missing file, got %+v", results[12])
+	}
+	if results[13].Err == nil {
+		t.Errorf("expected error for empty path")
+	}
+
+	joined := FormatFileResults(results, ReadOptions{})
+	if len(joined) != 1 || strings.Contains(joined[0], "missing.txt") || !strings.Contains(joined[0], "content 11") {
+		t.Errorf("expected only successful files in formatted output: %v", joined)
+	}
+	if got := FormatFileResults(results[12:], ReadOptions{}); len(got) != 0 {
+		t.Errorf("expected no output when every read failed, got %v", got)
+	}
+
+	// The inline form keeps reporting errors in place
+	inline := ReadMultipleFiles(paths)[0]
+	if !strings.Contains(inline, "Error reading "+missing) || !strings.Contains(inline, "Error: empty file path") {
+		t.Errorf("expected inline errors:\n%s", inline)
+	}
+}
+
+func TestReadFiles_ReportsLanguageAndTruncation(t *testing.T) {
+	dir := t.TempDir()
+	goFile := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(goFile, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	b.WriteString("n\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	csvFile := filepath.Join(dir, "big.csv")
+	if err := os.WriteFile(csvFile, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := ReadFiles([]string{goFile, csvFile}, ReadOptions{})
+	if results[0].Language != "go" || results[0].Truncated {
+		t.Errorf("unexpected report for go file: %+v", results[0])
+	}
+	if results[1].Language != "csv" || !results[1].Truncated {
+		t.Errorf("expected truncated csv preview: %+v", results[1])
+	}
+	if n := EstimateTokens(results[0].Content); n <= 0 || n > len(results[0].Content) {
+		t.Errorf("unexpected token estimate %d", n)
+	}
+}
+
+func TestFormatFileResults_Preamble(t *testing.T) {
+	results := []FileResult{{Path: "a.go", Content: "A"}, {Path: "b.go", Content: "B"}}
+
+	if got := FormatFileResults(results, ReadOptions{})[0]; !strings.HasPrefix(got, "\n\n\n"+DefaultPreamble+"\n\n---\n\nA\n") {
+		t.Errorf("expected default preamble, got %q", got)
+	}
+	custom := FormatFileResults(results, ReadOptions{Preamble: "Contexte ({count} fichiers : {paths})"})[0]
+	if !strings.HasPrefix(custom, "\n\n\nContexte (2 fichiers : a.go, b.go)\n\n---\n\n") {
+		t.Errorf("expected expanded custom preamble, got %q", custom)
+	}
+	if got := FormatFileResults(results, ReadOptions{NoPreamble: true})[0]; got != "\n\n\nA\n\nB\n" {
+		t.Errorf("expected contents without preamble, got %q", got)
+	}
+}
@@ -0,0 +1,96 @@
+package fileutil
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// minDupLines is the smallest run of identical lines worth replacing with a note
+const minDupLines = 8
+
+// elision marks lines [start, end] (indexes into a file's lines) as identical to
+// lines srcStart..srcEnd (displayed numbers) of srcPath.
+type elision struct {
+	start, end       int
+	srcPath          string
+	srcStart, srcEnd int
+}
+
+// dupTracker remembers line windows of files already emitted in a batch so later files
+// can reference them instead of repeating generated code or license headers.
+type dupTracker struct {
+	files   []dupFile
+	windows map[uint64]dupLoc // window hash -> first occurrence
+}
+
+type dupFile struct {
+	path  string
+	lines []string
+	first int
+}
+
+type dupLoc struct {
+	file, line int
+}
+
+func newDupTracker() *dupTracker {
+	return &dupTracker{windows: map[uint64]dupLoc{}}
+}
+
+// elide returns the runs of lines that repeat content of earlier files, in order, and
+// then registers this file for the following ones. Repeats within a file are kept.
+func (d *dupTracker) elide(path string, lines []string, first int) []elision {
+	var out []elision
+	for i := 0; i+minDupLines <= len(lines); {
+		loc, ok := d.windows[windowHash(lines[i:i+minDupLines])]
+		if !ok || isBlankWindow(lines[i:i+minDupLines]) {
+			i++
+			continue
+		}
+		src := d.files[loc.file]
+		n := 0
+		for i+n < len(lines) && loc.line+n < len(src.lines) && lines[i+n] == src.lines[loc.line+n] {
+			n++
+		}
+		if n < minDupLines { // hash collision
+			i++
+			continue
+		}
+		out = append(out, elision{
+			start:    i,
+			end:      i + n - 1,
+			srcPath:  src.path,
+			srcStart: src.first + loc.line,
+			srcEnd:   src.first + loc.line + n - 1,
+		})
+		i += n
+	}
+
+	idx := len(d.files)
+	d.files = append(d.files, dupFile{path: path, lines: lines, first: first})
+	for i := 0; i+minDupLines <= len(lines); i++ {
+		h := windowHash(lines[i : i+minDupLines])
+		if _, seen := d.windows[h]; !seen {
+			d.windows[h] = dupLoc{file: idx, line: i}
+		}
+	}
+	return out
+}
+
+func windowHash(lines []string) uint64 {
+	h := fnv.New64a()
+	for _, l := range lines {
+		h.Write([]byte(l))
+		h.Write([]byte{'\n'})
+	}
+	return h.Sum64()
+}
+
+func isBlankWindow(lines []string) bool {
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadMultipleFilesWithOptions_ElidesDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	var license []string
+	for i := 0; i < 10; i++ {
+		license = append(license, fmt.Sprintf("// License line %d", i))
+	}
+	header := strings.Join(license, "\n") + "\n"
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	if err := os.WriteFile(a, []byte(header+"package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("package b\n"+header), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := ReadMultipleFiles([]string{a, b})[0]
+	if strings.Count(plain, "License line 3") != 2 {
+		t.Fatalf("expected duplicates without the option:\n%s", plain)
+	}
+
+	out := ReadMultipleFilesWithOptions([]string{a, b}, ReadOptions{ElideDuplicates: true})[0]
+	if strings.Count(out, "License line 3") != 1 {
+		t.Fatalf("expected the repeated header to be elided:\n%s", out)
+	}
+	note := fmt.Sprintf("lines 1-10 identical to %s lines 0-9, omitted", a)
+	if !strings.Contains(out, note) {
+		t.Fatalf("expected reference note %q in:\n%s", note, out)
+	}
+	if !strings.Contains(out, "   0 package b") {
+		t.Fatalf("expected unique lines to be kept:\n%s", out)
+	}
+}
+
+func TestReadMultipleFilesWithOptions_KeepsShortRepeats(t *testing.T) {
+	dir := t.TempDir()
+	content := "package x\n\nimport \"fmt\"\n"
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	for _, p := range []string{a, b} {
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := ReadMultipleFilesWithOptions([]string{a, b}, ReadOptions{ElideDuplicates: true})[0]
+	if strings.Contains(out, "omitted") || strings.Count(out, "import \"fmt\"") != 2 {
+		t.Fatalf("expected short repeats to be kept:\n%s", out)
+	}
+}
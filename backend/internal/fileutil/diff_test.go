@@ -0,0 +1,23 @@
+package fileutil
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	updated := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	diff, added, removed := UnifiedDiff("a/x", "b/x", old, updated, 1)
+	want := "--- a/x\n+++ b/x\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n@@ -10 +10,2 @@\n j\n+k\n"
+	if diff != want || added != 2 || removed != 1 {
+		t.Fatalf("got %d+ %d-\n%s", added, removed, diff)
+	}
+	if d, _, _ := UnifiedDiff("a/x", "b/x", old, old, 3); d != "" {
+		t.Fatalf("equal texts diff: %q", d)
+	}
+}
+
+func TestUnifiedDiff_NewFile(t *testing.T) {
+	diff, added, removed := UnifiedDiff("/dev/null", "b/x", "", "one\ntwo\n", 3)
+	if diff != "--- /dev/null\n+++ b/x\n@@ -0,0 +1,2 @@\n+one\n+two\n" || added != 2 || removed != 0 {
+		t.Fatalf("got %q", diff)
+	}
+}
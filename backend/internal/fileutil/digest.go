@@ -0,0 +1,38 @@
+package fileutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// FileDigest returns the hex SHA-256 of a file's content. Paths may carry the same
+// ":start-end" (0-based, inclusive) suffix as ReadFileContent, in which case only those
+// lines are hashed, so edits elsewhere in the file do not change the digest.
+func FileDigest(filePath string) (string, error) {
+	basePath, hasRange, startLine, endLine, err := parsePathLineSpec(filePath)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(basePath)
+	if err != nil {
+		return "", err
+	}
+	if hasRange {
+		lines := bytes.SplitAfter(content, []byte("\n"))
+		if n := len(lines); n > 0 && len(lines[n-1]) == 0 {
+			lines = lines[:n-1]
+		}
+		if startLine >= len(lines) || endLine < startLine {
+			return "", fmt.Errorf("invalid line range %d-%d for %s", startLine, endLine, basePath)
+		}
+		if endLine >= len(lines) {
+			endLine = len(lines) - 1
+		}
+		content = bytes.Join(lines[startLine:endLine+1], nil)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,30 @@
+package fileutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParsePathLineSpec(f *testing.F) {
+	for _, seed := range []string{"main.go", "main.go:0-10", `C:\repo\main.go:3-4`, "C:", ":1-2", "a:b-c", "a.go:-1-2", "x:1-", "snippet://t.txt:0-0"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, p string) {
+		base, hasRange, start, end, err := parsePathLineSpec(p)
+		if err != nil {
+			return
+		}
+		if !hasRange {
+			if base != p {
+				t.Fatalf("path without range changed: %q -> %q", p, base)
+			}
+			return
+		}
+		if base == "" || start < 0 || end < 0 {
+			t.Fatalf("invalid range parse of %q: base=%q start=%d end=%d", p, base, start, end)
+		}
+		if !strings.HasPrefix(p, base+":") || strings.Contains(p[len(base)+1:], ":") {
+			t.Fatalf("range suffix of %q does not follow the last colon: base=%q", p, base)
+		}
+	})
+}
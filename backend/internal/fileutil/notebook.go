@@ -0,0 +1,129 @@
+package fileutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxNotebookOutputLines caps the text kept per cell output
+const maxNotebookOutputLines = 20
+
+// notebook is the subset of the Jupyter nbformat 4 schema needed for flattening
+type notebook struct {
+	Cells    []notebookCell `json:"cells"`
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+type notebookCell struct {
+	CellType string           `json:"cell_type"`
+	Source   notebookText     `json:"source"`
+	Outputs  []notebookOutput `json:"outputs"`
+}
+
+type notebookOutput struct {
+	OutputType string                  `json:"output_type"`
+	Text       notebookText            `json:"text"`  // stream outputs
+	Data       map[string]notebookText `json:"data"`  // execute_result / display_data
+	EName      string                  `json:"ename"` // error outputs
+	EValue     string                  `json:"evalue"`
+}
+
+// notebookText accepts nbformat multiline strings, stored either as a string or a list of lines
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = notebookText(s)
+		return nil
+	}
+	var parts []string
+	if err := json.Unmarshal(b, &parts); err != nil {
+		// Non-text payloads (e.g. JSON widget data) are not injected
+		*t = ""
+		return nil
+	}
+	*t = notebookText(strings.Join(parts, ""))
+	return nil
+}
+
+func isNotebook(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// flattenNotebook converts a notebook into "# %% [n] type" delimited cells with their
+// source and text outputs, dropping images and other binary payloads. The output is
+// deterministic, so line ranges into it stay valid while the notebook is unchanged.
+func flattenNotebook(content []byte) ([]byte, string, error) {
+	var nb notebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		return nil, "", err
+	}
+	language := strings.ToLower(nb.Metadata.LanguageInfo.Name)
+	if language == "" {
+		language = strings.ToLower(nb.Metadata.Kernelspec.Language)
+	}
+	if language == "" {
+		language = "python"
+	}
+
+	var b strings.Builder
+	for i, cell := range nb.Cells {
+		fmt.Fprintf(&b, "# %%%% [%d] %s\n", i, cell.CellType)
+		writeNotebookLines(&b, string(cell.Source), "", 0)
+		if cell.CellType != "code" || len(cell.Outputs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# Output [%d]:\n", i)
+		for _, out := range cell.Outputs {
+			switch {
+			case out.OutputType == "stream":
+				writeNotebookLines(&b, string(out.Text), "# ", maxNotebookOutputLines)
+			case out.OutputType == "error":
+				fmt.Fprintf(&b, "# %s: %s\n", out.EName, out.EValue)
+			case out.Data["text/plain"] != "":
+				writeNotebookLines(&b, string(out.Data["text/plain"]), "# ", maxNotebookOutputLines)
+			case len(out.Data) > 0:
+				mimes := make([]string, 0, len(out.Data))
+				for mime := range out.Data {
+					mimes = append(mimes, mime)
+				}
+				sort.Strings(mimes)
+				fmt.Fprintf(&b, "# [%s output omitted]\n", strings.Join(mimes, ", "))
+			}
+		}
+	}
+	return []byte(b.String()), language, nil
+}
+
+// writeNotebookLines writes text line by line with a prefix, keeping at most max lines (0 = all)
+func writeNotebookLines(b *strings.Builder, text, prefix string, max int) {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return
+	}
+	lines := strings.Split(text, "\n")
+	omitted := 0
+	if max > 0 && len(lines) > max {
+		omitted = len(lines) - max
+		lines = lines[:max]
+	}
+	for _, l := range lines {
+		b.WriteString(prefix)
+		b.WriteString(l)
+		b.WriteByte('\n')
+	}
+	if omitted > 0 {
+		fmt.Fprintf(b, "%s... %d more lines\n", prefix, omitted)
+	}
+}
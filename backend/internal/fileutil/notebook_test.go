@@ -0,0 +1,67 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Analysis\n", "Load the data"]},
+  {"cell_type": "code", "metadata": {}, "execution_count": 1,
+   "source": ["import pandas as pd\n", "df = pd.read_csv('x.csv')\n", "df.head()"],
+   "outputs": [
+    {"output_type": "stream", "name": "stdout", "text": ["loaded\n"]},
+    {"output_type": "display_data", "data": {"image/png": "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk", "text/html": ["<b>x</b>"]}, "metadata": {}},
+    {"output_type": "execute_result", "execution_count": 1, "data": {"text/plain": "   a  b\n0  1  2"}, "metadata": {}}
+   ]}
+ ],
+ "metadata": {"kernelspec": {"language": "python", "name": "python3"}},
+ "nbformat": 4, "nbformat_minor": 5
+}`
+
+func TestReadFileContent_FlattensNotebook(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "analysis.ipynb")
+	if err := os.WriteFile(p, []byte(testNotebook), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadFileContent(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"````python\n",
+		"   0 # %% [0] markdown\n",
+		"   3 # %% [1] code\n",
+		"   4 import pandas as pd\n",
+		"# Output [1]:",
+		"# loaded",
+		"# [image/png, text/html output omitted]",
+		"#    a  b",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "iVBORw0KGgo") || strings.Contains(out, `"cell_type"`) {
+		t.Errorf("expected raw JSON and base64 blobs to be dropped:\n%s", out)
+	}
+
+	// Line ranges refer to the flattened text
+	out, err = ReadFileContent(p + ":3-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "   3 # %% [1] code\n") || !strings.Contains(out, "   5 df = pd.read_csv") || strings.Contains(out, "df.head") {
+		t.Errorf("unexpected range output:\n%s", out)
+	}
+
+	raw := ReadMultipleFilesWithOptions([]string{p}, ReadOptions{RawNotebooks: true})[0]
+	if !strings.Contains(raw, `"cell_type"`) {
+		t.Errorf("expected raw JSON with RawNotebooks:\n%s", raw)
+	}
+}
@@ -0,0 +1,159 @@
+package fileutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ControlCharMode selects how control characters in file content are injected
+type ControlCharMode int
+
+const (
+	// ControlCharsEscape replaces control characters with visible escapes such as \x1b
+	ControlCharsEscape ControlCharMode = iota
+	// ControlCharsStrip removes control characters
+	ControlCharsStrip
+	// ControlCharsKeep injects control characters unchanged
+	ControlCharsKeep
+)
+
+// ParseControlCharMode maps "escape", "strip" and "keep" to a mode; anything else escapes
+func ParseControlCharMode(s string) ControlCharMode {
+	switch s {
+	case "strip":
+		return ControlCharsStrip
+	case "keep":
+		return ControlCharsKeep
+	}
+	return ControlCharsEscape
+}
+
+// ReadOptions controls how file content is prepared for injection. The zero value
+// keeps the historical ReadMultipleFiles output, except that control characters are
+// escaped so that they cannot corrupt the terminal.
+type ReadOptions struct {
+	// ElideDuplicates replaces blocks of at least minDupLines lines that already appeared
+	// in an earlier file of the same batch with a short reference note.
+	ElideDuplicates bool
+
+	// NormalizeLineEndings converts CRLF and lone CR line endings to LF.
+	NormalizeLineEndings bool
+	// StripBOM removes a leading UTF-8 byte order mark.
+	StripBOM bool
+	// TrimTrailingWhitespace removes spaces and tabs at the end of every line.
+	TrimTrailingWhitespace bool
+
+	// TabWidth expands tabs to the next multiple of TabWidth columns; 0 keeps tabs.
+	TabWidth int
+	// ControlChars selects how control characters other than tab and line endings are handled.
+	ControlChars ControlCharMode
+
+	// RawNotebooks injects .ipynb files as JSON instead of flattened cells.
+	RawNotebooks bool
+	// FullTabular injects large CSV/TSV files in full instead of a schema and row preview.
+	FullTabular bool
+
+	// Preamble is the text introducing the injected contents; "" uses DefaultPreamble.
+	// "{count}" expands to the number of files and "{paths}" to their comma-separated paths.
+	Preamble string
+	// NoPreamble injects the contents without an introduction.
+	NoPreamble bool
+
+	// Snippets holds in-memory files keyed by their SnippetPath; they are read instead of
+	// the file system and support line ranges like regular files.
+	Snippets map[string]string
+	// ReadFile, when set, reads files in place of the file system, e.g. from the synthetic
+	// workspace of mock mode.
+	ReadFile func(path string) ([]byte, error)
+
+	// Timeout bounds reading each file; 0 uses DefaultReadTimeout.
+	Timeout time.Duration
+	// Concurrency is the number of files read in parallel; 0 uses DefaultReadConcurrency.
+	Concurrency int
+}
+
+// utf8BOM is the UTF-8 encoded byte order mark
+const utf8BOM = "\uFEFF"
+
+// normalizeContent applies the whole-content options before the file is split into lines
+func normalizeContent(content string, opts ReadOptions) string {
+	if opts.StripBOM {
+		content = strings.TrimPrefix(content, utf8BOM)
+	}
+	if opts.NormalizeLineEndings {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+		content = strings.ReplaceAll(content, "\r", "\n")
+	}
+	return content
+}
+
+// prepareLine applies the per-line options. A trailing CR of a CRLF line ending is
+// preserved, so it is neither escaped nor counted as trailing content.
+func prepareLine(line string, opts ReadOptions) string {
+	cr := ""
+	if strings.HasSuffix(line, "\r") {
+		line, cr = line[:len(line)-1], "\r"
+	}
+	if opts.TrimTrailingWhitespace {
+		line = strings.TrimRight(line, " \t")
+	}
+	if opts.TabWidth > 0 && strings.IndexByte(line, '\t') >= 0 {
+		line = expandTabs(line, opts.TabWidth)
+	}
+	if opts.ControlChars != ControlCharsKeep && hasControlChars(line) {
+		line = sanitizeControlChars(line, opts.ControlChars)
+	}
+	return line + cr
+}
+
+// expandTabs replaces tabs with spaces up to the next tab stop, counting runes as columns
+func expandTabs(line string, width int) string {
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := width - col%width
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// isControlChar reports C0 controls (except tab), DEL and C1 controls
+func isControlChar(r rune) bool {
+	return (r < 0x20 && r != '\t') || (r >= 0x7f && r <= 0x9f)
+}
+
+func hasControlChars(line string) bool {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if (c < 0x20 && c != '\t') || c == 0x7f {
+			return true
+		}
+		// C1 controls are encoded as 0xC2 0x80..0x9F
+		if c == 0xc2 && i+1 < len(line) && line[i+1] <= 0x9f {
+			return true
+		}
+	}
+	return false
+}
+
+func sanitizeControlChars(line string, mode ControlCharMode) string {
+	var b strings.Builder
+	b.Grow(len(line))
+	for _, r := range line {
+		if !isControlChar(r) {
+			b.WriteRune(r)
+			continue
+		}
+		if mode == ControlCharsEscape {
+			fmt.Fprintf(&b, "\\x%02x", r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadMultipleFilesWithOptions_Normalization(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "win.txt")
+	if err := os.WriteFile(p, []byte("\uFEFFfirst  \r\nsecond\t\rthird\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := ReadMultipleFiles([]string{p})[0]
+	if !strings.Contains(raw, "\uFEFF") || !strings.Contains(raw, "\r") {
+		t.Fatalf("expected content untouched without options: %q", raw)
+	}
+
+	out := ReadMultipleFilesWithOptions([]string{p}, ReadOptions{
+		NormalizeLineEndings:   true,
+		StripBOM:               true,
+		TrimTrailingWhitespace: true,
+	})[0]
+	if strings.ContainsAny(out, "\r\uFEFF") {
+		t.Fatalf("expected CR and BOM to be removed: %q", out)
+	}
+	for _, want := range []string{"   0 first\n", "   1 second\n", "   2 third\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestReadFileContent_ControlCharsAndTabs(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "build.log")
+	if err := os.WriteFile(p, []byte("\x1b[31mred\x1b[0m\fpage\r\n\tx\ty\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadFileContent(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(out, "\x1b\f") {
+		t.Fatalf("expected control characters to be escaped by default: %q", out)
+	}
+	if !strings.Contains(out, `   0 \x1b[31mred\x1b[0m\x0cpage`+"\r\n") {
+		t.Fatalf("expected visible escapes and preserved CRLF: %q", out)
+	}
+
+	out = ReadMultipleFilesWithOptions([]string{p}, ReadOptions{TabWidth: 4, ControlChars: ControlCharsStrip})[0]
+	if !strings.Contains(out, "   0 [31mred[0mpage") {
+		t.Errorf("expected control characters to be stripped: %q", out)
+	}
+	if !strings.Contains(out, "   1     x   y") {
+		t.Errorf("expected tabs expanded to width 4: %q", out)
+	}
+
+	out = ReadMultipleFilesWithOptions([]string{p}, ReadOptions{ControlChars: ControlCharsKeep})[0]
+	if !strings.Contains(out, "\x1b[31m") || !strings.Contains(out, "\tx\ty") {
+		t.Errorf("expected raw content with keep mode: %q", out)
+	}
+}
@@ -45,6 +45,8 @@ func GetFileExtensionLanguage(filePath string) string {
 		".conf":       "ini",
 		".md":         "markdown",
 		".txt":        "text",
+		".csv":        "csv",
+		".tsv":        "tsv",
 		".sql":        "sql",
 		".r":          "r",
 		".m":          "matlab",
@@ -103,43 +105,64 @@ func quotePathIfNeeded(p string) string {
 // If filePath ends with ":start-end" (0-based, inclusive), only that range of lines is returned.
 // Example: "/abs/path/src/main.go:8-25" -> returns lines 8..25 inclusive.
 func ReadFileContent(filePath string) (string, error) {
-	// Support optional ":start-end" suffix (0-based, inclusive). Handle Windows drive letter colon safely.
-	basePath, hasRange, startLine, endLine, perr := parsePathLineSpec(filePath)
-	if perr != nil {
-		return "", perr
+	fl, err := readFileLines(filePath, ReadOptions{})
+	if err != nil {
+		return "", err
 	}
+	return formatFileContent(filePath, fl, nil), nil
+}
 
-	// Check if file exists
-	if _, err := os.Stat(basePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("file not found: %s", basePath)
-	}
+// fileLines is the prepared, optionally range-sliced content of a file
+type fileLines struct {
+	basePath  string
+	language  string
+	lines     []string
+	first     int  // 0-based number of lines[0]
+	truncated bool // content was summarized, e.g. a tabular preview
+}
 
-	// Open and read file
-	file, err := os.Open(basePath)
-	if err != nil {
-		return "", fmt.Errorf("error opening %s: %v", basePath, err)
+// readFileLines reads a file (optionally a ":start-end" line range) and returns the
+// selected lines. Notebooks are flattened first, so ranges refer to the flattened text.
+func readFileLines(filePath string, opts ReadOptions) (fileLines, error) {
+	// Support optional ":start-end" suffix (0-based, inclusive). Handle Windows drive letter colon safely.
+	basePath, hasRange, startLine, endLine, perr := parsePathLineSpec(filePath)
+	if perr != nil {
+		return fileLines{}, perr
 	}
-	defer file.Close()
 
-	// Read file content
-	content, err := io.ReadAll(file)
+	content, err := readSource(basePath, opts)
 	if err != nil {
-		return "", fmt.Errorf("error reading %s: %v", basePath, err)
+		return fileLines{}, err
 	}
 
 	// Check if content is valid UTF-8, if not try to handle it gracefully
 	if !utf8.Valid(content) {
 		// For binary files or non-UTF8, return an error message
-		return "", fmt.Errorf("file %s contains non-UTF8 content", basePath)
+		return fileLines{}, fmt.Errorf("file %s contains non-UTF8 content", basePath)
 	}
 
-	contentStr := string(content)
-
-	// Get language for syntax highlighting
 	language := GetFileExtensionLanguage(basePath)
+	if isNotebook(basePath) && !opts.RawNotebooks {
+		flat, lang, err := flattenNotebook(content)
+		if err != nil {
+			return fileLines{}, fmt.Errorf("error parsing notebook %s: %v", basePath, err)
+		}
+		content, language = flat, lang
+	}
+	truncated := false
+	if sep, ok := tabularSeparator(basePath); ok && !hasRange && !opts.FullTabular {
+		if preview, ok := previewTabular(content, sep); ok {
+			content, truncated = preview, true
+		}
+	}
+
+	contentStr := normalizeContent(string(content), opts)
 
 	// Split content into lines and add line numbers
 	lines := strings.Split(contentStr, "\n")
+	for i, l := range lines {
+		lines[i] = prepareLine(l, opts)
+	}
 
 	// Remove the last empty line if the file ends with a newline
 	if len(lines) > 0 && lines[len(lines)-1] == "" {
@@ -147,73 +170,73 @@ func ReadFileContent(filePath string) (string, error) {
 	}
 
 	// If a range was requested, clamp and slice (0-based, inclusive)
-	if hasRange {
-		if startLine < 0 {
-			startLine = 0
-		}
-		if endLine >= len(lines) {
-			endLine = len(lines) - 1
-		}
-		if endLine < startLine {
-			return "", fmt.Errorf("invalid line range %d-%d for %s", startLine, endLine, basePath)
-		}
-		lines = lines[startLine : endLine+1]
+	if !hasRange {
+		return fileLines{basePath: basePath, language: language, lines: lines, truncated: truncated}, nil
+	}
+	if startLine < 0 {
+		startLine = 0
+	}
+	if endLine >= len(lines) {
+		endLine = len(lines) - 1
+	}
+	if endLine < startLine {
+		return fileLines{}, fmt.Errorf("invalid line range %d-%d for %s", startLine, endLine, basePath)
 	}
+	return fileLines{basePath: basePath, language: language, lines: lines[startLine : endLine+1], first: startLine, truncated: truncated}, nil
+}
+
+// formatFileContent renders numbered lines in the open_files format. Lines covered by
+// elisions are replaced with a single reference note.
+func formatFileContent(headerPath string, fl fileLines, elisions []elision) string {
+	lines, first := fl.lines, fl.first
 
-	// Format the output similar to open_files
+	// Format the output similar to open_files; keep any provided suffix (e.g., :8-25) for clarity
 	var result strings.Builder
-	headerPath := filePath // keep any provided suffix (e.g., :8-25) for clarity
-	result.WriteString(fmt.Sprintf("Successfully opened %s:\n\n````%s\n", quotePathIfNeeded(headerPath), language))
+	result.WriteString(fmt.Sprintf("Successfully opened %s:\n\n````%s\n", quotePathIfNeeded(headerPath), fl.language))
 
-	for i, line := range lines {
-		num := i
-		if hasRange {
-			num = startLine + i
+	for i := 0; i < len(lines); i++ {
+		if len(elisions) > 0 && elisions[0].start == i {
+			el := elisions[0]
+			elisions = elisions[1:]
+			result.WriteString(fmt.Sprintf("     ... lines %d-%d identical to %s lines %d-%d, omitted ...\n",
+				first+el.start, first+el.end, quotePathIfNeeded(el.srcPath), el.srcStart, el.srcEnd))
+			i = el.end
+			continue
 		}
-		result.WriteString(fmt.Sprintf("%4d %s\n", num, line))
+		result.WriteString(fmt.Sprintf("%4d %s\n", first+i, lines[i]))
 	}
 
 	result.WriteString("````")
 
-	return result.String(), nil
+	return result.String()
 }
 
 // ReadMultipleFiles reads multiple files and returns their contents with header
 func ReadMultipleFiles(paths []string) []string {
+	return ReadMultipleFilesWithOptions(paths, ReadOptions{})
+}
+
+// ReadMultipleFilesWithOptions is like ReadMultipleFiles but applies per-request options.
+// Files are read concurrently (see ReadFiles); failures are reported inline.
+func ReadMultipleFilesWithOptions(paths []string, opts ReadOptions) []string {
 	if len(paths) == 0 {
 		return []string{}
 	}
 
 	// Prepare the output content similar to Python rdcb tool
-	var outputLines []string
-
-	// Add header (with newline at the beginning as requested)
-	outputLines = append(outputLines, "")
-	outputLines = append(outputLines, "")
-	outputLines = append(outputLines, "")
-	outputLines = append(outputLines, "The referenced content is provided below. There is no need to read it again.")
-	outputLines = append(outputLines, "")
-	outputLines = append(outputLines, "---")
-	outputLines = append(outputLines, "")
+	outputLines := injectionHeader(opts, paths)
 
 	// Process each file
-	for _, path := range paths {
-		if path == "" {
-			errorMsg := "Error: empty file path"
-			outputLines = append(outputLines, errorMsg)
-			outputLines = append(outputLines, "")
-			continue
-		}
-
-		content, err := ReadFileContent(path)
-		if err != nil {
-			errorMsg := fmt.Sprintf("Error reading %s: %v", quotePathIfNeeded(path), err)
-			outputLines = append(outputLines, errorMsg)
-			outputLines = append(outputLines, "")
-		} else {
-			outputLines = append(outputLines, content)
-			outputLines = append(outputLines, "")
+	for _, res := range ReadFiles(paths, opts) {
+		switch {
+		case res.Path == "":
+			outputLines = append(outputLines, "Error: empty file path")
+		case res.Err != nil:
+			outputLines = append(outputLines, fmt.Sprintf("Error reading %s: %v", quotePathIfNeeded(res.Path), res.Err))
+		default:
+			outputLines = append(outputLines, res.Content)
 		}
+		outputLines = append(outputLines, "")
 	}
 
 	// Join all lines into a single string and return as single element
@@ -221,6 +244,39 @@ func ReadMultipleFiles(paths []string) []string {
 	return []string{fullContent}
 }
 
+// readSource returns the raw content of a snippet or file on disk
+func readSource(basePath string, opts ReadOptions) ([]byte, error) {
+	if IsSnippetPath(basePath) {
+		content, ok := opts.Snippets[basePath]
+		if !ok {
+			return nil, fmt.Errorf("snippet not found: %s", basePath)
+		}
+		return []byte(content), nil
+	}
+	if opts.ReadFile != nil {
+		return opts.ReadFile(basePath)
+	}
+
+	// Check if file exists
+	if _, err := os.Stat(basePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("file not found: %s", basePath)
+	}
+
+	// Open and read file
+	file, err := os.Open(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", basePath, err)
+	}
+	defer file.Close()
+
+	// Read file content
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", basePath, err)
+	}
+	return content, nil
+}
+
 // parsePathLineSpec parses an optional ":start-end" suffix from a path string.
 // Returns base path, whether a range exists, start, end, and error if parsing fails.
 func parsePathLineSpec(p string) (string, bool, int, int, error) {
@@ -0,0 +1,16 @@
+package fileutil
+
+import "strings"
+
+// snippetScheme prefixes the paths of in-memory snippets, e.g. "snippet://trace.txt"
+const snippetScheme = "snippet://"
+
+// SnippetPath returns the virtual path under which a snippet is referenced and injected
+func SnippetPath(name string) string {
+	return snippetScheme + name
+}
+
+// IsSnippetPath reports whether path refers to an in-memory snippet rather than a file
+func IsSnippetPath(path string) bool {
+	return strings.HasPrefix(path, snippetScheme)
+}
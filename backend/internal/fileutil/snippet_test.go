@@ -0,0 +1,25 @@
+package fileutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadFiles_Snippets(t *testing.T) {
+	path := SnippetPath("trace.py")
+	opts := ReadOptions{Snippets: map[string]string{path: "Traceback:\n  File x\nValueError: boom\n"}}
+
+	results := ReadFiles([]string{path, path + ":2-2", SnippetPath("missing.txt")}, opts)
+	if results[0].Err != nil || results[0].Language != "python" {
+		t.Fatalf("unexpected snippet result: %+v", results[0])
+	}
+	if !strings.Contains(results[0].Content, "```python\n") || !strings.Contains(results[0].Content, "   2 ValueError: boom") {
+		t.Errorf("unexpected snippet content: %q", results[0].Content)
+	}
+	if results[1].Err != nil || strings.Contains(results[1].Content, "Traceback") || !strings.Contains(results[1].Content, "ValueError") {
+		t.Errorf("expected only the requested line: %+v", results[1])
+	}
+	if results[2].Err == nil || !strings.Contains(results[2].Err.Error(), "snippet not found") {
+		t.Errorf("expected missing snippet error, got %+v", results[2])
+	}
+}
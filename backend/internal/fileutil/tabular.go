@@ -0,0 +1,124 @@
+package fileutil
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// tabularPreviewRows is the number of rows shown from each end of a large table
+	tabularPreviewRows = 10
+	// tabularPreviewMinRows is the data row count above which a preview replaces the content
+	tabularPreviewMinRows = 50
+)
+
+// tabularSeparator returns the field separator for CSV and TSV files
+func tabularSeparator(path string) (rune, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ',', true
+	case ".tsv":
+		return '\t', true
+	}
+	return 0, false
+}
+
+// previewTabular summarizes a large table as its header, inferred column types, row count
+// and the first/last tabularPreviewRows rows. It reports false when the content is small
+// enough to inject in full or cannot be parsed, in which case the raw content is used.
+func previewTabular(content []byte, sep rune) ([]byte, bool) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = sep
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, false
+	}
+	header, rows := records[0], records[1:]
+	if len(rows) <= tabularPreviewMinRows {
+		return nil, false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Preview: %d rows, %d columns (middle rows omitted; request full content to include them)\n", len(rows), len(header))
+	b.WriteString("# Columns:\n")
+	for i, name := range header {
+		fmt.Fprintf(&b, "#   %s: %s\n", name, inferColumnType(rows, i))
+	}
+
+	w := csv.NewWriter(&b)
+	w.Comma = sep
+	writeRows := func(title string, rs [][]string) {
+		w.Flush()
+		fmt.Fprintf(&b, "# %s:\n", title)
+		_ = w.Write(header)
+		_ = w.WriteAll(rs)
+	}
+	writeRows(fmt.Sprintf("First %d rows", tabularPreviewRows), rows[:tabularPreviewRows])
+	writeRows(fmt.Sprintf("Last %d rows", tabularPreviewRows), rows[len(rows)-tabularPreviewRows:])
+	w.Flush()
+	return []byte(b.String()), true
+}
+
+// inferColumnType returns the narrowest of integer, number, boolean, date and string that
+// fits every non-empty value of column col; "empty" if it has no values.
+func inferColumnType(rows [][]string, col int) string {
+	isInt, isFloat, isBool, isDate := true, true, true, true
+	seen := false
+	for _, row := range rows {
+		if col >= len(row) {
+			continue
+		}
+		v := strings.TrimSpace(row[col])
+		if v == "" {
+			continue
+		}
+		seen = true
+		if isInt {
+			if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+				isInt = false
+			}
+		}
+		if isFloat {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				isFloat = false
+			}
+		}
+		if isBool {
+			if _, err := strconv.ParseBool(v); err != nil {
+				isBool = false
+			}
+		}
+		if isDate && !isDateValue(v) {
+			isDate = false
+		}
+	}
+	switch {
+	case !seen:
+		return "empty"
+	case isInt:
+		return "integer"
+	case isFloat:
+		return "number"
+	case isBool:
+		return "boolean"
+	case isDate:
+		return "date"
+	}
+	return "string"
+}
+
+func isDateValue(v string) bool {
+	for _, layout := range []string{time.RFC3339, "2006-01-02", "2006-01-02 15:04:05", "2006/01/02"} {
+		if _, err := time.Parse(layout, v); err == nil {
+			return true
+		}
+	}
+	return false
+}
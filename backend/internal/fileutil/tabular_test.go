@@ -0,0 +1,62 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFileContent_TabularPreview(t *testing.T) {
+	dir := t.TempDir()
+	var b strings.Builder
+	b.WriteString("id,price,active,day,name\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "%d,%d.5,true,2024-01-%02d,\"item, %d\"\n", i, i, i%28+1, i)
+	}
+	p := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(p, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadFileContent(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"````csv\n",
+		"# Preview: 100 rows, 5 columns",
+		"#   id: integer",
+		"#   price: number",
+		"#   active: boolean",
+		"#   day: date",
+		"#   name: string",
+		`9,9.5,true,2024-01-10,"item, 9"`,
+		`99,99.5,true,2024-01-16,"item, 99"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"item, 50"`) {
+		t.Errorf("expected middle rows to be omitted:\n%s", out)
+	}
+
+	full := ReadMultipleFilesWithOptions([]string{p}, ReadOptions{FullTabular: true})[0]
+	if !strings.Contains(full, `"item, 50"`) || strings.Contains(full, "# Preview") {
+		t.Errorf("expected full content with FullTabular")
+	}
+
+	// Small tables and explicit line ranges are injected as-is
+	small := filepath.Join(dir, "small.tsv")
+	if err := os.WriteFile(small, []byte("a\tb\n1\t2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := ReadFileContent(small); strings.Contains(out, "# Preview") || !strings.Contains(out, "1\t2") {
+		t.Errorf("expected small table in full:\n%s", out)
+	}
+	if out, _ := ReadFileContent(p + ":50-51"); strings.Contains(out, "# Preview") {
+		t.Errorf("expected line range to bypass the preview:\n%s", out)
+	}
+}
@@ -0,0 +1,231 @@
+// Package gitcheckpoint snapshots a git work tree onto a dedicated ref and restores it,
+// without touching the user's branch, index or stash. Each checkpoint is a commit whose
+// tree holds the tracked and untracked (but not ignored) files, chained to the previous
+// checkpoint on the same ref.
+package gitcheckpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefPrefix is where checkpoint refs live; they are invisible to branches and tags
+const RefPrefix = "refs/rovobridge/checkpoints/"
+
+// Timeout bounds each operation so a slow or locked repository never stalls a send
+const Timeout = 10 * time.Second
+
+// Checkpoint is one snapshot on a checkpoint ref
+type Checkpoint struct {
+	ID        string `json:"id"` // commit hash
+	CreatedAt int64  `json:"createdAt"`
+	Message   string `json:"message"`
+}
+
+// ErrUnknownCheckpoint is returned when restoring a commit that is not on the ref
+var ErrUnknownCheckpoint = errors.New("unknown checkpoint")
+
+// identity keeps commit-tree working in repositories without user.name/user.email
+var identity = []string{
+	"GIT_AUTHOR_NAME=rovo-bridge", "GIT_AUTHOR_EMAIL=rovo-bridge@localhost",
+	"GIT_COMMITTER_NAME=rovo-bridge", "GIT_COMMITTER_EMAIL=rovo-bridge@localhost",
+}
+
+// Ref returns the checkpoint ref of a session
+func Ref(sessionID string) string {
+	return RefPrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, sessionID)
+}
+
+// Create commits the current state of the work tree containing dir onto ref
+func Create(ctx context.Context, dir, ref, message string) (Checkpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	top, err := toplevel(ctx, dir)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	tree, err := withTempIndex(top, func(env []string) (string, error) {
+		// Start from HEAD so the temporary index matches the real one for unchanged files
+		if _, err := git(ctx, top, env, "read-tree", "HEAD"); err != nil {
+			if _, err := git(ctx, top, env, "read-tree", "--empty"); err != nil {
+				return "", err
+			}
+		}
+		if _, err := git(ctx, top, env, "add", "-A", "--", "."); err != nil {
+			return "", err
+		}
+		return git(ctx, top, env, "write-tree")
+	})
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	args := []string{"commit-tree", tree, "-m", message}
+	if parent, err := git(ctx, top, nil, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil && parent != "" {
+		args = append(args, "-p", parent)
+	}
+	id, err := git(ctx, top, identity, args...)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	if _, err := git(ctx, top, nil, "update-ref", "-m", "rovo-bridge checkpoint", ref, id); err != nil {
+		return Checkpoint{}, err
+	}
+	return Checkpoint{ID: id, CreatedAt: time.Now().UnixMilli(), Message: message}, nil
+}
+
+// List returns up to limit checkpoints on ref, newest first. A ref that does not exist
+// has none.
+func List(ctx context.Context, dir, ref string, limit int) ([]Checkpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	out := []Checkpoint{}
+	if _, err := git(ctx, dir, nil, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		if _, err := toplevel(ctx, dir); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	log, err := git(ctx, dir, nil, "log", "--first-parent", "-n", strconv.Itoa(limit), "--format=%H%x00%ct%x00%s", ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(log, "\n") {
+		f := strings.SplitN(line, "\x00", 3)
+		if len(f) != 3 {
+			continue
+		}
+		ts, _ := strconv.ParseInt(f[1], 10, 64)
+		out = append(out, Checkpoint{ID: f[0], CreatedAt: ts * 1000, Message: f[2]})
+	}
+	return out, nil
+}
+
+// Restore makes the work tree containing dir match the checkpoint id on ref: files are
+// rewritten to their checkpointed content and files created since are removed. Ignored
+// files are left alone, as are the user's index and branch. The state before the restore
+// is checkpointed first and returned, so the restore itself can be undone.
+func Restore(ctx context.Context, dir, ref, id string) (Checkpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*Timeout)
+	defer cancel()
+	top, err := toplevel(ctx, dir)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	// Only commits on the ref may be restored; this also rejects arbitrary revisions
+	if !isHash(id) {
+		return Checkpoint{}, ErrUnknownCheckpoint
+	}
+	if _, err := git(ctx, top, nil, "merge-base", "--is-ancestor", id, ref); err != nil {
+		return Checkpoint{}, ErrUnknownCheckpoint
+	}
+	before, err := Create(ctx, top, ref, "before restoring "+short(id))
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	// Remove the files created since, then write back the ones changed or deleted
+	added, err := git(ctx, top, nil, "diff-tree", "-r", "-z", "--no-renames", "--name-only", "--diff-filter=A", id, before.ID)
+	if err != nil {
+		return before, err
+	}
+	for _, p := range strings.Split(added, "\x00") {
+		if p == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(top, filepath.FromSlash(p))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return before, err
+		}
+	}
+	changed, err := git(ctx, top, nil, "diff-tree", "-r", "-z", "--no-renames", "--name-only", "--diff-filter=DMT", id, before.ID)
+	if err != nil || changed == "" {
+		return before, err
+	}
+	_, err = withTempIndex(top, func(env []string) (string, error) {
+		if _, err := git(ctx, top, env, "read-tree", id); err != nil {
+			return "", err
+		}
+		cmd := gitCmd(ctx, top, env, "checkout-index", "-f", "-z", "--stdin")
+		cmd.Stdin = strings.NewReader(changed)
+		return run(cmd)
+	})
+	return before, err
+}
+
+func toplevel(ctx context.Context, dir string) (string, error) {
+	top, err := git(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("not a git work tree: %w", err)
+	}
+	return top, nil
+}
+
+// withTempIndex runs fn with GIT_INDEX_FILE pointing at a scratch index
+func withTempIndex(top string, fn func(env []string) (string, error)) (string, error) {
+	f, err := os.CreateTemp("", "rovobridge-index-*")
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	f.Close()
+	// git refuses an empty file as an index; let it create a fresh one
+	os.Remove(name)
+	defer os.Remove(name)
+	return fn([]string{"GIT_INDEX_FILE=" + name})
+}
+
+func git(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	return run(gitCmd(ctx, dir, env, args...))
+}
+
+func gitCmd(ctx context.Context, dir string, env []string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+// run returns the trimmed stdout of cmd, or an error carrying its stderr
+func run(cmd *exec.Cmd) (string, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", cmd.Args[1], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", cmd.Args[1], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func isHash(s string) bool {
+	if len(s) < 7 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func short(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,114 @@
+package gitcheckpoint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// newRepo creates a git repository with one commit and returns its directory
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	write(t, dir, ".gitignore", "*.log\n")
+	write(t, dir, "a.txt", "a1\n")
+	write(t, dir, "b.txt", "b1\n")
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}, {"commit", "-q", "-m", "init"}} {
+		if _, err := git(context.Background(), dir, identity, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func write(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, dir, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "<missing>"
+	}
+	return string(b)
+}
+
+func TestCreateListRestore(t *testing.T) {
+	dir := newRepo(t)
+	ctx := context.Background()
+	ref := Ref("s/1")
+	write(t, dir, "untracked.txt", "u1\n")
+
+	first, err := Create(ctx, dir, ref, "first prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, dir, "a.txt", "a2\n")
+	if err := os.Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	write(t, dir, "c.txt", "c2\n")
+	write(t, dir, "run.log", "ignored\n")
+	if _, err := Create(ctx, dir, ref, "second prompt"); err != nil {
+		t.Fatal(err)
+	}
+	statusBefore, _ := git(ctx, dir, nil, "status", "--porcelain")
+
+	before, err := Restore(ctx, dir, ref, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a.txt": "a1\n", "b.txt": "b1\n", "untracked.txt": "u1\n", "c.txt": "<missing>", "run.log": "ignored\n"} {
+		if got := read(t, dir, name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	if status, _ := git(ctx, dir, nil, "diff", "--cached", "--name-only"); status != "" {
+		t.Errorf("restore touched the user's index: %q", status)
+	}
+
+	list, err := List(ctx, dir, ref, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].ID != before.ID || list[1].Message != "second prompt" || list[2].ID != first.ID {
+		t.Fatalf("unexpected checkpoints: %+v", list)
+	}
+
+	// The automatic checkpoint undoes the restore
+	if _, err := Restore(ctx, dir, ref, before.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := git(ctx, dir, nil, "status", "--porcelain"); status != statusBefore {
+		t.Fatalf("expected status %q after undo, got %q", statusBefore, status)
+	}
+}
+
+func TestRestore_RejectsCommitsOffTheRef(t *testing.T) {
+	dir := newRepo(t)
+	ctx := context.Background()
+	if _, err := Create(ctx, dir, Ref("s1"), "x"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"HEAD", "--help", "0123456789abcdef0123456789abcdef01234567"} {
+		if _, err := Restore(ctx, dir, Ref("s1"), id); !errors.Is(err, ErrUnknownCheckpoint) {
+			t.Errorf("Restore(%q): expected ErrUnknownCheckpoint, got %v", id, err)
+		}
+	}
+	if list, err := List(ctx, dir, Ref("other"), 10); err != nil || len(list) != 0 {
+		t.Fatalf("expected no checkpoints on a missing ref, got %v, %v", list, err)
+	}
+	if _, err := List(ctx, t.TempDir(), Ref("s1"), 10); err == nil {
+		t.Fatal("expected an error outside a git work tree")
+	}
+}
@@ -0,0 +1,156 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// defaultBackupCount is the number of rolling backups kept by NewHistoryManager
+const defaultBackupCount = 3
+
+// backupMinInterval limits routine backups; writes that drop entries always back up first
+const backupMinInterval = time.Hour
+
+// SetBackupCount configures how many rolling backups of the history file are kept.
+// Zero disables backups.
+func (h *HistoryManager) SetBackupCount(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	h.backupCount = n
+}
+
+// checksumEntries returns the hex SHA-256 of the compact JSON encoding of the entries array
+func checksumEntries(rawEntries []byte) (string, error) {
+	var compact []byte
+	if len(rawEntries) > 0 {
+		buf, err := compactJSON(rawEntries)
+		if err != nil {
+			return "", err
+		}
+		compact = buf
+	}
+	sum := sha256.Sum256(compact)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func compactJSON(data []byte) ([]byte, error) {
+	var v json.RawMessage
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// parseHistoryData decodes a history file and verifies its checksum when present.
+// Files written before checksums were introduced are accepted as-is.
+func parseHistoryData(data []byte) (HistoryFile, error) {
+	var historyFile HistoryFile
+	if err := json.Unmarshal(data, &historyFile); err != nil {
+		return HistoryFile{}, err
+	}
+	if historyFile.Checksum == "" {
+		return historyFile, nil
+	}
+	var raw struct {
+		Entries json.RawMessage `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return HistoryFile{}, err
+	}
+	sum, err := checksumEntries(raw.Entries)
+	if err != nil {
+		return HistoryFile{}, err
+	}
+	if sum != historyFile.Checksum {
+		return HistoryFile{}, fmt.Errorf("history checksum mismatch: expected %s, got %s", historyFile.Checksum, sum)
+	}
+	return historyFile, nil
+}
+
+// backupPaths returns existing rolling backups, newest first
+func (h *HistoryManager) backupPaths() []string {
+	paths, err := filepath.Glob(h.filePath + ".bak.*")
+	if err != nil {
+		return nil
+	}
+	// Names end with a fixed-width timestamp, so lexical order is chronological
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	return paths
+}
+
+// rotateBackupUnsafe copies the current history file to a new timestamped backup and
+// prunes backups beyond the configured count. Unless force is set, it is skipped when the
+// newest backup is more recent than backupMinInterval. Corrupted files are never backed up,
+// so backups always hold data recovery can use.
+func (h *HistoryManager) rotateBackupUnsafe(force bool) error {
+	if h.backupCount <= 0 {
+		return nil
+	}
+	existing := h.backupPaths()
+	if !force && len(existing) > 0 {
+		if info, err := os.Stat(existing[0]); err == nil && time.Since(info.ModTime()) < backupMinInterval {
+			return nil
+		}
+	}
+
+	data, err := os.ReadFile(h.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read history file for backup: %w", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := parseHistoryData(data); err != nil {
+		log.Printf("Skipping backup of invalid history file %s: %v", h.filePath, err)
+		return nil
+	}
+
+	backupPath := fmt.Sprintf("%s.bak.%020d", h.filePath, time.Now().UnixNano())
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history backup %s: %w", backupPath, err)
+	}
+
+	existing = append([]string{backupPath}, existing...)
+	for _, old := range existing[min(len(existing), h.backupCount):] {
+		if err := os.Remove(old); err != nil {
+			log.Printf("Warning: failed to remove old history backup %s: %v", old, err)
+		}
+	}
+	return nil
+}
+
+// restoreFromBackupUnsafe replaces the history file with the newest backup that passes
+// verification and returns its entries. ok is false if no usable backup exists.
+func (h *HistoryManager) restoreFromBackupUnsafe() (entries []PromptHistoryEntry, ok bool) {
+	for _, path := range h.backupPaths() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		historyFile, err := parseHistoryData(data)
+		if err != nil {
+			log.Printf("Skipping invalid history backup %s: %v", path, err)
+			continue
+		}
+		if err := h.writeHistoryFile(historyFile); err != nil {
+			log.Printf("Failed to restore history from backup %s: %v", path, err)
+			return historyFile.Entries, true
+		}
+		log.Printf("Restored history from backup %s (%d entries)", path, len(historyFile.Entries))
+		return historyFile.Entries, true
+	}
+	return nil, false
+}
@@ -0,0 +1,200 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// draftSaveDelay debounces draft writes; keystroke-level updates only touch memory
+const draftSaveDelay = 2 * time.Second
+
+// maxDrafts bounds the drafts file; the least recently updated drafts are dropped first
+const maxDrafts = 200
+
+// PromptDraft is the unsent prompt text of one session in one workspace
+type PromptDraft struct {
+	ProjectCwd        string `json:"projectCwd"`
+	SessionID         string `json:"sessionId,omitempty"`
+	SerializedContent string `json:"serializedContent"`
+	UpdatedAt         int64  `json:"updatedAt"`
+}
+
+// draftsFile represents the structure of the drafts file
+type draftsFile struct {
+	Version string        `json:"version"`
+	Drafts  []PromptDraft `json:"drafts"`
+}
+
+// DraftStore keeps unsent prompt drafts per workspace and session so that a reload or
+// crash of the webview does not lose a half-written prompt. Updates are applied in
+// memory immediately and written to disk after draftSaveDelay of inactivity.
+type DraftStore struct {
+	filePath string
+	delay    time.Duration
+
+	mu     sync.Mutex
+	drafts map[draftKey]PromptDraft
+	loaded bool
+	dirty  bool
+	timer  *time.Timer
+}
+
+type draftKey struct {
+	projectCwd string
+	sessionID  string
+}
+
+// NewDraftStore creates a DraftStore persisting next to the history file
+func NewDraftStore() *DraftStore {
+	return NewDraftStoreAt(getHistoryFilePath() + "-drafts")
+}
+
+// NewDraftStoreAt creates a DraftStore persisting to filePath
+func NewDraftStoreAt(filePath string) *DraftStore {
+	return &DraftStore{
+		filePath: filePath,
+		delay:    draftSaveDelay,
+	}
+}
+
+// SaveDraft records the draft for a workspace/session. An empty content clears it.
+func (d *DraftStore) SaveDraft(projectCwd, sessionID, serializedContent string) PromptDraft {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loadUnsafe()
+
+	key := draftKey{normalizeProjectCwd(projectCwd), sessionID}
+	draft := PromptDraft{
+		ProjectCwd:        key.projectCwd,
+		SessionID:         sessionID,
+		SerializedContent: serializedContent,
+		UpdatedAt:         time.Now().UnixMilli(),
+	}
+	if serializedContent == "" {
+		if _, ok := d.drafts[key]; !ok {
+			return draft
+		}
+		delete(d.drafts, key)
+	} else {
+		d.drafts[key] = draft
+	}
+	d.scheduleUnsafe()
+	return draft
+}
+
+// LoadDraft returns the draft for a workspace/session. When the session has no draft of
+// its own (e.g. a new session ID after an IDE reload), the most recent draft of the
+// workspace is returned instead.
+func (d *DraftStore) LoadDraft(projectCwd, sessionID string) (PromptDraft, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loadUnsafe()
+
+	cwd := normalizeProjectCwd(projectCwd)
+	if draft, ok := d.drafts[draftKey{cwd, sessionID}]; ok {
+		return draft, true
+	}
+	var latest PromptDraft
+	found := false
+	for key, draft := range d.drafts {
+		if key.projectCwd == cwd && (!found || draft.UpdatedAt > latest.UpdatedAt) {
+			latest, found = draft, true
+		}
+	}
+	return latest, found
+}
+
+// Flush writes pending draft changes to disk immediately
+func (d *DraftStore) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	return d.writeUnsafe()
+}
+
+// scheduleUnsafe marks the store dirty and (re)arms the debounce timer
+func (d *DraftStore) scheduleUnsafe() {
+	d.dirty = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.timer = nil
+		if err := d.writeUnsafe(); err != nil {
+			log.Printf("Failed to save prompt drafts: %v", err)
+		}
+	})
+}
+
+// loadUnsafe reads the drafts file once; a missing or unreadable file yields no drafts
+func (d *DraftStore) loadUnsafe() {
+	if d.loaded {
+		return
+	}
+	d.loaded = true
+	d.drafts = map[draftKey]PromptDraft{}
+
+	data, err := os.ReadFile(d.filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read drafts file %s: %v", d.filePath, err)
+		}
+		return
+	}
+	var file draftsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("Failed to parse drafts file %s, starting without drafts: %v", d.filePath, err)
+		return
+	}
+	for _, draft := range file.Drafts {
+		if draft.SerializedContent == "" {
+			continue
+		}
+		d.drafts[draftKey{draft.ProjectCwd, draft.SessionID}] = draft
+	}
+}
+
+// writeUnsafe persists drafts atomically when there are unsaved changes
+func (d *DraftStore) writeUnsafe() error {
+	if !d.dirty {
+		return nil
+	}
+	drafts := make([]PromptDraft, 0, len(d.drafts))
+	for _, draft := range d.drafts {
+		drafts = append(drafts, draft)
+	}
+	// Newest first, so trimming keeps the most recently edited drafts
+	sort.Slice(drafts, func(i, j int) bool { return drafts[i].UpdatedAt > drafts[j].UpdatedAt })
+	if len(drafts) > maxDrafts {
+		drafts = drafts[:maxDrafts]
+	}
+
+	data, err := json.MarshalIndent(draftsFile{Version: "1.0", Drafts: drafts}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal drafts: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(d.filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %w", err)
+	}
+	tempFile := d.filePath + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temporary drafts file %s: %w", tempFile, err)
+	}
+	if err := os.Rename(tempFile, d.filePath); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename temporary drafts file: %w", err)
+	}
+	d.dirty = false
+	return nil
+}
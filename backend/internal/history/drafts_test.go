@@ -0,0 +1,69 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDraftStore_SaveLoadAndFallback(t *testing.T) {
+	tempDir := t.TempDir()
+	store := &DraftStore{filePath: filepath.Join(tempDir, "drafts"), delay: time.Hour}
+
+	store.SaveDraft("/proj", "s1", "first draft")
+	time.Sleep(2 * time.Millisecond)
+	store.SaveDraft("/proj", "s2", "second draft")
+	store.SaveDraft("/other", "s3", "elsewhere")
+
+	if d, ok := store.LoadDraft("/proj", "s1"); !ok || d.SerializedContent != "first draft" {
+		t.Fatalf("Expected session draft, got %+v (found=%v)", d, ok)
+	}
+	// Unknown session falls back to the latest draft of the workspace
+	if d, ok := store.LoadDraft("/proj", "new-session"); !ok || d.SerializedContent != "second draft" {
+		t.Fatalf("Expected workspace fallback draft, got %+v (found=%v)", d, ok)
+	}
+	if _, ok := store.LoadDraft("/none", "s1"); ok {
+		t.Fatalf("Expected no draft for unknown workspace")
+	}
+
+	// Clearing removes the session draft
+	store.SaveDraft("/proj", "s2", "")
+	if d, _ := store.LoadDraft("/proj", "x"); d.SerializedContent != "first draft" {
+		t.Fatalf("Expected remaining draft after clear, got %+v", d)
+	}
+}
+
+func TestDraftStore_DebouncedPersistence(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "drafts")
+	store := &DraftStore{filePath: filePath, delay: time.Hour}
+
+	store.SaveDraft("/proj", "s1", "unsent")
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Fatalf("Expected no write before the debounce delay, stat err=%v", err)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	reloaded := &DraftStore{filePath: filePath, delay: time.Hour}
+	if d, ok := reloaded.LoadDraft("/proj", "s1"); !ok || d.SerializedContent != "unsent" {
+		t.Fatalf("Expected persisted draft, got %+v (found=%v)", d, ok)
+	}
+
+	// The debounce timer writes on its own
+	store.delay = 10 * time.Millisecond
+	store.SaveDraft("/proj", "s1", "updated")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		again := &DraftStore{filePath: filePath, delay: time.Hour}
+		if d, _ := again.LoadDraft("/proj", "s1"); d.SerializedContent == "updated" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Debounced draft write did not happen")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,32 @@
+package history
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// lockFileUnsafe takes the lock file next to the history file, so that a running bridge
+// and the history CLI, each with its own HistoryManager, do not overwrite each other's
+// changes. It returns the function releasing the lock; when the lock cannot be taken the
+// write goes ahead unguarded, as before locking existed.
+func (h *HistoryManager) lockFileUnsafe() func() {
+	if err := os.MkdirAll(filepath.Dir(h.filePath), 0755); err != nil {
+		log.Printf("Warning: failed to lock history file %s: %v", h.filePath, err)
+		return func() {}
+	}
+	f, err := os.OpenFile(h.filePath+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		log.Printf("Warning: failed to lock history file %s: %v", h.filePath, err)
+		return func() {}
+	}
+	if err := lockFile(f); err != nil {
+		log.Printf("Warning: failed to lock history file %s: %v", h.filePath, err)
+		f.Close()
+		return func() {}
+	}
+	return func() {
+		_ = unlockFile(f)
+		f.Close()
+	}
+}
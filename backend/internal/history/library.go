@@ -0,0 +1,189 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SourceProject flags history entries that come from the workspace prompt library
+// rather than the personal history file.
+const SourceProject = "project"
+
+// projectLibraryPath is the checked-in prompt library, relative to the workspace root
+var projectLibraryPath = filepath.Join(".rovobridge", "prompts.json")
+
+// ProjectPrompt is a shared prompt template stored in the workspace
+type ProjectPrompt struct {
+	ID                string `json:"id"`
+	Title             string `json:"title,omitempty"`
+	SerializedContent string `json:"serializedContent"`
+	Timestamp         int64  `json:"timestamp"`
+}
+
+// projectLibraryFile represents the structure of .rovobridge/prompts.json. It carries
+// no checksum or machine-specific paths so that it diffs and merges cleanly in VCS.
+type projectLibraryFile struct {
+	Version string          `json:"version"`
+	Prompts []ProjectPrompt `json:"prompts"`
+}
+
+// LoadProjectPrompts reads the workspace prompt library as history entries flagged with
+// SourceProject. A missing library yields no entries.
+func LoadProjectPrompts(projectCwd string) ([]PromptHistoryEntry, error) {
+	if projectCwd == "" {
+		return []PromptHistoryEntry{}, nil
+	}
+	lib, err := readProjectLibrary(projectCwd)
+	if err != nil {
+		return []PromptHistoryEntry{}, err
+	}
+	cwd := normalizeProjectCwd(projectCwd)
+	entries := make([]PromptHistoryEntry, 0, len(lib.Prompts))
+	for _, p := range lib.Prompts {
+		if p.ID == "" || p.SerializedContent == "" {
+			continue
+		}
+		entries = append(entries, p.entry(cwd))
+	}
+	return entries, nil
+}
+
+// SaveProjectPrompt adds or replaces (by ID) a prompt in the workspace library
+func SaveProjectPrompt(projectCwd, id, title, serializedContent string) (PromptHistoryEntry, error) {
+	if projectCwd == "" {
+		return PromptHistoryEntry{}, fmt.Errorf("no workspace for project prompt library")
+	}
+	if serializedContent == "" {
+		return PromptHistoryEntry{}, fmt.Errorf("empty project prompt")
+	}
+	if id == "" {
+		id = uuid.New().String()
+	}
+	lib, err := readProjectLibrary(projectCwd)
+	if err != nil {
+		return PromptHistoryEntry{}, err
+	}
+	prompt := ProjectPrompt{
+		ID:                id,
+		Title:             title,
+		SerializedContent: serializedContent,
+		Timestamp:         time.Now().UnixMilli(),
+	}
+	replaced := false
+	for i := range lib.Prompts {
+		if lib.Prompts[i].ID == id {
+			lib.Prompts[i] = prompt
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		lib.Prompts = append(lib.Prompts, prompt)
+	}
+	if err := writeProjectLibrary(projectCwd, lib); err != nil {
+		return PromptHistoryEntry{}, err
+	}
+	return prompt.entry(normalizeProjectCwd(projectCwd)), nil
+}
+
+// RemoveProjectPrompt deletes a prompt from the workspace library by ID
+func RemoveProjectPrompt(projectCwd, id string) error {
+	if id == "" {
+		return fmt.Errorf("empty prompt ID")
+	}
+	lib, err := readProjectLibrary(projectCwd)
+	if err != nil {
+		return err
+	}
+	kept := lib.Prompts[:0]
+	for _, p := range lib.Prompts {
+		if p.ID != id {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(lib.Prompts) {
+		return fmt.Errorf("project prompt ID not found: %s", id)
+	}
+	lib.Prompts = kept
+	return writeProjectLibrary(projectCwd, lib)
+}
+
+// MergeProjectPrompts appends library entries to personal history. Personal entries with
+// the same ID win, so a template that was also sent keeps appearing once.
+func MergeProjectPrompts(personal, project []PromptHistoryEntry) []PromptHistoryEntry {
+	if len(project) == 0 {
+		return personal
+	}
+	seen := make(map[string]bool, len(personal))
+	for _, e := range personal {
+		seen[e.ID] = true
+	}
+	merged := make([]PromptHistoryEntry, 0, len(personal)+len(project))
+	merged = append(merged, personal...)
+	for _, e := range project {
+		if !seen[e.ID] {
+			merged = append(merged, e)
+		}
+	}
+	return merged
+}
+
+func (p ProjectPrompt) entry(projectCwd string) PromptHistoryEntry {
+	return PromptHistoryEntry{
+		ID:                p.ID,
+		Timestamp:         p.Timestamp,
+		SerializedContent: p.SerializedContent,
+		ProjectCwd:        projectCwd,
+		ReferencedPaths:   ExtractReferencedPaths(p.SerializedContent),
+		Source:            SourceProject,
+		Title:             p.Title,
+	}
+}
+
+func readProjectLibrary(projectCwd string) (projectLibraryFile, error) {
+	path := filepath.Join(projectCwd, projectLibraryPath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return projectLibraryFile{Version: "1.0"}, nil
+	}
+	if err != nil {
+		return projectLibraryFile{}, fmt.Errorf("failed to read project prompt library %s: %w", path, err)
+	}
+	var lib projectLibraryFile
+	if err := json.Unmarshal(data, &lib); err != nil {
+		return projectLibraryFile{}, fmt.Errorf("failed to parse project prompt library %s: %w", path, err)
+	}
+	if lib.Version == "" {
+		lib.Version = "1.0"
+	}
+	return lib, nil
+}
+
+func writeProjectLibrary(projectCwd string, lib projectLibraryFile) error {
+	path := filepath.Join(projectCwd, projectLibraryPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create project prompt library directory: %w", err)
+	}
+	if lib.Prompts == nil {
+		lib.Prompts = []ProjectPrompt{}
+	}
+	data, err := json.MarshalIndent(lib, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal project prompt library: %w", err)
+	}
+	data = append(data, '\n')
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write project prompt library %s: %w", tempFile, err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename project prompt library: %w", err)
+	}
+	return nil
+}
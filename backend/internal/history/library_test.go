@@ -0,0 +1,93 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectLibrary_SaveLoadRemove(t *testing.T) {
+	workspace := t.TempDir()
+
+	entries, err := LoadProjectPrompts(workspace)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Expected empty library, got %v, %v", entries, err)
+	}
+
+	saved, err := SaveProjectPrompt(workspace, "tpl-1", "Review", "<[#src/a.go][a.go]> review this")
+	if err != nil {
+		t.Fatalf("SaveProjectPrompt failed: %v", err)
+	}
+	if saved.Source != SourceProject || saved.Title != "Review" || saved.Timestamp == 0 {
+		t.Fatalf("Unexpected saved entry: %+v", saved)
+	}
+
+	// Saving with the same ID replaces the template
+	if _, err := SaveProjectPrompt(workspace, "tpl-1", "Review v2", "review again"); err != nil {
+		t.Fatalf("SaveProjectPrompt (replace) failed: %v", err)
+	}
+	if _, err := SaveProjectPrompt(workspace, "", "", "second"); err != nil {
+		t.Fatalf("SaveProjectPrompt (new) failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workspace, ".rovobridge", "prompts.json"))
+	if err != nil {
+		t.Fatalf("Expected library file in workspace: %v", err)
+	}
+	if strings.Contains(string(data), workspace) {
+		t.Errorf("Library file should not contain machine-specific paths:\n%s", data)
+	}
+
+	entries, err = LoadProjectPrompts(workspace)
+	if err != nil {
+		t.Fatalf("LoadProjectPrompts failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Title != "Review v2" || entries[0].Source != SourceProject {
+		t.Fatalf("Unexpected library entries: %+v", entries)
+	}
+
+	if err := RemoveProjectPrompt(workspace, "tpl-1"); err != nil {
+		t.Fatalf("RemoveProjectPrompt failed: %v", err)
+	}
+	if err := RemoveProjectPrompt(workspace, "tpl-1"); err == nil {
+		t.Errorf("Expected error removing missing project prompt")
+	}
+	entries, _ = LoadProjectPrompts(workspace)
+	if len(entries) != 1 || entries[0].SerializedContent != "second" {
+		t.Fatalf("Unexpected entries after removal: %+v", entries)
+	}
+}
+
+func TestMergeProjectPrompts(t *testing.T) {
+	personal := []PromptHistoryEntry{{ID: "a", Timestamp: 1}, {ID: "shared", Timestamp: 2}}
+	project := []PromptHistoryEntry{
+		{ID: "shared", Timestamp: 3, Source: SourceProject},
+		{ID: "tpl", Timestamp: 4, Source: SourceProject},
+	}
+	merged := MergeProjectPrompts(personal, project)
+	if len(merged) != 3 {
+		t.Fatalf("Expected 3 merged entries, got %+v", merged)
+	}
+	if merged[1].Source != "" || merged[2].ID != "tpl" || merged[2].Source != SourceProject {
+		t.Errorf("Unexpected merge result: %+v", merged)
+	}
+}
+
+func TestProjectLibrary_CorruptedFile(t *testing.T) {
+	workspace := t.TempDir()
+	dir := filepath.Join(workspace, ".rovobridge")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "prompts.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProjectPrompts(workspace); err == nil {
+		t.Errorf("Expected parse error for corrupted library")
+	}
+	// Writes must not clobber a library that could not be parsed
+	if _, err := SaveProjectPrompt(workspace, "", "", "x"); err == nil {
+		t.Errorf("Expected save to fail on corrupted library")
+	}
+}
@@ -19,29 +19,61 @@ type PromptHistoryEntry struct {
 	Timestamp         int64  `json:"timestamp"`
 	SerializedContent string `json:"serializedContent"`
 	ProjectCwd        string `json:"projectCwd"`
+
+	// Structured metadata; optional and absent in entries written by older versions
+	ReferencedPaths []string `json:"referencedPaths,omitempty"` // chip paths extracted from SerializedContent
+	SessionID       string   `json:"sessionId,omitempty"`
+	Agent           string   `json:"agent,omitempty"`
+	Model           string   `json:"model,omitempty"`
+
+	// Source is SourceProject for entries from the workspace prompt library, empty for personal history
+	Source string `json:"source,omitempty"`
+	Title  string `json:"title,omitempty"`
+}
+
+// PromptMetadata carries optional context about where a prompt was sent
+type PromptMetadata struct {
+	SessionID string
+	Agent     string
+	Model     string
 }
 
 // HistoryFile represents the structure of the history file
 type HistoryFile struct {
-	Version string               `json:"version"`
-	Entries []PromptHistoryEntry `json:"entries"`
+	Version string `json:"version"`
+	// Checksum is the SHA-256 of the compact JSON entries array, verified on load
+	Checksum string               `json:"checksum,omitempty"`
+	Entries  []PromptHistoryEntry `json:"entries"`
 }
 
 // HistoryManager manages the persistent storage of prompt history
 type HistoryManager struct {
-	filePath string
-	mu       sync.RWMutex
+	filePath    string
+	backupCount int // rolling backups kept next to the history file; 0 disables them
+	mu          sync.RWMutex
+
+	// store holds the entries when set; nil keeps them in the JSON file at filePath
+	store Storage
 }
 
 // NewHistoryManager creates a new HistoryManager instance
 func NewHistoryManager() *HistoryManager {
+	return NewHistoryManagerAt(getHistoryFilePath())
+}
+
+// NewHistoryManagerAt creates a HistoryManager keeping its history in filePath
+func NewHistoryManagerAt(filePath string) *HistoryManager {
 	return &HistoryManager{
-		filePath: getHistoryFilePath(),
+		filePath:    filePath,
+		backupCount: defaultBackupCount,
 	}
 }
 
 // LoadHistory loads the existing prompt history from the file
 func (h *HistoryManager) LoadHistory() ([]PromptHistoryEntry, error) {
+	if h.store != nil {
+		return h.store.Load()
+	}
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
@@ -69,15 +101,19 @@ func (h *HistoryManager) LoadHistory() ([]PromptHistoryEntry, error) {
 		return []PromptHistoryEntry{}, nil
 	}
 
-	var historyFile HistoryFile
-	if err := json.Unmarshal(data, &historyFile); err != nil {
-		log.Printf("Failed to parse history file %s (corrupted JSON): %v", h.filePath, err)
-		// Backup corrupted file and start fresh
+	historyFile, err := parseHistoryData(data)
+	if err != nil {
+		log.Printf("Failed to parse history file %s (corrupted): %v", h.filePath, err)
+		// Backup corrupted file, then fall back to the newest valid rolling backup
 		if backupErr := h.backupCorruptedFile(); backupErr != nil {
 			log.Printf("Failed to backup corrupted file: %v", backupErr)
 		} else {
-			log.Printf("Corrupted history file backed up, starting with empty history")
+			log.Printf("Corrupted history file backed up")
+		}
+		if entries, ok := h.restoreFromBackupUnsafe(); ok {
+			return entries, nil
 		}
+		log.Printf("No usable history backup, starting with empty history")
 		return []PromptHistoryEntry{}, nil
 	}
 
@@ -125,6 +161,7 @@ func (h *HistoryManager) SavePrompt(serializedContent string, projectCwd string)
 		Timestamp:         time.Now().UnixMilli(),
 		SerializedContent: serializedContent,
 		ProjectCwd:        projectCwd,
+		ReferencedPaths:   ExtractReferencedPaths(serializedContent),
 	}
 
 	return h.savePromptEntry(entry)
@@ -132,13 +169,22 @@ func (h *HistoryManager) SavePrompt(serializedContent string, projectCwd string)
 
 // SavePromptWithID adds a new prompt entry to the history file with a specific ID
 func (h *HistoryManager) SavePromptWithID(id, serializedContent string, projectCwd string) error {
+	_, err := h.SavePromptWithMetadata(id, serializedContent, projectCwd, PromptMetadata{})
+	return err
+}
+
+// SavePromptWithMetadata adds a new prompt entry with a specific ID and structured metadata.
+// Referenced paths are extracted from the serialized content. It returns the entry as
+// stored, including server-assigned fields (generated ID, timestamp, normalized projectCwd).
+// Empty prompts are not saved and yield a zero entry with a nil error.
+func (h *HistoryManager) SavePromptWithMetadata(id, serializedContent string, projectCwd string, meta PromptMetadata) (PromptHistoryEntry, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	// Validate input parameters
 	if serializedContent == "" {
 		log.Printf("Skipping save of empty prompt to history")
-		return nil // Don't save empty prompts
+		return PromptHistoryEntry{}, nil // Don't save empty prompts
 	}
 
 	if id == "" {
@@ -151,14 +197,41 @@ func (h *HistoryManager) SavePromptWithID(id, serializedContent string, projectC
 		ID:                id,
 		Timestamp:         time.Now().UnixMilli(),
 		SerializedContent: serializedContent,
-		ProjectCwd:        projectCwd,
+		ProjectCwd:        normalizeProjectCwd(projectCwd),
+		ReferencedPaths:   ExtractReferencedPaths(serializedContent),
+		SessionID:         meta.SessionID,
+		Agent:             meta.Agent,
+		Model:             meta.Model,
 	}
 
-	return h.savePromptEntry(entry)
+	if err := h.savePromptEntry(entry); err != nil {
+		return PromptHistoryEntry{}, err
+	}
+	return entry, nil
+}
+
+// normalizeProjectCwd cleans the project directory and makes it absolute when possible,
+// so entries saved from different sessions of the same project compare equal.
+func normalizeProjectCwd(projectCwd string) string {
+	if projectCwd == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(projectCwd); err == nil {
+		return abs
+	}
+	return filepath.Clean(projectCwd)
 }
 
 // savePromptEntry is the common implementation for saving prompt entries
 func (h *HistoryManager) savePromptEntry(entry PromptHistoryEntry) error {
+	if h.store != nil {
+		if err := h.store.Append(entry); err != nil {
+			log.Printf("Failed to save prompt to %s history: %v", h.store.Name(), err)
+			return fmt.Errorf("failed to save prompt to history: %w", err)
+		}
+		return nil
+	}
+	defer h.lockFileUnsafe()()
 
 	// Load existing history with error recovery
 	existingEntries, err := h.loadHistoryUnsafe()
@@ -184,8 +257,11 @@ func (h *HistoryManager) savePromptEntry(entry PromptHistoryEntry) error {
 	existingEntries = append(existingEntries, entry)
 
 	// Implement history size limit to prevent unbounded growth
-	const maxHistoryEntries = 10000
-	if len(existingEntries) > maxHistoryEntries {
+	compacting := len(existingEntries) > maxHistoryEntries
+	if err := h.rotateBackupUnsafe(compacting); err != nil {
+		log.Printf("Warning: failed to back up history before save: %v", err)
+	}
+	if compacting {
 		// Keep most recent entries
 		startIndex := len(existingEntries) - maxHistoryEntries
 		existingEntries = existingEntries[startIndex:]
@@ -222,9 +298,37 @@ func (h *HistoryManager) CreatePromptEntry(serializedContent string, projectCwd
 		Timestamp:         time.Now().UnixMilli(),
 		SerializedContent: serializedContent,
 		ProjectCwd:        projectCwd,
+		ReferencedPaths:   ExtractReferencedPaths(serializedContent),
 	}
 }
 
+// FindByReferencedPath returns history entries whose prompt referenced the given file,
+// ignoring line ranges. Relative paths are resolved against each entry's project directory.
+// Entries written before referencedPaths existed are matched by parsing their content.
+func (h *HistoryManager) FindByReferencedPath(path string) ([]PromptHistoryEntry, error) {
+	entries, err := h.LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+	return FilterByReferencedPath(entries, path), nil
+}
+
+// FilterByReferencedPath returns the entries whose prompt referenced path, as
+// FindByReferencedPath does for the history file.
+func FilterByReferencedPath(entries []PromptHistoryEntry, path string) []PromptHistoryEntry {
+	matches := []PromptHistoryEntry{}
+	for _, entry := range entries {
+		refs := entry.ReferencedPaths
+		if refs == nil {
+			refs = ExtractReferencedPaths(entry.SerializedContent)
+		}
+		if referencesPath(refs, entry.ProjectCwd, path) {
+			matches = append(matches, entry)
+		}
+	}
+	return matches
+}
+
 // RemovePrompt removes a prompt entry from the history file by ID
 func (h *HistoryManager) RemovePrompt(id string) error {
 	h.mu.Lock()
@@ -236,6 +340,11 @@ func (h *HistoryManager) RemovePrompt(id string) error {
 		return fmt.Errorf("empty prompt ID")
 	}
 
+	if h.store != nil {
+		return h.store.Remove(id)
+	}
+	defer h.lockFileUnsafe()()
+
 	// Load existing history with error recovery
 	existingEntries, err := h.loadHistoryUnsafe()
 	if err != nil {
@@ -257,7 +366,12 @@ func (h *HistoryManager) RemovePrompt(id string) error {
 
 	if !found {
 		log.Printf("Prompt ID not found for removal: %s", id)
-		return fmt.Errorf("prompt ID not found: %s", id)
+		return fmt.Errorf("%w: %s", ErrPromptNotFound, id)
+	}
+
+	// Removal drops data, so always keep a backup of the previous state
+	if err := h.rotateBackupUnsafe(true); err != nil {
+		log.Printf("Warning: failed to back up history before removal: %v", err)
 	}
 
 	// Save updated history
@@ -274,7 +388,8 @@ func (h *HistoryManager) RemovePrompt(id string) error {
 	return nil
 }
 
-// GetHistoryFilePath returns the path to the history file (for testing/debugging)
+// GetHistoryFilePath returns the path to the history file or database (for testing/debugging).
+// It is empty for history kept in memory.
 func (h *HistoryManager) GetHistoryFilePath() string {
 	return h.filePath
 }
@@ -291,8 +406,8 @@ func (h *HistoryManager) loadHistoryUnsafe() ([]PromptHistoryEntry, error) {
 		return nil, err
 	}
 
-	var historyFile HistoryFile
-	if err := json.Unmarshal(data, &historyFile); err != nil {
+	historyFile, err := parseHistoryData(data)
+	if err != nil {
 		return nil, err
 	}
 
@@ -315,6 +430,15 @@ func (h *HistoryManager) writeHistoryFile(historyFile HistoryFile) error {
 		historyFile.Version = "1.0"
 	}
 
+	// Compute integrity checksum over the entries array
+	rawEntries, err := json.Marshal(historyFile.Entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entries: %w", err)
+	}
+	if historyFile.Checksum, err = checksumEntries(rawEntries); err != nil {
+		return fmt.Errorf("failed to checksum history entries: %w", err)
+	}
+
 	// Marshal to JSON with indentation for readability
 	data, err := json.MarshalIndent(historyFile, "", "  ")
 	if err != nil {
@@ -413,6 +537,9 @@ func (h *HistoryManager) backupCorruptedFile() error {
 
 // ValidateHistoryFile checks if the history file is valid and can be parsed
 func (h *HistoryManager) ValidateHistoryFile() error {
+	if h.store != nil {
+		return h.store.Validate()
+	}
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
@@ -425,8 +552,8 @@ func (h *HistoryManager) ValidateHistoryFile() error {
 		return fmt.Errorf("cannot read history file: %w", err)
 	}
 
-	var historyFile HistoryFile
-	if err := json.Unmarshal(data, &historyFile); err != nil {
+	historyFile, err := parseHistoryData(data)
+	if err != nil {
 		return fmt.Errorf("history file is corrupted: %w", err)
 	}
 
@@ -450,8 +577,12 @@ func (h *HistoryManager) ValidateHistoryFile() error {
 
 // RecoverFromCorruption attempts to recover from a corrupted history file
 func (h *HistoryManager) RecoverFromCorruption() error {
+	if h.store != nil {
+		return nil // backends other than the JSON file keep their own consistency
+	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	defer h.lockFileUnsafe()()
 	return h.recoverFromCorruptionUnsafe()
 }
 
@@ -465,6 +596,12 @@ func (h *HistoryManager) recoverFromCorruptionUnsafe() error {
 		// Continue with recovery attempt even if backup fails
 	}
 
+	// Prefer real data from the newest valid rolling backup
+	if entries, ok := h.restoreFromBackupUnsafe(); ok {
+		log.Printf("Successfully recovered from corruption using backup with %d entries", len(entries))
+		return nil
+	}
+
 	// Try to salvage any readable entries from corrupted file
 	salvageCount := 0
 	var salvageEntries []PromptHistoryEntry
@@ -2,8 +2,10 @@ package history
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -556,3 +558,201 @@ func TestRemovePrompt_EmptyId(t *testing.T) {
 		t.Fatalf("RemovePrompt should have failed for empty ID")
 	}
 }
+
+func TestExtractReferencedPaths(t *testing.T) {
+	content := "<[#/src/main.js][main.js]> and <[#src/utils.js:3-9][utils.js:3-9]> plus <[#/src/main.js][main.js]> <[#short.go]>"
+	got := ExtractReferencedPaths(content)
+	want := []string{"/src/main.js", "src/utils.js:3-9", "short.go"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected path %d to be %q, got %q", i, want[i], got[i])
+		}
+	}
+	if paths := ExtractReferencedPaths("plain prompt"); paths != nil {
+		t.Errorf("Expected no paths for plain prompt, got %v", paths)
+	}
+}
+
+func TestSavePromptWithMetadata_AndFindByReferencedPath(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := &HistoryManager{
+		filePath: filepath.Join(tempDir, "test_history"),
+	}
+
+	meta := PromptMetadata{SessionID: "s1", Agent: "rovodev", Model: "test-model"}
+	if _, err := manager.SavePromptWithMetadata("id-1", "<[#src/a.go:1-4][a.go:1-4]> explain", "/proj", meta); err != nil {
+		t.Fatalf("SavePromptWithMetadata failed: %v", err)
+	}
+	if err := manager.SavePromptWithID("id-2", "<[#/proj/src/b.go][b.go]> fix", "/proj"); err != nil {
+		t.Fatalf("SavePromptWithID failed: %v", err)
+	}
+
+	entries, err := manager.LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	first := entries[0]
+	if first.SessionID != "s1" || first.Agent != "rovodev" || first.Model != "test-model" {
+		t.Errorf("Metadata not persisted: %+v", first)
+	}
+	if len(first.ReferencedPaths) != 1 || first.ReferencedPaths[0] != "src/a.go:1-4" {
+		t.Errorf("Expected referenced path src/a.go:1-4, got %v", first.ReferencedPaths)
+	}
+
+	// Relative reference with a line range matches the absolute file path
+	found, err := manager.FindByReferencedPath(filepath.Join("/proj", "src", "a.go"))
+	if err != nil {
+		t.Fatalf("FindByReferencedPath failed: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != "id-1" {
+		t.Fatalf("Expected id-1, got %+v", found)
+	}
+
+	found, err = manager.FindByReferencedPath("/proj/src/b.go")
+	if err != nil {
+		t.Fatalf("FindByReferencedPath failed: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != "id-2" {
+		t.Fatalf("Expected id-2, got %+v", found)
+	}
+}
+
+func TestSuggestSimilar(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := &HistoryManager{
+		filePath: filepath.Join(tempDir, "test_history"),
+	}
+
+	prompts := []string{
+		"Refactor the websocket router to reduce duplication",
+		"Write unit tests for the history manager",
+		"<[#/proj/internal/index/search.go][search.go]> speed up search scoring",
+	}
+	for i, p := range prompts {
+		if err := manager.SavePromptWithID(fmt.Sprintf("id-%d", i), p, "/proj"); err != nil {
+			t.Fatalf("SavePromptWithID failed: %v", err)
+		}
+	}
+
+	got, err := manager.SuggestSimilar("refactor router duplication", nil, "/proj", 5)
+	if err != nil {
+		t.Fatalf("SuggestSimilar failed: %v", err)
+	}
+	if len(got) == 0 || got[0].Entry.ID != "id-0" {
+		t.Fatalf("Expected id-0 as best suggestion, got %+v", got)
+	}
+
+	// A shared file reference alone is enough to surface a prompt
+	got, err = manager.SuggestSimilar("", []string{"/proj/internal/index/search.go"}, "/proj", 5)
+	if err != nil {
+		t.Fatalf("SuggestSimilar failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Entry.ID != "id-2" {
+		t.Fatalf("Expected only id-2 for shared path, got %+v", got)
+	}
+
+	got, err = manager.SuggestSimilar("", nil, "/proj", 5)
+	if err != nil {
+		t.Fatalf("SuggestSimilar failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("Expected no suggestions for empty draft, got %+v", got)
+	}
+}
+
+func TestBackupRotation_AndRestoreOnChecksumMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := &HistoryManager{
+		filePath:    filepath.Join(tempDir, "test_history"),
+		backupCount: 2,
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := manager.SavePromptWithID(fmt.Sprintf("id-%d", i), fmt.Sprintf("prompt %d", i), "/proj"); err != nil {
+			t.Fatalf("SavePromptWithID failed: %v", err)
+		}
+	}
+	// Removals always rotate, regardless of the routine backup interval
+	for _, id := range []string{"id-3", "id-2"} {
+		if err := manager.RemovePrompt(id); err != nil {
+			t.Fatalf("RemovePrompt failed: %v", err)
+		}
+	}
+	backups := manager.backupPaths()
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 rolling backups, found %d", len(backups))
+	}
+
+	// Tamper with the entries so the stored checksum no longer matches
+	data, err := os.ReadFile(manager.filePath)
+	if err != nil {
+		t.Fatalf("Failed to read history file: %v", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Failed to parse history file: %v", err)
+	}
+	if raw["checksum"] == "" || raw["checksum"] == nil {
+		t.Fatal("Expected checksum in history file header")
+	}
+	tampered := []byte(strings.Replace(string(data), "prompt 0", "prompt X", 1))
+	if err := os.WriteFile(manager.filePath, tampered, 0644); err != nil {
+		t.Fatalf("Failed to tamper history file: %v", err)
+	}
+	if err := manager.ValidateHistoryFile(); err == nil {
+		t.Fatal("Expected ValidateHistoryFile to detect checksum mismatch")
+	}
+
+	// Loading falls back to the newest backup (taken before removing id-2)
+	entries, err := manager.LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries restored from backup, got %d", len(entries))
+	}
+	if entries[0].SerializedContent != "prompt 0" {
+		t.Errorf("Expected untampered content from backup, got %q", entries[0].SerializedContent)
+	}
+	if err := manager.ValidateHistoryFile(); err != nil {
+		t.Fatalf("Expected restored history file to be valid: %v", err)
+	}
+}
+
+func TestSavePromptWithMetadata_ReturnsStoredEntry(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := &HistoryManager{
+		filePath: filepath.Join(tempDir, "test_history"),
+	}
+
+	entry, err := manager.SavePromptWithMetadata("", "hello", "/proj/./sub/..", PromptMetadata{})
+	if err != nil {
+		t.Fatalf("SavePromptWithMetadata failed: %v", err)
+	}
+	if entry.ID == "" || entry.Timestamp == 0 {
+		t.Fatalf("Expected server-assigned ID and timestamp, got %+v", entry)
+	}
+	if want := filepath.Clean("/proj"); entry.ProjectCwd != want {
+		t.Errorf("Expected normalized projectCwd %q, got %q", want, entry.ProjectCwd)
+	}
+
+	entries, err := manager.LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != entry.ID || entries[0].Timestamp != entry.Timestamp {
+		t.Fatalf("Returned entry %+v does not match stored %+v", entry, entries)
+	}
+
+	// Empty prompts are skipped without error
+	skipped, err := manager.SavePromptWithMetadata("x", "", "/proj", PromptMetadata{})
+	if err != nil || skipped.ID != "" {
+		t.Fatalf("Expected zero entry for empty prompt, got %+v, %v", skipped, err)
+	}
+}
@@ -0,0 +1,85 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PruneOptions selects the history entries Prune removes. Of the entries passing Filter,
+// those sent before Before are removed, and all but the newest Keep; at least one of the
+// two must be set.
+type PruneOptions struct {
+	Filter Filter
+	Before time.Time
+	Keep   int
+	DryRun bool // report the entries without removing them
+}
+
+// Prune removes old history entries and returns them, oldest first
+func (h *HistoryManager) Prune(opts PruneOptions) ([]PromptHistoryEntry, error) {
+	if opts.Before.IsZero() && opts.Keep <= 0 {
+		return nil, fmt.Errorf("prune needs an age or a number of entries to keep")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.store == nil {
+		defer h.lockFileUnsafe()()
+	}
+	var entries []PromptHistoryEntry
+	var err error
+	if h.store != nil {
+		entries, err = h.store.Load()
+	} else {
+		entries, err = h.loadHistoryUnsafe()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history for pruning: %w", err)
+	}
+
+	// Entries are kept oldest first, so the newest Keep matches are the last ones
+	matched := 0
+	for _, e := range entries {
+		if opts.Filter.Match(e) {
+			matched++
+		}
+	}
+	removed := []PromptHistoryEntry{}
+	kept := make([]PromptHistoryEntry, 0, len(entries))
+	seen := 0
+	for _, e := range entries {
+		if !opts.Filter.Match(e) {
+			kept = append(kept, e)
+			continue
+		}
+		seen++
+		tooOld := !opts.Before.IsZero() && e.Timestamp < opts.Before.UnixMilli()
+		tooMany := opts.Keep > 0 && matched-seen >= opts.Keep
+		if tooOld || tooMany {
+			removed = append(removed, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	if opts.DryRun || len(removed) == 0 {
+		return removed, nil
+	}
+
+	if h.store != nil {
+		for _, e := range removed {
+			if err := h.store.Remove(e.ID); err != nil && !errors.Is(err, ErrPromptNotFound) {
+				return nil, fmt.Errorf("failed to remove pruned history entry %s: %w", e.ID, err)
+			}
+		}
+		return removed, nil
+	}
+	// Pruning drops data, so always keep a backup of the previous state
+	if err := h.rotateBackupUnsafe(true); err != nil {
+		log.Printf("Warning: failed to back up history before pruning: %v", err)
+	}
+	if err := h.writeHistoryFile(HistoryFile{Version: "1.0", Entries: kept}); err != nil {
+		return nil, fmt.Errorf("failed to save pruned history: %w", err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	now := time.Now()
+	entries := []PromptHistoryEntry{
+		{ID: "a", Timestamp: now.Add(-48 * time.Hour).UnixMilli(), SerializedContent: "Fix <[#src/a.go]>", ProjectCwd: "/p"},
+		{ID: "b", Timestamp: now.UnixMilli(), SerializedContent: "Explain the router", ProjectCwd: "/p"},
+		{ID: "c", Timestamp: now.UnixMilli(), SerializedContent: "other", ProjectCwd: "/q", Title: "Router notes"},
+	}
+	ids := func(es []PromptHistoryEntry) string {
+		s := ""
+		for _, e := range es {
+			s += e.ID
+		}
+		return s
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"all", Filter{}, "abc"},
+		{"project", Filter{Project: "/p"}, "ab"},
+		{"path", Filter{Path: "/p/src/a.go"}, "a"},
+		{"query matches content and title", Filter{Query: "ROUTER"}, "bc"},
+		{"since", Filter{Since: now.Add(-time.Hour)}, "bc"},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.filter.Apply(entries)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryManager_Prune(t *testing.T) {
+	old := time.Now().Add(-100 * 24 * time.Hour).UnixMilli()
+	for backend, h := range storageBackends(t) {
+		for i, project := range []string{"/p", "/p", "/q", "/p", "/p"} {
+			if _, err := h.SavePromptWithMetadata(fmt.Sprintf("e%d", i), fmt.Sprintf("prompt %d", i), project, PromptMetadata{}); err != nil {
+				t.Fatalf("%s: save: %v", backend, err)
+			}
+		}
+		if _, err := h.Prune(PruneOptions{}); err == nil {
+			t.Fatalf("%s: prune without limits should fail", backend)
+		}
+
+		removed, err := h.Prune(PruneOptions{Filter: Filter{Project: "/p"}, Keep: 2, DryRun: true})
+		if err != nil || len(removed) != 2 || removed[0].ID != "e0" || removed[1].ID != "e1" {
+			t.Fatalf("%s: dry run = %+v, %v", backend, removed, err)
+		}
+		if entries, _ := h.LoadHistory(); len(entries) != 5 {
+			t.Fatalf("%s: dry run removed entries", backend)
+		}
+		if _, err := h.Prune(PruneOptions{Filter: Filter{Project: "/p"}, Keep: 2}); err != nil {
+			t.Fatalf("%s: prune: %v", backend, err)
+		}
+		entries, _ := h.LoadHistory()
+		if ids := []string{entries[0].ID, entries[1].ID, entries[2].ID}; len(entries) != 3 || ids[0] != "e2" || ids[1] != "e3" || ids[2] != "e4" {
+			t.Fatalf("%s: after keeping 2 of /p: %+v", backend, entries)
+		}
+
+		// Nothing is old enough
+		if removed, err := h.Prune(PruneOptions{Before: time.UnixMilli(old)}); err != nil || len(removed) != 0 {
+			t.Fatalf("%s: prune by age = %+v, %v", backend, removed, err)
+		}
+		if removed, err := h.Prune(PruneOptions{Before: time.Now().Add(time.Minute)}); err != nil || len(removed) != 3 {
+			t.Fatalf("%s: prune everything = %+v, %v", backend, removed, err)
+		}
+	}
+}
+
+func TestHistoryFile_LockedAcrossManagers(t *testing.T) {
+	// Two managers on one file stand for the bridge and the history CLI
+	path := filepath.Join(t.TempDir(), "history.json")
+	a, b := NewHistoryManagerAt(path), NewHistoryManagerAt(path)
+	a.SetBackupCount(0)
+	b.SetBackupCount(0)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, h := range []*HistoryManager{a, b} {
+			wg.Add(1)
+			go func(h *HistoryManager, i int) {
+				defer wg.Done()
+				if err := h.SavePrompt(fmt.Sprintf("prompt %d", i), "/p"); err != nil {
+					t.Error(err)
+				}
+			}(h, i)
+		}
+	}
+	wg.Wait()
+	entries, err := a.LoadHistory()
+	if err != nil || len(entries) != 40 {
+		t.Fatalf("expected all 40 prompts, got %d (%v)", len(entries), err)
+	}
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"strings"
+	"time"
+)
+
+// Filter selects history entries; zero fields select everything
+type Filter struct {
+	Project string    // the projectCwd entries were sent from
+	Path    string    // a path the entries reference
+	Query   string    // text the content or title contains, ignoring case
+	Since   time.Time // entries sent at or after it
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e PromptHistoryEntry) bool {
+	if f.Project != "" && e.ProjectCwd != normalizeProjectCwd(f.Project) {
+		return false
+	}
+	if f.Path != "" {
+		refs := e.ReferencedPaths
+		if refs == nil {
+			refs = ExtractReferencedPaths(e.SerializedContent)
+		}
+		if !referencesPath(refs, e.ProjectCwd, f.Path) {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && e.Timestamp < f.Since.UnixMilli() {
+		return false
+	}
+	if f.Query != "" {
+		q := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(e.SerializedContent), q) && !strings.Contains(strings.ToLower(e.Title), q) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the entries passing the filter, in their order
+func (f Filter) Apply(entries []PromptHistoryEntry) []PromptHistoryEntry {
+	out := []PromptHistoryEntry{}
+	for _, e := range entries {
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package history
+
+import (
+	"path/filepath"
+	"regexp"
+)
+
+// chipTokenRe matches the chip markup used by the web UI composer: <[#path][display]>
+// or the short form <[#path]>. The path may carry a ":start-end" line range suffix.
+var chipTokenRe = regexp.MustCompile(`<\[#([^\]]+)\](?:\[([^\]]*)\])?>`)
+
+// lineRangeSuffixRe matches the optional ":start-end" line range of a chip path.
+var lineRangeSuffixRe = regexp.MustCompile(`:\d+-\d+$`)
+
+// ExtractReferencedPaths returns the distinct chip paths referenced by serialized
+// prompt content, in order of first appearance.
+func ExtractReferencedPaths(serializedContent string) []string {
+	matches := chipTokenRe.FindAllStringSubmatch(serializedContent, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(matches))
+	paths := make([]string, 0, len(matches))
+	for _, m := range matches {
+		p := m[1]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// Chip is one file reference of serialized prompt content
+type Chip struct {
+	Start, End int    // byte offsets of the markup in the content
+	Path       string // as written, line range included
+	Display    string // the label of <[#path][display]>; empty in the short form
+}
+
+// ParseChips returns the chips of serialized prompt content in order of appearance,
+// repeated ones included.
+func ParseChips(serializedContent string) []Chip {
+	matches := chipTokenRe.FindAllStringSubmatchIndex(serializedContent, -1)
+	chips := make([]Chip, 0, len(matches))
+	for _, m := range matches {
+		c := Chip{Start: m[0], End: m[1], Path: serializedContent[m[2]:m[3]]}
+		if m[4] >= 0 {
+			c.Display = serializedContent[m[4]:m[5]]
+		}
+		chips = append(chips, c)
+	}
+	return chips
+}
+
+// FormatChip writes the markup of a chip, in the short form when display is empty.
+func FormatChip(path, display string) string {
+	if display == "" {
+		return "<[#" + path + "]>"
+	}
+	return "<[#" + path + "][" + display + "]>"
+}
+
+// referencesPath reports whether any of refs points at path, ignoring line ranges.
+// Relative references are resolved against projectCwd before comparing.
+func referencesPath(refs []string, projectCwd, path string) bool {
+	want := normalizeRefPath(path, projectCwd)
+	for _, ref := range refs {
+		if normalizeRefPath(ref, projectCwd) == want {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeRefPath(p, projectCwd string) string {
+	p = lineRangeSuffixRe.ReplaceAllString(p, "")
+	if !filepath.IsAbs(p) && projectCwd != "" {
+		p = filepath.Join(projectCwd, p)
+	}
+	return filepath.Clean(p)
+}
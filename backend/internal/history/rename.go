@@ -0,0 +1,133 @@
+package history
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// PathRename is a file or directory moved from From to To, both absolute
+type PathRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RewriteChipPaths points the chips of serialized content that reference a renamed path,
+// or a path inside a renamed directory, at its new place. Renames apply in order, so a
+// file moved twice ends up at its last place. Chips keep their line range, and their
+// relative or absolute form when the new path allows it; a display text naming the old
+// file is renamed too. It reports whether any chip changed.
+func RewriteChipPaths(content, projectCwd string, renames []PathRename) (string, bool) {
+	chips := ParseChips(content)
+	var b strings.Builder
+	last := 0
+	for _, c := range chips {
+		rewritten, ok := rewriteChip(c, projectCwd, renames)
+		if !ok {
+			continue
+		}
+		b.WriteString(content[last:c.Start])
+		b.WriteString(rewritten)
+		last = c.End
+	}
+	if last == 0 {
+		return content, false
+	}
+	b.WriteString(content[last:])
+	return b.String(), true
+}
+
+// rewriteChip returns the markup of chip c after renames, if any applies
+func rewriteChip(c Chip, projectCwd string, renames []PathRename) (string, bool) {
+	base := lineRangeSuffixRe.ReplaceAllString(c.Path, "")
+	lineRange := c.Path[len(base):]
+	abs := normalizeRefPath(base, projectCwd)
+	moved := false
+	for _, rn := range renames {
+		from := filepath.Clean(rn.From)
+		switch {
+		case abs == from:
+			abs, moved = filepath.Clean(rn.To), true
+		case strings.HasPrefix(abs, from+string(filepath.Separator)):
+			abs, moved = filepath.Join(rn.To, abs[len(from):]), true
+		}
+	}
+	if !moved {
+		return "", false
+	}
+	path := abs
+	if !filepath.IsAbs(base) && projectCwd != "" {
+		if rel, err := filepath.Rel(projectCwd, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			path = rel
+			if !strings.Contains(base, `\`) {
+				path = filepath.ToSlash(rel)
+			}
+		}
+	}
+	display := c.Display
+	switch display {
+	case filepath.Base(base) + lineRange:
+		display = filepath.Base(path) + lineRange
+	case base + lineRange:
+		display = path + lineRange
+	}
+	return FormatChip(path+lineRange, display), true
+}
+
+// RewritePaths applies renames to the chips of every history entry, recomputing their
+// referenced paths, and returns the entries that changed as they are now. With dryRun
+// nothing is stored.
+func (h *HistoryManager) RewritePaths(renames []PathRename, dryRun bool) ([]PromptHistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.store == nil {
+		defer h.lockFileUnsafe()()
+	}
+	var entries []PromptHistoryEntry
+	var err error
+	if h.store != nil {
+		entries, err = h.store.Load()
+	} else {
+		entries, err = h.loadHistoryUnsafe()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history for path rewrite: %w", err)
+	}
+	changed := []PromptHistoryEntry{}
+	for _, e := range entries {
+		content, ok := RewriteChipPaths(e.SerializedContent, e.ProjectCwd, renames)
+		if !ok {
+			continue
+		}
+		e.SerializedContent = content
+		e.ReferencedPaths = ExtractReferencedPaths(content)
+		changed = append(changed, e)
+	}
+	if dryRun || len(changed) == 0 {
+		return changed, nil
+	}
+	if h.store != nil {
+		if err := h.store.Update(changed); err != nil {
+			return nil, fmt.Errorf("failed to store rewritten history entries: %w", err)
+		}
+		return changed, nil
+	}
+	byID := make(map[string]PromptHistoryEntry, len(changed))
+	for _, e := range changed {
+		byID[e.ID] = e
+	}
+	for i, e := range entries {
+		if updated, ok := byID[e.ID]; ok {
+			entries[i] = updated
+		}
+	}
+	// The rewrite loses the old paths, so always keep a backup of the previous state
+	if err := h.rotateBackupUnsafe(true); err != nil {
+		log.Printf("Warning: failed to back up history before path rewrite: %v", err)
+	}
+	if err := h.writeHistoryFile(HistoryFile{Version: "1.0", Entries: entries}); err != nil {
+		return nil, fmt.Errorf("failed to save rewritten history: %w", err)
+	}
+	return changed, nil
+}
@@ -0,0 +1,80 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteChipPaths(t *testing.T) {
+	project := filepath.FromSlash("/work/project")
+	renames := []PathRename{
+		{From: filepath.Join(project, "src", "old.go"), To: filepath.Join(project, "src", "new.go")},
+		{From: filepath.Join(project, "lib"), To: filepath.Join(project, "pkg", "lib")},
+	}
+	tests := []struct {
+		name, content, want string
+		changed             bool
+	}{
+		{"relative file", "see <[#src/old.go][old.go]>", "see <[#src/new.go][new.go]>", true},
+		{"line range kept", "<[#src/old.go:3-9][old.go:3-9]>", "<[#src/new.go:3-9][new.go:3-9]>", true},
+		{"absolute chip", "<[#" + filepath.Join(project, "src", "old.go") + "]>", "<[#" + filepath.Join(project, "src", "new.go") + "]>", true},
+		{"inside moved dir", "<[#lib/a/b.go][my helper]>", "<[#pkg/lib/a/b.go][my helper]>", true},
+		{"display is the path", "<[#lib/x.go][lib/x.go]>", "<[#pkg/lib/x.go][pkg/lib/x.go]>", true},
+		{"prefix is not a dir", "<[#library/x.go]>", "<[#library/x.go]>", false},
+		{"untouched", "plain text <[#README.md]>", "plain text <[#README.md]>", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := RewriteChipPaths(tt.content, project, renames)
+			if got != tt.want || changed != tt.changed {
+				t.Fatalf("RewriteChipPaths(%q) = %q, %v; want %q, %v", tt.content, got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestRewriteChipPaths_AppliesRenamesInOrder(t *testing.T) {
+	project := filepath.FromSlash("/work/project")
+	renames := []PathRename{
+		{From: filepath.Join(project, "a.go"), To: filepath.Join(project, "b.go")},
+		{From: filepath.Join(project, "b.go"), To: filepath.Join(project, "c.go")},
+	}
+	if got, _ := RewriteChipPaths("<[#a.go]>", project, renames); got != "<[#c.go]>" {
+		t.Fatalf("got %q, want the last place of the file", got)
+	}
+}
+
+func TestHistoryManager_RewritePaths(t *testing.T) {
+	project := filepath.FromSlash("/work/project")
+	renames := []PathRename{{From: filepath.Join(project, "old.go"), To: filepath.Join(project, "sub", "new.go")}}
+	for backend, h := range storageBackends(t) {
+		if _, err := h.SavePromptWithMetadata("moved", "fix <[#old.go][old.go]>", project, PromptMetadata{}); err != nil {
+			t.Fatalf("%s: save: %v", backend, err)
+		}
+		if _, err := h.SavePromptWithMetadata("kept", "fix <[#other.go]>", project, PromptMetadata{}); err != nil {
+			t.Fatalf("%s: save: %v", backend, err)
+		}
+
+		changed, err := h.RewritePaths(renames, true)
+		if err != nil || len(changed) != 1 || changed[0].ID != "moved" {
+			t.Fatalf("%s: dry run = %+v, %v", backend, changed, err)
+		}
+		if entries, _ := h.LoadHistory(); entries[0].SerializedContent != "fix <[#old.go][old.go]>" {
+			t.Fatalf("%s: dry run stored the rewrite: %+v", backend, entries[0])
+		}
+
+		if _, err := h.RewritePaths(renames, false); err != nil {
+			t.Fatalf("%s: RewritePaths: %v", backend, err)
+		}
+		entries, err := h.LoadHistory()
+		if err != nil || len(entries) != 2 {
+			t.Fatalf("%s: LoadHistory = %+v, %v", backend, entries, err)
+		}
+		if entries[0].SerializedContent != "fix <[#sub/new.go][new.go]>" || len(entries[0].ReferencedPaths) != 1 || entries[0].ReferencedPaths[0] != "sub/new.go" {
+			t.Errorf("%s: rewritten entry = %+v", backend, entries[0])
+		}
+		if entries[1].SerializedContent != "fix <[#other.go]>" {
+			t.Errorf("%s: unrelated entry changed: %+v", backend, entries[1])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite" // pure Go driver, so builds need no cgo
+)
+
+// sqliteSchema creates the prompts table. Entries are stored as their JSON encoding so
+// fields added later need no migration; id and timestamp are columns for lookups.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS prompts (
+	seq       INTEGER PRIMARY KEY AUTOINCREMENT,
+	id        TEXT NOT NULL,
+	timestamp INTEGER NOT NULL,
+	entry     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS prompts_id ON prompts(id);
+`
+
+// sqliteStorage keeps the history in a SQLite database, written one entry at a time
+// instead of rewriting a whole file per prompt
+type sqliteStorage struct {
+	db   *sql.DB
+	path string
+}
+
+// openSQLiteStorage opens or creates the history database at path
+func openSQLiteStorage(path string) (*sqliteStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	// Every connection waits for locks held by other processes instead of failing
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database %s: %w", path, err)
+	}
+	return &sqliteStorage{db: db, path: path}, nil
+}
+
+func (s *sqliteStorage) Name() string { return BackendSQLite }
+
+func (s *sqliteStorage) Load() ([]PromptHistoryEntry, error) {
+	rows, err := s.db.Query("SELECT entry FROM prompts ORDER BY seq")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []PromptHistoryEntry{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var entry PromptHistoryEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			log.Printf("Skipping unreadable history entry in %s: %v", s.path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqliteStorage) Append(entry PromptHistoryEntry) error {
+	return s.insert([]PromptHistoryEntry{entry})
+}
+
+// insert adds entries in one transaction and trims the history to maxHistoryEntries
+func (s *sqliteStorage) insert(entries []PromptHistoryEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, entry := range entries {
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO prompts (id, timestamp, entry) VALUES (?, ?, ?)", entry.ID, entry.Timestamp, string(raw)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM prompts WHERE seq NOT IN (SELECT seq FROM prompts ORDER BY seq DESC LIMIT ?)", maxHistoryEntries); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStorage) Remove(id string) error {
+	res, err := s.db.Exec("DELETE FROM prompts WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to remove prompt from history: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s", ErrPromptNotFound, id)
+	}
+	return nil
+}
+
+func (s *sqliteStorage) Update(entries []PromptHistoryEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, entry := range entries {
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE prompts SET entry = ? WHERE id = ?", string(raw), entry.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStorage) Validate() error {
+	var result string
+	if err := s.db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("cannot check history database: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("history database is corrupted: %s", result)
+	}
+	return nil
+}
+
+func (s *sqliteStorage) Close() error {
+	return s.db.Close()
+}
+
+// importFrom copies the entries of a JSON history file into a new database
+func (s *sqliteStorage) importFrom(file *HistoryManager) error {
+	if _, err := os.Stat(file.filePath); err != nil {
+		return nil
+	}
+	entries, err := file.LoadHistory()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	if err := s.insert(entries); err != nil {
+		return fmt.Errorf("failed to import %s into the history database: %w", file.filePath, err)
+	}
+	log.Printf("Imported %d history entries from %s into %s", len(entries), file.filePath, s.path)
+	return nil
+}
@@ -0,0 +1,159 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// maxHistoryEntries bounds the history; the oldest entries are dropped beyond it
+const maxHistoryEntries = 10000
+
+// ErrPromptNotFound is returned when removing a prompt ID that is not in the history
+var ErrPromptNotFound = errors.New("prompt ID not found")
+
+// Storage is a backend keeping the prompt history entries. HistoryManager builds entries
+// (IDs, timestamps, referenced paths) and searches them; a Storage only persists them.
+// Implementations must be safe for concurrent use.
+type Storage interface {
+	// Name identifies the backend in logs and diagnostics, e.g. "sqlite"
+	Name() string
+	// Load returns all entries, oldest first
+	Load() ([]PromptHistoryEntry, error)
+	// Append stores entry after the existing ones, dropping the oldest beyond maxHistoryEntries
+	Append(entry PromptHistoryEntry) error
+	// Remove deletes the entries with the given ID, or returns ErrPromptNotFound
+	Remove(id string) error
+	// Update replaces the stored entries having the IDs of entries, in place; entries
+	// no longer stored are skipped
+	Update(entries []PromptHistoryEntry) error
+	// Validate reports whether the stored history is intact
+	Validate() error
+	Close() error
+}
+
+// Storage backends selectable by name
+const (
+	BackendFile   = "file"   // JSON file with checksums and rolling backups
+	BackendSQLite = "sqlite" // SQLite database
+	BackendMemory = "memory" // process memory only; nothing persists
+)
+
+// Backends lists the names OpenHistoryManager accepts
+var Backends = []string{BackendFile, BackendSQLite, BackendMemory}
+
+// NewHistoryManagerWithStorage creates a HistoryManager keeping its entries in store.
+// location is reported by GetHistoryFilePath; it is empty for stores without a path.
+func NewHistoryManagerWithStorage(store Storage, location string) *HistoryManager {
+	return &HistoryManager{filePath: location, store: store}
+}
+
+// OpenHistoryManager creates a HistoryManager using the named backend. path is the
+// history file or database; empty selects the default in the user's home directory.
+// A SQLite database created at the default path imports the entries of the JSON history
+// file, so switching backends keeps the history.
+func OpenHistoryManager(backend, path string) (*HistoryManager, error) {
+	switch backend {
+	case BackendFile, "":
+		if path == "" {
+			return NewHistoryManager(), nil
+		}
+		return NewHistoryManagerAt(path), nil
+	case BackendSQLite:
+		var legacy string
+		if path == "" {
+			path = getHistoryFilePath() + ".db"
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				legacy = getHistoryFilePath()
+			}
+		}
+		store, err := openSQLiteStorage(path)
+		if err != nil {
+			return nil, err
+		}
+		if legacy != "" {
+			if err := store.importFrom(NewHistoryManagerAt(legacy)); err != nil {
+				store.Close()
+				return nil, err
+			}
+		}
+		return NewHistoryManagerWithStorage(store, path), nil
+	case BackendMemory:
+		return NewHistoryManagerWithStorage(NewMemoryStorage(), ""), nil
+	}
+	return nil, fmt.Errorf("unknown history backend %q (want one of %v)", backend, Backends)
+}
+
+// Close releases the storage backend. The JSON file needs no closing.
+func (h *HistoryManager) Close() error {
+	if h.store == nil {
+		return nil
+	}
+	return h.store.Close()
+}
+
+// memoryStorage keeps the history in a slice; tests and mock mode use it
+type memoryStorage struct {
+	mu      sync.Mutex
+	entries []PromptHistoryEntry
+}
+
+// NewMemoryStorage returns a Storage holding the given entries in memory
+func NewMemoryStorage(entries ...PromptHistoryEntry) Storage {
+	return &memoryStorage{entries: append([]PromptHistoryEntry(nil), entries...)}
+}
+
+func (m *memoryStorage) Name() string { return BackendMemory }
+
+func (m *memoryStorage) Load() ([]PromptHistoryEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]PromptHistoryEntry{}, m.entries...), nil
+}
+
+func (m *memoryStorage) Append(entry PromptHistoryEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entry)
+	if n := len(m.entries) - maxHistoryEntries; n > 0 {
+		m.entries = append([]PromptHistoryEntry(nil), m.entries[n:]...)
+	}
+	return nil
+}
+
+func (m *memoryStorage) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.entries[:0]
+	for _, e := range m.entries {
+		if e.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(m.entries) {
+		return fmt.Errorf("%w: %s", ErrPromptNotFound, id)
+	}
+	clear(m.entries[len(kept):])
+	m.entries = kept
+	return nil
+}
+
+func (m *memoryStorage) Update(entries []PromptHistoryEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byID := make(map[string]PromptHistoryEntry, len(entries))
+	for _, e := range entries {
+		byID[e.ID] = e
+	}
+	for i, e := range m.entries {
+		if updated, ok := byID[e.ID]; ok {
+			m.entries[i] = updated
+		}
+	}
+	return nil
+}
+
+func (m *memoryStorage) Validate() error { return nil }
+
+func (m *memoryStorage) Close() error { return nil }
@@ -0,0 +1,123 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// storageBackends returns a fresh HistoryManager for every backend
+func storageBackends(t *testing.T) map[string]*HistoryManager {
+	t.Helper()
+	dir := t.TempDir()
+	managers := map[string]*HistoryManager{}
+	for _, backend := range Backends {
+		h, err := OpenHistoryManager(backend, filepath.Join(dir, "history-"+backend))
+		if err != nil {
+			t.Fatalf("%s: %v", backend, err)
+		}
+		t.Cleanup(func() { h.Close() })
+		managers[backend] = h
+	}
+	return managers
+}
+
+func TestStorage_BackendsBehaveAlike(t *testing.T) {
+	for backend, h := range storageBackends(t) {
+		for _, content := range []string{"first", "second <[#src/a.go]>", "third"} {
+			if err := h.SavePrompt(content, "/project"); err != nil {
+				t.Fatalf("%s: SavePrompt: %v", backend, err)
+			}
+		}
+		entry, err := h.SavePromptWithMetadata("fixed-id", "fourth", "/project", PromptMetadata{Agent: "rovodev"})
+		if err != nil || entry.Agent != "rovodev" {
+			t.Fatalf("%s: SavePromptWithMetadata = %+v, %v", backend, entry, err)
+		}
+
+		entries, err := h.LoadHistory()
+		if err != nil || len(entries) != 4 {
+			t.Fatalf("%s: LoadHistory = %d entries, %v", backend, len(entries), err)
+		}
+		if entries[1].SerializedContent != "second <[#src/a.go]>" || len(entries[1].ReferencedPaths) != 1 || entries[3].Agent != "rovodev" {
+			t.Errorf("%s: entries not kept in order with their metadata: %+v", backend, entries)
+		}
+
+		if err := h.RemovePrompt(entries[0].ID); err != nil {
+			t.Fatalf("%s: RemovePrompt: %v", backend, err)
+		}
+		if err := h.RemovePrompt(entries[0].ID); !errors.Is(err, ErrPromptNotFound) {
+			t.Errorf("%s: removing twice = %v, want ErrPromptNotFound", backend, err)
+		}
+		if entries, _ = h.LoadHistory(); len(entries) != 3 || entries[0].SerializedContent != "second <[#src/a.go]>" {
+			t.Errorf("%s: after removal = %+v", backend, entries)
+		}
+		if err := h.ValidateHistoryFile(); err != nil {
+			t.Errorf("%s: ValidateHistoryFile: %v", backend, err)
+		}
+	}
+}
+
+func TestStorage_SQLitePersistsAcrossOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	h, err := OpenHistoryManager(BackendSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SavePrompt("kept", "/project"); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	h, err = OpenHistoryManager(BackendSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if entries, err := h.LoadHistory(); err != nil || len(entries) != 1 || entries[0].SerializedContent != "kept" {
+		t.Fatalf("reopened history = %+v, %v", entries, err)
+	}
+	if h.GetHistoryFilePath() != path {
+		t.Errorf("GetHistoryFilePath = %q", h.GetHistoryFilePath())
+	}
+}
+
+func TestStorage_SQLiteImportsJSONHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	if err := NewHistoryManager().SavePrompt("from the JSON file", "/project"); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := OpenHistoryManager(BackendSQLite, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if h.GetHistoryFilePath() != filepath.Join(home, ".rovobridge.db") {
+		t.Errorf("database at %q", h.GetHistoryFilePath())
+	}
+	entries, err := h.LoadHistory()
+	if err != nil || len(entries) != 1 || entries[0].SerializedContent != "from the JSON file" {
+		t.Fatalf("imported history = %+v, %v", entries, err)
+	}
+
+	// Only an empty database imports, so removed entries do not come back
+	if err := h.RemovePrompt(entries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	if h, err = OpenHistoryManager(BackendSQLite, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if entries, _ := h.LoadHistory(); len(entries) != 0 {
+		t.Fatalf("entries re-imported: %+v", entries)
+	}
+}
+
+func TestOpenHistoryManager_UnknownBackend(t *testing.T) {
+	if _, err := OpenHistoryManager("postgres", ""); err == nil {
+		t.Fatal("unknown backend accepted")
+	}
+}
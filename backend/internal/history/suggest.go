@@ -0,0 +1,120 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// PromptSuggestion is a past history entry ranked by similarity to the current draft
+type PromptSuggestion struct {
+	Entry PromptHistoryEntry `json:"entry"`
+	Score float64            `json:"score"`
+}
+
+// Weights for combining the similarity signals; text dominates, shared files break ties
+const (
+	suggestTextWeight = 0.8
+	suggestPathWeight = 0.2
+	minSuggestScore   = 0.1
+)
+
+// SuggestSimilar returns up to limit history entries most similar to the draft text and
+// referenced paths. Text similarity is the Jaccard index over character trigrams of the
+// prompt words (chip markup removed), combined with the overlap of referenced files.
+// Entries scoring below a small threshold are omitted; duplicates of the draft are kept
+// since reusing an identical prompt is a valid suggestion.
+func (h *HistoryManager) SuggestSimilar(draft string, paths []string, projectCwd string, limit int) ([]PromptSuggestion, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	entries, err := h.LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	draftGrams := trigrams(promptText(draft))
+	draftRefs := append(ExtractReferencedPaths(draft), paths...)
+	if len(draftGrams) == 0 && len(draftRefs) == 0 {
+		return []PromptSuggestion{}, nil
+	}
+	draftPaths := make(map[string]bool, len(draftRefs))
+	for _, p := range draftRefs {
+		draftPaths[normalizeRefPath(p, projectCwd)] = true
+	}
+
+	suggestions := []PromptSuggestion{}
+	for _, entry := range entries {
+		textScore := jaccard(draftGrams, trigrams(promptText(entry.SerializedContent)))
+
+		refs := entry.ReferencedPaths
+		if refs == nil {
+			refs = ExtractReferencedPaths(entry.SerializedContent)
+		}
+		pathScore := 0.0
+		if len(draftPaths) > 0 && len(refs) > 0 {
+			shared := 0
+			for _, ref := range refs {
+				if draftPaths[normalizeRefPath(ref, entry.ProjectCwd)] {
+					shared++
+				}
+			}
+			pathScore = float64(shared) / float64(len(draftPaths)+len(refs)-shared)
+		}
+
+		score := suggestTextWeight*textScore + suggestPathWeight*pathScore
+		if score < minSuggestScore {
+			continue
+		}
+		suggestions = append(suggestions, PromptSuggestion{Entry: entry, Score: score})
+	}
+
+	// Best first; newer entries win ties
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Entry.Timestamp > suggestions[j].Entry.Timestamp
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// promptText strips chip markup and returns the lower-cased words of a prompt
+func promptText(serialized string) []string {
+	text := chipTokenRe.ReplaceAllString(serialized, " ")
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// trigrams returns the set of character trigrams of the words, each word padded with
+// spaces so that short words still contribute
+func trigrams(words []string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, w := range words {
+		runes := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for g := range a {
+		if _, ok := b[g]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,42 @@
+package index
+
+import (
+	"strings"
+	"sync"
+)
+
+// searchCache remembers which entries matched the previous ranked query.
+// Successive keystrokes usually extend the pattern, and any entry matching the
+// longer pattern also matches its prefix (both substring and subsequence matching
+// are monotonic), so the next query only needs to rescore the cached candidates.
+// A nil *searchCache is valid and never hits.
+type searchCache struct {
+	mu      sync.Mutex
+	pattern string  // lowered pattern of the cached query
+	cands   []int32 // indexes within Snapshot.Entries that matched pattern
+}
+
+// lookup returns the cached candidates if pattern extends the cached pattern, or nil.
+// The returned slice must not be modified.
+func (c *searchCache) lookup(pattern string) []int32 {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pattern == "" || c.cands == nil || !strings.HasPrefix(pattern, c.pattern) {
+		return nil
+	}
+	return c.cands
+}
+
+// store replaces the cached candidates with those matching pattern.
+func (c *searchCache) store(pattern string, cands []int32) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.pattern = pattern
+	c.cands = cands
+	c.mu.Unlock()
+}
@@ -0,0 +1,54 @@
+package index
+
+import "strings"
+
+// compactEntries rewrites entries so that every Path shares a single backing string
+// (arena) and Name/Short reuse the tail of their Path whenever they are a suffix of it.
+// On large trees this replaces three independent allocations per entry with one
+// shared buffer. The arena is kept alive as long as any entry of the set is referenced,
+// which is fine because entries are always published and dropped as a whole.
+// It also precomputes the case-folded forms used by Search so that scoring does not
+// lower-case every entry on each keystroke.
+func compactEntries(entries []Entry) {
+	total := 0
+	for _, e := range entries {
+		total += len(e.Path)
+	}
+	var b strings.Builder
+	b.Grow(total)
+	for _, e := range entries {
+		b.WriteString(e.Path)
+	}
+	arena := b.String()
+
+	off := 0
+	for i := range entries {
+		e := &entries[i]
+		p := arena[off : off+len(e.Path)]
+		off += len(e.Path)
+		e.Path = p
+		e.Name = suffixOf(p, e.Name)
+		e.Short = suffixOf(p, e.Short)
+		e.lpath = foldASCII(p)
+		e.lname = suffixOf(e.lpath, foldASCII(e.Name))
+		e.lshort = suffixOf(e.lpath, foldASCII(e.Short))
+	}
+}
+
+// suffixOf returns s as a slice of path when s is a suffix of path, so that no
+// separate backing memory is retained for it. Otherwise s is returned unchanged.
+func suffixOf(path, s string) string {
+	if s == "" || !strings.HasSuffix(path, s) {
+		return s
+	}
+	return path[len(path)-len(s):]
+}
+
+// buildByName indexes entries by base name.
+func buildByName(entries []Entry) map[string][]int {
+	byName := make(map[string][]int, len(entries))
+	for i, e := range entries {
+		byName[e.Name] = append(byName[e.Name], i)
+	}
+	return byName
+}
@@ -0,0 +1,158 @@
+package index
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultContextBudget is the token budget used by SelectContext when none is given
+const DefaultContextBudget = 8000
+
+const (
+	maxContextKeywords   = 12 // distinct prompt words searched in the index
+	contextHitsPerWord   = 20 // ranked hits considered per word
+	maxContextCandidates = 30 // candidates returned, selected or not
+	contextGitBonus      = 60
+)
+
+// ContextCandidate is a file proposed for injection together with its estimated cost
+type ContextCandidate struct {
+	Path     string   `json:"path"`
+	Tokens   int      `json:"tokens"`
+	Score    int      `json:"score"`
+	Reasons  []string `json:"reasons"` // "match", "recent" and/or "git"
+	Selected bool     `json:"selected"`
+}
+
+// contextStopWords are frequent prompt words that would match half of any tree
+var contextStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true,
+	"from": true, "into": true, "file": true, "files": true, "code": true, "please": true,
+	"add": true, "fix": true, "make": true, "use": true, "should": true, "when": true,
+	"what": true, "how": true, "why": true, "can": true, "not": true, "all": true,
+}
+
+// SelectContext proposes files to inject for a prompt draft within a token budget.
+// Candidates are ranked by index search hits for the prompt's words, how recently they
+// were modified and whether git reports them as changed; the highest ranked ones that
+// fit the budget are marked selected. root is used to stat files, changed is the
+// result of GitChangedFiles (may be nil). The returned total is the selected tokens.
+func (s Snapshot) SelectContext(root, prompt string, budget int, changed map[string]bool) ([]ContextCandidate, int) {
+	return s.SelectContextFS(os.DirFS(root), prompt, budget, changed)
+}
+
+// SelectContextFS is SelectContext with files stat'ed in fsys, whose root is the index root
+func (s Snapshot) SelectContextFS(fsys fs.FS, prompt string, budget int, changed map[string]bool) ([]ContextCandidate, int) {
+	if budget <= 0 {
+		budget = DefaultContextBudget
+	}
+	scores := map[string]int{}
+	reasons := map[string][]string{}
+	addReason := func(p, reason string) {
+		for _, r := range reasons[p] {
+			if r == reason {
+				return
+			}
+		}
+		reasons[p] = append(reasons[p], reason)
+	}
+
+	for _, word := range contextKeywords(prompt) {
+		hits, _ := s.SearchWithProfile(word, contextHitsPerWord, nil, DefaultProfile)
+		for rank, e := range hits {
+			if e.IsDir {
+				continue
+			}
+			scores[e.Path] += 100 - rank*100/contextHitsPerWord
+			addReason(e.Path, "match")
+		}
+	}
+	for p := range changed {
+		scores[p] += contextGitBonus
+		addReason(p, "git")
+	}
+
+	now := time.Now()
+	candidates := make([]ContextCandidate, 0, len(scores))
+	for p, score := range scores {
+		info, err := fs.Stat(fsys, filepath.ToSlash(p))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if bonus := recencyBonus(now.Sub(info.ModTime())); bonus > 0 {
+			score += bonus
+			addReason(p, "recent")
+		}
+		candidates = append(candidates, ContextCandidate{
+			Path:    p,
+			Tokens:  estimateTokens(info.Size()),
+			Score:   score,
+			Reasons: reasons[p],
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Path < candidates[j].Path
+	})
+	if len(candidates) > maxContextCandidates {
+		candidates = candidates[:maxContextCandidates]
+	}
+
+	total := 0
+	for i := range candidates {
+		if total+candidates[i].Tokens <= budget {
+			candidates[i].Selected = true
+			total += candidates[i].Tokens
+		}
+	}
+	return candidates, total
+}
+
+// contextKeywords extracts distinct search words from a prompt, longest first
+func contextKeywords(prompt string) []string {
+	words := strings.FieldsFunc(prompt, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.'
+	})
+	seen := map[string]bool{}
+	var out []string
+	for _, w := range words {
+		w = strings.Trim(w, ".")
+		lw := strings.ToLower(w)
+		if len(lw) < 3 || contextStopWords[lw] || seen[lw] {
+			continue
+		}
+		seen[lw] = true
+		out = append(out, w)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i]) > len(out[j]) })
+	if len(out) > maxContextKeywords {
+		out = out[:maxContextKeywords]
+	}
+	return out
+}
+
+// recencyBonus favors files edited in the last hour, day and week
+func recencyBonus(age time.Duration) int {
+	switch {
+	case age < time.Hour:
+		return 40
+	case age < 24*time.Hour:
+		return 25
+	case age < 7*24*time.Hour:
+		return 10
+	}
+	return 0
+}
+
+// estimateTokens approximates the token cost of injecting a file of the given size,
+// using the common ~4 bytes per token heuristic plus the numbered-line overhead.
+func estimateTokens(size int64) int {
+	return int(size/4) + int(size/40) + 1
+}
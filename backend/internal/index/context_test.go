@@ -0,0 +1,84 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSelectContext_BudgetAndReasons(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]int{
+		"router.go":  400,  // ~110 tokens
+		"session.go": 4000, // ~1100 tokens
+		"notes.txt":  40,
+	}
+	var entries []Entry
+	for name, size := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry(name))
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	for name := range files {
+		_ = os.Chtimes(filepath.Join(root, name), old, old)
+	}
+	s := Snapshot{Entries: entries}
+
+	cands, total := s.SelectContext(root, "please fix the router and session handling", 500, map[string]bool{"notes.txt": true})
+	byPath := map[string]ContextCandidate{}
+	for _, c := range cands {
+		byPath[c.Path] = c
+	}
+	if len(byPath) != 3 {
+		t.Fatalf("expected 3 candidates, got %+v", cands)
+	}
+	if !byPath["router.go"].Selected || byPath["session.go"].Selected {
+		t.Errorf("expected router.go selected and session.go over budget, got %+v", cands)
+	}
+	if c := byPath["notes.txt"]; !c.Selected || len(c.Reasons) != 1 || c.Reasons[0] != "git" {
+		t.Errorf("expected notes.txt selected via git status, got %+v", c)
+	}
+	if total != byPath["router.go"].Tokens+byPath["notes.txt"].Tokens || total > 500 {
+		t.Errorf("unexpected total %d for %+v", total, cands)
+	}
+}
+
+func TestContextKeywords(t *testing.T) {
+	got := contextKeywords("Fix the parseConfig in config.yaml, and the API")
+	want := []string{"parseConfig", "config.yaml", "API"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestNewFromFS_IndexesWithoutDisk(t *testing.T) {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	fsys := fstest.MapFS{
+		"cmd/app/main.go":    {Data: []byte(strings.Repeat("x", 400)), ModTime: old},
+		"internal/router.go": {Data: []byte(strings.Repeat("x", 400)), ModTime: old},
+		".git/HEAD":          {Data: []byte("ref: refs/heads/main\n")},
+	}
+	ix := NewFromFS(filepath.Join(t.TempDir(), "missing"), fsys)
+	defer ix.Close()
+	ix.RequestRefresh() // must not rescan the (missing) root
+
+	snap := ix.Snapshot()
+	if _, ok := snap.Lookup("internal/router.go"); !ok {
+		t.Fatalf("router.go not indexed: %+v", snap.Entries)
+	}
+	if _, ok := snap.Lookup(".git/HEAD"); ok {
+		t.Fatal(".git was indexed")
+	}
+	if _, ok := snap.Lookup("cmd"); !ok {
+		t.Fatal("parent directory not indexed")
+	}
+	cands, _ := snap.SelectContextFS(fsys, "update the router", 500, nil)
+	if len(cands) != 1 || cands[0].Path != filepath.FromSlash("internal/router.go") || !cands[0].Selected {
+		t.Fatalf("candidates = %+v", cands)
+	}
+}
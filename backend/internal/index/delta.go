@@ -0,0 +1,190 @@
+package index
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// RenamedEntry is an entry that moved, with its old path and its new form
+type RenamedEntry struct {
+	From string `json:"from"`
+	ExportEntry
+}
+
+// Delta is how the entries changed from one published snapshot to the next. A client
+// holding the old entries reaches the new ones by dropping Removed, moving Renamed,
+// then adding Added and replacing Updated.
+type Delta struct {
+	Gen     uint64         `json:"-"` // generation of the new snapshot
+	Added   []ExportEntry  `json:"added,omitempty"`
+	Removed []string       `json:"removed,omitempty"`
+	Renamed []RenamedEntry `json:"renamed,omitempty"` // every moved entry, those under a moved directory too
+	Updated []ExportEntry  `json:"updated,omitempty"` // entries whose short name changed
+}
+
+// Empty reports whether nothing changed
+func (d Delta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0 && len(d.Updated) == 0
+}
+
+// SetChangeHandler calls fn with the Delta of every published snapshot, in order; fn must
+// not block, as scans wait for it
+func (ix *Indexer) SetChangeHandler(fn func(Delta)) {
+	ix.changeMu.Lock()
+	ix.onChange = fn
+	ix.changeMu.Unlock()
+}
+
+// Diff compares two entry lists sorted by path. Moves are paired only where they are
+// unambiguous: an entry whose base name is removed and added once each, or a directory
+// renamed in place that kept its contents. Anything else is a removal and an addition.
+func Diff(old, new []Entry) Delta {
+	var d Delta
+	var removed, added []Entry
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case j == len(new) || i < len(old) && old[i].Path < new[j].Path:
+			removed = append(removed, old[i])
+			i++
+		case i == len(old) || new[j].Path < old[i].Path:
+			added = append(added, new[j])
+			j++
+		default:
+			if o, n := old[i], new[j]; o.IsDir != n.IsDir {
+				removed, added = append(removed, o), append(added, n)
+			} else if o.Short != n.Short || o.Name != n.Name {
+				d.Updated = append(d.Updated, toExportEntry(n))
+			}
+			i++
+			j++
+		}
+	}
+	if len(removed) == 0 && len(added) == 0 {
+		return d
+	}
+
+	p := newRenamePairing(removed, added)
+	p.pairUnique(true, false)
+	p.pairUnique(true, true)
+	p.followDirs()
+	p.pairUnique(false, false)
+	for _, e := range removed {
+		if to, ok := p.pairs[e.Path]; ok {
+			d.Renamed = append(d.Renamed, RenamedEntry{From: e.Path, ExportEntry: toExportEntry(p.added[to])})
+		} else {
+			d.Removed = append(d.Removed, e.Path)
+		}
+	}
+	for _, e := range added {
+		if !p.taken[e.Path] {
+			d.Added = append(d.Added, toExportEntry(e))
+		}
+	}
+	return d
+}
+
+// renamePairing matches removed entries with the added entries they were moved to
+type renamePairing struct {
+	removedList, addedList []Entry
+	added                  map[string]Entry
+	pairs                  map[string]string // new path by old path
+	taken                  map[string]bool   // new paths paired
+}
+
+func newRenamePairing(removed, added []Entry) *renamePairing {
+	p := &renamePairing{removedList: removed, addedList: added, added: make(map[string]Entry, len(added)),
+		pairs: map[string]string{}, taken: map[string]bool{}}
+	for _, e := range added {
+		p.added[e.Path] = e
+	}
+	return p
+}
+
+// pairUnique pairs the unpaired directories, or files, that are the only removed and the
+// only added one with their base name, or with their parent directory. Directories paired
+// by their parent must have kept their contents, so deleting one and creating another
+// next to it is no rename.
+func (p *renamePairing) pairUnique(dirs, byParent bool) {
+	key := filepath.Base
+	if byParent {
+		key = filepath.Dir
+	}
+	type candidates struct {
+		from, to []string
+	}
+	byKey := map[string]*candidates{}
+	at := func(k string) *candidates {
+		c := byKey[k]
+		if c == nil {
+			c = &candidates{}
+			byKey[k] = c
+		}
+		return c
+	}
+	for _, e := range p.removedList {
+		if e.IsDir == dirs && p.pairs[e.Path] == "" {
+			c := at(key(e.Path))
+			c.from = append(c.from, e.Path)
+		}
+	}
+	for _, e := range p.addedList {
+		if e.IsDir == dirs && !p.taken[e.Path] {
+			c := at(key(e.Path))
+			c.to = append(c.to, e.Path)
+		}
+	}
+	for _, c := range byKey {
+		if len(c.from) != 1 || len(c.to) != 1 || c.from[0] == c.to[0] {
+			continue
+		}
+		if byParent && !p.keptContents(c.from[0], c.to[0]) {
+			continue
+		}
+		p.pairs[c.from[0]] = c.to[0]
+		p.taken[c.to[0]] = true
+	}
+}
+
+// keptContents reports whether a removed directory and an added one have an entry in
+// common, or are both empty
+func (p *renamePairing) keptContents(from, to string) bool {
+	sep := string(filepath.Separator)
+	inFrom, inTo, common := 0, 0, 0
+	for _, e := range p.removedList {
+		if rest, ok := strings.CutPrefix(e.Path, from+sep); ok {
+			inFrom++
+			if _, ok := p.added[to+sep+rest]; ok {
+				common++
+			}
+		}
+	}
+	for _, e := range p.addedList {
+		if strings.HasPrefix(e.Path, to+sep) {
+			inTo++
+		}
+	}
+	return common > 0 || inFrom == 0 && inTo == 0
+}
+
+// followDirs pairs the entries under a moved directory with their counterparts under its
+// new path. The removed entries are sorted, so a directory is paired before its contents.
+func (p *renamePairing) followDirs() {
+	for _, e := range p.removedList {
+		if p.pairs[e.Path] != "" {
+			continue
+		}
+		for dir := filepath.Dir(e.Path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			to, ok := p.pairs[dir]
+			if !ok {
+				continue
+			}
+			dest := to + e.Path[len(dir):]
+			if a, ok := p.added[dest]; ok && a.IsDir == e.IsDir && !p.taken[dest] {
+				p.pairs[e.Path] = dest
+				p.taken[dest] = true
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package index
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// entriesOf builds sorted entries with short names from slash paths; a trailing slash
+// marks a directory
+func entriesOf(paths ...string) []Entry {
+	out := make([]Entry, 0, len(paths))
+	for _, p := range paths {
+		isDir := strings.HasSuffix(p, "/")
+		p = filepath.FromSlash(strings.TrimSuffix(p, "/"))
+		out = append(out, Entry{Path: p, Name: filepath.Base(p), IsDir: isDir})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	computeShortNames(out)
+	return out
+}
+
+func renamesOf(d Delta) map[string]string {
+	out := map[string]string{}
+	for _, rn := range d.Renamed {
+		out[filepath.ToSlash(rn.From)] = filepath.ToSlash(rn.Path)
+	}
+	return out
+}
+
+func slashPaths(paths []string) []string {
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		out[i] = filepath.ToSlash(p)
+	}
+	return out
+}
+
+func TestDiff_AddsAndRemoves(t *testing.T) {
+	d := Diff(entriesOf("a.go", "b.go"), entriesOf("b.go", "c.txt"))
+	if len(d.Added) != 1 || d.Added[0].Path != "c.txt" || !reflect.DeepEqual(d.Removed, []string{"a.go"}) || len(d.Renamed) != 0 {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+	if !Diff(entriesOf("a.go"), entriesOf("a.go")).Empty() {
+		t.Fatal("expected no change")
+	}
+}
+
+func TestDiff_PairsMoves(t *testing.T) {
+	// A file moved to another directory keeps its name
+	d := Diff(entriesOf("src/", "src/main.go", "cmd/"), entriesOf("src/", "cmd/", "cmd/main.go"))
+	if got := renamesOf(d); !reflect.DeepEqual(got, map[string]string{"src/main.go": "cmd/main.go"}) || len(d.Added)+len(d.Removed) != 0 {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+
+	// A directory renamed in place brings its contents along
+	d = Diff(entriesOf("lib/", "lib/a.go", "lib/x/", "lib/x/b.go"), entriesOf("pkg/", "pkg/a.go", "pkg/x/", "pkg/x/b.go"))
+	want := map[string]string{"lib": "pkg", "lib/a.go": "pkg/a.go", "lib/x": "pkg/x", "lib/x/b.go": "pkg/x/b.go"}
+	if got := renamesOf(d); !reflect.DeepEqual(got, want) || len(d.Added)+len(d.Removed) != 0 {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+}
+
+func TestDiff_LeavesAmbiguousChangesAlone(t *testing.T) {
+	// Two files of the same name could have gone either way
+	d := Diff(entriesOf("a/util.go", "b/util.go"), entriesOf("c/util.go", "d/util.go"))
+	if len(d.Renamed) != 0 || len(d.Removed) != 2 || len(d.Added) != 2 {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+	// A directory deleted and another created next to it share nothing
+	d = Diff(entriesOf("old/", "old/a.go"), entriesOf("new/", "new/b.go"))
+	if len(d.Renamed) != 0 || !reflect.DeepEqual(slashPaths(d.Removed), []string{"old", "old/a.go"}) {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+	// A file replaced by a directory of the same name is no move
+	d = Diff(entriesOf("build"), entriesOf("build/"))
+	if len(d.Renamed) != 0 || len(d.Removed) != 1 || len(d.Added) != 1 || !d.Added[0].IsDir {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+}
+
+func TestDiff_ReportsChangedShortNames(t *testing.T) {
+	d := Diff(entriesOf("a/main.go"), entriesOf("a/main.go", "b/main.go"))
+	if len(d.Updated) != 1 || d.Updated[0].Path != filepath.FromSlash("a/main.go") || d.Updated[0].Short == "main.go" {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+}
+
+func TestIndexer_ChangeHandler(t *testing.T) {
+	ix := New(t.TempDir())
+	var deltas []Delta
+	ix.SetChangeHandler(func(d Delta) { deltas = append(deltas, d) })
+	ix.publish(entriesOf("a.go"))
+	ix.publish(entriesOf("a.go"))
+	ix.publish(entriesOf("b.go"))
+	if len(deltas) != 2 || len(deltas[0].Added) != 1 || len(deltas[1].Removed) != 1 {
+		t.Fatalf("unexpected deltas: %+v", deltas)
+	}
+	if deltas[1].Gen != ix.Snapshot().Gen || deltas[0].Gen >= deltas[1].Gen {
+		t.Fatalf("unexpected generations: %d, %d, snapshot %d", deltas[0].Gen, deltas[1].Gen, ix.Snapshot().Gen)
+	}
+}
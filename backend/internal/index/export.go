@@ -0,0 +1,75 @@
+package index
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// ExportEntry is the serialized form of an Entry returned by the index export API.
+type ExportEntry struct {
+	Path  string `json:"path"` // relative to root, with OS-specific separators (same as searchResult)
+	Name  string `json:"name"`
+	Short string `json:"short"`
+	IsDir bool   `json:"isDir"`
+}
+
+func toExportEntry(e Entry) ExportEntry {
+	return ExportEntry{Path: e.Path, Name: e.Name, Short: e.Short, IsDir: e.IsDir}
+}
+
+// Export returns all entries of the snapshot in their serialized form.
+func (s Snapshot) Export() []ExportEntry {
+	out := make([]ExportEntry, len(s.Entries))
+	for i, e := range s.Entries {
+		out[i] = toExportEntry(e)
+	}
+	return out
+}
+
+// WriteJSON streams the snapshot as a single JSON object
+// {"root": ..., "count": N, "entries": [...]} without materializing it in memory.
+func (s Snapshot) WriteJSON(w io.Writer, root string) error {
+	bw := bufio.NewWriterSize(w, 64*1024)
+	head, err := json.Marshal(map[string]any{"root": root, "count": len(s.Entries)})
+	if err != nil {
+		return err
+	}
+	// splice the entries array into the header object
+	if _, err := bw.Write(head[:len(head)-1]); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString(`,"entries":[`); err != nil {
+		return err
+	}
+	for i, e := range s.Entries {
+		if i > 0 {
+			if err := bw.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		buf, err := json.Marshal(toExportEntry(e))
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	if _, err := bw.WriteString("]}\n"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// WriteNDJSON streams the snapshot as newline-delimited JSON, one entry per line.
+func (s Snapshot) WriteNDJSON(w io.Writer) error {
+	bw := bufio.NewWriterSize(w, 64*1024)
+	enc := json.NewEncoder(bw)
+	for _, e := range s.Entries {
+		if err := enc.Encode(toExportEntry(e)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
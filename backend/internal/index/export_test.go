@@ -0,0 +1,99 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// failingWriter takes the first n bytes and fails every write after them
+type failingWriter struct {
+	n, late int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n <= 0 {
+		w.late++
+		return 0, errWriteFailed
+	}
+	k := min(len(p), w.n)
+	w.n -= k
+	if k < len(p) {
+		return k, errWriteFailed
+	}
+	return k, nil
+}
+
+func TestSnapshotWriteJSON(t *testing.T) {
+	s := Snapshot{Entries: []Entry{entry("src/main.go"), {Path: "src", Name: "src", Short: "src", IsDir: true}}}
+	var buf bytes.Buffer
+	if err := s.WriteJSON(&buf, "/repo"); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var out struct {
+		Root    string        `json:"root"`
+		Count   int           `json:"count"`
+		Entries []ExportEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if out.Root != "/repo" || out.Count != 2 || len(out.Entries) != 2 {
+		t.Fatalf("unexpected export: %+v", out)
+	}
+	if out.Entries[1].Path != "src" || !out.Entries[1].IsDir {
+		t.Fatalf("expected directory entry, got %+v", out.Entries[1])
+	}
+}
+
+func TestSnapshotWriteJSON_ReturnsTheFirstWriteError(t *testing.T) {
+	var s Snapshot
+	for i := range 5000 {
+		s.Entries = append(s.Entries, entry(fmt.Sprintf("src/pkg%d/file%d.go", i, i)))
+	}
+	w := &failingWriter{n: 1000}
+	if err := s.WriteJSON(w, "/repo"); !errors.Is(err, errWriteFailed) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	if w.late != 0 {
+		t.Fatalf("kept writing %d times after the first error", w.late)
+	}
+}
+
+func TestSnapshotWriteNDJSON(t *testing.T) {
+	s := Snapshot{Entries: []Entry{entry("a.go"), entry("b/c.go")}}
+	var buf bytes.Buffer
+	if err := s.WriteNDJSON(&buf); err != nil {
+		t.Fatalf("WriteNDJSON: %v", err)
+	}
+	sc := bufio.NewScanner(&buf)
+	var paths []string
+	for sc.Scan() {
+		var e ExportEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", sc.Text(), err)
+		}
+		paths = append(paths, e.Path)
+	}
+	if len(paths) != 2 || paths[0] != "a.go" || paths[1] != "b/c.go" {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+}
+
+func TestSnapshotLookup(t *testing.T) {
+	s := NewSnapshot([]Entry{entry("src/main.go"), entry("cmd/main.go")})
+	if e, ok := s.Lookup("src/main.go"); !ok || e.Path != entry("src/main.go").Path {
+		t.Fatalf("expected src/main.go, got %+v %v", e, ok)
+	}
+	if _, ok := s.Lookup("main.go"); ok {
+		t.Fatal("expected bare name not to match a nested path")
+	}
+	if n := len(s.ByName("main.go")); n != 2 {
+		t.Fatalf("expected 2 entries named main.go, got %d", n)
+	}
+}
@@ -1,7 +1,9 @@
 package index
 
 import (
+	"errors"
 	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -14,14 +16,16 @@ import (
 func (ix *Indexer) Start() {
 	ix.scanOnce()
 	ix.lastRefreshNano.Store(time.Now().UnixNano())
-	// Try to start fsnotify regardless of size; if it fails, we won't poll automatically
-	if ix.tryStartFsnotify() {
-		ix.mode = "fsnotify"
+	// Prefer one recursive watch for the whole tree where the platform has one; otherwise
+	// try fsnotify regardless of size; if it fails, we won't poll automatically
+	if ix.tryStartRecursive() || ix.tryStartFsnotify() {
+		ix.setMode("fsnotify")
 	} else {
-		ix.mode = "no-fsnotify"
+		ix.setMode("no-fsnotify")
 		// Without fsnotify, we treat as always changed so on-demand refresh will run (rate-limited)
 		ix.changed.Store(true)
 	}
+	ix.startRootWatch()
 }
 
 func (ix *Indexer) tryStartFsnotify() bool {
@@ -31,7 +35,9 @@ func (ix *Indexer) tryStartFsnotify() bool {
 		fmt.Printf("index: fsnotify unavailable (%v); will use on-demand rescans without watchers\n", err)
 		return false
 	}
+	ix.watchMu.Lock()
 	ix.watcher = w
+	ix.watchMu.Unlock()
 	// subscribe to directories (capped)
 	dirs := ix.currentDirs()
 	// Always watch root
@@ -73,52 +79,132 @@ func (ix *Indexer) tryStartFsnotify() bool {
 			case <-ix.closed:
 				w.Close()
 				return
-			case ev := <-w.Events:
-				// mark that changes were detected; rescan will be triggered on-demand by searchIndex
-				ix.changed.Store(true)
-				// If already overflowed, skip buffering
-				if ix.overflowed.Load() {
-					continue
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
 				}
-				// Normalize to rel path under root
-				rel := ev.Name
-				if rel != "" {
-					if abs, err := filepath.Abs(ev.Name); err == nil {
-						if r, err2 := filepath.Rel(rootAbs, abs); err2 == nil {
-							rel = r
-						}
-					}
+				ix.recordEvent(rootAbs, ev, false)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
 				}
-				if rel != "" {
-					if ix.changeCount.Add(1) > int64(ix.maxPending) {
-						ix.evMu.Lock()
-						ix.pending = make(map[string]fsnotify.Op)
-						ix.evMu.Unlock()
-						ix.overflowed.Store(true)
-						continue
-					}
-					ix.evMu.Lock()
-					ix.pending[normalizeSlash(rel)] |= ev.Op
-					ix.evMu.Unlock()
+				ix.recordError(err)
+			}
+		}
+	}()
+	return true
+}
+
+// tryStartRecursive watches the whole tree with a single recursive watch (see watch.go)
+func (ix *Indexer) tryStartRecursive() bool {
+	rootAbs, _ := filepath.Abs(ix.Root)
+	w, err := newRecursiveWatcher(rootAbs)
+	if err != nil {
+		if err != errRecursiveUnsupported {
+			fmt.Printf("index: recursive watch unavailable (%v); falling back to fsnotify\n", err)
+		}
+		return false
+	}
+	ix.watchMu.Lock()
+	ix.recursive = w
+	ix.watchMu.Unlock()
+	fmt.Printf("index: using fsnotify (1 recursive watch via %s)\n", w.Name())
+	ix.wg.Add(1)
+	go func() {
+		defer ix.wg.Done()
+		for {
+			select {
+			case <-ix.closed:
+				w.Close()
+				return
+			case ev, ok := <-w.Events():
+				if !ok {
+					return
 				}
-			case <-w.Errors:
-				// ignore errors; rely on periodic rebuild
+				ix.recordEvent(rootAbs, ev, true)
+			case err, ok := <-w.Errors():
+				if !ok {
+					return
+				}
+				ix.recordError(err)
 			}
 		}
 	}()
 	return true
 }
 
+// recordEvent buffers a change for the next incremental refresh. A recursive watch
+// reports changes in ignored directories too; only those in indexed directories are
+// kept, which is what per-directory watches would have reported.
+func (ix *Indexer) recordEvent(rootAbs string, ev fsnotify.Event, recursive bool) {
+	// Normalize to rel path under root
+	rel := ev.Name
+	if rel != "" {
+		if abs, err := filepath.Abs(ev.Name); err == nil {
+			if r, err2 := filepath.Rel(rootAbs, abs); err2 == nil {
+				rel = r
+			}
+		}
+	}
+	if recursive && !ix.inIndexedDir(rel) {
+		return
+	}
+	if rel != "" {
+		ix.trackRename(rootAbs, rel, ev.Op)
+	}
+	// mark that changes were detected; rescan will be triggered on-demand by searchIndex
+	ix.changed.Store(true)
+	// If already overflowed, skip buffering
+	if ix.overflowed.Load() || rel == "" {
+		return
+	}
+	if ix.changeCount.Add(1) > int64(ix.maxPending) {
+		ix.evMu.Lock()
+		ix.pending = make(map[string]fsnotify.Op)
+		ix.evMu.Unlock()
+		ix.overflowed.Store(true)
+		return
+	}
+	ix.evMu.Lock()
+	ix.pending[normalizeSlash(rel)] |= ev.Op
+	ix.evMu.Unlock()
+}
+
+// recordError handles a watcher error. Dropped events leave the pending changes
+// incomplete, so the next refresh rescans; other errors are ignored and rely on rescans.
+func (ix *Indexer) recordError(err error) {
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		ix.changed.Store(true)
+		ix.overflowed.Store(true)
+	}
+}
+
+// inIndexedDir reports whether rel, relative to the root, lies directly in the root or in
+// an indexed directory and outside .git
+func (ix *Indexer) inIndexedDir(rel string) bool {
+	rel = normalizeSlash(rel)
+	if rel == "" || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || rel == ".git" || strings.HasPrefix(rel, ".git/") {
+		return false
+	}
+	dir := path.Dir(rel)
+	if dir == "." {
+		return true
+	}
+	e, ok := ix.Snapshot().Lookup(dir)
+	return ok && e.IsDir
+}
+
 // RequestRefresh triggers a background rescan if needed, rate-limited by debounce.
 // In fsnotify mode, it only runs if changes were detected. Without fsnotify,
 // it behaves as if changes are always pending.
 func (ix *Indexer) RequestRefresh() {
-	// Avoid duplicate refreshes
-	if ix.refreshRunning.Load() {
+	// Avoid duplicate refreshes; static indexes and missing roots have nothing to rescan
+	mode := ix.getMode()
+	if mode == modeStatic || ix.rootLost.Load() || ix.refreshRunning.Load() {
 		return
 	}
 	// If fsnotify is active, require a change signal
-	if ix.mode == "fsnotify" && !ix.changed.Load() {
+	if mode == "fsnotify" && !ix.changed.Load() {
 		return
 	}
 	// Rate-limit by debounce interval
@@ -137,7 +223,7 @@ func (ix *Indexer) RequestRefresh() {
 
 		// In fsnotify mode, try incremental apply if not overflowed and there are pending events
 		didIncremental := false
-		if ix.mode == "fsnotify" && !ix.overflowed.Load() {
+		if mode == "fsnotify" && !ix.overflowed.Load() {
 			// snapshot pending
 			ix.evMu.Lock()
 			pend := make(map[string]fsnotify.Op, len(ix.pending))
@@ -194,7 +280,9 @@ func isUnderSubtree(p, d string) bool {
 
 func (ix *Indexer) rebuildWatchers() {
 	// Incrementally add/remove watches to match currentDirs(), honoring .gitignore via scanOnce() output.
+	ix.watchMu.Lock()
 	w := ix.watcher
+	ix.watchMu.Unlock()
 	if w == nil {
 		return
 	}
@@ -262,8 +350,14 @@ func (ix *Indexer) currentDirs() []string {
 // Close stops the background scanner.
 func (ix *Indexer) Close() {
 	close(ix.closed)
-	if ix.watcher != nil {
-		_ = ix.watcher.Close()
+	ix.watchMu.Lock()
+	w, rw := ix.watcher, ix.recursive
+	ix.watchMu.Unlock()
+	if w != nil {
+		_ = w.Close()
+	}
+	if rw != nil {
+		_ = rw.Close()
 	}
 	ix.wg.Wait()
 }
@@ -0,0 +1,29 @@
+package index
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzIgnoredByRules(f *testing.F) {
+	f.Add("node_modules/\n*.log\n!keep.log", ".", "src/node_modules/x.js")
+	f.Add("/build\n**/tmp/**\n\\#literal", "sub", "sub/build/out.o")
+	f.Add("[a-\n***\n!\n/", ".", "a/b")
+	f.Add("*.go", "pkg", "pkg")
+	f.Fuzz(func(t *testing.T, patterns, base, rel string) {
+		var lines []string
+		for _, l := range strings.Split(patterns, "\n") {
+			if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "#") {
+				lines = append(lines, l)
+			}
+		}
+		rules := []rule{{baseRel: base, ign: compileIgnoreLines(lines)}}
+		first := ignoredByRules(rules, rel)
+		if again := ignoredByRules(rules, rel); again != first {
+			t.Fatalf("non-deterministic result for %q", rel)
+		}
+		if base != "." && !strings.HasPrefix(rel, base+"/") && first {
+			t.Fatalf("rules at %q ignored %q outside their directory", base, rel)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitTimeout bounds git invocations so a slow or locked repository never stalls a request
+const gitTimeout = 5 * time.Second
+
+// GitChangedFiles returns the files under root that git reports as modified, added,
+// renamed or untracked, keyed by path relative to root with OS-specific separators.
+// It returns an empty set when root is not inside a git work tree or git is missing.
+func GitChangedFiles(root string) map[string]bool {
+	return GitChangedFilesContext(context.Background(), root)
+}
+
+// GitChangedFilesContext is GitChangedFiles that also stops git when ctx ends
+func GitChangedFilesContext(ctx context.Context, root string) map[string]bool {
+	changed := map[string]bool{}
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	top, err := gitOutput(ctx, root, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return changed
+	}
+	topDir := strings.TrimSpace(string(top))
+	out, err := gitOutput(ctx, root, "status", "--porcelain", "-z", "--untracked-files=all", "--", ".")
+	if err != nil {
+		return changed
+	}
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return changed
+	}
+	if resolved, err := filepath.EvalSymlinks(rootAbs); err == nil {
+		rootAbs = resolved
+	}
+
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if len(f) < 4 {
+			continue
+		}
+		status, path := string(f[:2]), string(f[3:])
+		if status[0] == 'R' || status[0] == 'C' {
+			i++ // the source path of a rename/copy follows as a separate field
+		}
+		if status[0] == 'D' || status[1] == 'D' {
+			continue
+		}
+		rel, err := filepath.Rel(rootAbs, filepath.Join(topDir, filepath.FromSlash(path)))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		changed[rel] = true
+	}
+	return changed
+}
+
+func gitOutput(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
@@ -0,0 +1,91 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reasons a path is not indexed, reported by VerifyIgnores
+const (
+	ExcludedOutsideRoot = "outsideRoot" // the path is not under the root
+	ExcludedMissing     = "missing"     // nothing exists at the path
+	ExcludedVCS         = "vcs"         // the path is, or is inside, a .git directory
+	ExcludedGitignore   = "gitignore"   // a .gitignore pattern matches the path or a parent
+	ExcludedSymlink     = "symlink"     // a parent is a symbolic link, which scans do not follow
+)
+
+// IgnoreReport tells whether the index includes a path and, if not, what keeps it out
+type IgnoreReport struct {
+	Path       string `json:"path"` // relative to the root
+	Exists     bool   `json:"exists"`
+	IsDir      bool   `json:"isDir,omitempty"`
+	Indexed    bool   `json:"indexed"`
+	Reason     string `json:"reason,omitempty"`
+	Excluded   string `json:"excluded,omitempty"`   // the path or the parent directory that is left out
+	IgnoreFile string `json:"ignoreFile,omitempty"` // the .gitignore whose pattern matched, relative to the root
+	Pattern    string `json:"pattern,omitempty"`
+}
+
+// VerifyIgnores explains whether a scan of root includes path, relative to root or
+// absolute. It applies the rules of walkTree to each directory from the root down to
+// path, so the answer is the one the server's index gives.
+func VerifyIgnores(root, path string) (IgnoreReport, error) {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return IgnoreReport{}, err
+	}
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(rootAbs, path)
+	}
+	abs = filepath.Clean(abs)
+	rep := IgnoreReport{Path: path}
+	if info, err := os.Stat(abs); err == nil {
+		rep.Exists, rep.IsDir = true, info.IsDir()
+	}
+	rel, err := filepath.Rel(rootAbs, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rep.Reason = ExcludedOutsideRoot
+		return rep, nil
+	}
+	rep.Path = rel
+
+	var rules []rule
+	if lines := readIgnoreLines(filepath.Join(rootAbs, ".gitignore")); len(lines) > 0 {
+		rules = append(rules, rule{baseAbs: rootAbs, baseRel: ".", ign: compileIgnoreLines(lines)})
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, name := range parts {
+		prefix := filepath.Join(parts[:i+1]...)
+		if name == ".git" {
+			rep.Reason, rep.Excluded = ExcludedVCS, prefix
+			return rep, nil
+		}
+		if r, how, ignored := matchIgnoreRules(rules, prefix); ignored {
+			rep.Reason, rep.Excluded = ExcludedGitignore, prefix
+			rep.IgnoreFile = filepath.Join(r.baseRel, ".gitignore")
+			if how != nil {
+				rep.Pattern = how.Line
+			}
+			return rep, nil
+		}
+		if i == len(parts)-1 {
+			break
+		}
+		dirAbs := filepath.Join(rootAbs, prefix)
+		if info, err := os.Lstat(dirAbs); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			rep.Reason, rep.Excluded = ExcludedSymlink, prefix
+			return rep, nil
+		}
+		if lines := readIgnoreLines(filepath.Join(dirAbs, ".gitignore")); len(lines) > 0 {
+			rules = append(append([]rule(nil), rules...), rule{baseAbs: dirAbs, baseRel: prefix, ign: compileIgnoreLines(lines)})
+		}
+	}
+	if !rep.Exists {
+		rep.Reason = ExcludedMissing
+		return rep, nil
+	}
+	rep.Indexed = true
+	return rep, nil
+}
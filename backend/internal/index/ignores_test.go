@@ -0,0 +1,96 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVerifyIgnores(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":         "build/\n*.log\n",
+		"main.go":            "package main",
+		"build/out.go":       "x",
+		"pkg/debug.log":      "x",
+		"pkg/.gitignore":     "gen/\n",
+		"pkg/gen/types.go":   "x",
+		".git/config":        "x",
+		"other/gen/types.go": "x",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path, reason, excluded, ignoreFile, pattern string
+	}{
+		{"main.go", "", "", "", ""},
+		{"other/gen/types.go", "", "", "", ""},
+		{"build/out.go", ExcludedGitignore, "build/out.go", ".gitignore", "build/"},
+		{"pkg/debug.log", ExcludedGitignore, "pkg/debug.log", ".gitignore", "*.log"},
+		{"pkg/gen/types.go", ExcludedGitignore, "pkg/gen/types.go", "pkg/.gitignore", "gen/"},
+		{"pkg/debug.log/x", ExcludedGitignore, "pkg/debug.log", ".gitignore", "*.log"},
+		{".git/config", ExcludedVCS, ".git", "", ""},
+		{"nope.go", ExcludedMissing, "", "", ""},
+		{filepath.Join(filepath.Dir(root), "elsewhere.go"), ExcludedOutsideRoot, "", "", ""},
+	}
+	for _, tt := range tests {
+		rep, err := VerifyIgnores(root, filepath.FromSlash(tt.path))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if rep.Indexed != (tt.reason == "") || rep.Reason != tt.reason || rep.Excluded != filepath.FromSlash(tt.excluded) ||
+			rep.IgnoreFile != filepath.FromSlash(tt.ignoreFile) || rep.Pattern != tt.pattern {
+			t.Errorf("%s: got %+v", tt.path, rep)
+		}
+		// The answer agrees with a scan
+		if _, ok := Scan(root).Lookup(tt.path); ok != rep.Indexed && !filepath.IsAbs(tt.path) {
+			t.Errorf("%s: indexed=%v but scan found it: %v", tt.path, rep.Indexed, ok)
+		}
+	}
+}
+
+func TestVerifyIgnores_SymlinkedDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	root := t.TempDir()
+	target := t.TempDir()
+	if err := os.WriteFile(filepath.Join(target, "a.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(root, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := VerifyIgnores(root, filepath.Join("linked", "a.go"))
+	if err != nil || rep.Indexed || rep.Reason != ExcludedSymlink || rep.Excluded != "linked" || !rep.Exists {
+		t.Fatalf("got %+v, %v", rep, err)
+	}
+}
+
+func TestSnapshotStats(t *testing.T) {
+	snap := NewSnapshot([]Entry{
+		{Path: ".gitignore", Name: ".gitignore"},
+		{Path: "src", Name: "src", IsDir: true},
+		{Path: filepath.Join("src", "a.go"), Name: "a.go"},
+		{Path: filepath.Join("src", "b.go"), Name: "b.go"},
+		{Path: filepath.Join("src", "sub"), Name: "sub", IsDir: true},
+		{Path: filepath.Join("src", "sub", "c.go"), Name: "c.go"},
+		{Path: "docs", Name: "docs", IsDir: true},
+		{Path: filepath.Join("docs", "x.md"), Name: "x.md"},
+	})
+	st := snap.Stats()
+	if st.Files != 5 || st.Dirs != 3 || st.IgnoreFiles != 1 || st.MaxDepth != 3 || st.Watches != 4 || st.WatchCap != defaultMaxWatchDirs {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if len(st.TopDirs) != 2 || st.TopDirs[0] != (DirCount{Dir: "src", Files: 3}) || st.TopDirs[1] != (DirCount{Dir: "docs", Files: 1}) {
+		t.Fatalf("unexpected top directories %+v", st.TopDirs)
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-// applyPendingIncremental mutates ix.entries based on a set of changed relative paths.
+// applyPendingIncremental replaces ix.entries based on a set of changed relative paths.
 // It handles file creates/updates/removes and directory subtree changes. If the scope
 // becomes too complex, call scanOnce() instead.
 func (ix *Indexer) applyPendingIncremental(pend map[string]fsnotify.Op) {
@@ -68,11 +68,11 @@ func (ix *Indexer) applyPendingIncremental(pend map[string]fsnotify.Op) {
 		}
 	}
 
-	// Mutate entries under lock
+	// Serialize with other writers; readers use the published snapshot lock-free
 	ix.mu.Lock()
-	// Start with current entries
-	cur := ix.entries
-	out := cur[:0]
+	// Start with current entries; build a fresh slice since snapshots may still share cur
+	cur := ix.Snapshot().Entries
+	out := make([]Entry, 0, len(cur))
 
 	// Build a quick ignore rule cache for subtrees we touch
 	ruleCache := make(map[string][]rule) // key: dir rel path ("" for root)
@@ -223,6 +223,7 @@ func (ix *Indexer) applyPendingIncremental(pend map[string]fsnotify.Op) {
 	// Sort and recompute short names
 	sort.Slice(out, func(i, j int) bool { return strings.Compare(out[i].Path, out[j].Path) < 0 })
 	computeShortNames(out)
-	ix.entries = out
+	compactEntries(out)
+	ix.publish(out)
 	ix.mu.Unlock()
 }
@@ -1,6 +1,7 @@
 package index
 
 import (
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -9,12 +10,20 @@ import (
 	ignore "github.com/sabhiram/go-gitignore"
 )
 
+// defaultMaxWatchDirs caps the directories watched one by one; beyond it changes in the
+// rest of the tree are only seen by on-demand rescans
+const defaultMaxWatchDirs = 10000
+
 // Entry represents a single file or directory in the index.
 type Entry struct {
 	Path  string // relative to root, with OS-specific separators
 	Name  string // base name
 	Short string // short display name, possibly parent prefixes to disambiguate
 	IsDir bool
+
+	// case-folded Name, Short and slash-normalized Path used by the search fast path;
+	// empty when not precomputed or when the field contains non-ASCII characters
+	lname, lshort, lpath string
 }
 
 // rule describes a .gitignore rule set anchored at a directory
@@ -26,30 +35,43 @@ type rule struct {
 	ign     *ignore.GitIgnore
 }
 
-// Snapshot is an immutable copy of the index state used for searching.
+// Snapshot is an immutable view of the index state used for searching.
+// Entries is shared with the indexer and must not be modified.
 type Snapshot struct {
 	Entries []Entry
+	// Gen counts the snapshots the Indexer published, this one included; a Delta with a
+	// higher Gen happened after it
+	Gen uint64
 	// map base name -> indexes within Entries
 	byName map[string][]int
+	// candidates of the previous ranked query; a new one is attached on every publish,
+	// which invalidates it whenever the index is refreshed
+	cache *searchCache
 }
 
 // Indexer maintains an index of files/directories under Root.
 type Indexer struct {
 	Root string
 
-	mu      sync.RWMutex
-	entries []Entry
-	closed  chan struct{}
-	wg      sync.WaitGroup
+	mu     sync.RWMutex
+	closed chan struct{}
+	wg     sync.WaitGroup
+
+	// current is the latest published Snapshot. Scans build a new entries slice and
+	// swap it in atomically, so searches read it lock-free and never copy.
+	current atomic.Pointer[Snapshot]
 
 	// last reported sizes for stdout logging
 	prevFiles   int
 	prevEntries int
 
-	// scanning strategy
+	// scanning strategy; watchMu guards mode and the watchers, which are replaced when
+	// the root is deleted and recreated (see rootwatch.go)
 	interval         time.Duration
+	watchMu          sync.Mutex
 	mode             string // "poll" or "fsnotify"
 	watcher          *fsnotify.Watcher
+	recursive        recursiveWatcher // one watch for the whole tree, replacing watcher (see watch.go)
 	watched          map[string]struct{}
 	debounce         time.Duration
 	massiveThreshold int // choose fsnotify if entries exceed this
@@ -66,6 +88,24 @@ type Indexer struct {
 	changeCount atomic.Int64
 	overflowed  atomic.Bool
 	maxPending  int // threshold; fallback to full scan if exceeded
+
+	// root health (see rootwatch.go)
+	rootCheck time.Duration
+	rootLost  atomic.Bool
+	statusMu  sync.Mutex
+	status    Status
+	onStatus  func(Status)
+
+	// moves pieced together from rename and create events (see renames.go)
+	renameMu    sync.Mutex
+	renamedFrom string
+	renamedAt   time.Time
+	onRename    func(Rename)
+
+	// changes between published snapshots (see delta.go); changeMu also orders publishes
+	changeMu sync.Mutex
+	gen      uint64
+	onChange func(Delta)
 }
 
 // New creates an Indexer for a given root directory.
@@ -76,22 +116,66 @@ func New(root string) *Indexer {
 		interval:         5 * time.Second,
 		debounce:         5 * time.Second,
 		massiveThreshold: 0,
-		maxWatchDirs:     10000,
+		maxWatchDirs:     defaultMaxWatchDirs,
 		watched:          make(map[string]struct{}),
 		pending:          make(map[string]fsnotify.Op),
 		maxPending:       1000,
+		rootCheck:        defaultRootCheck,
 	}
 }
 
-// Snapshot returns an immutable copy of current entries with auxiliary indices.
+// Snapshot returns an immutable view of current entries with auxiliary indices.
+// It does not lock or copy; the returned entries must be treated as read-only.
 func (ix *Indexer) Snapshot() Snapshot {
-	ix.mu.RLock()
-	defer ix.mu.RUnlock()
-	entries := make([]Entry, len(ix.entries))
-	copy(entries, ix.entries)
-	byName := make(map[string][]int)
-	for i, e := range entries {
-		byName[e.Name] = append(byName[e.Name], i)
+	if s := ix.current.Load(); s != nil {
+		return *s
+	}
+	return Snapshot{}
+}
+
+// publish atomically replaces the current snapshot. entries must not be mutated afterwards.
+func (ix *Indexer) publish(entries []Entry) {
+	s := NewSnapshot(entries)
+	ix.changeMu.Lock()
+	defer ix.changeMu.Unlock()
+	ix.gen++
+	s.Gen = ix.gen
+	old := ix.current.Swap(&s)
+	if ix.onChange == nil {
+		return
+	}
+	var prev []Entry
+	if old != nil {
+		prev = old.Entries
+	}
+	if d := Diff(prev, entries); !d.Empty() {
+		d.Gen = s.Gen
+		ix.onChange(d)
+	}
+}
+
+// NewSnapshot builds a searchable snapshot over entries.
+func NewSnapshot(entries []Entry) Snapshot {
+	return Snapshot{Entries: entries, byName: buildByName(entries), cache: &searchCache{}}
+}
+
+// Lookup returns the entry whose relative path equals rel (either separator style).
+func (s Snapshot) Lookup(rel string) (Entry, bool) {
+	want := normalizeSlash(filepath.Clean(filepath.FromSlash(rel)))
+	for _, i := range s.byName[filepath.Base(filepath.FromSlash(rel))] {
+		if normalizeSlash(s.Entries[i].Path) == want {
+			return s.Entries[i], true
+		}
+	}
+	return Entry{}, false
+}
+
+// ByName returns the entries with the given base name.
+func (s Snapshot) ByName(name string) []Entry {
+	idx := s.byName[name]
+	out := make([]Entry, 0, len(idx))
+	for _, i := range idx {
+		out = append(out, s.Entries[i])
 	}
-	return Snapshot{Entries: entries, byName: byName}
+	return out
 }
@@ -0,0 +1,76 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// NormalizedPath is a user-provided path resolved against the index root
+type NormalizedPath struct {
+	Input    string `json:"input"`
+	Path     string `json:"path" doc:"Relative to the index root with OS separators, like search results; . for the root itself, empty outside it"`
+	Absolute string `json:"absolute,omitempty"`
+	Inside   bool   `json:"inside" doc:"The path lies within the index root"`
+	Exists   bool   `json:"exists"`
+	IsDir    bool   `json:"isDir"`
+	Indexed  bool   `json:"indexed" doc:"The path is an entry of the index, i.e. not ignored"`
+}
+
+// NormalizePath resolves p the way a user means it: absolute, relative to the root,
+// starting with ~ for the home directory, quoted, or with the other platform's
+// separators. Existence is checked on disk, so ignored files are found as well.
+func (s Snapshot) NormalizePath(root, p string) NormalizedPath {
+	out := NormalizedPath{Input: p}
+	p = strings.TrimSpace(p)
+	if len(p) >= 2 && (p[0] == '"' || p[0] == '\'') && p[len(p)-1] == p[0] {
+		p = strings.TrimSpace(p[1 : len(p)-1])
+	}
+	if p == "" {
+		return out
+	}
+	if runtime.GOOS != "windows" {
+		// a backslash is legal in file names here, but pasted Windows paths are far
+		// more likely than such names
+		p = strings.ReplaceAll(p, `\`, "/")
+	}
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return out
+		}
+		p = filepath.Join(home, p[1:])
+	}
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return out
+	}
+	abs := filepath.FromSlash(p)
+	if !filepath.IsAbs(abs) {
+		if isDriveRooted(abs) {
+			// C:\repo\x.go on a platform without drive letters cannot name a local file
+			return out
+		}
+		abs = filepath.Join(rootAbs, abs)
+	}
+	abs = filepath.Clean(abs)
+	out.Absolute = abs
+
+	if info, err := os.Stat(abs); err == nil {
+		out.Exists, out.IsDir = true, info.IsDir()
+	}
+	rel, err := filepath.Rel(rootAbs, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return out
+	}
+	out.Inside = true
+	out.Path = rel
+	_, out.Indexed = s.Lookup(rel)
+	return out
+}
+
+// isDriveRooted reports whether p starts with a Windows drive letter, e.g. C:/repo
+func isDriveRooted(p string) bool {
+	return len(p) >= 2 && p[1] == ':' && ((p[0] >= 'a' && p[0] <= 'z') || (p[0] >= 'A' && p[0] <= 'Z'))
+}
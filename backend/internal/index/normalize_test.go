@@ -0,0 +1,62 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNormalizePath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	root := filepath.Join(home, "repo")
+	if err := os.MkdirAll(filepath.Join(root, "src", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"src/app.ts", "debug.log"} {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap := NewSnapshot([]Entry{
+		{Path: "src", Name: "src", IsDir: true},
+		{Path: filepath.Join("src", "app.ts"), Name: "app.ts"},
+	})
+	app := filepath.Join("src", "app.ts")
+
+	cases := []struct {
+		in   string
+		want NormalizedPath
+	}{
+		{"src/app.ts", NormalizedPath{Path: app, Inside: true, Exists: true, Indexed: true}},
+		{`src\app.ts`, NormalizedPath{Path: app, Inside: true, Exists: true, Indexed: true}},
+		{"./src/../src/app.ts", NormalizedPath{Path: app, Inside: true, Exists: true, Indexed: true}},
+		{filepath.Join(root, "src", "app.ts"), NormalizedPath{Path: app, Inside: true, Exists: true, Indexed: true}},
+		{"~/repo/src/app.ts", NormalizedPath{Path: app, Inside: true, Exists: true, Indexed: true}},
+		{` "src/sub" `, NormalizedPath{Path: filepath.Join("src", "sub"), Inside: true, Exists: true, IsDir: true}},
+		{"debug.log", NormalizedPath{Path: "debug.log", Inside: true, Exists: true}},
+		{"src/missing.ts", NormalizedPath{Path: filepath.Join("src", "missing.ts"), Inside: true}},
+		{".", NormalizedPath{Path: ".", Inside: true, Exists: true, IsDir: true}},
+		{"../outside.txt", NormalizedPath{}},
+		{"~", NormalizedPath{Exists: true, IsDir: true}},
+		{"", NormalizedPath{}},
+	}
+	for _, c := range cases {
+		got := snap.NormalizePath(root, c.in)
+		got.Input, got.Absolute = "", ""
+		if got != c.want {
+			t.Errorf("NormalizePath(%q) = %+v, want %+v", c.in, got, c.want)
+		}
+	}
+
+	if got := snap.NormalizePath(root, "~/repo/src"); got.Absolute != filepath.Join(root, "src") {
+		t.Errorf("absolute of ~/repo/src = %q", got.Absolute)
+	}
+	if runtime.GOOS != "windows" {
+		if got := snap.NormalizePath(root, `C:\repo\main.go`); got.Inside || got.Absolute != "" {
+			t.Errorf("drive path = %+v", got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package index
+
+// RankingProfile holds the weights used by the ranked search. Different callers
+// (e.g. the file picker vs. the chat #-mention) can select a named profile per
+// request instead of forking the scorer.
+type RankingProfile struct {
+	Name           string
+	NameWeight     int // added when the pattern matches the base name
+	ShortWeight    int // added when the pattern matches the disambiguated short name
+	PathWeight     int // added when the pattern matches the relative path
+	ExactNameBonus int // extra bonus when the base name equals the pattern
+	OpenedBonus    int // bonus for entries currently opened in the IDE
+	RecencyBonus   int // bonus for the first entry of the opened list, decaying with its position
+}
+
+// DefaultProfile reproduces the historical weights; opened files do not affect
+// the ranking of the main results.
+var DefaultProfile = RankingProfile{
+	Name:           "default",
+	NameWeight:     4000,
+	ShortWeight:    2500,
+	PathWeight:     1000,
+	ExactNameBonus: 800,
+}
+
+var profiles = map[string]RankingProfile{
+	"default": DefaultProfile,
+	// picker favors base-name hits and surfaces files already open in the IDE
+	"picker": {
+		Name:           "picker",
+		NameWeight:     4500,
+		ShortWeight:    2500,
+		PathWeight:     800,
+		ExactNameBonus: 1200,
+		OpenedBonus:    600,
+		RecencyBonus:   300,
+	},
+	// mention weighs full paths higher, since users often type directory fragments
+	"mention": {
+		Name:           "mention",
+		NameWeight:     3500,
+		ShortWeight:    3000,
+		PathWeight:     2000,
+		ExactNameBonus: 800,
+		OpenedBonus:    300,
+		RecencyBonus:   150,
+	},
+}
+
+// ProfileByName returns the named ranking profile, falling back to DefaultProfile
+// for empty or unknown names.
+func ProfileByName(name string) RankingProfile {
+	if p, ok := profiles[name]; ok {
+		return p
+	}
+	return DefaultProfile
+}
+
+// openedBonus returns the profile bonus for an entry at position pos in the opened
+// list (0 = most recent), or 0 if the entry is not opened (pos < 0).
+func (rp RankingProfile) openedBonus(pos, total int) int {
+	if pos < 0 {
+		return 0
+	}
+	bonus := rp.OpenedBonus
+	if rp.RecencyBonus > 0 && total > 0 {
+		bonus += rp.RecencyBonus * (total - pos) / total
+	}
+	return bonus
+}
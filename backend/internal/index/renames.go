@@ -0,0 +1,69 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// renamePairWindow is how soon after a path disappears with a rename a new one must be
+	// created for the two events to count as one move; watchers report the two halves of
+	// a move back to back
+	renamePairWindow = time.Second
+	// renameSettle is how long a paired move is left to settle before it is reported: an
+	// editor saving by renaming the file to a backup recreates it right away, which is no move
+	renameSettle = 500 * time.Millisecond
+)
+
+// Rename is a file or directory moved within the root, with paths relative to it
+type Rename struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	IsDir bool   `json:"isDir,omitempty"`
+}
+
+// SetRenameHandler calls fn with every move the watches observed; fn must not block
+func (ix *Indexer) SetRenameHandler(fn func(Rename)) {
+	ix.renameMu.Lock()
+	ix.onRename = fn
+	ix.renameMu.Unlock()
+}
+
+// trackRename pairs the rename event of a path with the create event of its new name
+func (ix *Indexer) trackRename(rootAbs, rel string, op fsnotify.Op) {
+	ix.renameMu.Lock()
+	defer ix.renameMu.Unlock()
+	if ix.onRename == nil {
+		return
+	}
+	now := time.Now()
+	switch {
+	case op.Has(fsnotify.Rename):
+		ix.renamedFrom, ix.renamedAt = rel, now
+	case op.Has(fsnotify.Create) && ix.renamedFrom != "" && ix.renamedFrom != rel && now.Sub(ix.renamedAt) <= renamePairWindow:
+		from := ix.renamedFrom
+		ix.renamedFrom = ""
+		time.AfterFunc(renameSettle, func() { ix.reportRename(rootAbs, from, rel) })
+	}
+}
+
+// reportRename reports a paired move once it settled: the old path must still be gone and
+// the new one present
+func (ix *Indexer) reportRename(rootAbs, from, to string) {
+	if _, err := os.Lstat(filepath.Join(rootAbs, from)); !os.IsNotExist(err) {
+		return
+	}
+	info, err := os.Lstat(filepath.Join(rootAbs, to))
+	if err != nil {
+		return
+	}
+	ix.renameMu.Lock()
+	fn := ix.onRename
+	ix.renameMu.Unlock()
+	if fn != nil {
+		fn(Rename{From: from, To: to, IsDir: info.IsDir()})
+	}
+}
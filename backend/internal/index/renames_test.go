@@ -0,0 +1,60 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndexer_ReportsRenames(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "old.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ix := New(root)
+	ix.debounce = 0
+	renames := make(chan Rename, 4)
+	ix.SetRenameHandler(func(rn Rename) { renames <- rn })
+	ix.Start()
+	defer ix.Close()
+
+	if err := os.Rename(filepath.Join(root, "old.go"), filepath.Join(root, "new.go")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case rn := <-renames:
+		if rn.From != "old.go" || rn.To != "new.go" || rn.IsDir {
+			t.Fatalf("unexpected rename %+v", rn)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rename not reported")
+	}
+}
+
+func TestIndexer_IgnoresSaveByRename(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "main.go")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ix := New(root)
+	ix.debounce = 0
+	renames := make(chan Rename, 4)
+	ix.SetRenameHandler(func(rn Rename) { renames <- rn })
+	ix.Start()
+	defer ix.Close()
+
+	// An editor keeping a backup moves the file aside and writes it again
+	if err := os.Rename(file, file+"~"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case rn := <-renames:
+		t.Fatalf("save reported as a rename: %+v", rn)
+	case <-time.After(renameSettle + 300*time.Millisecond):
+	}
+}
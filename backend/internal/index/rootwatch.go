@@ -0,0 +1,181 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// States of the index root reported in Status
+const (
+	StatusOK        = "ok"        // root present and watched as configured
+	StatusMissing   = "missing"   // root deleted; entries are those of the last scan
+	StatusRecovered = "recovered" // root returned or was replaced and has been rescanned
+)
+
+const (
+	// defaultRootCheck is how often the root is checked for deletion or replacement,
+	// and the first retry delay once it is gone
+	defaultRootCheck = 2 * time.Second
+	// maxRootBackoff caps the retry delay while the root is missing
+	maxRootBackoff = 30 * time.Second
+)
+
+// Status describes the health of the index root. A deleted root (worktree swap, container
+// restart) silently kills the watches on it, so the root is checked on its own and the
+// watches are set up again with a full rescan when it comes back.
+type Status struct {
+	State   string    `json:"state"`
+	Root    string    `json:"root"`
+	Message string    `json:"message,omitempty"`
+	Attempt int       `json:"attempt,omitempty"` // failed re-establishments since the root went missing
+	RetryIn int64     `json:"retryInMs,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+// SetStatusHandler calls fn with every change of Status; fn must not block
+func (ix *Indexer) SetStatusHandler(fn func(Status)) {
+	ix.statusMu.Lock()
+	ix.onStatus = fn
+	ix.statusMu.Unlock()
+}
+
+// Status returns the current health of the index root
+func (ix *Indexer) Status() Status {
+	ix.statusMu.Lock()
+	defer ix.statusMu.Unlock()
+	if ix.status.State == "" {
+		return Status{State: StatusOK, Root: ix.Root}
+	}
+	return ix.status
+}
+
+func (ix *Indexer) setStatus(st Status) {
+	st.Root = ix.Root
+	st.Since = time.Now().UTC()
+	ix.statusMu.Lock()
+	ix.status = st
+	fn := ix.onStatus
+	ix.statusMu.Unlock()
+	fmt.Printf("index: root %s: %s\n", st.State, st.Message)
+	if fn != nil {
+		fn(st)
+	}
+}
+
+// watchRoot checks the root every rootCheck. Once it is gone, checks back off up to
+// maxRootBackoff; when it exists again, or was replaced by another directory between two
+// checks, the watches are restarted and the tree rescanned.
+func (ix *Indexer) watchRoot(rootAbs string, info os.FileInfo) {
+	defer ix.wg.Done()
+	delay := ix.rootCheck
+	attempt := 0
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	for {
+		select {
+		case <-ix.closed:
+			return
+		case <-t.C:
+		}
+		fi, err := os.Stat(rootAbs)
+		present := err == nil && fi.IsDir()
+		switch {
+		case !ix.rootLost.Load() && present && os.SameFile(fi, info):
+			// unchanged
+		case present:
+			ix.stopWatchers()
+			info = fi
+			attempt = 0
+			delay = ix.rootCheck
+			ix.recoverRoot()
+		case !ix.rootLost.Load():
+			ix.stopWatchers()
+			ix.rootLost.Store(true)
+			ix.setStatus(Status{State: StatusMissing, Message: "index root was removed; watching for it to return", RetryIn: delay.Milliseconds()})
+		default:
+			attempt++
+			delay = min(delay*2, maxRootBackoff)
+			ix.setStatus(Status{State: StatusMissing, Message: "index root is still missing", Attempt: attempt, RetryIn: delay.Milliseconds()})
+		}
+		t.Reset(delay)
+	}
+}
+
+// recoverRoot rescans the returned root and watches it again. It claims refreshRunning
+// first, waiting out a refresh in flight, so the two never rescan at once.
+func (ix *Indexer) recoverRoot() {
+	for !ix.refreshRunning.CompareAndSwap(false, true) {
+		select {
+		case <-ix.closed:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	defer ix.refreshRunning.Store(false)
+	ix.evMu.Lock()
+	ix.pending = make(map[string]fsnotify.Op)
+	ix.changeCount.Store(0)
+	ix.evMu.Unlock()
+	ix.scanOnce()
+	ix.lastRefreshNano.Store(time.Now().UnixNano())
+	ix.changed.Store(false)
+	ix.overflowed.Store(false)
+	msg := "index root is back; rescanned"
+	if ix.tryStartRecursive() || ix.tryStartFsnotify() {
+		ix.setMode("fsnotify")
+	} else {
+		ix.setMode("no-fsnotify")
+		ix.changed.Store(true)
+		msg += " without watchers"
+	}
+	ix.rootLost.Store(false)
+	ix.setStatus(Status{State: StatusRecovered, Message: msg})
+}
+
+// startRootWatch begins checking the root of a live (not static) index
+func (ix *Indexer) startRootWatch() {
+	rootAbs, err := filepath.Abs(ix.Root)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(rootAbs)
+	if err != nil {
+		return
+	}
+	ix.wg.Add(1)
+	go ix.watchRoot(rootAbs, info)
+}
+
+// stopWatchers closes the watches of a root that went away; their goroutines end when
+// the event channels close
+func (ix *Indexer) stopWatchers() {
+	ix.watchMu.Lock()
+	w, rw := ix.watcher, ix.recursive
+	ix.watcher, ix.recursive = nil, nil
+	ix.watchMu.Unlock()
+	if w != nil {
+		_ = w.Close()
+	}
+	if rw != nil {
+		_ = rw.Close()
+	}
+	ix.mu.Lock()
+	ix.watched = make(map[string]struct{})
+	ix.mu.Unlock()
+}
+
+func (ix *Indexer) getMode() string {
+	ix.watchMu.Lock()
+	defer ix.watchMu.Unlock()
+	return ix.mode
+}
+
+func (ix *Indexer) setMode(mode string) {
+	ix.watchMu.Lock()
+	ix.mode = mode
+	ix.watchMu.Unlock()
+}
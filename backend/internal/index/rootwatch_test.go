@@ -0,0 +1,115 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitStatus(t *testing.T, ch <-chan Status, state string) Status {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case st := <-ch:
+			if st.State == state {
+				return st
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for status %q", state)
+		}
+	}
+}
+
+func TestIndexer_RecoversDeletedRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "work")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "old.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ix := New(root)
+	ix.rootCheck = 10 * time.Millisecond
+	ix.debounce = 0
+	statuses := make(chan Status, 64)
+	ix.SetStatusHandler(func(st Status) { statuses <- st })
+	ix.Start()
+	defer ix.Close()
+
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, statuses, StatusMissing)
+	if st := ix.Status(); st.State != StatusMissing || st.Root != root {
+		t.Fatalf("status = %+v", st)
+	}
+	// Entries of the last scan stay searchable while the root is gone
+	if _, ok := ix.Snapshot().Lookup("old.go"); !ok {
+		t.Fatal("last scan dropped while the root is missing")
+	}
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "new.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, statuses, StatusRecovered)
+	snap := ix.Snapshot()
+	if _, ok := snap.Lookup("new.go"); !ok {
+		t.Fatal("recreated root not rescanned")
+	}
+	if _, ok := snap.Lookup("old.go"); ok {
+		t.Fatal("entries of the deleted root kept")
+	}
+
+	// The new root is watched again
+	if err := os.WriteFile(filepath.Join(root, "later.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ix.RequestRefresh()
+		if _, ok := ix.Snapshot().Lookup("later.go"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("changes in the recreated root not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecoverRoot_WaitsForRefreshInFlight(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ix := New(root)
+	ix.refreshRunning.Store(true)
+	done := make(chan struct{})
+	go func() {
+		ix.recoverRoot()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("recoverRoot rescanned while a refresh was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if len(ix.Snapshot().Entries) != 0 {
+		t.Fatal("snapshot published while a refresh was running")
+	}
+	ix.refreshRunning.Store(false)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("recoverRoot did not run after the refresh ended")
+	}
+	if len(ix.Snapshot().Entries) != 1 || ix.refreshRunning.Load() {
+		t.Fatalf("unexpected state after recovery: %d entries, refreshRunning %v", len(ix.Snapshot().Entries), ix.refreshRunning.Load())
+	}
+	ix.Close()
+}
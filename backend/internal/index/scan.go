@@ -15,11 +15,63 @@ func (ix *Indexer) scanOnce() {
 		return
 	}
 
+	newEntries := scanEntries(root)
+
+	// Count files for logging
+	files := 0
+	for _, e := range newEntries {
+		if !e.IsDir {
+			files++
+		}
+	}
+
+	// Publish atomically under ix.mu, which incremental passes hold from reading the
+	// snapshot to publishing theirs, so neither overwrites the other with a stale base;
+	// readers holding the previous snapshot keep using it
+	ix.mu.Lock()
+	ix.publish(newEntries)
+	changed := (ix.prevFiles != files) || (ix.prevEntries != len(newEntries))
+	ix.prevFiles = files
+	ix.prevEntries = len(newEntries)
+	ix.mu.Unlock()
+
+	if changed {
+		fmt.Printf("index: %d files, %d entries\n", files, len(newEntries))
+	}
+}
+
+// Scan builds a snapshot of the tree under root once, the way the server indexes it,
+// without watching it. The CLI uses it to inspect an index headlessly.
+func Scan(root string) Snapshot {
+	return NewSnapshot(scanEntries(root))
+}
+
+// scanEntries returns the entries under root, sorted by path, with short names computed
+func scanEntries(root string) []Entry {
 	rootAbs, err := filepath.Abs(root)
 	if err != nil {
 		rootAbs = root
 	}
 
+	var entries []Entry
+	walkTree(rootAbs, func(rel, name string, isDir bool) bool {
+		entries = append(entries, Entry{Path: rel, Name: name, IsDir: isDir})
+		return true
+	})
+
+	// Sort entries by path for stable order
+	sort.Slice(entries, func(i, j int) bool { return strings.Compare(entries[i].Path, entries[j].Path) < 0 })
+
+	// Compute short names with disambiguation, then pack strings into a shared arena
+	computeShortNames(entries)
+	compactEntries(entries)
+	return entries
+}
+
+// walkTree visits the entries under rootAbs that are not ignored by .git or .gitignore
+// rules, with paths relative to rootAbs. Ignored directories are not descended into, and
+// the walk stops as soon as visit returns false.
+func walkTree(rootAbs string, visit func(rel, name string, isDir bool) bool) {
 	// Build entries using iterative stack to minimize recursion overhead.
 	type dirState struct {
 		absPath string
@@ -27,7 +79,6 @@ func (ix *Indexer) scanOnce() {
 		rules   []rule // ordered from root to current; last match wins
 	}
 
-	var newEntries []Entry
 	var rootRules []rule
 	if lines := readIgnoreLines(filepath.Join(rootAbs, ".gitignore")); len(lines) > 0 {
 		rootRules = append(rootRules, rule{baseAbs: rootAbs, baseRel: ".", ign: compileIgnoreLines(lines)})
@@ -63,9 +114,9 @@ func (ix *Indexer) scanOnce() {
 				continue
 			}
 
-			// Record entry
-			entry := Entry{Path: rel, Name: name, IsDir: de.IsDir()}
-			newEntries = append(newEntries, entry)
+			if !visit(rel, name, de.IsDir()) {
+				return
+			}
 			// If dir, queue traversal with appended rule if this dir has its own .gitignore
 			if de.IsDir() {
 				childRules := d.rules
@@ -77,30 +128,26 @@ func (ix *Indexer) scanOnce() {
 			}
 		}
 	}
+}
 
-	// Sort entries by path for stable order
-	sort.Slice(newEntries, func(i, j int) bool { return strings.Compare(newEntries[i].Path, newEntries[j].Path) < 0 })
-
-	// Compute short names with disambiguation
-	computeShortNames(newEntries)
-
-	// Count files for logging
-	files := 0
-	for _, e := range newEntries {
-		if !e.IsDir {
-			files++
-		}
-	}
-
-	// Publish atomically by replacing the slice under lock
-	ix.mu.Lock()
-	ix.entries = newEntries
-	changed := (ix.prevFiles != files) || (ix.prevEntries != len(newEntries))
-	ix.prevFiles = files
-	ix.prevEntries = len(newEntries)
-	ix.mu.Unlock()
-
-	if changed {
-		fmt.Printf("index: %d files, %d entries\n", files, len(newEntries))
+// ListFiles returns the paths, relative to root, of up to limit files under root that the
+// index would include. truncated reports whether files were left out because of the limit.
+func ListFiles(root string, limit int) (files []string, truncated bool) {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		rootAbs = root
 	}
+	walkTree(rootAbs, func(rel, _ string, isDir bool) bool {
+		if isDir {
+			return true
+		}
+		if limit > 0 && len(files) >= limit {
+			truncated = true
+			return false
+		}
+		files = append(files, rel)
+		return true
+	})
+	sort.Strings(files)
+	return files, truncated
 }
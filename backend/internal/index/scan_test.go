@@ -0,0 +1,38 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListFiles_HonorsGitignoreAndLimit(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":        "build/\n*.log\n",
+		"main.go":           "package main",
+		"pkg/a.go":          "package pkg",
+		"build/out.bin":     "x",
+		"debug.log":         "x",
+		".git/HEAD":         "ref",
+		"pkg/sub/README.md": "hi",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, truncated := ListFiles(root, 0)
+	want := []string{".gitignore", "main.go", filepath.Join("pkg", "a.go"), filepath.Join("pkg", "sub", "README.md")}
+	if truncated || !reflect.DeepEqual(files, want) {
+		t.Fatalf("expected %v, got %v (truncated=%v)", want, files, truncated)
+	}
+	if files, truncated := ListFiles(root, 2); !truncated || len(files) != 2 {
+		t.Fatalf("expected 2 files and truncation, got %v (truncated=%v)", files, truncated)
+	}
+}
@@ -5,11 +5,22 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // Search finds up to limit entries matching the pattern.
 // It also searches within a provided subset of paths (opened) and returns their matches separately.
 func (s Snapshot) Search(pattern string, limit int, opened []string) (results []Entry, openedResults []Entry) {
+	return s.SearchWithProfile(pattern, limit, opened, DefaultProfile)
+}
+
+// SearchWithProfile is like Search but ranks matches using the given profile.
+// The opened list is treated as most-recent-first for the profile's recency bonus.
+func (s Snapshot) SearchWithProfile(pattern string, limit int, opened []string, rp RankingProfile) (results []Entry, openedResults []Entry) {
 	if limit <= 0 {
 		limit = 100
 	}
@@ -17,8 +28,8 @@ func (s Snapshot) Search(pattern string, limit int, opened []string) (results []
 
 	// Normalize opened list to relative paths if possible
 	root := ""
-	// opened are matched by base name primarily
-	openedSet := make(map[string]bool)
+	// opened are matched by base name primarily; value is the position in the opened list
+	openedSet := make(map[string]int)
 	for _, op := range opened {
 		if op == "" {
 			continue
@@ -30,7 +41,10 @@ func (s Snapshot) Search(pattern string, limit int, opened []string) (results []
 				rel = r
 			}
 		}
-		openedSet[normalizeSlash(rel)] = true
+		key := normalizeSlash(rel)
+		if _, dup := openedSet[key]; !dup {
+			openedSet[key] = len(openedSet)
+		}
 	}
 
 	// Fast path for empty pattern: keep behavior (take first 'limit' in stable order; list opened that match)
@@ -45,7 +59,7 @@ func (s Snapshot) Search(pattern string, limit int, opened []string) (results []
 		}
 		if len(openedSet) > 0 {
 			for _, e := range s.Entries {
-				if !openedSet[normalizeSlash(e.Path)] {
+				if _, ok := openedSet[normalizeSlash(e.Path)]; !ok {
 					continue
 				}
 				if p.match(e) {
@@ -61,11 +75,33 @@ func (s Snapshot) Search(pattern string, limit int, opened []string) (results []
 	heap.Init(h)
 	openedScored := make([]scoredEntry, 0)
 
-	for _, e := range s.Entries {
-		ok, sc := scoreEntry(e, p)
+	// Narrow the scan to the previous query's candidates when this pattern extends it
+	cands := s.cache.lookup(p.lower)
+	n := len(s.Entries)
+	if cands != nil {
+		n = len(cands)
+	}
+	matched := make([]int32, 0, min(n, 1024))
+
+	for k := 0; k < n; k++ {
+		i := k
+		if cands != nil {
+			i = int(cands[k])
+		}
+		e := s.Entries[i]
+		ok, sc := scoreEntry(e, p, rp)
 		if !ok {
 			continue
 		}
+		matched = append(matched, int32(i))
+		pos, isOpened := -1, false
+		if len(openedSet) > 0 {
+			pos, isOpened = openedSet[normalizeSlash(e.Path)]
+			if !isOpened {
+				pos = -1
+			}
+		}
+		sc += rp.openedBonus(pos, len(openedSet))
 
 		if len(*h) < limit {
 			heap.Push(h, scoredEntry{entry: e, score: sc})
@@ -74,11 +110,13 @@ func (s Snapshot) Search(pattern string, limit int, opened []string) (results []
 			heap.Push(h, scoredEntry{entry: e, score: sc})
 		}
 
-		if len(openedSet) > 0 && openedSet[normalizeSlash(e.Path)] {
+		if isOpened {
 			openedScored = append(openedScored, scoredEntry{entry: e, score: sc})
 		}
 	}
 
+	s.cache.store(p.lower, matched)
+
 	// Extract and sort by score descending
 	thresholdScore := 0
 	if h.Len() > 0 {
@@ -120,15 +158,23 @@ func (s Snapshot) Search(pattern string, limit int, opened []string) (results []
 
 type compiledPattern struct {
 	raw   string
-	plain string // without '*' and spaces
-	lower string
+	plain string // without '*' and spaces, NFC-normalized
+	lower string // case-folded plain
+	runes []rune // case-folded runes of plain
+	ascii bool   // lower is pure ASCII, enabling the byte-wise fast path
 }
 
 func compilePattern(p string) compiledPattern {
 	p = strings.TrimSpace(p)
 	p = strings.ReplaceAll(p, "*", "")
 	p = strings.ReplaceAll(p, " ", "")
-	return compiledPattern{raw: p, plain: p, lower: strings.ToLower(p)}
+	p = norm.NFC.String(p)
+	runes := []rune(p)
+	for i, r := range runes {
+		runes[i] = foldRune(r)
+	}
+	lower := string(runes)
+	return compiledPattern{raw: p, plain: p, lower: lower, runes: runes, ascii: isASCII(lower)}
 }
 
 func (p compiledPattern) match(e Entry) bool {
@@ -136,13 +182,13 @@ func (p compiledPattern) match(e Entry) bool {
 		return true
 	}
 	// Try base name, short, then path
-	if camelOrSubseqMatch(e.Name, p) {
+	if camelOrSubseqMatch(e.Name, e.lname, p) {
 		return true
 	}
-	if camelOrSubseqMatch(e.Short, p) {
+	if camelOrSubseqMatch(e.Short, e.lshort, p) {
 		return true
 	}
-	if camelOrSubseqMatch(normalizeSlash(e.Path), p) {
+	if camelOrSubseqMatch(runePath(e), e.lpath, p) {
 		return true
 	}
 	return false
@@ -163,57 +209,71 @@ func (h scoredHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
 func (h *scoredHeap) Push(x any)        { *h = append(*h, x.(scoredEntry)) }
 func (h *scoredHeap) Pop() any          { old := *h; n := len(old); x := old[n-1]; *h = old[:n-1]; return x }
 
-// scoreEntry computes the best score among name/short/path with weights.
-func scoreEntry(e Entry, p compiledPattern) (bool, int) {
+// scoreEntry computes the best score among name/short/path with the profile's weights.
+func scoreEntry(e Entry, p compiledPattern, rp RankingProfile) (bool, int) {
 	if p.plain == "" {
 		return true, 0
 	}
-	const (
-		weightName  = 4000
-		weightShort = 2500
-		weightPath  = 1000
-	)
 
 	bestOK := false
 	bestScore := -1 << 30
 
-	if ok, sc := scoreString(e.Name, p); ok {
+	if ok, sc := scoreString(e.Name, e.lname, p); ok {
 		// exact base-name match bonus
-		if strings.EqualFold(e.Name, p.plain) {
-			sc += 800
+		if equalFold(e.Name, p) {
+			sc += rp.ExactNameBonus
 		}
-		sc += weightName
+		sc += rp.NameWeight
 		bestOK, bestScore = true, max(bestScore, sc)
 	}
 	if e.Short != "" {
-		if ok, sc := scoreString(e.Short, p); ok {
-			sc += weightShort
+		if ok, sc := scoreString(e.Short, e.lshort, p); ok {
+			sc += rp.ShortWeight
 			bestOK, bestScore = true, max(bestScore, sc)
 		}
 	}
-	path := normalizeSlash(e.Path)
-	if ok, sc := scoreString(path, p); ok {
+	if ok, sc := scoreString(runePath(e), e.lpath, p); ok {
 		// prefer matches near the end of path (i.e., closer to filename)
-		sc += weightPath
+		sc += rp.PathWeight
 		bestOK, bestScore = true, max(bestScore, sc)
 	}
 	return bestOK, bestScore
 }
 
-func scoreString(s string, p compiledPattern) (bool, int) {
+// runePath returns the path of e to match by runes when it has no folded form: with
+// forward slashes, as lpath has them, so patterns with "/" match on every platform
+func runePath(e Entry) string {
+	if e.lpath != "" {
+		return e.Path
+	}
+	return normalizeSlash(e.Path)
+}
+
+// scoreString scores s against the pattern. fold is the cached ASCII-folded form of s
+// (see foldASCII); when it is available and the pattern is ASCII the comparison runs
+// over bytes without allocating, otherwise s is decoded into runes.
+func scoreString(s, fold string, p compiledPattern) (bool, int) {
 	if s == "" {
 		return false, 0
 	}
-	sl := strings.ToLower(s)
-	pl := p.lower
+	if fold != "" && p.ascii {
+		return scoreSeq(bytesOf(s), bytesOf(fold), bytesOf(p.lower))
+	}
+	t := newRuneText(s)
+	return scoreSeq(t.orig, t.fold, p.runes)
+}
 
+// scoreSeq works on index-aligned original and folded characters. All positions and
+// lengths are in characters, so multi-byte names score the same way as their ASCII
+// equivalents.
+func scoreSeq[T char](orig, fold, pl []T) (bool, int) {
 	// Prefer contiguous substring matches
-	if idx := strings.Index(sl, pl); idx >= 0 {
+	if idx := indexSeq(fold, pl); idx >= 0 {
 		score := 2000
 		// earlier is better
 		score += max(0, 300-idx)
 		// at word/start boosts
-		if isWordStart(s, idx) {
+		if isWordStart(orig, idx) {
 			score += 200
 		}
 		if idx == 0 {
@@ -221,16 +281,16 @@ func scoreString(s string, p compiledPattern) (bool, int) {
 		}
 		// boundary after the match
 		end := idx + len(pl)
-		if end == len(s) || isBoundary(s[end]) {
+		if end == len(orig) || isBoundary(rune(orig[end])) {
 			score += 60
 		}
 		// shorter overall string preferred
-		score -= max(0, len(sl)-len(pl))
+		score -= max(0, len(fold)-len(pl))
 		return true, score
 	}
 
 	// Fallback to subsequence greedy matching
-	ok, ws, first, last := subseqGreedy(sl, pl, s)
+	ok, ws, first, last := subseqGreedy(orig, fold, pl)
 	if !ok {
 		return false, 0
 	}
@@ -249,14 +309,14 @@ func scoreString(s string, p compiledPattern) (bool, int) {
 	return true, score
 }
 
-// subseqGreedy matches pl as a subsequence of sl, counting word-start hits and span.
+// subseqGreedy matches pl as a subsequence of fold, counting word-start hits and span.
 // Returns ok, wordStartsMatched, firstIndex, lastIndex.
-func subseqGreedy(sl, pl, orig string) (bool, int, int, int) {
+func subseqGreedy[T char](orig, fold, pl []T) (bool, int, int, int) {
 	si, pi := 0, 0
 	ws := 0
 	first, last := -1, -1
-	for si < len(sl) && pi < len(pl) {
-		if sl[si] == pl[pi] {
+	for si < len(fold) && pi < len(pl) {
+		if fold[si] == pl[pi] {
 			if first == -1 {
 				first = si
 			}
@@ -276,8 +336,8 @@ func subseqGreedy(sl, pl, orig string) (bool, int, int, int) {
 	return false, 0, -1, -1
 }
 
-func isBoundary(b byte) bool {
-	return b == '/' || b == '\\' || b == '-' || b == '_' || b == '.'
+func isBoundary(r rune) bool {
+	return r == '/' || r == '\\' || r == '-' || r == '_' || r == '.'
 }
 
 func max(a, b int) int {
@@ -288,22 +348,28 @@ func max(a, b int) int {
 }
 
 // camelOrSubseqMatch performs a case-insensitive subsequence match with camel-case boosts
-func camelOrSubseqMatch(s string, p compiledPattern) bool {
+func camelOrSubseqMatch(s, fold string, p compiledPattern) bool {
 	if s == "" {
 		return false
 	}
-	sl := strings.ToLower(s)
-	pl := p.lower
+	if fold != "" && p.ascii {
+		return matchSeq(bytesOf(s), bytesOf(fold), bytesOf(p.lower))
+	}
+	t := newRuneText(s)
+	return matchSeq(t.orig, t.fold, p.runes)
+}
+
+func matchSeq[T char](orig, fold, pl []T) bool {
 	// quick substring check
-	if strings.Contains(sl, pl) {
+	if indexSeq(fold, pl) >= 0 {
 		return true
 	}
 	// subsequence with camel awareness
-	return subseqWithWordStarts(sl, p.lower, s)
+	return subseqWithWordStarts(orig, fold, pl)
 }
 
 // subseqWithWordStarts tries to match pattern as subsequence, preferring word starts (letters after separators or camel humps)
-func subseqWithWordStarts(sl string, pl string, orig string) bool {
+func subseqWithWordStarts[T char](orig, sl, pl []T) bool {
 	si := 0
 	pi := 0
 	for si < len(sl) && pi < len(pl) {
@@ -318,7 +384,7 @@ func subseqWithWordStarts(sl string, pl string, orig string) bool {
 		// If this is a word start in orig, allow skipping until next match
 		if isWordStart(orig, si) {
 			// try to align cp with next occurrence from here
-			idx := strings.IndexByte(sl[si:], cp)
+			idx := indexElem(sl[si:], cp)
 			if idx < 0 {
 				return false
 			}
@@ -331,21 +397,122 @@ func subseqWithWordStarts(sl string, pl string, orig string) bool {
 	return pi == len(pl)
 }
 
-func isWordStart(s string, i int) bool {
+func isWordStart[T char](s []T, i int) bool {
 	if i <= 0 {
 		return true
 	}
-	prev := s[i-1]
-	cur := s[i]
-	if prev == '/' || prev == '\\' || prev == '-' || prev == '_' || prev == '.' {
+	prev := rune(s[i-1])
+	cur := rune(s[i])
+	if isBoundary(prev) {
 		return true
 	}
 	// camel hump: prev is lower and cur is upper in original casing
-	if isLower(prev) && isUpper(cur) {
-		return true
+	if prev < utf8.RuneSelf && cur < utf8.RuneSelf {
+		return isLower(byte(prev)) && isUpper(byte(cur))
 	}
-	return false
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
 }
 
 func isLower(b byte) bool { return b >= 'a' && b <= 'z' }
 func isUpper(b byte) bool { return b >= 'A' && b <= 'Z' }
+
+// --- Unicode helpers ---
+
+// char is the element type matched by the scorer: bytes for the ASCII fast path,
+// runes otherwise.
+type char interface{ byte | rune }
+
+// runeText is a string decoded for matching: orig keeps the original casing (used for
+// camel-hump detection) and fold holds the case-folded rune at the same position.
+type runeText struct {
+	orig []rune
+	fold []rune
+}
+
+// newRuneText NFC-normalizes s (file systems such as macOS may report names in NFD)
+// and decodes it into index-aligned original and folded runes.
+func newRuneText(s string) runeText {
+	if !norm.NFC.IsNormalString(s) {
+		s = norm.NFC.String(s)
+	}
+	orig := []rune(s)
+	fold := make([]rune, len(orig))
+	for i, r := range orig {
+		fold[i] = foldRune(r)
+	}
+	return runeText{orig: orig, fold: fold}
+}
+
+// foldRune maps r to its lower-case form so that comparisons are case-insensitive
+// across scripts, not only for ASCII.
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}
+	return unicode.ToLower(r)
+}
+
+// foldASCII returns the lower-cased, slash-normalized form of s for the byte-wise fast
+// path, or "" if s contains non-ASCII characters. Already-lowercase input is returned
+// as-is, so it shares memory with s.
+func foldASCII(s string) string {
+	if !isASCII(s) {
+		return ""
+	}
+	return strings.ToLower(normalizeSlash(s))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// bytesOf returns the bytes of s without copying. The result must not be modified.
+func bytesOf(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// equalFold reports whether s equals the pattern under NFC normalization and case folding.
+func equalFold(s string, p compiledPattern) bool {
+	if !norm.NFC.IsNormalString(s) {
+		s = norm.NFC.String(s)
+	}
+	return strings.EqualFold(s, p.plain)
+}
+
+// indexSeq returns the index of the first occurrence of sub in s, or -1.
+func indexSeq[T char](s, sub []T) int {
+	if len(sub) == 0 {
+		return 0
+	}
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i] != sub[0] {
+			continue
+		}
+		j := 1
+		for j < len(sub) && s[i+j] == sub[j] {
+			j++
+		}
+		if j == len(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexElem returns the index of the first occurrence of c in s, or -1.
+func indexElem[T char](s []T, c T) int {
+	for i, x := range s {
+		if x == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,88 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+)
+
+// syntheticEntries builds a deterministic corpus of n entries shaped like a typical
+// source tree: nested directories with mixed-case file names and repeated base names.
+func syntheticEntries(n int) []Entry {
+	dirs := []string{"src", "internal", "pkg", "web-ui/src", "hosts/vscode-plugin/src", "docs", "test/fixtures"}
+	subs := []string{"api", "core", "ui", "util", "models", "handlers", "storage", "Config"}
+	stems := []string{"main", "index", "Router", "server", "searchIndex", "history_manager", "README", "fileUtil", "conpty", "session"}
+	exts := []string{".go", ".ts", ".tsx", ".md", ".json", ".kt"}
+	entries := make([]Entry, 0, n)
+	for i := 0; len(entries) < n; i++ {
+		dir := fmt.Sprintf("%s/%s%d/%s", dirs[i%len(dirs)], subs[(i/7)%len(subs)], i/400, subs[(i/3)%len(subs)])
+		name := fmt.Sprintf("%s%d%s", stems[i%len(stems)], i%97, exts[(i/11)%len(exts)])
+		entries = append(entries, Entry{Path: dir + "/" + name, Name: name})
+	}
+	computeShortNames(entries)
+	compactEntries(entries)
+	return entries
+}
+
+var benchSizes = []int{10_000, 100_000, 500_000}
+
+func benchmarkSearch(b *testing.B, n int, pattern string) {
+	s := Snapshot{Entries: syntheticEntries(n)} // no cache: every iteration scores the full corpus
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Search(pattern, 100, nil)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(n), "ns/entry")
+}
+
+// searchAllocBudget is the performance budget for a full ranked search, expressed per
+// indexed entry. Scoring an entry that does not match allocates nothing, but a match that
+// enters the top results costs up to two, as heap.Push and heap.Pop box it, and the
+// candidates kept for the search cache grow with the matches. Up to one entry in ten of
+// the corpus matches ("README" measures about 0.14), so 0.25 leaves headroom while still
+// failing once every entry allocates.
+const searchAllocBudget = 0.25
+
+func TestSearch_AllocBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping performance budget in short mode")
+	}
+	const n = 10_000
+	s := Snapshot{Entries: syntheticEntries(n)}
+	for _, pattern := range []string{"router", "hdlsrv", "README"} {
+		allocs := testing.AllocsPerRun(5, func() { s.Search(pattern, 100, nil) })
+		if perEntry := allocs / n; perEntry > searchAllocBudget {
+			t.Errorf("Search(%q) allocates %.3f times per entry (%.0f total), budget is %.2f", pattern, perEntry, allocs, searchAllocBudget)
+		}
+	}
+}
+
+func BenchmarkSearch_Substring(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) { benchmarkSearch(b, n, "router") })
+	}
+}
+
+func BenchmarkSearch_Subsequence(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) { benchmarkSearch(b, n, "hdlsrv") })
+	}
+}
+
+func BenchmarkSearch_Keystrokes(b *testing.B) {
+	// Simulates typing with a live snapshot so the prefix cache is exercised
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			entries := syntheticEntries(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s := Snapshot{Entries: entries, cache: &searchCache{}}
+				for _, p := range []string{"s", "se", "ser", "serv", "serve", "server"} {
+					s.Search(p, 100, nil)
+				}
+			}
+		})
+	}
+}
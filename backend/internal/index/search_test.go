@@ -0,0 +1,197 @@
+package index
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+func entry(path string) Entry {
+	e := Entry{Path: path, Name: path}
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			e.Name = path[i+1:]
+			break
+		}
+	}
+	e.Short = e.Name
+	return e
+}
+
+func searchPaths(t *testing.T, entries []Entry, pattern string) []string {
+	t.Helper()
+	res, _ := Snapshot{Entries: entries}.Search(pattern, 10, nil)
+	out := make([]string, len(res))
+	for i, e := range res {
+		out[i] = e.Path
+	}
+	return out
+}
+
+func TestSearch_UnicodeCaseFolding(t *testing.T) {
+	entries := []Entry{entry("docs/Éléphant.md"), entry("docs/elephant.md"), entry("src/main.go")}
+	got := searchPaths(t, entries, "élé")
+	if len(got) != 1 || got[0] != "docs/Éléphant.md" {
+		t.Fatalf("expected only docs/Éléphant.md, got %v", got)
+	}
+}
+
+func TestSearch_NFDNamesMatchNFCPattern(t *testing.T) {
+	// "café" with a combining acute accent, as reported by some file systems
+	entries := []Entry{entry("notes/café.txt"), entry("notes/cafe.txt")}
+	got := searchPaths(t, entries, "café")
+	if len(got) != 1 || got[0] != "notes/café.txt" {
+		t.Fatalf("expected NFD entry to match NFC pattern, got %v", got)
+	}
+}
+
+func TestSearch_UnicodeCamelHumps(t *testing.T) {
+	entries := []Entry{entry("src/ÜberÄrger.go"), entry("src/uberaxrger.go")}
+	got := searchPaths(t, entries, "üä")
+	if len(got) == 0 || got[0] != "src/ÜberÄrger.go" {
+		t.Fatalf("expected camel-hump match first, got %v", got)
+	}
+}
+
+func TestSearch_MultiByteRunesDoNotMatchPartially(t *testing.T) {
+	// "中" and "丨" share a leading UTF-8 byte; byte-wise subsequence matching
+	// could combine bytes from different runes.
+	entries := []Entry{entry("a/中文.txt"), entry("a/丨.txt")}
+	got := searchPaths(t, entries, "中")
+	if len(got) != 1 || got[0] != "a/中文.txt" {
+		t.Fatalf("expected only a/中文.txt, got %v", got)
+	}
+}
+
+func TestSearch_NonASCIIPathsMatchSlashPatterns(t *testing.T) {
+	// Non-ASCII paths have no folded form and are matched by runes; on Windows they hold
+	// backslashes, which a "/" in the pattern must still match
+	path := filepath.Join("src", "ünï", "file.go")
+	for _, entries := range [][]Entry{compacted(path), {{Path: path, Name: "file.go", Short: "file.go"}}} {
+		if got := searchPaths(t, entries, "ünï/file"); len(got) != 1 || got[0] != path {
+			t.Fatalf("expected %q to match, got %v", path, got)
+		}
+	}
+}
+
+func TestSearchWithProfile_OpenedBoost(t *testing.T) {
+	entries := []Entry{entry("a/config.go"), entry("b/config.go")}
+	s := Snapshot{Entries: entries}
+
+	res, _ := s.SearchWithProfile("config", 10, nil, DefaultProfile)
+	if len(res) != 2 {
+		t.Fatalf("expected both entries, got %v", res)
+	}
+
+	res, opened := s.SearchWithProfile("config", 10, []string{"b/config.go"}, ProfileByName("picker"))
+	if len(res) != 2 || res[0].Path != "b/config.go" {
+		t.Fatalf("expected opened entry ranked first with picker profile, got %v", res)
+	}
+	if len(opened) != 1 || opened[0].Path != "b/config.go" {
+		t.Fatalf("expected opened result b/config.go, got %v", opened)
+	}
+}
+
+func TestProfileByName_FallsBackToDefault(t *testing.T) {
+	if got := ProfileByName("no-such-profile"); got.Name != DefaultProfile.Name {
+		t.Fatalf("expected default profile, got %q", got.Name)
+	}
+}
+
+// compacted returns entries as a scan publishes them
+func compacted(paths ...string) []Entry {
+	entries := make([]Entry, len(paths))
+	for i, p := range paths {
+		entries[i] = entry(p)
+	}
+	compactEntries(entries)
+	return entries
+}
+
+func resultPaths(res []Entry) []string {
+	out := make([]string, len(res))
+	for i, e := range res {
+		out[i] = e.Path
+	}
+	return out
+}
+
+var cacheTestPaths = []string{
+	"src/main.go", "src/main_test.go", "cmd/mailer/main.go", "docs/manual.md", "abc/x.go", "abd/y.go", "lib/abdomen.go",
+}
+
+func TestSearchCache_ExtendedQueryMatchesColdSearch(t *testing.T) {
+	warm := NewSnapshot(compacted(cacheTestPaths...))
+	warm.Search("ma", 10, nil)
+	if warm.cache.lookup("mai") == nil {
+		t.Fatal("expected the extended query to use the cached candidates")
+	}
+	got, _ := warm.Search("mai", 10, nil)
+	want, _ := NewSnapshot(compacted(cacheTestPaths...)).Search("mai", 10, nil)
+	if !slices.Equal(resultPaths(got), resultPaths(want)) {
+		t.Fatalf("warm search = %v, cold search = %v", resultPaths(got), resultPaths(want))
+	}
+}
+
+func TestSearchCache_IgnoredWhenQueryDoesNotExtendIt(t *testing.T) {
+	warm := NewSnapshot(compacted(cacheTestPaths...))
+	warm.Search("abc", 10, nil)
+	if warm.cache.lookup("abd") != nil {
+		t.Fatal("cached candidates of abc used for abd")
+	}
+	got, _ := warm.Search("abd", 10, nil)
+	want, _ := NewSnapshot(compacted(cacheTestPaths...)).Search("abd", 10, nil)
+	if !slices.Equal(resultPaths(got), resultPaths(want)) || !slices.Contains(resultPaths(got), "abd/y.go") {
+		t.Fatalf("warm search = %v, cold search = %v", resultPaths(got), resultPaths(want))
+	}
+}
+
+func TestPublish_SearchesDoNotReuseCandidatesOfPreviousSnapshot(t *testing.T) {
+	ix := New(t.TempDir())
+	ix.publish(compacted("a/main.go", "b/main.go", "c/other.go"))
+	first := ix.Snapshot()
+	first.Search("ma", 10, nil)
+
+	// The new snapshot holds different entries at the cached indexes
+	ix.publish(compacted("docs/guide.md", "src/mail.go", "src/main.go", "x/y.go"))
+	second := ix.Snapshot()
+	if second.cache == first.cache || second.cache.lookup("mai") != nil {
+		t.Fatal("the new snapshot shares the previous snapshot's cache")
+	}
+	got, _ := second.Search("mai", 10, nil)
+	if paths := resultPaths(got); len(paths) != 2 || !slices.Contains(paths, "src/mail.go") || !slices.Contains(paths, "src/main.go") {
+		t.Fatalf("unexpected results after publish: %v", paths)
+	}
+	// Readers holding the previous snapshot keep its entries
+	if old, _ := first.Search("mai", 10, nil); len(old) != 2 || len(first.Entries) != 3 {
+		t.Fatalf("previous snapshot changed: %v", resultPaths(old))
+	}
+}
+
+func TestCompactEntries_KeepsPathNameAndShort(t *testing.T) {
+	entries := []Entry{
+		{Path: "src/app/main.go", Name: "main.go", Short: "app/main.go"},
+		{Path: "docs/README", Name: "Readme", Short: "docs/Readme"}, // not suffixes of the path
+		{Path: "lib", Name: "lib", Short: "lib", IsDir: true},
+	}
+	want := slices.Clone(entries)
+	compactEntries(entries)
+	for i, e := range entries {
+		w := want[i]
+		if e.Path != w.Path || e.Name != w.Name || e.Short != w.Short || e.IsDir != w.IsDir {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+		}
+	}
+	if e := entries[1]; e.lname != "readme" || e.lshort != "docs/readme" || e.lpath != "docs/readme" {
+		t.Errorf("unexpected folded forms %q %q %q", e.lpath, e.lname, e.lshort)
+	}
+	// Paths share one arena, in order, and names that are suffixes reuse it
+	first := unsafe.Pointer(unsafe.StringData(entries[0].Path))
+	if unsafe.Pointer(unsafe.StringData(entries[1].Path)) != unsafe.Add(first, len(entries[0].Path)) {
+		t.Error("paths not packed into one arena")
+	}
+	if unsafe.Pointer(unsafe.StringData(entries[0].Name)) != unsafe.Add(first, len("src/app/")) {
+		t.Error("name not taken from the path")
+	}
+}
@@ -0,0 +1,35 @@
+package index
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// modeStatic marks an Indexer built by NewFromFS, which never rescans
+const modeStatic = "static"
+
+// NewFromFS returns an Indexer over the files of fsys as if they were under root. The
+// entries are read once: the Indexer neither watches nor rescans, so fsys may be a
+// synthetic tree (mock mode, tests) that does not exist on disk.
+func NewFromFS(root string, fsys fs.FS) *Indexer {
+	ix := New(root)
+	ix.mode = modeStatic
+	var entries []Entry
+	_ = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return fs.SkipDir
+		}
+		entries = append(entries, Entry{Path: filepath.FromSlash(p), Name: d.Name(), IsDir: d.IsDir()})
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return strings.Compare(entries[i].Path, entries[j].Path) < 0 })
+	computeShortNames(entries)
+	compactEntries(entries)
+	ix.publish(entries)
+	return ix
+}
@@ -0,0 +1,64 @@
+package index
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxStatsDirs bounds the top-level directories listed in Stats
+const maxStatsDirs = 10
+
+// DirCount is the number of files under a top-level directory of the root
+type DirCount struct {
+	Dir   string `json:"dir"`
+	Files int    `json:"files"`
+}
+
+// Stats summarizes a snapshot: what it holds, and whether the per-directory watches of
+// the server would fit under their cap
+type Stats struct {
+	Files       int        `json:"files"`
+	Dirs        int        `json:"dirs"`
+	IgnoreFiles int        `json:"ignoreFiles"` // .gitignore files in the index
+	MaxDepth    int        `json:"maxDepth"`
+	Watches     int        `json:"watches"`  // directories a per-directory watch needs, the root included
+	WatchCap    int        `json:"watchCap"` // beyond it, the server stops adding watches
+	TopDirs     []DirCount `json:"topDirs"`  // the top-level directories holding the most files
+}
+
+// Stats computes the Stats of the snapshot
+func (s Snapshot) Stats() Stats {
+	st := Stats{WatchCap: defaultMaxWatchDirs, Watches: 1}
+	byDir := map[string]int{}
+	for _, e := range s.Entries {
+		depth := strings.Count(e.Path, string(filepath.Separator)) + 1
+		st.MaxDepth = max(st.MaxDepth, depth)
+		if e.IsDir {
+			st.Dirs++
+			st.Watches++
+			continue
+		}
+		st.Files++
+		if e.Name == ".gitignore" {
+			st.IgnoreFiles++
+		}
+		if top, _, ok := strings.Cut(e.Path, string(filepath.Separator)); ok {
+			byDir[top]++
+		}
+	}
+	st.TopDirs = make([]DirCount, 0, len(byDir))
+	for dir, n := range byDir {
+		st.TopDirs = append(st.TopDirs, DirCount{Dir: dir, Files: n})
+	}
+	sort.Slice(st.TopDirs, func(i, j int) bool {
+		if st.TopDirs[i].Files != st.TopDirs[j].Files {
+			return st.TopDirs[i].Files > st.TopDirs[j].Files
+		}
+		return st.TopDirs[i].Dir < st.TopDirs[j].Dir
+	})
+	if len(st.TopDirs) > maxStatsDirs {
+		st.TopDirs = st.TopDirs[:maxStatsDirs]
+	}
+	return st
+}
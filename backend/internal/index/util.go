@@ -48,8 +48,17 @@ func normalizeSlash(p string) string { return strings.ReplaceAll(p, string(filep
 // Later rules override earlier ones. We approximate by evaluating each rule matcher
 // against the path relative to the rule's base directory and remembering the last decision.
 func ignoredByRules(rules []rule, relPath string) bool {
+	_, _, ignored := matchIgnoreRules(rules, relPath)
+	return ignored
+}
+
+// matchIgnoreRules is ignoredByRules that also returns the last rule, and its pattern,
+// that ignored the path
+func matchIgnoreRules(rules []rule, relPath string) (rule, *ignore.IgnorePattern, bool) {
+	var by rule
+	var how *ignore.IgnorePattern
 	if len(rules) == 0 {
-		return false
+		return by, how, false
 	}
 	// Normalize
 	relNorm := normalizeSlash(relPath)
@@ -60,7 +69,13 @@ func ignoredByRules(rules []rule, relPath string) bool {
 		if base == "." {
 			p = relNorm
 		} else {
-			p = normalizeSlash(strings.TrimPrefix(relNorm, normalizeSlash(base)+"/"))
+			baseNorm := normalizeSlash(base)
+			if !strings.HasPrefix(relNorm, baseNorm+"/") {
+				// the directory itself, or a path outside it: a .gitignore only applies
+				// below the directory it lives in
+				continue
+			}
+			p = relNorm[len(baseNorm)+1:]
 		}
 		if r.ign == nil {
 			continue
@@ -69,15 +84,16 @@ func ignoredByRules(rules []rule, relPath string) bool {
 			// .gitignore at this dir should not ignore the directory entry itself
 			continue
 		}
-		if r.ign.MatchesPath(p) {
+		if ok, ip := r.ign.MatchesPathHow(p); ok {
 			ignored = true
+			by, how = r, ip
 		} else {
 			// If there is a negation pattern that matches, MatchesPath returns false.
 			// Proper handling would require MatchesPath fuzz with negations.
 			// For simplicity we keep last true as ignore; otherwise leave as is.
 		}
 	}
-	return ignored
+	return by, how, ignored
 }
 
 // computeShortNames fills Short for entries, disambiguating duplicate base names by
@@ -0,0 +1,24 @@
+package index
+
+import (
+	"errors"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// recursiveWatcher reports changes anywhere below a root through a single watch: FSEvents
+// on macOS and ReadDirectoryChangesW with subtree watching on Windows. fsnotify needs a
+// watch per directory there, which scales poorly on huge trees and runs into the kqueue
+// file descriptor limit on macOS. Event names are absolute paths; dropped events are
+// reported as fsnotify.ErrEventOverflow.
+type recursiveWatcher interface {
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+	Close() error
+	// Name names the platform API, for logging
+	Name() string
+}
+
+// errRecursiveUnsupported is returned by newRecursiveWatcher on platforms without a
+// recursive watch API, e.g. Linux, where inotify watches each directory anyway
+var errRecursiveUnsupported = errors.New("recursive watching is not supported on this platform")
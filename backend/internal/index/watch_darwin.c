@@ -0,0 +1,44 @@
+//go:build darwin && cgo
+
+#include <CoreServices/CoreServices.h>
+#include <dispatch/dispatch.h>
+#include "_cgo_export.h"
+
+static void rbFSEventsCallback(ConstFSEventStreamRef stream, void *info, size_t n, void *paths,
+                               const FSEventStreamEventFlags *flags, const FSEventStreamEventId *ids) {
+	rbFSEvents((uintptr_t)info, n, (char **)paths, (FSEventStreamEventFlags *)flags);
+}
+
+// rbStartFSEvents starts a stream of file-level events below root, delivered on a serial
+// dispatch queue; it returns NULL when the stream cannot be created or started
+FSEventStreamRef rbStartFSEvents(const char *root, uintptr_t id, double latency) {
+	CFStringRef path = CFStringCreateWithCString(NULL, root, kCFStringEncodingUTF8);
+	if (path == NULL) {
+		return NULL;
+	}
+	CFArrayRef paths = CFArrayCreate(NULL, (const void **)&path, 1, &kCFTypeArrayCallBacks);
+	FSEventStreamContext ctx = {0, (void *)id, NULL, NULL, NULL};
+	FSEventStreamRef stream = FSEventStreamCreate(NULL, rbFSEventsCallback, &ctx, paths, kFSEventStreamEventIdSinceNow, latency,
+		kFSEventStreamCreateFlagFileEvents | kFSEventStreamCreateFlagNoDefer | kFSEventStreamCreateFlagWatchRoot);
+	CFRelease(paths);
+	CFRelease(path);
+	if (stream == NULL) {
+		return NULL;
+	}
+	// the stream retains the queue
+	dispatch_queue_t queue = dispatch_queue_create("rovobridge.index.fsevents", DISPATCH_QUEUE_SERIAL);
+	FSEventStreamSetDispatchQueue(stream, queue);
+	dispatch_release(queue);
+	if (!FSEventStreamStart(stream)) {
+		FSEventStreamInvalidate(stream);
+		FSEventStreamRelease(stream);
+		return NULL;
+	}
+	return stream;
+}
+
+void rbStopFSEvents(FSEventStreamRef stream) {
+	FSEventStreamStop(stream);
+	FSEventStreamInvalidate(stream);
+	FSEventStreamRelease(stream);
+}
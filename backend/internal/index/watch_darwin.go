@@ -0,0 +1,145 @@
+//go:build darwin && cgo
+
+package index
+
+/*
+#cgo LDFLAGS: -framework CoreServices
+#include <stdlib.h>
+#include <CoreServices/CoreServices.h>
+
+FSEventStreamRef rbStartFSEvents(const char *root, uintptr_t id, double latency);
+void rbStopFSEvents(FSEventStreamRef stream);
+*/
+import "C"
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fseventsLatency is how long FSEvents coalesces changes before delivering them, in seconds
+const fseventsLatency = 0.1
+
+var errFSEventsStart = errors.New("FSEvents stream could not be started")
+
+// fseventsWatchers maps stream ids to their watchers for the C callback; a callback that
+// arrives after Close finds none and is dropped
+var (
+	fseventsMu       sync.Mutex
+	fseventsWatchers = map[uintptr]*fseventsWatcher{}
+	fseventsNextID   uintptr
+)
+
+// fseventsWatcher watches a tree with an FSEvents stream
+type fseventsWatcher struct {
+	root     string // as given
+	realRoot string // with symlinks resolved, as FSEvents reports paths, e.g. /private/var
+	id       uintptr
+	stream   C.FSEventStreamRef
+	quit     chan struct{}
+	events   chan fsnotify.Event
+	errors   chan error
+	once     sync.Once
+}
+
+func newRecursiveWatcher(root string) (recursiveWatcher, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	w := &fseventsWatcher{
+		root:     root,
+		realRoot: realRoot,
+		quit:     make(chan struct{}),
+		events:   make(chan fsnotify.Event, 256),
+		errors:   make(chan error, 1),
+	}
+	fseventsMu.Lock()
+	fseventsNextID++
+	w.id = fseventsNextID
+	fseventsWatchers[w.id] = w
+	fseventsMu.Unlock()
+
+	croot := C.CString(realRoot)
+	defer C.free(unsafe.Pointer(croot))
+	w.stream = C.rbStartFSEvents(croot, C.uintptr_t(w.id), C.double(fseventsLatency))
+	if w.stream == nil {
+		w.unregister()
+		return nil, errFSEventsStart
+	}
+	return w, nil
+}
+
+func (w *fseventsWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *fseventsWatcher) Errors() <-chan error          { return w.errors }
+func (w *fseventsWatcher) Name() string                  { return "FSEvents" }
+
+func (w *fseventsWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.quit)
+		C.rbStopFSEvents(w.stream)
+		w.unregister()
+	})
+	return nil
+}
+
+func (w *fseventsWatcher) unregister() {
+	fseventsMu.Lock()
+	delete(fseventsWatchers, w.id)
+	fseventsMu.Unlock()
+}
+
+// send delivers one FSEvents event; it is called on the stream's dispatch queue
+func (w *fseventsWatcher) send(path string, flags C.FSEventStreamEventFlags) {
+	if flags&(C.kFSEventStreamEventFlagMustScanSubDirs|C.kFSEventStreamEventFlagUserDropped|C.kFSEventStreamEventFlagKernelDropped) != 0 {
+		select {
+		case w.errors <- fsnotify.ErrEventOverflow:
+		default:
+		}
+		return
+	}
+	var op fsnotify.Op
+	if flags&C.kFSEventStreamEventFlagItemCreated != 0 {
+		op |= fsnotify.Create
+	}
+	if flags&C.kFSEventStreamEventFlagItemRemoved != 0 {
+		op |= fsnotify.Remove
+	}
+	if flags&C.kFSEventStreamEventFlagItemRenamed != 0 {
+		op |= fsnotify.Rename
+	}
+	if flags&(C.kFSEventStreamEventFlagItemModified|C.kFSEventStreamEventFlagItemInodeMetaMod) != 0 {
+		op |= fsnotify.Write
+	}
+	if op == 0 {
+		return
+	}
+	// report paths under the root as given, not its resolved form
+	if rest, ok := strings.CutPrefix(path, w.realRoot); ok && w.realRoot != w.root {
+		path = w.root + rest
+	}
+	select {
+	case w.events <- fsnotify.Event{Name: path, Op: op}:
+	case <-w.quit:
+	}
+}
+
+//export rbFSEvents
+func rbFSEvents(id C.uintptr_t, n C.size_t, paths **C.char, flags *C.FSEventStreamEventFlags) {
+	fseventsMu.Lock()
+	w := fseventsWatchers[uintptr(id)]
+	fseventsMu.Unlock()
+	if w == nil {
+		return
+	}
+	pathList := unsafe.Slice(paths, int(n))
+	flagList := unsafe.Slice(flags, int(n))
+	for i := range pathList {
+		w.send(C.GoString(pathList[i]), flagList[i])
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !windows && !(darwin && cgo)
+
+package index
+
+func newRecursiveWatcher(root string) (recursiveWatcher, error) {
+	return nil, errRecursiveUnsupported
+}
@@ -0,0 +1,41 @@
+package index
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestRecordEvent_RecursiveKeepsIndexedDirs(t *testing.T) {
+	root := t.TempDir()
+	ix := New(root)
+	ix.publish([]Entry{
+		{Path: "src", Name: "src", IsDir: true},
+		{Path: filepath.Join("src", "app.ts"), Name: "app.ts"},
+		{Path: "main.go", Name: "main.go"},
+	})
+	for _, name := range []string{
+		"main.go", "newdir", filepath.Join("src", "app.ts"), filepath.Join("src", "lib"),
+		filepath.Join(".git", "index"), ".git", filepath.Join("node_modules", "x", "index.js"),
+		filepath.Join("src", "lib", "deep.ts"),
+	} {
+		ix.recordEvent(root, fsnotify.Event{Name: filepath.Join(root, name), Op: fsnotify.Write}, true)
+	}
+	ix.recordEvent(root, fsnotify.Event{Name: filepath.Dir(root), Op: fsnotify.Write}, true)
+
+	want := map[string]bool{"main.go": true, "newdir": true, "src/app.ts": true, "src/lib": true}
+	if len(ix.pending) != len(want) {
+		t.Fatalf("pending = %v, want %v", ix.pending, want)
+	}
+	for p := range ix.pending {
+		if !want[p] {
+			t.Errorf("unexpected pending change %q", p)
+		}
+	}
+
+	ix.recordError(fsnotify.ErrEventOverflow)
+	if !ix.overflowed.Load() || !ix.changed.Load() {
+		t.Fatal("expected an overflow to force a rescan")
+	}
+}
@@ -0,0 +1,164 @@
+//go:build windows
+
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/sys/windows"
+)
+
+// rdcwBufferSize is the notification buffer of ReadDirectoryChangesW; when a burst of
+// changes does not fit, the call reports an overflow and the index is rescanned
+const rdcwBufferSize = 64 * 1024
+
+const rdcwFilter = windows.FILE_NOTIFY_CHANGE_FILE_NAME | windows.FILE_NOTIFY_CHANGE_DIR_NAME |
+	windows.FILE_NOTIFY_CHANGE_SIZE | windows.FILE_NOTIFY_CHANGE_LAST_WRITE | windows.FILE_NOTIFY_CHANGE_CREATION
+
+// rdcwWatcher watches a tree with ReadDirectoryChangesW in subtree mode
+type rdcwWatcher struct {
+	root   string
+	dir    windows.Handle
+	ready  windows.Handle // signalled when a read completes
+	stop   windows.Handle // signalled by Close
+	quit   chan struct{}
+	done   chan struct{}
+	events chan fsnotify.Event
+	errors chan error
+	once   sync.Once
+
+	// buffer and overlapped structure of the pending read; fields, so the kernel writes
+	// to memory that does not move
+	buf [rdcwBufferSize]byte
+	ov  windows.Overlapped
+}
+
+func newRecursiveWatcher(root string) (recursiveWatcher, error) {
+	p, err := windows.UTF16PtrFromString(root)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := windows.CreateFile(p, windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil,
+		windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", root, err)
+	}
+	ready, err := windows.CreateEvent(nil, 0, 0, nil)
+	if err != nil {
+		windows.CloseHandle(dir)
+		return nil, err
+	}
+	stop, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		windows.CloseHandle(ready)
+		windows.CloseHandle(dir)
+		return nil, err
+	}
+	w := &rdcwWatcher{
+		root:   root,
+		dir:    dir,
+		ready:  ready,
+		stop:   stop,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+		events: make(chan fsnotify.Event, 256),
+		errors: make(chan error, 1),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *rdcwWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *rdcwWatcher) Errors() <-chan error          { return w.errors }
+func (w *rdcwWatcher) Name() string                  { return "ReadDirectoryChangesW" }
+
+func (w *rdcwWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.quit)
+		_ = windows.SetEvent(w.stop)
+		<-w.done
+		windows.CloseHandle(w.dir)
+		windows.CloseHandle(w.ready)
+		windows.CloseHandle(w.stop)
+	})
+	return nil
+}
+
+func (w *rdcwWatcher) run() {
+	defer close(w.done)
+	defer close(w.events)
+	defer close(w.errors)
+	for {
+		w.ov = windows.Overlapped{HEvent: w.ready}
+		if err := windows.ReadDirectoryChanges(w.dir, &w.buf[0], uint32(len(w.buf)), true, rdcwFilter, nil, &w.ov, 0); err != nil {
+			w.sendError(err)
+			return
+		}
+		which, err := windows.WaitForMultipleObjects([]windows.Handle{w.ready, w.stop}, false, windows.INFINITE)
+		if err != nil || which != windows.WAIT_OBJECT_0 {
+			// stopping: cancel the read and wait until the kernel is done with the buffer
+			_ = windows.CancelIoEx(w.dir, &w.ov)
+			var n uint32
+			_ = windows.GetOverlappedResult(w.dir, &w.ov, &n, true)
+			return
+		}
+		var n uint32
+		if err := windows.GetOverlappedResult(w.dir, &w.ov, &n, false); err != nil {
+			if err != windows.ERROR_NOTIFY_ENUM_DIR {
+				w.sendError(err)
+				return
+			}
+			n = 0
+		}
+		if n == 0 {
+			// the changes did not fit the buffer
+			w.sendError(fsnotify.ErrEventOverflow)
+			continue
+		}
+		if !w.parse(w.buf[:n]) {
+			return
+		}
+	}
+}
+
+// parse sends the events of a FILE_NOTIFY_INFORMATION list; it reports false once the
+// watcher is closed
+func (w *rdcwWatcher) parse(buf []byte) bool {
+	for off := 0; off < len(buf); {
+		info := (*windows.FileNotifyInformation)(unsafe.Pointer(&buf[off]))
+		name := windows.UTF16ToString(unsafe.Slice(&info.FileName, info.FileNameLength/2))
+		var op fsnotify.Op
+		switch info.Action {
+		case windows.FILE_ACTION_ADDED, windows.FILE_ACTION_RENAMED_NEW_NAME:
+			op = fsnotify.Create
+		case windows.FILE_ACTION_REMOVED:
+			op = fsnotify.Remove
+		case windows.FILE_ACTION_RENAMED_OLD_NAME:
+			op = fsnotify.Rename
+		default:
+			op = fsnotify.Write
+		}
+		select {
+		case w.events <- fsnotify.Event{Name: filepath.Join(w.root, name), Op: op}:
+		case <-w.quit:
+			return false
+		}
+		if info.NextEntryOffset == 0 {
+			break
+		}
+		off += int(info.NextEntryOffset)
+	}
+	return true
+}
+
+func (w *rdcwWatcher) sendError(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
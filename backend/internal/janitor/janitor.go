@@ -0,0 +1,300 @@
+// Package janitor removes the files the bridge leaves behind as they age: session
+// recordings and input recordings, session event logs, corrupted-history backups and
+// temporary files of processes that did not exit cleanly. Each kind of file has its own
+// age limit and disk quota.
+package janitor
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/rovobridge/internal/recording"
+)
+
+const (
+	// DefaultInterval is how often a started Janitor sweeps
+	DefaultInterval = time.Hour
+	// DefaultMaxAge is how long recordings, session logs and history backups are kept
+	DefaultMaxAge = 30 * 24 * time.Hour
+	// DefaultMaxBytes is the disk quota of each kind of file
+	DefaultMaxBytes = 1 << 30
+	// tempMaxAge is how long a temporary file may stay behind; the process that made it
+	// removes it long before unless it crashed
+	tempMaxAge = 24 * time.Hour
+	// activeGrace protects files written to recently, such as the recording of a running
+	// session, from quota sweeps
+	activeGrace = time.Minute
+)
+
+// Rule selects the files of one kind and the limits they are kept under
+type Rule struct {
+	Name  string
+	Dir   string
+	Match func(name string, isDir bool) bool // entries of Dir to consider
+	// MaxAge removes entries not modified for longer; 0 sets no age limit
+	MaxAge time.Duration
+	// MaxBytes removes the oldest entries until the rest fit; 0 sets no quota
+	MaxBytes int64
+}
+
+// Paths are the places the bridge writes to, as configured
+type Paths struct {
+	RecordDir      string // empty when recording is off
+	InputRecordDir string // empty when input recording is off
+	SessionLogDir  string
+	HistoryFile    string // the JSON history file, whose corrupted copies are kept beside it
+	TempDir        string // empty uses os.TempDir
+}
+
+// Rules returns the rules for the files of the bridge under paths, with maxAge and
+// maxBytes applied to each kind (0 turns the limit off). Temporary files always go
+// after a day.
+func Rules(paths Paths, maxAge time.Duration, maxBytes int64) []Rule {
+	var rules []Rule
+	if paths.RecordDir != "" {
+		rules = append(rules, Rule{Name: "recordings", Dir: paths.RecordDir, MaxAge: maxAge, MaxBytes: maxBytes,
+			Match: func(name string, isDir bool) bool { return !isDir && strings.HasSuffix(name, recording.Ext) }})
+	}
+	if paths.InputRecordDir != "" {
+		rules = append(rules, Rule{Name: "inputRecordings", Dir: paths.InputRecordDir, MaxAge: maxAge, MaxBytes: maxBytes,
+			Match: func(name string, isDir bool) bool { return !isDir && strings.HasSuffix(name, recording.InputExt) }})
+	}
+	if paths.SessionLogDir != "" {
+		rules = append(rules, Rule{Name: "sessionLogs", Dir: paths.SessionLogDir, MaxAge: maxAge, MaxBytes: maxBytes,
+			Match: func(name string, isDir bool) bool {
+				return !isDir && (strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, ".jsonl.1"))
+			}})
+	}
+	if paths.HistoryFile != "" {
+		prefix := filepath.Base(paths.HistoryFile) + ".corrupted."
+		rules = append(rules, Rule{Name: "historyBackups", Dir: filepath.Dir(paths.HistoryFile), MaxAge: maxAge, MaxBytes: maxBytes,
+			Match: func(name string, isDir bool) bool { return !isDir && strings.HasPrefix(name, prefix) }})
+	}
+	tmp := paths.TempDir
+	if tmp == "" {
+		tmp = os.TempDir()
+	}
+	rules = append(rules, Rule{Name: "tempFiles", Dir: tmp, MaxAge: tempMaxAge,
+		Match: func(name string, isDir bool) bool {
+			// Scratch git indexes of checkpoints, and the data directories of mock mode
+			return (!isDir && strings.HasPrefix(name, "rovobridge-index-")) || (isDir && strings.HasPrefix(name, "rovo-bridge-mock-"))
+		}})
+	return rules
+}
+
+// RuleReport is what a sweep found and removed for one rule
+type RuleReport struct {
+	Name           string   `json:"name"`
+	Dir            string   `json:"dir"`
+	Files          int      `json:"files" doc:"Entries kept"`
+	Bytes          int64    `json:"bytes" doc:"Size of the entries kept"`
+	Removed        int      `json:"removed"`
+	ReclaimedBytes int64    `json:"reclaimedBytes"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// Report is the outcome of a sweep
+type Report struct {
+	At             time.Time    `json:"at"`
+	DryRun         bool         `json:"dryRun,omitempty" doc:"Nothing was removed; removed and reclaimedBytes tell what would have been"`
+	Removed        int          `json:"removed"`
+	ReclaimedBytes int64        `json:"reclaimedBytes"`
+	Rules          []RuleReport `json:"rules"`
+}
+
+// entry is a file or directory a rule matched
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Sweep applies rules at now: entries older than their rule's MaxAge go first, then the
+// oldest until the rest fit in MaxBytes. With dryRun nothing is removed.
+func Sweep(rules []Rule, now time.Time, dryRun bool) Report {
+	rep := Report{At: now, DryRun: dryRun, Rules: make([]RuleReport, 0, len(rules))}
+	for _, rule := range rules {
+		rr := sweepRule(rule, now, dryRun)
+		rep.Removed += rr.Removed
+		rep.ReclaimedBytes += rr.ReclaimedBytes
+		rep.Rules = append(rep.Rules, rr)
+	}
+	return rep
+}
+
+func sweepRule(rule Rule, now time.Time, dryRun bool) RuleReport {
+	rr := RuleReport{Name: rule.Name, Dir: rule.Dir}
+	entries, err := matchEntries(rule)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			rr.Errors = append(rr.Errors, err.Error())
+		}
+		return rr
+	}
+	// Oldest first, so the quota removes the oldest
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	remove := func(e entry) {
+		if !dryRun {
+			if err := os.RemoveAll(e.path); err != nil {
+				rr.Errors = append(rr.Errors, err.Error())
+				return
+			}
+		}
+		rr.Removed++
+		rr.ReclaimedBytes += e.size
+		total -= e.size
+	}
+	for _, e := range entries {
+		age := now.Sub(e.modTime)
+		switch {
+		case rule.MaxAge > 0 && age > rule.MaxAge:
+			remove(e)
+		case rule.MaxBytes > 0 && total > rule.MaxBytes && age > activeGrace:
+			remove(e)
+		default:
+			rr.Files++
+			rr.Bytes += e.size
+		}
+	}
+	return rr
+}
+
+// matchEntries lists the entries of rule.Dir the rule matches, with directories sized
+// by their contents and dated by their newest file
+func matchEntries(rule Rule) ([]entry, error) {
+	dirents, err := os.ReadDir(rule.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	for _, d := range dirents {
+		if d.Type()&fs.ModeSymlink != 0 || !rule.Match(d.Name(), d.IsDir()) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		e := entry{path: filepath.Join(rule.Dir, d.Name()), size: info.Size(), modTime: info.ModTime()}
+		if d.IsDir() {
+			e.size = 0
+			_ = filepath.WalkDir(e.path, func(_ string, sub fs.DirEntry, err error) error {
+				if err != nil || sub.IsDir() {
+					return nil
+				}
+				if si, err := sub.Info(); err == nil {
+					e.size += si.Size()
+					if si.ModTime().After(e.modTime) {
+						e.modTime = si.ModTime()
+					}
+				}
+				return nil
+			})
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Stats are the totals of the sweeps of a Janitor since it started
+type Stats struct {
+	Sweeps         int       `json:"sweeps"`
+	Removed        int       `json:"removed"`
+	ReclaimedBytes int64     `json:"reclaimedBytes"`
+	LastSweep      *Report   `json:"lastSweep,omitempty"`
+	NextSweep      time.Time `json:"nextSweep"`
+}
+
+// Janitor sweeps its rules in the background: once when started, then every interval
+type Janitor struct {
+	rules    []Rule
+	interval time.Duration
+
+	mu    sync.Mutex
+	stats Stats
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// New creates a Janitor applying rules every interval (DefaultInterval when 0)
+func New(rules []Rule, interval time.Duration) *Janitor {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Janitor{rules: rules, interval: interval}
+}
+
+// Start runs the sweeps until Close
+func (j *Janitor) Start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.stop != nil {
+		return
+	}
+	j.stop, j.done = make(chan struct{}), make(chan struct{})
+	go j.run(j.stop, j.done)
+}
+
+func (j *Janitor) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.Sweep()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep applies the rules now and adds the outcome to the stats
+func (j *Janitor) Sweep() Report {
+	rep := Sweep(j.rules, time.Now(), false)
+	if rep.Removed > 0 {
+		log.Printf("janitor: removed %d files, reclaiming %d bytes", rep.Removed, rep.ReclaimedBytes)
+	}
+	for _, rr := range rep.Rules {
+		for _, e := range rr.Errors {
+			log.Printf("janitor: %s: %s", rr.Name, e)
+		}
+	}
+	j.mu.Lock()
+	j.stats.Sweeps++
+	j.stats.Removed += rep.Removed
+	j.stats.ReclaimedBytes += rep.ReclaimedBytes
+	j.stats.LastSweep = &rep
+	j.stats.NextSweep = rep.At.Add(j.interval)
+	j.mu.Unlock()
+	return rep
+}
+
+// Stats returns the totals of the sweeps so far
+func (j *Janitor) Stats() Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stats
+}
+
+// Close stops the sweeps and waits for one in progress
+func (j *Janitor) Close() {
+	j.mu.Lock()
+	stop, done := j.stop, j.done
+	j.stop = nil
+	j.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
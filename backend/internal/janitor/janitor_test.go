@@ -0,0 +1,110 @@
+package janitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAged writes size bytes to dir/name and dates it age ago
+func writeAged(t *testing.T, dir, name string, size int, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	when := time.Now().Add(-age)
+	if err := os.Chtimes(path, when, when); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestSweep_AgeAndQuota(t *testing.T) {
+	dir := t.TempDir()
+	old := writeAged(t, dir, "old.cast", 10, 40*24*time.Hour)
+	a := writeAged(t, dir, "a.cast", 100, 3*time.Hour)
+	b := writeAged(t, dir, "b.cast", 100, 2*time.Hour)
+	active := writeAged(t, dir, "active.cast", 100, 0)
+	other := writeAged(t, dir, "notes.txt", 1000, 400*24*time.Hour)
+
+	rules := Rules(Paths{RecordDir: dir, TempDir: t.TempDir()}, DefaultMaxAge, 150)
+	rep := Sweep(rules, time.Now(), false)
+
+	// old is past the age limit; a then b go for the quota, but the file written to just
+	// now stays even though it alone is over it
+	for _, p := range []string{old, a, b} {
+		if exists(p) {
+			t.Errorf("%s should have been removed", filepath.Base(p))
+		}
+	}
+	for _, p := range []string{active, other} {
+		if !exists(p) {
+			t.Errorf("%s should have been kept", filepath.Base(p))
+		}
+	}
+	rec := rep.Rules[0]
+	if rec.Name != "recordings" || rec.Removed != 3 || rec.ReclaimedBytes != 210 || rec.Files != 1 || rec.Bytes != 100 {
+		t.Fatalf("unexpected report: %+v", rec)
+	}
+	if rep.Removed != 3 || rep.ReclaimedBytes != 210 {
+		t.Fatalf("unexpected totals: %+v", rep)
+	}
+}
+
+func TestSweep_DryRunRemovesNothing(t *testing.T) {
+	dir := t.TempDir()
+	old := writeAged(t, dir, "s1.jsonl.1", 10, 40*24*time.Hour)
+	rep := Sweep(Rules(Paths{SessionLogDir: dir, TempDir: t.TempDir()}, DefaultMaxAge, 0), time.Now(), true)
+	if !exists(old) {
+		t.Fatal("dry run removed a file")
+	}
+	if !rep.DryRun || rep.Removed != 1 || rep.ReclaimedBytes != 10 {
+		t.Fatalf("expected the dry run to report the file: %+v", rep)
+	}
+}
+
+func TestSweep_HistoryBackupsAndTempFiles(t *testing.T) {
+	home, tmp := t.TempDir(), t.TempDir()
+	hist := filepath.Join(home, ".rovobridge")
+	writeAged(t, home, ".rovobridge", 10, 90*24*time.Hour)
+	corrupted := writeAged(t, home, ".rovobridge.corrupted.1700000000", 10, 90*24*time.Hour)
+	backup := writeAged(t, home, ".rovobridge.bak.00000000000000000001", 10, 90*24*time.Hour)
+	staleIndex := writeAged(t, tmp, "rovobridge-index-123", 10, 48*time.Hour)
+	freshIndex := writeAged(t, tmp, "rovobridge-index-456", 10, time.Hour)
+	mockFile := writeAged(t, tmp, "rovo-bridge-mock-1/history.json", 10, 48*time.Hour)
+	when := time.Now().Add(-48 * time.Hour)
+	_ = os.Chtimes(filepath.Dir(mockFile), when, when)
+
+	Sweep(Rules(Paths{HistoryFile: hist, TempDir: tmp}, DefaultMaxAge, 0), time.Now(), false)
+	if exists(corrupted) || exists(staleIndex) || exists(filepath.Dir(mockFile)) {
+		t.Fatal("expected the corrupted copy, the stale index and the mock directory to be removed")
+	}
+	// The history itself and its rolling backups are managed by the history package
+	if !exists(hist) || !exists(backup) || !exists(freshIndex) {
+		t.Fatal("removed a file outside the rules")
+	}
+}
+
+func TestJanitor_StatsAccumulate(t *testing.T) {
+	dir := t.TempDir()
+	writeAged(t, dir, "old.cast", 10, 40*24*time.Hour)
+	j := New(Rules(Paths{RecordDir: dir, TempDir: t.TempDir()}, DefaultMaxAge, 0), time.Hour)
+	j.Sweep()
+	writeAged(t, dir, "older.cast", 20, 50*24*time.Hour)
+	j.Sweep()
+	st := j.Stats()
+	if st.Sweeps != 2 || st.Removed != 2 || st.ReclaimedBytes != 30 || st.LastSweep == nil || st.LastSweep.Removed != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
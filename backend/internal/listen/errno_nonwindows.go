@@ -0,0 +1,13 @@
+//go:build !windows
+
+package listen
+
+import (
+	"errors"
+	"syscall"
+)
+
+func isAddrInUse(err error) bool { return errors.Is(err, syscall.EADDRINUSE) }
+
+// isAccessDenied reports whether the port is privileged
+func isAccessDenied(err error) bool { return errors.Is(err, syscall.EACCES) }
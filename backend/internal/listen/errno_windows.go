@@ -0,0 +1,21 @@
+//go:build windows
+
+package listen
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	wsaeacces     = syscall.Errno(10013)
+	wsaeaddrinuse = syscall.Errno(10048)
+)
+
+func isAddrInUse(err error) bool {
+	return errors.Is(err, wsaeaddrinuse) || errors.Is(err, syscall.EADDRINUSE)
+}
+
+// isAccessDenied reports whether the port is in a range Windows reserves, e.g. for
+// Hyper-V, which fails with WSAEACCES rather than WSAEADDRINUSE
+func isAccessDenied(err error) bool { return errors.Is(err, wsaeacces) }
@@ -0,0 +1,262 @@
+// Package listen opens the bridge's loopback listener on a fixed port, any free port or
+// the first free port of a range, and keeps the connection file that lets a restarted
+// bridge come back on the port it used before, so IDE plugins can reconnect to it.
+package listen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Error codes reported in Error.Code
+const (
+	CodeAddrInUse      = "addrInUse"          // the requested port is taken
+	CodeRangeExhausted = "portRangeExhausted" // every port of the range is taken
+	CodeBadAddress     = "badAddress"         // the address or range does not parse
+	CodeListenFailed   = "listenFailed"       // any other listen error
+)
+
+// Error is a listen failure with a machine-readable code, printed as JSON for IDE plugins
+type Error struct {
+	Code    string `json:"code"`
+	Addr    string `json:"addr,omitempty"`
+	Range   string `json:"range,omitempty"`
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// PortRange is an inclusive range of ports; the zero value is no range
+type PortRange struct {
+	First, Last int
+}
+
+// ParseRange parses "8700-8799", or a single port. An empty string is no range.
+func ParseRange(s string) (PortRange, error) {
+	if s == "" {
+		return PortRange{}, nil
+	}
+	first, last, found := strings.Cut(s, "-")
+	if !found {
+		last = first
+	}
+	a, err1 := strconv.Atoi(strings.TrimSpace(first))
+	b, err2 := strconv.Atoi(strings.TrimSpace(last))
+	if err1 != nil || err2 != nil || a < 1 || b > 65535 || a > b {
+		return PortRange{}, &Error{Code: CodeBadAddress, Range: s, Message: fmt.Sprintf("bad port range %q: want e.g. 8700-8799, within 1-65535", s)}
+	}
+	return PortRange{First: a, Last: b}, nil
+}
+
+// IsZero reports whether r is no range
+func (r PortRange) IsZero() bool { return r.First == 0 }
+
+// Contains reports whether port is in r
+func (r PortRange) Contains(port int) bool { return port >= r.First && port <= r.Last }
+
+func (r PortRange) String() string {
+	if r.First == r.Last {
+		return strconv.Itoa(r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// Family selects the loopback addresses the bridge binds
+type Family string
+
+const (
+	FamilyV4   Family = "v4"   // 127.0.0.1
+	FamilyV6   Family = "v6"   // ::1
+	FamilyDual Family = "dual" // both, on the same port
+)
+
+// ParseFamily parses a -bind-family value
+func ParseFamily(s string) (Family, error) {
+	switch f := Family(s); f {
+	case FamilyV4, FamilyV6, FamilyDual:
+		return f, nil
+	}
+	return "", &Error{Code: CodeBadAddress, Message: fmt.Sprintf("bad bind family %q: want v4, v6 or dual", s)}
+}
+
+// Hosts returns the loopback addresses f binds
+func (f Family) Hosts() []string {
+	switch f {
+	case FamilyV6:
+		return []string{"::1"}
+	case FamilyDual:
+		return []string{"127.0.0.1", "::1"}
+	}
+	return []string{"127.0.0.1"}
+}
+
+// maxDualAttempts bounds the ports tried when binding several hosts on any free port, in
+// case the port picked for the first host is taken on another
+const maxDualAttempts = 10
+
+// Listen listens on the same port on every one of hosts. An explicit port is used as is.
+// With port 0, the preferred port (usually the one of the previous run) is tried first
+// when it is set and within rng, then, with a range, each of its ports in turn, skipping
+// those in use; without a range any free port is taken.
+func Listen(hosts []string, port int, rng PortRange, preferred int) (net.Listener, error) {
+	if port != 0 {
+		return listenAll(hosts, port)
+	}
+	if preferred > 0 && (rng.IsZero() || rng.Contains(preferred)) {
+		ln, err := listenAll(hosts, preferred)
+		if err == nil {
+			return ln, nil
+		}
+		if !isUnavailable(err) {
+			return nil, err
+		}
+	}
+	if rng.IsZero() {
+		var err error
+		for i := 0; i < maxDualAttempts; i++ {
+			var ln net.Listener
+			if ln, err = listenAll(hosts, 0); err == nil || !isUnavailable(err) {
+				return ln, err
+			}
+		}
+		return nil, err
+	}
+	for p := rng.First; p <= rng.Last; p++ {
+		ln, err := listenAll(hosts, p)
+		if err == nil {
+			return ln, nil
+		}
+		if !isUnavailable(err) {
+			return nil, err
+		}
+	}
+	return nil, &Error{Code: CodeRangeExhausted, Addr: strings.Join(hosts, ","), Range: rng.String(), Message: fmt.Sprintf("no free port in range %s on %s", rng, strings.Join(hosts, ", "))}
+}
+
+// SplitAddr splits a -http address into its host and port
+func SplitAddr(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err == nil {
+		var port int
+		if port, err = strconv.Atoi(portStr); err == nil {
+			return host, port, nil
+		}
+	}
+	return "", 0, &Error{Code: CodeBadAddress, Addr: addr, Message: fmt.Sprintf("bad listen address %q: %v", addr, err), Err: err}
+}
+
+// listenAll listens on port on every host; with port 0 the first host picks it. If any
+// host fails, the listeners already open are closed.
+func listenAll(hosts []string, port int) (net.Listener, error) {
+	var lns []net.Listener
+	for _, h := range hosts {
+		ln, err := listenOn(net.JoinHostPort(h, strconv.Itoa(port)))
+		if err != nil {
+			for _, l := range lns {
+				l.Close()
+			}
+			return nil, err
+		}
+		lns = append(lns, ln)
+		port = ln.Addr().(*net.TCPAddr).Port
+	}
+	if len(lns) == 1 {
+		return lns[0], nil
+	}
+	return newMultiListener(lns), nil
+}
+
+// BaseURL returns the http URL of the listener, with IPv6 hosts in brackets. An
+// unspecified address is reported as 127.0.0.1.
+func BaseURL(addr net.Addr) string {
+	ta, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return "http://" + addr.String() + "/"
+	}
+	ip := ta.IP
+	if ip == nil || ip.IsUnspecified() {
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+	return "http://" + net.JoinHostPort(ip.String(), strconv.Itoa(ta.Port)) + "/"
+}
+
+// listenOn listens on addr, classifying the error
+func listenOn(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err == nil {
+		return ln, nil
+	}
+	code := CodeListenFailed
+	if isAddrInUse(err) {
+		code = CodeAddrInUse
+	}
+	return nil, &Error{Code: code, Addr: addr, Message: err.Error(), Err: err}
+}
+
+// isUnavailable reports whether a listen error means the port cannot be used and the
+// next one may be tried: it is taken, or reserved for another user or service
+func isUnavailable(err error) bool {
+	var le *Error
+	if errors.As(err, &le) && le.Code == CodeAddrInUse {
+		return true
+	}
+	return isAccessDenied(err)
+}
+
+// PreviousPort returns the port recorded in the connection file at path, or 0
+func PreviousPort(path string) int {
+	if path == "" {
+		return 0
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	var info struct {
+		Port int `json:"port"`
+	}
+	if json.Unmarshal(data, &info) != nil || info.Port < 1 || info.Port > 65535 {
+		return 0
+	}
+	return info.Port
+}
+
+// WriteConnFile writes the connection info to path as JSON. The info carries the auth
+// token, so the file is readable only by the user, and it is replaced atomically so
+// readers never see it half written.
+func WriteConnFile(path string, info any) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".conn-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,158 @@
+package listen
+
+import (
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+var loopback4 = FamilyV4.Hosts()
+
+func port(ln net.Listener) int { return ln.Addr().(*net.TCPAddr).Port }
+
+func TestParseRange(t *testing.T) {
+	if r, err := ParseRange("8700-8799"); err != nil || r != (PortRange{8700, 8799}) {
+		t.Fatalf("got %v, %v", r, err)
+	}
+	if r, err := ParseRange("8700"); err != nil || r != (PortRange{8700, 8700}) {
+		t.Fatalf("got %v, %v", r, err)
+	}
+	for _, bad := range []string{"x", "8799-8700", "0-10", "1-70000"} {
+		var le *Error
+		if _, err := ParseRange(bad); !errors.As(err, &le) || le.Code != CodeBadAddress {
+			t.Errorf("ParseRange(%q) = %v, want a badAddress error", bad, err)
+		}
+	}
+}
+
+func TestListen_SkipsPortsInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	p := port(taken)
+	if p == 65535 {
+		t.Skip("no room for a range after the taken port")
+	}
+
+	ln, err := Listen(loopback4, 0, PortRange{p, p + 1}, 0)
+	if err != nil {
+		// The next port may be taken by something else; that must be reported as such
+		var le *Error
+		if !errors.As(err, &le) || le.Code != CodeRangeExhausted {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return
+	}
+	defer ln.Close()
+	if port(ln) != p+1 {
+		t.Fatalf("listening on %d, want %d", port(ln), p+1)
+	}
+
+	var le *Error
+	if _, err := Listen(loopback4, 0, PortRange{p, p}, 0); !errors.As(err, &le) || le.Code != CodeRangeExhausted {
+		t.Fatalf("expected portRangeExhausted, got %v", err)
+	}
+	if _, err := Listen(loopback4, p, PortRange{}, 0); !errors.As(err, &le) || le.Code != CodeAddrInUse {
+		t.Fatalf("expected addrInUse for an explicit port, got %v", err)
+	}
+}
+
+func TestListen_ReusesPreviousPortWhenFree(t *testing.T) {
+	prev, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := port(prev)
+
+	// Taken: any free port instead
+	ln, err := Listen(loopback4, 0, PortRange{}, p)
+	if err != nil || port(ln) == p {
+		t.Fatalf("expected another port while %d is taken, got %v", p, err)
+	}
+	ln.Close()
+
+	prev.Close()
+	ln, err = Listen(loopback4, 0, PortRange{}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if port(ln) != p {
+		t.Fatalf("listening on %d, want the previous port %d", port(ln), p)
+	}
+}
+
+func TestConnFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "conn.json")
+	if PreviousPort(path) != 0 {
+		t.Fatal("expected no port without a file")
+	}
+	if err := WriteConnFile(path, map[string]any{"port": 8712, "token": "t"}); err != nil {
+		t.Fatal(err)
+	}
+	if p := PreviousPort(path); p != 8712 {
+		t.Fatalf("PreviousPort = %d, want 8712", p)
+	}
+}
+
+func TestListen_DualStackServesBothFamilies(t *testing.T) {
+	if ln, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skip("no IPv6 loopback:", err)
+	} else {
+		ln.Close()
+	}
+	ln, err := Listen(FamilyDual.Hosts(), 0, PortRange{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	p := strconv.Itoa(port(ln))
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = c.Write([]byte("hi"))
+			c.Close()
+		}
+	}()
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		c, err := net.Dial("tcp", net.JoinHostPort(host, p))
+		if err != nil {
+			t.Fatalf("dial %s: %v", host, err)
+		}
+		buf, _ := io.ReadAll(c)
+		c.Close()
+		if string(buf) != "hi" {
+			t.Fatalf("%s: got %q", host, buf)
+		}
+	}
+	if got, want := BaseURL(ln.Addr()), "http://127.0.0.1:"+p+"/"; got != want {
+		t.Fatalf("BaseURL = %q, want %q", got, want)
+	}
+	ln.Close()
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Accept after Close = %v", err)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	cases := map[string]*net.TCPAddr{
+		"http://[::1]:8700/":     {IP: net.IPv6loopback, Port: 8700},
+		"http://127.0.0.1:8700/": {IP: net.IPv4zero, Port: 8700},
+	}
+	for want, addr := range cases {
+		if got := BaseURL(addr); got != want {
+			t.Errorf("BaseURL(%v) = %q, want %q", addr, got, want)
+		}
+	}
+	if _, err := ParseFamily("v5"); err == nil {
+		t.Error("expected an unknown family to be rejected")
+	}
+}
@@ -0,0 +1,72 @@
+package listen
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// multiListener accepts connections from several listeners, e.g. the IPv4 and IPv6
+// loopback addresses of a dual-stack bind. Its address is that of the first.
+type multiListener struct {
+	lns   []net.Listener
+	conns chan accepted
+	done  chan struct{}
+	once  sync.Once
+}
+
+type accepted struct {
+	c   net.Conn
+	err error
+}
+
+func newMultiListener(lns []net.Listener) *multiListener {
+	m := &multiListener{lns: lns, conns: make(chan accepted), done: make(chan struct{})}
+	for _, ln := range lns {
+		go m.serve(ln)
+	}
+	return m
+}
+
+// serve accepts from ln until it is closed, handing connections and errors to Accept;
+// the caller decides which errors are worth retrying, as with a single listener
+func (m *multiListener) serve(ln net.Listener) {
+	for {
+		c, err := ln.Accept()
+		select {
+		case m.conns <- accepted{c, err}:
+		case <-m.done:
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+func (m *multiListener) Accept() (net.Conn, error) {
+	select {
+	case a := <-m.conns:
+		return a.c, a.err
+	case <-m.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (m *multiListener) Close() error {
+	var err error
+	m.once.Do(func() {
+		close(m.done)
+		for _, ln := range m.lns {
+			if e := ln.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
+
+func (m *multiListener) Addr() net.Addr { return m.lns[0].Addr() }
@@ -0,0 +1,133 @@
+// Package notify shows native desktop notifications for session events, e.g. when the
+// agent finishes a long run while the UI is hidden.
+package notify
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Event identifies what a notification is about
+type Event string
+
+const (
+	// EventIdle fires when a session goes quiet after producing output for a long time
+	EventIdle Event = "idle"
+	// EventExit fires when a session's process exits with a non-zero code
+	EventExit Event = "exit"
+)
+
+const (
+	// DefaultIdleAfter is how long output must pause before a run counts as finished
+	DefaultIdleAfter = 5 * time.Second
+	// DefaultMinRun is the shortest run that triggers an idle notification
+	DefaultMinRun = 30 * time.Second
+)
+
+// Config selects which events notify. Notifications are off until enabled.
+type Config struct {
+	Enabled   bool
+	Events    map[Event]bool
+	IdleAfter time.Duration
+	MinRun    time.Duration
+}
+
+// DefaultConfig returns a disabled config with all events selected
+func DefaultConfig() Config {
+	return Config{
+		Events:    map[Event]bool{EventIdle: true, EventExit: true},
+		IdleAfter: DefaultIdleAfter,
+		MinRun:    DefaultMinRun,
+	}
+}
+
+// Notifier sends notifications for the configured events. It is safe for concurrent use.
+type Notifier struct {
+	mu   sync.Mutex
+	cfg  Config
+	send func(title, body string) error
+}
+
+// New returns a notifier using the native notification mechanism of the OS
+func New() *Notifier {
+	return NewWithSender(Send)
+}
+
+// NewWithSender returns a notifier that shows notifications with send
+func NewWithSender(send func(title, body string) error) *Notifier {
+	return &Notifier{cfg: DefaultConfig(), send: send}
+}
+
+// SetConfig replaces the configuration; zero durations keep their defaults
+func (n *Notifier) SetConfig(cfg Config) {
+	if cfg.IdleAfter <= 0 {
+		cfg.IdleAfter = DefaultIdleAfter
+	}
+	if cfg.MinRun <= 0 {
+		cfg.MinRun = DefaultMinRun
+	}
+	n.mu.Lock()
+	n.cfg = cfg
+	n.mu.Unlock()
+}
+
+// Config returns the current configuration
+func (n *Notifier) Config() Config {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.cfg
+}
+
+// Wants reports whether ev would currently produce a notification
+func (n *Notifier) Wants(ev Event) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.cfg.Enabled && n.cfg.Events[ev]
+}
+
+// Notify shows a notification for ev if it is enabled. It does not block; failures
+// (e.g. notify-send missing) are logged.
+func (n *Notifier) Notify(ev Event, title, body string) {
+	if !n.Wants(ev) {
+		return
+	}
+	go func() {
+		if err := n.send(title, body); err != nil {
+			log.Printf("notification failed: %v", err)
+		}
+	}()
+}
+
+// Send shows a native notification: osascript on macOS, notify-send on Linux and a
+// PowerShell toast on Windows. Title and body are passed through the environment so
+// they never need quoting inside scripts.
+func Send(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", `display notification (system attribute "RB_NOTIFY_BODY") with title (system attribute "RB_NOTIFY_TITLE")`)
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", windowsToastScript)
+	default:
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return errors.New("notify-send not available")
+		}
+		cmd = exec.Command("notify-send", "--app-name=RovoBridge", title, body)
+	}
+	cmd.Env = append(os.Environ(), "RB_NOTIFY_TITLE="+title, "RB_NOTIFY_BODY="+body)
+	return cmd.Run()
+}
+
+const windowsToastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:RB_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:RB_NOTIFY_BODY)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('RovoBridge').Show([Windows.UI.Notifications.ToastNotification]::new($xml))
+`
@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotifier_RespectsConfig(t *testing.T) {
+	sent := make(chan string, 4)
+	n := NewWithSender(func(title, body string) error {
+		sent <- body
+		return nil
+	})
+
+	n.Notify(EventExit, "t", "disabled by default")
+	n.SetConfig(Config{Enabled: true, Events: map[Event]bool{EventExit: true}})
+	n.Notify(EventIdle, "t", "idle not selected")
+	n.Notify(EventExit, "t", "exit")
+
+	select {
+	case body := <-sent:
+		if body != "exit" {
+			t.Fatalf("unexpected notification %q", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected exit notification")
+	}
+	select {
+	case body := <-sent:
+		t.Fatalf("unexpected extra notification %q", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if cfg := n.Config(); cfg.IdleAfter != DefaultIdleAfter || cfg.MinRun != DefaultMinRun {
+		t.Errorf("expected default durations, got %+v", cfg)
+	}
+}
@@ -0,0 +1,29 @@
+// Package parentwatch tells when the process that spawned the bridge is gone, so a bridge
+// started by an IDE plugin can shut down instead of living on after the IDE crashed.
+package parentwatch
+
+import (
+	"fmt"
+	"time"
+)
+
+// pollInterval is how often platforms without an exit notification check the process
+const pollInterval = time.Second
+
+// Watch returns a channel closed once the process pid has exited. It fails when no
+// process pid is running.
+func Watch(pid int) (<-chan struct{}, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("invalid parent pid %d", pid)
+	}
+	wait, err := waiter(pid)
+	if err != nil {
+		return nil, fmt.Errorf("parent process %d: %w", pid, err)
+	}
+	gone := make(chan struct{})
+	go func() {
+		wait()
+		close(gone)
+	}()
+	return gone, nil
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package parentwatch
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// waiter returns a function blocking until pid exits. When pid is the parent of the
+// bridge, the bridge being handed to another parent is the signal, which a new process
+// reusing the pid cannot hide; otherwise pid is probed with signal 0.
+func waiter(pid int) (func(), error) {
+	if err := unix.Kill(pid, 0); err == unix.ESRCH {
+		return nil, err
+	}
+	isParent := os.Getppid() == pid
+	return func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if isParent && os.Getppid() != pid {
+				return
+			}
+			if err := unix.Kill(pid, 0); err == unix.ESRCH {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,54 @@
+package parentwatch
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is the process watched by the tests; it only waits to be killed
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("PARENTWATCH_HELPER") != "1" {
+		return
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func TestWatch_ClosesWhenProcessExits(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "PARENTWATCH_HELPER=1")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	gone, err := Watch(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-gone:
+		t.Fatal("reported gone while running")
+	case <-time.After(2 * pollInterval):
+	}
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	select {
+	case <-gone:
+	case <-time.After(5 * pollInterval):
+		t.Fatal("exit not noticed")
+	}
+}
+
+func TestWatch_RejectsMissingProcess(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Watch(cmd.Process.Pid); err == nil {
+		t.Fatal("expected an exited process to be rejected")
+	}
+	if _, err := Watch(0); err == nil {
+		t.Fatal("expected pid 0 to be rejected")
+	}
+}
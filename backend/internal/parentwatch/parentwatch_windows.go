@@ -0,0 +1,20 @@
+//go:build windows
+
+package parentwatch
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// waiter returns a function blocking until pid exits. It holds a handle to the process
+// from the start, so a new process reusing the pid is never mistaken for it.
+func waiter(pid int) (func(), error) {
+	h, err := windows.OpenProcess(windows.SYNCHRONIZE, false, uint32(pid))
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		defer windows.CloseHandle(h)
+		_, _ = windows.WaitForSingleObject(h, windows.INFINITE)
+	}, nil
+}
@@ -0,0 +1,155 @@
+// Package permission recognizes the tool-use permission prompts of the wrapped agent CLI
+// in plain-text terminal output, so the UI can answer them with buttons instead of the
+// user typing y/n into the terminal.
+package permission
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Choices a prompt can be answered with
+const (
+	Yes    = "yes"
+	No     = "no"
+	Always = "always" // yes, and do not ask again for this tool
+)
+
+// maxLines is how many trailing output lines a prompt may span, question included
+const maxLines = 8
+
+// Prompt is a permission prompt waiting for an answer at the end of the output
+type Prompt struct {
+	Tool     string   `json:"tool,omitempty"`   // tool asking, e.g. "bash", when the prompt names it
+	Detail   string   `json:"detail,omitempty"` // command or path the tool acts on, when quoted in the prompt
+	Question string   `json:"question"`
+	Choices  []string `json:"choices"` // of Yes, No and Always, in the order the prompt offers them
+
+	keys map[string]string
+}
+
+// Keys returns what to type into the terminal to answer the prompt with choice
+func (p Prompt) Keys(choice string) (string, bool) {
+	k, ok := p.keys[choice]
+	return k, ok
+}
+
+var (
+	// Do you want to allow this tool call? (y/n/a) / Run `npm test`? [yes/no]:
+	inlineRe = regexp.MustCompile(`(?i)^(.*\?)\s*[\[(]\s*(y|yes)\s*/\s*(n|no)(?:\s*/\s*(a|always))?\s*[\])]\s*:?$`)
+	// ❯ 1. Yes / 2) Yes, and don't ask again for this session / 3. No, tell the agent what to do
+	optionRe = regexp.MustCompile(`^\s*(?:[❯›>»▶*]\s*)?(\d)[.)]\s+(.+)$`)
+	// Only questions about running tools are answered on the user's behalf
+	askRe    = regexp.MustCompile(`(?i)\b(allow|permit|approve|permission|proceed|execute|run)\b`)
+	toolRe   = regexp.MustCompile("(?i)\\btool\\s+['\"`]?([\\w.-]+)")
+	detailRe = regexp.MustCompile("`([^`]+)`|'([^']+)'|\"([^\"]+)\"")
+	alwaysRe = regexp.MustCompile(`(?i)\b(always|don'?t ask|do not ask|for this session|remember)\b`)
+)
+
+// Detect recognizes a permission prompt at the end of lines, the recent plain-text output
+// oldest first; the last line may be the unterminated one the cursor is on. It reports
+// false unless the output ends with the prompt, so answered prompts are not reported.
+func Detect(lines []string) (Prompt, bool) {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return Prompt{}, false
+	}
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	if p, ok := detectInline(lines[len(lines)-1]); ok {
+		return p, true
+	}
+	return detectMenu(lines)
+}
+
+// detectInline recognizes a question answered by typing y, n or a
+func detectInline(line string) (Prompt, bool) {
+	sm := inlineRe.FindStringSubmatch(strings.TrimSpace(line))
+	if sm == nil || !askRe.MatchString(sm[1]) {
+		return Prompt{}, false
+	}
+	p := newPrompt(sm[1])
+	p.add(Yes, strings.ToLower(sm[2][:1])+"\r")
+	p.add(No, strings.ToLower(sm[3][:1])+"\r")
+	if sm[4] != "" {
+		p.add(Always, strings.ToLower(sm[4][:1])+"\r")
+	}
+	return p, true
+}
+
+// detectMenu recognizes a question followed by numbered options, selected by their number
+func detectMenu(lines []string) (Prompt, bool) {
+	// Options are the trailing run of numbered lines, the question the line before them
+	i := len(lines)
+	for i > 0 && optionRe.MatchString(lines[i-1]) {
+		i--
+	}
+	if i == 0 || i == len(lines) {
+		return Prompt{}, false
+	}
+	question := strings.TrimSpace(lines[i-1])
+	if !strings.HasSuffix(question, "?") || !askRe.MatchString(question) {
+		return Prompt{}, false
+	}
+	p := newPrompt(question)
+	for _, line := range lines[i:] {
+		sm := optionRe.FindStringSubmatch(line)
+		if choice := classify(sm[2]); choice != "" {
+			p.add(choice, sm[1])
+		}
+	}
+	if _, ok := p.keys[Yes]; !ok {
+		return Prompt{}, false
+	}
+	if _, ok := p.keys[No]; !ok {
+		return Prompt{}, false
+	}
+	return p, true
+}
+
+// classify maps the text of a menu option to a choice, or "" for options such as
+// "Edit the command" that have no button
+func classify(option string) string {
+	word := strings.ToLower(strings.TrimLeft(option, " \t"))
+	switch {
+	case strings.HasPrefix(word, "yes") || strings.HasPrefix(word, "allow") || strings.HasPrefix(word, "approve"):
+		if alwaysRe.MatchString(word) {
+			return Always
+		}
+		return Yes
+	case strings.HasPrefix(word, "always"):
+		return Always
+	case strings.HasPrefix(word, "no") || strings.HasPrefix(word, "deny") || strings.HasPrefix(word, "reject"):
+		return No
+	}
+	return ""
+}
+
+func newPrompt(question string) Prompt {
+	p := Prompt{Question: strings.TrimSpace(question), keys: map[string]string{}}
+	if sm := toolRe.FindStringSubmatch(p.Question); sm != nil {
+		p.Tool = sm[1]
+	}
+	if sm := detailRe.FindStringSubmatch(p.Question); sm != nil {
+		p.Detail = sm[1] + sm[2] + sm[3]
+		if p.Detail == p.Tool {
+			p.Detail = ""
+			if all := detailRe.FindAllStringSubmatch(p.Question, 2); len(all) == 2 {
+				p.Detail = all[1][1] + all[1][2] + all[1][3]
+			}
+		}
+	}
+	return p
+}
+
+// add offers choice, answered by typing keys; the first option of a choice wins
+func (p *Prompt) add(choice, keys string) {
+	if _, ok := p.keys[choice]; ok {
+		return
+	}
+	p.keys[choice] = keys
+	p.Choices = append(p.Choices, choice)
+}
@@ -0,0 +1,57 @@
+package permission
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		lines   []string
+		tool    string
+		detail  string
+		choices []string
+		keys    map[string]string
+	}{
+		{[]string{"Running tests", "Do you want to allow tool 'bash' to run `npm test`? (y/n/a)"},
+			"bash", "npm test", []string{Yes, No, Always}, map[string]string{Yes: "y\r", No: "n\r", Always: "a\r"}},
+		{[]string{"Allow the agent to delete build/? [yes/no]: "},
+			"", "", []string{Yes, No}, map[string]string{Yes: "y\r", No: "n\r"}},
+		{[]string{
+			"╭─ bash ─────────────╮",
+			"Allow tool \"open_files\" to read \"src/app.ts\"?",
+			"❯ 1. Yes",
+			"  2. Yes, and don't ask again for this session",
+			"  3. Edit the request",
+			"  4. No, tell the agent what to do differently",
+			"",
+		}, "open_files", "src/app.ts", []string{Yes, Always, No}, map[string]string{Yes: "1", Always: "2", No: "4"}},
+	}
+	for _, c := range cases {
+		p, ok := Detect(c.lines)
+		if !ok {
+			t.Errorf("Detect(%q) did not match", c.lines)
+			continue
+		}
+		if p.Tool != c.tool || p.Detail != c.detail || !slices.Equal(p.Choices, c.choices) {
+			t.Errorf("Detect(%q) = %+v", c.lines, p)
+		}
+		for choice, want := range c.keys {
+			if got, _ := p.Keys(choice); got != want {
+				t.Errorf("Detect(%q) keys for %s = %q, want %q", c.lines, choice, got, want)
+			}
+		}
+	}
+
+	for _, lines := range [][]string{
+		nil,
+		{"Are you sure? (y/n)"}, // not about running a tool
+		{"Allow tool 'bash'? (y/n) y", "Running npm test"},       // answered, output went on
+		{"Which file should I run?", "1. main.go", "2. util.go"}, // a menu without yes/no
+		{"1. Yes", "2. No"}, // options without a question
+	} {
+		if p, ok := Detect(lines); ok {
+			t.Errorf("Detect(%q) = %+v, want no prompt", lines, p)
+		}
+	}
+}
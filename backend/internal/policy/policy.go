@@ -0,0 +1,193 @@
+// Package policy restricts which executables the bridge may launch. The bridge port is
+// reachable by any local process holding the token, so a policy file lets users pin the
+// commands that openSession and updateSessionConfig may run.
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName is the policy file looked up in the user's home directory
+const FileName = ".rovobridge-policy.json"
+
+// hashPrefix marks allow/deny entries that match the SHA-256 of the executable
+const hashPrefix = "sha256:"
+
+// Policy is an allow/deny list of executables. Entries are globs matched against the
+// resolved path and the base name of the executable, or "sha256:<hex>" digests of its
+// content. Deny entries win; a non-empty allow list rejects everything it does not match.
+type Policy struct {
+	Allow    []string `json:"allow"`
+	Deny     []string `json:"deny"`
+	AuditLog string   `json:"auditLog"` // JSON lines of rejected launches; empty logs only to stderr
+
+	mu sync.Mutex // serializes audit log writes
+}
+
+// Violation is the error returned for a rejected command
+type Violation struct {
+	Command string
+	Path    string
+	Reason  string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("command %q is not permitted by policy: %s", v.Command, v.Reason)
+}
+
+// DefaultPath returns the policy file in the user's home directory
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return FileName
+	}
+	return filepath.Join(home, FileName)
+}
+
+// Load reads a policy file. A missing file yields a nil policy, which permits everything.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, pattern := range append(append([]string(nil), p.Allow...), p.Deny...) {
+		if strings.HasPrefix(pattern, hashPrefix) {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("parse %s: bad pattern %q: %w", path, pattern, err)
+		}
+	}
+	return &p, nil
+}
+
+// Resolve returns the absolute path of the executable cmd starts in dir: a name without
+// a separator is looked up in PATH, a relative path is taken from dir (the bridge's working
+// directory when empty), as the session's process would. cmd is returned as is when it
+// cannot be found.
+func Resolve(cmd, dir string) string {
+	if dir != "" && !filepath.IsAbs(cmd) && strings.ContainsAny(cmd, "/"+string(filepath.Separator)) {
+		cmd = filepath.Join(dir, cmd)
+	}
+	resolved, err := exec.LookPath(cmd)
+	if err != nil {
+		return cmd
+	}
+	if abs, err := filepath.Abs(resolved); err == nil {
+		return abs
+	}
+	return resolved
+}
+
+// Check reports whether cmd may be launched for action (e.g. "openSession"). A relative
+// cmd is resolved against the bridge's working directory, so callers launching it elsewhere
+// pass the result of Resolve. Rejections are audited before they are returned.
+func (p *Policy) Check(action, cmd string) error {
+	if p == nil {
+		return nil
+	}
+	path := Resolve(cmd, "")
+	h := &hasher{path: path}
+	var reason string
+	switch {
+	case strings.TrimSpace(cmd) == "":
+		reason = "empty command"
+	case matchAny(p.Deny, path, h):
+		reason = "matches the deny list"
+	case len(p.Allow) > 0 && !matchAny(p.Allow, path, h):
+		reason = "not on the allow list"
+	default:
+		return nil
+	}
+	v := &Violation{Command: cmd, Path: path, Reason: reason}
+	p.audit(action, v)
+	return v
+}
+
+func matchAny(patterns []string, path string, h *hasher) bool {
+	for _, pattern := range patterns {
+		if digest, ok := strings.CutPrefix(pattern, hashPrefix); ok {
+			if sum := h.sum(); sum != "" && strings.EqualFold(sum, digest) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasher computes the digest of an executable at most once, and only when a hash entry
+// needs it
+type hasher struct {
+	path string
+	done bool
+	hex  string
+}
+
+func (h *hasher) sum() string {
+	if h.done {
+		return h.hex
+	}
+	h.done = true
+	f, err := os.Open(h.path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	d := sha256.New()
+	if _, err := io.Copy(d, f); err != nil {
+		return ""
+	}
+	h.hex = hex.EncodeToString(d.Sum(nil))
+	return h.hex
+}
+
+func (p *Policy) audit(action string, v *Violation) {
+	log.Printf("policy: rejected %s of %q (%s): %s", action, v.Command, v.Path, v.Reason)
+	if p.AuditLog == "" {
+		return
+	}
+	line, _ := json.Marshal(map[string]any{
+		"time":    time.Now().UTC().Format(time.RFC3339),
+		"action":  action,
+		"command": v.Command,
+		"path":    v.Path,
+		"reason":  v.Reason,
+	})
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, err := os.OpenFile(p.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("policy: audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("policy: audit log: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeExecutable(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoad_MissingFilePermitsEverything(t *testing.T) {
+	p, err := Load(filepath.Join(t.TempDir(), FileName))
+	if err != nil || p != nil {
+		t.Fatalf("expected nil policy for a missing file, got %v, %v", p, err)
+	}
+	if err := p.Check("openSession", "anything"); err != nil {
+		t.Fatalf("expected nil policy to permit, got %v", err)
+	}
+}
+
+func TestLoad_RejectsBadPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(`{"allow": ["[a-"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected an error for a malformed glob")
+	}
+}
+
+func TestCheck_AllowDenyAndHashes(t *testing.T) {
+	dir := t.TempDir()
+	agent := writeExecutable(t, dir, "agent", "#!/bin/sh\necho agent\n")
+	other := writeExecutable(t, dir, "other", "#!/bin/sh\necho other\n")
+	shell := writeExecutable(t, dir, "sh-wrapper", "#!/bin/sh\nexec sh\n")
+	sum := sha256.Sum256([]byte("#!/bin/sh\necho other\n"))
+
+	audit := filepath.Join(dir, "audit.log")
+	p := &Policy{
+		Allow:    []string{"agent", filepath.Join(dir, "sh-*"), "sha256:" + hex.EncodeToString(sum[:])},
+		Deny:     []string{"*wrapper"},
+		AuditLog: audit,
+	}
+	for _, tc := range []struct {
+		cmd    string
+		reason string // empty when permitted
+	}{
+		{agent, ""},                      // base name on the allow list
+		{other, ""},                      // content hash on the allow list
+		{shell, "matches the deny list"}, // allowed by glob, but deny wins
+		{"/bin/does-not-exist", "not on the allow list"},
+		{"", "empty command"},
+	} {
+		err := p.Check("openSession", tc.cmd)
+		var v *Violation
+		switch {
+		case tc.reason == "" && err != nil:
+			t.Errorf("%q: expected to be permitted, got %v", tc.cmd, err)
+		case tc.reason != "" && (!errors.As(err, &v) || v.Reason != tc.reason):
+			t.Errorf("%q: expected violation %q, got %v", tc.cmd, tc.reason, err)
+		}
+	}
+
+	data, err := os.ReadFile(audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected one audit line per violation, got %q", data)
+	}
+	var entry map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["action"] != "openSession" || entry["command"] != shell || entry["reason"] != "matches the deny list" {
+		t.Errorf("unexpected audit entry: %v", entry)
+	}
+}
+
+func TestResolve_RelativeCommandsFromDir(t *testing.T) {
+	dir := t.TempDir()
+	agent := writeExecutable(t, dir, "agent", "#!/bin/sh\n")
+	rel := "." + string(filepath.Separator) + "agent"
+	if got := Resolve(rel, dir); got != agent {
+		t.Fatalf("expected %q, got %q", agent, got)
+	}
+	// The bridge's working directory holds no such file
+	p := &Policy{Allow: []string{agent}}
+	if err := p.Check("openSession", rel); err == nil {
+		t.Fatal("expected the unresolved command to be rejected")
+	}
+	if err := p.Check("openSession", Resolve(rel, dir)); err != nil {
+		t.Fatalf("expected the resolved command to be permitted, got %v", err)
+	}
+}
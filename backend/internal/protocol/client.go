@@ -0,0 +1,527 @@
+package protocol
+
+// Messages sent by clients (the web UI and IDE plugins) to the bridge. Every message also
+// carries its type, e.g. { type: "openSession", ... }.
+
+// HelloFeatures are the optional protocol features a client supports
+type HelloFeatures struct {
+	Batch         bool     `json:"batch,omitempty" doc:"Accept JSON arrays of messages in one frame"`
+	Compression   []string `json:"compression,omitempty" doc:"Snapshot encodings the client can decode, preferred first, e.g. [\"zstd\", \"gzip\"]"`
+	Channels      bool     `json:"channels,omitempty" doc:"Tag messages with their channel; control then overtakes queued output, and stream and bulk need channelCredit"`
+	ChannelWindow int      `json:"channelWindow,omitempty" doc:"Bytes stream and bulk may each send before credit is granted (default 1 MiB)"`
+}
+
+type HelloRequest struct {
+	Client   string         `json:"client,omitempty" doc:"\"ide\" for IDE plugins, which receive openInEditor requests"`
+	Features *HelloFeatures `json:"features,omitempty"`
+}
+
+type SearchIndexRequest struct {
+	Pattern string   `json:"pattern"`
+	Opened  []string `json:"opened,omitempty" doc:"Paths open in the editor, ranked separately"`
+	Limit   int      `json:"limit,omitempty"`
+	Profile string   `json:"profile,omitempty"`
+}
+
+type ExportIndexRequest struct{}
+
+type SubscribeIndexRequest struct {
+	Prefix string `json:"prefix,omitempty" doc:"Directory relative to the index root whose entries to follow; the whole index when empty"`
+	Limit  int    `json:"limit,omitempty" doc:"Most entries to accept, 20000 by default; more is an error"`
+}
+
+type UnsubscribeIndexRequest struct{}
+
+type ReplaceInFilesRequest struct {
+	Pattern       string   `json:"pattern" doc:"Literal text, or a regular expression with regex"`
+	Replacement   string   `json:"replacement" doc:"With regex, $1 and ${name} insert submatches"`
+	Regex         bool     `json:"regex,omitempty"`
+	CaseSensitive bool     `json:"caseSensitive,omitempty"`
+	Include       []string `json:"include,omitempty" doc:"Globs of the files to change, matched against the root-relative path and the base name"`
+	Exclude       []string `json:"exclude,omitempty" doc:"Globs of files to leave alone"`
+	DryRun        bool     `json:"dryRun,omitempty" doc:"Report the changes with diffs without writing; otherwise the edit needs confirmation"`
+}
+
+type GetIndexStatusRequest struct{}
+
+type NormalizePathsRequest struct {
+	Paths []string `json:"paths" doc:"Absolute, root-relative or ~ paths, with either separator"`
+}
+
+type ResolveReferencesRequest struct {
+	SerializedContent string `json:"serializedContent" doc:"Prompt content with <[#path][display]> chips"`
+}
+
+type SelectContextRequest struct {
+	Text   string `json:"text"`
+	Budget int    `json:"budget,omitempty" doc:"Token budget for the proposed files"`
+}
+
+type UpdateInjectionSettingsRequest struct {
+	Preamble        *string `json:"preamble,omitempty"`
+	PreambleEnabled *bool   `json:"preambleEnabled,omitempty"`
+}
+
+type UpdateNotificationsRequest struct {
+	Enabled     bool     `json:"enabled"`
+	Events      []string `json:"events,omitempty" doc:"\"idle\" and/or \"exit\""`
+	IdleAfterMs int      `json:"idleAfterMs,omitempty"`
+	MinRunMs    int      `json:"minRunMs,omitempty"`
+}
+
+type SetClipHistoryRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type SetGitCheckpointsRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type ListCheckpointsRequest struct {
+	SessionID string `json:"sessionId"`
+}
+
+type RestoreCheckpointRequest struct {
+	SessionID    string `json:"sessionId"`
+	CheckpointID string `json:"checkpointId"`
+}
+
+type SetPromptPrefixRequest struct {
+	SessionID string `json:"sessionId"`
+	Enabled   *bool  `json:"enabled,omitempty" doc:"Turn the workspace prompt prefix on or off for the session; without it the prefix is only reported"`
+}
+
+type ListTasksRequest struct {
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+type RunTaskRequest struct {
+	SessionID string `json:"sessionId"`
+	Name      string `json:"name"`
+}
+
+type CancelTaskRequest struct {
+	SessionID string `json:"sessionId"`
+}
+
+type ListPortsRequest struct{}
+
+type OpenProxyRequest struct {
+	Port int `json:"port"`
+}
+
+type TailFileRequest struct {
+	SessionID string `json:"sessionId,omitempty" doc:"Session whose working directory relative paths resolve against"`
+	Path      string `json:"path"`
+	Lines     int    `json:"lines,omitempty" doc:"Last lines sent first"`
+}
+
+type StopTailRequest struct {
+	TailID string `json:"tailId"`
+}
+
+type DiagnosticsRequest struct{}
+
+type UsageStatsRequest struct {
+	Days int `json:"days,omitempty" doc:"Days covered, up to today; 7 by default, at most 366"`
+}
+
+type CheckUpdateRequest struct{}
+
+type TransferSessionRequest struct {
+	SessionID string `json:"sessionId"`
+	ToEditor  bool   `json:"toEditor,omitempty" doc:"Also offer the session to connected IDE plugins"`
+}
+
+type ClaimSessionRequest struct {
+	Token string `json:"token"`
+}
+
+type BroadcastSendRequest struct {
+	SendOptions
+	SessionIDs []string          `json:"sessionIds"`
+	DataBase64 string            `json:"dataBase64,omitempty"`
+	Contexts   map[string]string `json:"contexts,omitempty" doc:"Extra text appended to the prompt of each session, by session id"`
+	Tag        string            `json:"tag,omitempty"`
+}
+
+type GetStatsRequest struct{}
+
+type DashboardRequest struct{}
+
+type ListClipsRequest struct{}
+
+type RegisterSnippetRequest struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+type RemoveSnippetRequest struct {
+	Name string `json:"name" doc:"Snippet name or path"`
+}
+
+type ListSnippetsRequest struct{}
+
+type OpenInEditorRequest struct {
+	SessionID string `json:"sessionId,omitempty"`
+	Path      string `json:"path"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+}
+
+// EditorSelection is the selected text in the IDE
+type EditorSelection struct {
+	Text      string `json:"text,omitempty"`
+	StartLine int    `json:"startLine,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+}
+
+// EditorCursor is the caret position in the IDE
+type EditorCursor struct {
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+type SetEditorContextRequest struct {
+	Workspace string           `json:"workspace,omitempty"`
+	File      string           `json:"file,omitempty"`
+	Selection *EditorSelection `json:"selection,omitempty"`
+	Cursor    *EditorCursor    `json:"cursor,omitempty"`
+}
+
+type UpdateSessionConfigRequest struct {
+	CustomCommand  *string `json:"customCommand,omitempty" doc:"Command run by new sessions; empty restores the default"`
+	OutputEncoding *string `json:"outputEncoding,omitempty" doc:"Code page of new sessions' output, such as cp1252 or cp437; empty restores UTF-8. Needs no confirmation."`
+}
+
+type ConfirmRequest struct {
+	Token    string `json:"token"`
+	Approved bool   `json:"approved,omitempty"`
+}
+
+type RespondPermissionRequest struct {
+	SessionID string `json:"sessionId"`
+	RequestID string `json:"requestId"`
+	Choice    string `json:"choice" doc:"yes, no or always; one of the prompt's choices"`
+}
+
+type ReloadAgentRequest struct {
+	SessionID    string `json:"sessionId"`
+	TailLines    int    `json:"tailLines,omitempty" doc:"Output lines of the transcript sent back (default 200, at most 500)"`
+	Reinject     bool   `json:"reinject,omitempty" doc:"Send a condensed summary of the transcript as the first prompt once the new process is ready"`
+	SummaryLines int    `json:"summaryLines,omitempty" doc:"Lines of the condensed summary (default 40)"`
+}
+
+type OpenSessionRequest struct {
+	ID           string   `json:"id,omitempty" doc:"Session alias, \"s1\" by default; the session id is generated by the server"`
+	Cmd          string   `json:"cmd,omitempty"`
+	Args         []string `json:"args,omitempty"`
+	Cwd          string   `json:"cwd,omitempty"`
+	Env          []string `json:"env,omitempty" doc:"KEY=VALUE pairs"`
+	Pty          *bool    `json:"pty,omitempty"`
+	Resume       bool     `json:"resume,omitempty" doc:"Attach to the running session instead of starting a new one"`
+	Cols         int      `json:"cols,omitempty"`
+	Rows         int      `json:"rows,omitempty"`
+	UseClipboard *bool    `json:"useClipboard,omitempty"`
+	HistoryLimit int      `json:"historyLimit,omitempty" doc:"Newest prompt history entries sent with opened, 50 by default; queryHistory pages the rest"`
+	// startup readiness
+	ReadyPattern   string `json:"readyPattern,omitempty" doc:"Regular expression matching the agent's input prompt in plain-text output lines, trailing spaces removed; the session is ready when one matches"`
+	ReadyIdleMs    int    `json:"readyIdleMs,omitempty" doc:"Quiet time after startup output that makes the session ready, 1500 by default"`
+	SuppressBanner bool   `json:"suppressBanner,omitempty" doc:"Withhold output printed before the session is ready from stdout and snapshots"`
+	StallAfterMs   int    `json:"stallAfterMs,omitempty" doc:"Silence after a submitted prompt that gets the session probed and, without an answer, reported with sessionStalled; 0 turns the check off"`
+	StallProbe     string `json:"stallProbe,omitempty" enum:"winch,write,none" doc:"How a silent session is probed, winch by default"`
+	Label          string `json:"label,omitempty" doc:"A name for the session in the dashboard"`
+	PromptPrefix   *bool  `json:"promptPrefix,omitempty" doc:"false leaves out the promptPrefix of .rovobridge/settings.json from the session's sends"`
+	OutputEncoding string `json:"outputEncoding,omitempty" doc:"Code page the process writes and reads, such as cp1252, cp437 or shift_jis; output is transcoded to UTF-8 and input back. Defaults to the one set with updateSessionConfig."`
+	PlainText      bool   `json:"plainText,omitempty" doc:"Send output as lines of plain text instead of stdout; sets TERM=dumb and NO_COLOR=1 and runs without a PTY unless pty is set"`
+	DowngradeTerm  *bool  `json:"downgradeTerm,omitempty" doc:"Start with TERM=xterm-256color when env sets a TERM the bridge does not know; defaults to --downgrade-term"`
+}
+
+// HistoryEntryInput is a prompt history entry as the UI sends it
+type HistoryEntryInput struct {
+	ID                string `json:"id,omitempty"`
+	Title             string `json:"title,omitempty"`
+	SerializedContent string `json:"serializedContent"`
+	Agent             string `json:"agent,omitempty"`
+	Model             string `json:"model,omitempty"`
+}
+
+type StdinRequest struct {
+	SessionID    string             `json:"sessionId"`
+	DataBase64   string             `json:"dataBase64"`
+	HistoryEntry *HistoryEntryInput `json:"historyEntry,omitempty"`
+}
+
+type ResizeRequest struct {
+	SessionID string `json:"sessionId"`
+	Cols      int    `json:"cols"`
+	Rows      int    `json:"rows"`
+}
+
+// InjectOptions tune how injected files are read and formatted
+type InjectOptions struct {
+	ElideDuplicates        bool   `json:"elideDuplicates,omitempty"`
+	NormalizeLineEndings   bool   `json:"normalizeLineEndings,omitempty"`
+	StripBOM               bool   `json:"stripBOM,omitempty"`
+	TrimTrailingWhitespace bool   `json:"trimTrailingWhitespace,omitempty"`
+	TabWidth               int    `json:"tabWidth,omitempty"`
+	ControlChars           string `json:"controlChars,omitempty" enum:"escape,strip,keep"`
+	Preamble               string `json:"preamble,omitempty"`
+	NoPreamble             bool   `json:"noPreamble,omitempty"`
+	RawNotebooks           bool   `json:"rawNotebooks,omitempty"`
+	FullTabular            bool   `json:"fullTabular,omitempty"`
+	TimeoutMs              int    `json:"timeoutMs,omitempty" doc:"Bound on reading each file"`
+	Concurrency            int    `json:"concurrency,omitempty" doc:"Files read in parallel"`
+}
+
+type InjectFilesRequest struct {
+	SessionID string         `json:"sessionId"`
+	Paths     []string       `json:"paths"`
+	Options   *InjectOptions `json:"options,omitempty"`
+}
+
+type SnapshotRequest struct {
+	SessionID string `json:"sessionId"`
+}
+
+type FontSizeChangedRequest struct {
+	FontSize int `json:"fontSize"`
+}
+
+type UpdateUseClipboardRequest struct {
+	SessionID    string `json:"sessionId"`
+	UseClipboard bool   `json:"useClipboard"`
+}
+
+type SavePromptRequest struct {
+	SessionID    string            `json:"sessionId,omitempty"`
+	HistoryEntry HistoryEntryInput `json:"historyEntry"`
+}
+
+type SaveDraftRequest struct {
+	SessionID         string `json:"sessionId,omitempty"`
+	SerializedContent string `json:"serializedContent" doc:"Empty content clears the draft"`
+}
+
+type LoadDraftRequest struct {
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+type AddNoteRequest struct {
+	SessionID string `json:"sessionId"`
+	Text      string `json:"text,omitempty"`
+	Offset    *int64 `json:"offset,omitempty" doc:"Stream offset the note is attached to; the end of the output by default"`
+	Bookmark  bool   `json:"bookmark,omitempty"`
+}
+
+type RemoveNoteRequest struct {
+	SessionID string `json:"sessionId"`
+	NoteID    string `json:"noteId"`
+}
+
+type ListNotesRequest struct {
+	SessionID string `json:"sessionId"`
+}
+
+type ListCodeBlocksRequest struct {
+	SessionID string `json:"sessionId"`
+	Language  string `json:"language,omitempty" doc:"Only blocks in this language"`
+	AfterID   int    `json:"afterId,omitempty" doc:"Only blocks printed after the block with this id"`
+}
+
+type ApplyCodeBlockRequest struct {
+	SessionID string `json:"sessionId"`
+	BlockID   int    `json:"blockId"`
+	Path      string `json:"path" doc:"Target file inside the session's working directory; created when missing"`
+	StartLine int    `json:"startLine,omitempty" doc:"First line (1-based) replaced by the block; the whole file when omitted"`
+	EndLine   int    `json:"endLine,omitempty" doc:"Last line replaced; below startLine the block is inserted before startLine"`
+	DryRun    bool   `json:"dryRun,omitempty" doc:"Only send the preview; otherwise the edit is made after confirmation"`
+}
+
+type ReadFileRequest struct {
+	SessionID string `json:"sessionId,omitempty" doc:"Session whose working directory path is resolved against; the bridge's without it"`
+	Path      string `json:"path"`
+}
+
+type SaveFileRequest struct {
+	SessionID string `json:"sessionId,omitempty"`
+	Path      string `json:"path" doc:"File inside the working directory"`
+	Content   string `json:"content"`
+	StartLine int    `json:"startLine,omitempty" doc:"First line (1-based) content replaces; the whole file when omitted"`
+	EndLine   int    `json:"endLine,omitempty" doc:"Last line replaced; below startLine content is inserted before startLine"`
+	BaseHash  string `json:"baseHash,omitempty" doc:"hash from fileContent; the save fails with fileConflict if the file no longer has it"`
+	BaseMtime int64  `json:"baseMtime,omitempty" doc:"mtime from fileContent, checked like baseHash"`
+	Create    bool   `json:"create,omitempty" doc:"Create a new file; fails with fileConflict if it exists"`
+}
+
+type SetRedactionRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type ResolveTimeRequest struct {
+	SessionID string `json:"sessionId"`
+	Time      any    `json:"time" doc:"Unix milliseconds or an RFC 3339 string"`
+}
+
+type CreateCheckpointRequest struct {
+	SessionID string   `json:"sessionId"`
+	Prompt    string   `json:"prompt,omitempty"`
+	Paths     []string `json:"paths,omitempty"`
+}
+
+type DiffSinceCheckpointRequest struct {
+	SessionID    string `json:"sessionId"`
+	CheckpointID string `json:"checkpointId,omitempty" doc:"The latest checkpoint by default"`
+}
+
+type SessionDiffRequest struct {
+	SessionID string `json:"sessionId"`
+}
+
+type SuggestPromptsRequest struct {
+	Text      string   `json:"text"`
+	Paths     []string `json:"paths,omitempty"`
+	Limit     int      `json:"limit,omitempty"`
+	SessionID string   `json:"sessionId,omitempty"`
+}
+
+type QueryHistoryByPathRequest struct {
+	Path      string `json:"path"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+type QueryHistoryRequest struct {
+	SessionID string `json:"sessionId,omitempty" doc:"Includes the prompt library of the session's workspace"`
+	Offset    int    `json:"offset,omitempty" doc:"Index of the first entry, counted from the oldest"`
+	Limit     int    `json:"limit,omitempty" doc:"100 by default, at most 500"`
+}
+
+// RenameInput is a file or directory moved from From to To
+type RenameInput struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type RewriteHistoryPathsRequest struct {
+	Renames []RenameInput `json:"renames,omitempty" doc:"Paths absolute or relative to the index root; without renames the moves seen by the index since the last rewrite apply"`
+	DryRun  bool          `json:"dryRun,omitempty" doc:"Report the entries that would change without storing them"`
+}
+
+type SetHistoryRenameRewriteRequest struct {
+	Enabled bool `json:"enabled" doc:"Rewrite the history as soon as the index sees files move"`
+}
+
+type ChannelCreditRequest struct {
+	Ch    int   `json:"ch" doc:"The flow-controlled channel: 1 stream or 2 bulk"`
+	Bytes int64 `json:"bytes" doc:"Bytes of the channel the client consumed"`
+}
+
+type SaveProjectPromptRequest struct {
+	SessionID    string            `json:"sessionId,omitempty"`
+	HistoryEntry HistoryEntryInput `json:"historyEntry"`
+}
+
+type RemoveProjectPromptRequest struct {
+	SessionID string `json:"sessionId,omitempty"`
+	PromptID  string `json:"promptId"`
+}
+
+type RemovePromptRequest struct {
+	PromptID string `json:"promptId"`
+}
+
+// SendOptions are the fields send shares with broadcastSend
+type SendOptions struct {
+	Paths            []string           `json:"paths,omitempty" doc:"Files injected with the prompt"`
+	HistoryEntry     *HistoryEntryInput `json:"historyEntry,omitempty"`
+	InjectOutputTail int                `json:"injectOutputTail,omitempty" doc:"Append the session's last N output lines"`
+	InjectTaskResult bool               `json:"injectTaskResult,omitempty" doc:"Append the outcome of the session's last runTask"`
+	Options          *InjectOptions     `json:"options,omitempty"`
+}
+
+type SendRequest struct {
+	SendOptions
+	SessionID        string `json:"sessionId"`
+	DataBase64       string `json:"dataBase64,omitempty"`
+	LintAcknowledged bool   `json:"lintAcknowledged,omitempty" doc:"Send despite the warnings of promptLint"`
+	ComposedAt       int64  `json:"composedAt,omitempty" doc:"Unix ms when the prompt was written; paths modified since are reported stale"`
+}
+
+// ClientMessages lists every message the bridge accepts, in the order of the Router's
+// message switch
+var ClientMessages = []Message{
+	{"hello", HelloRequest{}, "Identifies the client and negotiates features (answered with welcome)"},
+	{"searchIndex", SearchIndexRequest{}, "Searches the file index (answered with searchResult)"},
+	{"exportIndex", ExportIndexRequest{}, "Requests the full file index (answered with indexExport)"},
+	{"subscribeIndex", SubscribeIndexRequest{}, "Requests the file index, or a directory of it, and its changes (answered with indexSubscribed, then indexDelta)"},
+	{"unsubscribeIndex", UnsubscribeIndexRequest{}, "Stops the changes of subscribeIndex (answered with indexUnsubscribed)"},
+	{"replaceInFiles", ReplaceInFilesRequest{}, "Replaces text in the indexed files; needs confirmation unless dryRun (answered with replaceResult)"},
+	{"getIndexStatus", GetIndexStatusRequest{}, "Asks whether the index root is present and watched (answered with indexStatus)"},
+	{"normalizePaths", NormalizePathsRequest{}, "Resolves user-provided paths against the index root (answered with normalizedPaths)"},
+	{"resolveReferences", ResolveReferencesRequest{}, "Checks the file chips of serialized prompt content against the index and finds moved files (answered with resolvedReferences)"},
+	{"selectContext", SelectContextRequest{}, "Proposes files to inject for a prompt (answered with contextSelection)"},
+	{"updateInjectionSettings", UpdateInjectionSettingsRequest{}, "Sets the preamble written before injected files"},
+	{"updateNotifications", UpdateNotificationsRequest{}, "Configures desktop notifications for session events"},
+	{"setClipHistory", SetClipHistoryRequest{}, "Turns the clipboard history on or off (answered with clips)"},
+	{"setGitCheckpoints", SetGitCheckpointsRequest{}, "Turns git checkpoints before each send on or off (answered with gitCheckpoints)"},
+	{"listCheckpoints", ListCheckpointsRequest{}, "Lists the git checkpoints of a session (answered with checkpointList)"},
+	{"restoreCheckpoint", RestoreCheckpointRequest{}, "Restores a git checkpoint; needs confirmation (answered with checkpointRestored)"},
+	{"setPromptPrefix", SetPromptPrefixRequest{}, "Turns the workspace prompt prefix on or off for a session (answered with promptPrefix)"},
+	{"listTasks", ListTasksRequest{}, "Lists the project tasks (answered with tasks)"},
+	{"runTask", RunTaskRequest{}, "Runs a project task (answered with taskStarted, then taskResult)"},
+	{"cancelTask", CancelTaskRequest{}, "Cancels the running task of a session"},
+	{"listPorts", ListPortsRequest{}, "Lists the detected dev server ports (answered with ports)"},
+	{"openProxy", OpenProxyRequest{}, "Returns a one-time proxy URL for a detected port (answered with proxyUrl)"},
+	{"tailFile", TailFileRequest{}, "Follows a file (answered with tailStarted and tailLines)"},
+	{"stopTail", StopTailRequest{}, "Stops following a file (answered with tailStopped)"},
+	{"diagnostics", DiagnosticsRequest{}, "Runs the environment checks (answered with diagnosticsReport)"},
+	{"checkUpdate", CheckUpdateRequest{}, "Checks for a newer release (answered with updateInfo)"},
+	{"transferSession", TransferSessionRequest{}, "Offers the control of a session to another client (answered with transferOffered)"},
+	{"claimSession", ClaimSessionRequest{}, "Takes over a session offered with transferSession"},
+	{"broadcastSend", BroadcastSendRequest{}, "Sends one prompt to several sessions (answered with broadcastStarted)"},
+	{"getStats", GetStatsRequest{}, "Requests connection and session counters (answered with stats)"},
+	{"dashboard", DashboardRequest{}, "Requests an overview of every session in one message (answered with dashboard)"},
+	{"usageStats", UsageStatsRequest{}, "Requests the token, request and cost totals per session and per day (answered with usage)"},
+	{"listClips", ListClipsRequest{}, "Lists the clipboard history (answered with clips)"},
+	{"registerSnippet", RegisterSnippetRequest{}, "Registers an in-memory file injectable by its path (answered with snippetRegistered)"},
+	{"removeSnippet", RemoveSnippetRequest{}, "Removes a snippet (answered with snippets)"},
+	{"listSnippets", ListSnippetsRequest{}, "Lists the snippets (answered with snippets)"},
+	{"openInEditor", OpenInEditorRequest{}, "Asks connected IDE plugins to open a file"},
+	{"setEditorContext", SetEditorContextRequest{}, "Pushes the IDE's current file and selection for prompt placeholders"},
+	{"updateSessionConfig", UpdateSessionConfigRequest{}, "Changes the command of new sessions, which needs confirmation, or their output encoding (answered with sessionConfigUpdated)"},
+	{"confirm", ConfirmRequest{}, "Answers a confirmationRequired challenge"},
+	{"respondPermission", RespondPermissionRequest{}, "Answers a permissionRequest by typing the choice into the session (answered with permissionResolved)"},
+	{"reloadAgent", ReloadAgentRequest{}, "Restarts the command of a session with the same options, carrying its transcript over (answered with opened, then agentReloaded)"},
+	{"openSession", OpenSessionRequest{}, "Starts or resumes a session (answered with opened)"},
+	{"stdin", StdinRequest{}, "Writes input to a session"},
+	{"resize", ResizeRequest{}, "Resizes the terminal of a session"},
+	{"injectFiles", InjectFilesRequest{}, "Injects files into a session (answered with injectResult)"},
+	{"snapshot", SnapshotRequest{}, "Requests the recent output of a session (answered with snapshot)"},
+	{"fontSizeChanged", FontSizeChangedRequest{}, "Reports the UI font size, served at /font-size"},
+	{"updateUseClipboard", UpdateUseClipboardRequest{}, "Sets whether files are injected through the clipboard"},
+	{"savePrompt", SavePromptRequest{}, "Saves a prompt history entry (answered with promptSaved)"},
+	{"saveDraft", SaveDraftRequest{}, "Saves the unsent prompt (answered with draftSaved)"},
+	{"loadDraft", LoadDraftRequest{}, "Loads the unsent prompt (answered with draft)"},
+	{"addNote", AddNoteRequest{}, "Attaches a note or bookmark to the output (answered with noteAdded)"},
+	{"removeNote", RemoveNoteRequest{}, "Removes a note (answered with noteRemoved)"},
+	{"listNotes", ListNotesRequest{}, "Lists the notes of a session (answered with notes)"},
+	{"listCodeBlocks", ListCodeBlocksRequest{}, "Lists the fenced code blocks in a session's output (answered with codeBlocks)"},
+	{"applyCodeBlock", ApplyCodeBlockRequest{}, "Previews (codeBlockPreview) and, once confirmed, makes (codeBlockApplied) the edit writing a code block to a file"},
+	{"readFile", ReadFileRequest{}, "Reads a text file for the quick editor (answered with fileContent)"},
+	{"saveFile", SaveFileRequest{}, "Writes a file read with readFile if no one changed it since, once confirmed (answered with fileSaved)"},
+	{"setRedaction", SetRedactionRequest{}, "Turns secret masking on or off; off needs confirmation (answered with redaction)"},
+	{"resolveTime", ResolveTimeRequest{}, "Finds the output position at a time (answered with timeResolved)"},
+	{"createCheckpoint", CreateCheckpointRequest{}, "Records file digests and the output position (answered with checkpointCreated)"},
+	{"diffSinceCheckpoint", DiffSinceCheckpointRequest{}, "Lists files changed since a checkpoint (answered with checkpointDiff)"},
+	{"sessionDiff", SessionDiffRequest{}, "Lists files changed since the session started (answered with sessionDiff)"},
+	{"suggestPrompts", SuggestPromptsRequest{}, "Finds similar history prompts (answered with promptSuggestions)"},
+	{"queryHistoryByPath", QueryHistoryByPathRequest{}, "Finds prompts that referenced a file (answered with historyByPath)"},
+	{"rewriteHistoryPaths", RewriteHistoryPathsRequest{}, "Points the file chips of stored prompts at the new place of renamed or moved files (answered with historyPathsRewritten)"},
+	{"setHistoryRenameRewrite", SetHistoryRenameRewriteRequest{}, "Turns the automatic history rewrite on file moves on or off (answered with historyRenameRewrite)"},
+	{"channelCredit", ChannelCreditRequest{}, "Lets the bridge send more bytes on a channel negotiated in hello (not answered)"},
+	{"queryHistory", QueryHistoryRequest{}, "Reads a page of the prompt history (answered with historyPage)"},
+	{"saveProjectPrompt", SaveProjectPromptRequest{}, "Saves a prompt to the workspace library (answered with projectPromptSaved)"},
+	{"removeProjectPrompt", RemoveProjectPromptRequest{}, "Removes a prompt from the workspace library (answered with projectPromptRemoved)"},
+	{"removePrompt", RemovePromptRequest{}, "Removes a prompt from the history (answered with promptRemoved)"},
+	{"send", SendRequest{}, "Sends a prompt with files and a history entry (answered with injectResult, or promptLint when held back)"},
+}
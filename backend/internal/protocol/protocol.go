@@ -0,0 +1,207 @@
+// Package protocol describes the WebSocket messages and REST payloads of the bridge as Go
+// types and generates JSON Schema and OpenAPI documents from them, so the web UI and IDE
+// plugins can generate and validate their code against the backend.
+package protocol
+
+import (
+	"reflect"
+
+	"github.com/example/rovobridge/internal/index"
+	"github.com/example/rovobridge/internal/recording"
+	"github.com/example/rovobridge/internal/ws"
+)
+
+// Message describes one WebSocket message type
+type Message struct {
+	Type   string // value of the type field
+	Fields any    // zero value of the struct holding the other fields
+	Doc    string
+}
+
+// SchemaID identifies the JSON Schema document
+const SchemaID = "https://rovobridge.local/schema/protocol.json"
+
+// messageSchema returns the schema of a message: the fields of m.Fields plus its type
+func (g *generator) messageSchema(m Message) map[string]any {
+	s := g.object(reflect.TypeOf(m.Fields))
+	s["properties"].(map[string]any)["type"] = map[string]any{"const": m.Type}
+	s["required"] = append([]any{"type"}, requiredOf(s)...)
+	if m.Doc != "" {
+		s["description"] = m.Doc
+	}
+	return s
+}
+
+func requiredOf(s map[string]any) []any {
+	r, _ := s["required"].([]any)
+	return r
+}
+
+// messageDefs adds the definitions of msgs under their type names prefixed by prefix
+// and returns references to them in order
+func (g *generator) messageDefs(prefix string, msgs []Message) []any {
+	refs := make([]any, 0, len(msgs))
+	for _, m := range msgs {
+		name := prefix + upperFirst(m.Type)
+		g.defs[name] = g.messageSchema(m)
+		refs = append(refs, map[string]any{"$ref": g.refPrefix + name})
+	}
+	return refs
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
+
+// Schema returns a JSON Schema (draft 2020-12) document with a definition per message
+// type, named ClientXxx and ServerXxx, and ClientMessage and ServerMessage definitions
+// accepting any message of each direction
+func Schema() map[string]any {
+	g := newGenerator("#/$defs/")
+	client := g.messageDefs("Client", ClientMessages)
+	server := g.messageDefs("Server", ServerMessages)
+	for _, m := range ServerMessages {
+		// Connections that negotiated channels in hello get every message tagged with its channel
+		g.defs["Server"+upperFirst(m.Type)].(map[string]any)["properties"].(map[string]any)["ch"] = map[string]any{
+			"type": "integer", "minimum": 0, "maximum": 2, "description": "Channel of the message: 0 control, 1 stream, 2 bulk",
+		}
+	}
+	g.defs["ClientMessage"] = map[string]any{"oneOf": client}
+	g.defs["ServerMessage"] = map[string]any{"oneOf": server}
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     SchemaID,
+		"title":   "rovo-bridge WebSocket protocol",
+		"$defs":   g.defs,
+		"anyOf": []any{
+			map[string]any{"$ref": "#/$defs/ClientMessage"},
+			map[string]any{"$ref": "#/$defs/ServerMessage"},
+		},
+	}
+}
+
+// REST payloads
+
+type FontSize struct {
+	FontSize int `json:"fontSize" doc:"Font size last reported by the UI, 0 if unchanged since the last request"`
+}
+
+type IndexDocument struct {
+	Root    string              `json:"root"`
+	Count   int                 `json:"count"`
+	Entries []index.ExportEntry `json:"entries"`
+}
+
+type RecordingList struct {
+	Recordings []recording.Info `json:"recordings"`
+}
+
+// endpoint describes one REST endpoint for OpenAPI
+type endpoint struct {
+	path, summary string
+	public        bool           // served without the bearer token
+	params        []any          // OpenAPI parameter objects
+	responses     map[string]any // by status code
+}
+
+func jsonResponse(g *generator, desc string, v any) map[string]any {
+	return map[string]any{
+		"description": desc,
+		"content":     map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(v))}},
+	}
+}
+
+func textResponse(desc, mediaType string) map[string]any {
+	return map[string]any{
+		"description": desc,
+		"content":     map[string]any{mediaType: map[string]any{"schema": map[string]any{"type": "string"}}},
+	}
+}
+
+func queryParam(name, desc string, schema map[string]any) map[string]any {
+	return map[string]any{"name": name, "in": "query", "description": desc, "schema": schema}
+}
+
+// OpenAPI returns an OpenAPI 3.1 document of the REST endpoints. All but /health take
+// the token from the connection JSON as a bearer token.
+func OpenAPI() map[string]any {
+	g := newGenerator("#/components/schemas/")
+	forbidden := map[string]any{"description": "Missing or wrong bearer token"}
+	endpoints := []endpoint{
+		{path: "/health", summary: "Liveness check", public: true, responses: map[string]any{
+			"200": textResponse("The bridge is up; the body is ok", "text/plain"),
+		}},
+		{path: "/font-size", summary: "Font size last reported by the UI", responses: map[string]any{
+			"200": jsonResponse(g, "The font size", FontSize{}),
+		}},
+		{path: "/index", summary: "Export the gitignore-aware file index", params: []any{
+			queryParam("format", "ndjson streams one entry per line", map[string]any{"type": "string", "enum": []any{"json", "ndjson"}}),
+		}, responses: map[string]any{
+			"200": map[string]any{
+				"description": "The index",
+				"content": map[string]any{
+					"application/json":     map[string]any{"schema": g.schema(reflect.TypeOf(IndexDocument{}))},
+					"application/x-ndjson": map[string]any{"schema": map[string]any{"type": "string"}},
+				},
+			},
+		}},
+		{path: "/recordings", summary: "List the session recordings", responses: map[string]any{
+			"200": jsonResponse(g, "The recordings, newest first", RecordingList{}),
+		}},
+		{path: "/recordings/{name}", summary: "Stream a recording as asciicast v2", params: []any{
+			map[string]any{"name": "name", "in": "path", "required": true, "schema": map[string]any{"type": "string"}},
+			queryParam("seek", "Seconds to start at", map[string]any{"type": "number"}),
+			queryParam("speed", "Factor playback is sped up by", map[string]any{"type": "number"}),
+		}, responses: map[string]any{
+			"200": textResponse("The recording", "application/x-asciicast"),
+			"404": map[string]any{"description": "Recording is disabled or there is no such recording"},
+		}},
+		{path: "/metrics", summary: "Session and connection counters; advertised as metricsUrl in the connection JSON", responses: map[string]any{
+			"200": jsonResponse(g, "The counters", ws.Metrics{}),
+		}},
+		{path: "/crash-report", summary: "The last panic recovered from a message handler; served with -crash-report-endpoint", responses: map[string]any{
+			"200": jsonResponse(g, "The crash report", ws.CrashReport{}),
+			"204": map[string]any{"description": "Nothing crashed"},
+		}},
+		{path: "/schema", summary: "JSON Schema of the WebSocket messages", responses: map[string]any{
+			"200": map[string]any{"description": "The JSON Schema document", "content": map[string]any{"application/schema+json": map[string]any{}}},
+		}},
+		{path: "/schema/openapi.json", summary: "This document", responses: map[string]any{
+			"200": map[string]any{"description": "The OpenAPI document", "content": map[string]any{"application/json": map[string]any{}}},
+		}},
+		{path: "/proxy/{port}/{path}", summary: "Preview a dev server detected in session output, on the preview listener that openProxy URLs point to; also accepts the proxy cookie and tickets from openProxy", params: []any{
+			map[string]any{"name": "port", "in": "path", "required": true, "schema": map[string]any{"type": "integer"}},
+			map[string]any{"name": "path", "in": "path", "required": true, "schema": map[string]any{"type": "string"}},
+		}, responses: map[string]any{
+			"200": map[string]any{"description": "The dev server's response"},
+			"404": map[string]any{"description": "No dev server was detected on the port"},
+			"502": map[string]any{"description": "The dev server is not reachable"},
+		}},
+	}
+	paths := map[string]any{}
+	for _, e := range endpoints {
+		op := map[string]any{"summary": e.summary, "responses": e.responses}
+		if e.public {
+			op["security"] = []any{}
+		} else {
+			e.responses["403"] = forbidden
+		}
+		if len(e.params) > 0 {
+			op["parameters"] = e.params
+		}
+		paths[e.path] = map[string]any{"get": op}
+	}
+	return map[string]any{
+		"openapi":  "3.1.0",
+		"info":     map[string]any{"title": "rovo-bridge", "version": "1"},
+		"security": []any{map[string]any{"bearer": []any{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"securitySchemes": map[string]any{"bearer": map[string]any{"type": "http", "scheme": "bearer"}},
+			"schemas":         g.defs,
+		},
+	}
+}
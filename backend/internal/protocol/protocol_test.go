@@ -0,0 +1,184 @@
+package protocol
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// routerMessageTypes returns the message types the Router's message switch handles
+func routerMessageTypes(t *testing.T) []string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), "../ws/router.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		sw, ok := n.(*ast.SwitchStmt)
+		if !ok {
+			return true
+		}
+		if key, ok := indexKey(sw.Tag); !ok || key != "type" {
+			return true
+		}
+		for _, stmt := range sw.Body.List {
+			for _, e := range stmt.(*ast.CaseClause).List {
+				if lit, ok := e.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					s, _ := strconv.Unquote(lit.Value)
+					types = append(types, s)
+				}
+			}
+		}
+		return false
+	})
+	sort.Strings(types)
+	return types
+}
+
+// indexKey returns k for expressions of the form m["k"]
+func indexKey(e ast.Expr) (string, bool) {
+	idx, ok := e.(*ast.IndexExpr)
+	if !ok {
+		return "", false
+	}
+	lit, ok := idx.Index.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+func TestClientMessages_MatchRouter(t *testing.T) {
+	want := routerMessageTypes(t)
+	if len(want) == 0 {
+		t.Fatal("found no message switch in router.go")
+	}
+	var got []string
+	for _, m := range ClientMessages {
+		got = append(got, m.Type)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ClientMessages do not match the router:\n got  %v\n want %v", got, want)
+	}
+}
+
+// refs collects the $ref values in a document
+func refs(v any, out *[]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if s, ok := e.(string); ok && k == "$ref" {
+				*out = append(*out, s)
+			}
+			refs(e, out)
+		}
+	case []any:
+		for _, e := range v {
+			refs(e, out)
+		}
+	}
+}
+
+func TestSchema_ReferencesResolve(t *testing.T) {
+	for name, doc := range map[string]map[string]any{"schema": Schema(), "openapi": OpenAPI()} {
+		// Round trip through JSON, as clients see it
+		buf, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var parsed map[string]any
+		if err := json.Unmarshal(buf, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		var all []string
+		refs(parsed, &all)
+		if len(all) == 0 {
+			t.Fatalf("%s has no references", name)
+		}
+		for _, ref := range all {
+			node := any(parsed)
+			for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+				m, _ := node.(map[string]any)
+				node = m[part]
+			}
+			if node == nil {
+				t.Errorf("%s: unresolved %s", name, ref)
+			}
+		}
+	}
+}
+
+func TestSchema_DescribesMessages(t *testing.T) {
+	defs := Schema()["$defs"].(map[string]any)
+	opened := defs["ServerOpened"].(map[string]any)
+	req := opened["required"].([]any)
+	if req[0] != "type" || !contains(req, "promptHistory") || contains(req, "notes") {
+		t.Fatalf("opened required = %v", req)
+	}
+	id := opened["properties"].(map[string]any)["id"].(map[string]any)
+	if typ, _ := id["type"].([]any); len(typ) != 2 || typ[1] != "null" {
+		t.Fatalf("nullable id = %v", id)
+	}
+	if _, ok := defs["HistoryPromptHistoryEntry"]; !ok {
+		t.Fatal("missing definition of history.PromptHistoryEntry")
+	}
+	if n := len(defs["ClientMessage"].(map[string]any)["oneOf"].([]any)); n != len(ClientMessages) {
+		t.Fatalf("ClientMessage has %d alternatives, want %d", n, len(ClientMessages))
+	}
+}
+
+func TestValidateMessage(t *testing.T) {
+	for _, tc := range []struct {
+		dir  Direction
+		msg  string
+		want string // substring of the error; empty for valid messages
+	}{
+		{FromClient, `{"type":"hello"}`, ""},
+		{FromClient, `{"type":"openSession","id":"s1","pty":false,"args":["-v"]}`, ""},
+		{FromClient, `{"type":"stdin","sessionId":"s1","dataBase64":"aGkK"}`, ""},
+		{FromClient, `{"type":"stdin","sessionId":"s1"}`, "missing dataBase64"},
+		{FromClient, `{"type":"resize","sessionId":"s1","cols":80.5,"rows":24}`, "resize.cols"},
+		{FromClient, `{"type":"hello","bogus":1}`, "unexpected field bogus"},
+		{FromClient, `{"type":"nope"}`, "unknown client message type"},
+		{FromClient, `{"type":"send","sessionId":"s1","paths":["a.go"],"injectOutputTail":20}`, ""},
+		{FromServer, `{"type":"opened","id":null,"sessionId":"s1","pid":3,"resumed":false,"promptHistory":[]}`, ""},
+		{FromServer, `{"type":"error","message":"x","code":"bogus"}`, "is not one of"},
+		{FromServer, `{"type":"stats","sessions":1,"stdinRejectedBytes":0,"connections":{"clients":1}}`, "stats.connections: missing"},
+		{FromServer, `{"type":"hello"}`, "unknown server message type"},
+	} {
+		err := ValidateMessage(tc.dir, []byte(tc.msg))
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%s: %v", tc.msg, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%s: err = %v, want %q", tc.msg, err, tc.want)
+		}
+	}
+}
+
+func TestClientMessages_EncodeValid(t *testing.T) {
+	// encoding/json's output for every request struct must pass its own schema
+	for _, m := range ClientMessages {
+		buf, err := json.Marshal(m.Fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := `{"type":` + strconv.Quote(m.Type)
+		if len(buf) > 2 {
+			msg += "," + string(buf[1:])
+		} else {
+			msg += "}"
+		}
+		if err := ValidateMessage(FromClient, []byte(msg)); err != nil {
+			t.Errorf("%s: %v", msg, err)
+		}
+	}
+}
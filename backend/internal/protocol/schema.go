@@ -0,0 +1,174 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// generator derives JSON Schema (draft 2020-12) from Go types the way encoding/json
+// encodes them. Named struct types become definitions referenced with refPrefix.
+type generator struct {
+	refPrefix string
+	defs      map[string]any
+	names     map[reflect.Type]string
+}
+
+func newGenerator(refPrefix string) *generator {
+	return &generator{refPrefix: refPrefix, defs: map[string]any{}, names: map[reflect.Type]string{}}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// defName names the definition of a struct type. Types of other packages carry the
+// package name, e.g. HistoryPromptHistoryEntry, so names never collide.
+func defName(t reflect.Type) string {
+	name := t.Name()
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	if pkg == "protocol" {
+		return name
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+// ref returns a reference to the definition of the named struct type t, generating it
+// on first use
+func (g *generator) ref(t reflect.Type) map[string]any {
+	name, ok := g.names[t]
+	if !ok {
+		name = defName(t)
+		g.names[t] = name
+		g.defs[name] = true // placeholder, so recursive types terminate
+		g.defs[name] = g.object(t)
+	}
+	return map[string]any{"$ref": g.refPrefix + name}
+}
+
+// schema returns the schema of values of type t
+func (g *generator) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.ref(t)
+	}
+	// Interfaces carry any JSON value
+	return map[string]any{}
+}
+
+// nullable reports whether encoding/json may write null for a value of type t
+func nullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	case reflect.Map, reflect.Pointer:
+		return true
+	}
+	return false
+}
+
+// orNull widens s to also accept null
+func orNull(s map[string]any) map[string]any {
+	if typ, ok := s["type"].(string); ok {
+		out := make(map[string]any, len(s))
+		for k, v := range s {
+			out[k] = v
+		}
+		out["type"] = []any{typ, "null"}
+		return out
+	}
+	return map[string]any{"anyOf": []any{s, map[string]any{"type": "null"}}}
+}
+
+// object returns the schema of a struct: its exported fields by their json names, with
+// fields not tagged omitempty required. The doc tag describes a field and the enum tag
+// lists its allowed values, comma separated.
+func (g *generator) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []any
+	g.fields(t, props, &required)
+	s := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *generator) fields(t reflect.Type, props map[string]any, required *[]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		omitempty := strings.Contains(opts, "omitempty")
+		s := g.schema(f.Type)
+		if !omitempty && nullable(f.Type) {
+			s = orNull(s)
+		}
+		if doc := f.Tag.Get("doc"); doc != "" || f.Tag.Get("enum") != "" {
+			s = withAnnotations(s, doc, f.Tag.Get("enum"))
+		}
+		props[name] = s
+		if !omitempty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// withAnnotations adds a description and allowed values to a copy of s
+func withAnnotations(s map[string]any, doc, enum string) map[string]any {
+	out := make(map[string]any, len(s)+2)
+	for k, v := range s {
+		out[k] = v
+	}
+	if doc != "" {
+		out["description"] = doc
+	}
+	if enum != "" {
+		var values []any
+		for _, v := range strings.Split(enum, ",") {
+			values = append(values, v)
+		}
+		out["enum"] = values
+	}
+	return out
+}
@@ -0,0 +1,777 @@
+package protocol
+
+import (
+	"github.com/example/rovobridge/internal/diagnostics"
+	"github.com/example/rovobridge/internal/doctor"
+	"github.com/example/rovobridge/internal/gitcheckpoint"
+	"github.com/example/rovobridge/internal/history"
+	"github.com/example/rovobridge/internal/index"
+	"github.com/example/rovobridge/internal/janitor"
+	"github.com/example/rovobridge/internal/permission"
+	"github.com/example/rovobridge/internal/tasks"
+	"github.com/example/rovobridge/internal/usage"
+	"github.com/example/rovobridge/internal/ws"
+)
+
+// Messages sent by the bridge to clients. Every message also carries its type.
+
+// Features are the protocol features of the bridge
+type Features struct {
+	Streaming     bool           `json:"streaming"`
+	Pty           bool           `json:"pty"`
+	Batch         bool           `json:"batch,omitempty" doc:"Batched frames were negotiated in hello"`
+	Compression   string         `json:"compression,omitempty" enum:"gzip" doc:"The snapshot encoding picked from the compression list of hello"`
+	Channels      map[string]int `json:"channels,omitempty" doc:"Channel IDs by name, when channels were negotiated in hello"`
+	ChannelWindow int            `json:"channelWindow,omitempty" doc:"The credit window granted to stream and bulk"`
+}
+
+// SessionConfig is the command new sessions run
+type SessionConfig struct {
+	Cmd  string   `json:"cmd"`
+	Args []string `json:"args"`
+	Pty  bool     `json:"pty"`
+	Env  []string `json:"env"`
+	// OutputEncoding is the code page of new sessions' output, when not UTF-8
+	OutputEncoding string `json:"outputEncoding,omitempty"`
+}
+
+type Welcome struct {
+	SessionID     string         `json:"sessionId"`
+	Features      Features       `json:"features"`
+	SessionConfig *SessionConfig `json:"sessionConfig,omitempty"`
+	Restricted    []string       `json:"restricted,omitempty" doc:"Capabilities this connection lacks, as it was opened by a web page: command, writeFiles"`
+}
+
+// SearchEntry is a file or directory found by searchIndex
+type SearchEntry struct {
+	Short string `json:"short"`
+	Path  string `json:"path"`
+	IsDir bool   `json:"isDir"`
+}
+
+type SearchResult struct {
+	Results       []SearchEntry `json:"results"`
+	OpenedResults []SearchEntry `json:"openedResults"`
+}
+
+type IndexExport struct {
+	Root    string              `json:"root"`
+	Count   int                 `json:"count"`
+	Entries []index.ExportEntry `json:"entries"`
+}
+
+type IndexSubscribed struct {
+	Root    string              `json:"root"`
+	Prefix  string              `json:"prefix" doc:"Slash-separated; empty for the whole index"`
+	Count   int                 `json:"count"`
+	Entries []index.ExportEntry `json:"entries"`
+}
+
+type IndexDelta struct {
+	Added   []index.ExportEntry  `json:"added,omitempty"`
+	Removed []string             `json:"removed,omitempty"`
+	Renamed []index.RenamedEntry `json:"renamed,omitempty" doc:"Every moved entry, those under a moved directory too"`
+	Updated []index.ExportEntry  `json:"updated,omitempty" doc:"Entries whose short name changed"`
+}
+
+type IndexUnsubscribed struct{}
+
+// ReplaceFileReport is the change replaceInFiles made, or would make, to one file
+type ReplaceFileReport struct {
+	Path          string `json:"path" doc:"Relative to the index root, slash-separated"`
+	Replacements  int    `json:"replacements"`
+	Diff          string `json:"diff,omitempty" doc:"Unified diff, in dry runs"`
+	DiffTruncated bool   `json:"diffTruncated,omitempty" doc:"The diff was cut at 16 KiB"`
+	Error         string `json:"error,omitempty" doc:"Why the file was not changed"`
+}
+
+type ReplaceResult struct {
+	Root              string              `json:"root"`
+	DryRun            bool                `json:"dryRun"`
+	Files             []ReplaceFileReport `json:"files"`
+	TotalReplacements int                 `json:"totalReplacements"`
+	Truncated         bool                `json:"truncated" doc:"More than 500 files matched; only those listed were considered"`
+}
+
+// IndexStatus reports the health of the index root; it is also sent to every client when
+// the root goes missing or comes back
+type IndexStatus struct {
+	State     string `json:"state" doc:"ok, missing or recovered"`
+	Root      string `json:"root"`
+	Since     int64  `json:"since" doc:"Unix milliseconds of the last change"`
+	Message   string `json:"message,omitempty"`
+	Attempt   int    `json:"attempt,omitempty" doc:"Failed checks since the root went missing"`
+	RetryInMs int64  `json:"retryInMs,omitempty" doc:"Delay before the root is checked again"`
+}
+
+type NormalizedPaths struct {
+	Root  string                 `json:"root"`
+	Paths []index.NormalizedPath `json:"paths" doc:"In the order of the request"`
+}
+
+type ResolvedReferences struct {
+	Root              string              `json:"root"`
+	References        []ws.ChipResolution `json:"references" doc:"In the order of the chips"`
+	SerializedContent string              `json:"serializedContent" doc:"The content with the chips of moved files pointing at their new place"`
+	Changed           bool                `json:"changed"`
+}
+
+type ContextSelection struct {
+	Files       []index.ContextCandidate `json:"files"`
+	TotalTokens int                      `json:"totalTokens"`
+	Budget      int                      `json:"budget,omitempty"`
+}
+
+// Clip is a clipboard history entry
+type Clip struct {
+	Text string `json:"text"`
+	Time int64  `json:"time" doc:"Unix milliseconds"`
+}
+
+type Clips struct {
+	Enabled bool   `json:"enabled"`
+	Clips   []Clip `json:"clips"`
+}
+
+type GitCheckpoints struct {
+	Enabled bool `json:"enabled"`
+}
+
+type CheckpointList struct {
+	SessionID   string                     `json:"sessionId"`
+	Checkpoints []gitcheckpoint.Checkpoint `json:"checkpoints"`
+}
+
+type CheckpointRestored struct {
+	SessionID    string `json:"sessionId"`
+	CheckpointID string `json:"checkpointId"`
+	UndoID       string `json:"undoId" doc:"Checkpoint of the work tree before the restore"`
+}
+
+type GitCheckpointCreated struct {
+	SessionID  string                   `json:"sessionId"`
+	Checkpoint gitcheckpoint.Checkpoint `json:"checkpoint"`
+}
+
+// PromptPrefix is the prefix of .rovobridge/settings.json written before a session's sends
+type PromptPrefix struct {
+	SessionID string `json:"sessionId"`
+	Enabled   bool   `json:"enabled"`
+	Prefix    string `json:"prefix" doc:"The prefix of the session's workspace; empty when it has none"`
+	Error     string `json:"error,omitempty" doc:"Why the settings file could not be read"`
+}
+
+type Tasks struct {
+	SessionID string       `json:"sessionId"`
+	Tasks     []tasks.Task `json:"tasks"`
+}
+
+type TaskStarted struct {
+	SessionID string     `json:"sessionId"`
+	RunID     string     `json:"runId"`
+	Task      tasks.Task `json:"task"`
+}
+
+type TaskResult struct {
+	SessionID string       `json:"sessionId"`
+	RunID     string       `json:"runId"`
+	Result    tasks.Result `json:"result"`
+	Replayed  bool         `json:"replayed,omitempty"`
+}
+
+// Port is a dev server port detected in session output
+type Port struct {
+	Port       int    `json:"port"`
+	SessionID  string `json:"sessionId"`
+	DetectedAt int64  `json:"detectedAt" doc:"Unix milliseconds"`
+}
+
+type Ports struct {
+	Ports []Port `json:"ports"`
+}
+
+type PortDetected struct {
+	SessionID string `json:"sessionId"`
+	Port      int    `json:"port"`
+	Path      string `json:"path" doc:"Proxy route of the port, on the preview listener"`
+	Replayed  bool   `json:"replayed,omitempty"`
+}
+
+type ProxyURL struct {
+	Port int    `json:"port"`
+	URL  string `json:"url"`
+}
+
+type TailStarted struct {
+	TailID string `json:"tailId"`
+	Path   string `json:"path"`
+}
+
+type TailLines struct {
+	TailID    string   `json:"tailId"`
+	Path      string   `json:"path"`
+	Lines     []string `json:"lines"`
+	Truncated bool     `json:"truncated,omitempty" doc:"The file was truncated and is followed from its start"`
+}
+
+type TailStopped struct {
+	TailID string `json:"tailId"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type DiagnosticsReport struct {
+	Status doctor.Status `json:"status" enum:"pass,warn,fail"`
+	Report doctor.Report `json:"report"`
+}
+
+type UpdateInfo struct {
+	Current   string `json:"current"`
+	Latest    string `json:"latest"`
+	Available bool   `json:"available"`
+}
+
+type TransferOffered struct {
+	SessionID   string `json:"sessionId"`
+	Token       string `json:"token"`
+	ExpiresInMs int64  `json:"expiresInMs"`
+}
+
+type SessionTransferred struct {
+	SessionID   string `json:"sessionId"`
+	Controlling bool   `json:"controlling" doc:"Whether this connection now controls the session"`
+}
+
+type BroadcastStarted struct {
+	BroadcastID string   `json:"broadcastId"`
+	Sessions    []string `json:"sessions"`
+	Missing     []string `json:"missing"`
+	Tag         string   `json:"tag,omitempty"`
+}
+
+type Stats struct {
+	Sessions           int               `json:"sessions"`
+	StdinRejectedBytes int64             `json:"stdinRejectedBytes"`
+	Connections        *ws.Stats         `json:"connections,omitempty"`
+	PromptLatency      *ws.PromptLatency `json:"promptLatency,omitempty" doc:"Time from submitting prompts to the first output after them; absent until one was measured"`
+	Janitor            *janitor.Stats    `json:"janitor,omitempty" doc:"Files the janitor removed and the space it reclaimed; absent when it is off"`
+}
+
+type Dashboard struct {
+	At       int64                 `json:"at" doc:"Unix milliseconds"`
+	Sessions []ws.DashboardSession `json:"sessions" doc:"Ordered by session id"`
+}
+
+// SessionUsage is the consumption an agent printed in one session
+type SessionUsage struct {
+	SessionID string      `json:"sessionId"`
+	Usage     usage.Usage `json:"usage"`
+}
+
+type UsageStats struct {
+	Sessions []SessionUsage `json:"sessions"`
+	Days     []usage.Day    `json:"days" doc:"Oldest first, ending today; days without usage included"`
+}
+
+type PromptMetrics struct {
+	SessionID     string `json:"sessionId"`
+	PromptID      string `json:"promptId,omitempty" doc:"History entry of the prompt"`
+	FirstOutputMs int64  `json:"firstOutputMs" doc:"Time from the Enter submitting the prompt to the first output sent after it"`
+}
+
+// Snippet is an in-memory file registered with registerSnippet
+type Snippet struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Bytes int    `json:"bytes"`
+}
+
+type SnippetRegistered Snippet
+
+type Snippets struct {
+	Snippets []Snippet `json:"snippets"`
+}
+
+type OpenInEditor struct {
+	SessionID string `json:"sessionId,omitempty"`
+	Path      string `json:"path"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+}
+
+type SessionConfigUpdated struct {
+	SessionConfig SessionConfig `json:"sessionConfig"`
+}
+
+type ConfirmationRequired struct {
+	Operation   string `json:"operation"`
+	Summary     string `json:"summary"`
+	Token       string `json:"token"`
+	ExpiresInMs int64  `json:"expiresInMs"`
+}
+
+// Note is a note or bookmark attached to a position in session output
+type Note struct {
+	ID        string `json:"id"`
+	Offset    int64  `json:"offset"`
+	Seq       uint64 `json:"seq"`
+	Text      string `json:"text,omitempty"`
+	Bookmark  bool   `json:"bookmark,omitempty"`
+	CreatedAt int64  `json:"createdAt" doc:"Unix milliseconds"`
+}
+
+type Opened struct {
+	ID                  *string                      `json:"id" doc:"The id given in openSession, null if none was"`
+	SessionID           string                       `json:"sessionId" doc:"Server-generated id, unique for the life of the bridge"`
+	Alias               string                       `json:"alias,omitempty" doc:"The id the session was opened as, which this connection may use in place of sessionId"`
+	PID                 int                          `json:"pid"`
+	Resumed             bool                         `json:"resumed"`
+	Transferred         bool                         `json:"transferred,omitempty"`
+	Warm                bool                         `json:"warm,omitempty" doc:"The process was started ahead by the warm pool"`
+	PromptHistory       []history.PromptHistoryEntry `json:"promptHistory" doc:"The newest entries of the prompt history"`
+	PromptHistoryTotal  int                          `json:"promptHistoryTotal,omitempty" doc:"Entries in the whole prompt history"`
+	PromptHistoryOffset int                          `json:"promptHistoryOffset,omitempty" doc:"Index of the first entry of promptHistory; queryHistory pages the entries before it"`
+	Notes               []Note                       `json:"notes,omitempty"`
+	Starting            bool                         `json:"starting,omitempty" doc:"A resumed session has not printed ready yet"`
+}
+
+type Ready struct {
+	SessionID       string `json:"sessionId"`
+	Reason          string `json:"reason" enum:"idle,prompt,timeout"`
+	AfterMs         int64  `json:"afterMs" doc:"Time since the process started"`
+	SuppressedBytes int64  `json:"suppressedBytes,omitempty" doc:"Startup banner output withheld with suppressBanner"`
+	Replayed        bool   `json:"replayed,omitempty"`
+}
+
+// PromptLint lists the likely mistakes a send was held back for
+type PromptLint struct {
+	SessionID string           `json:"sessionId"`
+	Warnings  []ws.LintWarning `json:"warnings"`
+}
+
+type SessionStalled struct {
+	SessionID string `json:"sessionId"`
+	Stalled   bool   `json:"stalled" doc:"false once the session prints again"`
+	SilentMs  int64  `json:"silentMs,omitempty"`
+	Probe     string `json:"probe,omitempty" enum:"winch,write,none"`
+	Reason    string `json:"reason,omitempty" doc:"Set when the probe itself failed"`
+}
+
+type TermWarning struct {
+	SessionID  string `json:"sessionId"`
+	Term       string `json:"term" doc:"The TERM set in the env of openSession"`
+	Message    string `json:"message"`
+	Fallback   string `json:"fallback" doc:"The TERM downgradeTerm starts the session with"`
+	Downgraded bool   `json:"downgraded" doc:"The session runs with fallback instead of term"`
+	Replayed   bool   `json:"replayed,omitempty"`
+}
+
+type Snapshot struct {
+	SessionID  string `json:"sessionId"`
+	DataBase64 string `json:"dataBase64"`
+	LastSeq    uint64 `json:"lastSeq"`
+	Encoding   string `json:"encoding,omitempty" enum:"gzip" doc:"dataBase64 is compressed with the encoding negotiated in hello"`
+	RawBytes   int    `json:"rawBytes,omitempty" doc:"Size of the output before compression"`
+	Text       string `json:"text,omitempty" doc:"The output as plain text, for plainText sessions"`
+}
+
+type Stdout struct {
+	SessionID   string `json:"sessionId"`
+	DataBase64  string `json:"dataBase64"`
+	Seq         uint64 `json:"seq"`
+	Offset      int64  `json:"offset,omitempty" doc:"Stream offset of the first byte"`
+	BroadcastID string `json:"broadcastId,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+}
+
+// PlainTextLine is a line of a plainText session's output
+type PlainTextLine struct {
+	Text   string `json:"text"`
+	Offset int64  `json:"offset" doc:"Stream offset of the output the line's first character came from"`
+}
+
+type Lines struct {
+	SessionID   string          `json:"sessionId"`
+	Seq         uint64          `json:"seq"`
+	Lines       []PlainTextLine `json:"lines" doc:"Lines completed since the last lines message, escape sequences removed"`
+	Pending     string          `json:"pending,omitempty" doc:"The unterminated last line, such as a prompt waiting for input"`
+	BroadcastID string          `json:"broadcastId,omitempty"`
+	Tag         string          `json:"tag,omitempty"`
+}
+
+type Exit struct {
+	SessionID string `json:"sessionId"`
+	Code      int    `json:"code"`
+	Replayed  bool   `json:"replayed,omitempty"`
+}
+
+// ErrorCodes are the machine-readable codes of errors a client can act on
+var ErrorCodes = []string{
+	"internalError", "gitCheckpointFailed", "redactionNotConfigured", "stdinTooLarge",
+	"stdinRateLimited", "notController", "transferStale", "sessionTransferred",
+	"updatesNotConfigured", "updateCheckFailed", "mockUnsupported", "timeout",
+	"permissionStale", "payloadTooLarge", "previewStale",
+}
+
+type Error struct {
+	Code        string `json:"code,omitempty" enum:"internalError,gitCheckpointFailed,redactionNotConfigured,stdinTooLarge,stdinRateLimited,notController,transferStale,sessionTransferred,updatesNotConfigured,updateCheckFailed,mockUnsupported,capabilityDenied,fileConflict,timeout,permissionStale,payloadTooLarge,previewStale"`
+	Message     string `json:"message"`
+	SessionID   string `json:"sessionId,omitempty"`
+	MessageType string `json:"messageType,omitempty" doc:"Type of the message that panicked, timed out, is not available in mock mode or needs a withheld capability"`
+	Bytes       int    `json:"bytes,omitempty" doc:"Stdin or payload bytes rejected"`
+	Limit       int    `json:"limit,omitempty"`
+	Current     string `json:"current,omitempty" doc:"Version of the running bridge"`
+	// payloadTooLarge breakdown
+	TextBytes   int                    `json:"textBytes,omitempty" doc:"Prompt text in a rejected payload"`
+	Files       []ws.PayloadPart       `json:"files,omitempty" doc:"Files read for a rejected payload"`
+	Suggestions []ws.PayloadSuggestion `json:"suggestions,omitempty" doc:"Changes that bring a rejected payload under the limit"`
+	// previewStale
+	BlockID int    `json:"blockId,omitempty"`
+	Path    string `json:"path,omitempty" doc:"File changed since the preview or the read"`
+	// capabilityDenied
+	Capability string `json:"capability,omitempty" doc:"Capability withheld from the connection"`
+	// fileConflict
+	Exists       bool   `json:"exists,omitempty" doc:"The file is on disk"`
+	CurrentHash  string `json:"currentHash,omitempty" doc:"Hash of the file on disk"`
+	CurrentMtime int64  `json:"currentMtime,omitempty"`
+}
+
+type PromptSaved struct {
+	ID    string                      `json:"id,omitempty"`
+	Entry *history.PromptHistoryEntry `json:"entry,omitempty"`
+	Error string                      `json:"error,omitempty"`
+}
+
+type DraftSaved struct {
+	SessionID string `json:"sessionId"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+type Draft struct {
+	SessionID string               `json:"sessionId"`
+	Draft     *history.PromptDraft `json:"draft,omitempty"`
+}
+
+// CheckpointFile is the digest of a file when a checkpoint was created
+type CheckpointFile struct {
+	Path   string `json:"path"`
+	Digest string `json:"digest,omitempty" doc:"Empty when the file could not be read"`
+}
+
+// Checkpoint records file digests and the output position of a session
+type Checkpoint struct {
+	ID        string           `json:"id"`
+	CreatedAt int64            `json:"createdAt"`
+	Prompt    string           `json:"prompt,omitempty"`
+	Seq       uint64           `json:"seq"`
+	Files     []CheckpointFile `json:"files"`
+}
+
+// FileChange is a file changed since a checkpoint or the start of a session
+type FileChange struct {
+	Path   string `json:"path"`
+	Status string `json:"status" enum:"modified,deleted,created"`
+}
+
+type CheckpointCreated struct {
+	SessionID  string     `json:"sessionId"`
+	Checkpoint Checkpoint `json:"checkpoint"`
+}
+
+type CheckpointDiff struct {
+	SessionID    string       `json:"sessionId"`
+	CheckpointID string       `json:"checkpointId"`
+	Seq          uint64       `json:"seq"`
+	CurrentSeq   uint64       `json:"currentSeq"`
+	Changed      []FileChange `json:"changed"`
+}
+
+type SessionDiff struct {
+	SessionID string       `json:"sessionId"`
+	Since     int64        `json:"since" doc:"Unix milliseconds"`
+	Changed   []FileChange `json:"changed"`
+	Truncated bool         `json:"truncated"`
+}
+
+type PromptSuggestions struct {
+	Suggestions []history.PromptSuggestion `json:"suggestions"`
+}
+
+type HistoryByPath struct {
+	Path    string                       `json:"path"`
+	Entries []history.PromptHistoryEntry `json:"entries"`
+}
+
+// RewrittenEntry is a history entry whose file chips were rewritten
+type RewrittenEntry struct {
+	ID                string `json:"id"`
+	SerializedContent string `json:"serializedContent"`
+}
+
+type HistoryPathsRewritten struct {
+	Renames []history.PathRename `json:"renames" doc:"Absolute paths"`
+	Entries []RewrittenEntry     `json:"entries"`
+	DryRun  bool                 `json:"dryRun"`
+}
+
+type FilesRenamed struct {
+	Renames []history.PathRename `json:"renames" doc:"Absolute paths"`
+}
+
+type HistoryRenameRewrite struct {
+	Enabled bool `json:"enabled"`
+}
+
+type HistoryPage struct {
+	Offset  int                          `json:"offset"`
+	Total   int                          `json:"total"`
+	Entries []history.PromptHistoryEntry `json:"entries"`
+}
+
+type ProjectPromptSaved struct {
+	ID    string                      `json:"id"`
+	Entry *history.PromptHistoryEntry `json:"entry,omitempty"`
+	Error string                      `json:"error,omitempty"`
+}
+
+type ProjectPromptRemoved struct {
+	PromptID string `json:"promptId"`
+	Error    string `json:"error,omitempty"`
+}
+
+type PromptRemoved struct {
+	PromptID string `json:"promptId"`
+}
+
+// InjectedFile reports one file of injectFiles or send
+type InjectedFile struct {
+	Path      string `json:"path"`
+	Error     string `json:"error,omitempty"`
+	Bytes     int    `json:"bytes,omitempty"`
+	Tokens    int    `json:"tokens,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+type InjectResult struct {
+	SessionID string         `json:"sessionId"`
+	Files     []InjectedFile `json:"files"`
+}
+
+type NoteAdded struct {
+	SessionID string `json:"sessionId"`
+	Note      Note   `json:"note"`
+}
+
+type NoteRemoved struct {
+	SessionID string `json:"sessionId"`
+	NoteID    string `json:"noteId"`
+}
+
+type Notes struct {
+	SessionID string `json:"sessionId"`
+	Notes     []Note `json:"notes"`
+}
+
+// CodeBlock is a fenced code block printed in session output
+type CodeBlock struct {
+	ID               int    `json:"id"`
+	Language         string `json:"language,omitempty" doc:"From the fence, or guessed from the content"`
+	LanguageDetected bool   `json:"languageDetected,omitempty" doc:"The fence named no language and it was guessed"`
+	Content          string `json:"content" doc:"The code, the fence's indentation removed"`
+	Lines            int    `json:"lines"`
+	Start            int64  `json:"start" doc:"Stream offset of the opening fence"`
+	End              int64  `json:"end" doc:"Stream offset just past the closing fence"`
+	ContentStart     int64  `json:"contentStart" doc:"Stream offset of the first line of code"`
+	Truncated        bool   `json:"truncated,omitempty" doc:"Content beyond 256 KiB was dropped"`
+}
+
+type CodeBlocks struct {
+	SessionID string      `json:"sessionId"`
+	Blocks    []CodeBlock `json:"blocks"`
+}
+
+type CodeBlockPreview struct {
+	SessionID string `json:"sessionId"`
+	BlockID   int    `json:"blockId"`
+	Path      string `json:"path"`
+	Created   bool   `json:"created,omitempty" doc:"The file does not exist yet"`
+	Diff      string `json:"diff" doc:"Unified diff of the edit; empty when it changes nothing"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+}
+
+type CodeBlockApplied struct {
+	SessionID string `json:"sessionId"`
+	BlockID   int    `json:"blockId"`
+	Path      string `json:"path"`
+	Created   bool   `json:"created,omitempty"`
+	Bytes     int    `json:"bytes" doc:"Size of the file written"`
+}
+
+type FileContent struct {
+	SessionID string `json:"sessionId,omitempty"`
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Hash      string `json:"hash" doc:"Hex SHA-256 of the content, the baseHash of saveFile"`
+	Mtime     int64  `json:"mtime" doc:"Modification time in unix milliseconds, the baseMtime of saveFile"`
+	Bytes     int    `json:"bytes"`
+}
+
+type FileSaved struct {
+	SessionID string `json:"sessionId,omitempty"`
+	Path      string `json:"path"`
+	Created   bool   `json:"created,omitempty"`
+	Hash      string `json:"hash" doc:"Of the content written, the baseHash of the next save"`
+	Mtime     int64  `json:"mtime"`
+	Bytes     int    `json:"bytes"`
+}
+
+type Redaction struct {
+	Enabled  bool `json:"enabled"`
+	Patterns int  `json:"patterns"`
+}
+
+type TimeResolved struct {
+	SessionID   string `json:"sessionId"`
+	Time        int64  `json:"time"`
+	Offset      int64  `json:"offset"`
+	At          int64  `json:"at,omitempty" doc:"When the output at offset was printed"`
+	ReplayStart int64  `json:"replayStart" doc:"Offset the snapshot starts at"`
+	FirstOutput int64  `json:"firstOutput,omitempty"`
+}
+
+type QuotaWarning struct {
+	Direction     string  `json:"direction" enum:"sent,received"`
+	Bytes         int64   `json:"bytes"`
+	Limit         int64   `json:"limit"`
+	WindowSeconds float64 `json:"windowSeconds"`
+}
+
+type Diagnostic struct {
+	SessionID  string                 `json:"sessionId"`
+	Diagnostic diagnostics.Diagnostic `json:"diagnostic"`
+	Replayed   bool                   `json:"replayed,omitempty"`
+}
+
+type PermissionRequest struct {
+	SessionID string            `json:"sessionId"`
+	RequestID string            `json:"requestId"`
+	Prompt    permission.Prompt `json:"prompt"`
+	Replayed  bool              `json:"replayed,omitempty"`
+}
+
+type PermissionResolved struct {
+	SessionID string `json:"sessionId"`
+	RequestID string `json:"requestId"`
+	Choice    string `json:"choice,omitempty" enum:"yes,no,always" doc:"Absent when the prompt was answered in the terminal or went away"`
+	Replayed  bool   `json:"replayed,omitempty"`
+}
+
+type AgentReloaded struct {
+	SessionID  string `json:"sessionId"`
+	PID        int    `json:"pid" doc:"Of the new process"`
+	Transcript string `json:"transcript" doc:"The last output of the previous process as plain text"`
+	Lines      int    `json:"lines" doc:"Lines in transcript"`
+	Reinjected bool   `json:"reinjected,omitempty" doc:"A summary of transcript will be sent as the first prompt when the new process is ready"`
+}
+
+// PathAnnotation marks a file:line reference in session output
+type PathAnnotation struct {
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+type PathAnnotations struct {
+	SessionID   string           `json:"sessionId"`
+	Annotations []PathAnnotation `json:"annotations"`
+}
+
+// ServerMessages lists every message the bridge sends
+var ServerMessages = []Message{
+	{"welcome", Welcome{}, "Answers hello with the bridge's features and session config"},
+	{"searchResult", SearchResult{}, "Files matching a searchIndex pattern"},
+	{"indexExport", IndexExport{}, "The full file index"},
+	{"indexSubscribed", IndexSubscribed{}, "The entries a subscribeIndex follows"},
+	{"indexDelta", IndexDelta{}, "Entries removed, moved, added and updated since the last indexSubscribed or indexDelta, to apply in that order"},
+	{"indexUnsubscribed", IndexUnsubscribed{}, "Index changes are no longer sent"},
+	{"replaceResult", ReplaceResult{}, "The changes of a replaceInFiles"},
+	{"indexStatus", IndexStatus{}, "The index root went missing, is still missing or came back and was rescanned"},
+	{"normalizedPaths", NormalizedPaths{}, "Paths of a normalizePaths request relative to the index root"},
+	{"resolvedReferences", ResolvedReferences{}, "The chips of a resolveReferences request, resolved"},
+	{"contextSelection", ContextSelection{}, "Files proposed for a prompt"},
+	{"clips", Clips{}, "The clipboard history"},
+	{"gitCheckpoints", GitCheckpoints{}, "Whether git checkpoints are taken before each send"},
+	{"checkpointList", CheckpointList{}, "The git checkpoints of a session"},
+	{"checkpointRestored", CheckpointRestored{}, "A git checkpoint was restored"},
+	{"gitCheckpointCreated", GitCheckpointCreated{}, "A git checkpoint was taken before a send"},
+	{"promptPrefix", PromptPrefix{}, "Whether a session's sends get the workspace prompt prefix, and the prefix"},
+	{"tasks", Tasks{}, "The project tasks"},
+	{"taskStarted", TaskStarted{}, "A task run started"},
+	{"taskResult", TaskResult{}, "The outcome of a task run"},
+	{"ports", Ports{}, "The detected dev server ports"},
+	{"portDetected", PortDetected{}, "A session announced a dev server"},
+	{"proxyUrl", ProxyURL{}, "A one-time URL previewing a dev server"},
+	{"tailStarted", TailStarted{}, "A file is followed"},
+	{"tailLines", TailLines{}, "Lines appended to a followed file"},
+	{"tailStopped", TailStopped{}, "A file is no longer followed"},
+	{"diagnosticsReport", DiagnosticsReport{}, "The environment checks"},
+	{"updateInfo", UpdateInfo{}, "Whether a newer release is available"},
+	{"transferOffered", TransferOffered{}, "The token another client claims a session with"},
+	{"sessionTransferred", SessionTransferred{}, "The control of a session changed hands"},
+	{"broadcastStarted", BroadcastStarted{}, "The sessions a broadcastSend went to"},
+	{"stats", Stats{}, "Connection and session counters"},
+	{"dashboard", Dashboard{}, "Every session's state, uptime, last activity, resource usage and queues"},
+	{"promptMetrics", PromptMetrics{}, "How long a submitted prompt took to produce output"},
+	{"usage", UsageStats{}, "Token, request and cost totals per session and per day"},
+	{"snippetRegistered", SnippetRegistered{}, "A snippet was registered"},
+	{"snippets", Snippets{}, "The registered snippets"},
+	{"openInEditor", OpenInEditor{}, "Asks an IDE plugin to open a file"},
+	{"sessionConfigUpdated", SessionConfigUpdated{}, "The command of new sessions changed"},
+	{"confirmationRequired", ConfirmationRequired{}, "An operation waits for confirm"},
+	{"opened", Opened{}, "A session was started, resumed or claimed"},
+	{"ready", Ready{}, "A session finished its startup output and takes input"},
+	{"agentReloaded", AgentReloaded{}, "A session's command was restarted by reloadAgent"},
+	{"termWarning", TermWarning{}, "openSession set a TERM the bridge's terminal may not render as the program expects"},
+	{"sessionStalled", SessionStalled{}, "A busy session stopped printing and did not answer a probe, or printed again"},
+	{"snapshot", Snapshot{}, "The recent output of a session"},
+	{"stdout", Stdout{}, "Session output"},
+	{"lines", Lines{}, "Output of a plainText session as lines of text"},
+	{"exit", Exit{}, "A session's process exited"},
+	{"error", Error{}, "A request failed"},
+	{"promptSaved", PromptSaved{}, "A prompt history entry was saved"},
+	{"draftSaved", DraftSaved{}, "The unsent prompt was saved"},
+	{"draft", Draft{}, "The unsent prompt"},
+	{"checkpointCreated", CheckpointCreated{}, "A checkpoint was created"},
+	{"checkpointDiff", CheckpointDiff{}, "Files changed since a checkpoint"},
+	{"sessionDiff", SessionDiff{}, "Files changed since a session started"},
+	{"promptSuggestions", PromptSuggestions{}, "History prompts similar to a draft"},
+	{"historyByPath", HistoryByPath{}, "Prompts that referenced a file"},
+	{"historyPathsRewritten", HistoryPathsRewritten{}, "The history entries whose file chips were rewritten for moved files, by rewriteHistoryPaths or automatically"},
+	{"filesRenamed", FilesRenamed{}, "The index saw files move; rewriteHistoryPaths updates the history for them"},
+	{"historyRenameRewrite", HistoryRenameRewrite{}, "Whether file moves rewrite the history automatically"},
+	{"historyPage", HistoryPage{}, "A page of the prompt history"},
+	{"projectPromptSaved", ProjectPromptSaved{}, "A prompt was saved to the workspace library"},
+	{"projectPromptRemoved", ProjectPromptRemoved{}, "A prompt was removed from the workspace library"},
+	{"promptRemoved", PromptRemoved{}, "A prompt was removed from the history"},
+	{"injectResult", InjectResult{}, "The files injected by injectFiles or send"},
+	{"promptLint", PromptLint{}, "A send was not written because of likely mistakes; resend it with lintAcknowledged to write it anyway"},
+	{"noteAdded", NoteAdded{}, "A note was added"},
+	{"noteRemoved", NoteRemoved{}, "A note was removed"},
+	{"notes", Notes{}, "The notes of a session"},
+	{"codeBlocks", CodeBlocks{}, "The fenced code blocks of a session's output"},
+	{"codeBlockPreview", CodeBlockPreview{}, "The diff applying a code block would make"},
+	{"codeBlockApplied", CodeBlockApplied{}, "A code block was written to a file"},
+	{"fileContent", FileContent{}, "A file read for the quick editor"},
+	{"fileSaved", FileSaved{}, "A file of the quick editor was written"},
+	{"redaction", Redaction{}, "Whether secrets are masked"},
+	{"timeResolved", TimeResolved{}, "The output position at a time"},
+	{"quotaWarning", QuotaWarning{}, "The connection went over a soft traffic quota"},
+	{"diagnostic", Diagnostic{}, "A compiler or test error recognized in session output"},
+	{"pathAnnotations", PathAnnotations{}, "File references recognized in session output"},
+	{"permissionRequest", PermissionRequest{}, "The agent asks permission to run a tool; answer with respondPermission"},
+	{"permissionResolved", PermissionResolved{}, "A permission prompt was answered or went away"},
+}
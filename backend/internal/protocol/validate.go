@@ -0,0 +1,192 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Direction selects the messages a frame is validated against
+type Direction string
+
+const (
+	FromClient Direction = "Client"
+	FromServer Direction = "Server"
+)
+
+var (
+	schemaOnce sync.Once
+	schemaDefs map[string]any
+)
+
+// ValidateMessage checks one JSON message against the schema of its type. It supports the
+// subset of JSON Schema the generator emits.
+func ValidateMessage(dir Direction, data []byte) error {
+	schemaOnce.Do(func() { schemaDefs = Schema()["$defs"].(map[string]any) })
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("message is not an object")
+	}
+	typ, _ := m["type"].(string)
+	def, ok := schemaDefs[string(dir)+upperFirst(typ)].(map[string]any)
+	if typ == "" || !ok {
+		return fmt.Errorf("unknown %s message type %q", strings.ToLower(string(dir)), typ)
+	}
+	return validate(schemaDefs, def, v, typ)
+}
+
+// validate checks v against schema s, naming the value path in errors
+func validate(defs map[string]any, s map[string]any, v any, path string) error {
+	if ref, ok := s["$ref"].(string); ok {
+		name := ref[strings.LastIndex(ref, "/")+1:]
+		def, ok := defs[name].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: unresolved %s", path, ref)
+		}
+		return validate(defs, def, v, path)
+	}
+	if c, ok := s["const"]; ok && v != c {
+		return fmt.Errorf("%s: want %v", path, c)
+	}
+	if enum, ok := s["enum"].([]any); ok && !contains(enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, enum)
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		if err := matchOne(defs, anyOf, v, path, false); err != nil {
+			return err
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]any); ok {
+		if err := matchOne(defs, oneOf, v, path, true); err != nil {
+			return err
+		}
+	}
+	if t, ok := s["type"]; ok && !hasType(t, v) {
+		return fmt.Errorf("%s: want %v, got %s", path, t, jsonType(v))
+	}
+	if min, ok := s["minimum"].(int); ok {
+		if n, isNum := v.(float64); isNum && n < float64(min) {
+			return fmt.Errorf("%s: %v is below %d", path, n, min)
+		}
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		return validateObject(defs, s, v, path)
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, e := range v {
+				if err := validate(defs, items, e, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateObject(defs map[string]any, s map[string]any, v map[string]any, path string) error {
+	props, _ := s["properties"].(map[string]any)
+	for _, r := range requiredOf(s) {
+		if _, ok := v[r.(string)]; !ok {
+			return fmt.Errorf("%s: missing %s", path, r)
+		}
+	}
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if ps, ok := props[k].(map[string]any); ok {
+			if err := validate(defs, ps, v[k], path+"."+k); err != nil {
+				return err
+			}
+			continue
+		}
+		switch extra := s["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				return fmt.Errorf("%s: unexpected field %s", path, k)
+			}
+		case map[string]any:
+			if err := validate(defs, extra, v[k], path+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchOne checks that v matches one of the schemas, or exactly one if exact is set
+func matchOne(defs map[string]any, schemas []any, v any, path string, exact bool) error {
+	matched := 0
+	var firstErr error
+	for _, s := range schemas {
+		err := validate(defs, s.(map[string]any), v, path)
+		if err == nil {
+			matched++
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	switch {
+	case matched == 0:
+		return firstErr
+	case exact && matched > 1:
+		return fmt.Errorf("%s: matches %d schemas, want one", path, matched)
+	}
+	return nil
+}
+
+func hasType(t, v any) bool {
+	if list, ok := t.([]any); ok {
+		for _, e := range list {
+			if hasType(e, v) {
+				return true
+			}
+		}
+		return false
+	}
+	want, _ := t.(string)
+	got := jsonType(v)
+	if want == "integer" {
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	}
+	return want == got || want == "number" && got == "integer"
+}
+
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+func contains(list []any, v any) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
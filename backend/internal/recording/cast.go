@@ -0,0 +1,204 @@
+// Package recording writes session output as asciicast v2 files and plays them back, so
+// past agent runs can be replayed through the terminal renderer. Input recordings keep
+// what was written to a session's stdin, to replay it into another session.
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Ext is the file extension of recordings
+const Ext = ".cast"
+
+// Header is the first line of an asciicast v2 file
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Duration  float64           `json:"duration,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Writer appends the output of one session to a cast file. It is safe for concurrent use.
+type Writer struct {
+	mu      sync.Mutex
+	f       *os.File
+	start   time.Time
+	partial []byte // incomplete UTF-8 sequence carried over to the next chunk
+	err     error
+
+	// spinner collapsing, see CollapseFrames
+	frameInterval time.Duration
+	framing       bool      // the last output written was a redraw frame
+	lastFrame     time.Time // when it was written
+	lastFrameUp   int
+	pending       *heldFrame // latest frame held back, replaced by the next one
+}
+
+// heldFrame is a redraw frame not written yet, with its original time
+type heldFrame struct {
+	t    float64
+	data string
+}
+
+// Create starts a recording in dir named after the start time and session id
+func Create(dir, sessionID string, width, height int, title string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	name := fmt.Sprintf("%s-%s%s", start.Format("20060102-150405"), SafeName(sessionID), Ext)
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	w := &Writer{f: f, start: start}
+	w.writeLine(Header{Version: 2, Width: width, Height: height, Timestamp: start.Unix(), Title: title})
+	if w.err != nil {
+		f.Close()
+		return nil, w.err
+	}
+	return w, nil
+}
+
+// Output records a chunk of terminal output
+func (w *Writer) Output(p []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := append(w.partial, p...)
+	// Hold back a rune split across chunks; JSON would otherwise mangle it
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	w.partial = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return
+	}
+	if w.frameInterval <= 0 {
+		w.event("o", string(data[:cut]))
+		return
+	}
+	for _, seg := range SplitFrames(data[:cut]) {
+		now := time.Now()
+		if seg.Redraws() && w.framing && seg.Up == w.lastFrameUp && now.Sub(w.lastFrame) < w.frameInterval {
+			// Too soon after the last frame: hold it, unless the next one redraws it first
+			w.pending = &heldFrame{t: w.elapsed(), data: string(seg.Data)}
+			continue
+		}
+		w.flushFrame()
+		w.event("o", string(seg.Data))
+		switch {
+		case seg.Redraws():
+			w.framing, w.lastFrame, w.lastFrameUp = true, now, seg.Up
+		case seg.Frame || seg.Lines > 0:
+			// A frame that moves on, or the rest of a frame that does
+			w.framing = false
+		}
+	}
+}
+
+// CollapseFrames drops spinner and progress redraws that replace each other within
+// interval, so the recording keeps at most one frame per interval of a redraw that goes
+// on for long. The last frame is always kept. Zero turns collapsing off.
+func (w *Writer) CollapseFrames(interval time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.frameInterval = interval
+}
+
+// flushFrame writes the held frame, if any
+func (w *Writer) flushFrame() {
+	if w.pending != nil && w.f != nil {
+		w.writeLine([]any{w.pending.t, "o", w.pending.data})
+	}
+	w.pending = nil
+}
+
+// Resize records a terminal size change
+func (w *Writer) Resize(cols, rows int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushFrame()
+	w.framing = false
+	w.event("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Marker records a marker event labelled label, e.g. a note the user attached to the output
+func (w *Writer) Marker(label string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushFrame()
+	w.event("m", label)
+}
+
+// Close flushes any held-back bytes and closes the file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	w.flushFrame()
+	if len(w.partial) > 0 {
+		w.event("o", string(w.partial))
+		w.partial = nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	if w.err != nil {
+		return w.err
+	}
+	return err
+}
+
+func (w *Writer) event(kind, data string) {
+	if w.f == nil {
+		return
+	}
+	w.writeLine([]any{w.elapsed(), kind, data})
+}
+
+// elapsed returns the event time of now, in seconds since the start
+func (w *Writer) elapsed() float64 {
+	return float64(time.Since(w.start).Microseconds()) / 1e6
+}
+
+func (w *Writer) writeLine(v any) {
+	if w.err != nil {
+		return
+	}
+	line, err := json.Marshal(v)
+	if err == nil {
+		_, err = w.f.Write(append(line, '\n'))
+	}
+	w.err = err
+}
+
+// SafeName reduces s to characters that are safe in a file name
+func SafeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
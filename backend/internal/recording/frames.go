@@ -0,0 +1,91 @@
+package recording
+
+import "bytes"
+
+// Segment is a run of terminal output. A frame segment starts by moving the cursor back
+// over earlier output and erasing it, the way spinners and progress bars redraw; it runs
+// until the next frame. Output before the first frame of a chunk is a plain segment,
+// which continues whatever came before.
+type Segment struct {
+	Data  []byte
+	Frame bool
+	Up    int // lines the frame moves up before redrawing
+	Lines int // line feeds in the segment
+}
+
+// Redraws reports whether the frame ends on the line it started on, so a following
+// frame with the same Up redraws exactly what it drew and it can be dropped
+func (s Segment) Redraws() bool { return s.Frame && s.Lines == s.Up }
+
+// SplitFrames splits p into segments at every redraw
+func SplitFrames(p []byte) []Segment {
+	var segs []Segment
+	start, up, frame := 0, 0, false
+	for i := 0; i < len(p); i++ {
+		if p[i] != '\r' && p[i] != 0x1b {
+			continue
+		}
+		n, l, ok := parseRewind(p[i:])
+		if !ok {
+			continue
+		}
+		if i > start {
+			segs = append(segs, Segment{Data: p[start:i], Frame: frame, Up: up, Lines: bytes.Count(p[start:i], []byte{'\n'})})
+		}
+		start, up, frame = i, n, true
+		i += l - 1
+	}
+	if start < len(p) {
+		segs = append(segs, Segment{Data: p[start:], Frame: frame, Up: up, Lines: bytes.Count(p[start:], []byte{'\n'})})
+	}
+	return segs
+}
+
+// parseRewind recognizes the start of a redraw: a carriage return or a cursor-up followed
+// by an erase that clears the line (or, after cursor-up, the screen below), or an erase of
+// the whole line followed by a carriage return. It returns the lines moved up and the
+// length of the sequence.
+func parseRewind(b []byte) (up, n int, ok bool) {
+	if num, l, final := parseCSI(b); final == 'K' && num == 2 && l < len(b) && b[l] == '\r' {
+		return 0, l + 1, true
+	}
+	i, col0 := 0, false
+	if num, l, final := parseCSI(b); final == 'A' || final == 'F' {
+		up, i, col0 = max(num, 1), l, final == 'F'
+	}
+	if i < len(b) && b[i] == '\r' {
+		i++
+		col0 = true
+	}
+	if i == 0 {
+		return 0, 0, false
+	}
+	num, l, final := parseCSI(b[i:])
+	switch {
+	case final == 'K' && (num == 2 || col0 && num == 0):
+	case final == 'J' && col0 && num == 0 && up > 0:
+	default:
+		return 0, 0, false
+	}
+	return up, i + l, true
+}
+
+// parseCSI parses a control sequence with at most one numeric parameter at the start of
+// b, returning the parameter (0 when absent), its length and its final byte, or a zero
+// final byte when b does not start with one
+func parseCSI(b []byte) (num, n int, final byte) {
+	if len(b) < 3 || b[0] != 0x1b || b[1] != '[' {
+		return 0, 0, 0
+	}
+	for i := 2; i < len(b) && i < 8; i++ {
+		switch c := b[i]; {
+		case c >= '0' && c <= '9':
+			num = num*10 + int(c-'0')
+		case c >= 0x40 && c <= 0x7e:
+			return num, i + 1, c
+		default:
+			return 0, 0, 0
+		}
+	}
+	return 0, 0, 0
+}
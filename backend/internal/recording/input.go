@@ -0,0 +1,180 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// InputExt is the file extension of input recordings
+const InputExt = ".input.jsonl"
+
+// maxHeldInput bounds the unfinished input line an InputWriter holds back for redaction
+const maxHeldInput = 4096
+
+// InputHeader is the first line of an input recording
+type InputHeader struct {
+	Version   int    `json:"version"`
+	SessionID string `json:"sessionId"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// InputEvent is one write to a session's stdin: the seconds since the start of the
+// recording and the exact bytes written (base64 in the file)
+type InputEvent struct {
+	T    float64 `json:"t"`
+	Data []byte  `json:"data"`
+}
+
+// InputWriter records the bytes written to a session's stdin with their times, as JSON
+// lines, so a run can be replayed into another session keystroke by keystroke. Writes
+// are held back until their line ends, so a secret typed a key at a time can still be
+// masked as a whole by the redact function. It is safe for concurrent use.
+type InputWriter struct {
+	mu     sync.Mutex
+	f      *os.File
+	name   string
+	start  time.Time
+	redact func([]byte) []byte // masks secrets, keeping the length; nil records as is
+	held   []InputEvent        // events of the unfinished line
+	bytes  int                 // held bytes
+	err    error
+}
+
+// CreateInput starts an input recording in dir named after the start time and session id.
+// redact, when not nil, masks secrets in each line of input without changing its length.
+func CreateInput(dir, sessionID, title string, redact func([]byte) []byte) (*InputWriter, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	name := fmt.Sprintf("%s-%s%s", start.Format("20060102-150405"), SafeName(sessionID), InputExt)
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := &InputWriter{f: f, name: name, start: start, redact: redact}
+	w.writeLine(InputHeader{Version: 1, SessionID: sessionID, Timestamp: start.Unix(), Title: title})
+	if w.err != nil {
+		f.Close()
+		return nil, w.err
+	}
+	return w, nil
+}
+
+// Name returns the file name of the recording
+func (w *InputWriter) Name() string {
+	return w.name
+}
+
+// Input records a write to stdin
+func (w *InputWriter) Input(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return
+	}
+	t := float64(time.Since(w.start).Microseconds()) / 1e6
+	w.held = append(w.held, InputEvent{T: t, Data: append([]byte(nil), p...)})
+	w.bytes += len(p)
+	if p[len(p)-1] == '\r' || p[len(p)-1] == '\n' || w.bytes >= maxHeldInput {
+		w.flush()
+	}
+}
+
+// flush redacts the held line as a whole and writes its events, each with its own part of
+// the masked bytes. Caller must hold w.mu.
+func (w *InputWriter) flush() {
+	if len(w.held) == 0 {
+		return
+	}
+	if w.redact != nil {
+		line := make([]byte, 0, w.bytes)
+		for _, e := range w.held {
+			line = append(line, e.Data...)
+		}
+		if masked := w.redact(line); len(masked) == len(line) {
+			for i := range w.held {
+				n := len(w.held[i].Data)
+				w.held[i].Data, masked = masked[:n:n], masked[n:]
+			}
+		}
+	}
+	for _, e := range w.held {
+		w.writeLine(e)
+	}
+	w.held, w.bytes = nil, 0
+}
+
+// Close writes any held input and closes the file
+func (w *InputWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	w.flush()
+	err := w.f.Close()
+	w.f = nil
+	if w.err != nil {
+		return w.err
+	}
+	return err
+}
+
+func (w *InputWriter) writeLine(v any) {
+	if w.err != nil {
+		return
+	}
+	line, err := json.Marshal(v)
+	if err == nil {
+		_, err = w.f.Write(append(line, '\n'))
+	}
+	w.err = err
+}
+
+// ReadInput reads an input recording
+func ReadInput(r io.Reader) (InputHeader, []InputEvent, error) {
+	var h InputHeader
+	var events []InputEvent
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	first := true
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		if first {
+			if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+				return h, nil, fmt.Errorf("bad header: %w", err)
+			}
+			if h.Version != 1 {
+				return h, nil, fmt.Errorf("unsupported input recording version %d", h.Version)
+			}
+			first = false
+			continue
+		}
+		var e InputEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return h, nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		events = append(events, e)
+	}
+	if err := sc.Err(); err != nil {
+		return h, nil, err
+	}
+	if first {
+		return h, nil, errors.New("empty input recording")
+	}
+	return h, events, nil
+}
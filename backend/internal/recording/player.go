@@ -0,0 +1,222 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Info describes a recording for listing
+type Info struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Width    int       `json:"width"`
+	Height   int       `json:"height"`
+	Title    string    `json:"title,omitempty"`
+	Duration float64   `json:"duration"` // seconds, up to the last event
+	Markers  int       `json:"markers"`  // marker events, e.g. notes attached to the output
+}
+
+// ErrInvalidName is returned for names that do not denote a recording in the directory
+var ErrInvalidName = errors.New("invalid recording name")
+
+// List returns the recordings in dir, newest first. A missing directory has none.
+func List(dir string) ([]Info, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := []Info{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), Ext) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		info := Info{Name: e.Name(), Size: fi.Size(), Modified: fi.ModTime()}
+		if f, err := os.Open(filepath.Join(dir, e.Name())); err == nil {
+			if h, dur, markers, err := scanMeta(f); err == nil {
+				info.Width, info.Height, info.Title, info.Duration, info.Markers = h.Width, h.Height, h.Title, dur, markers
+			}
+			f.Close()
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Modified.After(out[j].Modified) })
+	return out, nil
+}
+
+// Open opens the recording called name in dir. Only plain file names with the cast
+// extension are accepted, so a request cannot reach outside the directory.
+func Open(dir, name string) (*os.File, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, Ext) {
+		return nil, ErrInvalidName
+	}
+	return os.Open(filepath.Join(dir, name))
+}
+
+// event is one [time, kind, data] line of a cast file
+type event struct {
+	t    float64
+	kind string
+	data string
+}
+
+func (e *event) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("event has %d fields", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &e.t); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw[1], &e.kind); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw[2], &e.data)
+}
+
+func (e event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{float64(int64(e.t*1e6)) / 1e6, e.kind, e.data})
+}
+
+// scanLines calls fn with the header and then every event of a cast file
+func scanLines(r io.Reader, header func(Header) error, fn func(event) error) error {
+	br := bufio.NewReader(r)
+	first := true
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			if first {
+				var h Header
+				if err := json.Unmarshal(line, &h); err != nil {
+					return fmt.Errorf("bad header: %w", err)
+				}
+				if h.Version != 2 {
+					return fmt.Errorf("unsupported asciicast version %d", h.Version)
+				}
+				if err := header(h); err != nil {
+					return err
+				}
+				first = false
+			} else {
+				var e event
+				if json.Unmarshal(line, &e) == nil {
+					if err := fn(e); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if err == io.EOF {
+			if first {
+				return errors.New("empty recording")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// scanMeta returns the header, the time of the last event and the number of markers
+func scanMeta(r io.Reader) (Header, float64, int, error) {
+	var h Header
+	var last float64
+	markers := 0
+	err := scanLines(r, func(hh Header) error { h = hh; return nil }, func(e event) error {
+		if e.t > last {
+			last = e.t
+		}
+		if e.kind == "m" {
+			markers++
+		}
+		return nil
+	})
+	return h, last, markers, err
+}
+
+// Play writes the recording in f as an asciicast v2 stream starting at seek seconds, with
+// event times divided by speed for the client to schedule. Output before seek is folded
+// into a single event at time 0 so the terminal state is complete, and the header carries
+// the size in effect at seek and the remaining duration.
+func Play(w io.Writer, f io.ReadSeeker, seek, speed float64) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	if seek < 0 {
+		seek = 0
+	}
+	// First pass: terminal size and output at the seek point, and the total duration
+	var h Header
+	var prefix strings.Builder
+	var last float64
+	err := scanLines(f, func(hh Header) error { h = hh; return nil }, func(e event) error {
+		if e.t > last {
+			last = e.t
+		}
+		if e.t >= seek {
+			return nil
+		}
+		switch e.kind {
+		case "o":
+			prefix.WriteString(e.data)
+		case "r":
+			var cols, rows int
+			if _, err := fmt.Sscanf(e.data, "%dx%d", &cols, &rows); err == nil && cols > 0 && rows > 0 {
+				h.Width, h.Height = cols, rows
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	h.Duration = 0
+	if last > seek {
+		h.Duration = float64(int64((last-seek)/speed*1e6)) / 1e6
+	}
+	if err := enc.Encode(h); err != nil {
+		return err
+	}
+	if prefix.Len() > 0 {
+		if err := enc.Encode(event{0, "o", prefix.String()}); err != nil {
+			return err
+		}
+	}
+	// Second pass: the events from seek on, rescaled
+	err = scanLines(f, func(Header) error { return nil }, func(e event) error {
+		if e.t < seek {
+			return nil
+		}
+		e.t = (e.t - seek) / speed
+		return enc.Encode(e)
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
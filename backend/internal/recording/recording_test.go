@@ -0,0 +1,228 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCast writes a cast file with fixed event times
+func writeCast(t *testing.T, dir, name string, lines ...string) {
+	t.Helper()
+	data := `{"version":2,"width":80,"height":24,"title":"agent"}` + "\n" + strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readEvents(t *testing.T, b []byte) (Header, []event) {
+	t.Helper()
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	var h Header
+	var evs []event
+	for first := true; sc.Scan(); first = false {
+		if first {
+			if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		var e event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		evs = append(evs, e)
+	}
+	return h, evs
+}
+
+func TestWriter_RecordsOutputAndResizes(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Create(dir, "s/1", 100, 30, "agent run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	euro := []byte("€")
+	w.Output(append([]byte("price "), euro[:1]...))
+	w.Output(euro[1:])
+	w.Marker("went wrong here")
+	w.Resize(120, 40)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := List(dir)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one recording, got %v, %v", list, err)
+	}
+	if !strings.HasSuffix(list[0].Name, "-s_1"+Ext) || list[0].Width != 100 || list[0].Title != "agent run" || list[0].Markers != 1 {
+		t.Fatalf("unexpected info: %+v", list[0])
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, list[0].Name))
+	_, evs := readEvents(t, b)
+	var out strings.Builder
+	for _, e := range evs {
+		if e.kind == "o" {
+			out.WriteString(e.data)
+		}
+	}
+	if out.String() != "price €" {
+		t.Fatalf("split rune was not kept whole: %q", out.String())
+	}
+	if last := evs[len(evs)-1]; last.kind != "r" || last.data != "120x40" {
+		t.Fatalf("expected resize event, got %+v", last)
+	}
+}
+
+func TestList_MissingDirectoryIsEmpty(t *testing.T) {
+	list, err := List(filepath.Join(t.TempDir(), "none"))
+	if err != nil || len(list) != 0 {
+		t.Fatalf("expected empty list, got %v, %v", list, err)
+	}
+}
+
+func TestOpen_RejectsNamesOutsideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"", "../x.cast", "sub/x.cast", ".cast", "x.txt"} {
+		if _, err := Open(dir, name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Open(%q): expected ErrInvalidName, got %v", name, err)
+		}
+	}
+}
+
+func TestPlay_SeeksAndRescales(t *testing.T) {
+	dir := t.TempDir()
+	writeCast(t, dir, "a.cast",
+		`[0.5, "o", "one "]`,
+		`[1.0, "r", "100x50"]`,
+		`[2.0, "o", "two "]`,
+		`[4.0, "o", "three"]`,
+	)
+	f, err := Open(dir, "a.cast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	if err := Play(&buf, f, 1.5, 2); err != nil {
+		t.Fatal(err)
+	}
+	h, evs := readEvents(t, buf.Bytes())
+	if h.Width != 100 || h.Height != 50 || h.Duration != 1.25 {
+		t.Fatalf("unexpected header: %+v", h)
+	}
+	want := []event{{0, "o", "one "}, {0.25, "o", "two "}, {1.25, "o", "three"}}
+	if len(evs) != len(want) {
+		t.Fatalf("expected %v, got %v", want, evs)
+	}
+	for i := range want {
+		if evs[i] != want[i] {
+			t.Fatalf("event %d: expected %v, got %v", i, want[i], evs[i])
+		}
+	}
+}
+
+func TestSplitFrames(t *testing.T) {
+	segs := SplitFrames([]byte("tail\r\x1b[K⠋ a\r\x1b[2K⠙ b\r\n\x1b[1A\x1b[2Kline\nrest"))
+	var got []string
+	for _, s := range segs {
+		got = append(got, fmt.Sprintf("%q frame=%v up=%d redraws=%v", s.Data, s.Frame, s.Up, s.Redraws()))
+	}
+	want := []string{
+		`"tail" frame=false up=0 redraws=false`,
+		`"\r\x1b[K⠋ a" frame=true up=0 redraws=true`,
+		`"\r\x1b[2K⠙ b\r\n" frame=true up=0 redraws=false`,
+		`"\x1b[1A\x1b[2Kline\nrest" frame=true up=1 redraws=true`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWriter_CollapsesRapidFrames(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Create(dir, "s1", 80, 24, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.CollapseFrames(time.Hour)
+	w.Output([]byte("start\n"))
+	for i := 0; i < 30; i++ {
+		w.Output([]byte(fmt.Sprintf("\r\x1b[K⠋ thinking %d", i)))
+	}
+	w.Output([]byte("\r\x1b[K✓ done\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := List(dir)
+	b, _ := os.ReadFile(filepath.Join(dir, list[0].Name))
+	_, evs := readEvents(t, b)
+	var got []string
+	for _, e := range evs {
+		got = append(got, e.data)
+	}
+	// The first frame starts the spinner and the last one before the result is kept
+	want := []string{"start\n", "\r\x1b[K⠋ thinking 0", "\r\x1b[K⠋ thinking 29", "\r\x1b[K✓ done\n"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestInputWriter_RedactsWholeLinesKeepingTimes(t *testing.T) {
+	dir := t.TempDir()
+	// Masks every "secret" without changing the length, like redact.Filter.Redact
+	mask := func(b []byte) []byte { return bytes.ReplaceAll(b, []byte("secret"), []byte("******")) }
+	w, err := CreateInput(dir, "ses_1", "agent", mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"token=se", "cr", "et\r", "\x1b[A", "\xff"} {
+		w.Input([]byte(p))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(w.Name(), "-ses_1"+InputExt) {
+		t.Fatalf("unexpected name %q", w.Name())
+	}
+
+	f, err := os.Open(filepath.Join(dir, w.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	h, events, err := ReadInput(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.SessionID != "ses_1" || h.Title != "agent" {
+		t.Fatalf("unexpected header: %+v", h)
+	}
+	var got []string
+	for i, e := range events {
+		got = append(got, string(e.Data))
+		if i > 0 && e.T < events[i-1].T {
+			t.Fatalf("events out of order: %+v", events)
+		}
+	}
+	// The secret typed in pieces is masked, each write keeping its own bytes and time
+	if want := []string{"token=**", "**", "**\r", "\x1b[A", "\xff"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadInput_RejectsUnknownVersion(t *testing.T) {
+	if _, _, err := ReadInput(strings.NewReader(`{"version":9}` + "\n")); err == nil {
+		t.Fatal("expected an error for version 9")
+	}
+	if _, _, err := ReadInput(strings.NewReader("")); err == nil {
+		t.Fatal("expected an error for an empty file")
+	}
+}
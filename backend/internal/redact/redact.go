@@ -0,0 +1,206 @@
+// Package redact masks secrets in session output before it leaves the bridge, for users
+// who share their screen or record sessions. Matches are overwritten with asterisks of
+// the same length, so terminal layout and stream offsets are unchanged.
+package redact
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask is the byte secrets are overwritten with
+const Mask = '*'
+
+// maxHold bounds the unfinished line a Stream holds back waiting for the rest of a
+// secret; secrets are expected to fit in it
+const maxHold = 256
+
+// DefaultPatterns are well-known secret formats. Where a pattern has a group, only the
+// group is masked, so "password: hunter22" keeps its label.
+var DefaultPatterns = []string{
+	// AWS access key id
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// GitHub token
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	// GitHub fine-grained token
+	`\bgithub_pat_[A-Za-z0-9_]{22,}\b`,
+	// GitLab token
+	`\bglpat-[A-Za-z0-9_\-]{20,}\b`,
+	// Slack token
+	`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`,
+	// Atlassian API token
+	`\bATATT[A-Za-z0-9_\-=]{20,}`,
+	// OpenAI/Anthropic style API key
+	`\bsk-[A-Za-z0-9_\-]{20,}\b`,
+	// Google API key
+	`\bAIza[0-9A-Za-z_\-]{35}\b`,
+	// JWT
+	`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`,
+	// Authorization header
+	`(?i)\bbearer\s+([A-Za-z0-9._~+/=-]{16,})`,
+	// password=..., token: ... and similar assignments
+	`(?i)\b(?:password|passwd|secret|token|api[_-]?key)\s*[:=]\s*["']?([^\s"']{8,})`,
+}
+
+// Filter masks the matches of a set of patterns
+type Filter struct {
+	patterns []*regexp.Regexp
+}
+
+// New compiles patterns into a Filter
+func New(patterns []string) (*Filter, error) {
+	f := &Filter{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad redaction pattern %q: %w", p, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	return f, nil
+}
+
+// LoadPatterns reads a file of patterns, one per line. Blank lines and lines starting
+// with # are skipped.
+func LoadPatterns(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var patterns []string
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, errors.New(path + ": no patterns")
+	}
+	return patterns, nil
+}
+
+// Len returns the number of patterns
+func (f *Filter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.patterns)
+}
+
+// span is a byte range to mask
+type span struct{ start, end int }
+
+// spans returns the ranges of b to mask, in no particular order
+func (f *Filter) spans(b []byte) []span {
+	var out []span
+	for _, re := range f.patterns {
+		for _, m := range re.FindAllSubmatchIndex(b, -1) {
+			s := span{m[0], m[1]}
+			if len(m) >= 4 && m[2] >= 0 {
+				s = span{m[2], m[3]}
+			}
+			if s.end > s.start {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// Redact returns a copy of b with every match masked
+func (f *Filter) Redact(b []byte) []byte {
+	out := append([]byte(nil), b...)
+	if f == nil {
+		return out
+	}
+	for _, s := range f.spans(out) {
+		mask(out[s.start:s.end])
+	}
+	return out
+}
+
+func mask(b []byte) {
+	for i := range b {
+		b[i] = Mask
+	}
+}
+
+// Stream redacts output that arrives in chunks. It holds back the unfinished last line,
+// so a secret split between two reads is still masked, and any secret running into the
+// held bytes.
+type Stream struct {
+	held []byte
+}
+
+// Write returns the output of p, after the bytes held back from earlier writes, that is
+// safe to pass on. With a nil filter everything is passed through.
+func (s *Stream) Write(f *Filter, p []byte) []byte {
+	buf := append(s.held, p...)
+	s.held = nil
+	if f == nil || len(buf) == 0 {
+		return buf
+	}
+	cut := holdPoint(buf)
+	spans := f.spans(buf)
+	// Hold back whole any secret that runs into the held bytes
+	for moved := true; moved; {
+		moved = false
+		for _, sp := range spans {
+			if sp.start < cut && sp.end > cut {
+				cut, moved = sp.start, true
+			}
+		}
+	}
+	for cut > 0 && cut < len(buf) && !utf8.RuneStart(buf[cut]) {
+		cut--
+	}
+	out := append([]byte(nil), buf[:cut]...)
+	for _, sp := range spans {
+		if sp.end <= cut {
+			mask(out[sp.start:sp.end])
+		}
+	}
+	s.held = append([]byte(nil), buf[cut:]...)
+	return out
+}
+
+// Held reports whether bytes are held back for the next Write or Flush
+func (s *Stream) Held() bool { return len(s.held) > 0 }
+
+// Flush returns the held bytes, redacted on their own
+func (s *Stream) Flush(f *Filter) []byte {
+	held := s.held
+	s.held = nil
+	if len(held) == 0 {
+		return nil
+	}
+	return f.Redact(held)
+}
+
+// holdPoint returns where the unfinished last line of buf starts, keeping at most maxHold
+// bytes back
+func holdPoint(buf []byte) int {
+	cut := 0
+	for i := len(buf) - 1; i >= 0; i-- {
+		if buf[i] == '\n' || buf[i] == '\r' {
+			cut = i + 1
+			break
+		}
+	}
+	if len(buf)-cut > maxHold {
+		cut = len(buf) - maxHold
+	}
+	return cut
+}
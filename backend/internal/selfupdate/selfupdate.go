@@ -0,0 +1,308 @@
+// Package selfupdate replaces the running bridge binary with a newer release. A release is
+// described by a JSON manifest at the release URL listing one binary per platform with its
+// SHA-256 digest and an ed25519 signature of that digest; nothing is installed unless both
+// match, and the swap is a rename so an interrupted update leaves the old binary in place.
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Build-time settings, overridden with -ldflags "-X github.com/example/rovobridge/internal/selfupdate.Version=1.2.3" etc.
+var (
+	// Version is the version of the running binary
+	Version = "dev"
+	// ReleaseURL is the default manifest URL
+	ReleaseURL = ""
+	// PublicKey is the default base64 ed25519 key release signatures are checked against
+	PublicKey = ""
+)
+
+const (
+	// maxManifestBytes bounds the manifest download
+	maxManifestBytes = 1 << 20
+	// maxBinaryBytes bounds the binary download
+	maxBinaryBytes = 256 << 20
+	// httpTimeout bounds each request, including the binary download
+	httpTimeout = 10 * time.Minute
+)
+
+// Manifest describes the latest release
+type Manifest struct {
+	Version string           `json:"version"`
+	Assets  map[string]Asset `json:"assets"` // by "<goos>-<goarch>"
+}
+
+// Asset is the binary of one platform. URL may be relative to the manifest.
+type Asset struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`    // hex digest of the binary
+	Signature string `json:"signature"` // base64 ed25519 signature of the raw SHA-256 digest
+}
+
+// Info compares the running version with the latest release
+type Info struct {
+	Current   string `json:"current"`
+	Latest    string `json:"latest"`
+	Available bool   `json:"available"`
+}
+
+// Updater checks for and installs releases
+type Updater struct {
+	ReleaseURL string
+	PublicKey  ed25519.PublicKey
+	Executable string // binary to replace; empty means the running one
+	Client     *http.Client
+}
+
+// New returns an updater for the manifest at releaseURL, verified with the base64 ed25519
+// key pubKey. Empty arguments fall back to the build-time defaults.
+func New(releaseURL, pubKey string) (*Updater, error) {
+	if releaseURL == "" {
+		releaseURL = ReleaseURL
+	}
+	if pubKey == "" {
+		pubKey = PublicKey
+	}
+	if releaseURL == "" {
+		return nil, errors.New("no release URL configured")
+	}
+	if err := checkURL(releaseURL); err != nil {
+		return nil, err
+	}
+	if pubKey == "" {
+		return nil, errors.New("no release public key configured")
+	}
+	key, err := base64.StdEncoding.DecodeString(pubKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("release public key must be a base64 ed25519 key")
+	}
+	return &Updater{ReleaseURL: releaseURL, PublicKey: ed25519.PublicKey(key)}, nil
+}
+
+// checkURL requires https, except for loopback hosts used by local mirrors and tests
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("bad release URL: %w", err)
+	}
+	if u.Scheme == "https" {
+		return nil
+	}
+	if ip := net.ParseIP(u.Hostname()); u.Scheme == "http" && (u.Hostname() == "localhost" || ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return fmt.Errorf("release URL %s must use https", raw)
+}
+
+func (u *Updater) client() *http.Client {
+	if u.Client != nil {
+		return u.Client
+	}
+	return &http.Client{Timeout: httpTimeout}
+}
+
+func (u *Updater) get(ctx context.Context, raw string, limit int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", raw, resp.Status)
+	}
+	if resp.ContentLength > limit {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %d bytes exceeds the %d byte limit", raw, resp.ContentLength, limit)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(resp.Body, limit+1), resp.Body}, nil
+}
+
+// Latest fetches the manifest and the asset for this platform
+func (u *Updater) Latest(ctx context.Context) (Manifest, Asset, error) {
+	var m Manifest
+	body, err := u.get(ctx, u.ReleaseURL, maxManifestBytes)
+	if err != nil {
+		return m, Asset{}, err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(&m); err != nil {
+		return m, Asset{}, fmt.Errorf("bad release manifest: %w", err)
+	}
+	if m.Version == "" {
+		return m, Asset{}, errors.New("bad release manifest: no version")
+	}
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	a, ok := m.Assets[platform]
+	if !ok {
+		return m, Asset{}, fmt.Errorf("release %s has no binary for %s", m.Version, platform)
+	}
+	base, _ := url.Parse(u.ReleaseURL)
+	ref, err := url.Parse(a.URL)
+	if err != nil || a.URL == "" {
+		return m, Asset{}, fmt.Errorf("release %s: bad binary URL %q", m.Version, a.URL)
+	}
+	a.URL = base.ResolveReference(ref).String()
+	if err := checkURL(a.URL); err != nil {
+		return m, Asset{}, err
+	}
+	return m, a, nil
+}
+
+// Check reports whether a newer release is available
+func (u *Updater) Check(ctx context.Context) (Info, error) {
+	m, _, err := u.Latest(ctx)
+	if err != nil {
+		return Info{Current: Version}, err
+	}
+	return Info{Current: Version, Latest: m.Version, Available: Newer(m.Version, Version)}, nil
+}
+
+// Apply installs the latest release if it is newer than the running version. The binary
+// is downloaded next to the executable, verified and renamed over it; the running process
+// keeps using the old one until it is restarted.
+func (u *Updater) Apply(ctx context.Context) (Info, error) {
+	m, a, err := u.Latest(ctx)
+	if err != nil {
+		return Info{Current: Version}, err
+	}
+	info := Info{Current: Version, Latest: m.Version, Available: Newer(m.Version, Version)}
+	if !info.Available {
+		return info, nil
+	}
+	exe := u.Executable
+	if exe == "" {
+		if exe, err = os.Executable(); err != nil {
+			return info, err
+		}
+		if exe, err = filepath.EvalSymlinks(exe); err != nil {
+			return info, err
+		}
+	}
+	tmp, err := u.download(ctx, a, filepath.Dir(exe))
+	if err != nil {
+		return info, err
+	}
+	if err := swap(exe, tmp); err != nil {
+		os.Remove(tmp)
+		return info, err
+	}
+	return info, nil
+}
+
+// download writes the asset to a temporary file in dir and verifies it
+func (u *Updater) download(ctx context.Context, a Asset, dir string) (string, error) {
+	want, err := hex.DecodeString(a.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return "", errors.New("release manifest has no valid sha256 for the binary")
+	}
+	sig, err := base64.StdEncoding.DecodeString(a.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return "", errors.New("release manifest has no valid signature for the binary")
+	}
+	body, err := u.get(ctx, a.URL, maxBinaryBytes)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	f, err := os.CreateTemp(dir, ".rovo-bridge-update-*")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > maxBinaryBytes {
+		err = fmt.Errorf("binary exceeds the %d byte limit", maxBinaryBytes)
+	}
+	sum := h.Sum(nil)
+	switch {
+	case err != nil:
+	case !strings.EqualFold(hex.EncodeToString(sum), a.SHA256):
+		err = fmt.Errorf("checksum mismatch: got %x, want %s", sum, a.SHA256)
+	case !ed25519.Verify(u.PublicKey, sum, sig):
+		err = errors.New("signature verification failed")
+	default:
+		err = os.Chmod(tmp, 0755)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// Newer reports whether version a is newer than b. Versions are dotted numbers with an
+// optional "v" prefix and a pre-release suffix after "-", which sorts before the release;
+// a version that does not parse (like "dev") is older than any that does.
+func Newer(a, b string) bool {
+	pa, oka := parseVersion(a)
+	pb, okb := parseVersion(b)
+	if !oka || !okb {
+		return oka && !okb
+	}
+	for i := 0; i < len(pa.nums) || i < len(pb.nums); i++ {
+		var x, y int
+		if i < len(pa.nums) {
+			x = pa.nums[i]
+		}
+		if i < len(pb.nums) {
+			y = pb.nums[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	if (pa.pre == "") != (pb.pre == "") {
+		return pa.pre == ""
+	}
+	return pa.pre > pb.pre
+}
+
+type version struct {
+	nums []int
+	pre  string
+}
+
+func parseVersion(s string) (version, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, pre, _ := strings.Cut(s, "-")
+	var v version
+	v.pre = pre
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version{}, false
+		}
+		v.nums = append(v.nums, n)
+	}
+	return v, true
+}
@@ -0,0 +1,159 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// release serves a manifest for version with binary, signed by priv, and returns its URL
+func release(t *testing.T, version string, binary []byte, priv ed25519.PrivateKey, tamper func(*Asset)) string {
+	t.Helper()
+	sum := sha256.Sum256(binary)
+	a := Asset{
+		URL:       "bin/rovo-bridge",
+		SHA256:    hex.EncodeToString(sum[:]),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, sum[:])),
+	}
+	if tamper != nil {
+		tamper(&a)
+	}
+	m := Manifest{Version: version, Assets: map[string]Asset{runtime.GOOS + "-" + runtime.GOARCH: a}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/releases/latest.json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(m)
+	})
+	mux.HandleFunc("/releases/bin/rovo-bridge", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(binary)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts.URL + "/releases/latest.json"
+}
+
+func newUpdater(t *testing.T, url string, pub ed25519.PublicKey) (*Updater, string) {
+	t.Helper()
+	u, err := New(url, base64.StdEncoding.EncodeToString(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Executable = filepath.Join(t.TempDir(), "rovo-bridge")
+	if err := os.WriteFile(u.Executable, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return u, u.Executable
+}
+
+func setVersion(t *testing.T, v string) {
+	old := Version
+	Version = v
+	t.Cleanup(func() { Version = old })
+}
+
+func TestApply_InstallsVerifiedRelease(t *testing.T) {
+	setVersion(t, "1.0.0")
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	u, exe := newUpdater(t, release(t, "1.1.0", []byte("new binary"), priv, nil), pub)
+
+	info, err := u.Check(context.Background())
+	if err != nil || !info.Available || info.Latest != "1.1.0" || info.Current != "1.0.0" {
+		t.Fatalf("check: %+v, %v", info, err)
+	}
+	if _, err := u.Apply(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "new binary" {
+		t.Fatalf("executable not replaced: %q", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(exe)); runtime.GOOS != "windows" && len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestApply_RejectsUnverifiedBinaries(t *testing.T) {
+	setVersion(t, "1.0.0")
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	cases := map[string]string{
+		"checksum": release(t, "1.1.0", []byte("new binary"), priv, func(a *Asset) {
+			a.SHA256 = strings.Repeat("0", 64)
+		}),
+		"signature": release(t, "1.1.0", []byte("new binary"), otherPriv, nil),
+		"unsigned": release(t, "1.1.0", []byte("new binary"), priv, func(a *Asset) {
+			a.Signature = ""
+		}),
+	}
+	for name, url := range cases {
+		u, exe := newUpdater(t, url, pub)
+		if _, err := u.Apply(context.Background()); err == nil {
+			t.Fatalf("%s: update applied", name)
+		}
+		if data, _ := os.ReadFile(exe); string(data) != "old" {
+			t.Fatalf("%s: executable changed to %q", name, data)
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(exe)); len(entries) != 1 {
+			t.Fatalf("%s: temporary files left behind: %v", name, entries)
+		}
+	}
+}
+
+func TestApply_SkipsWhenUpToDate(t *testing.T) {
+	setVersion(t, "v1.1.0")
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	u, exe := newUpdater(t, release(t, "1.1.0", []byte("new binary"), priv, nil), pub)
+	info, err := u.Apply(context.Background())
+	if err != nil || info.Available {
+		t.Fatalf("apply: %+v, %v", info, err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old" {
+		t.Fatalf("executable changed to %q", data)
+	}
+}
+
+func TestNew_RequiresHTTPSAndKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	key := base64.StdEncoding.EncodeToString(pub)
+	if _, err := New("http://example.com/latest.json", key); err == nil {
+		t.Fatal("plain http accepted")
+	}
+	if _, err := New("https://example.com/latest.json", ""); err == nil {
+		t.Fatal("missing key accepted")
+	}
+	if _, err := New("https://example.com/latest.json", "c2hvcnQ="); err == nil {
+		t.Fatal("short key accepted")
+	}
+	if _, err := New("https://example.com/latest.json", key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.0", "1.1.9", true},
+		{"1.10.0", "1.9.0", true},
+		{"v1.2", "1.2.0", false},
+		{"1.2.0", "1.2.0-rc1", true},
+		{"1.2.0-rc1", "1.2.0", false},
+		{"0.1.0", "dev", true},
+		{"dev", "0.1.0", false},
+		{"1.0.0", "1.0.1", false},
+	}
+	for _, c := range cases {
+		if got := Newer(c.a, c.b); got != c.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
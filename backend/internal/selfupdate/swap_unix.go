@@ -0,0 +1,11 @@
+//go:build !windows
+
+package selfupdate
+
+import "os"
+
+// swap replaces exe with the verified binary at tmp. rename is atomic on the same file
+// system, and the running process keeps its open copy of the old binary.
+func swap(exe, tmp string) error {
+	return os.Rename(tmp, exe)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package selfupdate
+
+import "os"
+
+// swap replaces exe with the verified binary at tmp. A running executable cannot be
+// overwritten on Windows but it can be renamed, so it is moved aside first and put back
+// if the new binary cannot take its place. The old copy is removed on the next update.
+func swap(exe, tmp string) error {
+	old := exe + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		_ = os.Rename(old, exe)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/rovobridge/internal/diagnostics"
+)
+
+const (
+	// maxFailures bounds the failing tests kept per result
+	maxFailures = 50
+	// maxFailureOutput bounds the output kept per failing test
+	maxFailureOutput = 4 << 10
+	// maxDiagnostics bounds the compiler/test errors kept per result
+	maxDiagnostics = 100
+	// tailLines is the amount of trailing plain output kept for failed runs
+	tailLines = 40
+)
+
+// Result is the outcome of a task run
+type Result struct {
+	Task        string                   `json:"task"`
+	ExitCode    int                      `json:"exitCode"`
+	Success     bool                     `json:"success"`
+	Canceled    bool                     `json:"canceled,omitempty"`
+	DurationMs  int64                    `json:"durationMs"`
+	Passed      int                      `json:"passed"`
+	Failed      int                      `json:"failed"`
+	Skipped     int                      `json:"skipped"`
+	Failures    []Failure                `json:"failures"`
+	Diagnostics []diagnostics.Diagnostic `json:"diagnostics"`
+	Tail        string                   `json:"tail,omitempty"` // last plain output lines of a failed run
+}
+
+// Failure is a failing test, or a package that failed without a failing test (e.g. a
+// build error)
+type Failure struct {
+	Package string `json:"package,omitempty"`
+	Name    string `json:"name"`
+	Output  string `json:"output,omitempty"`
+}
+
+// goTestEvent is a line of go test -json output
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+var (
+	// jest: "Tests:       1 failed, 4 passed, 5 total"
+	jestSummary = regexp.MustCompile(`^Tests:\s+(.+ total)`)
+	// jest failing test header: "  ● Suite › does things"
+	jestFailure = regexp.MustCompile(`^\s*● (.+)$`)
+	// pytest: "==== 1 failed, 2 passed, 1 skipped in 0.12s ===="
+	pytestSummary = regexp.MustCompile(`^=+ (.+) in [\d.]+s\b`)
+	// pytest short summary: "FAILED tests/test_a.py::test_x - assert 1 == 2"
+	pytestFailure = regexp.MustCompile(`^FAILED (\S+)(?: - (.*))?$`)
+	countPart     = regexp.MustCompile(`(\d+) (passed|failed|skipped)`)
+)
+
+// Parser accumulates the output of a task run line by line
+type Parser struct {
+	format string
+	res    Result
+	diag   map[string]bool
+	// go test output per running test and package, keyed by package + "\x00" + test
+	output map[string]*strings.Builder
+	tail   []string
+}
+
+// NewParser returns a parser for output in format
+func NewParser(task, format string) *Parser {
+	return &Parser{
+		format: format,
+		res:    Result{Task: task, Failures: []Failure{}, Diagnostics: []diagnostics.Diagnostic{}},
+		diag:   map[string]bool{},
+		output: map[string]*strings.Builder{},
+	}
+}
+
+// Line feeds one line of output, without its line terminator
+func (p *Parser) Line(line string) {
+	line = strings.TrimRight(line, "\r")
+	if p.format == FormatGoTest && strings.HasPrefix(line, "{") {
+		var ev goTestEvent
+		if json.Unmarshal([]byte(line), &ev) == nil && ev.Action != "" {
+			p.goEvent(ev)
+			return
+		}
+	}
+	p.text(line)
+}
+
+func (p *Parser) goEvent(ev goTestEvent) {
+	key := ev.Package + "\x00" + ev.Test
+	switch ev.Action {
+	case "output":
+		b := p.output[key]
+		if b == nil {
+			b = &strings.Builder{}
+			p.output[key] = b
+		}
+		if b.Len() < maxFailureOutput {
+			b.WriteString(ev.Output)
+		}
+		p.diagnostic(strings.TrimRight(ev.Output, "\n"))
+	case "pass", "skip":
+		if ev.Test != "" {
+			if ev.Action == "pass" {
+				p.res.Passed++
+			} else {
+				p.res.Skipped++
+			}
+		}
+		delete(p.output, key)
+	case "fail":
+		out := ""
+		if b := p.output[key]; b != nil {
+			out = b.String()
+		}
+		delete(p.output, key)
+		if ev.Test != "" {
+			p.res.Failed++
+			p.fail(Failure{Package: ev.Package, Name: ev.Test, Output: out})
+			return
+		}
+		// A package failing without a failing test did not build or crashed outside tests
+		for _, f := range p.res.Failures {
+			if f.Package == ev.Package {
+				return
+			}
+		}
+		p.fail(Failure{Package: ev.Package, Name: "(package)", Output: out})
+	}
+}
+
+func (p *Parser) text(line string) {
+	p.tail = append(p.tail, line)
+	if len(p.tail) > tailLines {
+		p.tail = p.tail[1:]
+	}
+	p.diagnostic(line)
+	switch {
+	case jestSummary.MatchString(line):
+		p.counts(jestSummary.FindStringSubmatch(line)[1])
+	case pytestSummary.MatchString(line):
+		p.counts(pytestSummary.FindStringSubmatch(line)[1])
+	case jestFailure.MatchString(line):
+		p.fail(Failure{Name: strings.TrimSpace(jestFailure.FindStringSubmatch(line)[1])})
+	case pytestFailure.MatchString(line):
+		sm := pytestFailure.FindStringSubmatch(line)
+		p.fail(Failure{Name: sm[1], Output: sm[2]})
+	}
+}
+
+// counts takes the totals from a runner's summary line
+func (p *Parser) counts(summary string) {
+	for _, sm := range countPart.FindAllStringSubmatch(summary, -1) {
+		n, _ := strconv.Atoi(sm[1])
+		switch sm[2] {
+		case "passed":
+			p.res.Passed = n
+		case "failed":
+			p.res.Failed = n
+		case "skipped":
+			p.res.Skipped = n
+		}
+	}
+}
+
+func (p *Parser) fail(f Failure) {
+	for _, have := range p.res.Failures {
+		if have.Package == f.Package && have.Name == f.Name {
+			return
+		}
+	}
+	if len(p.res.Failures) < maxFailures {
+		p.res.Failures = append(p.res.Failures, f)
+	}
+}
+
+func (p *Parser) diagnostic(line string) {
+	d, ok := diagnostics.Parse(line)
+	if !ok || len(p.res.Diagnostics) >= maxDiagnostics {
+		return
+	}
+	key := d.File + ":" + strconv.Itoa(d.Line) + ":" + strconv.Itoa(d.Column) + ":" + d.Message
+	if p.diag[key] {
+		return
+	}
+	p.diag[key] = true
+	p.res.Diagnostics = append(p.res.Diagnostics, d)
+}
+
+// Result completes the run with the process exit code and duration
+func (p *Parser) Result(exitCode int, d time.Duration) Result {
+	res := p.res
+	res.ExitCode = exitCode
+	res.DurationMs = d.Milliseconds()
+	res.Success = exitCode == 0 && res.Failed == 0
+	if !res.Success && len(p.tail) > 0 {
+		res.Tail = strings.Join(p.tail, "\n")
+	}
+	return res
+}
+
+// Summary renders the result as text to inject into a prompt
+func (r Result) Summary() string {
+	var b strings.Builder
+	switch {
+	case r.Canceled:
+		fmt.Fprintf(&b, "Task %q was canceled", r.Task)
+	case r.Success:
+		fmt.Fprintf(&b, "Task %q passed", r.Task)
+	default:
+		fmt.Fprintf(&b, "Task %q failed (exit code %d)", r.Task, r.ExitCode)
+	}
+	fmt.Fprintf(&b, ": %d passed, %d failed, %d skipped.\n", r.Passed, r.Failed, r.Skipped)
+	if len(r.Failures) > 0 {
+		b.WriteString("\nHere are the failing tests:\n")
+		for _, f := range r.Failures {
+			name := f.Name
+			if f.Package != "" {
+				name = f.Package + " " + f.Name
+			}
+			fmt.Fprintf(&b, "- %s\n", name)
+			if out := strings.TrimSpace(f.Output); out != "" {
+				b.WriteString("```\n" + out + "\n```\n")
+			}
+		}
+	}
+	if len(r.Diagnostics) > 0 {
+		b.WriteString("\nErrors:\n")
+		for _, d := range r.Diagnostics {
+			loc := d.File
+			if d.Line > 0 {
+				loc += ":" + strconv.Itoa(d.Line)
+			}
+			fmt.Fprintf(&b, "- %s: %s\n", loc, d.Message)
+		}
+	}
+	if len(r.Failures) == 0 && len(r.Diagnostics) == 0 && r.Tail != "" {
+		b.WriteString("\nOutput:\n```\n" + r.Tail + "\n```\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,87 @@
+// Package tasks runs configured project build and test commands and parses their output
+// into pass/fail results, so failures can be shown in the UI and injected into a prompt.
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// configPath is the checked-in task list, relative to the workspace root
+var configPath = filepath.Join(".rovobridge", "tasks.json")
+
+// Formats understood by Parser
+const (
+	FormatGoTest = "gotest" // go test -json
+	FormatText   = "text"   // plain output; diagnostics and jest/pytest summaries are recognized
+)
+
+// Task is a command that can be run in the workspace with runTask
+type Task struct {
+	Name   string   `json:"name"`
+	Cmd    string   `json:"cmd"`
+	Args   []string `json:"args,omitempty"`
+	Format string   `json:"format,omitempty"` // FormatGoTest or FormatText (default)
+}
+
+// configFile represents the structure of .rovobridge/tasks.json
+type configFile struct {
+	Tasks []Task `json:"tasks"`
+}
+
+// Load returns the tasks of .rovobridge/tasks.json in workspace, or the tasks detected
+// from the project files when there is no such file.
+func Load(workspace string) ([]Task, error) {
+	data, err := os.ReadFile(filepath.Join(workspace, configPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return Detect(workspace), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg configFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", configPath, err)
+	}
+	out := make([]Task, 0, len(cfg.Tasks))
+	for _, t := range cfg.Tasks {
+		if t.Name == "" || t.Cmd == "" {
+			continue
+		}
+		if t.Format == "" {
+			t.Format = FormatText
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// Detect proposes tasks for the project types found at the workspace root
+func Detect(workspace string) []Task {
+	out := []Task{}
+	if _, err := os.Stat(filepath.Join(workspace, "go.mod")); err == nil {
+		out = append(out, Task{Name: "go test", Cmd: "go", Args: []string{"test", "-json", "./..."}, Format: FormatGoTest})
+	}
+	if data, err := os.ReadFile(filepath.Join(workspace, "package.json")); err == nil {
+		var pkg struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		if json.Unmarshal(data, &pkg) == nil && pkg.Scripts["test"] != "" {
+			out = append(out, Task{Name: "npm test", Cmd: "npm", Args: []string{"test"}, Format: FormatText})
+		}
+	}
+	return out
+}
+
+// Find returns the task called name
+func Find(tasks []Task, name string) (Task, bool) {
+	for _, t := range tasks {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Task{}, false
+}
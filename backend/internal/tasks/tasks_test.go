@@ -0,0 +1,104 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_DetectsProjectTasks(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"scripts":{"test":"jest"}}`), 0644)
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "go test" || got[0].Format != FormatGoTest || got[1].Cmd != "npm" {
+		t.Fatalf("unexpected tasks: %+v", got)
+	}
+}
+
+func TestLoad_ConfigOverridesDetection(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644)
+	_ = os.MkdirAll(filepath.Join(dir, ".rovobridge"), 0755)
+	cfg := `{"tasks":[{"name":"unit","cmd":"make","args":["test"]},{"name":"","cmd":"x"}]}`
+	_ = os.WriteFile(filepath.Join(dir, ".rovobridge", "tasks.json"), []byte(cfg), 0644)
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "unit" || got[0].Format != FormatText {
+		t.Fatalf("unexpected tasks: %+v", got)
+	}
+	if _, ok := Find(got, "unit"); !ok {
+		t.Fatal("expected to find the unit task")
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, ".rovobridge", "tasks.json"), []byte("{"), 0644)
+	if _, err := Load(dir); err == nil {
+		t.Fatal("expected an error for a malformed task file")
+	}
+}
+
+func TestParser_GoTestJSON(t *testing.T) {
+	p := NewParser("go test", FormatGoTest)
+	for _, line := range []string{
+		`{"Action":"run","Package":"ex/a","Test":"TestOK"}`,
+		`{"Action":"pass","Package":"ex/a","Test":"TestOK"}`,
+		`{"Action":"run","Package":"ex/a","Test":"TestBad"}`,
+		`{"Action":"output","Package":"ex/a","Test":"TestBad","Output":"    a_test.go:12: expected 1, got 2\n"}`,
+		`{"Action":"fail","Package":"ex/a","Test":"TestBad"}`,
+		`{"Action":"skip","Package":"ex/a","Test":"TestLater"}`,
+		`{"Action":"fail","Package":"ex/a"}`,
+		`# ex/b`,
+		`b/b.go:3:2: undefined: x`,
+		`{"Action":"output","Package":"ex/b","Output":"FAIL\tex/b [build failed]\n"}`,
+		`{"Action":"fail","Package":"ex/b"}`,
+	} {
+		p.Line(line)
+	}
+	res := p.Result(1, time.Second)
+	if res.Success || res.Passed != 1 || res.Failed != 1 || res.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", res)
+	}
+	if len(res.Failures) != 2 || res.Failures[0].Name != "TestBad" || !strings.Contains(res.Failures[0].Output, "expected 1") || res.Failures[1].Package != "ex/b" {
+		t.Fatalf("unexpected failures: %+v", res.Failures)
+	}
+	if len(res.Diagnostics) != 2 || res.Diagnostics[1].File != "b/b.go" {
+		t.Fatalf("unexpected diagnostics: %+v", res.Diagnostics)
+	}
+	sum := res.Summary()
+	for _, want := range []string{`Task "go test" failed (exit code 1): 1 passed, 1 failed, 1 skipped.`, "- ex/a TestBad", "b/b.go:3: undefined: x"} {
+		if !strings.Contains(sum, want) {
+			t.Fatalf("summary lacks %q:\n%s", want, sum)
+		}
+	}
+}
+
+func TestParser_TextSummaries(t *testing.T) {
+	p := NewParser("npm test", FormatText)
+	for _, line := range []string{"  ● Math › adds", "Tests:       1 failed, 4 passed, 5 total"} {
+		p.Line(line)
+	}
+	if res := p.Result(1, 0); res.Failed != 1 || res.Passed != 4 || len(res.Failures) != 1 || res.Failures[0].Name != "Math › adds" {
+		t.Fatalf("unexpected jest result: %+v", res)
+	}
+
+	p = NewParser("pytest", FormatText)
+	for _, line := range []string{"FAILED tests/test_a.py::test_x - assert 1 == 2", "==== 1 failed, 2 passed, 1 skipped in 0.12s ===="} {
+		p.Line(line)
+	}
+	if res := p.Result(1, 0); res.Failed != 1 || res.Passed != 2 || res.Skipped != 1 || res.Failures[0].Output != "assert 1 == 2" {
+		t.Fatalf("unexpected pytest result: %+v", res)
+	}
+
+	p = NewParser("build", FormatText)
+	p.Line("boom")
+	if res := p.Result(2, 0); res.Success || !strings.Contains(res.Summary(), "boom") {
+		t.Fatalf("expected the output tail in a failed run's summary: %+v", res)
+	}
+}
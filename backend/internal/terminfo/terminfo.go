@@ -0,0 +1,120 @@
+// Package terminfo is a small embedded database of the terminal types the bridge's
+// terminal can stand in for. A session started with a TERM outside of it may get output
+// the terminal does not render as the program meant, so the bridge checks the TERM a
+// client asks for and can fall back to Fallback.
+package terminfo
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Fallback is the terminal type the bridge's terminal renders best, used in place of an
+// unknown one
+const Fallback = "xterm-256color"
+
+//go:embed terms.txt
+var termsFile string
+
+// Entry describes a terminal type
+type Entry struct {
+	Name         string   `json:"name"`
+	Colors       int      `json:"colors"`
+	Capabilities []string `json:"capabilities,omitempty" doc:"Of altscreen, bce, mouse, title and truecolor"`
+}
+
+// Has reports whether the terminal type has capability c
+func (e Entry) Has(c string) bool {
+	return slices.Contains(e.Capabilities, c)
+}
+
+var (
+	loadOnce sync.Once
+	entries  map[string]Entry
+	names    []string
+)
+
+// load parses the embedded database once
+func load() {
+	loadOnce.Do(func() {
+		var err error
+		if entries, names, err = parse(termsFile); err != nil {
+			panic(err)
+		}
+	})
+}
+
+// parse reads a database: a terminal type per line with its name, its number of colors
+// and its comma-separated capabilities; # starts a comment
+func parse(s string) (map[string]Entry, []string, error) {
+	byName := map[string]Entry{}
+	var order []string
+	for i, line := range strings.Split(s, "\n") {
+		if c := strings.IndexByte(line, '#'); c >= 0 {
+			line = line[:c]
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) < 2 || len(f) > 3 {
+			return nil, nil, fmt.Errorf("terminfo: line %d: want name, colors and capabilities", i+1)
+		}
+		colors, err := strconv.Atoi(f[1])
+		if err != nil || colors < 0 {
+			return nil, nil, fmt.Errorf("terminfo: line %d: bad colors %q", i+1, f[1])
+		}
+		e := Entry{Name: f[0], Colors: colors}
+		if len(f) == 3 {
+			e.Capabilities = strings.Split(f[2], ",")
+		}
+		byName[e.Name] = e
+		order = append(order, e.Name)
+	}
+	return byName, order, nil
+}
+
+// Lookup returns the terminal type named term
+func Lookup(term string) (Entry, bool) {
+	load()
+	e, ok := entries[term]
+	return e, ok
+}
+
+// Names lists the known terminal types, the preferred first
+func Names() []string {
+	load()
+	return slices.Clone(names)
+}
+
+// Check reports why term is not a known terminal type, with a close known one when term
+// looks like a variant of it (xterm-256color-italic, screen.xterm-256color); nil when it
+// is known
+func Check(term string) error {
+	if term == "" {
+		return fmt.Errorf("TERM is empty")
+	}
+	if _, ok := Lookup(term); ok {
+		return nil
+	}
+	if near := nearest(term); near != "" {
+		return fmt.Errorf("unknown terminal type %q (did you mean %s?)", term, near)
+	}
+	return fmt.Errorf("unknown terminal type %q", term)
+}
+
+// nearest returns the longest known name term starts or ends with, as in the variants
+// terminfo databases name after a base type
+func nearest(term string) string {
+	best := ""
+	for _, n := range Names() {
+		if len(n) > len(best) && (strings.HasPrefix(term, n+"-") || strings.HasSuffix(term, "."+n)) {
+			best = n
+		}
+	}
+	return best
+}
@@ -0,0 +1,52 @@
+package terminfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	e, ok := Lookup("xterm-256color")
+	if !ok || e.Colors != 256 || !e.Has("altscreen") || e.Has("truecolor") {
+		t.Fatalf("unexpected xterm-256color: %+v, %v", e, ok)
+	}
+	if e, ok := Lookup("dumb"); !ok || e.Colors != 0 || len(e.Capabilities) != 0 {
+		t.Fatalf("unexpected dumb: %+v, %v", e, ok)
+	}
+	if _, ok := Lookup("hpterm-fancy"); ok {
+		t.Fatal("unexpected entry for hpterm-fancy")
+	}
+	if names := Names(); names[0] != Fallback {
+		t.Fatalf("expected the fallback first, got %v", names[:3])
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for term, want := range map[string]string{
+		"tmux-256color":         "",
+		"":                      "empty",
+		"xterm-256color-italic": "did you mean xterm-256color?",
+		"screen.xterm-256color": "did you mean xterm-256color?",
+		"hpterm":                `unknown terminal type "hpterm"`,
+	} {
+		err := Check(term)
+		if want == "" {
+			if err != nil {
+				t.Errorf("Check(%q) = %v", term, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Check(%q) = %v, want %q", term, err, want)
+		}
+	}
+}
+
+func TestParseRejectsBadLines(t *testing.T) {
+	if _, _, err := parse("xterm eight altscreen\n"); err == nil {
+		t.Fatal("expected an error for bad colors")
+	}
+	if _, _, err := parse("xterm 8 a b\n"); err == nil {
+		t.Fatal("expected an error for extra fields")
+	}
+}
@@ -0,0 +1,178 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ledgerSaveDelay debounces ledger writes; agents print usage after every response
+	ledgerSaveDelay = 2 * time.Second
+	// maxDays bounds the ledger; the oldest days are dropped first
+	maxDays = 366
+	// dayLayout formats the local date a usage is counted on
+	dayLayout = "2006-01-02"
+)
+
+// Day is the usage counted on one local date
+type Day struct {
+	Date  string `json:"date" doc:"Local date, YYYY-MM-DD"`
+	Usage Usage  `json:"usage"`
+}
+
+// ledgerFile is the structure of the ledger file
+type ledgerFile struct {
+	Version string `json:"version"`
+	Days    []Day  `json:"days"`
+}
+
+// Ledger keeps the usage of all sessions per day. Updates are applied in memory and
+// written to disk after ledgerSaveDelay of inactivity, so daily totals survive restarts.
+type Ledger struct {
+	filePath string // empty keeps the ledger in memory only
+	delay    time.Duration
+
+	mu     sync.Mutex
+	days   map[string]Usage
+	loaded bool
+	dirty  bool
+	timer  *time.Timer
+}
+
+// DefaultPath returns the ledger file next to the prompt history in the home directory
+func DefaultPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Printf("Failed to get user home directory, using current directory: %v", err)
+		return ".rovobridge-usage"
+	}
+	return filepath.Join(homeDir, ".rovobridge-usage")
+}
+
+// NewLedger creates a Ledger persisting to filePath; empty keeps it in memory only
+func NewLedger(filePath string) *Ledger {
+	return &Ledger{filePath: filePath, delay: ledgerSaveDelay}
+}
+
+// Add counts u on the local date of at
+func (l *Ledger) Add(at time.Time, u Usage) {
+	if u.IsZero() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadUnsafe()
+	day := at.Format(dayLayout)
+	l.days[day] = l.days[day].Add(u)
+	l.scheduleUnsafe()
+}
+
+// Days returns the usage of the last n days up to and including the date of now, oldest
+// first; days without usage are included with zero usage
+func (l *Ledger) Days(now time.Time, n int) []Day {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadUnsafe()
+	days := make([]Day, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i).Format(dayLayout)
+		days = append(days, Day{Date: date, Usage: l.days[date]})
+	}
+	return days
+}
+
+// Flush writes pending changes to disk immediately
+func (l *Ledger) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	return l.writeUnsafe()
+}
+
+// scheduleUnsafe marks the ledger dirty and (re)arms the debounce timer
+func (l *Ledger) scheduleUnsafe() {
+	l.dirty = true
+	if l.filePath == "" {
+		return
+	}
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.timer = time.AfterFunc(l.delay, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.timer = nil
+		if err := l.writeUnsafe(); err != nil {
+			log.Printf("Failed to save usage ledger: %v", err)
+		}
+	})
+}
+
+// loadUnsafe reads the ledger file once; a missing or unreadable file yields no usage
+func (l *Ledger) loadUnsafe() {
+	if l.loaded {
+		return
+	}
+	l.loaded = true
+	l.days = map[string]Usage{}
+	if l.filePath == "" {
+		return
+	}
+	data, err := os.ReadFile(l.filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read usage ledger %s: %v", l.filePath, err)
+		}
+		return
+	}
+	var file ledgerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("Failed to parse usage ledger %s, starting afresh: %v", l.filePath, err)
+		return
+	}
+	for _, d := range file.Days {
+		l.days[d.Date] = d.Usage
+	}
+}
+
+// writeUnsafe persists the ledger atomically when there are unsaved changes
+func (l *Ledger) writeUnsafe() error {
+	if !l.dirty || l.filePath == "" {
+		return nil
+	}
+	days := make([]Day, 0, len(l.days))
+	for date, u := range l.days {
+		days = append(days, Day{Date: date, Usage: u})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	if len(days) > maxDays {
+		days = days[len(days)-maxDays:]
+	}
+
+	data, err := json.MarshalIndent(ledgerFile{Version: "1.0", Days: days}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create usage ledger directory: %w", err)
+	}
+	tempFile := l.filePath + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temporary usage ledger %s: %w", tempFile, err)
+	}
+	if err := os.Rename(tempFile, l.filePath); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename temporary usage ledger: %w", err)
+	}
+	l.dirty = false
+	return nil
+}
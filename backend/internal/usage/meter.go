@@ -0,0 +1,51 @@
+package usage
+
+// Meter totals the readings of one session. Cumulative readings replace the session's
+// figures, as redraws and repeated summaries must not count twice; per-response readings
+// add to them.
+type Meter struct {
+	total Usage
+	last  Usage // last cumulative reading of each figure
+}
+
+// Record adds a reading and returns by how much it increased the session's usage, for
+// the daily totals. A cumulative figure lower than the previous one means the agent
+// started counting afresh, e.g. after /clear, so all of it is new.
+func (m *Meter) Record(r Reading) Usage {
+	if !r.Cumulative {
+		m.total = m.total.Add(r.Usage)
+		return r.Usage
+	}
+	var delta Usage
+	step := func(v int64, last, total *int64) int64 {
+		if v == 0 {
+			return 0
+		}
+		d := v - *last
+		if d < 0 {
+			d = v
+		}
+		*last = v
+		*total += d
+		return d
+	}
+	delta.InputTokens = step(r.InputTokens, &m.last.InputTokens, &m.total.InputTokens)
+	delta.OutputTokens = step(r.OutputTokens, &m.last.OutputTokens, &m.total.OutputTokens)
+	delta.Tokens = step(r.Tokens, &m.last.Tokens, &m.total.Tokens)
+	delta.Requests = step(r.Requests, &m.last.Requests, &m.total.Requests)
+	if r.CostUSD > 0 {
+		d := r.CostUSD - m.last.CostUSD
+		if d < 0 {
+			d = r.CostUSD
+		}
+		m.last.CostUSD = r.CostUSD
+		m.total.CostUSD += d
+		delta.CostUSD = d
+	}
+	return delta
+}
+
+// Usage returns the session's usage so far
+func (m *Meter) Usage() Usage {
+	return m.total
+}
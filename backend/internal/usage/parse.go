@@ -0,0 +1,133 @@
+// Package usage recognizes the token, request and cost figures agent CLIs print in their
+// output and totals them per session and per day, so consumption can be tracked without
+// scraping the terminal.
+package usage
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Usage is consumption in tokens, model requests and cost
+type Usage struct {
+	InputTokens  int64   `json:"inputTokens,omitempty"`
+	OutputTokens int64   `json:"outputTokens,omitempty"`
+	Tokens       int64   `json:"tokens,omitempty" doc:"All tokens; input plus output when the agent prints both"`
+	Requests     int64   `json:"requests,omitempty"`
+	CostUSD      float64 `json:"costUsd,omitempty"`
+}
+
+// Add returns the sum of u and v
+func (u Usage) Add(v Usage) Usage {
+	return Usage{
+		InputTokens:  u.InputTokens + v.InputTokens,
+		OutputTokens: u.OutputTokens + v.OutputTokens,
+		Tokens:       u.Tokens + v.Tokens,
+		Requests:     u.Requests + v.Requests,
+		CostUSD:      u.CostUSD + v.CostUSD,
+	}
+}
+
+// IsZero reports whether u holds no consumption
+func (u Usage) IsZero() bool {
+	return u == Usage{}
+}
+
+// Reading is the usage one output line reports
+type Reading struct {
+	Usage
+	// Cumulative readings ("Total tokens: ...", "Session cost: ...") give the session's
+	// usage so far; the others give the usage of one response.
+	Cumulative bool
+}
+
+const number = `(\d[\d,]*(?:\.\d+)?\s*[kKmM]?)`
+
+var (
+	cumulativeRe = regexp.MustCompile(`(?i)\b(total|session|so far|cumulative)\b`)
+	// Cost: $0.12 / Session cost: $1.23
+	costRe = regexp.MustCompile(`(?i)\bcost\s*[:=]\s*\$\s*(\d[\d,]*(?:\.\d+)?)`)
+	// Input tokens: 1,200 / Prompt tokens = 1.2k
+	inputRe = regexp.MustCompile(`(?i)\b(?:input|prompt)\s+tokens?\s*[:=]\s*` + number)
+	// Output tokens: 300 / Completion tokens: 300
+	outputRe = regexp.MustCompile(`(?i)\b(?:output|completion)\s+tokens?\s*[:=]\s*` + number)
+	// Tokens: 1.2k in, 300 out
+	inOutRe = regexp.MustCompile(`(?i)\btokens?\s*[:=]\s*` + number + `\s*(?:in|input)\b[,/ ]*` + number + `\s*(?:out|output)\b`)
+	// Tokens used: 1,234 / Total tokens: 5.6k / Used 1,234 tokens / 1,234 tokens used
+	tokensRe = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\btokens?(?:\s+used)?\s*[:=]\s*` + number + `(?:\s|$|[,;|·/])`),
+		regexp.MustCompile(`(?i)\bused\s+` + number + `\s+tokens?\b`),
+		regexp.MustCompile(`(?i)^\W*` + number + `\s+tokens?\s+used\b`),
+	}
+	// Requests: 3 / 3 requests used
+	requestsRe = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\brequests?(?:\s+used)?\s*[:=]\s*(\d[\d,]*)`),
+		regexp.MustCompile(`(?i)\b(\d[\d,]*)\s+requests?\s+used\b`),
+	}
+)
+
+// Parse recognizes the usage reported by a single plain-text output line. It reports
+// false for lines reporting none.
+func Parse(line string) (Reading, bool) {
+	line = strings.TrimSpace(line)
+	if len(line) < 6 || len(line) > 300 {
+		return Reading{}, false
+	}
+	lower := strings.ToLower(line)
+	if !strings.Contains(lower, "token") && !strings.Contains(lower, "cost") && !strings.Contains(lower, "request") {
+		return Reading{}, false
+	}
+	var r Reading
+	if sm := costRe.FindStringSubmatch(line); sm != nil {
+		r.CostUSD, _ = strconv.ParseFloat(strings.ReplaceAll(sm[1], ",", ""), 64)
+	}
+	if sm := inOutRe.FindStringSubmatch(line); sm != nil {
+		r.InputTokens, r.OutputTokens = parseCount(sm[1]), parseCount(sm[2])
+	} else {
+		if sm := inputRe.FindStringSubmatch(line); sm != nil {
+			r.InputTokens = parseCount(sm[1])
+		}
+		if sm := outputRe.FindStringSubmatch(line); sm != nil {
+			r.OutputTokens = parseCount(sm[1])
+		}
+	}
+	if r.InputTokens > 0 || r.OutputTokens > 0 {
+		r.Tokens = r.InputTokens + r.OutputTokens
+	} else {
+		for _, re := range tokensRe {
+			if sm := re.FindStringSubmatch(line); sm != nil {
+				r.Tokens = parseCount(sm[1])
+				break
+			}
+		}
+	}
+	for _, re := range requestsRe {
+		if sm := re.FindStringSubmatch(line); sm != nil {
+			r.Requests = parseCount(sm[1])
+			break
+		}
+	}
+	if r.IsZero() {
+		return Reading{}, false
+	}
+	r.Cumulative = cumulativeRe.MatchString(line)
+	return r, true
+}
+
+// parseCount parses 12,345, 1.2k or 3.4M
+func parseCount(s string) int64 {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "k") || strings.HasSuffix(s, "K"):
+		mult, s = 1e3, strings.TrimSpace(s[:len(s)-1])
+	case strings.HasSuffix(s, "m") || strings.HasSuffix(s, "M"):
+		mult, s = 1e6, strings.TrimSpace(s[:len(s)-1])
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(f*mult + 0.5)
+}
@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		line string
+		want Reading
+	}{
+		{"Tokens used: 1,234", Reading{Usage: Usage{Tokens: 1234}}},
+		{"│ Total tokens: 5.6k │", Reading{Usage: Usage{Tokens: 5600}, Cumulative: true}},
+		{"Used 820 tokens (2 requests used)", Reading{Usage: Usage{Tokens: 820, Requests: 2}}},
+		{"Input tokens: 1,200  Output tokens: 300", Reading{Usage: Usage{InputTokens: 1200, OutputTokens: 300, Tokens: 1500}}},
+		{"Tokens: 1.2k in, 300 out · Cost: $0.02 · Requests: 1", Reading{Usage: Usage{InputTokens: 1200, OutputTokens: 300, Tokens: 1500, Requests: 1, CostUSD: 0.02}}},
+		{"Session cost: $1.25", Reading{Usage: Usage{CostUSD: 1.25}, Cumulative: true}},
+		{"Session tokens: 2.5M", Reading{Usage: Usage{Tokens: 2500000}, Cumulative: true}},
+	}
+	for _, c := range cases {
+		got, ok := Parse(c.line)
+		if !ok {
+			t.Errorf("Parse(%q) did not match", c.line)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", c.line, got, c.want)
+		}
+	}
+
+	for _, line := range []string{"", "Refactoring the token parser", "This costs $5 per month", "The request failed: 500", "tokens.go:12: undefined"} {
+		if r, ok := Parse(line); ok {
+			t.Errorf("Parse(%q) = %+v, want no usage", line, r)
+		}
+	}
+}
+
+func TestMeter(t *testing.T) {
+	var m Meter
+	if d := m.Record(Reading{Usage: Usage{Tokens: 100, Requests: 1}}); d.Tokens != 100 {
+		t.Fatalf("increment delta = %+v", d)
+	}
+	m.Record(Reading{Usage: Usage{Tokens: 50, Requests: 1}})
+	if u := m.Usage(); u.Tokens != 150 || u.Requests != 2 {
+		t.Fatalf("after increments = %+v", u)
+	}
+
+	// A summary redrawn with the same figures counts once
+	for range 3 {
+		m.Record(Reading{Usage: Usage{CostUSD: 0.5}, Cumulative: true})
+	}
+	if d := m.Record(Reading{Usage: Usage{CostUSD: 0.75}, Cumulative: true}); d.CostUSD != 0.25 {
+		t.Fatalf("cumulative delta = %+v", d)
+	}
+	// The agent started counting afresh
+	if d := m.Record(Reading{Usage: Usage{CostUSD: 0.1}, Cumulative: true}); d.CostUSD != 0.1 {
+		t.Fatalf("reset delta = %+v", d)
+	}
+	if u := m.Usage(); u.CostUSD < 0.849 || u.CostUSD > 0.851 {
+		t.Fatalf("cost = %v", u.CostUSD)
+	}
+}
+
+func TestLedger_PersistsDays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage")
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.Local)
+	l := NewLedger(path)
+	l.Add(now.AddDate(0, 0, -1), Usage{Tokens: 10})
+	l.Add(now, Usage{Tokens: 5, CostUSD: 0.5})
+	l.Add(now, Usage{Tokens: 7})
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	days := NewLedger(path).Days(now, 3)
+	want := []Day{{Date: "2026-03-08"}, {Date: "2026-03-09", Usage: Usage{Tokens: 10}}, {Date: "2026-03-10", Usage: Usage{Tokens: 12, CostUSD: 0.5}}}
+	if len(days) != len(want) {
+		t.Fatalf("days = %+v", days)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("day %d = %+v, want %+v", i, days[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultQuotaWindow is the window soft quotas are measured over when Quota.Window is unset
+const defaultQuotaWindow = time.Minute
+
+// Quota is a soft per-connection traffic limit. A connection moving more bytes than
+// allowed within a window is logged, counted in stats and sent a quotaWarning once per
+// window; nothing is dropped. Runaway frontends (a JCEF tab re-requesting snapshots in a
+// loop, say) show up this way before they exhaust memory.
+type Quota struct {
+	SentBytes     int64         // bytes written to the client per window; 0 = no quota
+	ReceivedBytes int64         // bytes read from the client per window; 0 = no quota
+	Window        time.Duration // defaults to a minute
+}
+
+func (q Quota) window() time.Duration {
+	if q.Window <= 0 {
+		return defaultQuotaWindow
+	}
+	return q.Window
+}
+
+// QuotaWarning records a connection going over its quota
+type QuotaWarning struct {
+	Remote    string    `json:"remote"`
+	Direction string    `json:"direction"` // "sent" or "received"
+	Bytes     int64     `json:"bytes"`     // moved in the window so far
+	Limit     int64     `json:"limit"`
+	At        time.Time `json:"at"`
+}
+
+// connUsage counts the traffic of one connection
+type connUsage struct {
+	connectedAt  time.Time
+	bytesSent    atomic.Int64
+	bytesRecv    atomic.Int64
+	framesSent   atomic.Int64
+	messagesRecv atomic.Int64
+	warnings     atomic.Int64
+
+	mu          sync.Mutex // guards the window counters below
+	windowStart time.Time
+	windowSent  int64
+	windowRecv  int64
+	warnedSent  bool
+	warnedRecv  bool
+}
+
+// add counts n bytes moved in one frame and returns a warning when they take the
+// connection over its quota for the first time in the current window
+func (u *connUsage) add(sent bool, n int, q Quota, now time.Time) *QuotaWarning {
+	if sent {
+		u.bytesSent.Add(int64(n))
+		u.framesSent.Add(1)
+	} else {
+		u.bytesRecv.Add(int64(n))
+		u.messagesRecv.Add(1)
+	}
+	if q.SentBytes <= 0 && q.ReceivedBytes <= 0 {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if now.Sub(u.windowStart) >= q.window() {
+		u.windowStart = now
+		u.windowSent, u.windowRecv = 0, 0
+		u.warnedSent, u.warnedRecv = false, false
+	}
+	var used, limit int64
+	var warned *bool
+	direction := "received"
+	if sent {
+		u.windowSent += int64(n)
+		used, limit, warned, direction = u.windowSent, q.SentBytes, &u.warnedSent, "sent"
+	} else {
+		u.windowRecv += int64(n)
+		used, limit, warned = u.windowRecv, q.ReceivedBytes, &u.warnedRecv
+	}
+	if limit <= 0 || used <= limit || *warned {
+		return nil
+	}
+	*warned = true
+	u.warnings.Add(1)
+	return &QuotaWarning{Direction: direction, Bytes: used, Limit: limit, At: now}
+}
+
+// ConnStats is the traffic of one open connection
+type ConnStats struct {
+	Remote           string    `json:"remote"`
+	Origin           string    `json:"origin,omitempty"`
+	ConnectedAt      time.Time `json:"connectedAt"`
+	BytesSent        int64     `json:"bytesSent"`
+	BytesReceived    int64     `json:"bytesReceived"`
+	FramesSent       int64     `json:"framesSent"`
+	MessagesReceived int64     `json:"messagesReceived"`
+	Queued           int       `json:"queued"`
+	QuotaWarnings    int64     `json:"quotaWarnings,omitempty"`
+	// QueuedByChannel splits Queued by channel for connections that negotiated channels
+	QueuedByChannel map[string]int `json:"queuedByChannel,omitempty"`
+}
+
+// stats returns the traffic counters of the connection
+func (w *connWriter) stats() ConnStats {
+	u := &w.usage
+	var byChannel map[string]int
+	if w.mux.Load() {
+		byChannel = make(map[string]int, numChannels)
+		for ch, q := range w.queues {
+			byChannel[channelNames[ch]] = len(q)
+		}
+	}
+	return ConnStats{
+		Origin:           w.origin,
+		Remote:           w.c.RemoteAddr().String(),
+		ConnectedAt:      u.connectedAt,
+		BytesSent:        u.bytesSent.Load(),
+		BytesReceived:    u.bytesRecv.Load(),
+		FramesSent:       u.framesSent.Load(),
+		MessagesReceived: u.messagesRecv.Load(),
+		Queued:           w.pending(),
+		QuotaWarnings:    u.warnings.Load(),
+		QueuedByChannel:  byChannel,
+	}
+}
+
+// account counts a frame written to or read from a connection against its quota. Going
+// over it is logged, recorded for stats and reported to the client.
+func (s *Server) account(w *connWriter, sent bool, n int) {
+	warn := w.usage.add(sent, n, s.Quota, time.Now())
+	if warn == nil {
+		return
+	}
+	warn.Remote = w.c.RemoteAddr().String()
+	log.Printf("ws: client %s over its %s quota: %d bytes in %s (limit %d)", warn.Remote, warn.Direction, warn.Bytes, s.Quota.window(), warn.Limit)
+	s.mu.Lock()
+	s.quotaWarnings++
+	s.lastQuotaWarning = warn
+	s.mu.Unlock()
+	buf, err := json.Marshal(map[string]any{
+		"type":          "quotaWarning",
+		"direction":     warn.Direction,
+		"bytes":         warn.Bytes,
+		"limit":         warn.Limit,
+		"windowSeconds": s.Quota.window().Seconds(),
+	})
+	if err == nil {
+		_ = w.enqueue(buf, chControl)
+	}
+}
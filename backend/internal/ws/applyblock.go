@@ -0,0 +1,249 @@
+package ws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/example/rovobridge/internal/fileutil"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxApplyFileBytes bounds the files a code block can be applied to
+	maxApplyFileBytes = 4 << 20
+	// applyDiffContext is the unchanged lines shown around each change of a preview
+	applyDiffContext = 3
+)
+
+// codeBlockEdit is where applyCodeBlock puts a block: the whole file when no range is
+// given, else in place of the 1-based lines StartLine to EndLine. EndLine below StartLine
+// inserts the block before StartLine.
+type codeBlockEdit struct {
+	Path      string
+	StartLine int
+	EndLine   int
+}
+
+// resolveWritePath resolves p against the session working directory for writing: the
+// file, or for a new file its directory, must be inside the working directory after
+// following symlinks. It also reports whether the file exists.
+func resolveWritePath(workingDir, p string) (string, bool, error) {
+	if p == "" {
+		return "", false, errors.New("no path")
+	}
+	abs := resolveSessionPath(workingDir, p)
+	real, err := filepath.EvalSymlinks(abs)
+	exists := err == nil
+	if errors.Is(err, fs.ErrNotExist) {
+		dir, derr := filepath.EvalSymlinks(filepath.Dir(abs))
+		if derr != nil {
+			return "", false, derr
+		}
+		real, err = filepath.Join(dir, filepath.Base(abs)), nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	root := workingDir
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", false, errOutsideWorkspace
+	}
+	if exists {
+		fi, err := os.Stat(real)
+		if err != nil {
+			return "", false, err
+		}
+		if !fi.Mode().IsRegular() {
+			return "", false, errors.New("not a regular file")
+		}
+		if fi.Size() > maxApplyFileBytes {
+			return "", false, errors.New("file is too large to edit")
+		}
+	}
+	return real, exists, nil
+}
+
+// spliceLines puts block in place of the 1-based lines start to end of old, or replaces
+// all of old when start is 0
+func spliceLines(old, block string, start, end int) (string, error) {
+	if start <= 0 {
+		return block, nil
+	}
+	lines := strings.SplitAfter(old, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if start > len(lines)+1 || end > len(lines) {
+		return "", errors.New("line range is past the end of the file")
+	}
+	end = max(end, start-1)
+	if block != "" && !strings.HasSuffix(block, "\n") && end < len(lines) {
+		block += "\n"
+	}
+	// A block inserted after a last line without a newline starts on a line of its own
+	if start > 1 && !strings.HasSuffix(lines[start-2], "\n") {
+		lines[start-2] += "\n"
+	}
+	var sb strings.Builder
+	for _, l := range lines[:start-1] {
+		sb.WriteString(l)
+	}
+	sb.WriteString(block)
+	for _, l := range lines[end:] {
+		sb.WriteString(l)
+	}
+	return sb.String(), nil
+}
+
+// applyStateID identifies an edit by the file it was planned on and the result, so a
+// confirmed edit fails when the file changed in between
+func applyStateID(abs string, exists bool, old, updated string) string {
+	h := sha256.New()
+	h.Write([]byte(abs))
+	if exists {
+		h.Write([]byte{1})
+	}
+	h.Write([]byte(old))
+	h.Write([]byte{0})
+	h.Write([]byte(updated))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the same
+// directory, keeping the mode of the file it replaces
+func writeFileAtomic(path string, data []byte) error {
+	mode := fs.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// applyCodeBlock answers applyCodeBlock with codeBlockPreview, the diff the edit would
+// make. Unless dryRun is set it then asks for confirmation, like every write to the
+// workspace, and writes the file once confirmed, unless the file changed in the
+// meantime (previewStale).
+func (r *Router) applyCodeBlock(conn *websocket.Conn, sid string, blockID int, edit codeBlockEdit, dryRun bool) error {
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	if st == nil {
+		Errorf(conn, "no session")
+		return nil
+	}
+	st.mu.Lock()
+	block, ok := st.codeBlocks.get(blockID)
+	st.mu.Unlock()
+	if !ok {
+		Errorf(conn, "applyCodeBlock: unknown code block %d", blockID)
+		return nil
+	}
+	abs, exists, old, updated, ok := r.planCodeBlock(conn, sid, block.Content, edit)
+	if !ok {
+		return nil
+	}
+	oldName := "a/" + filepath.ToSlash(edit.Path)
+	if !exists {
+		oldName = "/dev/null"
+	}
+	diff, added, removed := fileutil.UnifiedDiff(oldName, "b/"+filepath.ToSlash(edit.Path), old, updated, applyDiffContext)
+	if err := SendJSON(conn, map[string]any{
+		"type":      "codeBlockPreview",
+		"sessionId": sid,
+		"blockId":   blockID,
+		"path":      edit.Path,
+		"created":   !exists,
+		"diff":      diff,
+		"added":     added,
+		"removed":   removed,
+	}); err != nil || dryRun {
+		return err
+	}
+
+	id := applyStateID(abs, exists, old, updated)
+	summary := fmt.Sprintf("Write code block %d to %s (+%d -%d lines)", blockID, edit.Path, added, removed)
+	return r.requireConfirmation(conn, "applyCodeBlock", summary, func() error {
+		r.fileWriteMu.Lock()
+		defer r.fileWriteMu.Unlock()
+		nowAbs, nowExists, nowOld, nowUpdated, ok := r.planCodeBlock(conn, sid, block.Content, edit)
+		if !ok {
+			return nil
+		}
+		if applyStateID(nowAbs, nowExists, nowOld, nowUpdated) != id {
+			ErrorCode(conn, "previewStale", map[string]any{"sessionId": sid, "blockId": blockID, "path": edit.Path},
+				"applyCodeBlock: %s changed since the preview; apply again", edit.Path)
+			return nil
+		}
+		if err := writeFileAtomic(abs, []byte(updated)); err != nil {
+			Errorf(conn, "applyCodeBlock: %v", err)
+			return nil
+		}
+		r.logSessionEvent(sid, "codeBlockApplied", map[string]any{"blockId": blockID, "path": edit.Path, "bytes": len(updated), "created": !exists})
+		return SendJSON(conn, map[string]any{
+			"type":      "codeBlockApplied",
+			"sessionId": sid,
+			"blockId":   blockID,
+			"path":      edit.Path,
+			"created":   !exists,
+			"bytes":     len(updated),
+		})
+	})
+}
+
+// planCodeBlock resolves the file of a code block edit and computes its new content,
+// answering conn when it cannot. It returns the file, whether it exists, and its old and
+// new content.
+func (r *Router) planCodeBlock(conn *websocket.Conn, sid, content string, edit codeBlockEdit) (string, bool, string, string, bool) {
+	abs, exists, err := resolveWritePath(r.sessionWorkingDir(sid), edit.Path)
+	if err != nil {
+		Errorf(conn, "applyCodeBlock: %v", err)
+		return "", false, "", "", false
+	}
+	var old string
+	if exists {
+		b, err := os.ReadFile(abs)
+		if err != nil {
+			Errorf(conn, "applyCodeBlock: %v", err)
+			return "", false, "", "", false
+		}
+		old = string(b)
+	} else if edit.StartLine > 1 {
+		Errorf(conn, "applyCodeBlock: line range is past the end of the file")
+		return "", false, "", "", false
+	}
+	updated, err := spliceLines(old, content, edit.StartLine, edit.EndLine)
+	if err != nil {
+		Errorf(conn, "applyCodeBlock: %v", err)
+		return "", false, "", "", false
+	}
+	return abs, exists, old, updated, true
+}
@@ -0,0 +1,86 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSpliceLines(t *testing.T) {
+	cases := []struct {
+		old, block string
+		start, end int
+		want       string
+	}{
+		{"a\nb\nc\n", "X\n", 0, 0, "X\n"},
+		{"a\nb\nc\n", "X", 2, 2, "a\nX\nc\n"},
+		{"a\nb\nc\n", "X\nY\n", 2, 3, "a\nX\nY\n"},
+		{"a\nb\nc\n", "X\n", 2, 1, "a\nX\nb\nc\n"},
+		{"a\nb", "X\n", 3, 2, "a\nb\nX\n"},
+	}
+	for _, c := range cases {
+		got, err := spliceLines(c.old, c.block, c.start, c.end)
+		if err != nil || got != c.want {
+			t.Errorf("spliceLines(%q, %q, %d, %d) = %q, %v; want %q", c.old, c.block, c.start, c.end, got, err, c.want)
+		}
+	}
+	if _, err := spliceLines("a\n", "X\n", 2, 4); err == nil {
+		t.Fatal("range past the end accepted")
+	}
+}
+
+func TestRouter_ApplyCodeBlockPreviewThenApply(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(target, []byte("package main\n\nfunc old() {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir})
+	readType(t, c, "opened")
+	fs.last(t).emit("```go\nfunc updated() {}\n```\n")
+	readStdout(t, c, "updated")
+
+	apply := map[string]any{"type": "applyCodeBlock", "sessionId": "s1", "blockId": 1, "path": "main.go", "startLine": 3, "endLine": 3, "dryRun": true}
+	_ = c.WriteJSON(apply)
+	preview := readType(t, c, "codeBlockPreview")
+	if !strings.Contains(preview["diff"].(string), "-func old() {}\n+func updated() {}\n") || preview["created"] != false {
+		t.Fatalf("unexpected preview: %v", preview)
+	}
+	if b, _ := os.ReadFile(target); string(b) != "package main\n\nfunc old() {}\n" {
+		t.Fatalf("preview wrote the file: %q", b)
+	}
+
+	// Without dryRun the preview is followed by a confirmation
+	apply["dryRun"] = false
+	_ = c.WriteJSON(apply)
+	readType(t, c, "codeBlockPreview")
+	confirmNext(t, c, "applyCodeBlock")
+	readType(t, c, "codeBlockApplied")
+	if b, _ := os.ReadFile(target); string(b) != "package main\n\nfunc updated() {}\n" {
+		t.Fatalf("unexpected file: %q", b)
+	}
+	if fi, _ := os.Stat(target); fi.Mode().Perm() != 0o600 {
+		t.Fatalf("mode not kept: %v", fi.Mode())
+	}
+
+	// The file changes while the edit waits for confirmation
+	_ = c.WriteJSON(apply)
+	challenge := readType(t, c, "confirmationRequired")
+	if err := os.WriteFile(target, []byte("package main\n\nfunc other() {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "confirm", "token": challenge["token"], "approved": true})
+	if e := readType(t, c, "error"); e["code"] != "previewStale" {
+		t.Fatalf("unexpected error: %v", e)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "applyCodeBlock", "sessionId": "s1", "blockId": 1, "path": "../escape.go"})
+	if e := readType(t, c, "error"); !strings.Contains(e["message"].(string), "outside") {
+		t.Fatalf("unexpected error: %v", e)
+	}
+}
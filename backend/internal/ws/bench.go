@@ -0,0 +1,317 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/rovobridge/internal/session"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// benchDrainTimeout is how long clients wait for the last output after generation ends
+	benchDrainTimeout = 30 * time.Second
+	// maxBenchSamples bounds the latency samples kept; later lines are sampled with a stride
+	maxBenchSamples = 1 << 21
+)
+
+// BenchConfig describes a synthetic load run through the Router and WebSocket stack
+type BenchConfig struct {
+	Sessions    int           `json:"sessions"`    // synthetic sessions producing output
+	Clients     int           `json:"clients"`     // WebSocket connections; sessions are spread over them
+	BytesPerSec int           `json:"bytesPerSec"` // output rate of each session
+	LineBytes   int           `json:"lineBytes"`   // length of each output line, newline included
+	Duration    time.Duration `json:"duration"`    // how long sessions produce output
+	Batch       bool          `json:"batch"`       // negotiate batched frames
+}
+
+// DefaultBenchConfig is a moderate load: 8 sessions at 256 KiB/s for 10 seconds
+func DefaultBenchConfig() BenchConfig {
+	return BenchConfig{Sessions: 8, Clients: 1, BytesPerSec: 256 << 10, LineBytes: 120, Duration: 10 * time.Second}
+}
+
+// BenchReport is the result of RunBench. Latency is measured per output line, from the
+// moment a synthetic session writes it to the moment a client decodes it.
+type BenchReport struct {
+	Config         BenchConfig   `json:"config"`
+	Elapsed        time.Duration `json:"elapsed"`
+	GeneratedBytes int64         `json:"generatedBytes"`
+	DeliveredBytes int64         `json:"deliveredBytes"`
+	Messages       int64         `json:"messages"` // stdout messages received
+	Frames         int64         `json:"frames"`   // WebSocket frames received
+	Gaps           int           `json:"gaps"`     // stdout offsets that skipped output
+	ThroughputMBps float64       `json:"throughputMBps"`
+	LatencyP50     time.Duration `json:"latencyP50"`
+	LatencyP95     time.Duration `json:"latencyP95"`
+	LatencyP99     time.Duration `json:"latencyP99"`
+	LatencyMax     time.Duration `json:"latencyMax"`
+	Evictions      uint64        `json:"evictions"`  // slow-client evictions by the server
+	Incomplete     int           `json:"incomplete"` // sessions whose exit was not seen before the drain timeout
+}
+
+// Print writes the report in a human-readable form
+func (rep BenchReport) Print(w io.Writer) {
+	c := rep.Config
+	fmt.Fprintf(w, "sessions %d over %d connection(s), %d B/s each, %d-byte lines, %s, batch=%v\n",
+		c.Sessions, c.Clients, c.BytesPerSec, c.LineBytes, c.Duration, c.Batch)
+	fmt.Fprintf(w, "elapsed     %s\n", rep.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "generated   %d bytes\n", rep.GeneratedBytes)
+	fmt.Fprintf(w, "delivered   %d bytes in %d messages, %d frames (%.2f MB/s)\n", rep.DeliveredBytes, rep.Messages, rep.Frames, rep.ThroughputMBps)
+	fmt.Fprintf(w, "latency     p50 %s  p95 %s  p99 %s  max %s\n", rep.LatencyP50, rep.LatencyP95, rep.LatencyP99, rep.LatencyMax)
+	fmt.Fprintf(w, "gaps %d  evictions %d  incomplete sessions %d\n", rep.Gaps, rep.Evictions, rep.Incomplete)
+}
+
+// benchSession is a synthetic process writing timestamped lines at a fixed rate
+type benchSession struct {
+	outR *io.PipeReader
+	outW *io.PipeWriter
+	done chan struct{}
+	stop context.CancelFunc
+}
+
+func newBenchSession(id string, cfg BenchConfig, generated *atomic.Int64) *benchSession {
+	r, w := io.Pipe()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Duration)
+	s := &benchSession{outR: r, outW: w, done: make(chan struct{}), stop: cancel}
+	go func() {
+		defer close(s.done)
+		defer w.Close()
+		start := time.Now()
+		tick := time.NewTicker(10 * time.Millisecond)
+		defer tick.Stop()
+		var sent int64
+		var line int
+		for {
+			due := int64(time.Since(start).Seconds() * float64(cfg.BytesPerSec))
+			var buf bytes.Buffer
+			for sent+int64(buf.Len()) < due {
+				line++
+				buf.Write(benchLine(id, line, time.Now(), cfg.LineBytes))
+			}
+			if buf.Len() > 0 {
+				n, err := w.Write(buf.Bytes())
+				sent += int64(n)
+				generated.Add(int64(n))
+				if err != nil {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+	return s
+}
+
+// benchLine formats "bench <session> <line> <unixnano>" padded with dots to size bytes
+func benchLine(id string, n int, at time.Time, size int) []byte {
+	b := fmt.Appendf(nil, "bench %s %d %d ", id, n, at.UnixNano())
+	for len(b) < size-1 {
+		b = append(b, '.')
+	}
+	return append(b, '\n')
+}
+
+func (s *benchSession) Stdin() io.Writer      { return io.Discard }
+func (s *benchSession) Stdout() io.Reader     { return s.outR }
+func (s *benchSession) Resize(int, int) error { return nil }
+func (s *benchSession) PID() int              { return 0 }
+func (s *benchSession) Wait() error           { <-s.done; return nil }
+func (s *benchSession) Close() error          { s.stop(); s.outR.Close(); return nil }
+
+// benchClient is one connection receiving the output of its sessions
+type benchClient struct {
+	conn     *websocket.Conn
+	pending  map[string]bool   // sessions whose exit has not arrived
+	partial  map[string][]byte // unterminated line per session
+	next     map[string]int64  // expected stdout offset per session
+	bytes    int64
+	messages int64
+	frames   int64
+	gaps     int
+	samples  []time.Duration
+	seen     int64 // lines decoded, for sampling
+}
+
+// RunBench starts a Router with synthetic sessions behind a loopback WebSocket server,
+// drives it with cfg.Clients connections and reports throughput and latency
+func RunBench(ctx context.Context, cfg BenchConfig) (BenchReport, error) {
+	rep := BenchReport{Config: cfg}
+	if cfg.Sessions <= 0 || cfg.Clients <= 0 || cfg.BytesPerSec <= 0 || cfg.Duration <= 0 {
+		return rep, errors.New("bench: sessions, clients, rate and duration must be positive")
+	}
+	if cfg.LineBytes < 48 {
+		cfg.LineBytes = 48 // room for the timestamp header
+		rep.Config.LineBytes = cfg.LineBytes
+	}
+
+	var generated atomic.Int64
+	var started atomic.Int32
+	r := newRouter("")
+	r.orphanGrace = 0 // sessions go as soon as their client disconnects
+	r.startSession = func(context.Context, session.Config) (ptySession, error) {
+		return newBenchSession(strconv.Itoa(int(started.Add(1))), cfg, &generated), nil
+	}
+
+	token := "bench"
+	srv := NewServer(token)
+	r.Attach(srv)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return rep, err
+	}
+	hs := &http.Server{Handler: http.HandlerFunc(srv.HandleWS)}
+	go hs.Serve(ln)
+	defer hs.Close()
+
+	url := "ws://" + ln.Addr().String() + "/ws"
+	clients := make([]*benchClient, cfg.Clients)
+	for i := range clients {
+		d := websocket.Dialer{Subprotocols: []string{"auth.bearer." + token}}
+		conn, _, err := d.DialContext(ctx, url, nil)
+		if err != nil {
+			return rep, err
+		}
+		defer conn.Close()
+		clients[i] = &benchClient{conn: conn, pending: map[string]bool{}, partial: map[string][]byte{}, next: map[string]int64{}}
+		_ = conn.WriteJSON(map[string]any{"type": "hello", "features": map[string]any{"batch": cfg.Batch}})
+	}
+
+	start := time.Now()
+	for i := 0; i < cfg.Sessions; i++ {
+		c := clients[i%len(clients)]
+		sid := fmt.Sprintf("bench-%d", i)
+		c.pending[sid] = true
+		if err := c.conn.WriteJSON(map[string]any{"type": "openSession", "id": sid, "useClipboard": false}); err != nil {
+			return rep, err
+		}
+	}
+
+	deadline := start.Add(cfg.Duration + benchDrainTimeout)
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.receive(deadline)
+		}()
+	}
+	wg.Wait()
+	rep.Elapsed = time.Since(start)
+
+	var samples []time.Duration
+	for _, c := range clients {
+		rep.DeliveredBytes += c.bytes
+		rep.Messages += c.messages
+		rep.Frames += c.frames
+		rep.Gaps += c.gaps
+		rep.Incomplete += len(c.pending)
+		samples = append(samples, c.samples...)
+	}
+	if secs := rep.Elapsed.Seconds(); secs > 0 {
+		rep.ThroughputMBps = float64(rep.DeliveredBytes) / secs / 1e6
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	if n := len(samples); n > 0 {
+		pct := func(p float64) time.Duration { return samples[int(p*float64(n-1))] }
+		rep.LatencyP50, rep.LatencyP95, rep.LatencyP99, rep.LatencyMax = pct(0.50), pct(0.95), pct(0.99), samples[n-1]
+	}
+	rep.Evictions = srv.Stats().Evictions
+	rep.GeneratedBytes = generated.Load()
+	return rep, nil
+}
+
+// receive reads frames until every session of the client has exited or deadline passes
+func (c *benchClient) receive(deadline time.Time) {
+	_ = c.conn.SetReadDeadline(deadline)
+	for len(c.pending) > 0 {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.frames++
+		var msgs []map[string]any
+		if len(data) > 0 && data[0] == '[' {
+			_ = json.Unmarshal(data, &msgs)
+		} else {
+			var m map[string]any
+			if json.Unmarshal(data, &m) == nil {
+				msgs = append(msgs, m)
+			}
+		}
+		for _, m := range msgs {
+			c.handle(m)
+		}
+	}
+}
+
+func (c *benchClient) handle(m map[string]any) {
+	sid, _ := m["sessionId"].(string)
+	switch m["type"] {
+	case "opened":
+		// sessions are tracked by the id the bridge gave them
+		if alias, _ := m["alias"].(string); c.pending[alias] {
+			delete(c.pending, alias)
+			c.pending[sid] = true
+		}
+	case "exit":
+		delete(c.pending, sid)
+	case "stdout":
+		data, err := base64.StdEncoding.DecodeString(fmt.Sprint(m["dataBase64"]))
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		c.messages++
+		c.bytes += int64(len(data))
+		if off, ok := m["offset"].(float64); ok {
+			if int64(off) != c.next[sid] {
+				c.gaps++
+				c.partial[sid] = nil
+			}
+			c.next[sid] = int64(off) + int64(len(data))
+		}
+		buf := append(c.partial[sid], data...)
+		for {
+			i := bytes.IndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+			c.sample(buf[:i], now)
+			buf = buf[i+1:]
+		}
+		c.partial[sid] = append([]byte(nil), buf...)
+	}
+}
+
+// sample records the latency of a "bench <session> <line> <unixnano>" line
+func (c *benchClient) sample(line []byte, now time.Time) {
+	fields := bytes.Fields(line)
+	if len(fields) < 4 || string(fields[0]) != "bench" {
+		return
+	}
+	ns, err := strconv.ParseInt(string(fields[3]), 10, 64)
+	if err != nil {
+		return
+	}
+	c.seen++
+	stride := c.seen/maxBenchSamples + 1
+	if c.seen%stride == 0 {
+		c.samples = append(c.samples, now.Sub(time.Unix(0, ns)))
+	}
+}
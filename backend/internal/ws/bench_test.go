@@ -0,0 +1,33 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunBench_DeliversAllOutput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+	for _, batch := range []bool{false, true} {
+		rep, err := RunBench(context.Background(), BenchConfig{
+			Sessions: 3, Clients: 2, BytesPerSec: 64 << 10, LineBytes: 100, Duration: 300 * time.Millisecond, Batch: batch,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rep.Incomplete != 0 || rep.Gaps != 0 || rep.GeneratedBytes == 0 || rep.DeliveredBytes != rep.GeneratedBytes {
+			t.Fatalf("batch=%v: unexpected report %+v", batch, rep)
+		}
+		if rep.LatencyP50 <= 0 || rep.LatencyMax < rep.LatencyP99 || rep.Messages == 0 {
+			t.Fatalf("batch=%v: unexpected latencies %+v", batch, rep)
+		}
+		var out bytes.Buffer
+		rep.Print(&out)
+		if !strings.Contains(out.String(), "latency     p50") {
+			t.Fatalf("unexpected output:\n%s", out.String())
+		}
+	}
+}
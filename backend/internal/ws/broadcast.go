@@ -0,0 +1,134 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxBroadcastSessions bounds the sessions one broadcastSend reaches
+const maxBroadcastSessions = 16
+
+// broadcastKey carries the tag of a broadcast into the send it makes for each session.
+// Its value is a *broadcastTag, which a client's JSON can never decode to.
+const broadcastKey = "\x00broadcast"
+
+// broadcastTag marks the output of sessions prompted by one broadcastSend, so clients can
+// show the answers of different agents or configurations side by side
+type broadcastTag struct {
+	id  string
+	tag string
+}
+
+// broadcastSend sends the prompt in m to every session in sids. Each session gets the
+// same text followed by its entry in m["contexts"], and the other send options (paths,
+// injectOutputTail, injectTaskResult) apply to all of them; the history entry is saved
+// once. The sends run one after the other in the background, since clipboard injection
+// goes through the single system clipboard.
+func (r *Router) broadcastSend(ctx context.Context, conn *websocket.Conn, m map[string]any) error {
+	sids, _ := anyToStrings(m["sessionIds"])
+	tagName, _ := m["tag"].(string)
+	contexts, _ := m["contexts"].(map[string]any)
+	var text []byte
+	if dataB64, _ := m["dataBase64"].(string); dataB64 != "" {
+		var err error
+		if text, err = base64.StdEncoding.DecodeString(dataB64); err != nil {
+			Errorf(conn, "broadcastSend: bad base64")
+			return nil
+		}
+	}
+	if len(sids) == 0 {
+		Errorf(conn, "broadcastSend: no sessionIds")
+		return nil
+	}
+	if len(sids) > maxBroadcastSessions {
+		Errorf(conn, "broadcastSend: at most %d sessions", maxBroadcastSessions)
+		return nil
+	}
+
+	sent, missing := []string{}, []string{}
+	seen := map[string]bool{}
+	r.mu.Lock()
+	for _, sid := range sids {
+		if seen[sid] {
+			continue
+		}
+		seen[sid] = true
+		if r.sessions[sid] == nil {
+			missing = append(missing, sid)
+		} else {
+			sent = append(sent, sid)
+		}
+	}
+	r.mu.Unlock()
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	tag := &broadcastTag{id: "bc-" + hex.EncodeToString(b), tag: tagName}
+	reply := map[string]any{"type": "broadcastStarted", "broadcastId": tag.id, "sessions": sent, "missing": missing}
+	if tagName != "" {
+		reply["tag"] = tagName
+	}
+	if err := SendJSON(conn, reply); err != nil || len(sent) == 0 {
+		return err
+	}
+
+	sends := make([]map[string]any, len(sent))
+	for i, sid := range sent {
+		msg := map[string]any{}
+		for k, v := range m {
+			switch k {
+			case "type", "sessionIds", "tag", "contexts", "dataBase64":
+			case "historyEntry":
+				if i == 0 {
+					msg[k] = v
+				}
+			default:
+				msg[k] = v
+			}
+		}
+		prompt := text
+		if extra, _ := contexts[sid].(string); extra != "" {
+			prompt = fmt.Appendf(append([]byte(nil), text...), "\n\n%s", extra)
+		}
+		msg["type"] = "send"
+		msg["sessionId"] = sid
+		msg["dataBase64"] = base64.StdEncoding.EncodeToString(prompt)
+		msg[broadcastKey] = tag
+		sends[i] = msg
+	}
+	go func() {
+		for _, msg := range sends {
+			r.handleSafely(ctx, conn, msg)
+		}
+	}()
+	return nil
+}
+
+// setBroadcastTag tags the output that follows a send with the broadcast it belongs to,
+// or clears the tag of an earlier broadcast when the send is a plain one
+func (r *Router) setBroadcastTag(st *sessionState, m map[string]any) {
+	if st == nil {
+		return
+	}
+	tag, _ := m[broadcastKey].(*broadcastTag)
+	st.mu.Lock()
+	st.broadcast = tag
+	st.mu.Unlock()
+}
+
+// broadcastFieldsUnsafe adds the tag of the session's current broadcast to a stdout
+// message. Caller must hold st.mu.
+func (st *sessionState) broadcastFieldsUnsafe(msg map[string]any) {
+	if st.broadcast == nil {
+		return
+	}
+	msg["broadcastId"] = st.broadcast.id
+	if st.broadcast.tag != "" {
+		msg["tag"] = st.broadcast.tag
+	}
+}
@@ -0,0 +1,62 @@
+package ws
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestRouter_BroadcastSendTagsOutput(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	ids := map[string]string{}
+	for _, alias := range []string{"a", "b"} {
+		_ = c.WriteJSON(map[string]any{"type": "openSession", "id": alias, "useClipboard": false})
+		ids[alias] = readType(t, c, "opened")["sessionId"].(string)
+	}
+	fs.mu.Lock()
+	sa, sb := fs.started[0], fs.started[1]
+	fs.mu.Unlock()
+
+	_ = c.WriteJSON(map[string]any{
+		"type":       "broadcastSend",
+		"sessionIds": []string{"a", "b", "gone", "a"},
+		"dataBase64": b64("explain the bug"),
+		"contexts":   map[string]any{"b": "use the slow model"},
+		"tag":        "compare",
+	})
+	started := readType(t, c, "broadcastStarted")
+	id, _ := started["broadcastId"].(string)
+	if id == "" || started["tag"] != "compare" ||
+		strings.Join(anyStrings(started["sessions"]), ",") != ids["a"]+","+ids["b"] || strings.Join(anyStrings(started["missing"]), ",") != "gone" {
+		t.Fatalf("unexpected broadcastStarted: %v", started)
+	}
+	eventually(t, "prompt to reach both sessions", func() bool {
+		return strings.Contains(sa.stdinString(), "explain the bug") && strings.Contains(sb.stdinString(), "use the slow model")
+	})
+	if strings.Contains(sa.stdinString(), "slow model") {
+		t.Fatalf("context of b sent to a: %q", sa.stdinString())
+	}
+
+	sb.emit("answer from b")
+	msg := readType(t, c, "stdout")
+	data, _ := base64.StdEncoding.DecodeString(msg["dataBase64"].(string))
+	if msg["sessionId"] != ids["b"] || msg["broadcastId"] != id || msg["tag"] != "compare" || string(data) != "answer from b" {
+		t.Fatalf("untagged broadcast output: %v", msg)
+	}
+
+	// A plain send ends the broadcast for that session
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "b", "dataBase64": b64("thanks")})
+	eventually(t, "plain send", func() bool { return strings.Contains(sb.stdinString(), "thanks") })
+	sb.emit("more")
+	if msg := readType(t, c, "stdout"); msg["broadcastId"] != nil {
+		t.Fatalf("output still tagged: %v", msg)
+	}
+}
+
+func anyStrings(v any) []string {
+	s, _ := anyToStrings(v)
+	return s
+}
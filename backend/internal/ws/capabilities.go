@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Capabilities that can be withheld from browser connections with SetBrowserRestrictions
+const (
+	// CapCommand chooses what runs: openSession with cmd, args or env (PROMPT_COMMAND,
+	// LD_PRELOAD and the like run code in the default agent) or with a cwd outside the
+	// workspace, updateSessionConfig with customCommand, and runTask, which runs the tasks
+	// file of the session's directory
+	CapCommand = "command"
+	// CapWriteFiles changes workspace files or the repository: applyCodeBlock, saveFile,
+	// replaceInFiles without dryRun, restoreCheckpoint, saveProjectPrompt,
+	// removeProjectPrompt, setGitCheckpoints and rewriteHistoryPaths
+	CapWriteFiles = "writeFiles"
+)
+
+// Capabilities lists the names SetBrowserRestrictions accepts
+var Capabilities = []string{CapCommand, CapWriteFiles}
+
+// capabilityOf returns the capability m needs, or "" when any client may send it. root is
+// the workspace sessions may be opened in without CapCommand.
+func capabilityOf(m map[string]any, root string) string {
+	switch m["type"] {
+	case "openSession":
+		if cmd, _ := m["cmd"].(string); cmd != "" {
+			return CapCommand
+		}
+		if args, ok := m["args"].([]any); ok && len(args) > 0 {
+			return CapCommand
+		}
+		if env, ok := m["env"].([]any); ok && len(env) > 0 {
+			return CapCommand
+		}
+		if dir, _ := m["cwd"].(string); dir != "" && !insideDir(root, dir) {
+			return CapCommand
+		}
+	case "updateSessionConfig":
+		if _, ok := m["customCommand"]; ok {
+			return CapCommand
+		}
+	case "runTask":
+		return CapCommand
+	case "applyCodeBlock", "saveFile", "restoreCheckpoint", "saveProjectPrompt", "removeProjectPrompt", "setGitCheckpoints", "rewriteHistoryPaths":
+		return CapWriteFiles
+	case "replaceInFiles":
+		if dryRun, _ := m["dryRun"].(bool); !dryRun {
+			return CapWriteFiles
+		}
+	}
+	return ""
+}
+
+// insideDir reports whether dir is root or beneath it, after following symlinks where
+// the paths exist
+func insideDir(root, dir string) bool {
+	if root == "" {
+		return false
+	}
+	resolve := func(p string) string {
+		p, _ = filepath.Abs(p)
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			return real
+		}
+		return p
+	}
+	rel, err := filepath.Rel(resolve(root), resolve(dir))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// workspaceRoot returns the directory browser connections may open sessions in: the index
+// root, or the working directory of the bridge
+func (r *Router) workspaceRoot() string {
+	if root := r.indexRoot(); root != "" {
+		return root
+	}
+	root, _ := os.Getwd()
+	return root
+}
+
+// isBrowserConn reports whether c was opened by a web page: its Origin is an http(s)
+// URL. The IDE's JCEF panel sends Origin null and IDE plugins connecting directly send
+// none; connections not served by a Server count as IDE connections.
+func isBrowserConn(c *websocket.Conn) bool {
+	v, ok := wsWriters.Load(c)
+	if !ok {
+		return false
+	}
+	u, err := url.Parse(v.(*connWriter).origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// SetBrowserRestrictions withholds caps from connections opened by a web page, while the
+// IDE keeps full control. Empty lifts the restrictions.
+func (r *Router) SetBrowserRestrictions(caps []string) error {
+	denied := make([]string, 0, len(caps))
+	for _, c := range caps {
+		if !slices.Contains(Capabilities, c) {
+			return fmt.Errorf("unknown capability %q (want one of %v)", c, Capabilities)
+		}
+		if !slices.Contains(denied, c) {
+			denied = append(denied, c)
+		}
+	}
+	r.browserDenied.Store(&denied)
+	return nil
+}
+
+// restrictedFor returns the capabilities withheld from conn
+func (r *Router) restrictedFor(conn *websocket.Conn) []string {
+	denied := r.browserDenied.Load()
+	if denied == nil || len(*denied) == 0 || !isBrowserConn(conn) {
+		return nil
+	}
+	return *denied
+}
+
+// capabilityGuard answers the messages that need a capability withheld from conn. It
+// reports whether the message was answered.
+func (r *Router) capabilityGuard(conn *websocket.Conn, m map[string]any) bool {
+	restricted := r.restrictedFor(conn)
+	if len(restricted) == 0 {
+		return false
+	}
+	capability := capabilityOf(m, r.workspaceRoot())
+	if capability == "" || !slices.Contains(restricted, capability) {
+		return false
+	}
+	typ, _ := m["type"].(string)
+	ErrorCode(conn, "capabilityDenied", map[string]any{"messageType": typ, "capability": capability},
+		"%s needs the %s capability, which browser connections do not have", typ, capability)
+	return true
+}
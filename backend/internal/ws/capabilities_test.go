@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"testing"
+)
+
+func TestRouter_BrowserRestrictions(t *testing.T) {
+	r, _ := newTestRouter(t)
+	if err := r.SetBrowserRestrictions([]string{CapCommand, CapWriteFiles}); err != nil {
+		t.Fatal(err)
+	}
+	c, closeConn := dialRouterFrom(t, r, "http://localhost:5173")
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "hello"})
+	if restricted, _ := readType(t, c, "welcome")["restricted"].([]any); len(restricted) != 2 {
+		t.Fatalf("expected both capabilities withheld in welcome, got %v", restricted)
+	}
+	for _, msg := range []map[string]any{
+		{"type": "updateSessionConfig", "customCommand": "bash"},
+		{"type": "openSession", "id": "s1", "cmd": "bash"},
+		{"type": "replaceInFiles", "pattern": "a", "replacement": "b"},
+		{"type": "applyCodeBlock", "sessionId": "s1", "blockId": 1, "path": "a.go"},
+		{"type": "openSession", "id": "s1", "env": []string{"BASH_ENV=/tmp/payload.sh"}},
+		{"type": "openSession", "id": "s1", "cwd": t.TempDir()},
+		{"type": "runTask", "sessionId": "s1", "name": "test"},
+		{"type": "saveProjectPrompt", "name": "review", "text": "Review this"},
+		{"type": "removeProjectPrompt", "promptId": "review"},
+		{"type": "setGitCheckpoints", "enabled": true},
+		{"type": "rewriteHistoryPaths", "renames": []map[string]any{{"from": "a.go", "to": "b.go"}}},
+	} {
+		_ = c.WriteJSON(msg)
+		e := readType(t, c, "error")
+		if e["code"] != "capabilityDenied" || e["messageType"] != msg["type"] {
+			t.Fatalf("%v: expected capabilityDenied, got %v", msg["type"], e)
+		}
+	}
+	if r.getSessionConfig()["cmd"] == "bash" {
+		t.Fatal("the command changed despite the restriction")
+	}
+
+	// The default command is still available, in the workspace
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s2", "cwd": "."})
+	readType(t, c, "opened")
+}
+
+func TestRouter_IDEKeepsFullControl(t *testing.T) {
+	r, _ := newTestRouter(t)
+	_ = r.SetBrowserRestrictions([]string{CapCommand})
+	for _, origin := range []string{"null", ""} {
+		c, closeConn := dialRouterFrom(t, r, origin)
+		_ = c.WriteJSON(map[string]any{"type": "hello"})
+		if w := readType(t, c, "welcome"); w["restricted"] != nil {
+			t.Fatalf("origin %q: expected no restrictions, got %v", origin, w["restricted"])
+		}
+		_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s-" + origin, "cmd": "bash"})
+		readType(t, c, "opened")
+		closeConn()
+	}
+}
+
+func TestRouter_SetBrowserRestrictionsRejectsUnknown(t *testing.T) {
+	r, _ := newTestRouter(t)
+	if err := r.SetBrowserRestrictions([]string{"command", "everything"}); err == nil {
+		t.Fatal("expected an unknown capability to be rejected")
+	}
+}
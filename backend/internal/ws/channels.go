@@ -0,0 +1,149 @@
+package ws
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// channel is a logical stream of the messages sent to a connection. A client negotiating
+// channels in hello gets each message tagged with its channel ID in a ch field; control
+// messages are then written ahead of queued output, and stream and bulk messages take
+// turns within the credit the client grants them.
+type channel int
+
+const (
+	chControl channel = iota // replies, events and errors; never held back
+	chStream                 // session output and what must stay in order with it
+	chBulk                   // large transfers: index exports, diffs, history pages, tailed files
+	numChannels
+)
+
+const (
+	// defaultChannelWindow is the credit, in bytes, stream and bulk start with
+	defaultChannelWindow = 1 << 20
+	// minChannelWindow and maxChannelWindow bound the window a client may ask for
+	minChannelWindow = 64 << 10
+	maxChannelWindow = 64 << 20
+)
+
+// channelNames maps channel IDs to the names announced in welcome
+var channelNames = [numChannels]string{chControl: "control", chStream: "stream", chBulk: "bulk"}
+
+// streamMessages are sent on chStream: session output, and messages a client must see in
+// order with it, such as the snapshot a resume continues from or the exit after the last
+// output
+var streamMessages = map[string]bool{
+	"stdout": true, "snapshot": true, "lines": true, "exit": true, "pathAnnotations": true, "sessionTransferred": true,
+}
+
+// bulkMessages are sent on chBulk, so a large one never delays a reply
+var bulkMessages = map[string]bool{
+	"indexExport": true, "indexSubscribed": true, "indexDelta": true, "tailLines": true, "checkpointDiff": true, "sessionDiff": true, "historyPage": true,
+}
+
+// channelOf returns the channel of an outbound message
+func channelOf(v any) channel {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return chControl
+	}
+	typ, _ := m["type"].(string)
+	switch {
+	case streamMessages[typ]:
+		return chStream
+	case bulkMessages[typ]:
+		return chBulk
+	}
+	return chControl
+}
+
+// tagChannel adds the ch field to an encoded JSON object
+func tagChannel(buf []byte, ch channel) []byte {
+	tag := `{"ch":` + strconv.Itoa(int(ch))
+	out := make([]byte, 0, len(buf)+len(tag)+1)
+	out = append(out, tag...)
+	if len(buf) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, buf[1:]...)
+}
+
+// channelFlow is the send credit of the flow-controlled channels of a connection. Sending
+// a frame spends its size; a channel out of credit waits for the client to grant more
+// with channelCredit. Control is never flow-controlled, so replies get through while
+// output waits.
+type channelFlow struct {
+	window   atomic.Int64 // initial credit; 0 turns flow control off
+	credit   [numChannels]atomic.Int64
+	credited chan struct{} // signaled when credit is granted, to wake the writer
+}
+
+// open reports whether ch may send
+func (f *channelFlow) open(ch channel) bool {
+	return ch == chControl || f.window.Load() == 0 || f.credit[ch].Load() > 0
+}
+
+// spend takes n bytes from the credit of ch; a frame larger than the credit left still
+// goes out, and the channel then waits until the client catches up
+func (f *channelFlow) spend(ch channel, n int) {
+	if ch != chControl && f.window.Load() > 0 {
+		f.credit[ch].Add(-int64(n))
+	}
+}
+
+// grant adds n bytes to the credit of ch
+func (f *channelFlow) grant(ch channel, n int64) {
+	f.credit[ch].Add(n)
+	select {
+	case f.credited <- struct{}{}:
+	default:
+	}
+}
+
+// SetChannels turns channel multiplexing on for a connection served by a Server, with
+// window bytes of credit for each flow-controlled channel (clamped, default when 0). It
+// reports whether the connection supports it and the window granted.
+func SetChannels(c *websocket.Conn, on bool, window int) (bool, int) {
+	v, ok := wsWriters.Load(c)
+	if !ok {
+		return false, 0
+	}
+	w := v.(*connWriter)
+	if !on {
+		w.mux.Store(false)
+		w.flow.window.Store(0)
+		return true, 0
+	}
+	if window <= 0 {
+		window = defaultChannelWindow
+	}
+	window = min(max(window, minChannelWindow), maxChannelWindow)
+	for ch := range w.flow.credit {
+		w.flow.credit[ch].Store(int64(window))
+	}
+	w.flow.window.Store(int64(window))
+	w.mux.Store(true)
+	return true, window
+}
+
+// grantCredit answers channelCredit: the client consumed bytes of channel ch and may be
+// sent that many more
+func grantCredit(c *websocket.Conn, ch int, bytes int64) bool {
+	v, ok := wsWriters.Load(c)
+	if !ok || ch <= int(chControl) || ch >= int(numChannels) || bytes <= 0 {
+		return false
+	}
+	v.(*connWriter).flow.grant(channel(ch), bytes)
+	return true
+}
+
+// channelIDs are the channels announced in welcome, by name
+func channelIDs() map[string]int {
+	ids := make(map[string]int, numChannels)
+	for ch, name := range channelNames {
+		ids[name] = ch
+	}
+	return ids
+}
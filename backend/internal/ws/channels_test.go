@@ -0,0 +1,150 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTagChannel(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		ch   channel
+		want string
+	}{
+		{`{"type":"stdout"}`, chStream, `{"ch":1,"type":"stdout"}`},
+		{`{"type":"indexExport","entries":[]}`, chBulk, `{"ch":2,"type":"indexExport","entries":[]}`},
+		{`{}`, chControl, `{"ch":0}`},
+	} {
+		if got := string(tagChannel([]byte(tc.in), tc.ch)); got != tc.want {
+			t.Errorf("tagChannel(%s, %d) = %s, want %s", tc.in, tc.ch, got, tc.want)
+		}
+	}
+}
+
+func TestChannelOf(t *testing.T) {
+	for typ, want := range map[string]channel{"stdout": chStream, "exit": chStream, "indexExport": chBulk, "opened": chControl, "error": chControl} {
+		if got := channelOf(map[string]any{"type": typ}); got != want {
+			t.Errorf("channelOf(%s) = %d, want %d", typ, got, want)
+		}
+	}
+}
+
+// dialRouterConn is dialRouter that also returns the server side of the connection,
+// once the client has sent its first message
+func dialRouterConn(t *testing.T, r *Router, first map[string]any) (*websocket.Conn, *websocket.Conn, func()) {
+	t.Helper()
+	s := NewServer("tok")
+	r.Attach(s)
+	conns := make(chan *websocket.Conn, 1)
+	handle := s.OnMessage
+	s.OnMessage = func(ctx context.Context, conn *websocket.Conn, m map[string]any) {
+		select {
+		case conns <- conn:
+		default:
+		}
+		handle(ctx, conn, m)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.HandleWS)
+	ts := httptest.NewServer(mux)
+	d := websocket.Dialer{Subprotocols: []string{"auth.bearer.tok"}}
+	h := http.Header{}
+	h.Set("Origin", "http://localhost")
+	c, _, err := d.Dial(wsURLFromHTTP(ts.URL, "/ws"), h)
+	if err != nil {
+		ts.Close()
+		t.Fatalf("dial: %v", err)
+	}
+	_ = c.WriteJSON(first)
+	return c, <-conns, func() { c.Close(); ts.Close() }
+}
+
+// readMessage reads the next message from c
+func readMessage(t *testing.T, c *websocket.Conn) map[string]any {
+	t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var m map[string]any
+	if err := c.ReadJSON(&m); err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	return m
+}
+
+func TestChannels_ControlOvertakesStreamWithoutCredit(t *testing.T) {
+	r, _ := newTestRouter(t)
+	c, conn, closeConn := dialRouterConn(t, r, map[string]any{
+		"type": "hello", "features": map[string]any{"channels": true, "channelWindow": minChannelWindow},
+	})
+	defer closeConn()
+
+	welcome := readType(t, c, "welcome")
+	features, _ := welcome["features"].(map[string]any)
+	if ids, _ := features["channels"].(map[string]any); ids["stream"] != float64(chStream) || ids["bulk"] != float64(chBulk) {
+		t.Fatalf("expected the channel IDs in welcome: %v", welcome)
+	}
+	if features["channelWindow"] != float64(minChannelWindow) || welcome["ch"] != float64(chControl) {
+		t.Fatalf("expected a control welcome with the window asked for: %v", welcome)
+	}
+
+	// Four chunks of 40 KiB against a 64 KiB window: two go out, the rest wait for credit
+	chunk := strings.Repeat("x", 40<<10)
+	for i := 0; i < 4; i++ {
+		_ = SendJSON(conn, map[string]any{"type": "stdout", "sessionId": "s1", "dataBase64": chunk})
+	}
+	_ = SendJSON(conn, map[string]any{"type": "pong"})
+
+	// Only two stdout fit in the window, so pong must be among the first three messages
+	var order []string
+	for len(order) < 3 {
+		m := readMessage(t, c)
+		order = append(order, m["type"].(string))
+		if m["type"] == "stdout" && m["ch"] != float64(chStream) {
+			t.Fatalf("stdout not tagged with the stream channel: ch=%v", m["ch"])
+		}
+	}
+	if !slices.Contains(order, "pong") {
+		t.Fatalf("expected pong ahead of the stdout waiting for credit, got %v", order)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "channelCredit", "ch": int(chStream), "bytes": 1 << 20})
+	for i := 0; i < 2; i++ {
+		readType(t, c, "stdout")
+	}
+}
+
+func TestChannels_FIFOWithoutNegotiation(t *testing.T) {
+	r, _ := newTestRouter(t)
+	c, conn, closeConn := dialRouterConn(t, r, map[string]any{"type": "hello"})
+	defer closeConn()
+
+	welcome := readType(t, c, "welcome")
+	if features, _ := welcome["features"].(map[string]any); features["channels"] != nil {
+		t.Fatalf("channels granted without being asked for: %v", welcome)
+	}
+	_ = SendJSON(conn, map[string]any{"type": "stdout", "sessionId": "s1", "dataBase64": "aGkK"})
+	_ = SendJSON(conn, map[string]any{"type": "pong"})
+	first := readMessage(t, c)
+	if first["type"] != "stdout" || first["ch"] != nil {
+		t.Fatalf("expected an untagged stdout first, got %v", first)
+	}
+	readType(t, c, "pong")
+}
+
+func TestChannels_InvalidCredit(t *testing.T) {
+	r, _ := newTestRouter(t)
+	c, _, closeConn := dialRouterConn(t, r, map[string]any{"type": "hello", "features": map[string]any{"channels": true}})
+	defer closeConn()
+
+	readType(t, c, "welcome")
+	_ = c.WriteJSON(map[string]any{"type": "channelCredit", "ch": int(chControl), "bytes": 100})
+	if msg := readType(t, c, "error"); !strings.Contains(msg["message"].(string), "channelCredit") {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+}
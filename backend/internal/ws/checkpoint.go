@@ -0,0 +1,136 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/example/rovobridge/internal/fileutil"
+	"github.com/example/rovobridge/internal/history"
+)
+
+// maxCheckpoints bounds the checkpoints kept per session; the oldest are dropped first
+const maxCheckpoints = 20
+
+// maxInjectedPaths bounds the injected paths remembered per session for checkpoints
+const maxInjectedPaths = 500
+
+// checkpoint captures a point in a conversation: the prompt, digests of the files
+// referenced or injected so far, and the stdout sequence number at that time.
+type checkpoint struct {
+	ID        string           `json:"id"`
+	CreatedAt int64            `json:"createdAt"`
+	Prompt    string           `json:"prompt,omitempty"`
+	Seq       uint64           `json:"seq"`
+	Files     []checkpointFile `json:"files"`
+}
+
+type checkpointFile struct {
+	Path   string `json:"path"`
+	Digest string `json:"digest,omitempty"` // empty when the file could not be read
+}
+
+// checkpointChange describes a referenced file that differs from its checkpoint digest
+type checkpointChange struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // "modified", "deleted" or "created"
+}
+
+// rememberInjectedUnsafe records paths injected into the session, deduplicated and in
+// order of first injection. The caller must hold st.mu.
+func (st *sessionState) rememberInjectedUnsafe(paths []string) {
+	if st.injectedSeen == nil {
+		st.injectedSeen = map[string]bool{}
+	}
+	for _, p := range paths {
+		if p == "" || fileutil.IsSnippetPath(p) || st.injectedSeen[p] || len(st.injectedPaths) >= maxInjectedPaths {
+			continue
+		}
+		st.injectedSeen[p] = true
+		st.injectedPaths = append(st.injectedPaths, p)
+	}
+}
+
+// createCheckpoint snapshots prompt, files and output position for the session. When
+// paths is empty the files injected so far in the session are used; chips referenced by
+// the prompt are always included.
+func (st *sessionState) createCheckpoint(prompt string, paths []string) checkpoint {
+	st.mu.Lock()
+	workingDir := st.workingDir
+	seq := st.lastSeq
+	if len(paths) == 0 {
+		paths = append([]string(nil), st.injectedPaths...)
+	}
+	st.nextCheckpoint++
+	id := "cp" + strconv.FormatUint(st.nextCheckpoint, 10)
+	st.mu.Unlock()
+
+	seen := map[string]bool{}
+	var files []checkpointFile
+	for _, p := range append(paths, history.ExtractReferencedPaths(prompt)...) {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		digest, _ := fileutil.FileDigest(resolveSessionPath(workingDir, p))
+		files = append(files, checkpointFile{Path: p, Digest: digest})
+	}
+	if files == nil {
+		files = []checkpointFile{}
+	}
+	cp := checkpoint{ID: id, CreatedAt: time.Now().UnixMilli(), Prompt: prompt, Seq: seq, Files: files}
+
+	st.mu.Lock()
+	st.checkpoints = append(st.checkpoints, cp)
+	if len(st.checkpoints) > maxCheckpoints {
+		st.checkpoints = st.checkpoints[len(st.checkpoints)-maxCheckpoints:]
+	}
+	st.mu.Unlock()
+	return cp
+}
+
+// findCheckpoint returns the checkpoint with the given ID, or the latest one when id is empty
+func (st *sessionState) findCheckpoint(id string) (checkpoint, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for i := len(st.checkpoints) - 1; i >= 0; i-- {
+		if id == "" || st.checkpoints[i].ID == id {
+			return st.checkpoints[i], true
+		}
+	}
+	return checkpoint{}, false
+}
+
+// diffCheckpoint reports which of the checkpoint's files changed since it was taken
+func diffCheckpoint(cp checkpoint, workingDir string) []checkpointChange {
+	changes := []checkpointChange{}
+	for _, f := range cp.Files {
+		digest, _ := fileutil.FileDigest(resolveSessionPath(workingDir, f.Path))
+		switch {
+		case digest == f.Digest:
+			continue
+		case digest == "":
+			changes = append(changes, checkpointChange{Path: f.Path, Status: "deleted"})
+		case f.Digest == "":
+			changes = append(changes, checkpointChange{Path: f.Path, Status: "created"})
+		default:
+			changes = append(changes, checkpointChange{Path: f.Path, Status: "modified"})
+		}
+	}
+	return changes
+}
+
+// resolveSessionPath resolves a relative (chip) path against the session working
+// directory, falling back to the process working directory.
+func resolveSessionPath(workingDir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	if workingDir == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			workingDir = cwd
+		}
+	}
+	return filepath.Join(workingDir, p)
+}
@@ -0,0 +1,60 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint_DiffSinceCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.go", "line0\nline1\nline2\n")
+	write("b.go", "package b\n")
+	write("c.go", "package c\n")
+
+	st := &sessionState{workingDir: dir, lastSeq: 7}
+	st.rememberInjectedUnsafe([]string{"b.go", "c.go", "b.go", "new.go"})
+
+	cp := st.createCheckpoint("<[#a.go:0-0][a.go:0-0]> refactor", nil)
+	if cp.Seq != 7 || len(cp.Files) != 4 {
+		t.Fatalf("Unexpected checkpoint: %+v", cp)
+	}
+
+	if changes := diffCheckpoint(cp, dir); len(changes) != 0 {
+		t.Fatalf("Expected no changes right after checkpoint, got %+v", changes)
+	}
+
+	write("a.go", "line0\nedited\nline2\n") // outside the referenced range
+	write("b.go", "package b // edited\n")
+	if err := os.Remove(filepath.Join(dir, "c.go")); err != nil {
+		t.Fatal(err)
+	}
+	write("new.go", "package new\n")
+
+	got := map[string]string{}
+	for _, c := range diffCheckpoint(cp, dir) {
+		got[c.Path] = c.Status
+	}
+	want := map[string]string{"b.go": "modified", "c.go": "deleted", "new.go": "created"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected changes %v, got %v", want, got)
+	}
+	for p, status := range want {
+		if got[p] != status {
+			t.Errorf("Expected %s to be %s, got %q", p, status, got[p])
+		}
+	}
+
+	if latest, ok := st.findCheckpoint(""); !ok || latest.ID != cp.ID {
+		t.Errorf("Expected latest checkpoint %s, got %+v", cp.ID, latest)
+	}
+	if _, ok := st.findCheckpoint("missing"); ok {
+		t.Errorf("Expected unknown checkpoint to be missing")
+	}
+}
@@ -2,17 +2,27 @@ package ws
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os/exec"
 	"runtime"
+	"time"
 )
 
+// clipboardCommand returns a clipboard utility invocation that is killed when ctx ends
+func clipboardCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Do not wait on children of a killed utility that still hold its output open
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
 // getClipboard returns current system clipboard text using best-effort, cross-platform approach.
-func getClipboard() (string, error) {
+func getClipboard(ctx context.Context) (string, error) {
 	switch runtime.GOOS {
 	case "darwin":
 		// pbpaste
-		out, err := exec.Command("pbpaste").Output()
+		out, err := clipboardCommand(ctx, "pbpaste").Output()
 		if err != nil {
 			return "", err
 		}
@@ -20,7 +30,7 @@ func getClipboard() (string, error) {
 	case "windows":
 		// Use PowerShell to read clipboard as raw text
 		// -Raw avoids extra newlines, and we write directly to stdout
-		cmd := exec.Command("powershell", "-NoProfile", "-Command", `[Console]::Out.Write((Get-Clipboard -Raw))`)
+		cmd := clipboardCommand(ctx, "powershell", "-NoProfile", "-Command", `[Console]::Out.Write((Get-Clipboard -Raw))`)
 		out, err := cmd.Output()
 		if err != nil {
 			return "", err
@@ -28,15 +38,15 @@ func getClipboard() (string, error) {
 		return string(out), nil
 	default:
 		// Try Wayland first: wl-paste
-		if out, err := exec.Command("wl-paste", "-n").Output(); err == nil {
+		if out, err := clipboardCommand(ctx, "wl-paste", "-n").Output(); err == nil {
 			return string(out), nil
 		}
 		// Try xclip (X11)
-		if out, err := exec.Command("xclip", "-selection", "clipboard", "-o").Output(); err == nil {
+		if out, err := clipboardCommand(ctx, "xclip", "-selection", "clipboard", "-o").Output(); err == nil {
 			return string(out), nil
 		}
 		// Try xsel (X11)
-		if out, err := exec.Command("xsel", "-b", "-o").Output(); err == nil {
+		if out, err := clipboardCommand(ctx, "xsel", "-b", "-o").Output(); err == nil {
 			return string(out), nil
 		}
 		return "", errors.New("no clipboard utility available (tried wl-paste, xclip, xsel)")
@@ -44,34 +54,34 @@ func getClipboard() (string, error) {
 }
 
 // setClipboard sets system clipboard text using best-effort, cross-platform approach.
-func setClipboard(s string) error {
+func setClipboard(ctx context.Context, s string) error {
 	data := []byte(s)
 	switch runtime.GOOS {
 	case "darwin":
 		// pbcopy reads from stdin
-		cmd := exec.Command("pbcopy")
+		cmd := clipboardCommand(ctx, "pbcopy")
 		cmd.Stdin = bytes.NewReader(data)
 		return cmd.Run()
 	case "windows":
 		// Use PowerShell, pipe stdin and set clipboard with exact content
-		cmd := exec.Command("powershell", "-NoProfile", "-Command", `Set-Clipboard -Value ([Console]::In.ReadToEnd())`)
+		cmd := clipboardCommand(ctx, "powershell", "-NoProfile", "-Command", `Set-Clipboard -Value ([Console]::In.ReadToEnd())`)
 		cmd.Stdin = bytes.NewReader(data)
 		return cmd.Run()
 	default:
 		// Try Wayland: wl-copy
-		cmd := exec.Command("wl-copy", "--type", "text/plain")
+		cmd := clipboardCommand(ctx, "wl-copy", "--type", "text/plain")
 		cmd.Stdin = bytes.NewReader(data)
 		if err := cmd.Run(); err == nil {
 			return nil
 		}
 		// Try xclip (X11)
-		cmd = exec.Command("xclip", "-selection", "clipboard")
+		cmd = clipboardCommand(ctx, "xclip", "-selection", "clipboard")
 		cmd.Stdin = bytes.NewReader(data)
 		if err := cmd.Run(); err == nil {
 			return nil
 		}
 		// Try xsel (X11)
-		cmd = exec.Command("xsel", "-b", "-i")
+		cmd = clipboardCommand(ctx, "xsel", "-b", "-i")
 		cmd.Stdin = bytes.NewReader(data)
 		if err := cmd.Run(); err == nil {
 			return nil
@@ -79,3 +89,23 @@ func setClipboard(s string) error {
 		return errors.New("no clipboard utility available to set content (tried wl-copy, xclip, xsel)")
 	}
 }
+
+// readClipboard reads the clipboard within the configured clipboard timeout
+func (r *Router) readClipboard(ctx context.Context) (string, error) {
+	if d := r.getTimeouts().Clipboard; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	return getClipboard(ctx)
+}
+
+// writeClipboard sets the clipboard within the configured clipboard timeout
+func (r *Router) writeClipboard(ctx context.Context, s string) error {
+	if d := r.getTimeouts().Clipboard; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	return setClipboard(ctx, s)
+}
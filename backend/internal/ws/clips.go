@@ -0,0 +1,139 @@
+package ws
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clipPollInterval is how often the clipboard is sampled while clip history is enabled
+	clipPollInterval = time.Second
+	// maxClips bounds the clip history; the oldest clips are dropped first
+	maxClips = 20
+	// maxClipBytes skips clips larger than this (e.g. whole files copied by accident)
+	maxClipBytes = 64 * 1024
+)
+
+// clip is a text copied to the system clipboard
+type clip struct {
+	Text string `json:"text"`
+	Time int64  `json:"time"` // unix milliseconds
+}
+
+// clipHistory records recent clipboard texts while enabled. It is opt-in, keeps clips in
+// memory only and forgets them when disabled. Payloads the bridge itself puts on the
+// clipboard for injection are not recorded.
+type clipHistory struct {
+	mu      sync.Mutex
+	read    func() (string, error)
+	clips   []clip // newest last
+	last    string
+	ignored map[string]bool
+	stop    chan struct{}
+}
+
+func newClipHistory() *clipHistory {
+	return &clipHistory{read: func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeouts().Clipboard)
+		defer cancel()
+		return getClipboard(ctx)
+	}}
+}
+
+// setEnabled starts or stops watching the clipboard; disabling clears the history
+func (h *clipHistory) setEnabled(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if enabled == (h.stop != nil) {
+		return
+	}
+	if !enabled {
+		close(h.stop)
+		h.stop = nil
+		h.clips, h.last, h.ignored = nil, "", nil
+		return
+	}
+	h.stop = make(chan struct{})
+	// The current clipboard content predates enabling and is not recorded
+	if text, err := h.read(); err == nil {
+		h.last = text
+	}
+	go h.watch(h.stop)
+}
+
+func (h *clipHistory) watch(stop chan struct{}) {
+	ticker := time.NewTicker(clipPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if text, err := h.read(); err == nil {
+				h.observe(text, time.Now())
+			}
+		}
+	}
+}
+
+// observe records text if it differs from the last clipboard content
+func (h *clipHistory) observe(text string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stop == nil || text == h.last {
+		return
+	}
+	h.last = text
+	if h.ignored[text] {
+		delete(h.ignored, text)
+		return
+	}
+	if strings.TrimSpace(text) == "" || len(text) > maxClipBytes {
+		return
+	}
+	// A text copied again moves to the front instead of being duplicated
+	for i, c := range h.clips {
+		if c.Text == text {
+			h.clips = append(h.clips[:i], h.clips[i+1:]...)
+			break
+		}
+	}
+	h.clips = append(h.clips, clip{Text: text, Time: now.UnixMilli()})
+	if len(h.clips) > maxClips {
+		h.clips = h.clips[len(h.clips)-maxClips:]
+	}
+}
+
+// ignore excludes a clipboard text set by the bridge from the history
+func (h *clipHistory) ignore(text string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stop == nil {
+		return
+	}
+	// Payloads replaced before the next poll are never observed; do not let them pile up
+	if h.ignored == nil || len(h.ignored) >= maxClips {
+		h.ignored = map[string]bool{}
+	}
+	h.ignored[text] = true
+}
+
+// list returns the recorded clips, newest first
+func (h *clipHistory) list() []clip {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]clip, 0, len(h.clips))
+	for i := len(h.clips) - 1; i >= 0; i-- {
+		out = append(out, h.clips[i])
+	}
+	return out
+}
+
+// enabled reports whether the clipboard is being watched
+func (h *clipHistory) enabled() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stop != nil
+}
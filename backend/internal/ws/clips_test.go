@@ -0,0 +1,46 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClipHistory(t *testing.T) {
+	h := newClipHistory()
+	h.read = func() (string, error) { return "before", nil }
+	now := time.Now()
+
+	h.observe("ignored while disabled", now)
+	h.setEnabled(true)
+	defer h.setEnabled(false)
+
+	h.observe("before", now) // present when enabled, not recorded
+	h.observe("first", now)
+	h.observe("   ", now)
+	h.observe(strings.Repeat("x", maxClipBytes+1), now)
+	h.ignore("injected payload")
+	h.observe("injected payload", now)
+	h.observe("second", now.Add(time.Second))
+	h.observe("first", now.Add(2*time.Second))
+
+	clips := h.list()
+	if len(clips) != 2 || clips[0].Text != "first" || clips[1].Text != "second" {
+		t.Fatalf("unexpected clips: %+v", clips)
+	}
+	if clips[0].Time != now.Add(2*time.Second).UnixMilli() {
+		t.Errorf("expected re-copied clip to get the new time, got %d", clips[0].Time)
+	}
+
+	for i := 0; i < maxClips+5; i++ {
+		h.observe(strings.Repeat("c", i+1), now)
+	}
+	if n := len(h.list()); n != maxClips {
+		t.Errorf("expected history capped at %d, got %d", maxClips, n)
+	}
+
+	h.setEnabled(false)
+	if h.enabled() || len(h.list()) != 0 {
+		t.Fatal("expected disabling to stop watching and clear clips")
+	}
+}
@@ -0,0 +1,195 @@
+package ws
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxCodeBlocks bounds the code blocks kept per session; the oldest go first
+	maxCodeBlocks = 200
+	// maxCodeBlockBytes bounds the content of one code block; a fence left open by a
+	// truncated answer must not swallow the rest of the session
+	maxCodeBlockBytes = 256 * 1024
+)
+
+// codeFence matches the opening line of a fenced code block, "```go" or "~~~ python",
+// possibly indented. The info string may carry more than the language after a space.
+var codeFence = regexp.MustCompile("^([ \t]*)(`{3,}|~{3,})[ \t]*([^`\\s]*)[^`]*$")
+
+// codeBlock is a fenced code block printed by the agent, its content with the fence's
+// indentation removed
+type codeBlock struct {
+	ID               int    `json:"id"`
+	Language         string `json:"language,omitempty"`
+	LanguageDetected bool   `json:"languageDetected,omitempty"` // guessed from the content; the fence named none
+	Content          string `json:"content"`
+	Lines            int    `json:"lines"`
+	Start            int64  `json:"start"`        // stream offset of the opening fence
+	End              int64  `json:"end"`          // stream offset just past the closing fence
+	ContentStart     int64  `json:"contentStart"` // stream offset of the first content line
+	Truncated        bool   `json:"truncated,omitempty"`
+}
+
+// codeBlockTracker finds fenced code blocks in the completed plain-text lines of a
+// session's output
+type codeBlockTracker struct {
+	blocks []codeBlock
+	nextID int
+
+	open    *codeBlock
+	fence   string // the opening fence, closed by a run of at least as many of its character
+	indent  string
+	content []string
+	size    int
+}
+
+// feed takes completed output lines
+func (t *codeBlockTracker) feed(lines []plainLine) {
+	next := int64(-1)
+	for _, l := range lines {
+		start, end := next, next
+		if len(l.offsets) > 0 {
+			start, end = l.offsets[0], l.offsets[len(l.offsets)-1]+1
+		}
+		next = end
+		if t.open == nil {
+			sm := codeFence.FindStringSubmatch(l.text)
+			if sm == nil {
+				continue
+			}
+			t.open = &codeBlock{Language: strings.ToLower(sm[3]), Start: start, ContentStart: -1}
+			t.indent, t.fence = sm[1], sm[2]
+			t.content, t.size = nil, 0
+			continue
+		}
+		if closesFence(l.text, t.fence) {
+			t.open.End = end
+			t.finish()
+			continue
+		}
+		if t.open.ContentStart < 0 {
+			t.open.ContentStart = start
+		}
+		text := strings.TrimPrefix(l.text, t.indent)
+		if t.size+len(text)+1 > maxCodeBlockBytes {
+			t.open.Truncated = true
+			continue
+		}
+		t.content = append(t.content, text)
+		t.size += len(text) + 1
+	}
+}
+
+// closesFence reports whether line is a closing fence for the opening fence open
+func closesFence(line, open string) bool {
+	s := strings.TrimSpace(line)
+	return len(s) >= len(open) && strings.Trim(s, open[:1]) == ""
+}
+
+// finish completes the open block and keeps it
+func (t *codeBlockTracker) finish() {
+	b := t.open
+	t.open = nil
+	if b.ContentStart < 0 {
+		b.ContentStart = b.End
+	}
+	b.Content = strings.Join(t.content, "\n")
+	if len(t.content) > 0 {
+		b.Content += "\n"
+	}
+	b.Lines = len(t.content)
+	t.content = nil
+	if b.Language == "" {
+		b.Language = detectLanguage(b.Content)
+		b.LanguageDetected = b.Language != ""
+	}
+	t.nextID++
+	b.ID = t.nextID
+	if len(t.blocks) >= maxCodeBlocks {
+		t.blocks = append(t.blocks[:0], t.blocks[1:]...)
+	}
+	t.blocks = append(t.blocks, *b)
+}
+
+// get returns the block with id
+func (t *codeBlockTracker) get(id int) (codeBlock, bool) {
+	for _, b := range t.blocks {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	return codeBlock{}, false
+}
+
+// languageHints recognise the languages agents print most, by a line of their content;
+// the first hint matching any line wins
+var languageHints = []struct {
+	lang string
+	re   *regexp.Regexp
+}{
+	{"go", regexp.MustCompile(`^package \w+$|^func (\(\w+ \*?\w+\) )?\w+\(`)},
+	{"python", regexp.MustCompile(`^(def|class) \w+.*:$|^(from [\w.]+ )?import [\w., ]+$|^if __name__ == `)},
+	{"rust", regexp.MustCompile(`^(pub )?fn \w+|^use \w+::|\blet mut\b`)},
+	{"typescript", regexp.MustCompile(`^(export )?(interface|type) \w+.*[={]|: (string|number|boolean)\b`)},
+	{"javascript", regexp.MustCompile(`^(const|let|var) \w+ = |\brequire\(|^export (default|function|const)|=> \{`)},
+	{"java", regexp.MustCompile(`^(public |private )?(class|interface) \w+|^import java\.`)},
+	{"bash", regexp.MustCompile(`^#!/(usr/)?bin/(env )?(ba)?sh|^\$ \w|^(sudo|npm|go|pip|git|cd|export) `)},
+	{"sql", regexp.MustCompile(`(?i)^(select|insert into|update|delete from|create table)\b`)},
+	{"html", regexp.MustCompile(`(?i)^<(!doctype|html|div|head|body)\b`)},
+	{"yaml", regexp.MustCompile(`^[\w-]+:( .*)?$`)},
+}
+
+// detectLanguage guesses the language of code printed without one, or returns ""
+func detectLanguage(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return ""
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+	if strings.HasPrefix(trimmed, "diff --git ") || strings.HasPrefix(trimmed, "--- ") && strings.Contains(trimmed, "\n+++ ") {
+		return "diff"
+	}
+	lines := strings.Split(trimmed, "\n")
+	for _, h := range languageHints {
+		for _, l := range lines {
+			if h.re.MatchString(strings.TrimSpace(l)) {
+				return h.lang
+			}
+		}
+	}
+	return ""
+}
+
+// recordCodeBlocks takes completed output lines into the session's code blocks
+func (st *sessionState) recordCodeBlocks(lines []plainLine) {
+	st.mu.Lock()
+	st.codeBlocks.feed(lines)
+	st.mu.Unlock()
+}
+
+// listCodeBlocks answers listCodeBlocks with the session's code blocks in output order,
+// those after the block afterId or in one language only when asked
+func (r *Router) listCodeBlocks(conn *websocket.Conn, sid, language string, afterID int) error {
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	if st == nil {
+		Errorf(conn, "no session")
+		return nil
+	}
+	blocks := []codeBlock{}
+	st.mu.Lock()
+	for _, b := range st.codeBlocks.blocks {
+		if b.ID > afterID && (language == "" || strings.EqualFold(b.Language, language)) {
+			blocks = append(blocks, b)
+		}
+	}
+	st.mu.Unlock()
+	return SendJSON(conn, map[string]any{"type": "codeBlocks", "sessionId": sid, "blocks": blocks})
+}
@@ -0,0 +1,72 @@
+package ws
+
+import "testing"
+
+func TestCodeBlockTracker_FencesAcrossChunks(t *testing.T) {
+	var m plainTextMirror
+	var tr codeBlockTracker
+	out := "Here is the fix:\n  ```go\n  func main() {\n  }\n  ```\nand a script:\n"
+	tr.feed(m.write([]byte(out[:20])))
+	tr.feed(m.write([]byte(out[20:])))
+	tr.feed(m.write([]byte("~~~~\n\x1b[32m#!/bin/sh\x1b[0m\n```\nstill code\n~~~~\n")))
+
+	if len(tr.blocks) != 2 {
+		t.Fatalf("got %d blocks: %+v", len(tr.blocks), tr.blocks)
+	}
+	goBlock := tr.blocks[0]
+	if goBlock.Language != "go" || goBlock.LanguageDetected || goBlock.Content != "func main() {\n}\n" || goBlock.Lines != 2 {
+		t.Fatalf("unexpected block: %+v", goBlock)
+	}
+	if goBlock.Start != int64(len("Here is the fix:\n")) || goBlock.End != int64(len("Here is the fix:\n  ```go\n  func main() {\n  }\n  ```")) {
+		t.Fatalf("unexpected offsets: %+v", goBlock)
+	}
+	if goBlock.ContentStart != int64(len("Here is the fix:\n  ```go\n")) {
+		t.Fatalf("unexpected content start: %+v", goBlock)
+	}
+
+	// A shorter fence of the other kind does not close the block
+	sh := tr.blocks[1]
+	if sh.Language != "bash" || !sh.LanguageDetected || sh.Content != "#!/bin/sh\n```\nstill code\n" || sh.ID != 2 {
+		t.Fatalf("unexpected block: %+v", sh)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]string{
+		`{"a": [1, 2]}`:                       "json",
+		"def main():\n    pass\n":             "python",
+		"package main\n":                      "go",
+		"const x = require('fs')\n":           "javascript",
+		"SELECT * FROM users;\n":              "sql",
+		"diff --git a/x b/x\n":                "diff",
+		"just some words\nwithout any code\n": "",
+	}
+	for content, want := range cases {
+		if got := detectLanguage(content); got != want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestRouter_ListCodeBlocks(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	fs.last(t).emit("```python\nprint(1)\n```\n```go\npackage x\n```\n")
+	readStdout(t, c, "package x")
+
+	_ = c.WriteJSON(map[string]any{"type": "listCodeBlocks", "sessionId": "s1", "language": "GO"})
+	msg := readType(t, c, "codeBlocks")
+	blocks := msg["blocks"].([]any)
+	if len(blocks) != 1 || blocks[0].(map[string]any)["content"] != "package x\n" {
+		t.Fatalf("unexpected blocks: %v", msg)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "listCodeBlocks", "sessionId": "s1", "afterId": 2})
+	if msg := readType(t, c, "codeBlocks"); len(msg["blocks"].([]any)) != 0 {
+		t.Fatalf("unexpected blocks: %v", msg)
+	}
+}
@@ -0,0 +1,75 @@
+package ws
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// encodingGzip is the only snapshot encoding the bridge offers; zstd would need a
+	// dependency outside the standard library
+	encodingGzip = "gzip"
+	// minCompressBytes is the smallest snapshot worth compressing
+	minCompressBytes = 1 << 10
+)
+
+// negotiateEncoding picks the first encoding of the client's preference list the bridge
+// supports, or "" for none
+func negotiateEncoding(offered any) string {
+	list, _ := offered.([]any)
+	for _, v := range list {
+		if v == encodingGzip {
+			return encodingGzip
+		}
+	}
+	return ""
+}
+
+// setSnapshotEncoding records the encoding negotiated in the hello of conn
+func (r *Router) setSnapshotEncoding(conn *websocket.Conn, enc string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if enc == "" {
+		delete(r.snapshotEncodings, conn)
+		return
+	}
+	r.snapshotEncodings[conn] = enc
+}
+
+// snapshotMessage builds the snapshot of session sid for conn. Output of at least
+// minCompressBytes is compressed with the encoding conn negotiated, when that makes it
+// smaller; the message then names the encoding and the size of the raw output.
+func (r *Router) snapshotMessage(conn *websocket.Conn, sid string, data []byte, lastSeq uint64, plain bool) map[string]any {
+	msg := map[string]any{"type": "snapshot", "sessionId": sid, "lastSeq": lastSeq}
+	payload := data
+	r.mu.Lock()
+	enc := r.snapshotEncodings[conn]
+	r.mu.Unlock()
+	if enc == encodingGzip && len(data) >= minCompressBytes {
+		if z, err := gzipBytes(data); err == nil && len(z) < len(data) {
+			payload = z
+			msg["encoding"] = enc
+			msg["rawBytes"] = len(data)
+		}
+	}
+	msg["dataBase64"] = base64.StdEncoding.EncodeToString(payload)
+	return addPlainSnapshot(msg, plain, data)
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
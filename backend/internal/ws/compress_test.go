@@ -0,0 +1,63 @@
+package ws
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRouter_SnapshotCompressedWhenNegotiated(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "hello", "features": map[string]any{"compression": []string{"zstd", "gzip"}}})
+	if f := readType(t, c, "welcome")["features"].(map[string]any); f["compression"] != "gzip" {
+		t.Fatalf("expected gzip to be picked, got %v", f)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	out := strings.Repeat("building package 42\r\n", 200)
+	fs.last(t).emit(out)
+	readStdout(t, c, out)
+
+	_ = c.WriteJSON(map[string]any{"type": "snapshot", "sessionId": "s1"})
+	snap := readType(t, c, "snapshot")
+	if snap["encoding"] != "gzip" || snap["rawBytes"] != float64(len(out)) {
+		t.Fatalf("expected a gzip snapshot, got encoding %v and rawBytes %v", snap["encoding"], snap["rawBytes"])
+	}
+	z, _ := base64.StdEncoding.DecodeString(snap["dataBase64"].(string))
+	if len(z) >= len(out) {
+		t.Fatalf("compressed snapshot is %d bytes for %d of output", len(z), len(out))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(z))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != out {
+		t.Fatalf("unexpected snapshot %q", data)
+	}
+}
+
+func TestRouter_SnapshotUncompressedByDefault(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "hello", "features": map[string]any{"compression": []string{"zstd"}}})
+	if f := readType(t, c, "welcome")["features"].(map[string]any); f["compression"] != nil {
+		t.Fatalf("expected no encoding, got %v", f)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	out := strings.Repeat("x", 2000)
+	fs.last(t).emit(out)
+	readStdout(t, c, out)
+	_ = c.WriteJSON(map[string]any{"type": "snapshot", "sessionId": "s1"})
+	if snap := readType(t, c, "snapshot"); snap["encoding"] != nil || snap["dataBase64"] != b64(out) {
+		t.Fatalf("unexpected snapshot: encoding %v", snap["encoding"])
+	}
+}
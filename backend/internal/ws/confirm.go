@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// confirmationTimeout is how long a confirmationRequired challenge can be answered
+	confirmationTimeout = 30 * time.Second
+	// maxPendingConfirmations bounds the unanswered challenges over all connections
+	maxPendingConfirmations = 64
+)
+
+// pendingConfirmation is a dangerous operation parked until the client that requested it
+// echoes its token back with a confirm message
+type pendingConfirmation struct {
+	conn      *websocket.Conn
+	operation string
+	run       func() error
+	timer     *time.Timer
+}
+
+// requireConfirmation parks run and sends conn a confirmationRequired challenge describing
+// it. The operation only runs if the same connection confirms before the timeout, so the
+// guardrail holds no matter which client sent the request.
+func (r *Router) requireConfirmation(conn *websocket.Conn, operation, summary string, run func() error) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		Errorf(conn, "%s: %v", operation, err)
+		return nil
+	}
+	token := hex.EncodeToString(b)
+	p := &pendingConfirmation{conn: conn, operation: operation, run: run}
+
+	r.mu.Lock()
+	if len(r.confirmations) >= maxPendingConfirmations {
+		r.mu.Unlock()
+		Errorf(conn, "%s: too many unanswered confirmations", operation)
+		return nil
+	}
+	r.confirmations[token] = p
+	p.timer = time.AfterFunc(confirmationTimeout, func() {
+		r.mu.Lock()
+		if r.confirmations[token] == p {
+			delete(r.confirmations, token)
+		}
+		r.mu.Unlock()
+	})
+	r.mu.Unlock()
+
+	return SendJSON(conn, map[string]any{
+		"type":        "confirmationRequired",
+		"operation":   operation,
+		"summary":     summary,
+		"token":       token,
+		"expiresInMs": confirmationTimeout.Milliseconds(),
+	})
+}
+
+// resolveConfirmation runs (approved) or discards the operation parked under token
+func (r *Router) resolveConfirmation(conn *websocket.Conn, token string, approved bool) error {
+	r.mu.Lock()
+	p := r.confirmations[token]
+	if p != nil && p.conn == conn {
+		delete(r.confirmations, token)
+		p.timer.Stop()
+	} else {
+		p = nil
+	}
+	r.mu.Unlock()
+	if p == nil {
+		Errorf(conn, "confirm: unknown or expired confirmation")
+		return nil
+	}
+	if !approved {
+		log.Printf("confirm: %s declined", p.operation)
+		return nil
+	}
+	return p.run()
+}
+
+// dropConfirmations discards the challenges issued to a closing connection
+func (r *Router) dropConfirmations(conn *websocket.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for token, p := range r.confirmations {
+		if p.conn == conn {
+			p.timer.Stop()
+			delete(r.confirmations, token)
+		}
+	}
+}
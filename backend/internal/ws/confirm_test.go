@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"testing"
+)
+
+func TestRouter_CustomCommandRequiresConfirmation(t *testing.T) {
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	other, closeOther := dialRouter(t, r)
+	defer closeOther()
+
+	_ = c.WriteJSON(map[string]any{"type": "updateSessionConfig", "customCommand": "rm -rf /"})
+	challenge := readType(t, c, "confirmationRequired")
+	token, _ := challenge["token"].(string)
+	if token == "" || challenge["operation"] != "updateSessionConfig" || challenge["summary"] != `Run "rm -rf /" in new sessions` {
+		t.Fatalf("unexpected challenge: %v", challenge)
+	}
+	if cmd, _ := r.sessionDefaults(); cmd != "" {
+		t.Fatal("expected the command to stay unchanged until confirmed")
+	}
+
+	// Only the connection that asked can confirm
+	_ = other.WriteJSON(map[string]any{"type": "confirm", "token": token})
+	readType(t, other, "error")
+
+	_ = c.WriteJSON(map[string]any{"type": "confirm", "token": token})
+	cfg := readType(t, c, "sessionConfigUpdated")["sessionConfig"].(map[string]any)
+	if cfg["cmd"] != "rm" {
+		t.Fatalf("expected confirmed command to apply, got %v", cfg)
+	}
+
+	// A token is good for one use
+	_ = c.WriteJSON(map[string]any{"type": "confirm", "token": token})
+	readType(t, c, "error")
+}
+
+func TestRouter_DeclinedConfirmation(t *testing.T) {
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "updateSessionConfig", "customCommand": "agent --yolo"})
+	token := readType(t, c, "confirmationRequired")["token"]
+	_ = c.WriteJSON(map[string]any{"type": "confirm", "token": token, "approved": false})
+
+	// Clearing the command is safe and needs no confirmation
+	_ = c.WriteJSON(map[string]any{"type": "updateSessionConfig", "customCommand": ""})
+	cfg := readType(t, c, "sessionConfigUpdated")["sessionConfig"].(map[string]any)
+	if cfg["cmd"] != "acli" {
+		t.Fatalf("expected the default command, got %v", cfg)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.confirmations) != 0 {
+		t.Errorf("expected no pending confirmations, got %d", len(r.confirmations))
+	}
+}
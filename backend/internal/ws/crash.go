@@ -0,0 +1,165 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// CrashLogName is the crash log kept in the user's home directory by default
+const CrashLogName = ".rovobridge-crash.log"
+
+// maxCrashLogBytes is the size at which the crash log is rotated to <path>.1
+const maxCrashLogBytes = 1 << 20
+
+// CrashReport describes a panic recovered while handling a client message
+type CrashReport struct {
+	Time        time.Time `json:"time"`
+	MessageType string    `json:"messageType"`
+	SessionID   string    `json:"sessionId,omitempty"`
+	Panic       string    `json:"panic"`
+	Stack       string    `json:"stack"`
+	GoVersion   string    `json:"goVersion"`
+	OS          string    `json:"os"`
+	Arch        string    `json:"arch"`
+}
+
+// crashLog records recovered panics. It has its own lock: a handler may panic while
+// holding r.mu, and reporting the crash must not wait for it.
+type crashLog struct {
+	mu   sync.Mutex
+	path string // JSON lines of crash reports; empty keeps them in memory only
+	last *CrashReport
+}
+
+// DefaultCrashLogPath returns the crash log in the user's home directory
+func DefaultCrashLogPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return CrashLogName
+	}
+	return filepath.Join(home, CrashLogName)
+}
+
+// SetCrashLog sets the file recovered panics are appended to; empty disables the file
+func (r *Router) SetCrashLog(path string) {
+	r.crashes.mu.Lock()
+	r.crashes.path = path
+	r.crashes.mu.Unlock()
+}
+
+// handleSafely dispatches a message, turning a panic in its handler into an internalError
+// reply and a crash report instead of taking down the bridge and every session with it
+func (r *Router) handleSafely(ctx context.Context, conn *websocket.Conn, m map[string]any) {
+	defer func() {
+		if v := recover(); v != nil {
+			rep := r.crashes.record(m, v, debug.Stack())
+			ErrorCode(conn, "internalError", map[string]any{"messageType": rep.MessageType},
+				"internal error handling %s; details were written to the crash log", rep.MessageType)
+		}
+	}()
+	_ = r.handle(ctx, conn, m)
+}
+
+func (c *crashLog) record(m map[string]any, v any, stack []byte) CrashReport {
+	typ, _ := m["type"].(string)
+	sid, _ := m["sessionId"].(string)
+	if sid == "" {
+		sid, _ = m["id"].(string)
+	}
+	rep := CrashReport{
+		Time:        time.Now().UTC(),
+		MessageType: typ,
+		SessionID:   sid,
+		Panic:       fmt.Sprint(v),
+		Stack:       string(stack),
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+	}
+	log.Printf("panic handling %q: %s\n%s", typ, rep.Panic, stack)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last = &rep
+	if c.path != "" {
+		if err := appendCrashReport(c.path, rep); err != nil {
+			log.Printf("crash log: %v", err)
+		}
+	}
+	return rep
+}
+
+func appendCrashReport(path string, rep CrashReport) error {
+	if fi, err := os.Stat(path); err == nil && fi.Size() >= maxCrashLogBytes {
+		_ = os.Rename(path, path+".1")
+	}
+	line, err := json.Marshal(rep)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LastCrash returns the most recent crash report, reading the crash log when none happened
+// in this process so a restarted bridge can still hand over the previous one
+func (r *Router) LastCrash() (*CrashReport, error) {
+	r.crashes.mu.Lock()
+	defer r.crashes.mu.Unlock()
+	if r.crashes.last != nil || r.crashes.path == "" {
+		return r.crashes.last, nil
+	}
+	f, err := os.Open(r.crashes.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var last *CrashReport
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), maxCrashLogBytes)
+	for sc.Scan() {
+		var rep CrashReport
+		if json.Unmarshal(sc.Bytes(), &rep) == nil {
+			last = &rep
+		}
+	}
+	return last, sc.Err()
+}
+
+// WriteLastCrash serves the most recent crash report as JSON, or 204 when there is none
+func (r *Router) WriteLastCrash(w http.ResponseWriter) {
+	rep, err := r.LastCrash()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rep == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rep)
+}
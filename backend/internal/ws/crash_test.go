@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/rovobridge/internal/session"
+)
+
+func TestRouter_RecoversHandlerPanics(t *testing.T) {
+	r, fs := newTestRouter(t)
+	logPath := filepath.Join(t.TempDir(), "crash.log")
+	r.SetCrashLog(logPath)
+
+	rec := httptest.NewRecorder()
+	r.WriteLastCrash(rec)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("no crash yet: status %d", rec.Code)
+	}
+
+	r.startSession = func(context.Context, session.Config) (ptySession, error) { panic("boom") }
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	msg := readType(t, c, "error")
+	if msg["code"] != "internalError" || msg["messageType"] != "openSession" {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+
+	// The connection and the bridge keep working
+	r.startSession = fs.start
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s2"})
+	readType(t, c, "opened")
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged CrashReport
+	if err := json.Unmarshal(data, &logged); err != nil {
+		t.Fatalf("crash log: %v\n%s", err, data)
+	}
+	if logged.Panic != "boom" || !strings.HasPrefix(logged.SessionID, "ses_") || !strings.Contains(logged.Stack, "crash_test.go") {
+		t.Fatalf("unexpected report: %+v", logged)
+	}
+
+	// A restarted bridge hands over the report from the log
+	r2, _ := newTestRouter(t)
+	r2.SetCrashLog(logPath)
+	rec = httptest.NewRecorder()
+	r2.WriteLastCrash(rec)
+	var served CrashReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, %v: %s", rec.Code, err, rec.Body)
+	}
+	if served.Panic != "boom" || served.MessageType != "openSession" {
+		t.Fatalf("unexpected served report: %+v", served)
+	}
+}
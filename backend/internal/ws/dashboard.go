@@ -0,0 +1,117 @@
+package ws
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dashboardActiveWindow is how recent output must be for a session to count as running
+const dashboardActiveWindow = 2 * time.Second
+
+// ProcessUsage is the resource usage of a session's process, where the platform reports it
+type ProcessUsage struct {
+	CPUMs    int64 `json:"cpuMs" doc:"User and system CPU time"`
+	RSSBytes int64 `json:"rssBytes" doc:"Resident memory"`
+}
+
+// DashboardSession is one session of the dashboard overview
+type DashboardSession struct {
+	ID            string        `json:"id"`
+	Alias         string        `json:"alias,omitempty"`
+	Label         string        `json:"label,omitempty" doc:"The label given in openSession"`
+	State         string        `json:"state" enum:"starting,running,awaitingPermission,stalled,idle,exited"`
+	Attached      bool          `json:"attached"`
+	PID           int           `json:"pid,omitempty"`
+	WorkingDir    string        `json:"workingDir,omitempty"`
+	UptimeMs      int64         `json:"uptimeMs,omitempty" doc:"Since the process started"`
+	LastActivity  *time.Time    `json:"lastActivity,omitempty" doc:"Last output or input"`
+	Usage         *ProcessUsage `json:"usage,omitempty" doc:"Of the session's process itself, not its children; Linux only"`
+	PendingQueue  int           `json:"pendingQueue" doc:"Messages for the session waiting behind the one being handled"`
+	BufferedBytes int           `json:"bufferedBytes" doc:"Output read but not yet sent"`
+	ClientQueue   int           `json:"clientQueue" doc:"Messages queued to the attached client"`
+	ExitCode      *int          `json:"exitCode,omitempty" doc:"For a session whose process exited in the last 5 minutes"`
+}
+
+// stateNameUnsafe sums up what a session is doing. Caller must hold st.mu.
+func (st *sessionState) stateNameUnsafe(now time.Time) string {
+	switch {
+	case st.startup.pending:
+		return "starting"
+	case st.permission != nil:
+		return "awaitingPermission"
+	case st.liveness.stalled:
+		return "stalled"
+	case st.liveness.busy, !st.promptSentAt.IsZero(), now.Sub(st.lastEnqueue) < dashboardActiveWindow:
+		return "running"
+	}
+	return "idle"
+}
+
+// dashboard summarizes every session, exited ones included, in one message
+func (r *Router) dashboard(conn *websocket.Conn) error {
+	now := time.Now()
+	r.mu.Lock()
+	states := make(map[string]*sessionState, len(r.sessionStates))
+	pids := make(map[string]int, len(r.sessions))
+	aliases := make(map[string]string, len(r.aliases))
+	var exited []DashboardSession
+	for id, st := range r.sessionStates {
+		states[id] = st
+	}
+	for id, sess := range r.sessions {
+		pids[id] = sess.PID()
+	}
+	for id, a := range r.aliases {
+		aliases[id] = a
+	}
+	for id, ev := range r.events {
+		if !ev.exited || states[id] != nil {
+			continue
+		}
+		ds := DashboardSession{ID: id, Alias: aliases[id], State: "exited"}
+		if n := len(ev.events); n > 0 {
+			if code, ok := ev.events[n-1]["code"].(int); ok {
+				ds.ExitCode = &code
+			}
+		}
+		exited = append(exited, ds)
+	}
+	r.mu.Unlock()
+
+	sessions := append([]DashboardSession{}, exited...)
+	for id, st := range states {
+		st.mu.Lock()
+		ds := DashboardSession{
+			ID:            id,
+			Alias:         aliases[id],
+			Label:         st.label,
+			State:         st.stateNameUnsafe(now),
+			Attached:      st.currentConn != nil,
+			PID:           pids[id],
+			WorkingDir:    st.workingDir,
+			BufferedBytes: len(st.outBuf),
+		}
+		if !st.startup.started.IsZero() {
+			ds.UptimeMs = now.Sub(st.startup.started).Milliseconds()
+		}
+		last := st.lastEnqueue
+		if st.lastInput.After(last) {
+			last = st.lastInput
+		}
+		if !last.IsZero() {
+			ds.LastActivity = &last
+		}
+		c := st.currentConn
+		st.mu.Unlock()
+		if c != nil {
+			ds.ClientQueue = queuedFor(c)
+		}
+		ds.PendingQueue = r.dispatcher.laneLen("session:" + id)
+		ds.Usage = processUsage(ds.PID)
+		sessions = append(sessions, ds)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return SendJSON(conn, map[string]any{"type": "dashboard", "at": now.UnixMilli(), "sessions": sessions})
+}
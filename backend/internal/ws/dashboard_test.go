@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestRouter_DashboardSummarizesSessions(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "build", "label": "Build agent", "readyPattern": `^>$`})
+	readType(t, c, "opened")
+	build := fs.last(t)
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "review"})
+	readType(t, c, "opened")
+	review := fs.last(t)
+
+	build.emit("> ")
+	readType(t, c, "ready")
+	review.exit(errors.New("killed"))
+	readType(t, c, "exit")
+
+	_ = c.WriteJSON(map[string]any{"type": "dashboard"})
+	msg := readType(t, c, "dashboard")
+	byAlias := map[string]map[string]any{}
+	for _, s := range msg["sessions"].([]any) {
+		s := s.(map[string]any)
+		byAlias[s["alias"].(string)] = s
+	}
+	b := byAlias["build"]
+	if b == nil || b["label"] != "Build agent" || b["attached"] != true || b["pid"] != float64(4242) || b["lastActivity"] == nil {
+		t.Fatalf("unexpected build session: %v", b)
+	}
+	if b["state"] != "running" && b["state"] != "idle" {
+		t.Fatalf("unexpected state of a ready session: %v", b["state"])
+	}
+	if rv := byAlias["review"]; rv == nil || rv["state"] != "exited" || rv["exitCode"] != float64(-1) {
+		t.Fatalf("unexpected exited session: %v", rv)
+	}
+}
+
+func TestProcessUsage_OwnProcess(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process usage is read from /proc")
+	}
+	u := processUsage(os.Getpid())
+	if u == nil || u.RSSBytes <= 0 || u.CPUMs < 0 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	if processUsage(0) != nil {
+		t.Fatal("expected no usage without a process")
+	}
+}
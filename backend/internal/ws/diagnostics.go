@@ -0,0 +1,61 @@
+package ws
+
+import (
+	"strconv"
+
+	"github.com/example/rovobridge/internal/diagnostics"
+)
+
+// maxDiagSeen bounds the per-session memory of reported diagnostics; TUIs redraw the
+// same error lines repeatedly and each one should be reported once
+const maxDiagSeen = 500
+
+// emitDiagnostics parses completed plain-text output lines and sends a "diagnostic"
+// event for each new compiler/test error to the session's current connection.
+func (r *Router) emitDiagnostics(sid string, st *sessionState, lines []plainLine) {
+	var found []diagnostics.Diagnostic
+	for _, line := range lines {
+		if d, ok := diagnostics.Parse(line.text); ok {
+			found = append(found, d)
+		}
+	}
+	if len(found) == 0 {
+		return
+	}
+
+	st.mu.Lock()
+	conn := st.currentConn
+	fresh := found[:0]
+	for _, d := range found {
+		if st.markDiagnosticSeenUnsafe(d) {
+			fresh = append(fresh, d)
+		}
+	}
+	st.mu.Unlock()
+
+	for _, d := range fresh {
+		msg := map[string]any{"type": "diagnostic", "sessionId": sid, "diagnostic": d}
+		r.recordEvent(sid, msg)
+		if conn != nil {
+			_ = SendJSON(conn, msg)
+		}
+	}
+}
+
+// markDiagnosticSeenUnsafe records d and reports whether it was new. The caller must hold st.mu.
+func (st *sessionState) markDiagnosticSeenUnsafe(d diagnostics.Diagnostic) bool {
+	key := d.File + ":" + strconv.Itoa(d.Line) + ":" + strconv.Itoa(d.Column) + ":" + d.Message
+	if st.diagSeen[key] {
+		return false
+	}
+	if st.diagSeen == nil {
+		st.diagSeen = map[string]bool{}
+	}
+	st.diagSeen[key] = true
+	st.diagSeenKeys = append(st.diagSeenKeys, key)
+	if len(st.diagSeenKeys) > maxDiagSeen {
+		delete(st.diagSeen, st.diagSeenKeys[0])
+		st.diagSeenKeys = st.diagSeenKeys[1:]
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxPendingMessages bounds the messages of one connection queued or running at once;
+// beyond it the connection's read loop waits, pushing back on the client
+const maxPendingMessages = 256
+
+// DefaultWorkers is the number of message handlers run at once by default
+func DefaultWorkers() int {
+	return max(4, 2*runtime.GOMAXPROCS(0))
+}
+
+// parallelMessages are read-only queries; they run as soon as a worker is free, in any
+// order relative to other messages
+var parallelMessages = map[string]bool{
+	"searchIndex": true, "exportIndex": true, "getIndexStatus": true, "normalizePaths": true, "resolveReferences": true, "selectContext": true, "suggestPrompts": true,
+	"queryHistoryByPath": true, "queryHistory": true, "listPorts": true, "diagnostics": true, "checkUpdate": true,
+	"getStats": true, "listClips": true, "listSnippets": true, "usageStats": true, "listCodeBlocks": true, "dashboard": true,
+}
+
+// inlineMessages are transport messages that only adjust the connection's writer; they
+// are handled in the read loop as they arrive, ahead of any queued work
+var inlineMessages = map[string]bool{"channelCredit": true}
+
+// barrierMessages change state that the handling of later messages depends on, or act on
+// several sessions. They run once the connection's earlier messages are done, and its
+// later messages wait for them.
+var barrierMessages = map[string]bool{
+	"hello": true, "updateInjectionSettings": true, "setGitCheckpoints": true,
+	"registerSnippet": true, "removeSnippet": true, "setRedaction": true,
+	"updateSessionConfig": true, "confirm": true, "claimSession": true, "broadcastSend": true,
+}
+
+// dispatcher runs message handlers on a bounded pool of workers. Messages for a session
+// are handled in order, one at a time; other messages of a connection are ordered among
+// themselves, so a slow injectFiles holds up neither other sessions nor searches.
+type dispatcher struct {
+	sem chan struct{} // one token per running handler
+
+	mu    sync.Mutex
+	lanes map[string][]func()           // handlers waiting behind a running one, by ordering key
+	conns map[*websocket.Conn]*connJobs // outstanding handlers per connection
+}
+
+// connJobs tracks the handlers of one connection that are queued or running
+type connJobs struct {
+	wg      sync.WaitGroup
+	pending chan struct{} // one token per queued or running handler
+}
+
+func newDispatcher(workers int) *dispatcher {
+	if workers <= 0 {
+		workers = DefaultWorkers()
+	}
+	return &dispatcher{
+		sem:   make(chan struct{}, workers),
+		lanes: map[string][]func(){},
+		conns: map[*websocket.Conn]*connJobs{},
+	}
+}
+
+// SetWorkers sets how many message handlers run at once; call it before serving
+func (r *Router) SetWorkers(n int) {
+	r.dispatcher = newDispatcher(n)
+}
+
+// dispatchKey returns how a message is ordered: "" with parallel set for read-only
+// queries, "" alone for barriers, else the key of the lane it is queued on
+func dispatchKey(conn *websocket.Conn, m map[string]any) (key string, parallel bool) {
+	typ, _ := m["type"].(string)
+	switch {
+	case parallelMessages[typ]:
+		return "", true
+	case barrierMessages[typ]:
+		return "", false
+	}
+	// openSession included: resolveSessionRefs has set the id of the session it opens
+	if sid, _ := m["sessionId"].(string); sid != "" {
+		return "session:" + sid, false
+	}
+	return fmt.Sprintf("conn:%p", conn), false
+}
+
+// dispatch hands a message read from conn to a worker. It is called from the read loop
+// of conn, so it sees the connection's messages in order; it only blocks to run a
+// barrier or when the connection has maxPendingMessages outstanding. Without a
+// dispatcher messages are handled in the read loop, one at a time.
+func (r *Router) dispatch(ctx context.Context, conn *websocket.Conn, m map[string]any) {
+	if typ, _ := m["type"].(string); inlineMessages[typ] {
+		r.handleSafely(ctx, conn, m)
+		return
+	}
+	if r.capabilityGuard(conn, m) {
+		return
+	}
+	r.resolveSessionRefs(conn, m)
+	d := r.dispatcher
+	if d == nil {
+		r.handleSafely(ctx, conn, m)
+		return
+	}
+	jobs := d.jobs(conn)
+	key, parallel := dispatchKey(conn, m)
+	if key == "" && !parallel {
+		jobs.wg.Wait()
+		r.handleSafely(ctx, conn, m)
+		return
+	}
+	jobs.pending <- struct{}{}
+	jobs.wg.Add(1)
+	job := func() {
+		defer func() {
+			<-jobs.pending
+			jobs.wg.Done()
+		}()
+		r.handleSafely(ctx, conn, m)
+	}
+	if parallel {
+		go d.run(job)
+		return
+	}
+	d.mu.Lock()
+	queue, busy := d.lanes[key]
+	d.lanes[key] = append(queue, job)
+	d.mu.Unlock()
+	if !busy {
+		go d.drain(key)
+	}
+}
+
+// laneLen returns the number of handlers waiting on a lane
+func (d *dispatcher) laneLen(key string) int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.lanes[key])
+}
+
+// run executes a handler once a worker is free
+func (d *dispatcher) run(job func()) {
+	d.sem <- struct{}{}
+	defer func() { <-d.sem }()
+	job()
+}
+
+// drain runs the handlers queued on a lane in order until it is empty
+func (d *dispatcher) drain(key string) {
+	for {
+		d.mu.Lock()
+		queue := d.lanes[key]
+		if len(queue) == 0 {
+			delete(d.lanes, key)
+			d.mu.Unlock()
+			return
+		}
+		job := queue[0]
+		d.lanes[key] = queue[1:]
+		d.mu.Unlock()
+		d.run(job)
+	}
+}
+
+func (d *dispatcher) jobs(conn *websocket.Conn) *connJobs {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	jobs := d.conns[conn]
+	if jobs == nil {
+		jobs = &connJobs{pending: make(chan struct{}, maxPendingMessages)}
+		d.conns[conn] = jobs
+	}
+	return jobs
+}
+
+// finish waits for the handlers still running for a closed connection and forgets it,
+// so connection cleanup does not race with them
+func (d *dispatcher) finish(conn *websocket.Conn) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	jobs := d.conns[conn]
+	delete(d.conns, conn)
+	d.mu.Unlock()
+	if jobs != nil {
+		jobs.wg.Wait()
+	}
+}
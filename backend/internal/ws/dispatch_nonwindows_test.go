@@ -0,0 +1,62 @@
+//go:build !windows
+
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDispatch_StuckInjectionHoldsOnlyItsSession(t *testing.T) {
+	dir := t.TempDir()
+	// Opening a FIFO without a writer blocks, like a read on a dead mount
+	fifo := filepath.Join(dir, "stuck")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("mkfifo unavailable: %v", err)
+	}
+	release := func() {
+		if w, err := os.OpenFile(fifo, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			w.Close()
+		}
+	}
+	defer release()
+
+	r, fs := newTestRouter(t)
+	r.SetTimeouts(Timeouts{FileRead: time.Minute})
+	c, done := dialRouter(t, r)
+	defer done()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	s1 := fs.last(t)
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s2"})
+	readType(t, c, "opened")
+	s2 := fs.last(t)
+
+	_ = c.WriteJSON(map[string]any{"type": "injectFiles", "sessionId": "s1", "paths": []string{fifo}})
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("after")})
+
+	// Other sessions and queries go on while s1 waits for the file
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s2", "dataBase64": b64("ls\r")})
+	_ = c.WriteJSON(map[string]any{"type": "resize", "sessionId": "s2", "cols": 100, "rows": 30})
+	_ = c.WriteJSON(map[string]any{"type": "listSnippets"})
+	readType(t, c, "snippets")
+	eventually(t, "s2 stdin and resize", func() bool {
+		s2.mu.Lock()
+		defer s2.mu.Unlock()
+		return s2.stdin.String() == "ls\r" && len(s2.resizes) == 1
+	})
+	if got := s1.stdinString(); got != "" {
+		t.Fatalf("s1 stdin %q handled before its injection", got)
+	}
+
+	// s1's input follows its injection once the read completes
+	release()
+	readType(t, c, "injectResult")
+	eventually(t, "s1 stdin", func() bool { return s1.stdinString() != "" })
+	if got := s1.stdinString(); len(got) < 5 || got[len(got)-5:] != "after" {
+		t.Fatalf("s1 stdin = %q, want the injection then input", got)
+	}
+}
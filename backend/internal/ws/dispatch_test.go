@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDispatchKey(t *testing.T) {
+	conn := new(websocket.Conn)
+	for _, tc := range []struct {
+		msg      map[string]any
+		key      string
+		parallel bool
+	}{
+		{map[string]any{"type": "searchIndex", "pattern": "x"}, "", true},
+		{map[string]any{"type": "hello"}, "", false},
+		{map[string]any{"type": "broadcastSend", "sessionIds": []any{"s1", "s2"}}, "", false},
+		{map[string]any{"type": "openSession", "id": "s2", "sessionId": "k7"}, "session:k7", false},
+		{map[string]any{"type": "stdin", "sessionId": "s2"}, "session:s2", false},
+		{map[string]any{"type": "injectFiles", "sessionId": "s1"}, "session:s1", false},
+		{map[string]any{"type": "openInEditor"}, fmt.Sprintf("conn:%p", conn), false},
+	} {
+		if key, parallel := dispatchKey(conn, tc.msg); key != tc.key || parallel != tc.parallel {
+			t.Errorf("%v: got (%q, %v), want (%q, %v)", tc.msg, key, parallel, tc.key, tc.parallel)
+		}
+	}
+	// Each connection orders its other messages on a lane of its own
+	if a, b := dispatchKey(conn, map[string]any{"type": "setEditorContext"}); a == "" || b {
+		t.Fatalf("setEditorContext = (%q, %v)", a, b)
+	} else if other, _ := dispatchKey(new(websocket.Conn), map[string]any{"type": "setEditorContext"}); other == a {
+		t.Fatal("connections share a lane")
+	}
+}
+
+func TestDispatch_KeepsSessionOrder(t *testing.T) {
+	r, fs := newTestRouter(t)
+	r.SetWorkers(2)
+	c, done := dialRouter(t, r)
+	defer done()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	var want strings.Builder
+	for i := range 200 {
+		chunk := fmt.Sprintf("%d,", i)
+		want.WriteString(chunk)
+		_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64(chunk)})
+		// Searches in between run alongside and must not reorder the session's input
+		_ = c.WriteJSON(map[string]any{"type": "listSnippets"})
+	}
+	eventually(t, "all stdin", func() bool { return len(f.stdinString()) == want.Len() })
+	if got := f.stdinString(); got != want.String() {
+		t.Fatalf("stdin out of order: %q", got)
+	}
+}
@@ -0,0 +1,20 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/example/rovobridge/internal/doctor"
+	"github.com/gorilla/websocket"
+)
+
+// runDoctor checks the environment in the background, since asking the agent CLI for its
+// version can take seconds, and replies with a diagnosticsReport
+func (r *Router) runDoctor(conn *websocket.Conn) error {
+	command, _ := r.sessionDefaults()
+	opts := doctor.Options{Command: command, History: r.historyManager}
+	go func() {
+		rep := doctor.Run(context.Background(), opts)
+		_ = SendJSON(conn, map[string]any{"type": "diagnosticsReport", "status": rep.Worst(), "report": rep})
+	}()
+	return nil
+}
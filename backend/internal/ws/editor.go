@@ -0,0 +1,155 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// clientIDE is the hello "client" value sent by IDE plugin connections
+const clientIDE = "ide"
+
+// registerEditorConn marks conn as an IDE plugin connection able to open files
+func (r *Router) registerEditorConn(conn *websocket.Conn) {
+	r.mu.Lock()
+	r.editorConns[conn] = true
+	r.mu.Unlock()
+}
+
+// editorConnections returns the attached IDE plugin connections
+func (r *Router) editorConnections() []*websocket.Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conns := make([]*websocket.Conn, 0, len(r.editorConns))
+	for c := range r.editorConns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// openInEditor forwards a request to open path at line/column to the attached IDE
+// plugins. Relative paths are resolved against the session's working directory. It
+// reports how many IDE connections the request was delivered to.
+func (r *Router) openInEditor(sid, path string, line, column int) int {
+	abs := filepath.Clean(resolveSessionPath(r.sessionWorkingDir(sid), path))
+	msg := map[string]any{"type": "openInEditor", "path": abs}
+	if sid != "" {
+		msg["sessionId"] = sid
+	}
+	if line > 0 {
+		msg["line"] = line
+	}
+	if column > 0 {
+		msg["column"] = column
+	}
+	delivered := 0
+	for _, c := range r.editorConnections() {
+		if err := SendJSON(c, msg); err == nil {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// editorContext is the IDE state pushed with setEditorContext for one workspace
+type editorContext struct {
+	File         string // absolute path of the active file
+	Selection    string // selected text, empty when nothing is selected
+	StartLine    int    // 1-based selection range
+	EndLine      int
+	CursorLine   int
+	CursorColumn int
+}
+
+// parseEditorContext reads a setEditorContext message:
+// { file?: string, selection?: { text, startLine, endLine }, cursor?: { line, column } }
+func parseEditorContext(m map[string]any, workspace string) editorContext {
+	var ctx editorContext
+	if file, _ := m["file"].(string); file != "" {
+		ctx.File = filepath.Clean(resolveSessionPath(workspace, file))
+	}
+	if sel, ok := m["selection"].(map[string]any); ok {
+		ctx.Selection, _ = sel["text"].(string)
+		ctx.StartLine = asInt(sel["startLine"])
+		ctx.EndLine = asInt(sel["endLine"])
+	}
+	if cur, ok := m["cursor"].(map[string]any); ok {
+		ctx.CursorLine = asInt(cur["line"])
+		ctx.CursorColumn = asInt(cur["column"])
+	}
+	return ctx
+}
+
+// editorWorkspaceKey normalizes a workspace directory for the editorContexts map
+func editorWorkspaceKey(dir string) string {
+	if dir == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			dir = cwd
+		}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return filepath.Clean(dir)
+}
+
+// setEditorContext stores the IDE state of a workspace; an empty context clears it
+func (r *Router) setEditorContext(workspace string, ctx editorContext) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctx == (editorContext{}) {
+		delete(r.editorContexts, workspace)
+		return
+	}
+	r.editorContexts[workspace] = ctx
+}
+
+// editorContextFor returns the IDE state of the innermost workspace containing dir
+func (r *Router) editorContextFor(dir string) (editorContext, string, bool) {
+	dir = editorWorkspaceKey(dir)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	best := ""
+	found := false
+	for ws := range r.editorContexts {
+		rel, err := filepath.Rel(ws, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(ws) > len(best) {
+			best, found = ws, true
+		}
+	}
+	return r.editorContexts[best], best, found
+}
+
+// expandEditorPlaceholders replaces {currentFile} and {selection} in a prompt with the
+// IDE state of the workspace. {currentFile} becomes the active file relative to the
+// workspace; {selection} the selected text, labelled with its file and line range.
+// Placeholders without a value expand to an empty string.
+func expandEditorPlaceholders(text string, ctx editorContext, workspace string) string {
+	if !strings.Contains(text, "{currentFile}") && !strings.Contains(text, "{selection}") {
+		return text
+	}
+	file := ctx.File
+	if file != "" && workspace != "" {
+		if rel, err := filepath.Rel(workspace, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = filepath.ToSlash(rel)
+		}
+	}
+	selection := ""
+	if ctx.Selection != "" {
+		selection = ctx.Selection
+		if file != "" && ctx.StartLine > 0 {
+			label := file + ":" + strconv.Itoa(ctx.StartLine)
+			if ctx.EndLine > ctx.StartLine {
+				label += "-" + strconv.Itoa(ctx.EndLine)
+			}
+			selection = "\n" + label + ":\n```\n" + strings.TrimSuffix(selection, "\n") + "\n```\n"
+		}
+	}
+	return strings.NewReplacer("{currentFile}", file, "{selection}", selection).Replace(text)
+}
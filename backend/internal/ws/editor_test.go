@@ -0,0 +1,62 @@
+package ws
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestOpenInEditor_ForwardsToIDE(t *testing.T) {
+	r := &Router{sessionStates: map[string]*sessionState{}, connSessions: map[*websocket.Conn]map[string]bool{}, editorConns: map[*websocket.Conn]bool{}}
+
+	browser, closeBrowser := dialRouter(t, r)
+	defer closeBrowser()
+	_ = browser.WriteJSON(map[string]any{"type": "openInEditor", "path": "src/app.ts", "line": 12})
+	if msg := readType(t, browser, "error"); msg["message"] != "openInEditor: no IDE plugin attached" {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+
+	ide, closeIDE := dialRouter(t, r)
+	defer closeIDE()
+	_ = ide.WriteJSON(map[string]any{"type": "hello", "client": "ide"})
+	readType(t, ide, "welcome")
+
+	dir := t.TempDir()
+	r.sessionStates["s1"] = &sessionState{workingDir: dir}
+	_ = browser.WriteJSON(map[string]any{"type": "openInEditor", "sessionId": "s1", "path": "src/app.ts", "line": 12, "column": 5})
+	msg := readType(t, ide, "openInEditor")
+	if msg["path"] != filepath.Join(dir, "src", "app.ts") || msg["line"] != float64(12) || msg["column"] != float64(5) {
+		t.Fatalf("unexpected openInEditor: %v", msg)
+	}
+}
+
+func TestEditorContext_PlaceholderExpansion(t *testing.T) {
+	r := &Router{editorContexts: map[string]editorContext{}}
+	workspace := t.TempDir()
+	key := editorWorkspaceKey(workspace)
+	r.setEditorContext(key, parseEditorContext(map[string]any{
+		"file":      "src/app.ts",
+		"selection": map[string]any{"text": "const x = 1;\n", "startLine": float64(3), "endLine": float64(4)},
+		"cursor":    map[string]any{"line": float64(4), "column": float64(1)},
+	}, key))
+
+	ctx, ws, ok := r.editorContextFor(filepath.Join(workspace, "sub"))
+	if !ok || ws != key || ctx.CursorLine != 4 {
+		t.Fatalf("expected context of enclosing workspace, got %+v %q %v", ctx, ws, ok)
+	}
+	if _, _, ok := r.editorContextFor(t.TempDir()); ok {
+		t.Fatal("expected no context outside the workspace")
+	}
+
+	got := expandEditorPlaceholders("explain {selection} in {currentFile}", ctx, ws)
+	want := "explain \nsrc/app.ts:3-4:\n```\nconst x = 1;\n```\n in src/app.ts"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	r.setEditorContext(key, editorContext{})
+	if _, _, ok := r.editorContextFor(workspace); ok {
+		t.Fatal("expected empty context to clear the workspace")
+	}
+}
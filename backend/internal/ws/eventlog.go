@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSessionLogBytes is the size at which a session's event log is rotated to <file>.1
+const maxSessionLogBytes = 1 << 20
+
+// SessionEvent is one line of a session's event log
+type SessionEvent struct {
+	Time      time.Time      `json:"time"`
+	SessionID string         `json:"sessionId"`
+	Event     string         `json:"event"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+// sessionEventLog appends the lifecycle events of each session to <dir>/<session>.jsonl:
+// opens, resizes, sends (with digests, not content), stdin rejections, detaches and exits,
+// so a session that died unattended can be looked into afterwards. It has its own lock, as
+// events are logged with and without r.mu or a session's lock held.
+type sessionEventLog struct {
+	mu  sync.Mutex
+	dir string // empty keeps no logs
+}
+
+// DefaultSessionLogDir returns the directory session event logs are kept in: rovobridge/sessions
+// in the user's cache directory
+func DefaultSessionLogDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rovobridge", "sessions")
+}
+
+// SetSessionLogDir turns on the per-session event logs in dir; empty turns them off
+func (r *Router) SetSessionLogDir(dir string) {
+	r.sessionLogs.mu.Lock()
+	r.sessionLogs.dir = dir
+	r.sessionLogs.mu.Unlock()
+}
+
+// logSessionEvent appends an event to the session's log when logs are on
+func (r *Router) logSessionEvent(sid, event string, fields map[string]any) {
+	r.sessionLogs.append(SessionEvent{Time: time.Now().UTC(), SessionID: sid, Event: event, Fields: fields})
+}
+
+func (l *sessionEventLog) append(ev SessionEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.dir == "" {
+		return
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("session log: %v", err)
+		return
+	}
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		log.Printf("session log: %v", err)
+		return
+	}
+	path := filepath.Join(l.dir, sessionLogName(ev.SessionID))
+	if fi, err := os.Stat(path); err == nil && fi.Size() >= maxSessionLogBytes {
+		_ = os.Rename(path, path+".1")
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("session log: %v", err)
+		return
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("session log: %v", err)
+	}
+	_ = f.Close()
+}
+
+// sessionLogName is the log file of a session; ids are chosen by clients, so anything but
+// letters, digits, '-' and '_' is replaced
+func sessionLogName(sid string) string {
+	name := strings.Map(func(c rune) rune {
+		if c == '-' || c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			return c
+		}
+		return '_'
+	}, sid)
+	if name == "" {
+		name = "_"
+	}
+	return name + ".jsonl"
+}
+
+// digest returns the hex SHA-256 of b, which logs what was sent without its content
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readSessionLog(t *testing.T, path string) []SessionEvent {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var events []SessionEvent
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev SessionEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("bad log line %q: %v", sc.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestRouter_SessionEventLog(t *testing.T) {
+	r, fs := newTestRouter(t)
+	dir := t.TempDir()
+	r.SetSessionLogDir(dir)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s/1", "cols": 80, "rows": 24})
+	sid := readType(t, c, "opened")["sessionId"].(string)
+	_ = c.WriteJSON(map[string]any{"type": "resize", "sessionId": "s/1", "cols": 120, "rows": 40})
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s/1", "dataBase64": b64("secret prompt")})
+	fake := fs.last(t)
+	eventually(t, "stdin delivered", func() bool { return strings.Contains(fake.stdinString(), "secret prompt") })
+	fake.exit(nil)
+	readType(t, c, "exit")
+
+	path := filepath.Join(dir, sid+".jsonl")
+	var events []SessionEvent
+	eventually(t, "exit logged", func() bool {
+		events = readSessionLog(t, path)
+		return len(events) > 0 && events[len(events)-1].Event == "exit"
+	})
+	var names []string
+	for _, ev := range events {
+		if ev.SessionID != sid {
+			t.Fatalf("unexpected session id: %+v", ev)
+		}
+		names = append(names, ev.Event)
+	}
+	if got := strings.Join(names, ","); got != "opened,resized,send,exit" {
+		t.Fatalf("events = %s", got)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("log holds sent content: %s", data)
+	}
+}
+
+func TestSessionEventLog_Rotates(t *testing.T) {
+	dir := t.TempDir()
+	l := sessionEventLog{dir: dir}
+	path := filepath.Join(dir, "s1.jsonl")
+	if err := os.WriteFile(path, make([]byte, maxSessionLogBytes), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l.append(SessionEvent{SessionID: "s1", Event: "send"})
+	if fi, err := os.Stat(path + ".1"); err != nil || fi.Size() != maxSessionLogBytes {
+		t.Fatalf("not rotated: %v", err)
+	}
+	if events := readSessionLog(t, path); len(events) != 1 || events[0].Event != "send" {
+		t.Fatalf("events after rotation: %+v", events)
+	}
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxSessionEvents bounds the events kept per session for late-joining clients
+	maxSessionEvents = 64
+	// exitedEventsRetention is how long the events of an exited session stay available
+	exitedEventsRetention = 5 * time.Minute
+)
+
+// sessionEvents is the recent non-stdout events of a session (exit, diagnostics), replayed
+// to a client that attaches or resumes so it does not miss what happened while it was
+// away. It outlives the session's process by exitedEventsRetention.
+type sessionEvents struct {
+	events []map[string]any
+	exited bool
+	expire *time.Timer
+}
+
+// recordEvent appends msg to the events of session sid
+func (r *Router) recordEvent(sid string, msg map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ev := r.events[sid]
+	if ev == nil {
+		ev = &sessionEvents{}
+		r.events[sid] = ev
+	}
+	if len(ev.events) >= maxSessionEvents {
+		ev.events = append(ev.events[:0], ev.events[len(ev.events)-maxSessionEvents+1:]...)
+	}
+	ev.events = append(ev.events, msg)
+	if msg["type"] == "exit" {
+		ev.exited = true
+		ev.expire = time.AfterFunc(exitedEventsRetention, func() {
+			r.mu.Lock()
+			if r.events[sid] == ev {
+				delete(r.events, sid)
+			}
+			r.mu.Unlock()
+		})
+	}
+}
+
+// clearEvents forgets the events of session sid, when a new process takes the id over
+func (r *Router) clearEvents(sid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ev := r.events[sid]; ev != nil {
+		if ev.expire != nil {
+			ev.expire.Stop()
+		}
+		delete(r.events, sid)
+	}
+}
+
+// replayEvents sends the recorded events of session sid to conn, marked replayed, and
+// reports whether they end with the session's exit
+func (r *Router) replayEvents(conn *websocket.Conn, sid string) bool {
+	r.mu.Lock()
+	var events []map[string]any
+	exited := false
+	if ev := r.events[sid]; ev != nil {
+		events = append(events, ev.events...)
+		exited = ev.exited
+	}
+	r.mu.Unlock()
+	for _, e := range events {
+		msg := make(map[string]any, len(e)+1)
+		for k, v := range e {
+			msg[k] = v
+		}
+		msg["replayed"] = true
+		_ = SendJSON(conn, msg)
+	}
+	return exited
+}
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -78,7 +79,7 @@ func TestFontSizeEndpoint_ReturnsAndResets(t *testing.T) {
 	}
 
 	// Simulate frontend notifying font size changed via router.handle
-	_ = r.handle(nil, map[string]any{"type": "fontSizeChanged", "fontSize": 18})
+	_ = r.handle(context.Background(), nil, map[string]any{"type": "fontSizeChanged", "fontSize": 18})
 
 	// Read value -> expect 18
 	req1, _ := http.NewRequest("GET", ts.URL+"/font-size", nil)
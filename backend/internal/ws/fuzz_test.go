@@ -0,0 +1,130 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func FuzzSanitizeSnapshot(f *testing.F) {
+	f.Add([]byte("\r\n]11;rgb:0000/0000/0000\x07prompt> "))
+	f.Add([]byte("]10;rgb:ffff/ffff/ffff\x1b\\text"))
+	f.Add([]byte("\x1b]11;?\x07plain"))
+	f.Add([]byte("]11;unterminated"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		in := append([]byte(nil), b...)
+		out := sanitizeSnapshot(b)
+		if !bytes.Equal(b, in) {
+			t.Fatal("input was modified")
+		}
+		if len(out) > len(b) {
+			t.Fatalf("output grew from %d to %d bytes", len(b), len(out))
+		}
+		if !bytes.Contains(b, []byte("]")) && !bytes.Equal(out, b) {
+			t.Fatalf("input without OSC remnants changed: %q -> %q", b, out)
+		}
+	})
+}
+
+// fuzzPathKeys are message fields naming files or directories; the fuzzer's values are
+// confined to a temporary directory so inputs cannot read devices or write into the tree
+var fuzzPathKeys = map[string]bool{"cwd": true, "path": true, "file": true, "workspace": true, "projectCwd": true}
+
+// confineFuzzMessage rewrites paths into dir and disables features with effects outside
+// the process (system clipboard, desktop notifications)
+func confineFuzzMessage(m map[string]any, dir string) bool {
+	switch m["type"] {
+	case "setClipHistory", "updateNotifications", "updateUseClipboard":
+		return false
+	}
+	for k, v := range m {
+		switch {
+		case fuzzPathKeys[k]:
+			if s, ok := v.(string); ok {
+				m[k] = filepath.Join(dir, filepath.Base(s))
+			}
+		case k == "paths":
+			if arr, ok := v.([]any); ok {
+				for i, p := range arr {
+					if s, ok := p.(string); ok {
+						arr[i] = filepath.Join(dir, filepath.Base(s))
+					}
+				}
+			}
+		}
+	}
+	m["useClipboard"] = false
+	return true
+}
+
+func FuzzRouterHandle(f *testing.F) {
+	for _, seed := range []string{
+		`{"type":"hello","client":"ide"}`,
+		`{"type":"openSession","id":"s1","cols":80,"rows":24}`,
+		`{"type":"stdin","sessionId":"s1","dataBase64":"aGkK"}`,
+		`{"type":"resize","sessionId":"s1","cols":-1,"rows":1e9}`,
+		`{"type":"injectFiles","sessionId":"s1","paths":["a.go:3-1",""],"options":{"tabWidth":-4,"controlChars":"x"}}`,
+		`{"type":"send","sessionId":"s1","dataBase64":"e3NlbGVjdGlvbn0=","injectOutputTail":99999,"paths":[1,null]}`,
+		`{"type":"searchIndex","pattern":"","limit":-1,"opened":"x"}`,
+		`{"type":"createCheckpoint","sessionId":"s1"}`,
+		`{"type":"diffSinceCheckpoint","sessionId":"s1","checkpointId":"missing"}`,
+		`{"type":"saveDraft","sessionId":"s1","text":{}}`,
+		`{"type":"registerSnippet","name":"x","text":"y"}`,
+		`{"type":"snapshot","sessionId":"nope"}`,
+		`{"type":"selectContext","text":"fix router","budget":-5}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	dir := f.TempDir()
+	f.Chdir(dir)
+	f.Setenv("HOME", f.TempDir())
+	f.Setenv("USERPROFILE", f.TempDir())
+	r := NewRouter("")
+	fs := &fakeSessions{}
+	r.startSession = fs.start
+
+	// A server-side connection for replies; the client drains them
+	conns := make(chan *websocket.Conn, 1)
+	done := make(chan struct{})
+	up := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c, err := up.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		conns <- c
+		<-done // keep the connection open for the whole run
+	}))
+	f.Cleanup(func() { close(done); ts.Close() })
+	client, _, err := websocket.DefaultDialer.Dial(wsURLFromHTTP(ts.URL, "/"), nil)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { client.Close() })
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	conn := <-conns
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var m map[string]any
+		if json.Unmarshal(data, &m) != nil || m == nil {
+			return
+		}
+		if !confineFuzzMessage(m, dir) {
+			return
+		}
+		_ = r.handle(context.Background(), conn, m)
+	})
+}
@@ -0,0 +1,73 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/example/rovobridge/internal/gitcheckpoint"
+	"github.com/gorilla/websocket"
+)
+
+// maxListedGitCheckpoints bounds the checkpoints returned by listCheckpoints
+const maxListedGitCheckpoints = 50
+
+// checkpointMessage summarizes a prompt as the subject of its git checkpoint
+func checkpointMessage(prompt string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "send"
+	}
+	if r := []rune(line); len(r) > 72 {
+		line = string(r[:71]) + "…"
+	}
+	return line
+}
+
+// gitCheckpointBeforeSend commits the session's work tree to its checkpoint ref when git
+// checkpoints are enabled. A failure is reported to the client but does not stop the send.
+func (r *Router) gitCheckpointBeforeSend(conn *websocket.Conn, sid, prompt string) {
+	r.mu.Lock()
+	enabled := r.gitCheckpoints
+	r.mu.Unlock()
+	if !enabled {
+		return
+	}
+	cp, err := gitcheckpoint.Create(context.Background(), r.sessionWorkingDir(sid), gitcheckpoint.Ref(sid), checkpointMessage(prompt))
+	if err != nil {
+		ErrorCode(conn, "gitCheckpointFailed", map[string]any{"sessionId": sid}, "git checkpoint: %v", err)
+		return
+	}
+	_ = SendJSON(conn, map[string]any{"type": "gitCheckpointCreated", "sessionId": sid, "checkpoint": cp})
+}
+
+// listGitCheckpoints answers listCheckpoints with the session's checkpoints, newest first
+func (r *Router) listGitCheckpoints(conn *websocket.Conn, sid string) error {
+	list, err := gitcheckpoint.List(context.Background(), r.sessionWorkingDir(sid), gitcheckpoint.Ref(sid), maxListedGitCheckpoints)
+	if err != nil {
+		Errorf(conn, "listCheckpoints: %v", err)
+		return nil
+	}
+	return SendJSON(conn, map[string]any{"type": "checkpointList", "sessionId": sid, "checkpoints": list})
+}
+
+// restoreGitCheckpoint rolls the session's work tree back to a checkpoint once the client
+// confirms. The state it replaces is checkpointed first and reported as undoId.
+func (r *Router) restoreGitCheckpoint(conn *websocket.Conn, sid, id string) error {
+	dir := r.sessionWorkingDir(sid)
+	summary := fmt.Sprintf("Restore the files of %s to checkpoint %s", dir, id)
+	return r.requireConfirmation(conn, "restoreCheckpoint", summary, func() error {
+		undo, err := gitcheckpoint.Restore(context.Background(), dir, gitcheckpoint.Ref(sid), id)
+		if errors.Is(err, gitcheckpoint.ErrUnknownCheckpoint) {
+			Errorf(conn, "restoreCheckpoint: unknown checkpoint %q", id)
+			return nil
+		}
+		if err != nil {
+			Errorf(conn, "restoreCheckpoint: %v", err)
+			return nil
+		}
+		return SendJSON(conn, map[string]any{"type": "checkpointRestored", "sessionId": sid, "checkpointId": id, "undoId": undo.ID})
+	})
+}
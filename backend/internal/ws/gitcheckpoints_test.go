@@ -0,0 +1,61 @@
+package ws
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouter_GitCheckpointsAroundSends(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir, "useClipboard": false})
+	readType(t, c, "opened")
+
+	// Off by default
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64("first")})
+	eventually(t, "first prompt delivered", func() bool { return fs.last(t).stdinString() != "" })
+	_ = c.WriteJSON(map[string]any{"type": "listCheckpoints", "sessionId": "s1"})
+	if list := readType(t, c, "checkpointList")["checkpoints"].([]any); len(list) != 0 {
+		t.Fatalf("expected no checkpoints while disabled, got %v", list)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "setGitCheckpoints", "enabled": true})
+	readType(t, c, "gitCheckpoints")
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64("refactor main\nplease")})
+	cp := readType(t, c, "gitCheckpointCreated")["checkpoint"].(map[string]any)
+	if cp["message"] != "refactor main" {
+		t.Fatalf("unexpected checkpoint: %v", cp)
+	}
+
+	// The agent edits the file; roll it back
+	if err := os.WriteFile(file, []byte("package broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "restoreCheckpoint", "sessionId": "s1", "checkpointId": cp["id"]})
+	token := readType(t, c, "confirmationRequired")["token"]
+	_ = c.WriteJSON(map[string]any{"type": "confirm", "token": token})
+	restored := readType(t, c, "checkpointRestored")
+	if b, _ := os.ReadFile(file); string(b) != "package main\n" || restored["undoId"] == "" {
+		t.Fatalf("expected main.go restored, got %q (%v)", b, restored)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "listCheckpoints", "sessionId": "s1"})
+	if list := readType(t, c, "checkpointList")["checkpoints"].([]any); len(list) != 2 {
+		t.Fatalf("expected the checkpoint and its undo point, got %v", list)
+	}
+}
@@ -0,0 +1,230 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/rovobridge/internal/history"
+	"github.com/example/rovobridge/internal/session"
+	"github.com/gorilla/websocket"
+)
+
+// fakeSession is an in-process ptySession: tests script its stdout and inspect what the
+// Router wrote to stdin and how it resized and closed it.
+type fakeSession struct {
+	cfg    session.Config
+	outR   *io.PipeReader
+	outW   *io.PipeWriter
+	done   chan struct{}
+	finish sync.Once
+
+	mu      sync.Mutex
+	stdin   bytes.Buffer
+	resizes [][2]int
+	closed  bool
+	exitErr error
+}
+
+func newFakeSession(cfg session.Config) *fakeSession {
+	r, w := io.Pipe()
+	return &fakeSession{cfg: cfg, outR: r, outW: w, done: make(chan struct{})}
+}
+
+func (f *fakeSession) Stdin() io.Writer  { return fakeStdin{f} }
+func (f *fakeSession) Stdout() io.Reader { return f.outR }
+func (f *fakeSession) PID() int          { return 4242 }
+
+func (f *fakeSession) Resize(cols, rows int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resizes = append(f.resizes, [2]int{cols, rows})
+	return nil
+}
+
+func (f *fakeSession) Wait() error {
+	<-f.done
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.exitErr
+}
+
+func (f *fakeSession) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	f.exit(nil)
+	return nil
+}
+
+// emit writes scripted output as if the process printed it
+func (f *fakeSession) emit(s string) {
+	_, _ = f.outW.Write([]byte(s))
+}
+
+// exit ends the process with err (nil for a zero exit code)
+func (f *fakeSession) exit(err error) {
+	f.finish.Do(func() {
+		f.mu.Lock()
+		f.exitErr = err
+		f.mu.Unlock()
+		_ = f.outW.Close()
+		close(f.done)
+	})
+}
+
+func (f *fakeSession) stdinString() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stdin.String()
+}
+
+func (f *fakeSession) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+type fakeStdin struct{ f *fakeSession }
+
+func (w fakeStdin) Write(p []byte) (int, error) {
+	w.f.mu.Lock()
+	defer w.f.mu.Unlock()
+	return w.f.stdin.Write(p)
+}
+
+// fakeSessions records the sessions started by a Router under test
+type fakeSessions struct {
+	mu      sync.Mutex
+	started []*fakeSession
+}
+
+func (fs *fakeSessions) start(_ context.Context, cfg session.Config) (ptySession, error) {
+	f := newFakeSession(cfg)
+	fs.mu.Lock()
+	fs.started = append(fs.started, f)
+	fs.mu.Unlock()
+	return f, nil
+}
+
+func (fs *fakeSessions) last(t *testing.T) *fakeSession {
+	t.Helper()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if len(fs.started) == 0 {
+		t.Fatal("no session started")
+	}
+	return fs.started[len(fs.started)-1]
+}
+
+// newTestRouter returns a Router whose sessions are fakes, with history kept in memory and
+// drafts in a temporary home directory
+func newTestRouter(t *testing.T) (*Router, *fakeSessions) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+	r := NewRouter("")
+	r.SetHistoryManager(history.NewHistoryManagerWithStorage(history.NewMemoryStorage(), ""))
+	if r.indexer != nil {
+		r.indexer.Close()
+	}
+	fs := &fakeSessions{}
+	r.startSession = fs.start
+	return r, fs
+}
+
+// dialRouter connects a client to a test server whose messages are handled by r
+func dialRouter(t *testing.T, r *Router) (*websocket.Conn, func()) {
+	t.Helper()
+	return dialRouterFrom(t, r, "http://localhost")
+}
+
+// dialRouterFrom is dialRouter with the Origin header set to origin
+func dialRouterFrom(t *testing.T, r *Router, origin string) (*websocket.Conn, func()) {
+	t.Helper()
+	s := NewServer("tok")
+	r.Attach(s)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.HandleWS)
+	ts := httptest.NewServer(mux)
+	d := websocket.Dialer{Subprotocols: []string{"auth.bearer.tok"}}
+	h := http.Header{}
+	h.Set("Origin", origin)
+	c, _, err := d.Dial(wsURLFromHTTP(ts.URL, "/ws"), h)
+	if err != nil {
+		ts.Close()
+		t.Fatalf("dial: %v", err)
+	}
+	return c, func() { c.Close(); ts.Close() }
+}
+
+// confirmNext approves the next confirmationRequired of operation sent to c
+func confirmNext(t *testing.T, c *websocket.Conn, operation string) {
+	t.Helper()
+	challenge := readType(t, c, "confirmationRequired")
+	if challenge["operation"] != operation {
+		t.Fatalf("expected a confirmation of %s, got %v", operation, challenge)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "confirm", "token": challenge["token"], "approved": true})
+}
+
+// readType reads messages from c until one of the given type arrives
+func readType(t *testing.T, c *websocket.Conn, typ string) map[string]any {
+	t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var m map[string]any
+		if err := c.ReadJSON(&m); err != nil {
+			t.Fatalf("waiting for %q: %v", typ, err)
+		}
+		if m["type"] == typ {
+			return m
+		}
+	}
+}
+
+// readStdout collects stdout messages from c until want has been received
+func readStdout(t *testing.T, c *websocket.Conn, want string) {
+	t.Helper()
+	var got []byte
+	for !bytes.Contains(got, []byte(want)) {
+		msg := readType(t, c, "stdout")
+		data, err := base64.StdEncoding.DecodeString(msg["dataBase64"].(string))
+		if err != nil {
+			t.Fatalf("bad stdout payload: %v", err)
+		}
+		got = append(got, data...)
+	}
+}
+
+// eventually polls cond until it holds or the deadline passes
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// sessionIDOf returns the id of the session opened under alias
+func sessionIDOf(t *testing.T, r *Router, alias string) string {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for sid, a := range r.aliases {
+		if a == alias {
+			return sid
+		}
+	}
+	t.Fatalf("no session opened as %q", alias)
+	return ""
+}
@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/example/rovobridge/internal/history"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultOpenHistoryEntries is the prompt history sent with opened unless openSession
+	// asks for another historyLimit; the client pages the rest with queryHistory
+	defaultOpenHistoryEntries = 50
+	// maxOpenHistoryBytes bounds the prompt text sent with opened, so that a few huge
+	// prompts do not hold up the first output
+	maxOpenHistoryBytes = 64 << 10
+	// defaultHistoryPage and maxHistoryPage are the entries of a queryHistory answer
+	defaultHistoryPage = 100
+	maxHistoryPage     = 500
+)
+
+// recentHistory returns the newest entries, at most limit of them and up to
+// maxOpenHistoryBytes of prompt text though always at least one, and the offset of the
+// first of them in entries
+func recentHistory(entries []history.PromptHistoryEntry, limit int) ([]history.PromptHistoryEntry, int) {
+	if limit <= 0 {
+		limit = defaultOpenHistoryEntries
+	}
+	start, size := len(entries), 0
+	for start > 0 && len(entries)-start < limit {
+		size += len(entries[start-1].SerializedContent)
+		if size > maxOpenHistoryBytes && start < len(entries) {
+			break
+		}
+		start--
+	}
+	return entries[start:], start
+}
+
+// openedHistory is the prompt history sent with opened: the newest entries, the total and
+// the offset of the first entry, from which the client pages back with queryHistory
+type openedHistory struct {
+	entries []history.PromptHistoryEntry
+	total   int
+	offset  int
+}
+
+// openedPromptHistory loads the prompt history of session sid, plus the workspace prompt
+// library, for an opened message with up to limit entries
+func (r *Router) openedPromptHistory(ctx context.Context, sid string, limit int) openedHistory {
+	all := r.loadPromptHistory(ctx, r.sessionWorkingDir(sid))
+	recent, offset := recentHistory(all, limit)
+	return openedHistory{entries: recent, total: len(all), offset: offset}
+}
+
+// addTo sets the prompt history fields of an opened message
+func (h openedHistory) addTo(opened map[string]any) {
+	opened["promptHistory"] = h.entries
+	opened["promptHistoryTotal"] = h.total
+	opened["promptHistoryOffset"] = h.offset
+}
+
+// queryHistory answers queryHistory with historyPage, the entries of the prompt history
+// from offset on. Offsets count from the oldest entry, as in opened.
+func (r *Router) queryHistory(ctx context.Context, conn *websocket.Conn, sid string, offset, limit int) error {
+	if limit <= 0 {
+		limit = defaultHistoryPage
+	}
+	limit = min(limit, maxHistoryPage)
+	all := r.loadPromptHistory(ctx, r.sessionWorkingDir(sid))
+	offset = min(max(offset, 0), len(all))
+	end := min(offset+limit, len(all))
+	return SendJSON(conn, map[string]any{
+		"type":    "historyPage",
+		"offset":  offset,
+		"total":   len(all),
+		"entries": all[offset:end],
+	})
+}
@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/example/rovobridge/internal/history"
+)
+
+func TestRouter_OpenedSendsRecentHistoryAndPagesTheRest(t *testing.T) {
+	r, _ := newTestRouter(t)
+	entries := make([]history.PromptHistoryEntry, 120)
+	for i := range entries {
+		entries[i] = history.PromptHistoryEntry{ID: fmt.Sprintf("p%d", i), Timestamp: int64(i + 1), SerializedContent: "prompt"}
+	}
+	r.SetHistoryManager(history.NewHistoryManagerWithStorage(history.NewMemoryStorage(entries...), ""))
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "historyLimit": 20})
+	opened := readType(t, c, "opened")
+	recent := opened["promptHistory"].([]any)
+	if len(recent) != 20 || opened["promptHistoryTotal"] != float64(120) || opened["promptHistoryOffset"] != float64(100) {
+		t.Fatalf("unexpected history in opened: %d entries, total %v, offset %v", len(recent), opened["promptHistoryTotal"], opened["promptHistoryOffset"])
+	}
+	if recent[19].(map[string]any)["id"] != "p119" {
+		t.Fatalf("expected the newest entries, got %v", recent[19])
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "queryHistory", "offset": 90, "limit": 10})
+	page := readType(t, c, "historyPage")
+	got := page["entries"].([]any)
+	if len(got) != 10 || page["offset"] != float64(90) || page["total"] != float64(120) || got[0].(map[string]any)["id"] != "p90" {
+		t.Fatalf("unexpected page: %v", page)
+	}
+}
+
+func TestRecentHistory_BoundsPromptBytes(t *testing.T) {
+	big := strings.Repeat("x", maxOpenHistoryBytes/2+1)
+	entries := []history.PromptHistoryEntry{{ID: "a", SerializedContent: big}, {ID: "b", SerializedContent: big}, {ID: "c", SerializedContent: "small"}}
+	recent, offset := recentHistory(entries, 10)
+	if len(recent) != 2 || offset != 1 {
+		t.Fatalf("got %d entries from offset %d", len(recent), offset)
+	}
+	// A single entry over the budget is still sent
+	recent, _ = recentHistory([]history.PromptHistoryEntry{{ID: "huge", SerializedContent: big + big}}, 10)
+	if len(recent) != 1 {
+		t.Fatalf("expected the newest entry, got %d", len(recent))
+	}
+}
@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+
+	"github.com/example/rovobridge/internal/history"
+	"github.com/example/rovobridge/internal/index"
+	"github.com/gorilla/websocket"
+)
+
+// maxPendingRenames bounds the moves kept for rewriteHistoryPaths; the oldest are dropped
+const maxPendingRenames = 100
+
+// SetHistoryRenameRewrite makes moves seen by the index watches rewrite the prompt
+// history right away, instead of waiting for rewriteHistoryPaths
+func (r *Router) SetHistoryRenameRewrite(on bool) {
+	r.mu.Lock()
+	r.autoRewriteHistory = on
+	r.mu.Unlock()
+}
+
+// indexRoot returns the absolute root of the index, or "" without one
+func (r *Router) indexRoot() string {
+	if r.indexer == nil {
+		return ""
+	}
+	root, err := filepath.Abs(r.indexer.Root)
+	if err != nil {
+		return ""
+	}
+	return root
+}
+
+// onIndexRename takes a move seen by the index watches: it rewrites the history at once in
+// automatic mode, and otherwise keeps it for rewriteHistoryPaths and tells every client
+// with filesRenamed. It runs on a timer of the indexer and must not block it.
+func (r *Router) onIndexRename(rn index.Rename) {
+	root := r.indexRoot()
+	if root == "" {
+		return
+	}
+	pr := history.PathRename{From: filepath.Join(root, rn.From), To: filepath.Join(root, rn.To)}
+	r.mu.Lock()
+	auto := r.autoRewriteHistory
+	if !auto {
+		r.pendingRenames = append(r.pendingRenames, pr)
+		if n := len(r.pendingRenames) - maxPendingRenames; n > 0 {
+			r.pendingRenames = append([]history.PathRename(nil), r.pendingRenames[n:]...)
+		}
+	}
+	s := r.server
+	r.mu.Unlock()
+	if s == nil {
+		return
+	}
+	go func() {
+		var msg map[string]any
+		if auto {
+			changed, err := r.rewriteHistory(context.Background(), []history.PathRename{pr}, false)
+			if err != nil {
+				log.Printf("Failed to rewrite history paths after %s moved: %v", rn.From, err)
+				return
+			}
+			if len(changed) == 0 {
+				return
+			}
+			msg = historyRewriteMessage([]history.PathRename{pr}, changed, false)
+		} else {
+			msg = map[string]any{"type": "filesRenamed", "renames": []history.PathRename{pr}}
+		}
+		for _, c := range s.connections() {
+			_ = SendJSON(c, msg)
+		}
+	}()
+}
+
+// rewriteHistory applies renames to the chips of the prompt history
+func (r *Router) rewriteHistory(ctx context.Context, renames []history.PathRename, dryRun bool) ([]history.PromptHistoryEntry, error) {
+	return withTimeout(ctx, r.getTimeouts().History, "history path rewrite", func(context.Context) ([]history.PromptHistoryEntry, error) {
+		return r.historyManager.RewritePaths(renames, dryRun)
+	})
+}
+
+// rewriteHistoryPaths answers rewriteHistoryPaths. Renames given as paths relative to the
+// index root are made absolute; without any, the moves seen since the last rewrite apply.
+func (r *Router) rewriteHistoryPaths(ctx context.Context, conn *websocket.Conn, given []history.PathRename, dryRun bool) error {
+	renames := given
+	if len(given) == 0 {
+		r.mu.Lock()
+		renames = append([]history.PathRename{}, r.pendingRenames...)
+		r.mu.Unlock()
+	}
+	root := r.indexRoot()
+	for i, rn := range renames {
+		if rn.From == "" || rn.To == "" {
+			Errorf(conn, "rewriteHistoryPaths: renames need from and to")
+			return nil
+		}
+		if root != "" && !filepath.IsAbs(rn.From) {
+			renames[i].From = filepath.Join(root, rn.From)
+		}
+		if root != "" && !filepath.IsAbs(rn.To) {
+			renames[i].To = filepath.Join(root, rn.To)
+		}
+	}
+	changed, err := r.rewriteHistory(ctx, renames, dryRun)
+	if err != nil {
+		if errors.Is(err, errTimeout) {
+			replyTimeout(conn, "rewriteHistoryPaths", err)
+		} else {
+			Errorf(conn, "rewriteHistoryPaths: %v", err)
+		}
+		return nil
+	}
+	if len(given) == 0 && !dryRun {
+		r.mu.Lock()
+		r.pendingRenames = r.pendingRenames[min(len(renames), len(r.pendingRenames)):]
+		r.mu.Unlock()
+	}
+	return SendJSON(conn, historyRewriteMessage(renames, changed, dryRun))
+}
+
+// historyRewriteMessage is the historyPathsRewritten message for changed entries
+func historyRewriteMessage(renames []history.PathRename, changed []history.PromptHistoryEntry, dryRun bool) map[string]any {
+	entries := make([]map[string]any, 0, len(changed))
+	for _, e := range changed {
+		entries = append(entries, map[string]any{"id": e.ID, "serializedContent": e.SerializedContent})
+	}
+	return map[string]any{"type": "historyPathsRewritten", "renames": renames, "entries": entries, "dryRun": dryRun}
+}
@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/example/rovobridge/internal/history"
+	"github.com/example/rovobridge/internal/index"
+)
+
+func TestRouter_RewriteHistoryPaths(t *testing.T) {
+	r, _ := newTestRouter(t)
+	root := r.indexRoot()
+	r.SetHistoryManager(history.NewHistoryManagerWithStorage(history.NewMemoryStorage(
+		history.PromptHistoryEntry{ID: "a", SerializedContent: "fix <[#old.go][old.go]>", ProjectCwd: root},
+		history.PromptHistoryEntry{ID: "b", SerializedContent: "fix <[#other.go]>", ProjectCwd: root},
+	), ""))
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	// A move seen by the index is announced and kept for the next rewrite
+	r.onIndexRename(index.Rename{From: "old.go", To: filepath.Join("sub", "new.go")})
+	msg := readType(t, c, "filesRenamed")
+	if renames, _ := msg["renames"].([]any); len(renames) != 1 {
+		t.Fatalf("unexpected filesRenamed: %v", msg)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "rewriteHistoryPaths", "dryRun": true})
+	msg = readType(t, c, "historyPathsRewritten")
+	if entries, _ := msg["entries"].([]any); len(entries) != 1 || msg["dryRun"] != true {
+		t.Fatalf("unexpected dry run: %v", msg)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "rewriteHistoryPaths"})
+	msg = readType(t, c, "historyPathsRewritten")
+	entries, _ := msg["entries"].([]any)
+	if len(entries) != 1 {
+		t.Fatalf("unexpected rewrite: %v", msg)
+	}
+	if e, _ := entries[0].(map[string]any); e["id"] != "a" || e["serializedContent"] != "fix <[#sub/new.go][new.go]>" {
+		t.Fatalf("unexpected rewritten entry: %v", e)
+	}
+
+	// The pending moves were consumed
+	_ = c.WriteJSON(map[string]any{"type": "rewriteHistoryPaths"})
+	msg = readType(t, c, "historyPathsRewritten")
+	if renames, _ := msg["renames"].([]any); len(renames) != 0 {
+		t.Fatalf("pending renames not cleared: %v", msg)
+	}
+
+	// Explicit renames may be relative to the index root
+	_ = c.WriteJSON(map[string]any{"type": "rewriteHistoryPaths", "renames": []any{map[string]any{"from": "other.go", "to": "lib/other.go"}}})
+	msg = readType(t, c, "historyPathsRewritten")
+	if entries, _ := msg["entries"].([]any); len(entries) != 1 {
+		t.Fatalf("unexpected explicit rewrite: %v", msg)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "rewriteHistoryPaths", "renames": []any{map[string]any{"from": "x.go"}}})
+	readType(t, c, "error")
+}
+
+func TestRouter_HistoryRenameRewriteAutomatic(t *testing.T) {
+	r, _ := newTestRouter(t)
+	root := r.indexRoot()
+	r.SetHistoryManager(history.NewHistoryManagerWithStorage(history.NewMemoryStorage(
+		history.PromptHistoryEntry{ID: "a", SerializedContent: "<[#lib/a.go]>", ProjectCwd: root},
+	), ""))
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "setHistoryRenameRewrite", "enabled": true})
+	if msg := readType(t, c, "historyRenameRewrite"); msg["enabled"] != true {
+		t.Fatalf("unexpected reply: %v", msg)
+	}
+	r.onIndexRename(index.Rename{From: "lib", To: "pkg", IsDir: true})
+	msg := readType(t, c, "historyPathsRewritten")
+	entries, _ := msg["entries"].([]any)
+	if len(entries) != 1 {
+		t.Fatalf("unexpected rewrite: %v", msg)
+	}
+	if e, _ := entries[0].(map[string]any); e["serializedContent"] != "<[#pkg/a.go]>" {
+		t.Fatalf("unexpected rewritten entry: %v", e)
+	}
+	stored, _ := r.historyManager.LoadHistory()
+	if stored[0].SerializedContent != "<[#pkg/a.go]>" {
+		t.Fatalf("rewrite not stored: %+v", stored)
+	}
+}
@@ -0,0 +1,47 @@
+package ws
+
+import (
+	"github.com/example/rovobridge/internal/index"
+	"github.com/gorilla/websocket"
+)
+
+// indexStatusMessage is the indexStatus message reporting st
+func indexStatusMessage(st index.Status) map[string]any {
+	msg := map[string]any{"type": "indexStatus", "state": st.State, "root": st.Root, "since": st.Since.UnixMilli()}
+	if st.Message != "" {
+		msg["message"] = st.Message
+	}
+	if st.Attempt > 0 {
+		msg["attempt"] = st.Attempt
+	}
+	if st.RetryIn > 0 {
+		msg["retryInMs"] = st.RetryIn
+	}
+	return msg
+}
+
+// broadcastIndexStatus tells every connected client that the index root went missing or
+// came back; it runs on the indexer's root watch goroutine and only queues the messages
+func (r *Router) broadcastIndexStatus(st index.Status) {
+	r.mu.Lock()
+	s := r.server
+	r.mu.Unlock()
+	if s == nil {
+		return
+	}
+	msg := indexStatusMessage(st)
+	for _, c := range s.connections() {
+		_ = SendJSON(c, msg)
+	}
+}
+
+// connections returns the open connections
+func (s *Server) connections() []*websocket.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns := make([]*websocket.Conn, 0, len(s.writers))
+	for c := range s.writers {
+		conns = append(conns, c)
+	}
+	return conns
+}
@@ -0,0 +1,29 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/rovobridge/internal/index"
+)
+
+func TestRouter_IndexStatus(t *testing.T) {
+	r, _ := newTestRouter(t)
+	root := t.TempDir()
+	r.indexer = index.New(root)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "getIndexStatus"})
+	msg := readType(t, c, "indexStatus")
+	if msg["state"] != index.StatusOK || msg["root"] != root {
+		t.Fatalf("unexpected status: %v", msg)
+	}
+
+	// Changes reported by the indexer reach connected clients unasked
+	r.broadcastIndexStatus(index.Status{State: index.StatusMissing, Root: root, Message: "gone", Attempt: 2, RetryIn: 8000, Since: time.Now()})
+	msg = readType(t, c, "indexStatus")
+	if msg["state"] != index.StatusMissing || msg["attempt"] != float64(2) || msg["retryInMs"] != float64(8000) {
+		t.Fatalf("unexpected broadcast: %v", msg)
+	}
+}
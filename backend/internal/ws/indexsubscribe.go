@@ -0,0 +1,173 @@
+package ws
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/example/rovobridge/internal/index"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultIndexSubscribeLimit and maxIndexSubscribeLimit bound the entries subscribeIndex
+	// sends; a larger project is better served by searchIndex
+	defaultIndexSubscribeLimit = 20000
+	maxIndexSubscribeLimit     = 200000
+)
+
+// indexSubscription is a client following the index: the entries under prefix, and the
+// generation of the snapshot it was sent, whose changes it already has
+type indexSubscription struct {
+	prefix string
+	gen    uint64
+}
+
+// cleanIndexPrefix turns the directory a subscription asks for into an index path: relative
+// to the root, with OS separators and no trailing one; "" for the whole index
+func cleanIndexPrefix(p string) string {
+	p = filepath.Clean(filepath.FromSlash(p))
+	if p == "." || p == string(filepath.Separator) {
+		return ""
+	}
+	return strings.TrimPrefix(p, "."+string(filepath.Separator))
+}
+
+// underPrefix reports whether an index path is the prefix directory or beneath it
+func underPrefix(p, prefix string) bool {
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+string(filepath.Separator))
+}
+
+// subscribeIndex sends the entries under prefix and registers the connection for the
+// changes that follow, pushed with indexDelta. Subscribing again replaces the filter.
+func (r *Router) subscribeIndex(conn *websocket.Conn, prefix string, limit int) error {
+	if r.indexer == nil {
+		Errorf(conn, "subscribeIndex: no index")
+		return nil
+	}
+	prefix = cleanIndexPrefix(prefix)
+	if prefix == ".." || strings.HasPrefix(prefix, ".."+string(filepath.Separator)) || filepath.IsAbs(prefix) {
+		Errorf(conn, "subscribeIndex: prefix %q is outside the index root", prefix)
+		return nil
+	}
+	if limit <= 0 {
+		limit = defaultIndexSubscribeLimit
+	}
+	limit = min(limit, maxIndexSubscribeLimit)
+	r.indexer.RequestRefresh()
+
+	// The snapshot is taken and sent under indexSubsMu, which onIndexChange holds too, so
+	// no delta is lost between the two or sent ahead of the entries
+	r.indexSubsMu.Lock()
+	defer r.indexSubsMu.Unlock()
+	snap := r.indexer.Snapshot()
+	entries := []index.ExportEntry{}
+	for _, e := range snap.Entries {
+		if !underPrefix(e.Path, prefix) {
+			continue
+		}
+		if len(entries) == limit {
+			Errorf(conn, "subscribeIndex: more than %d entries; use searchIndex for a project this large", limit)
+			return nil
+		}
+		entries = append(entries, index.ExportEntry{Path: e.Path, Name: e.Name, Short: e.Short, IsDir: e.IsDir})
+	}
+	r.indexSubs[conn] = indexSubscription{prefix: prefix, gen: snap.Gen}
+	return SendJSON(conn, map[string]any{
+		"type":    "indexSubscribed",
+		"root":    r.indexer.Root,
+		"prefix":  filepath.ToSlash(prefix),
+		"count":   len(entries),
+		"entries": entries,
+	})
+}
+
+// unsubscribeIndex stops the index changes of a connection
+func (r *Router) unsubscribeIndex(conn *websocket.Conn) error {
+	r.indexSubsMu.Lock()
+	_, ok := r.indexSubs[conn]
+	delete(r.indexSubs, conn)
+	r.indexSubsMu.Unlock()
+	if !ok {
+		Errorf(conn, "unsubscribeIndex: not subscribed")
+		return nil
+	}
+	return SendJSON(conn, map[string]any{"type": "indexUnsubscribed"})
+}
+
+// dropIndexSubscription forgets the subscription of a closing connection
+func (r *Router) dropIndexSubscription(conn *websocket.Conn) {
+	r.indexSubsMu.Lock()
+	delete(r.indexSubs, conn)
+	r.indexSubsMu.Unlock()
+}
+
+// onIndexChange pushes a change of the index to the subscribed clients, each limited to
+// its prefix. It runs while the indexer publishes and only queues the messages.
+func (r *Router) onIndexChange(d index.Delta) {
+	r.indexSubsMu.Lock()
+	defer r.indexSubsMu.Unlock()
+	for conn, sub := range r.indexSubs {
+		if d.Gen <= sub.gen {
+			continue
+		}
+		sub.gen = d.Gen
+		r.indexSubs[conn] = sub
+		if f := deltaUnder(d, sub.prefix); !f.Empty() {
+			_ = SendJSON(conn, indexDeltaMessage(f))
+		}
+	}
+}
+
+// indexDeltaMessage is the indexDelta of d, without its empty lists
+func indexDeltaMessage(d index.Delta) map[string]any {
+	msg := map[string]any{"type": "indexDelta"}
+	if len(d.Added) > 0 {
+		msg["added"] = d.Added
+	}
+	if len(d.Removed) > 0 {
+		msg["removed"] = d.Removed
+	}
+	if len(d.Renamed) > 0 {
+		msg["renamed"] = d.Renamed
+	}
+	if len(d.Updated) > 0 {
+		msg["updated"] = d.Updated
+	}
+	return msg
+}
+
+// deltaUnder limits a delta to the entries under prefix: a move across its border becomes
+// an addition or a removal
+func deltaUnder(d index.Delta, prefix string) index.Delta {
+	if prefix == "" {
+		return d
+	}
+	var out index.Delta
+	for _, e := range d.Added {
+		if underPrefix(e.Path, prefix) {
+			out.Added = append(out.Added, e)
+		}
+	}
+	for _, p := range d.Removed {
+		if underPrefix(p, prefix) {
+			out.Removed = append(out.Removed, p)
+		}
+	}
+	for _, rn := range d.Renamed {
+		from, to := underPrefix(rn.From, prefix), underPrefix(rn.Path, prefix)
+		switch {
+		case from && to:
+			out.Renamed = append(out.Renamed, rn)
+		case from:
+			out.Removed = append(out.Removed, rn.From)
+		case to:
+			out.Added = append(out.Added, rn.ExportEntry)
+		}
+	}
+	for _, e := range d.Updated {
+		if underPrefix(e.Path, prefix) {
+			out.Updated = append(out.Updated, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package ws
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/example/rovobridge/internal/index"
+)
+
+func TestRouter_SubscribeIndexPushesDeltas(t *testing.T) {
+	r, _ := newTestRouter(t)
+	r.indexer = index.NewFromFS("/proj", fstest.MapFS{
+		"main.go":        {},
+		"src/app.ts":     {},
+		"src/lib/x.ts":   {},
+		"docs/readme.md": {},
+	})
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "subscribeIndex", "prefix": "./src/"})
+	msg := readType(t, c, "indexSubscribed")
+	if msg["prefix"] != "src" || msg["count"] != float64(4) {
+		t.Fatalf("unexpected subscription: %v", msg)
+	}
+	for _, e := range msg["entries"].([]any) {
+		if p := e.(map[string]any)["path"].(string); !strings.HasPrefix(filepath.ToSlash(p), "src") {
+			t.Fatalf("entry outside the prefix: %v", p)
+		}
+	}
+
+	// A delta the subscription's snapshot already holds is not sent again
+	gen := r.indexer.Snapshot().Gen
+	r.onIndexChange(index.Delta{Gen: gen, Added: []index.ExportEntry{{Path: filepath.FromSlash("src/stale.ts"), Name: "stale.ts"}}})
+	// Moves across the prefix become additions and removals
+	r.onIndexChange(index.Delta{
+		Gen:     gen + 1,
+		Added:   []index.ExportEntry{{Path: filepath.FromSlash("src/new.ts"), Name: "new.ts", Short: "new.ts"}, {Path: "other.go", Name: "other.go"}},
+		Removed: []string{"main.go"},
+		Renamed: []index.RenamedEntry{
+			{From: filepath.FromSlash("src/lib/x.ts"), ExportEntry: index.ExportEntry{Path: filepath.FromSlash("src/lib/y.ts"), Name: "y.ts"}},
+			{From: filepath.FromSlash("src/app.ts"), ExportEntry: index.ExportEntry{Path: "app.ts", Name: "app.ts"}},
+			{From: filepath.FromSlash("docs/readme.md"), ExportEntry: index.ExportEntry{Path: filepath.FromSlash("src/readme.md"), Name: "readme.md"}},
+		},
+	})
+	msg = readType(t, c, "indexDelta")
+	added, _ := msg["added"].([]any)
+	removed, _ := msg["removed"].([]any)
+	renamed, _ := msg["renamed"].([]any)
+	if len(added) != 2 || len(removed) != 1 || len(renamed) != 1 {
+		t.Fatalf("unexpected delta: %v", msg)
+	}
+	if strings.Contains(filepath.ToSlash(added[0].(map[string]any)["path"].(string)), "stale") ||
+		removed[0] != filepath.FromSlash("src/app.ts") || renamed[0].(map[string]any)["from"] != filepath.FromSlash("src/lib/x.ts") {
+		t.Fatalf("unexpected delta: %v", msg)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "unsubscribeIndex"})
+	readType(t, c, "indexUnsubscribed")
+	r.indexSubsMu.Lock()
+	n := len(r.indexSubs)
+	r.indexSubsMu.Unlock()
+	if n != 0 {
+		t.Fatal("subscription kept after unsubscribeIndex")
+	}
+}
+
+func TestRouter_SubscribeIndexLimit(t *testing.T) {
+	r, _ := newTestRouter(t)
+	r.indexer = index.NewFromFS("/proj", fstest.MapFS{"a.go": {}, "b.go": {}, "c.go": {}})
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "subscribeIndex", "limit": 2})
+	if msg := readType(t, c, "error"); !strings.Contains(msg["message"].(string), "searchIndex") {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "subscribeIndex", "prefix": "../up"})
+	if msg := readType(t, c, "error"); !strings.Contains(msg["message"].(string), "outside") {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+}
@@ -0,0 +1,10 @@
+package ws
+
+import "github.com/example/rovobridge/internal/janitor"
+
+// SetJanitor reports the sweeps of j in stats; nil leaves them out
+func (r *Router) SetJanitor(j *janitor.Janitor) {
+	r.mu.Lock()
+	r.janitor = j
+	r.mu.Unlock()
+}
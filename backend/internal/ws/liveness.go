@@ -0,0 +1,183 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	// stallProbeWait is how long a probed session has to print something before it is
+	// reported stalled, or stallAfterMs when that is shorter
+	stallProbeWait = 5 * time.Second
+
+	probeWinch = "winch" // resize the terminal by a row and back; a live TUI redraws
+	probeWrite = "write" // a zero-length write to stdin, which fails once the PTY is gone
+	probeNone  = "none"  // no probe: silence alone counts
+)
+
+// livenessState watches a busy session for a hung agent. A session is busy from the
+// submission of a prompt until its output matches readyPattern, it answers a probe, or
+// it exits. A busy session silent for after is probed, and reported with sessionStalled
+// when the probe draws no output within stallProbeWait. An agent idling at its prompt
+// redraws when its terminal is resized, which is what the winch probe relies on.
+// Sessions waiting on a permission prompt are not probed.
+type livenessState struct {
+	after   time.Duration // 0 turns the check off
+	probe   string
+	marker  *regexp.Regexp
+	busy    bool
+	stalled bool
+	probed  time.Time // when the probe of the current silence was sent
+	last    time.Time // last output, or the prompt submission
+	cols    int
+	rows    int
+	timer   *time.Timer
+}
+
+// livenessOptions reads the liveness options of openSession:
+// { stallAfterMs?: number, stallProbe?: "winch" | "write" | "none" }
+func livenessOptions(m map[string]any, marker *regexp.Regexp, cols, rows int) (livenessState, error) {
+	l := livenessState{probe: probeWinch, marker: marker, cols: cols, rows: rows}
+	if ms := asInt(m["stallAfterMs"]); ms > 0 {
+		l.after = time.Duration(ms) * time.Millisecond
+	}
+	if p, ok := m["stallProbe"].(string); ok && p != "" {
+		switch p {
+		case probeWinch, probeWrite, probeNone:
+			l.probe = p
+		default:
+			return l, fmt.Errorf("unknown stallProbe %q", p)
+		}
+	}
+	return l, nil
+}
+
+// stopUnsafe ends the watch, e.g. when the process exits or is replaced
+func (l *livenessState) stopUnsafe() {
+	l.busy = false
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// armLivenessUnsafe schedules the next check of the process owning ctx. Caller must hold st.mu.
+func (r *Router) armLivenessUnsafe(ctx context.Context, sid string, st *sessionState, d time.Duration) {
+	if st.liveness.timer != nil {
+		st.liveness.timer.Stop()
+	}
+	st.liveness.timer = time.AfterFunc(d, func() { r.checkLiveness(ctx, sid, st) })
+}
+
+// livenessBusy marks the session busy as a prompt is submitted
+func (r *Router) livenessBusy(sid string, st *sessionState) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	l := &st.liveness
+	if l.after <= 0 || st.ctx == nil {
+		return
+	}
+	l.busy, l.probed, l.last = true, time.Time{}, time.Now()
+	r.armLivenessUnsafe(st.ctx, sid, st, l.after)
+}
+
+// livenessOutputUnsafe takes the output of a busy session: it ends the busy period when a
+// line matches readyPattern or the output answers a probe, and otherwise restarts the
+// wait. It returns the sessionStalled message ending a stall, if any. Caller must hold
+// st.mu.
+func (r *Router) livenessOutputUnsafe(ctx context.Context, sid string, st *sessionState, lines []plainLine) map[string]any {
+	l := &st.liveness
+	var msg map[string]any
+	if l.stalled {
+		l.stalled = false
+		msg = map[string]any{"type": "sessionStalled", "sessionId": sid, "stalled": false}
+	}
+	if !l.busy {
+		return msg
+	}
+	done := !l.probed.IsZero()
+	if l.marker != nil {
+		done = done || l.marker.MatchString(st.mirror.pending())
+		for _, ln := range lines {
+			done = done || l.marker.MatchString(ln.text)
+		}
+	}
+	if done {
+		l.stopUnsafe()
+		return msg
+	}
+	l.last = time.Now()
+	r.armLivenessUnsafe(ctx, sid, st, l.after)
+	return msg
+}
+
+// checkLiveness runs when a busy session has been silent: the first time it probes the
+// process, the second time it reports the session stalled
+func (r *Router) checkLiveness(ctx context.Context, sid string, st *sessionState) {
+	st.mu.Lock()
+	l := &st.liveness
+	if st.staleUnsafe(ctx) || !l.busy || l.stalled {
+		st.mu.Unlock()
+		return
+	}
+	if st.permission != nil {
+		// waiting on the user, not hung
+		r.armLivenessUnsafe(ctx, sid, st, l.after)
+		st.mu.Unlock()
+		return
+	}
+	if l.probed.IsZero() && l.probe != probeNone {
+		l.probed = time.Now()
+		probe, cols, rows := l.probe, l.cols, l.rows
+		r.armLivenessUnsafe(ctx, sid, st, min(stallProbeWait, l.after))
+		st.mu.Unlock()
+		if err := r.probeSession(sid, probe, cols, rows); err != nil {
+			r.reportStall(ctx, sid, st, fmt.Sprintf("probe failed: %v", err))
+		}
+		return
+	}
+	st.mu.Unlock()
+	r.reportStall(ctx, sid, st, "")
+}
+
+// probeSession sends the probe to the process of session sid
+func (r *Router) probeSession(sid, probe string, cols, rows int) error {
+	r.mu.Lock()
+	sess := r.sessions[sid]
+	r.mu.Unlock()
+	if sess == nil {
+		return nil
+	}
+	if probe == probeWinch && cols > 0 && rows > 1 {
+		if err := sess.Resize(cols, rows-1); err != nil {
+			return err
+		}
+		return sess.Resize(cols, rows)
+	}
+	_, err := sess.Stdin().Write(nil)
+	return err
+}
+
+// reportStall marks a busy session stalled and tells its client
+func (r *Router) reportStall(ctx context.Context, sid string, st *sessionState, reason string) {
+	st.mu.Lock()
+	l := &st.liveness
+	if st.staleUnsafe(ctx) || !l.busy || l.stalled {
+		st.mu.Unlock()
+		return
+	}
+	l.stalled = true
+	silent := time.Since(l.last)
+	msg := map[string]any{"type": "sessionStalled", "sessionId": sid, "stalled": true, "silentMs": silent.Milliseconds(), "probe": l.probe}
+	if reason != "" {
+		msg["reason"] = reason
+	}
+	c := st.currentConn
+	st.mu.Unlock()
+	r.logSessionEvent(sid, "stalled", map[string]any{"silentMs": silent.Milliseconds(), "probe": msg["probe"], "reason": reason})
+	if c != nil {
+		_ = SendJSON(c, msg)
+	}
+}
@@ -0,0 +1,57 @@
+package ws
+
+import "testing"
+
+func TestRouter_SessionStalledAfterUnansweredProbe(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cols": 80, "rows": 24, "stallAfterMs": 50})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("refactor it\r"), "historyEntry": map[string]any{"id": "p1", "serializedContent": "refactor it"}})
+	msg := readType(t, c, "sessionStalled")
+	if msg["stalled"] != true || msg["probe"] != "winch" {
+		t.Fatalf("unexpected sessionStalled: %v", msg)
+	}
+	f.mu.Lock()
+	resizes := append([][2]int(nil), f.resizes...)
+	f.mu.Unlock()
+	if len(resizes) != 3 || resizes[1] != [2]int{80, 23} || resizes[2] != [2]int{80, 24} {
+		t.Fatalf("expected a resize wiggle, got %v", resizes)
+	}
+
+	f.emit("still here\r\n")
+	if msg := readType(t, c, "sessionStalled"); msg["stalled"] != false {
+		t.Fatalf("expected the stall to end, got %v", msg)
+	}
+}
+
+func TestRouter_ReadyPatternEndsBusy(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "stallAfterMs": 100, "stallProbe": "none", "readyPattern": `^>$`})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("hi\r"), "historyEntry": map[string]any{"id": "p1", "serializedContent": "hi"}})
+	sid := sessionIDOf(t, r, "s1")
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	busy := func() bool {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		return st.liveness.busy
+	}
+	eventually(t, "the prompt to make the session busy", busy)
+	f.emit("hello\r\n> ")
+	readStdout(t, c, "hello\r\n> ")
+	eventually(t, "the busy period to end", func() bool { return !busy() })
+
+	if _, err := livenessOptions(map[string]any{"stallProbe": "ping"}, nil, 0, 0); err == nil {
+		t.Fatal("expected an unknown probe to be rejected")
+	}
+}
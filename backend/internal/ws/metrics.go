@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// MetricsVersion is the schemaVersion of the /metrics document. Fields may be added
+// without changing it; it changes only when one is removed or changes meaning.
+const MetricsVersion = 1
+
+// SessionMetrics are the counters of one session for the /metrics endpoint
+type SessionMetrics struct {
+	ID            string         `json:"id"`
+	Alias         string         `json:"alias,omitempty" doc:"The id the client opened the session under"`
+	PID           int            `json:"pid,omitempty" doc:"Process of the session; absent once it exited"`
+	Attached      bool           `json:"attached" doc:"Whether a client is connected to the session"`
+	WorkingDir    string         `json:"workingDir,omitempty"`
+	OutputBytes   int64          `json:"outputBytes" doc:"Output of the current process streamed so far"`
+	InjectedFiles int            `json:"injectedFiles"`
+	Checkpoints   int            `json:"checkpoints"`
+	Notes         int            `json:"notes"`
+	LastOutput    *time.Time     `json:"lastOutput,omitempty" doc:"When the process last printed; absent before its first output"`
+	PromptLatency *PromptLatency `json:"promptLatency,omitempty" doc:"Time from submitting prompts to the first output after them"`
+}
+
+// Metrics is the /metrics document: counters of every session and of the connections
+type Metrics struct {
+	SchemaVersion      int              `json:"schemaVersion"`
+	Sessions           []SessionMetrics `json:"sessions" doc:"Ordered by session ID"`
+	StdinRejectedBytes int64            `json:"stdinRejectedBytes"`
+	PromptLatency      *PromptLatency   `json:"promptLatency,omitempty" doc:"Over the prompts of all sessions"`
+	Connections        *Stats           `json:"connections,omitempty"`
+}
+
+// Metrics returns a snapshot of the session and connection counters
+func (r *Router) Metrics() Metrics {
+	r.mu.Lock()
+	m := Metrics{SchemaVersion: MetricsVersion, Sessions: []SessionMetrics{}, StdinRejectedBytes: r.stdinRejected, PromptLatency: r.promptLatency.summary()}
+	s := r.server
+	states := make(map[string]*sessionState, len(r.sessionStates))
+	pids := make(map[string]int, len(r.sessions))
+	aliases := make(map[string]string, len(r.sessionStates))
+	for id, st := range r.sessionStates {
+		states[id] = st
+		aliases[id] = r.aliases[id]
+	}
+	for id, sess := range r.sessions {
+		pids[id] = sess.PID()
+	}
+	r.mu.Unlock()
+
+	for id, st := range states {
+		st.mu.Lock()
+		sm := SessionMetrics{
+			ID:            id,
+			Alias:         aliases[id],
+			PID:           pids[id],
+			Attached:      st.currentConn != nil,
+			WorkingDir:    st.workingDir,
+			OutputBytes:   st.sentBytes,
+			InjectedFiles: len(st.injectedPaths),
+			Checkpoints:   len(st.checkpoints),
+			Notes:         len(st.notes),
+			PromptLatency: st.latency.summary(),
+		}
+		if !st.lastEnqueue.IsZero() {
+			last := st.lastEnqueue
+			sm.LastOutput = &last
+		}
+		st.mu.Unlock()
+		m.Sessions = append(m.Sessions, sm)
+	}
+	sort.Slice(m.Sessions, func(i, j int) bool { return m.Sessions[i].ID < m.Sessions[j].ID })
+	if s != nil {
+		st := s.Stats()
+		m.Connections = &st
+	}
+	return m
+}
+
+// WriteMetrics serves Metrics for the /metrics HTTP endpoint
+func (r *Router) WriteMetrics(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(r.Metrics())
+}
@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter_Metrics(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, done := dialRouter(t, r)
+	defer done()
+	dir := t.TempDir()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s2", "cwd": dir})
+	readType(t, c, "opened")
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	fs.last(t).emit("hello\r\n")
+	readStdout(t, c, "hello")
+
+	rec := httptest.NewRecorder()
+	r.WriteMetrics(rec)
+	var m Metrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.SchemaVersion != MetricsVersion || len(m.Sessions) != 2 || m.Connections == nil || m.Connections.Connections != 1 {
+		t.Fatalf("metrics = %+v", m)
+	}
+	s1, s2 := m.Sessions[0], m.Sessions[1]
+	if s1.Alias != "s1" {
+		s1, s2 = s2, s1
+	}
+	if s1.Alias != "s1" || s1.PID != 4242 || !s1.Attached || s1.OutputBytes != 7 || s1.LastOutput == nil {
+		t.Errorf("s1 = %+v", s1)
+	}
+	if s2.Alias != "s2" || s2.WorkingDir != dir || s2.OutputBytes != 0 || s2.LastOutput != nil {
+		t.Errorf("s2 = %+v", s2)
+	}
+}
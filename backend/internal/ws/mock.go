@@ -0,0 +1,317 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing/fstest"
+	"time"
+
+	"github.com/example/rovobridge/internal/history"
+	"github.com/example/rovobridge/internal/index"
+	"github.com/example/rovobridge/internal/notify"
+	"github.com/example/rovobridge/internal/session"
+	"github.com/gorilla/websocket"
+)
+
+// mockRoot is the directory the synthetic workspace of mock mode pretends to live in
+var mockRoot = filepath.FromSlash("/mock/project")
+
+// mockTime stamps every mock file and history entry, so mock data is the same on every run
+var mockTime = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+// mockSpinnerDelay paces the frames of the spinner command
+const mockSpinnerDelay = 80 * time.Millisecond
+
+func mockFile(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content), Mode: 0644, ModTime: mockTime}
+}
+
+// mockFiles is the synthetic workspace: a small Go service with a TypeScript frontend
+var mockFiles = fstest.MapFS{
+	"README.md": mockFile("# todo-service\n\nA small TODO API with a web frontend, served by rovo-bridge --mock.\n"),
+	"go.mod":    mockFile("module example.com/todo\n\ngo 1.22\n"),
+	"main.go": mockFile(`package main
+
+import (
+	"log"
+	"net/http"
+
+	"example.com/todo/internal/server"
+)
+
+func main() {
+	log.Fatal(http.ListenAndServe(":8080", server.New()))
+}
+`),
+	"internal/server/server.go": mockFile(`package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Todo is one item of the list
+type Todo struct {
+	ID    int    ` + "`json:\"id\"`" + `
+	Title string ` + "`json:\"title\"`" + `
+	Done  bool   ` + "`json:\"done\"`" + `
+}
+
+// Server serves the TODO API
+type Server struct {
+	mu    sync.Mutex
+	todos []Todo
+}
+
+// New returns an empty Server
+func New() *Server { return &Server{} }
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(s.todos)
+}
+`),
+	"internal/server/server_test.go": mockFile(`package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Body.String() != "[]\n" {
+		t.Fatalf("body = %q", rec.Body.String())
+	}
+}
+`),
+	"internal/store/store.go": mockFile("package store\n\n// Store persists todos\ntype Store interface {\n\tLoad() ([]byte, error)\n\tSave([]byte) error\n}\n"),
+	"web/package.json":        mockFile("{\n  \"name\": \"todo-web\",\n  \"scripts\": {\"dev\": \"vite\", \"test\": \"vitest\"}\n}\n"),
+	"web/src/App.tsx":         mockFile("import { TodoList } from './TodoList'\n\nexport function App() {\n  return <TodoList />\n}\n"),
+	"web/src/TodoList.tsx":    mockFile("export function TodoList() {\n  return <ul className=\"todos\" />\n}\n"),
+	"web/src/main.ts":         mockFile("import { App } from './App'\n\nconsole.log(App)\n"),
+	"docs/architecture.md":    mockFile("# Architecture\n\nThe API lives in internal/server, storage behind internal/store.\n"),
+}
+
+// mockHistory seeds the prompt history of mock mode
+var mockHistory = []history.PromptHistoryEntry{
+	{ID: "mock-1", Timestamp: mockTime.Add(-72 * time.Hour).UnixMilli(), SerializedContent: "Explain how the TODO server stores items", ProjectCwd: mockRoot},
+	{ID: "mock-2", Timestamp: mockTime.Add(-48 * time.Hour).UnixMilli(), SerializedContent: "Add a DELETE endpoint to internal/server/server.go", ProjectCwd: mockRoot,
+		ReferencedPaths: []string{"internal/server/server.go"}, Agent: "rovodev"},
+	{ID: "mock-3", Timestamp: mockTime.Add(-24 * time.Hour).UnixMilli(), SerializedContent: "Write a test for the empty list response", ProjectCwd: mockRoot,
+		ReferencedPaths: []string{"internal/server/server_test.go"}, Agent: "rovodev"},
+	{ID: "mock-4", Timestamp: mockTime.UnixMilli(), SerializedContent: "Render the todos in web/src/TodoList.tsx", ProjectCwd: mockRoot,
+		ReferencedPaths: []string{"web/src/TodoList.tsx"}, Agent: "rovodev"},
+}
+
+// mockUnsupported lists messages that would run git, tasks, the clipboard or other
+// tools, or read the real file system; mock mode answers them with mockUnsupported
+var mockUnsupported = map[string]bool{
+	"setClipHistory": true, "setGitCheckpoints": true, "listCheckpoints": true, "restoreCheckpoint": true,
+	"listTasks": true, "runTask": true, "cancelTask": true, "tailFile": true, "readFile": true, "saveFile": true, "diagnostics": true,
+	"checkUpdate": true, "updateUseClipboard": true, "createCheckpoint": true, "diffSinceCheckpoint": true,
+	"sessionDiff": true, "saveProjectPrompt": true, "removeProjectPrompt": true,
+}
+
+// mockWorkspace is the synthetic project mock mode serves instead of the working directory
+type mockWorkspace struct {
+	root string
+	fsys fs.FS
+	pids atomic.Int32
+}
+
+// readFile reads a mock file by its path, absolute or relative to the workspace root
+func (w *mockWorkspace) readFile(path string) ([]byte, error) {
+	rel := path
+	if filepath.IsAbs(path) {
+		r, err := filepath.Rel(w.root, path)
+		if err != nil || strings.HasPrefix(r, "..") {
+			return nil, fmt.Errorf("file not found: %s", path)
+		}
+		rel = r
+	}
+	data, err := fs.ReadFile(w.fsys, filepath.ToSlash(filepath.Clean(rel)))
+	if err != nil {
+		return nil, fmt.Errorf("file not found: %s", path)
+	}
+	return data, nil
+}
+
+// NewMockRouter returns a Router for frontend development. Sessions are scripted
+// in-process fakes (see mockSession), the index, context selection and injected files
+// come from a synthetic workspace, prompt history is kept in memory, seeded with sample
+// prompts, and drafts are kept in dataDir. No process is started and the working directory is not
+// read; messages that would need either are answered with a mockUnsupported error.
+func NewMockRouter(dataDir string) (*Router, error) {
+	r := newRouter("")
+	r.mock = &mockWorkspace{root: mockRoot, fsys: mockFiles}
+	r.indexer = index.NewFromFS(mockRoot, mockFiles)
+	r.historyManager = history.NewHistoryManagerWithStorage(history.NewMemoryStorage(mockHistory...), "")
+	r.drafts = history.NewDraftStoreAt(filepath.Join(dataDir, "drafts"))
+	r.notifier = notify.NewWithSender(func(title, body string) error {
+		log.Printf("mock: notification %q: %s", title, body)
+		return nil
+	})
+	r.startSession = func(_ context.Context, cfg session.Config) (ptySession, error) {
+		return newMockSession(cfg, 1000+int(r.mock.pids.Add(1))), nil
+	}
+	return r, nil
+}
+
+// mockGuard answers the messages mock mode cannot serve and pins new sessions to the
+// mock workspace, without the clipboard. It reports whether the message was answered.
+func (r *Router) mockGuard(conn *websocket.Conn, m map[string]any) bool {
+	typ, _ := m["type"].(string)
+	if mockUnsupported[typ] {
+		ErrorCode(conn, "mockUnsupported", map[string]any{"messageType": typ}, "%s is not available in mock mode", typ)
+		return true
+	}
+	if typ == "openSession" {
+		m["cwd"] = r.mock.root
+		m["useClipboard"] = false
+	}
+	return false
+}
+
+// mockSession is a scripted stand-in for the agent CLI. It prints a banner and a prompt,
+// and answers each input line: the commands listed by help print canned compiler errors,
+// test failures, a dev server URL, spinner redraws or long output, so every output
+// feature of the UI can be exercised; anything else gets a canned agent reply.
+type mockSession struct {
+	pid   int
+	outR  *io.PipeReader
+	outW  *io.PipeWriter
+	input chan []byte
+	done  chan struct{}
+	stop  sync.Once
+}
+
+const mockHelp = "Commands: help, error, test, server, spinner, long, exit; anything else is answered as a prompt\r\n"
+
+func newMockSession(cfg session.Config, pid int) *mockSession {
+	r, w := io.Pipe()
+	s := &mockSession{pid: pid, outR: r, outW: w, input: make(chan []byte, 64), done: make(chan struct{})}
+	go s.run(cfg)
+	return s
+}
+
+func (s *mockSession) run(cfg session.Config) {
+	defer s.finish()
+	defer s.outW.Close()
+	s.print("Rovo Dev (mock session)\r\nWorking in " + cfg.Dir + "\r\n" + mockHelp + "> ")
+	var line []byte
+	for {
+		var chunk []byte
+		select {
+		case <-s.done:
+			return
+		case chunk = <-s.input:
+		}
+		for _, b := range chunk {
+			if b != '\r' && b != '\n' {
+				line = append(line, b)
+				continue
+			}
+			// A trailing backslash continues the prompt on the next line
+			if n := len(line); n > 0 && line[n-1] == '\\' {
+				line = append(line[:n-1], '\n')
+				continue
+			}
+			text := string(line)
+			line = nil
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			if !s.answer(text) {
+				return
+			}
+			s.print("> ")
+		}
+	}
+}
+
+// answer prints the reply to one input and reports whether the session goes on
+func (s *mockSession) answer(text string) bool {
+	s.print(strings.ReplaceAll(text, "\n", "\r\n") + "\r\n")
+	switch strings.TrimSpace(text) {
+	case "help":
+		s.print(mockHelp)
+	case "error":
+		s.print("$ go build ./...\r\n# example.com/todo/internal/server\r\n" +
+			"internal/server/server.go:27:9: undefined: store\r\n" +
+			"internal/server/server.go:31:2: missing return\r\n")
+	case "test":
+		s.print("$ go test ./...\r\n--- FAIL: TestServe (0.00s)\r\n" +
+			"    server_test.go:12: body = \"null\\n\"\r\nFAIL\r\n" +
+			"FAIL\texample.com/todo/internal/server\t0.004s\r\n")
+	case "server":
+		s.print("$ npm run dev\r\n\r\n  VITE v5.0.0  ready in 312 ms\r\n\r\n  ➜  Local:   http://localhost:5173/\r\n")
+	case "spinner":
+		for i, frame := range []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"} {
+			s.print(fmt.Sprintf("\r%s Thinking (%d/10)\x1b[K", frame, i+1))
+			select {
+			case <-s.done:
+				return false
+			case <-time.After(mockSpinnerDelay):
+			}
+		}
+		s.print("\r✓ Done thinking\x1b[K\r\n")
+	case "long":
+		var b strings.Builder
+		for i := 1; i <= 500; i++ {
+			fmt.Fprintf(&b, "line %03d of mock output\r\n", i)
+		}
+		s.print(b.String())
+	case "exit":
+		s.print("Bye\r\n")
+		return false
+	default:
+		lines := strings.Count(text, "\n") + 1
+		s.print(fmt.Sprintf("I'll look into that (%d line(s), %d bytes received).\r\n", lines, len(text)) +
+			"Reading internal/server/server.go\r\n" +
+			"This is a mock reply; no agent is running.\r\n")
+	}
+	return true
+}
+
+func (s *mockSession) print(text string) {
+	_, _ = s.outW.Write([]byte(text))
+}
+
+type mockStdin struct{ s *mockSession }
+
+func (w mockStdin) Write(p []byte) (int, error) {
+	select {
+	case w.s.input <- append([]byte(nil), p...):
+		return len(p), nil
+	case <-w.s.done:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+func (s *mockSession) Stdin() io.Writer      { return mockStdin{s} }
+func (s *mockSession) Stdout() io.Reader     { return s.outR }
+func (s *mockSession) Resize(int, int) error { return nil }
+func (s *mockSession) PID() int              { return s.pid }
+func (s *mockSession) Wait() error           { <-s.done; return nil }
+
+// finish marks the session exited
+func (s *mockSession) finish() {
+	s.stop.Do(func() { close(s.done) })
+}
+
+func (s *mockSession) Close() error {
+	s.finish()
+	s.outR.Close()
+	return nil
+}
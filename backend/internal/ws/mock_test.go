@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+)
+
+func newMockTestRouter(t *testing.T) *Router {
+	t.Helper()
+	r, err := NewMockRouter(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.indexer.Close)
+	return r
+}
+
+func TestMock_ScriptedSession(t *testing.T) {
+	r := newMockTestRouter(t)
+	c, done := dialRouter(t, r)
+	defer done()
+
+	if err := c.WriteJSON(map[string]any{"type": "openSession", "id": "m1", "cwd": t.TempDir(), "useClipboard": true}); err != nil {
+		t.Fatal(err)
+	}
+	opened := readType(t, c, "opened")
+	if opened["pid"] != float64(1001) {
+		t.Errorf("pid = %v, want the first mock pid", opened["pid"])
+	}
+	if hist, _ := opened["promptHistory"].([]any); len(hist) != len(mockHistory) {
+		t.Fatalf("promptHistory = %v, want the %d mock entries", opened["promptHistory"], len(mockHistory))
+	}
+	readStdout(t, c, "Working in "+mockRoot)
+
+	// The session is pinned to the mock workspace and never uses the clipboard
+	r.mu.Lock()
+	st := r.sessionStates[opened["sessionId"].(string)]
+	r.mu.Unlock()
+	st.mu.Lock()
+	dir, clip := st.workingDir, st.useClipboard
+	st.mu.Unlock()
+	if dir != mockRoot || clip {
+		t.Fatalf("workingDir = %q, useClipboard = %v", dir, clip)
+	}
+
+	stdin := func(s string) {
+		t.Helper()
+		if err := c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "m1", "dataBase64": base64.StdEncoding.EncodeToString([]byte(s))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stdin("error\r")
+	if d := readType(t, c, "diagnostic")["diagnostic"].(map[string]any); d["message"] != "undefined: store" {
+		t.Errorf("diagnostic = %v", d)
+	}
+	stdin("server\r")
+	if msg := readType(t, c, "portDetected"); msg["port"] != float64(5173) {
+		t.Errorf("portDetected = %v", msg)
+	}
+	stdin("exit\r")
+	if msg := readType(t, c, "exit"); msg["code"] != float64(0) {
+		t.Errorf("exit = %v", msg)
+	}
+}
+
+func TestMock_IndexFilesAndUnsupported(t *testing.T) {
+	r := newMockTestRouter(t)
+	c, done := dialRouter(t, r)
+	defer done()
+
+	_ = c.WriteJSON(map[string]any{"type": "searchIndex", "pattern": "server_test"})
+	res, _ := readType(t, c, "searchResult")["results"].([]any)
+	if len(res) == 0 || res[0].(map[string]any)["path"] != filepath.FromSlash("internal/server/server_test.go") {
+		t.Fatalf("results = %v", res)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "selectContext", "text": "the todo list component"})
+	if files, _ := readType(t, c, "contextSelection")["files"].([]any); len(files) == 0 {
+		t.Fatal("no context proposed from the mock workspace")
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "m1"})
+	readType(t, c, "opened")
+	_ = c.WriteJSON(map[string]any{"type": "injectFiles", "sessionId": "m1", "paths": []string{
+		"internal/server/server.go", filepath.Join(mockRoot, "go.mod"), "missing.go",
+	}})
+	files := readType(t, c, "injectResult")["files"].([]any)
+	for i, f := range files {
+		_, failed := f.(map[string]any)["error"]
+		if failed != (i == 2) {
+			t.Errorf("file %d: %v", i, f)
+		}
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "runTask", "sessionId": "m1", "name": "test"})
+	if msg := readType(t, c, "error"); msg["code"] != "mockUnsupported" || msg["messageType"] != "runTask" {
+		t.Fatalf("error = %v", msg)
+	}
+}
@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxNotes bounds the notes kept per session
+	maxNotes = 200
+	// maxNoteBytes bounds the text of one note
+	maxNoteBytes = 4096
+)
+
+// note is a user note or bookmark attached to a position in a session's output stream,
+// e.g. to mark where a long agent run went wrong
+type note struct {
+	ID        string `json:"id"`
+	Offset    int64  `json:"offset"` // stream byte offset the note is attached to
+	Seq       uint64 `json:"seq"`    // stdout sequence number when the note was added
+	Text      string `json:"text,omitempty"`
+	Bookmark  bool   `json:"bookmark,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// addNote attaches a note to the session's output at offset, or at the end of the output
+// sent so far when offset is negative. The note is also written to the session's
+// recording as a marker, so it outlives the session.
+func (st *sessionState) addNote(text string, offset int64, bookmark bool) (note, string) {
+	if len(text) > maxNoteBytes {
+		return note{}, "note is longer than " + strconv.Itoa(maxNoteBytes) + " bytes"
+	}
+	if text == "" && !bookmark {
+		return note{}, "empty note"
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if offset < 0 {
+		offset = st.sentBytes
+	}
+	if offset > st.sentBytes {
+		return note{}, "offset is past the end of the output"
+	}
+	if len(st.notes) >= maxNotes {
+		return note{}, "too many notes"
+	}
+	st.nextNote++
+	n := note{
+		ID:        "n" + strconv.FormatUint(st.nextNote, 10),
+		Offset:    offset,
+		Seq:       st.lastSeq,
+		Text:      text,
+		Bookmark:  bookmark,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	// Keep notes ordered by position
+	i := len(st.notes)
+	for i > 0 && st.notes[i-1].Offset > offset {
+		i--
+	}
+	st.notes = append(st.notes, note{})
+	copy(st.notes[i+1:], st.notes[i:])
+	st.notes[i] = n
+	if st.recorder != nil {
+		label := text
+		if label == "" {
+			label = "bookmark"
+		}
+		st.recorder.Marker(label)
+	}
+	return n, ""
+}
+
+// removeNote deletes the note with id and reports whether it existed
+func (st *sessionState) removeNote(id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for i, n := range st.notes {
+		if n.ID == id {
+			st.notes = append(st.notes[:i], st.notes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// notesUnsafe returns a copy of the session's notes in output order. Caller must hold st.mu.
+func (st *sessionState) notesUnsafe() []note {
+	return append([]note{}, st.notes...)
+}
+
+// handleNote serves addNote, removeNote and listNotes for the session sid
+func (r *Router) handleNote(conn *websocket.Conn, m map[string]any) error {
+	typ, _ := m["type"].(string)
+	sid, _ := m["sessionId"].(string)
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	if st == nil {
+		Errorf(conn, "no session")
+		return nil
+	}
+	switch typ {
+	case "addNote":
+		text, _ := m["text"].(string)
+		bookmark, _ := m["bookmark"].(bool)
+		offset := int64(-1)
+		if v, ok := m["offset"].(float64); ok {
+			offset = int64(v)
+		}
+		n, problem := st.addNote(text, offset, bookmark)
+		if problem != "" {
+			Errorf(conn, "addNote: %s", problem)
+			return nil
+		}
+		return SendJSON(conn, map[string]any{"type": "noteAdded", "sessionId": sid, "note": n})
+	case "removeNote":
+		id, _ := m["noteId"].(string)
+		if !st.removeNote(id) {
+			Errorf(conn, "removeNote: unknown note")
+			return nil
+		}
+		return SendJSON(conn, map[string]any{"type": "noteRemoved", "sessionId": sid, "noteId": id})
+	}
+	st.mu.Lock()
+	notes := st.notesUnsafe()
+	st.mu.Unlock()
+	return SendJSON(conn, map[string]any{"type": "notes", "sessionId": sid, "notes": notes})
+}
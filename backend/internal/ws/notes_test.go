@@ -0,0 +1,56 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/example/rovobridge/internal/recording"
+)
+
+func TestRouter_NotesReturnedOnResume(t *testing.T) {
+	r, fs := newTestRouter(t)
+	dir := t.TempDir()
+	r.SetRecordingDir(dir)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	sess := fs.last(t)
+	sess.emit("step 1 ok\nstep 2 FAILED\n")
+	readStdout(t, c, "FAILED")
+
+	_ = c.WriteJSON(map[string]any{"type": "addNote", "sessionId": "s1", "text": "this is where it went wrong"})
+	added := readType(t, c, "noteAdded")["note"].(map[string]any)
+	if added["offset"] != float64(len("step 1 ok\nstep 2 FAILED\n")) || added["id"] == "" {
+		t.Fatalf("unexpected note: %v", added)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "addNote", "sessionId": "s1", "offset": 10, "bookmark": true})
+	bookmark := readType(t, c, "noteAdded")["note"].(map[string]any)
+
+	_ = c.WriteJSON(map[string]any{"type": "addNote", "sessionId": "s1", "text": "later", "offset": 1 << 20})
+	readType(t, c, "error")
+
+	// A client resuming the session gets the notes in output order
+	other, closeOther := dialRouter(t, r)
+	defer closeOther()
+	_ = other.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "resume": true})
+	notes, _ := readType(t, other, "opened")["notes"].([]any)
+	if len(notes) != 2 || notes[0].(map[string]any)["id"] != bookmark["id"] || notes[1].(map[string]any)["id"] != added["id"] {
+		t.Fatalf("unexpected notes on resume: %v", notes)
+	}
+
+	_ = other.WriteJSON(map[string]any{"type": "removeNote", "sessionId": "s1", "noteId": bookmark["id"]})
+	readType(t, other, "noteRemoved")
+	_ = other.WriteJSON(map[string]any{"type": "listNotes", "sessionId": "s1"})
+	if notes, _ := readType(t, other, "notes")["notes"].([]any); len(notes) != 1 {
+		t.Fatalf("expected one note left, got %v", notes)
+	}
+
+	// Both notes were written to the recording as markers
+	sess.exit(nil)
+	readType(t, other, "exit")
+	list, err := recording.List(dir)
+	if err != nil || len(list) != 1 || list[0].Markers != 2 {
+		t.Fatalf("expected a recording with two markers, got %+v, %v", list, err)
+	}
+}
@@ -0,0 +1,50 @@
+package ws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/example/rovobridge/internal/notify"
+)
+
+// trackActivityUnsafe records output activity for idle notifications. A run starts with the
+// first output after a quiet period; once output pauses for IdleAfter, a run that lasted
+// at least MinRun notifies. Callers hold st.mu.
+func (r *Router) trackActivityUnsafe(st *sessionState, now time.Time) {
+	if !r.notifier.Wants(notify.EventIdle) {
+		return
+	}
+	if st.runStart.IsZero() {
+		st.runStart = now
+	}
+	st.lastOutput = now
+	if st.idleTimer != nil {
+		st.idleTimer.Stop()
+	}
+	st.idleTimer = time.AfterFunc(r.notifier.Config().IdleAfter, func() {
+		st.mu.Lock()
+		run := st.lastOutput.Sub(st.runStart)
+		st.runStart = time.Time{}
+		st.idleTimer = nil
+		st.mu.Unlock()
+		if run >= r.notifier.Config().MinRun {
+			r.notifier.Notify(notify.EventIdle, "RovoBridge", fmt.Sprintf("The agent went idle after %s of output", run.Round(time.Second)))
+		}
+	})
+}
+
+// notifyConfig parses an updateNotifications message:
+// { enabled: bool, events?: ["idle","exit"], idleAfterMs?, minRunMs?: number }
+func notifyConfig(m map[string]any) notify.Config {
+	cfg := notify.DefaultConfig()
+	cfg.Enabled, _ = m["enabled"].(bool)
+	if events, ok := anyToStrings(m["events"]); ok && events != nil {
+		cfg.Events = map[notify.Event]bool{}
+		for _, ev := range events {
+			cfg.Events[notify.Event(ev)] = true
+		}
+	}
+	cfg.IdleAfter = time.Duration(asInt(m["idleAfterMs"])) * time.Millisecond
+	cfg.MinRun = time.Duration(asInt(m["minRunMs"])) * time.Millisecond
+	return cfg
+}
@@ -0,0 +1,41 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/rovobridge/internal/notify"
+)
+
+func TestTrackActivity_NotifiesAfterLongRun(t *testing.T) {
+	sent := make(chan string, 4)
+	r := &Router{notifier: notify.NewWithSender(func(title, body string) error {
+		sent <- body
+		return nil
+	})}
+	r.notifier.SetConfig(notifyConfig(map[string]any{"enabled": true, "events": []any{"idle"}, "idleAfterMs": float64(20), "minRunMs": float64(1000)}))
+
+	st := &sessionState{}
+	start := time.Now()
+	st.mu.Lock()
+	r.trackActivityUnsafe(st, start)
+	r.trackActivityUnsafe(st, start.Add(2*time.Second)) // long run: output for 2s
+	st.mu.Unlock()
+	select {
+	case body := <-sent:
+		if body != "The agent went idle after 2s of output" {
+			t.Fatalf("unexpected notification %q", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected idle notification")
+	}
+
+	st.mu.Lock()
+	r.trackActivityUnsafe(st, time.Now()) // short run after the idle period
+	st.mu.Unlock()
+	select {
+	case body := <-sent:
+		t.Fatalf("unexpected notification for a short run %q", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
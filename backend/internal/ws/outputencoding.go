@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// lookupOutputEncoding resolves the outputEncoding of a session: an IANA name or alias
+// such as "windows-1252", "cp437", "cp850" or "shift_jis", with "cp1252" and the other
+// Windows ANSI code pages accepted as well. Empty and UTF-8 need no transcoding and
+// yield nil.
+func lookupOutputEncoding(name string) (encoding.Encoding, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	}
+	if n, ok := strings.CutPrefix(name, "cp"); ok && len(n) == 4 && strings.HasPrefix(n, "125") {
+		name = "windows-" + n
+	}
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unknown output encoding %q", name)
+	}
+	if enc == unicode.UTF8 {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// transcodedSession runs a session's output through the decoder of a legacy code page
+// into UTF-8, and its input back through the encoder. Characters the code page cannot
+// represent are replaced by its substitute character.
+type transcodedSession struct {
+	ptySession
+	stdout io.Reader
+	stdin  *transcodedStdin
+}
+
+// transcodedStdin serializes writes: the encoder keeps the partial character a write ends
+// with for the next one
+type transcodedStdin struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *transcodedStdin) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.w.Write(p)
+}
+
+// transcodeSession wraps sess to transcode between enc and UTF-8, or returns it as is
+// when enc is nil
+func transcodeSession(sess ptySession, enc encoding.Encoding) ptySession {
+	if enc == nil {
+		return sess
+	}
+	return &transcodedSession{
+		ptySession: sess,
+		stdout:     transform.NewReader(sess.Stdout(), enc.NewDecoder()),
+		stdin:      &transcodedStdin{w: transform.NewWriter(sess.Stdin(), encoding.ReplaceUnsupported(enc.NewEncoder()))},
+	}
+}
+
+func (s *transcodedSession) Stdout() io.Reader { return s.stdout }
+func (s *transcodedSession) Stdin() io.Writer  { return s.stdin }
@@ -0,0 +1,53 @@
+package ws
+
+import "testing"
+
+func TestRouter_OutputEncodingTranscodes(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "outputEncoding": "cp1252"})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	f.emit("caf\xe9 \x80\r\n")
+	readStdout(t, c, "café €\r\n")
+
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("né 😀")})
+	eventually(t, "the input to be transcoded", func() bool { return f.stdinString() == "n\xe9 \x1a" })
+}
+
+func TestRouter_OutputEncodingSessionConfig(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "updateSessionConfig", "outputEncoding": "klingon"})
+	readType(t, c, "error")
+	_ = c.WriteJSON(map[string]any{"type": "updateSessionConfig", "outputEncoding": "cp437"})
+	msg := readType(t, c, "sessionConfigUpdated")
+	if cfg, _ := msg["sessionConfig"].(map[string]any); cfg["outputEncoding"] != "cp437" {
+		t.Fatalf("expected the encoding in sessionConfig, got %v", msg)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	fs.last(t).emit("\xc9\xcd\xbb\r\n")
+	readStdout(t, c, "╔═╗\r\n")
+}
+
+func TestLookupOutputEncoding(t *testing.T) {
+	for _, name := range []string{"", "UTF-8", "utf8"} {
+		if enc, err := lookupOutputEncoding(name); enc != nil || err != nil {
+			t.Fatalf("%q: expected no transcoding, got %v, %v", name, enc, err)
+		}
+	}
+	for _, name := range []string{"cp1252", "windows-1251", "IBM850", "Shift_JIS"} {
+		if enc, err := lookupOutputEncoding(name); enc == nil || err != nil {
+			t.Fatalf("%q: expected an encoding, got %v, %v", name, enc, err)
+		}
+	}
+	if _, err := lookupOutputEncoding("cp9999"); err == nil {
+		t.Fatal("expected an unknown encoding to be rejected")
+	}
+}
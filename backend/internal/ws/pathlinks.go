@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/example/rovobridge/internal/index"
+)
+
+// pathRefRe matches file:line[:col] references such as "src/app.ts:12:5" or
+// "C:\repo\main.go:7". The path must have an extension to limit false positives.
+var pathRefRe = regexp.MustCompile(`((?:[A-Za-z]:[\\/])?[\w.@~+\-/\\]*[\w\-]\.[A-Za-z0-9]{1,10}):(\d+)(?::(\d+))?`)
+
+// pathAnnotation marks a file reference in the raw output stream. Start and End are
+// absolute byte offsets (End exclusive) matching the "offset" of stdout messages.
+type pathAnnotation struct {
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Path   string `json:"path"` // relative to the index root
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+// emitPathAnnotations detects file:line references in completed output lines that
+// resolve to indexed files and sends them as a "pathAnnotations" event.
+func (r *Router) emitPathAnnotations(sid string, st *sessionState, lines []plainLine) {
+	if r.indexer == nil {
+		return
+	}
+	annotations := findPathAnnotations(r.indexer.Snapshot(), r.indexer.Root, lines)
+	if len(annotations) == 0 {
+		return
+	}
+	st.mu.Lock()
+	conn := st.currentConn
+	st.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	_ = SendJSON(conn, map[string]any{"type": "pathAnnotations", "sessionId": sid, "annotations": annotations})
+}
+
+func findPathAnnotations(snap index.Snapshot, root string, lines []plainLine) []pathAnnotation {
+	var out []pathAnnotation
+	for _, l := range lines {
+		if !strings.Contains(l.text, ":") {
+			continue
+		}
+		for _, m := range pathRefRe.FindAllStringSubmatchIndex(l.text, -1) {
+			raw := l.text[m[2]:m[3]]
+			rel, ok := resolveIndexedPath(snap, root, raw)
+			if !ok {
+				continue
+			}
+			a := pathAnnotation{
+				Start: l.offsets[m[0]],
+				End:   l.offsets[m[1]-1] + 1,
+				Path:  rel,
+			}
+			a.Line, _ = strconv.Atoi(l.text[m[4]:m[5]])
+			if m[6] >= 0 {
+				a.Column, _ = strconv.Atoi(l.text[m[6]:m[7]])
+			}
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// resolveIndexedPath maps a path printed in the output to an indexed file: relative to
+// the root, absolute under the root, or a bare file name that is unique in the index.
+func resolveIndexedPath(snap index.Snapshot, root, p string) (string, bool) {
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "./"), ".\\")
+	if filepath.IsAbs(p) || (len(p) > 2 && p[1] == ':') {
+		rootAbs, err := filepath.Abs(root)
+		if err != nil {
+			return "", false
+		}
+		rel, err := filepath.Rel(rootAbs, filepath.FromSlash(p))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		p = rel
+	}
+	if e, ok := snap.Lookup(p); ok && !e.IsDir {
+		return e.Path, true
+	}
+	if !strings.ContainsAny(p, `/\`) {
+		if matches := snap.ByName(p); len(matches) == 1 && !matches[0].IsDir {
+			return matches[0].Path, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,41 @@
+package ws
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/example/rovobridge/internal/index"
+)
+
+func TestFindPathAnnotations(t *testing.T) {
+	root := t.TempDir()
+	snap := index.NewSnapshot([]index.Entry{
+		{Path: filepath.Join("src", "app.ts"), Name: "app.ts"},
+		{Path: "main.go", Name: "main.go"},
+		{Path: filepath.Join("a", "util.go"), Name: "util.go"},
+		{Path: filepath.Join("b", "util.go"), Name: "util.go"},
+	})
+	var m plainTextMirror
+	m.write([]byte("0123456789")) // unterminated, shifts offsets of the next line
+	lines := m.write([]byte("\n\x1b[31m./src/app.ts:12:5\x1b[0m error, see main.go:7 and util.go:3 or missing.go:1\n"))
+
+	got := findPathAnnotations(snap, root, lines)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 annotations, got %+v", got)
+	}
+	if got[0].Path != filepath.Join("src", "app.ts") || got[0].Line != 12 || got[0].Column != 5 {
+		t.Errorf("unexpected first annotation: %+v", got[0])
+	}
+	// "./src/app.ts:12:5" starts after the newline and the 5-byte color sequence
+	if got[0].Start != 16 || got[0].End != 16+int64(len("./src/app.ts:12:5")) {
+		t.Errorf("unexpected offsets for first annotation: %+v", got[0])
+	}
+	if got[1].Path != "main.go" || got[1].Line != 7 || got[1].Column != 0 {
+		t.Errorf("unexpected second annotation: %+v", got[1])
+	}
+
+	abs := scanPlainText([]byte(filepath.Join(root, "main.go")+":3\n"), 0, true)
+	if got := findPathAnnotations(snap, root, abs); len(got) != 1 || got[0].Path != "main.go" {
+		t.Errorf("expected absolute path under root to resolve, got %+v", got)
+	}
+}
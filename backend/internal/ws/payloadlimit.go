@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/example/rovobridge/internal/fileutil"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultMaxPayloadBytes bounds the prompt text and file contents one send or injectFiles
+// writes into a session: room for large multi-file prompts, but not for megabytes of logs
+// or data typed into the PTY by accident
+const DefaultMaxPayloadBytes = 1 << 20
+
+// PayloadPart is the share of one injected file in an oversized payload
+type PayloadPart struct {
+	Path   string `json:"path"`
+	Bytes  int    `json:"bytes"`
+	Lines  int    `json:"lines"`
+	Tokens int    `json:"tokens"`
+}
+
+// PayloadSuggestion is one way to bring an oversized payload under the limit
+type PayloadSuggestion struct {
+	// Action is "dropPaths" (send without Paths), "lineRange" (send Paths, which are
+	// ":start-end" ranges of the largest file, instead of the file) or "elideDuplicates"
+	// (set the option of that name)
+	Action string   `json:"action"`
+	Paths  []string `json:"paths,omitempty"`
+	// Bytes is about the payload size after the change, when it can be told in advance
+	Bytes int `json:"bytes,omitempty"`
+}
+
+// lineRangeSpec matches a path that already names a line range
+var lineRangeSpec = regexp.MustCompile(`:\d+-\d+$`)
+
+// SetMaxPayloadBytes bounds the payload of send and injectFiles; 0 removes the bound
+func (r *Router) SetMaxPayloadBytes(n int) {
+	r.mu.Lock()
+	r.maxPayload = n
+	r.mu.Unlock()
+}
+
+// payloadFits reports whether a payload of n bytes is within the limit
+func (r *Router) payloadFits(n int) bool {
+	r.mu.Lock()
+	limit := r.maxPayload
+	r.mu.Unlock()
+	return limit <= 0 || n <= limit
+}
+
+// payloadSize is the number of bytes written for text followed by the formatted contents,
+// each of which is followed by a space
+func payloadSize(textBytes int, contents []string) int {
+	n := textBytes
+	for _, c := range contents {
+		if c == "" {
+			continue
+		}
+		n += len(c)
+		if !strings.HasSuffix(c, " ") {
+			n++
+		}
+	}
+	return n
+}
+
+// rejectPayload tells the client that a payload of total bytes was not sent, with the size
+// of each file read for it and what would make it fit
+func (r *Router) rejectPayload(conn *websocket.Conn, sid string, textBytes, total int, results []fileutil.FileResult, opts fileutil.ReadOptions) {
+	r.mu.Lock()
+	limit := r.maxPayload
+	r.mu.Unlock()
+	parts := make([]PayloadPart, 0, len(results))
+	for _, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		parts = append(parts, PayloadPart{
+			Path:   res.Path,
+			Bytes:  len(res.Content),
+			Lines:  strings.Count(res.Content, "\n") + 1,
+			Tokens: fileutil.EstimateTokens(res.Content),
+		})
+	}
+	r.logSessionEvent(sid, "payloadTooLarge", map[string]any{"bytes": total, "limit": limit})
+	ErrorCode(conn, "payloadTooLarge", map[string]any{
+		"sessionId":   sid,
+		"bytes":       total,
+		"limit":       limit,
+		"textBytes":   textBytes,
+		"files":       parts,
+		"suggestions": payloadSuggestions(parts, total, limit, opts),
+	}, "payload of %d bytes exceeds the %d byte limit; nothing was sent", total, limit)
+}
+
+// payloadSuggestions proposes, in order: dropping the largest files until the rest fits,
+// sending only the leading lines of the largest file, and eliding blocks repeated across
+// files. A payload whose text alone is too large gets none.
+func payloadSuggestions(parts []PayloadPart, total, limit int, opts fileutil.ReadOptions) []PayloadSuggestion {
+	out := []PayloadSuggestion{}
+	if len(parts) == 0 {
+		return out
+	}
+	bySize := append([]PayloadPart(nil), parts...)
+	sort.SliceStable(bySize, func(i, j int) bool { return bySize[i].Bytes > bySize[j].Bytes })
+
+	excess := total - limit
+	var drop []string
+	size := total
+	for _, p := range bySize {
+		if size <= limit {
+			break
+		}
+		drop = append(drop, p.Path)
+		size -= p.Bytes + 1
+	}
+	if size <= limit {
+		out = append(out, PayloadSuggestion{Action: "dropPaths", Paths: drop, Bytes: size})
+	}
+
+	// Keep the share of the largest file's lines that fits, rounded down for line lengths
+	if largest := bySize[0]; largest.Bytes > excess && largest.Lines > 1 && !lineRangeSpec.MatchString(largest.Path) {
+		keep := largest.Lines * (largest.Bytes - excess) / largest.Bytes * 9 / 10
+		if keep >= 1 {
+			out = append(out, PayloadSuggestion{Action: "lineRange", Paths: []string{fmt.Sprintf("%s:0-%d", largest.Path, keep-1)}})
+		}
+	}
+
+	if len(parts) > 1 && !opts.ElideDuplicates {
+		out = append(out, PayloadSuggestion{Action: "elideDuplicates"})
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/rovobridge/internal/fileutil"
+)
+
+func TestRouter_SendRejectsOversizedPayload(t *testing.T) {
+	r, fs := newTestRouter(t)
+	r.SetMaxPayloadBytes(4000)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.log")
+	small := filepath.Join(dir, "small.go")
+	if err := os.WriteFile(big, []byte(strings.Repeat("line of log output\n", 300)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(small, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir, "useClipboard": false})
+	readType(t, c, "opened")
+
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64("explain"), "paths": []string{big, small}})
+	msg := readType(t, c, "error")
+	if msg["code"] != "payloadTooLarge" || msg["limit"] != float64(4000) || msg["textBytes"] != float64(7) {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+	files := msg["files"].([]any)
+	if len(files) != 2 || files[0].(map[string]any)["path"] != big || files[0].(map[string]any)["bytes"].(float64) < 4000 {
+		t.Fatalf("unexpected breakdown: %v", files)
+	}
+	var actions []string
+	for _, s := range msg["suggestions"].([]any) {
+		actions = append(actions, s.(map[string]any)["action"].(string))
+	}
+	if got := strings.Join(actions, ","); got != "dropPaths,lineRange,elideDuplicates" {
+		t.Fatalf("suggestions = %s (%v)", got, msg["suggestions"])
+	}
+
+	// Nothing reached the session; a payload within the limit still goes through
+	time.Sleep(50 * time.Millisecond)
+	f := fs.last(t)
+	if in := f.stdinString(); in != "" {
+		t.Fatalf("oversized payload written: %q", in)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64("explain"), "paths": []string{small}})
+	readType(t, c, "injectResult")
+	eventually(t, "small payload sent", func() bool { return strings.Contains(f.stdinString(), "package main") })
+
+	// Text alone over the limit is rejected without a breakdown
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64(strings.Repeat("x", 5000))})
+	if msg := readType(t, c, "error"); msg["code"] != "payloadTooLarge" || len(msg["suggestions"].([]any)) != 0 {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+}
+
+func TestPayloadSuggestions_LineRange(t *testing.T) {
+	parts := []PayloadPart{{Path: "a.txt", Bytes: 10000, Lines: 1000}}
+	got := payloadSuggestions(parts, 10100, 5000, fileutil.ReadOptions{})
+	if len(got) != 2 || got[0].Action != "dropPaths" || got[1].Action != "lineRange" || got[1].Paths[0] != "a.txt:0-440" {
+		t.Fatalf("suggestions = %+v", got)
+	}
+	// A file given as a range already gets no further range
+	parts[0].Path = "a.txt:0-999"
+	if got := payloadSuggestions(parts, 10100, 5000, fileutil.ReadOptions{}); len(got) != 1 {
+		t.Fatalf("suggestions = %+v", got)
+	}
+}
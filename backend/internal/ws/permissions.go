@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/example/rovobridge/internal/permission"
+	"github.com/gorilla/websocket"
+)
+
+// maxPermissionLines is how many completed output lines are kept for recognizing a
+// permission prompt, which may span several lines
+const maxPermissionLines = 8
+
+// pendingPermission is a permission prompt of the agent waiting for an answer
+type pendingPermission struct {
+	id     string
+	prompt permission.Prompt
+}
+
+// watchPermissions looks for a permission prompt at the end of a session's output and
+// sends a "permissionRequest" event when a new one appears. A prompt the output moved on
+// from was answered in the terminal; its request is resolved without a choice.
+func (r *Router) watchPermissions(sid string, st *sessionState, lines []plainLine) {
+	st.mu.Lock()
+	for _, l := range lines {
+		st.permLines = append(st.permLines, l.text)
+	}
+	if n := len(st.permLines) - maxPermissionLines; n > 0 {
+		st.permLines = append(st.permLines[:0], st.permLines[n:]...)
+	}
+	window := append(append([]string(nil), st.permLines...), st.mirror.pending())
+	p, ok := permission.Detect(window)
+
+	var resolved, requested map[string]any
+	switch {
+	case !ok:
+		// The answered prompt may be shown again once the output shows something else
+		st.permAnswered = ""
+		if st.permission != nil && len(lines) > 0 {
+			resolved = st.resolvePermissionUnsafe(sid, "")
+		}
+	case st.permission != nil && st.permission.prompt.Question == p.Question, st.permAnswered == p.Question:
+		// Still waiting, or the terminal redrew a prompt already answered
+	default:
+		if st.permission != nil {
+			resolved = st.resolvePermissionUnsafe(sid, "")
+		}
+		st.nextPermission++
+		st.permission = &pendingPermission{id: "perm-" + strconv.FormatUint(st.nextPermission, 10), prompt: p}
+		requested = map[string]any{"type": "permissionRequest", "sessionId": sid, "requestId": st.permission.id, "prompt": p}
+	}
+	conn := st.currentConn
+	st.mu.Unlock()
+
+	for _, msg := range []map[string]any{resolved, requested} {
+		if msg == nil {
+			continue
+		}
+		r.recordEvent(sid, msg)
+		if conn != nil {
+			_ = SendJSON(conn, msg)
+		}
+	}
+}
+
+// resolvePermissionUnsafe forgets the pending permission prompt and returns the
+// "permissionResolved" event for it; choice is empty when it was answered in the terminal.
+// Caller must hold st.mu.
+func (st *sessionState) resolvePermissionUnsafe(sid, choice string) map[string]any {
+	p := st.permission
+	st.permission = nil
+	st.permAnswered = p.prompt.Question
+	msg := map[string]any{"type": "permissionResolved", "sessionId": sid, "requestId": p.id}
+	if choice != "" {
+		msg["choice"] = choice
+	}
+	return msg
+}
+
+// respondPermission answers a permissionRequest by typing the keys of choice into the session
+func (r *Router) respondPermission(conn *websocket.Conn, sid, requestID, choice string) error {
+	r.mu.Lock()
+	sess := r.sessions[sid]
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	if sess == nil || st == nil {
+		Errorf(conn, "no session")
+		return nil
+	}
+	if r.rejectHandedOff(conn, sid, st) {
+		return nil
+	}
+	st.mu.Lock()
+	p := st.permission
+	if p == nil || p.id != requestID {
+		st.mu.Unlock()
+		ErrorCode(conn, "permissionStale", map[string]any{"sessionId": sid}, "respondPermission: %s is no longer waiting for an answer", requestID)
+		return nil
+	}
+	keys, ok := p.prompt.Keys(choice)
+	if !ok {
+		st.mu.Unlock()
+		Errorf(conn, "respondPermission: the prompt does not offer %q", choice)
+		return nil
+	}
+	msg := st.resolvePermissionUnsafe(sid, choice)
+	st.needImmediate = true
+	current := st.currentConn
+	st.mu.Unlock()
+
+	_, _ = sess.Stdin().Write([]byte(keys))
+	r.recordEvent(sid, msg)
+	if current != nil {
+		_ = SendJSON(current, msg)
+	}
+	if current != conn {
+		return SendJSON(conn, msg)
+	}
+	return nil
+}
+
+// permissionAnsweredInTerminal resolves the pending permission prompt of a session the
+// client typed input into, as the input answers it. Escape sequences, such as arrow keys
+// moving through a menu or focus reports, do not answer it.
+func (r *Router) permissionAnsweredInTerminal(sid string, st *sessionState, input []byte) {
+	if len(input) == 0 || bytes.IndexByte(input, 0x1b) >= 0 {
+		return
+	}
+	st.mu.Lock()
+	if st.permission == nil {
+		st.mu.Unlock()
+		return
+	}
+	msg := st.resolvePermissionUnsafe(sid, "")
+	conn := st.currentConn
+	st.mu.Unlock()
+	r.recordEvent(sid, msg)
+	if conn != nil {
+		_ = SendJSON(conn, msg)
+	}
+}
@@ -0,0 +1,44 @@
+package ws
+
+import "testing"
+
+func TestPermissionRelay(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, done := dialRouter(t, r)
+	defer done()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	// The prompt waits on an unterminated line
+	f.emit("Planning...\r\nAllow tool 'bash' to run `go test ./...`? (y/n/a) ")
+	req := readType(t, c, "permissionRequest")
+	prompt, _ := req["prompt"].(map[string]any)
+	if req["sessionId"] != sessionIDOf(t, r, "s1") || prompt["tool"] != "bash" || prompt["detail"] != "go test ./..." || len(prompt["choices"].([]any)) != 3 {
+		t.Fatalf("permissionRequest = %v", req)
+	}
+	id := req["requestId"].(string)
+
+	_ = c.WriteJSON(map[string]any{"type": "respondPermission", "sessionId": "s1", "requestId": id, "choice": "always"})
+	if res := readType(t, c, "permissionResolved"); res["requestId"] != id || res["choice"] != "always" {
+		t.Fatalf("permissionResolved = %v", res)
+	}
+	eventually(t, "the answer typed", func() bool { return f.stdinString() == "a\r" })
+
+	// Answering again is refused
+	_ = c.WriteJSON(map[string]any{"type": "respondPermission", "sessionId": "s1", "requestId": id, "choice": "yes"})
+	if msg := readType(t, c, "error"); msg["code"] != "permissionStale" {
+		t.Fatalf("error = %v", msg)
+	}
+
+	// A menu answered by typing into the terminal resolves without a choice
+	f.emit("a\r\nok\r\nAllow tool 'bash' to run `rm -rf build`?\r\n❯ 1. Yes\r\n  2. No\r\n")
+	req = readType(t, c, "permissionRequest")
+	if req["requestId"] == id {
+		t.Fatalf("request id reused: %v", req)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("2")})
+	if res := readType(t, c, "permissionResolved"); res["requestId"] != req["requestId"] || res["choice"] != nil {
+		t.Fatalf("permissionResolved = %v", res)
+	}
+}
@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// plainTextEnv asks the process for output a screen reader can follow: no colors and no
+// full-screen interface. Variables the client sets itself win.
+var plainTextEnv = []string{"TERM=dumb", "NO_COLOR=1"}
+
+// plainTextOptions reads the plain text option of openSession:
+// { plainText?: bool, pty?: bool, env?: string[] }
+// and returns whether it is on, with env extended by plainTextEnv. Plain text sessions run
+// without a PTY unless the client asks for one.
+func plainTextOptions(m map[string]any, env []string) (plain bool, usePTY bool, out []string) {
+	usePTY = true
+	if v, ok := m["pty"].(bool); ok {
+		usePTY = v
+	}
+	plain, _ = m["plainText"].(bool)
+	if !plain {
+		return false, usePTY, env
+	}
+	if _, ok := m["pty"].(bool); !ok {
+		usePTY = false
+	}
+	out = append([]string(nil), env...)
+	for _, kv := range plainTextEnv {
+		key := kv[:strings.IndexByte(kv, '=')+1]
+		set := false
+		for _, e := range env {
+			if strings.HasPrefix(e, key) {
+				set = true
+				break
+			}
+		}
+		if !set {
+			out = append(out, kv)
+		}
+	}
+	return true, usePTY, out
+}
+
+// plainTextLine is one completed line of a lines message
+type plainTextLine struct {
+	Text   string `json:"text"`
+	Offset int64  `json:"offset"` // stream offset of the raw output of the line's first character
+}
+
+// linesMessageUnsafe turns the lines completed by a chunk of output into the lines message
+// of a plain text session, or nil when neither they nor the unterminated line changed.
+// The raw chunk is not sent, but takes up its stream offsets. Caller must hold st.mu.
+func (st *sessionState) linesMessageUnsafe(sid string, chunk []byte, lines []plainLine) map[string]any {
+	st.sentBytes += int64(len(chunk))
+	pending := st.mirror.pending()
+	if len(lines) == 0 && pending == st.plainPending {
+		return nil
+	}
+	out := make([]plainTextLine, 0, len(lines))
+	next := st.sentBytes - int64(len(chunk))
+	for _, l := range lines {
+		// a blank line has no characters; it gets the offset after the line before it
+		if len(l.offsets) > 0 {
+			next = l.offsets[0]
+		}
+		out = append(out, plainTextLine{Text: l.text, Offset: next})
+		if len(l.offsets) > 0 {
+			next = l.offsets[len(l.offsets)-1] + 1
+		}
+	}
+	st.plainPending = pending
+	st.lastSeq++
+	msg := map[string]any{"type": "lines", "sessionId": sid, "seq": st.lastSeq, "lines": out}
+	if pending != "" {
+		msg["pending"] = pending
+	}
+	st.broadcastFieldsUnsafe(msg)
+	return msg
+}
+
+// sendLines delivers a lines message to the session's client, in order with the output
+// before it
+func (r *Router) sendLines(ctx context.Context, sid string, st *sessionState, msg map[string]any) {
+	st.sendMu.Lock()
+	defer st.sendMu.Unlock()
+	st.mu.Lock()
+	c := st.currentConn
+	if st.staleUnsafe(ctx) || c == nil {
+		st.mu.Unlock()
+		return
+	}
+	metrics, latency := st.firstOutputUnsafe(sid, time.Now())
+	st.mu.Unlock()
+	if err := SendJSON(c, msg); err != nil {
+		log.Printf("ws write error: %v", err)
+	}
+	if metrics != nil {
+		r.recordPromptLatency(latency)
+		_ = SendJSON(c, metrics)
+	}
+	st.mu.Lock()
+	st.lastSend = time.Now()
+	st.mu.Unlock()
+}
+
+// addPlainSnapshot adds the plain text of the replay to a snapshot of a plain text session
+func addPlainSnapshot(msg map[string]any, plain bool, data []byte) map[string]any {
+	if plain {
+		msg["text"] = strings.TrimRight(strings.Join(plainTextLines(data), "\n"), "\n")
+	}
+	return msg
+}
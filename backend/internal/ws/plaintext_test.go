@@ -0,0 +1,63 @@
+package ws
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/example/rovobridge/internal/session"
+)
+
+func TestRouter_PlainTextSessionSendsLines(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "plainText": true, "env": []string{"TERM=vt100"}})
+	readType(t, c, "opened")
+	f := fs.last(t)
+	if f.cfg.Mode != session.ModeNoPTY {
+		t.Fatalf("plain text session runs with mode %v", f.cfg.Mode)
+	}
+	if !slices.Equal(f.cfg.Env, []string{"TERM=vt100", "NO_COLOR=1"}) {
+		t.Fatalf("unexpected env: %v", f.cfg.Env)
+	}
+
+	f.emit("\x1b[1;32mdone\x1b[0m   \r\nsecond\n> ")
+	msg := readType(t, c, "lines")
+	lines := msg["lines"].([]any)
+	if len(lines) != 2 || msg["pending"] != ">" {
+		t.Fatalf("unexpected lines: %v", msg)
+	}
+	first, second := lines[0].(map[string]any), lines[1].(map[string]any)
+	if first["text"] != "done" || first["offset"] != float64(len("\x1b[1;32m")) {
+		t.Fatalf("unexpected first line: %v", first)
+	}
+	if second["text"] != "second" || second["offset"] != float64(len("\x1b[1;32mdone\x1b[0m   \r\n")) {
+		t.Fatalf("unexpected second line: %v", second)
+	}
+
+	// The prompt line is sent once it is complete, at its stream offset
+	f.emit("yes\n")
+	msg = readType(t, c, "lines")
+	lines = msg["lines"].([]any)
+	if len(lines) != 1 || lines[0].(map[string]any)["text"] != "> yes" || msg["pending"] != nil {
+		t.Fatalf("unexpected lines: %v", msg)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "snapshot", "sessionId": "s1"})
+	snap := readType(t, c, "snapshot")
+	if snap["text"] != "done\nsecond\n> yes" {
+		t.Fatalf("unexpected snapshot text: %q", snap["text"])
+	}
+}
+
+func TestPlainTextOptions_PtyOverride(t *testing.T) {
+	plain, usePTY, env := plainTextOptions(map[string]any{"plainText": true, "pty": true}, nil)
+	if !plain || !usePTY || !slices.Equal(env, []string{"TERM=dumb", "NO_COLOR=1"}) {
+		t.Fatalf("got %v %v %v", plain, usePTY, env)
+	}
+	plain, usePTY, env = plainTextOptions(map[string]any{"env": []string{"A=1"}}, []string{"A=1"})
+	if plain || !usePTY || !slices.Equal(env, []string{"A=1"}) {
+		t.Fatalf("got %v %v %v", plain, usePTY, env)
+	}
+}
@@ -0,0 +1,263 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxForwardedPorts bounds the detected dev server ports remembered at once
+	maxForwardedPorts = 32
+	// proxyTicketTTL is how long a proxy URL handed out by openProxy can be opened
+	proxyTicketTTL = time.Minute
+	// proxyCookie carries the per-port secret that lets a preview load its assets
+	proxyCookie = "rovobridge_proxy"
+	// proxyTicketParam is the query parameter carrying a one-time proxy ticket
+	proxyTicketParam = "rovobridge_ticket"
+)
+
+// proxyTransport dials dev servers on 127.0.0.1 and, when nothing listens there, on ::1:
+// Node 17+ resolves "localhost" to ::1 first, so servers announcing localhost often
+// listen on IPv6 only
+var proxyTransport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	var d net.Dialer
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := d.DialContext(ctx, network, addr)
+		host, port, _ := net.SplitHostPort(addr)
+		if err == nil || host != "127.0.0.1" {
+			return c, err
+		}
+		if c6, err6 := d.DialContext(ctx, network, net.JoinHostPort("::1", port)); err6 == nil {
+			return c6, nil
+		}
+		return nil, err
+	}
+	return t
+}()
+
+// listenPortRes match the ways dev servers announce where they listen, e.g.
+// "Local: http://localhost:5173/", "Listening on :8080" or "Server running on port 3000"
+var listenPortRes = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bhttps?://(?:localhost|127\.0\.0\.1|0\.0\.0\.0|\[::1?\]):(\d{2,5})\b`),
+	regexp.MustCompile(`(?i)\blistening\b.*?(?:\bport\s+|:)(\d{2,5})\b`),
+	regexp.MustCompile(`(?i)\b(?:running|started|serving|available)\b.*?\bport\s+(\d{2,5})\b`),
+}
+
+// forwardedPort is a dev server port detected in session output that may be previewed
+// through /proxy/<port>/
+type forwardedPort struct {
+	Port       int    `json:"port"`
+	SessionID  string `json:"sessionId"`
+	DetectedAt int64  `json:"detectedAt"`
+	secret     string // value of proxyCookie granting access to this port
+}
+
+// proxyTicket is a one-time grant exchanged for the proxy cookie of a port
+type proxyTicket struct {
+	port    int
+	expires time.Time
+}
+
+// detectPorts returns the unprivileged ports announced in a line of output
+func detectPorts(line string) []int {
+	var ports []int
+	for _, re := range listenPortRes {
+		for _, sm := range re.FindAllStringSubmatch(line, -1) {
+			p, err := strconv.Atoi(sm[1])
+			if err != nil || p < 1024 || p > 65535 {
+				continue
+			}
+			dup := false
+			for _, have := range ports {
+				dup = dup || have == p
+			}
+			if !dup {
+				ports = append(ports, p)
+			}
+		}
+	}
+	return ports
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// emitPorts remembers dev server ports announced in completed output lines and sends a
+// "portDetected" event for each new one
+func (r *Router) emitPorts(sid string, st *sessionState, lines []plainLine) {
+	var fresh []*forwardedPort
+	r.mu.Lock()
+	for _, line := range lines {
+		for _, p := range detectPorts(line.text) {
+			if r.ports[p] != nil || len(r.ports) >= maxForwardedPorts {
+				continue
+			}
+			fp := &forwardedPort{Port: p, SessionID: sid, DetectedAt: time.Now().UnixMilli(), secret: randomHex(16)}
+			r.ports[p] = fp
+			fresh = append(fresh, fp)
+		}
+	}
+	r.mu.Unlock()
+	if len(fresh) == 0 {
+		return
+	}
+	st.mu.Lock()
+	conn := st.currentConn
+	st.mu.Unlock()
+	for _, fp := range fresh {
+		msg := map[string]any{"type": "portDetected", "sessionId": sid, "port": fp.Port, "path": proxyPath(fp.Port)}
+		r.recordEvent(sid, msg)
+		if conn != nil {
+			_ = SendJSON(conn, msg)
+		}
+	}
+}
+
+func proxyPath(port int) string {
+	return "/proxy/" + strconv.Itoa(port) + "/"
+}
+
+// SetProxyBase sets the base URL, e.g. "http://127.0.0.1:8701/", of the listener that
+// serves ServeProxy, so the URLs of openProxy point there; call it before serving. A
+// preview served from an origin of its own cannot script the UI or read its storage.
+func (r *Router) SetProxyBase(base string) {
+	r.proxyBase = strings.TrimSuffix(base, "/")
+}
+
+// forgetSessionPortsUnsafe drops the ports detected in a session's output. Caller must
+// hold r.mu.
+func (r *Router) forgetSessionPortsUnsafe(sid string) {
+	for p, fp := range r.ports {
+		if fp.SessionID == sid {
+			delete(r.ports, p)
+		}
+	}
+}
+
+// listPorts answers listPorts with the detected ports, lowest first
+func (r *Router) listPorts(conn *websocket.Conn) error {
+	r.mu.Lock()
+	ports := make([]forwardedPort, 0, len(r.ports))
+	for _, fp := range r.ports {
+		ports = append(ports, *fp)
+	}
+	r.mu.Unlock()
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return SendJSON(conn, map[string]any{"type": "ports", "ports": ports})
+}
+
+// openProxy answers openProxy with a URL that opens the preview of a detected port. The
+// URL carries a one-time ticket, so a webview without the bearer token can load it.
+func (r *Router) openProxy(conn *websocket.Conn, port int) error {
+	ticket := randomHex(16)
+	now := time.Now()
+	r.mu.Lock()
+	if r.ports[port] == nil {
+		r.mu.Unlock()
+		Errorf(conn, "openProxy: port %d was not detected in session output", port)
+		return nil
+	}
+	for t, pt := range r.proxyTickets {
+		if now.After(pt.expires) {
+			delete(r.proxyTickets, t)
+		}
+	}
+	r.proxyTickets[ticket] = proxyTicket{port: port, expires: now.Add(proxyTicketTTL)}
+	r.mu.Unlock()
+	return SendJSON(conn, map[string]any{
+		"type": "proxyUrl",
+		"port": port,
+		"url":  r.proxyBase + proxyPath(port) + "?" + proxyTicketParam + "=" + ticket,
+	})
+}
+
+// ServeProxy serves /proxy/<port>/... by forwarding to a dev server detected in session
+// output on 127.0.0.1:<port>. Requests must be authorized with the bearer token
+// (authorized), the port's cookie, or a ticket from openProxy, which is exchanged for
+// the cookie. Bridge credentials are not forwarded, and the dev server cannot set cookies:
+// they would reach the bridge, and its other previews, as cookies are not kept apart by port.
+func (r *Router) ServeProxy(w http.ResponseWriter, req *http.Request, authorized bool) {
+	portStr, rest, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/proxy/"), "/")
+	port, err := strconv.Atoi(portStr)
+	r.mu.Lock()
+	fp := r.ports[port]
+	var secret string
+	if fp != nil {
+		secret = fp.secret
+	}
+	r.mu.Unlock()
+	if err != nil || fp == nil {
+		http.Error(w, "no such port", http.StatusNotFound)
+		return
+	}
+	if rest == "" && !strings.HasSuffix(req.URL.Path, "/") {
+		http.Redirect(w, req, proxyPath(port), http.StatusFound)
+		return
+	}
+
+	if ticket := req.URL.Query().Get(proxyTicketParam); ticket != "" {
+		r.mu.Lock()
+		pt, ok := r.proxyTickets[ticket]
+		delete(r.proxyTickets, ticket)
+		r.mu.Unlock()
+		if !ok || pt.port != port || time.Now().After(pt.expires) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: proxyCookie, Value: secret, Path: proxyPath(port), HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		q := req.URL.Query()
+		q.Del(proxyTicketParam)
+		target := req.URL.Path
+		if len(q) > 0 {
+			target += "?" + q.Encode()
+		}
+		http.Redirect(w, req, target, http.StatusFound)
+		return
+	}
+	if !authorized {
+		c, err := req.Cookie(proxyCookie)
+		if err != nil || c.Value != secret {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port)}
+	proxy := &httputil.ReverseProxy{Transport: proxyTransport, Rewrite: func(pr *httputil.ProxyRequest) {
+		pr.Out.URL.Path = "/" + rest
+		pr.Out.URL.RawPath = ""
+		pr.SetURL(target)
+		pr.Out.Header.Del("Authorization")
+		cookies := pr.Out.Cookies()
+		pr.Out.Header.Del("Cookie")
+		for _, c := range cookies {
+			if c.Name != proxyCookie {
+				pr.Out.AddCookie(c)
+			}
+		}
+	}, ModifyResponse: func(resp *http.Response) error {
+		resp.Header.Del("Set-Cookie")
+		return nil
+	}}
+	// Dev servers stream hot-reload events for as long as the page is open, so the bridge's
+	// write timeout must not cut proxied responses off
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	proxy.ServeHTTP(w, req)
+}
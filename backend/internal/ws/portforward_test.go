@@ -0,0 +1,172 @@
+package ws
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectPorts(t *testing.T) {
+	cases := map[string][]int{
+		"  ➜  Local:   http://localhost:5173/":                       {5173},
+		"Listening on :8080":                                         {8080},
+		"Server running on port 3000":                                {3000},
+		"started server on 0.0.0.0:3000, url: http://127.0.0.1:3000": {3000},
+		"listening on port 80":                                       nil,
+		"see src/app.ts:12 for details":                              nil,
+	}
+	for line, want := range cases {
+		if got := detectPorts(line); !reflect.DeepEqual(got, want) {
+			t.Errorf("detectPorts(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestRouter_ProxiesDetectedDevServer(t *testing.T) {
+	var seen http.Header
+	dev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = req.Header.Clone()
+		http.SetCookie(w, &http.Cookie{Name: proxyCookie, Value: "forged", Path: "/"})
+		fmt.Fprintf(w, "dev %s", req.URL.Path)
+	}))
+	defer dev.Close()
+	devURL, _ := url.Parse(dev.URL)
+	port := devURL.Port()
+	portNum, _ := strconv.Atoi(port)
+
+	r, fs := newTestRouter(t)
+	r.SetProxyBase("http://127.0.0.1:8702/")
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+
+	// Undetected ports are not reachable
+	rec := httptest.NewRecorder()
+	r.ServeProxy(rec, httptest.NewRequest("GET", "/proxy/"+port+"/", nil), true)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before detection, got %d", rec.Code)
+	}
+
+	fs.last(t).emit("  Local:   http://localhost:" + port + "/\r\n")
+	if msg := readType(t, c, "portDetected"); msg["path"] != "/proxy/"+port+"/" {
+		t.Fatalf("unexpected detection: %v", msg)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "openProxy", "port": portNum})
+	link := readType(t, c, "proxyUrl")["url"].(string)
+	if !strings.HasPrefix(link, "http://127.0.0.1:8702/proxy/"+port+"/?") {
+		t.Fatalf("expected a URL on the preview listener, got %q", link)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeProxy(rec, httptest.NewRequest("GET", link, nil), false)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/proxy/"+port+"/" || len(cookies) != 1 {
+		t.Fatalf("expected the ticket to be exchanged for a cookie, got %d %v", rec.Code, rec.Header())
+	}
+	rec = httptest.NewRecorder()
+	r.ServeProxy(rec, httptest.NewRequest("GET", link, nil), false)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected a used ticket to be rejected, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/proxy/"+port+"/assets/app.js", nil)
+	req.AddCookie(cookies[0])
+	req.AddCookie(&http.Cookie{Name: "app", Value: "1"})
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	r.ServeProxy(rec, req, false)
+	if rec.Code != http.StatusOK || rec.Body.String() != "dev /assets/app.js" {
+		t.Fatalf("unexpected proxied response: %d %q", rec.Code, rec.Body.String())
+	}
+	if set := rec.Header().Values("Set-Cookie"); len(set) != 0 {
+		t.Fatalf("expected the dev server's cookies to be dropped, got %v", set)
+	}
+	if seen.Get("Authorization") != "" || strings.Contains(seen.Get("Cookie"), proxyCookie) || !strings.Contains(seen.Get("Cookie"), "app=1") {
+		t.Fatalf("bridge credentials leaked to the dev server: %v", seen)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeProxy(rec, httptest.NewRequest("GET", "/proxy/"+port+"/", nil), false)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without credentials, got %d", rec.Code)
+	}
+}
+
+func TestServeProxy_StreamsPastWriteTimeout(t *testing.T) {
+	dev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "event %d\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer dev.Close()
+	devURL, _ := url.Parse(dev.URL)
+	port := devURL.Port()
+
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	fs.last(t).emit("  Local:   http://localhost:" + port + "/\r\n")
+	readType(t, c, "portDetected")
+
+	bridge := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.ServeProxy(w, req, true)
+	}))
+	bridge.Config.WriteTimeout = 50 * time.Millisecond
+	bridge.Start()
+	defer bridge.Close()
+	resp, err := http.Get(bridge.URL + "/proxy/" + port + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || strings.Count(string(body), "event") != 3 {
+		t.Fatalf("proxied stream was cut off: %q, %v", body, err)
+	}
+}
+
+func TestServeProxy_FallsBackToIPv6Loopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("no IPv6 loopback:", err)
+	}
+	dev := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "dev on ::1")
+	}))
+	dev.Listener.Close()
+	dev.Listener = ln
+	dev.Start()
+	defer dev.Close()
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	if c, err := net.Dial("tcp", "127.0.0.1:"+port); err == nil {
+		c.Close()
+		t.Skip("the port is also taken on 127.0.0.1")
+	}
+
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	fs.last(t).emit("  Local:   http://localhost:" + port + "/\r\n")
+	readType(t, c, "portDetected")
+
+	rec := httptest.NewRecorder()
+	r.ServeProxy(rec, httptest.NewRequest("GET", "/proxy/"+port+"/", nil), true)
+	if rec.Code != http.StatusOK || rec.Body.String() != "dev on ::1" {
+		t.Fatalf("unexpected proxied response: %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package ws
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is USER_HZ, the unit of the CPU times in /proc/<pid>/stat, which is 100 on
+// every Linux architecture Go supports
+const clockTicks = 100
+
+// processUsage reads the CPU time and resident memory of process pid from /proc, or
+// returns nil when it cannot
+func processUsage(pid int) *ProcessUsage {
+	if pid <= 0 {
+		return nil
+	}
+	dir := "/proc/" + strconv.Itoa(pid)
+	stat, err := os.ReadFile(dir + "/stat")
+	if err != nil {
+		return nil
+	}
+	// the command name may hold spaces and parentheses; the fields follow the last ')'
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return nil
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	// utime and stime are fields 14 and 15 of the line, 12 and 13 after the name
+	if len(fields) < 13 {
+		return nil
+	}
+	utime, err1 := strconv.ParseInt(fields[11], 10, 64)
+	stime, err2 := strconv.ParseInt(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	u := &ProcessUsage{CPUMs: (utime + stime) * 1000 / clockTicks}
+	if statm, err := os.ReadFile(dir + "/statm"); err == nil {
+		if f := strings.Fields(string(statm)); len(f) > 1 {
+			if pages, err := strconv.ParseInt(f[1], 10, 64); err == nil {
+				u.RSSBytes = pages * int64(os.Getpagesize())
+			}
+		}
+	}
+	return u
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package ws
+
+// processUsage is only implemented on Linux
+func processUsage(pid int) *ProcessUsage { return nil }
@@ -0,0 +1,134 @@
+package ws
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/example/rovobridge/internal/fileutil"
+	"github.com/gorilla/websocket"
+)
+
+// The checks of the prompt lint, also the codes of the warnings they raise
+const (
+	lintEmpty       = "empty"       // whitespace-only prompt text and no files
+	lintPlaceholder = "placeholder" // a {name} left in the text, e.g. of a prompt template
+	lintMissingFile = "missingFile" // a referenced file that does not exist
+	lintStaleFile   = "staleFile"   // a referenced file modified since the prompt was composed
+)
+
+// DefaultPromptLint is every lint check, on unless SetPromptLint says otherwise
+var DefaultPromptLint = []string{lintEmpty, lintPlaceholder, lintMissingFile, lintStaleFile}
+
+// LintWarning is a likely mistake found in a send before it was written to the session
+type LintWarning struct {
+	Check   string `json:"check" enum:"empty,placeholder,missingFile,staleFile"`
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty" doc:"The referenced file of missingFile and staleFile"`
+	Name    string `json:"name,omitempty" doc:"The placeholder, braces included"`
+}
+
+// placeholderPattern matches {name} outside ${...} and {{...}}, which belong to shell and
+// template code rather than an unfilled prompt template
+var placeholderPattern = regexp.MustCompile(`(^|[^${\\])(\{[A-Za-z_][A-Za-z0-9_.-]*\})`)
+
+// inlineCode matches `code` spans, whose braces are code too
+var inlineCode = regexp.MustCompile("`[^`\n]*`")
+
+// lintChecks turns a list of check names into the set the Router keeps
+func lintChecks(checks []string) (map[string]bool, error) {
+	enabled := map[string]bool{}
+	for _, c := range checks {
+		switch c = strings.TrimSpace(c); c {
+		case "":
+		case lintEmpty, lintPlaceholder, lintMissingFile, lintStaleFile:
+			enabled[c] = true
+		default:
+			return nil, fmt.Errorf("unknown prompt lint check %q", c)
+		}
+	}
+	return enabled, nil
+}
+
+// SetPromptLint picks the checks run on send; none turns the lint off
+func (r *Router) SetPromptLint(checks []string) error {
+	enabled, err := lintChecks(checks)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.promptLint = enabled
+	r.mu.Unlock()
+	return nil
+}
+
+// lintPrompt checks the text and referenced paths of a send. Files are stale when they
+// were modified after composedAt; a zero composedAt skips that check.
+func (r *Router) lintPrompt(text string, paths []string, composedAt time.Time) []LintWarning {
+	r.mu.Lock()
+	checks := r.promptLint
+	mock := r.mock != nil
+	r.mu.Unlock()
+	var warnings []LintWarning
+	if checks[lintEmpty] && text != "" && strings.TrimSpace(text) == "" && len(paths) == 0 {
+		warnings = append(warnings, LintWarning{Check: lintEmpty, Message: "the prompt is only whitespace"})
+	}
+	if checks[lintPlaceholder] {
+		for _, name := range unresolvedPlaceholders(text) {
+			warnings = append(warnings, LintWarning{Check: lintPlaceholder, Name: name, Message: name + " was not filled in"})
+		}
+	}
+	if mock || (!checks[lintMissingFile] && !checks[lintStaleFile]) {
+		return warnings
+	}
+	seen := map[string]bool{}
+	for _, p := range paths {
+		base := lineRangeSpec.ReplaceAllString(p, "")
+		if fileutil.IsSnippetPath(base) || seen[base] {
+			continue
+		}
+		seen[base] = true
+		fi, err := os.Stat(base)
+		switch {
+		case err != nil && os.IsNotExist(err):
+			if checks[lintMissingFile] {
+				warnings = append(warnings, LintWarning{Check: lintMissingFile, Path: base, Message: base + " does not exist"})
+			}
+		case err == nil && checks[lintStaleFile] && !composedAt.IsZero() && fi.ModTime().After(composedAt):
+			warnings = append(warnings, LintWarning{Check: lintStaleFile, Path: base, Message: base + " changed after the prompt was written"})
+		}
+	}
+	return warnings
+}
+
+// unresolvedPlaceholders returns the distinct {name} placeholders of text, sorted, leaving
+// out those in fenced code blocks and code spans
+func unresolvedPlaceholders(text string) []string {
+	if !strings.Contains(text, "{") {
+		return nil
+	}
+	found := map[string]bool{}
+	for i, part := range strings.Split(text, "```") {
+		if i%2 == 1 {
+			continue
+		}
+		for _, m := range placeholderPattern.FindAllStringSubmatch(inlineCode.ReplaceAllString(part, ""), -1) {
+			found[m[2]] = true
+		}
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sendPromptLint tells the client why its send was held back; resending it with
+// lintAcknowledged writes it regardless
+func sendPromptLint(conn *websocket.Conn, sid string, warnings []LintWarning) error {
+	return SendJSON(conn, map[string]any{"type": "promptLint", "sessionId": sid, "warnings": warnings})
+}
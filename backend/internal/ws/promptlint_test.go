@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouter_SendHeldBackByPromptLint(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir, "useClipboard": false})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	send := map[string]any{
+		"type": "send", "sessionId": "s1", "dataBase64": b64("fix {issue} in"),
+		"paths":      []string{file + ":1-1", filepath.Join(dir, "gone.go")},
+		"composedAt": time.Now().Add(-time.Hour).UnixMilli(),
+	}
+	_ = c.WriteJSON(send)
+	msg := readType(t, c, "promptLint")
+	var checks []string
+	for _, w := range msg["warnings"].([]any) {
+		checks = append(checks, w.(map[string]any)["check"].(string))
+	}
+	if !reflect.DeepEqual(checks, []string{"placeholder", "staleFile", "missingFile"}) {
+		t.Fatalf("unexpected warnings: %v", msg)
+	}
+	if f.stdinString() != "" {
+		t.Fatalf("a held back send reached the session: %q", f.stdinString())
+	}
+
+	send["lintAcknowledged"] = true
+	_ = c.WriteJSON(send)
+	readType(t, c, "injectResult")
+	eventually(t, "the acknowledged send to be written", func() bool { return strings.HasPrefix(f.stdinString(), "fix {issue} in") })
+}
+
+func TestRouter_PromptLintOff(t *testing.T) {
+	r, fs := newTestRouter(t)
+	if err := r.SetPromptLint(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPromptLint([]string{"spelling"}); err == nil {
+		t.Fatal("expected an unknown check to be rejected")
+	}
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "useClipboard": false})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64("  ")})
+	eventually(t, "the send to be written", func() bool { return f.stdinString() == "  " })
+}
+
+func TestUnresolvedPlaceholders(t *testing.T) {
+	text := "Rename {old} to {new}, keep ${HOME}, {{template}} and {old}.\n" +
+		"Not `fmt.Printf(\"{x}\")` either:\n```go\nm := map[string]int{a: 1}\nf\"{name}\"\n```\n{ spaced } {1st}"
+	if got := unresolvedPlaceholders(text); !reflect.DeepEqual(got, []string{"{new}", "{old}"}) {
+		t.Fatalf("unexpected placeholders: %v", got)
+	}
+}
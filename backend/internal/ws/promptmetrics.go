@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"bytes"
+	"time"
+)
+
+// PromptLatency summarizes the time from dispatching prompts to the first output after them
+type PromptLatency struct {
+	Prompts int64 `json:"prompts" doc:"Prompts whose first output was measured"`
+	LastMs  int64 `json:"lastMs"`
+	AvgMs   int64 `json:"avgMs"`
+	MaxMs   int64 `json:"maxMs"`
+}
+
+// latencyStats accumulates first-output latencies
+type latencyStats struct {
+	count int64
+	total time.Duration
+	max   time.Duration
+	last  time.Duration
+}
+
+func (l *latencyStats) add(d time.Duration) {
+	l.count++
+	l.total += d
+	l.last = d
+	l.max = max(l.max, d)
+}
+
+// summary returns the latencies in milliseconds, or nil when no prompt was measured
+func (l *latencyStats) summary() *PromptLatency {
+	if l.count == 0 {
+		return nil
+	}
+	return &PromptLatency{
+		Prompts: l.count,
+		LastMs:  l.last.Milliseconds(),
+		AvgMs:   (l.total / time.Duration(l.count)).Milliseconds(),
+		MaxMs:   l.max.Milliseconds(),
+	}
+}
+
+// promptEntryID returns the ID of the history entry a stdin or send message carries, and
+// whether it carries one, which makes it a prompt
+func promptEntryID(m map[string]any) (string, bool) {
+	entry, ok := m["historyEntry"].(map[string]any)
+	if !ok {
+		return "", false
+	}
+	id, _ := entry["id"].(string)
+	return id, true
+}
+
+// promptQueued notes the prompt a send message typed into a session; it is submitted by
+// the Enter the client sends after it
+func (st *sessionState) promptQueued(m map[string]any) {
+	id, _ := promptEntryID(m)
+	st.mu.Lock()
+	st.promptPending, st.promptID = true, id
+	st.mu.Unlock()
+}
+
+// promptInput starts timing the first output when input submits a prompt: an Enter after
+// a queued prompt, or a stdin message carrying its history entry. The terminal's echo of
+// the prompt before the Enter is not counted. It reports whether the input submitted a
+// prompt.
+func (st *sessionState) promptInput(input []byte, m map[string]any) bool {
+	id, isPrompt := promptEntryID(m)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if isPrompt {
+		st.promptPending, st.promptID = true, id
+	}
+	if st.promptPending && bytes.IndexByte(input, '\r') >= 0 {
+		st.promptPending = false
+		st.promptSentAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// firstOutputUnsafe ends the timing of the last prompt as its first output is flushed, and
+// returns the "promptMetrics" event, or nil when no prompt is being timed. Caller must
+// hold st.mu.
+func (st *sessionState) firstOutputUnsafe(sid string, now time.Time) (map[string]any, time.Duration) {
+	if st.promptSentAt.IsZero() {
+		return nil, 0
+	}
+	d := now.Sub(st.promptSentAt)
+	st.promptSentAt = time.Time{}
+	st.latency.add(d)
+	msg := map[string]any{"type": "promptMetrics", "sessionId": sid, "firstOutputMs": d.Milliseconds()}
+	if st.promptID != "" {
+		msg["promptId"] = st.promptID
+	}
+	return msg, d
+}
+
+// recordPromptLatency adds a session's first-output latency to the totals for stats
+func (r *Router) recordPromptLatency(d time.Duration) {
+	r.mu.Lock()
+	r.promptLatency.add(d)
+	r.mu.Unlock()
+}
@@ -0,0 +1,38 @@
+package ws
+
+import "testing"
+
+func TestPromptMetrics_FirstOutputLatency(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, done := dialRouter(t, r)
+	defer done()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	// The echo of the typed prompt comes before the Enter and is not timed
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64("fix the test"), "historyEntry": map[string]any{"id": "p1", "serializedContent": "fix the test"}})
+	eventually(t, "the prompt typed", func() bool { return f.stdinString() == "fix the test" })
+	f.emit("fix the test")
+	readStdout(t, c, "fix the test")
+
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("\r")})
+	eventually(t, "the Enter", func() bool { return f.stdinString() == "fix the test\r" })
+	f.emit("\r\nThinking...")
+	msg := readType(t, c, "promptMetrics")
+	if msg["sessionId"] != sessionIDOf(t, r, "s1") || msg["promptId"] != "p1" {
+		t.Fatalf("promptMetrics = %v", msg)
+	}
+	if ms, ok := msg["firstOutputMs"].(float64); !ok || ms < 0 {
+		t.Fatalf("firstOutputMs = %v", msg["firstOutputMs"])
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "getStats"})
+	stats := readType(t, c, "stats")
+	if l, _ := stats["promptLatency"].(map[string]any); l == nil || l["prompts"] != float64(1) {
+		t.Fatalf("stats = %v", stats)
+	}
+	if m := r.Metrics(); m.Sessions[0].PromptLatency == nil || m.Sessions[0].PromptLatency.Prompts != 1 {
+		t.Fatalf("metrics = %+v", m.Sessions[0])
+	}
+}
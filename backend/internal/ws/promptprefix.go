@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// workspaceSettingsPath is the checked-in settings file, relative to the workspace root
+var workspaceSettingsPath = filepath.Join(".rovobridge", "settings.json")
+
+// workspaceSettings represents the structure of .rovobridge/settings.json
+type workspaceSettings struct {
+	// PromptPrefix is written before the text of every send in the workspace, e.g. a
+	// reminder of the team's coding conventions
+	PromptPrefix string `json:"promptPrefix"`
+}
+
+// loadPromptPrefix returns the prompt prefix of the workspace, or "" when it has none
+func loadPromptPrefix(workspace string) (string, error) {
+	if workspace == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Join(workspace, workspaceSettingsPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var s workspaceSettings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return "", fmt.Errorf("parse %s: %w", workspaceSettingsPath, err)
+	}
+	return strings.TrimSpace(s.PromptPrefix), nil
+}
+
+// sessionPromptPrefix returns the prefix sends to session sid get: that of its workspace,
+// unless the session turned it off
+func (r *Router) sessionPromptPrefix(sid string, st *sessionState) string {
+	if st != nil {
+		st.mu.Lock()
+		off := st.promptPrefixOff
+		st.mu.Unlock()
+		if off {
+			return ""
+		}
+	}
+	prefix, err := loadPromptPrefix(r.sessionWorkingDir(sid))
+	if err != nil {
+		log.Printf("Failed to load the prompt prefix of session %s: %v", sid, err)
+	}
+	return prefix
+}
+
+// withPromptPrefix writes prefix before the text of a send, separated by a blank line
+func withPromptPrefix(prefix string, text []byte) []byte {
+	if prefix == "" {
+		return text
+	}
+	out := make([]byte, 0, len(prefix)+2+len(text))
+	out = append(out, prefix...)
+	if len(text) > 0 {
+		out = append(out, "\n\n"...)
+		out = append(out, text...)
+	}
+	return out
+}
+
+// setPromptPrefix turns the workspace prompt prefix on or off for session sid, or with a
+// nil enabled only reports it, answering with promptPrefix
+func (r *Router) setPromptPrefix(conn *websocket.Conn, sid string, enabled *bool) error {
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	if st == nil {
+		Errorf(conn, "setPromptPrefix: no session")
+		return nil
+	}
+	st.mu.Lock()
+	if enabled != nil {
+		st.promptPrefixOff = !*enabled
+	}
+	on := !st.promptPrefixOff
+	st.mu.Unlock()
+	if enabled != nil {
+		r.logSessionEvent(sid, "promptPrefix", map[string]any{"enabled": on})
+	}
+	prefix, err := loadPromptPrefix(r.sessionWorkingDir(sid))
+	msg := map[string]any{"type": "promptPrefix", "sessionId": sid, "enabled": on, "prefix": prefix}
+	if err != nil {
+		msg["error"] = err.Error()
+	}
+	return SendJSON(conn, msg)
+}
@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRouter_PromptPrefix(t *testing.T) {
+	r, fs := newTestRouter(t)
+	logDir := t.TempDir()
+	r.SetSessionLogDir(logDir)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	work := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(work, ".rovobridge"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, workspaceSettingsPath), []byte(`{"promptPrefix": "Use tabs.\n"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": work, "useClipboard": false})
+	sid := readType(t, c, "opened")["sessionId"].(string)
+	f := fs.last(t)
+
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64("fix it")})
+	eventually(t, "the prefixed prompt", func() bool { return f.stdinString() == "Use tabs.\\\n\\\nfix it" })
+	var sends []SessionEvent
+	eventually(t, "the send to be logged", func() bool {
+		sends = nil
+		for _, ev := range readSessionLog(t, filepath.Join(logDir, sid+".jsonl")) {
+			if ev.Event == "send" {
+				sends = append(sends, ev)
+			}
+		}
+		return len(sends) == 1
+	})
+	if sends[0].Fields["promptPrefixDigest"] != digest([]byte("Use tabs.")) || sends[0].Fields["textDigest"] != digest([]byte("fix it")) {
+		t.Fatalf("unexpected send event: %+v", sends[0])
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "setPromptPrefix", "sessionId": "s1", "enabled": false})
+	if msg := readType(t, c, "promptPrefix"); msg["enabled"] != false || msg["prefix"] != "Use tabs." {
+		t.Fatalf("unexpected promptPrefix: %v", msg)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64(" again")})
+	eventually(t, "the prompt without prefix", func() bool { return strings.HasSuffix(f.stdinString(), "fix it again") })
+}
+
+func TestLoadPromptPrefix(t *testing.T) {
+	work := t.TempDir()
+	if p, err := loadPromptPrefix(work); p != "" || err != nil {
+		t.Fatalf("expected no prefix without settings, got %q, %v", p, err)
+	}
+	if err := os.MkdirAll(filepath.Join(work, ".rovobridge"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, workspaceSettingsPath), []byte(`{`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPromptPrefix(work); err == nil {
+		t.Fatal("expected a broken settings file to be reported")
+	}
+}
@@ -0,0 +1,28 @@
+package ws
+
+import (
+	"context"
+	"io"
+
+	"github.com/example/rovobridge/internal/session"
+)
+
+// ptySession is the process session driven by the Router. *session.Session implements
+// it; tests substitute an in-process fake.
+type ptySession interface {
+	Stdin() io.Writer
+	Stdout() io.Reader
+	Resize(cols, rows int) error
+	Wait() error
+	Close() error
+	PID() int
+}
+
+// startPTYSession starts a real process session
+func startPTYSession(ctx context.Context, cfg session.Config) (ptySession, error) {
+	sess, err := session.Start(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// quickEdit is a file save of the quick editor. The save goes through only if the file
+// is still the one the client read: its hash is BaseHash, or its modification time
+// BaseMtime, when set. A file the client means to create must not exist yet.
+type quickEdit struct {
+	Path      string
+	Content   string
+	StartLine int // with EndLine, the lines Content replaces, as in applyCodeBlock; 0 = the whole file
+	EndLine   int
+	BaseHash  string
+	BaseMtime int64 // unix milliseconds
+	Create    bool
+}
+
+// contentHash is the hex SHA-256 of a file's content, the precondition of saveFile
+func contentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// readQuickEditFile reads a text file inside the working directory for the quick editor
+func readQuickEditFile(workingDir, p string) (abs string, data []byte, fi os.FileInfo, err error) {
+	abs, exists, err := resolveWritePath(workingDir, p)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if !exists {
+		return "", nil, nil, os.ErrNotExist
+	}
+	if data, err = os.ReadFile(abs); err != nil {
+		return "", nil, nil, err
+	}
+	if !utf8.Valid(data) {
+		return "", nil, nil, errors.New("not a text file")
+	}
+	if fi, err = os.Stat(abs); err != nil {
+		return "", nil, nil, err
+	}
+	return abs, data, fi, nil
+}
+
+// readFile answers readFile with the content of a file in the session's working directory
+// and the hash and modification time a later saveFile must match
+func (r *Router) readFile(conn *websocket.Conn, sid, p string) error {
+	_, data, fi, err := readQuickEditFile(r.sessionWorkingDir(sid), p)
+	if err != nil {
+		Errorf(conn, "readFile: %v", err)
+		return nil
+	}
+	return SendJSON(conn, map[string]any{
+		"type":      "fileContent",
+		"sessionId": sid,
+		"path":      p,
+		"content":   string(data),
+		"hash":      contentHash(data),
+		"mtime":     fi.ModTime().UnixMilli(),
+		"bytes":     len(data),
+	})
+}
+
+// saveFile answers saveFile: if its preconditions hold it asks for confirmation, like
+// every write to the workspace, then writes the file; when another writer got there
+// first, before or while the confirmation was pending, it answers with fileConflict and
+// the current hash and modification time. The last check and the write are serialized
+// with other saves and the confirmed writes of applyCodeBlock and replaceInFiles, so no
+// write slips between them and two clients saving from the same read cannot both succeed.
+func (r *Router) saveFile(conn *websocket.Conn, sid string, edit quickEdit) error {
+	if !edit.Create && edit.BaseHash == "" && edit.BaseMtime == 0 {
+		Errorf(conn, "saveFile: baseHash or baseMtime from readFile is required")
+		return nil
+	}
+	abs, exists, old, ok := r.checkSaveFile(conn, sid, edit)
+	if !ok {
+		return nil
+	}
+	updated, err := spliceLines(string(old), edit.Content, edit.StartLine, edit.EndLine)
+	if err != nil {
+		Errorf(conn, "saveFile: %v", err)
+		return nil
+	}
+	if len(updated) > maxApplyFileBytes {
+		Errorf(conn, "saveFile: file is too large to edit")
+		return nil
+	}
+	verb := "Save"
+	if !exists {
+		verb = "Create"
+	}
+	summary := fmt.Sprintf("%s %s (%d bytes)", verb, edit.Path, len(updated))
+	return r.requireConfirmation(conn, "saveFile", summary, func() error {
+		r.fileWriteMu.Lock()
+		defer r.fileWriteMu.Unlock()
+		// The file must still be the one the save was checked against
+		nowAbs, nowExists, now, ok := r.checkSaveFile(conn, sid, edit)
+		if !ok {
+			return nil
+		}
+		if nowAbs != abs || nowExists != exists || !bytes.Equal(now, old) {
+			fields := map[string]any{"sessionId": sid, "path": edit.Path, "exists": nowExists}
+			ErrorCode(conn, "fileConflict", fields, "saveFile: %s changed while the save awaited confirmation", edit.Path)
+			return nil
+		}
+		if err := writeFileAtomic(abs, []byte(updated)); err != nil {
+			Errorf(conn, "saveFile: %v", err)
+			return nil
+		}
+		fi, err := os.Stat(abs)
+		if err != nil {
+			Errorf(conn, "saveFile: %v", err)
+			return nil
+		}
+		r.logSessionEvent(sid, "fileSaved", map[string]any{"path": edit.Path, "bytes": len(updated), "created": !exists})
+		return SendJSON(conn, map[string]any{
+			"type":      "fileSaved",
+			"sessionId": sid,
+			"path":      edit.Path,
+			"created":   !exists,
+			"hash":      contentHash([]byte(updated)),
+			"mtime":     fi.ModTime().UnixMilli(),
+			"bytes":     len(updated),
+		})
+	})
+}
+
+// checkSaveFile resolves the file of a save and checks its preconditions, answering
+// conn when they fail. It returns the file, whether it exists and its content.
+func (r *Router) checkSaveFile(conn *websocket.Conn, sid string, edit quickEdit) (string, bool, []byte, bool) {
+	abs, exists, err := resolveWritePath(r.sessionWorkingDir(sid), edit.Path)
+	if err != nil {
+		Errorf(conn, "saveFile: %v", err)
+		return "", false, nil, false
+	}
+	var old []byte
+	fields := map[string]any{"sessionId": sid, "path": edit.Path, "exists": exists}
+	if exists {
+		_, data, fi, err := readQuickEditFile(r.sessionWorkingDir(sid), edit.Path)
+		if err != nil {
+			Errorf(conn, "saveFile: %v", err)
+			return "", false, nil, false
+		}
+		old = data
+		hash, mtime := contentHash(data), fi.ModTime().UnixMilli()
+		fields["currentHash"], fields["currentMtime"] = hash, mtime
+		if edit.Create || (edit.BaseHash != "" && edit.BaseHash != hash) || (edit.BaseMtime != 0 && edit.BaseMtime != mtime) {
+			ErrorCode(conn, "fileConflict", fields, "saveFile: %s changed since it was read", edit.Path)
+			return "", false, nil, false
+		}
+	} else if !edit.Create {
+		ErrorCode(conn, "fileConflict", fields, "saveFile: %s was removed since it was read", edit.Path)
+		return "", false, nil, false
+	}
+	return abs, exists, old, true
+}
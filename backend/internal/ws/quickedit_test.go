@@ -0,0 +1,117 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRouter_QuickEditReadSaveConflict(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(target, []byte("one\ntwo\nthree\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir})
+	readType(t, c, "opened")
+
+	_ = c.WriteJSON(map[string]any{"type": "readFile", "sessionId": "s1", "path": "notes.md"})
+	read := readType(t, c, "fileContent")
+	if read["content"] != "one\ntwo\nthree\n" || read["hash"] != contentHash([]byte("one\ntwo\nthree\n")) {
+		t.Fatalf("unexpected fileContent: %v", read)
+	}
+
+	// A save from the read goes through and replaces only the given line
+	save := map[string]any{"type": "saveFile", "sessionId": "s1", "path": "notes.md", "content": "TWO\n", "startLine": 2, "endLine": 2, "baseHash": read["hash"]}
+	_ = c.WriteJSON(save)
+	if b, _ := os.ReadFile(target); string(b) != "one\ntwo\nthree\n" {
+		t.Fatalf("save wrote the file before confirmation: %q", b)
+	}
+	confirmNext(t, c, "saveFile")
+	saved := readType(t, c, "fileSaved")
+	if b, _ := os.ReadFile(target); string(b) != "one\nTWO\nthree\n" {
+		t.Fatalf("unexpected file after save: %q", b)
+	}
+	if saved["hash"] != contentHash([]byte("one\nTWO\nthree\n")) {
+		t.Fatalf("unexpected fileSaved: %v", saved)
+	}
+
+	// A second save from the same read lost the race
+	save["content"] = "2\n"
+	_ = c.WriteJSON(save)
+	e := readType(t, c, "error")
+	if e["code"] != "fileConflict" || e["currentHash"] != saved["hash"] {
+		t.Fatalf("expected fileConflict with the current hash, got %v", e)
+	}
+	if b, _ := os.ReadFile(target); string(b) != "one\nTWO\nthree\n" {
+		t.Fatalf("conflicting save wrote the file: %q", b)
+	}
+
+	// Saves need a precondition, and create refuses existing files
+	_ = c.WriteJSON(map[string]any{"type": "saveFile", "sessionId": "s1", "path": "notes.md", "content": "x"})
+	readType(t, c, "error")
+	_ = c.WriteJSON(map[string]any{"type": "saveFile", "sessionId": "s1", "path": "notes.md", "content": "x", "create": true})
+	if e := readType(t, c, "error"); e["code"] != "fileConflict" {
+		t.Fatalf("expected create over an existing file to conflict, got %v", e)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "saveFile", "sessionId": "s1", "path": "new.md", "content": "fresh\n", "create": true})
+	confirmNext(t, c, "saveFile")
+	if saved := readType(t, c, "fileSaved"); saved["created"] != true {
+		t.Fatalf("expected the file to be created: %v", saved)
+	}
+}
+
+func TestRouter_QuickEditStaysInWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir})
+	readType(t, c, "opened")
+
+	_ = c.WriteJSON(map[string]any{"type": "readFile", "sessionId": "s1", "path": outside})
+	readType(t, c, "error")
+	_ = c.WriteJSON(map[string]any{"type": "saveFile", "sessionId": "s1", "path": "../secret.txt", "content": "x", "create": true})
+	readType(t, c, "error")
+	if b, _ := os.ReadFile(outside); string(b) != "secret" {
+		t.Fatalf("file outside the workspace changed: %q", b)
+	}
+}
+
+func TestRouter_SaveFileConflictWhileConfirming(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(target, []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir})
+	readType(t, c, "opened")
+
+	_ = c.WriteJSON(map[string]any{"type": "saveFile", "sessionId": "s1", "path": "notes.md", "content": "mine\n", "baseHash": contentHash([]byte("one\n"))})
+	challenge := readType(t, c, "confirmationRequired")
+	if !strings.Contains(challenge["summary"].(string), "notes.md") {
+		t.Fatalf("unexpected challenge: %v", challenge)
+	}
+	// Another writer gets there while the save waits for confirmation
+	if err := os.WriteFile(target, []byte("theirs\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "confirm", "token": challenge["token"], "approved": true})
+	if e := readType(t, c, "error"); e["code"] != "fileConflict" {
+		t.Fatalf("expected fileConflict, got %v", e)
+	}
+	if b, _ := os.ReadFile(target); string(b) != "theirs\n" {
+		t.Fatalf("confirmed save overwrote a newer file: %q", b)
+	}
+}
@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+const (
+	// defaultReadyIdle is how long a starting process must stay quiet after printing
+	// something before it counts as ready for input
+	defaultReadyIdle = 1500 * time.Millisecond
+	// maxReadyWait marks a process ready when its startup output never pauses, or when it
+	// prints nothing at all
+	maxReadyWait = 15 * time.Second
+)
+
+// startupState follows a process from its start until it is ready for input: the first
+// pause of readyIdle in its output, a line matching the client's prompt marker, or
+// maxReadyWait, whichever comes first. Output before then is the startup banner, which is
+// left out of the stdout stream and the snapshot when suppress is set.
+type startupState struct {
+	pending    bool
+	started    time.Time
+	idle       time.Duration
+	marker     *regexp.Regexp
+	suppress   bool
+	suppressed int64 // banner bytes not forwarded
+	idleTimer  *time.Timer
+	deadline   *time.Timer
+}
+
+// startupOptions reads the readiness options of openSession:
+// { readyPattern?: string, readyIdleMs?: number, suppressBanner?: bool }
+func startupOptions(m map[string]any) (startupState, error) {
+	s := startupState{idle: defaultReadyIdle}
+	if ms := asInt(m["readyIdleMs"]); ms > 0 {
+		s.idle = time.Duration(ms) * time.Millisecond
+	}
+	if p, _ := m["readyPattern"].(string); p != "" {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return s, err
+		}
+		s.marker = re
+	}
+	s.suppress, _ = m["suppressBanner"].(bool)
+	return s, nil
+}
+
+// beginStartupUnsafe starts following the startup of the process owning ctx. Caller must
+// hold st.mu.
+func (r *Router) beginStartupUnsafe(ctx context.Context, sid string, st *sessionState, s startupState) {
+	st.startup.stopUnsafe()
+	s.pending = true
+	s.started = time.Now()
+	s.deadline = time.AfterFunc(maxReadyWait, func() { r.markReady(ctx, sid, st, "timeout") })
+	st.startup = s
+}
+
+// stopUnsafe ends the wait for readiness, e.g. when the process exits or is replaced
+func (s *startupState) stopUnsafe() {
+	s.pending = false
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+		s.idleTimer = nil
+	}
+	if s.deadline != nil {
+		s.deadline.Stop()
+		s.deadline = nil
+	}
+}
+
+// startupOutputUnsafe takes a chunk of output printed while the process is starting. It
+// reports whether the chunk belongs to the suppressed banner, and returns the ready
+// message when the chunk completes the prompt marker; that chunk is forwarded, so the
+// prompt shows. Caller must hold st.mu.
+func (r *Router) startupOutputUnsafe(ctx context.Context, sid string, st *sessionState, lines []plainLine) (suppress bool, ready map[string]any) {
+	s := &st.startup
+	if !s.pending {
+		return false, nil
+	}
+	if s.marker != nil {
+		matched := s.marker.MatchString(st.mirror.pending())
+		for _, l := range lines {
+			matched = matched || s.marker.MatchString(l.text)
+		}
+		if matched {
+			return false, st.finishStartupUnsafe(sid, "prompt")
+		}
+	}
+	if s.idleTimer == nil {
+		s.idleTimer = time.AfterFunc(s.idle, func() { r.markReady(ctx, sid, st, "idle") })
+	} else {
+		s.idleTimer.Reset(s.idle)
+	}
+	return s.suppress, nil
+}
+
+// finishStartupUnsafe marks the process ready and returns the ready message. Caller must
+// hold st.mu.
+func (st *sessionState) finishStartupUnsafe(sid, reason string) map[string]any {
+	s := &st.startup
+	msg := map[string]any{
+		"type":      "ready",
+		"sessionId": sid,
+		"reason":    reason,
+		"afterMs":   time.Since(s.started).Milliseconds(),
+	}
+	if s.suppressed > 0 {
+		msg["suppressedBytes"] = s.suppressed
+	}
+	s.stopUnsafe()
+	return msg
+}
+
+// markReady marks the process owning ctx ready when it is still starting
+func (r *Router) markReady(ctx context.Context, sid string, st *sessionState, reason string) {
+	st.mu.Lock()
+	if st.staleUnsafe(ctx) || !st.startup.pending {
+		st.mu.Unlock()
+		return
+	}
+	msg := st.finishStartupUnsafe(sid, reason)
+	st.mu.Unlock()
+	r.announceReady(ctx, sid, st, msg)
+}
+
+// announceReady sends a ready message after the output before it, and keeps it for
+// clients that attach later
+func (r *Router) announceReady(ctx context.Context, sid string, st *sessionState, msg map[string]any) {
+	r.flushSession(ctx, sid, st)
+	r.recordEvent(sid, msg)
+	r.logSessionEvent(sid, "ready", map[string]any{"reason": msg["reason"], "afterMs": msg["afterMs"]})
+	st.mu.Lock()
+	c := st.currentConn
+	st.mu.Unlock()
+	if c != nil {
+		_ = SendJSON(c, msg)
+	}
+	r.sendCarryOver(ctx, sid, st)
+}
@@ -0,0 +1,76 @@
+package ws
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestRouter_ReadyOnPromptMarkerWithBannerSuppressed(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "readyPattern": `^>$`, "suppressBanner": true, "readyIdleMs": 60000})
+	readType(t, c, "opened")
+	f := fs.last(t)
+	f.emit("Welcome to the agent v1.2\nLoading tools...\n")
+	f.emit("> ")
+	ready := readType(t, c, "ready")
+	if ready["reason"] != "prompt" || ready["suppressedBytes"] != float64(len("Welcome to the agent v1.2\nLoading tools...\n")) {
+		t.Fatalf("unexpected ready: %v", ready)
+	}
+
+	// Output after the banner flows, at its absolute stream offset
+	f.emit("answer\n")
+	msg := readType(t, c, "stdout")
+	data, _ := base64.StdEncoding.DecodeString(msg["dataBase64"].(string))
+	if string(data) != "answer\n" || msg["offset"] != float64(len("Welcome to the agent v1.2\nLoading tools...\n> ")) {
+		t.Fatalf("unexpected output %q at %v", data, msg["offset"])
+	}
+
+	// The banner is kept out of the snapshot too
+	_ = c.WriteJSON(map[string]any{"type": "snapshot", "sessionId": "s1"})
+	snap := readType(t, c, "snapshot")
+	data, _ = base64.StdEncoding.DecodeString(snap["dataBase64"].(string))
+	if strings.Contains(string(data), "Welcome") || !strings.Contains(string(data), "answer") {
+		t.Fatalf("unexpected snapshot: %q", data)
+	}
+}
+
+func TestRouter_ReadyWhenStartupOutputPauses(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "readyIdleMs": 50})
+	readType(t, c, "opened")
+	fs.last(t).emit("banner\n")
+	readStdout(t, c, "banner")
+	if ready := readType(t, c, "ready"); ready["reason"] != "idle" || ready["suppressedBytes"] != nil {
+		t.Fatalf("unexpected ready: %v", ready)
+	}
+
+	// A resuming client learns it from the replayed events
+	c2, close2 := dialRouter(t, r)
+	defer close2()
+	_ = c2.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "resume": true})
+	if opened := readType(t, c2, "opened"); opened["starting"] != nil {
+		t.Fatalf("resumed session still starting: %v", opened)
+	}
+	readType(t, c2, "ready")
+}
+
+func TestRouter_BadReadyPattern(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "readyPattern": "(unclosed"})
+	if msg := readType(t, c, "error"); !strings.Contains(msg["message"].(string), "readyPattern") {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+	if len(fs.started) != 0 {
+		t.Fatal("session started despite the bad pattern")
+	}
+}
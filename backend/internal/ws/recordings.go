@@ -0,0 +1,177 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/example/rovobridge/internal/recording"
+)
+
+// SetRecordingDir turns on recording of new sessions as asciicast files in dir; call it
+// before serving. An empty dir leaves recording off.
+func (r *Router) SetRecordingDir(dir string) {
+	r.mu.Lock()
+	r.recordDir = dir
+	r.mu.Unlock()
+}
+
+// SetInputRecordingDir turns on recording of what is written to new sessions' stdin in
+// dir, secrets masked with the redaction patterns, for replay-input; call it before
+// serving. An empty dir leaves it off.
+func (r *Router) SetInputRecordingDir(dir string) {
+	r.mu.Lock()
+	r.inputRecordDir = dir
+	r.mu.Unlock()
+}
+
+// spinnerFrameInterval is the rate at which recordings keep frames of a long-running
+// spinner or progress bar when spinner collapsing is on
+const spinnerFrameInterval = 500 * time.Millisecond
+
+// SetCollapseSpinners drops spinner and progress redraws that a later frame replaces from
+// the replay buffers and recordings of new sessions, keeping snapshots compact. The live
+// stdout stream is unchanged.
+func (r *Router) SetCollapseSpinners(on bool) {
+	r.mu.Lock()
+	r.collapseSpinners = on
+	r.mu.Unlock()
+}
+
+// startRecording opens the recording of a new session, or returns nil when recording is
+// off or the file cannot be created
+func (r *Router) startRecording(sid string, cols, rows int, cmd string, args []string) *recording.Writer {
+	r.mu.Lock()
+	dir := r.recordDir
+	collapse := r.collapseSpinners
+	r.mu.Unlock()
+	if dir == "" {
+		return nil
+	}
+	rec, err := recording.Create(dir, sid, cols, rows, strings.TrimSpace(cmd+" "+strings.Join(args, " ")))
+	if err != nil {
+		log.Printf("recording: %v", err)
+		return nil
+	}
+	if collapse {
+		rec.CollapseFrames(spinnerFrameInterval)
+	}
+	return rec
+}
+
+// startInputRecording opens the input recording of a new session, or returns nil when
+// input recording is off or the file cannot be created
+func (r *Router) startInputRecording(sid, cmd string, args []string) *recording.InputWriter {
+	r.mu.Lock()
+	dir := r.inputRecordDir
+	f := r.redactFilter
+	r.mu.Unlock()
+	if dir == "" {
+		return nil
+	}
+	var mask func([]byte) []byte
+	if f != nil {
+		mask = f.Redact
+	}
+	rec, err := recording.CreateInput(dir, sid, strings.TrimSpace(cmd+" "+strings.Join(args, " ")), mask)
+	if err != nil {
+		log.Printf("recording: %v", err)
+		return nil
+	}
+	return rec
+}
+
+// inputRecordedSession records what is written to the stdin of the session it wraps
+type inputRecordedSession struct {
+	ptySession
+	stdin recordedStdin
+}
+
+type recordedStdin struct {
+	w   io.Writer
+	rec *recording.InputWriter
+}
+
+func (s recordedStdin) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.rec.Input(p[:n])
+	return n, err
+}
+
+func (s *inputRecordedSession) Stdin() io.Writer { return s.stdin }
+
+// recordInputSession wraps sess to record its input in rec, or returns it as is when rec
+// is nil
+func recordInputSession(sess ptySession, rec *recording.InputWriter) ptySession {
+	if rec == nil {
+		return sess
+	}
+	return &inputRecordedSession{ptySession: sess, stdin: recordedStdin{w: sess.Stdin(), rec: rec}}
+}
+
+// stopRecordingUnsafe closes the session's recordings, if any. Caller must hold st.mu.
+func (st *sessionState) stopRecordingUnsafe() {
+	if st.recorder != nil {
+		if err := st.recorder.Close(); err != nil {
+			log.Printf("recording: %v", err)
+		}
+		st.recorder = nil
+	}
+	if st.inputRecorder != nil {
+		if err := st.inputRecorder.Close(); err != nil {
+			log.Printf("recording: %v", err)
+		}
+		st.inputRecorder = nil
+	}
+}
+
+// WriteRecordingList writes the recordings for the /recordings HTTP endpoint
+func (r *Router) WriteRecordingList(w http.ResponseWriter) {
+	r.mu.Lock()
+	dir := r.recordDir
+	r.mu.Unlock()
+	list := []recording.Info{}
+	if dir != "" {
+		var err error
+		if list, err = recording.List(dir); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"recordings": list})
+}
+
+// WriteRecording streams the recording called name for the /recordings/<name> HTTP
+// endpoint as asciicast v2, from seek seconds on and with times divided by speed
+func (r *Router) WriteRecording(w http.ResponseWriter, name string, seek, speed float64) {
+	r.mu.Lock()
+	dir := r.recordDir
+	r.mu.Unlock()
+	if dir == "" {
+		http.Error(w, "recording is disabled", http.StatusNotFound)
+		return
+	}
+	f, err := recording.Open(dir, name)
+	switch {
+	case errors.Is(err, recording.ErrInvalidName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "no such recording", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	if err := recording.Play(w, f, seek, speed); err != nil {
+		log.Printf("recording playback error: %v", err)
+	}
+}
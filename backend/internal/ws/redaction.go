@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/example/rovobridge/internal/redact"
+	"github.com/gorilla/websocket"
+)
+
+// redactHoldDelay is how long redaction holds back an unfinished line, waiting for the
+// rest of a secret, before passing it on
+const redactHoldDelay = 50 * time.Millisecond
+
+// SetRedaction configures the secret patterns masked in session output, and whether
+// masking starts enabled. Clients can toggle it with setRedaction.
+func (r *Router) SetRedaction(f *redact.Filter, enabled bool) {
+	r.mu.Lock()
+	r.redactFilter = f
+	r.mu.Unlock()
+	if enabled {
+		r.redaction.Store(f)
+	} else {
+		r.redaction.Store(nil)
+	}
+}
+
+// outputSource tells ingestOutput where a chunk of output comes from
+type outputSource uint8
+
+const (
+	outputRead        outputSource = iota // read from the process
+	outputHoldTimeout                     // redaction held an unfinished line for redactHoldDelay
+	outputEOF                             // the process closed its output
+)
+
+// redactUnsafe masks secrets in a chunk of output read from the process, or returns the
+// bytes held back once the hold delay has passed or the output ended. Caller must hold
+// st.mu.
+func (r *Router) redactUnsafe(ctx context.Context, sid string, st *sessionState, p []byte, src outputSource) []byte {
+	f := r.redaction.Load()
+	switch src {
+	case outputEOF:
+		return st.redactor.Flush(f)
+	case outputHoldTimeout:
+		// A later read may have taken the held bytes or held new ones more recently
+		if !st.redactor.Held() || time.Since(st.redactHeldAt) < redactHoldDelay {
+			return nil
+		}
+		return st.redactor.Flush(f)
+	}
+	p = st.redactor.Write(f, p)
+	if st.redactor.Held() {
+		st.redactHeldAt = time.Now()
+		if st.redactTimer == nil {
+			st.redactTimer = time.AfterFunc(redactHoldDelay, func() {
+				r.ingestOutput(ctx, sid, st, nil, outputHoldTimeout)
+			})
+		} else {
+			st.redactTimer.Reset(redactHoldDelay)
+		}
+	}
+	return p
+}
+
+// setRedaction turns masking of secrets in session output on or off. Turning it off
+// exposes secrets to everyone watching, so it needs confirmation.
+func (r *Router) setRedaction(conn *websocket.Conn, enabled bool) error {
+	r.mu.Lock()
+	f := r.redactFilter
+	r.mu.Unlock()
+	if f == nil {
+		ErrorCode(conn, "redactionNotConfigured", nil, "setRedaction: no redaction patterns are configured")
+		return nil
+	}
+	apply := func() error {
+		if enabled {
+			r.redaction.Store(f)
+		} else {
+			r.redaction.Store(nil)
+		}
+		return SendJSON(conn, map[string]any{"type": "redaction", "enabled": enabled, "patterns": f.Len()})
+	}
+	if enabled || r.redaction.Load() == nil {
+		return apply()
+	}
+	return r.requireConfirmation(conn, "setRedaction", "Show secrets in session output unmasked", apply)
+}
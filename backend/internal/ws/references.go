@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/example/rovobridge/internal/history"
+	"github.com/example/rovobridge/internal/index"
+)
+
+// maxReferenceCandidates bounds the candidates listed for an ambiguous chip
+const maxReferenceCandidates = 10
+
+// ChipResolution is what became of one chip of serialized prompt content
+type ChipResolution struct {
+	Chip       string   `json:"chip" doc:"The markup as written"`
+	Path       string   `json:"path" doc:"The referenced path, line range removed"`
+	LineRange  string   `json:"lineRange,omitempty" doc:"The :start-end suffix of the chip"`
+	Status     string   `json:"status" enum:"ok,moved,ambiguous,missing,invalid"`
+	Resolved   string   `json:"resolved,omitempty" doc:"Path relative to the root of the file referenced or, for moved, of the file it most likely became"`
+	Candidates []string `json:"candidates,omitempty" doc:"Indexed files of the same name, closest first, for an ambiguous chip"`
+	Reason     string   `json:"reason,omitempty" doc:"Why an invalid chip cannot be resolved"`
+}
+
+// resolveReferences checks each chip of serialized prompt content against the index
+// rooted at root. A chip whose file is gone is matched with an indexed file of the same
+// name: the one sharing the most trailing directories with it is where it moved, unless
+// several tie. It returns the results, in the order of the chips, and the content with
+// the chips of moved files pointing at their new place.
+func resolveReferences(snap index.Snapshot, root, content string) ([]ChipResolution, string) {
+	chips := history.ParseChips(content)
+	out := make([]ChipResolution, 0, len(chips))
+	var b strings.Builder
+	last := 0
+	for _, c := range chips {
+		res, rewritten := resolveChip(snap, root, c)
+		res.Chip = content[c.Start:c.End]
+		out = append(out, res)
+		if rewritten != "" {
+			b.WriteString(content[last:c.Start])
+			b.WriteString(rewritten)
+			last = c.End
+		}
+	}
+	if last == 0 {
+		return out, content
+	}
+	b.WriteString(content[last:])
+	return out, b.String()
+}
+
+// resolveChip resolves one chip, returning the markup replacing it when its file moved
+func resolveChip(snap index.Snapshot, root string, c history.Chip) (ChipResolution, string) {
+	res := ChipResolution{Path: c.Path, Status: "invalid"}
+	if loc := lineRangeSpec.FindStringIndex(c.Path); loc != nil {
+		res.Path, res.LineRange = c.Path[:loc[0]], c.Path[loc[0]:]
+		start, end, _ := strings.Cut(res.LineRange[1:], "-")
+		s, _ := strconv.Atoi(start)
+		e, _ := strconv.Atoi(end)
+		if s > e {
+			res.Reason = "the line range ends before it starts"
+			return res, ""
+		}
+	}
+	if strings.TrimSpace(res.Path) == "" {
+		res.Reason = "the path is empty"
+		return res, ""
+	}
+	np := snap.NormalizePath(root, res.Path)
+	if np.Absolute == "" {
+		res.Reason = "the path cannot name a file on this system"
+		return res, ""
+	}
+	if np.Exists {
+		res.Status, res.Resolved = "ok", np.Path
+		return res, ""
+	}
+	candidates := movedCandidates(snap, np)
+	switch {
+	case len(candidates) == 0:
+		res.Status = "missing"
+		return res, ""
+	case len(candidates) > 1 && trailingMatch(np.Absolute, candidates[0]) == trailingMatch(np.Absolute, candidates[1]):
+		res.Status = "ambiguous"
+		res.Candidates = candidates[:min(len(candidates), maxReferenceCandidates)]
+		return res, ""
+	}
+	res.Status, res.Resolved = "moved", candidates[0]
+	path := candidates[0]
+	if filepath.IsAbs(res.Path) {
+		if rootAbs, err := filepath.Abs(root); err == nil {
+			path = filepath.Join(rootAbs, path)
+		}
+	}
+	return res, history.FormatChip(path+res.LineRange, c.Display)
+}
+
+// movedCandidates returns the indexed files named like the missing np, those sharing the
+// most trailing directories with it first
+func movedCandidates(snap index.Snapshot, np index.NormalizedPath) []string {
+	var out []string
+	for _, e := range snap.ByName(filepath.Base(np.Absolute)) {
+		if !e.IsDir {
+			out = append(out, e.Path)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		si, sj := trailingMatch(np.Absolute, out[i]), trailingMatch(np.Absolute, out[j])
+		if si != sj {
+			return si > sj
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// trailingMatch counts the trailing path elements a and b have in common
+func trailingMatch(a, b string) int {
+	pa := strings.Split(filepath.ToSlash(filepath.Clean(a)), "/")
+	pb := strings.Split(filepath.ToSlash(filepath.Clean(b)), "/")
+	n := 0
+	for n < len(pa) && n < len(pb) && pa[len(pa)-1-n] == pb[len(pb)-1-n] {
+		n++
+	}
+	return n
+}
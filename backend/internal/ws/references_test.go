@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/example/rovobridge/internal/index"
+)
+
+func TestResolveReferences(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"src/app/main.go", "lib/util.go", "pkg/a/util.go", "README.md"} {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap := index.NewSnapshot([]index.Entry{
+		{Path: filepath.FromSlash("src/app/main.go"), Name: "main.go"},
+		{Path: filepath.FromSlash("lib/util.go"), Name: "util.go"},
+		{Path: filepath.FromSlash("pkg/a/util.go"), Name: "util.go"},
+		{Path: "README.md", Name: "README.md"},
+	})
+
+	content := "<[#README.md][README.md]> then <[#" + filepath.Join(root, "app", "main.go") + ":3-9][main.go:3-9]>" +
+		" and <[#old/util.go]> <[#gone.go][gone.go]> <[#x.go:9-3]>"
+	refs, rewritten := resolveReferences(snap, root, content)
+	var statuses []string
+	for _, ref := range refs {
+		statuses = append(statuses, ref.Status)
+	}
+	if !reflect.DeepEqual(statuses, []string{"ok", "moved", "ambiguous", "missing", "invalid"}) {
+		t.Fatalf("unexpected resolutions: %+v", refs)
+	}
+	if refs[1].Resolved != filepath.FromSlash("src/app/main.go") || refs[1].LineRange != ":3-9" {
+		t.Fatalf("unexpected move: %+v", refs[1])
+	}
+	if len(refs[2].Candidates) != 2 {
+		t.Fatalf("expected both util.go files as candidates, got %+v", refs[2])
+	}
+	want := "<[#README.md][README.md]> then <[#" + filepath.Join(root, "src", "app", "main.go") + ":3-9][main.go:3-9]>" +
+		" and <[#old/util.go]> <[#gone.go][gone.go]> <[#x.go:9-3]>"
+	if rewritten != want {
+		t.Fatalf("unexpected rewrite:\n%s\nwant\n%s", rewritten, want)
+	}
+}
+
+func TestRouter_ResolveReferences(t *testing.T) {
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "resolveReferences", "serializedContent": "<[#router.go][router.go]> explain"})
+	msg := readType(t, c, "resolvedReferences")
+	refs, _ := msg["references"].([]any)
+	if len(refs) != 1 || refs[0].(map[string]any)["status"] != "ok" || msg["changed"] != false {
+		t.Fatalf("unexpected resolvedReferences: %v", msg)
+	}
+}
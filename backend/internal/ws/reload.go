@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"context"
+	"encoding/base64"
+	"maps"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultReloadTailLines is the transcript reloadAgent sends back unless asked otherwise
+	defaultReloadTailLines = 200
+	// defaultCarryOverLines is the length of the summary reloadAgent reinjects
+	defaultCarryOverLines = 40
+)
+
+// carryOverKey carries the summary a reloadAgent reinjects into the openSession it makes.
+// Its value is only ever set by the bridge: a client's JSON cannot hold the key.
+const carryOverKey = "\x00carryOver"
+
+// openRequestOf returns the options of an openSession message to start the same command
+// again with
+func openRequestOf(m map[string]any) map[string]any {
+	req := maps.Clone(m)
+	delete(req, carryOverKey)
+	delete(req, broadcastKey)
+	return req
+}
+
+// reloadAgent restarts the process of a session, for an agent CLI stuck in a bad state:
+// the command starts again with the options of the openSession that started it, and the
+// client gets opened for the new process, then agentReloaded with the last output of the
+// old one as plain text. With reinject, a condensed summary of that output is sent as the
+// first prompt once the new process is ready, so the agent keeps some of its context.
+func (r *Router) reloadAgent(ctx context.Context, conn *websocket.Conn, sid string, m map[string]any) error {
+	r.mu.Lock()
+	old := r.sessions[sid]
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	if old == nil || st == nil {
+		Errorf(conn, "reloadAgent: no session")
+		return nil
+	}
+	if r.rejectHandedOff(conn, sid, st) {
+		return nil
+	}
+	tailLines := asInt(m["tailLines"])
+	if tailLines <= 0 {
+		tailLines = defaultReloadTailLines
+	}
+	tailLines = min(tailLines, maxOutputTailLines)
+	summaryLines := asInt(m["summaryLines"])
+	if summaryLines <= 0 {
+		summaryLines = defaultCarryOverLines
+	}
+	summaryLines = min(summaryLines, maxOutputTailLines)
+	reinject, _ := m["reinject"].(bool)
+
+	st.mu.Lock()
+	transcript := outputTail(st.replay, tailLines)
+	open := maps.Clone(st.openRequest)
+	cols, rows := st.liveness.cols, st.liveness.rows
+	st.mu.Unlock()
+	if open == nil {
+		Errorf(conn, "reloadAgent: the session was not started by openSession")
+		return nil
+	}
+	open["type"] = "openSession"
+	open["sessionId"] = sid
+	delete(open, "resume")
+	if cols > 0 && rows > 0 {
+		// the size the terminal was last resized to
+		open["cols"], open["rows"] = cols, rows
+	}
+	summary := ""
+	if reinject {
+		summary = carryOverPrompt(transcript, summaryLines)
+		if summary != "" {
+			open[carryOverKey] = summary
+		}
+	}
+
+	r.logSessionEvent(sid, "reloading", map[string]any{"pid": old.PID(), "transcriptBytes": len(transcript), "reinject": summary != ""})
+	if err := r.handle(ctx, conn, open); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	sess := r.sessions[sid]
+	r.mu.Unlock()
+	if sess == nil || sess == old {
+		// openSession failed and said why
+		return nil
+	}
+	return SendJSON(conn, map[string]any{
+		"type":       "agentReloaded",
+		"sessionId":  sid,
+		"pid":        sess.PID(),
+		"transcript": transcript,
+		"lines":      lineCount(transcript),
+		"reinjected": summary != "",
+	})
+}
+
+// carryOverPrompt condenses a transcript into the prompt reinjected after a reload: its
+// last n non-blank lines, without the repeats of redrawn status lines
+func carryOverPrompt(transcript string, n int) string {
+	var lines []string
+	for _, ln := range strings.Split(transcript, "\n") {
+		ln = strings.TrimRight(ln, " \t")
+		if strings.TrimSpace(ln) == "" || (len(lines) > 0 && lines[len(lines)-1] == ln) {
+			continue
+		}
+		lines = append(lines, ln)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return "The previous session was restarted. Its last output, for context:\n```\n" + strings.Join(lines, "\n") + "\n```\n"
+}
+
+// sendCarryOver sends the summary a reloadAgent left for the session's new process, once
+// it is ready
+func (r *Router) sendCarryOver(ctx context.Context, sid string, st *sessionState) {
+	st.mu.Lock()
+	summary, c := st.carryOver, st.currentConn
+	st.carryOver = ""
+	st.mu.Unlock()
+	if summary == "" || c == nil {
+		return
+	}
+	send := map[string]any{"type": "send", "sessionId": sid, "dataBase64": base64.StdEncoding.EncodeToString([]byte(summary))}
+	go r.handleSafely(ctx, c, send)
+}
+
+// lineCount is the number of lines of s
+func lineCount(s string) int {
+	if s == "" {
+		return 0
+	}
+	return strings.Count(s, "\n") + 1
+}
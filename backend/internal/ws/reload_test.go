@@ -0,0 +1,90 @@
+package ws
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadAgent_RestartsWithTranscriptAndReinjects(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	dir := t.TempDir()
+	_ = c.WriteJSON(map[string]any{
+		"type": "openSession", "id": "s1", "cmd": "agent", "args": []string{"run"}, "cwd": dir, "useClipboard": false, "readyIdleMs": 50,
+	})
+	opened := readType(t, c, "opened")
+	sid := opened["sessionId"].(string)
+	first := fs.last(t)
+	first.emit("> fix the build\nEditing main.go\nEditing main.go\n\nError: tool call failed\n")
+	readStdout(t, c, "tool call failed")
+	readType(t, c, "ready")
+
+	_ = c.WriteJSON(map[string]any{"type": "reloadAgent", "sessionId": "s1", "reinject": true})
+	if again := readType(t, c, "opened"); again["sessionId"] != sid || again["id"] != "s1" || again["resumed"] != false {
+		t.Fatalf("unexpected opened: %v", again)
+	}
+	reloaded := readType(t, c, "agentReloaded")
+	transcript, _ := reloaded["transcript"].(string)
+	if !strings.Contains(transcript, "Error: tool call failed") || reloaded["lines"] != float64(5) || reloaded["reinjected"] != true {
+		t.Fatalf("unexpected agentReloaded: %v", reloaded)
+	}
+	if !first.isClosed() {
+		t.Fatal("previous process not closed")
+	}
+	second := fs.last(t)
+	if second == first || second.cfg.Cmd != "agent" || !slices.Equal(second.cfg.Args, []string{"run"}) || second.cfg.Dir != dir {
+		t.Fatalf("not restarted with the same command: %+v", second.cfg)
+	}
+
+	// The summary goes in once the new process is ready, without the repeated line
+	second.emit("banner\n")
+	readType(t, c, "ready")
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(second.stdinString(), "Error: tool call failed") {
+		if time.Now().After(deadline) {
+			t.Fatalf("summary not sent: %q", second.stdinString())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if in := second.stdinString(); strings.Count(in, "Editing main.go") != 1 || !strings.Contains(in, "previous session was restarted") {
+		t.Fatalf("unexpected summary: %q", in)
+	}
+
+	// The replaced process exiting is not reported
+	first.exit(nil)
+	_ = c.WriteJSON(map[string]any{"type": "listNotes", "sessionId": "s1"})
+	for {
+		msg := readMessage(t, c)
+		if msg["type"] == "exit" {
+			t.Fatalf("exit reported for the replaced process: %v", msg)
+		}
+		if msg["type"] == "notes" {
+			break
+		}
+	}
+}
+
+func TestReloadAgent_NoSession(t *testing.T) {
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "reloadAgent", "sessionId": "s1"})
+	if msg := readType(t, c, "error"); !strings.Contains(msg["message"].(string), "reloadAgent") {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+}
+
+func TestCarryOverPrompt(t *testing.T) {
+	got := carryOverPrompt("one\n\nspinner\nspinner\ntwo  \nthree", 3)
+	if !strings.Contains(got, "```\nspinner\ntwo\nthree\n```") {
+		t.Fatalf("unexpected prompt: %q", got)
+	}
+	if carryOverPrompt("\n  \n", 10) != "" {
+		t.Fatal("expected no prompt for a blank transcript")
+	}
+}
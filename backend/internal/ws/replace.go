@@ -0,0 +1,195 @@
+package ws
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/example/rovobridge/internal/fileutil"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxReplaceFileBytes skips larger files; generated bundles and data files are no
+	// place for a bulk edit
+	maxReplaceFileBytes = 1 << 20
+	// maxReplaceFiles bounds the files changed by one replaceInFiles
+	maxReplaceFiles = 500
+	// maxReplaceDiffBytes bounds the diff of one file in a dry run report
+	maxReplaceDiffBytes = 16 << 10
+	// replaceDiffContext is the unchanged lines shown around each change of a dry run
+	replaceDiffContext = 1
+)
+
+// replaceSpec is a replaceInFiles request: Pattern is literal text unless Regex is set, in
+// which case Replacement may use $1 and ${name}. Include and Exclude are globs matched
+// against the slash-separated path relative to the index root and against the base name.
+type replaceSpec struct {
+	Pattern       string
+	Replacement   string
+	Regex         bool
+	CaseSensitive bool
+	Include       []string
+	Exclude       []string
+	DryRun        bool
+}
+
+// replaceFileReport is the change replaceInFiles made, or would make, to one file
+type replaceFileReport struct {
+	Path          string `json:"path"`
+	Replacements  int    `json:"replacements"`
+	Diff          string `json:"diff,omitempty"`
+	DiffTruncated bool   `json:"diffTruncated,omitempty"`
+	Error         string `json:"error,omitempty"`
+
+	abs     string
+	before  []byte
+	updated []byte
+}
+
+// compile turns the pattern into a regexp, quoting a literal pattern
+func (s replaceSpec) compile() (*regexp.Regexp, error) {
+	if s.Pattern == "" {
+		return nil, errors.New("empty pattern")
+	}
+	p := s.Pattern
+	if !s.Regex {
+		p = regexp.QuoteMeta(p)
+	}
+	if !s.CaseSensitive {
+		p = "(?i)" + p
+	}
+	return regexp.Compile(p)
+}
+
+// matchesGlobs reports whether the relative path rel matches one of globs, by its whole
+// slash-separated path or its base name
+func matchesGlobs(rel string, globs []string) bool {
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(g, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// planReplace finds the changes of spec in the indexed files under root: files ignored by
+// .gitignore, binary files and files over maxReplaceFileBytes are left alone. It reports
+// truncated when more than maxReplaceFiles files would change.
+func planReplace(root string, rels []string, spec replaceSpec, re *regexp.Regexp) (files []replaceFileReport, truncated bool) {
+	files = []replaceFileReport{}
+	for _, rel := range rels {
+		if len(spec.Include) > 0 && !matchesGlobs(rel, spec.Include) || matchesGlobs(rel, spec.Exclude) {
+			continue
+		}
+		abs := filepath.Join(root, rel)
+		fi, err := os.Lstat(abs)
+		if err != nil || !fi.Mode().IsRegular() || fi.Size() > maxReplaceFileBytes {
+			continue
+		}
+		before, err := os.ReadFile(abs)
+		if err != nil || bytes.IndexByte(before[:min(len(before), 8000)], 0) >= 0 {
+			continue
+		}
+		n := len(re.FindAllIndex(before, -1))
+		if n == 0 {
+			continue
+		}
+		var updated []byte
+		if spec.Regex {
+			updated = re.ReplaceAll(before, []byte(spec.Replacement))
+		} else {
+			updated = re.ReplaceAllLiteral(before, []byte(spec.Replacement))
+		}
+		if bytes.Equal(before, updated) {
+			continue
+		}
+		if len(files) == maxReplaceFiles {
+			return files, true
+		}
+		files = append(files, replaceFileReport{Path: filepath.ToSlash(rel), Replacements: n, abs: abs, before: before, updated: updated})
+	}
+	return files, false
+}
+
+// replaceInFiles answers replaceInFiles with replaceResult. A dry run reports the diff of
+// every file; otherwise the edit runs once the client confirms, and a file that changed
+// after it was read is reported with an error and left alone.
+func (r *Router) replaceInFiles(conn *websocket.Conn, spec replaceSpec) error {
+	if r.indexer == nil {
+		Errorf(conn, "replaceInFiles: no index")
+		return nil
+	}
+	re, err := spec.compile()
+	if err != nil {
+		Errorf(conn, "replaceInFiles: bad pattern: %v", err)
+		return nil
+	}
+	root, err := filepath.Abs(r.indexer.Root)
+	if err != nil {
+		Errorf(conn, "replaceInFiles: %v", err)
+		return nil
+	}
+	r.indexer.RequestRefresh()
+	snap := r.indexer.Snapshot()
+	rels := make([]string, 0, len(snap.Entries))
+	for _, e := range snap.Entries {
+		if !e.IsDir {
+			rels = append(rels, e.Path)
+		}
+	}
+	files, truncated := planReplace(root, rels, spec, re)
+	total := 0
+	for _, f := range files {
+		total += f.Replacements
+	}
+	report := func(files []replaceFileReport) error {
+		return SendJSON(conn, map[string]any{
+			"type":              "replaceResult",
+			"root":              root,
+			"dryRun":            spec.DryRun,
+			"files":             files,
+			"totalReplacements": total,
+			"truncated":         truncated,
+		})
+	}
+	if spec.DryRun || len(files) == 0 {
+		for i := range files {
+			f := &files[i]
+			f.Diff, _, _ = fileutil.UnifiedDiff("a/"+f.Path, "b/"+f.Path, string(f.before), string(f.updated), replaceDiffContext)
+			if len(f.Diff) > maxReplaceDiffBytes {
+				f.Diff, f.DiffTruncated = f.Diff[:maxReplaceDiffBytes], true
+			}
+		}
+		return report(files)
+	}
+	summary := fmt.Sprintf("Replace %d matches of %q in %d files under %s", total, spec.Pattern, len(files), root)
+	return r.requireConfirmation(conn, "replaceInFiles", summary, func() error {
+		r.fileWriteMu.Lock()
+		defer r.fileWriteMu.Unlock()
+		for i := range files {
+			f := &files[i]
+			now, err := os.ReadFile(f.abs)
+			switch {
+			case err != nil:
+				f.Error = err.Error()
+			case !bytes.Equal(now, f.before):
+				f.Error = "file changed since it was searched; not replaced"
+			default:
+				if err := writeFileAtomic(f.abs, f.updated); err != nil {
+					f.Error = err.Error()
+				}
+			}
+		}
+		return report(files)
+	})
+}
@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/rovobridge/internal/index"
+)
+
+func writeReplaceTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for rel, content := range files {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestRouter_ReplaceInFilesDryRunThenConfirm(t *testing.T) {
+	root := writeReplaceTree(t, map[string]string{
+		"a.go":         "package a\n\nfunc OldName() {}\n",
+		"sub/b.go":     "package sub\n\n// oldname is called here\nvar _ = OldName\n",
+		"notes.md":     "OldName in docs\n",
+		"vendor/c.go":  "OldName\n",
+		".gitignore":   "vendor/\n",
+		"sub/data.bin": "OldName\x00",
+	})
+	r, _ := newTestRouter(t)
+	r.indexer = index.New(root)
+	r.indexer.Start()
+	t.Cleanup(r.indexer.Close)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	req := map[string]any{"type": "replaceInFiles", "pattern": "OldName", "replacement": "NewName", "caseSensitive": true, "include": []string{"*.go"}, "dryRun": true}
+	_ = c.WriteJSON(req)
+	msg := readType(t, c, "replaceResult")
+	files := msg["files"].([]any)
+	if len(files) != 2 || msg["totalReplacements"] != float64(2) {
+		t.Fatalf("unexpected report: %v", msg)
+	}
+	if f := files[0].(map[string]any); f["path"] != "a.go" || !strings.Contains(f["diff"].(string), "-func OldName() {}\n+func NewName() {}\n") {
+		t.Fatalf("unexpected file report: %v", f)
+	}
+	if b, _ := os.ReadFile(filepath.Join(root, "a.go")); strings.Contains(string(b), "NewName") {
+		t.Fatal("dry run wrote a file")
+	}
+
+	// Without dryRun the edit waits for confirmation
+	req["dryRun"] = false
+	_ = c.WriteJSON(req)
+	challenge := readType(t, c, "confirmationRequired")
+	if challenge["operation"] != "replaceInFiles" {
+		t.Fatalf("unexpected challenge: %v", challenge)
+	}
+	// a file edited in between is left alone
+	if err := os.WriteFile(filepath.Join(root, "sub/b.go"), []byte("package sub\n\nvar _ = OldName // edited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "confirm", "token": challenge["token"], "approved": true})
+	msg = readType(t, c, "replaceResult")
+	files = msg["files"].([]any)
+	if f := files[1].(map[string]any); f["path"] != "sub/b.go" || !strings.Contains(f["error"].(string), "changed") {
+		t.Fatalf("unexpected file report: %v", f)
+	}
+	if b, _ := os.ReadFile(filepath.Join(root, "a.go")); string(b) != "package a\n\nfunc NewName() {}\n" {
+		t.Fatalf("unexpected content: %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(root, "vendor/c.go")); string(b) != "OldName\n" {
+		t.Fatalf("ignored file replaced: %q", b)
+	}
+}
+
+func TestReplaceSpec_RegexAndCase(t *testing.T) {
+	root := writeReplaceTree(t, map[string]string{"x.txt": "id=1 ID=2\n"})
+	spec := replaceSpec{Pattern: `id=(\d)`, Replacement: "key=$1", Regex: true}
+	re, err := spec.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := planReplace(root, []string{"x.txt"}, spec, re)
+	if len(files) != 1 || files[0].Replacements != 2 || string(files[0].updated) != "key=1 key=2\n" {
+		t.Fatalf("unexpected plan: %+v", files)
+	}
+	if _, err := (replaceSpec{Pattern: "("}).compile(); err != nil {
+		t.Fatalf("literal pattern not quoted: %v", err)
+	}
+}
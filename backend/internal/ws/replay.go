@@ -0,0 +1,220 @@
+package ws
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/example/rovobridge/internal/recording"
+)
+
+const (
+	// replayLineSpacing is the minimum distance between recorded line-start trim points
+	replayLineSpacing = 256
+	// replayMaxSpacing records a trim point mid-line when no line break came for this
+	// long, e.g. during full-screen redraws
+	replayMaxSpacing = 4096
+)
+
+// replayScanState is the escape-sequence parser state at the end of the replay buffer
+type replayScanState uint8
+
+const (
+	scanGround    replayScanState = iota
+	scanEscape                    // after ESC
+	scanCSI                       // inside ESC [ ... final byte
+	scanString                    // inside OSC/DCS/SOS/PM/APC, until BEL or ST
+	scanStringEsc                 // ESC inside a string, possibly starting ST
+	scanCharset                   // after ESC ( and similar, one byte left
+)
+
+// replayScanner tracks where the replay buffer may be trimmed. While output is
+// appended it follows escape sequences and UTF-8 runes, and records offsets that are
+// outside any sequence and at the start of a rune, preferring line starts. Trimming
+// then cuts at such an offset so snapshots never start mid-rune or mid-sequence.
+type replayScanner struct {
+	state    replayScanState
+	pending  int   // UTF-8 continuation bytes still expected
+	safe     []int // trim points as offsets into the buffer, ascending
+	lastSafe int   // latest trim point, or 0
+	prev     byte  // last byte scanned
+
+	// output before the start of the buffer, trimmed or collapsed away, in stream bytes
+	trimmed int64
+	// spinner collapsing (see appendCollapse): the redraw frame at the end of the buffer,
+	// and where earlier frames were dropped
+	frame replayFrame
+	drops []replayDrop
+}
+
+// replayFrame is a redraw frame at the end of the replay buffer, with the scanner state
+// at its start so it can be dropped
+type replayFrame struct {
+	active   bool
+	start    int // offset of the frame in the buffer
+	up       int
+	lines    int
+	state    replayScanState
+	pending  int
+	lastSafe int
+	prev     byte
+	nsafe    int // trim points before start
+}
+
+// replayDrop records n stream bytes collapsed away at offset at of the buffer
+type replayDrop struct {
+	at int
+	n  int64
+}
+
+// appendTrim appends p to buf and, if the result exceeds max bytes, drops the oldest
+// output up to the first trim point that leaves at most max bytes. Without such a point
+// it falls back to a plain cut at the next rune start.
+func (s *replayScanner) appendTrim(buf, p []byte, max int) []byte {
+	base := len(buf)
+	buf = append(buf, p...)
+	s.scan(p, base)
+	if len(buf) <= max {
+		return buf
+	}
+	cut := len(buf) - max
+	i := sort.SearchInts(s.safe, cut)
+	if i < len(s.safe) {
+		cut = s.safe[i]
+		i++
+	} else {
+		for cut < len(buf) && !utf8.RuneStart(buf[cut]) {
+			cut++
+		}
+	}
+	s.safe = append(s.safe[:0], s.safe[i:]...)
+	for j := range s.safe {
+		s.safe[j] -= cut
+	}
+	s.lastSafe -= cut
+	s.trimmed += int64(cut)
+	k := 0
+	for k < len(s.drops) && s.drops[k].at <= cut {
+		s.trimmed += s.drops[k].n
+		k++
+	}
+	s.drops = append(s.drops[:0], s.drops[k:]...)
+	for j := range s.drops {
+		s.drops[j].at -= cut
+	}
+	if f := &s.frame; f.active {
+		f.start -= cut
+		f.lastSafe -= cut
+		if f.nsafe -= i; f.nsafe < 0 {
+			f.nsafe = 0
+		}
+		f.active = f.start >= 0
+	}
+	return buf[cut:]
+}
+
+// appendCollapse is appendTrim for output that may redraw itself: a spinner or progress
+// frame at the end of the buffer is dropped when the next frame redraws it, so snapshots
+// hold only the latest frame (see recording.SplitFrames)
+func (s *replayScanner) appendCollapse(buf, p []byte, max int) []byte {
+	for _, seg := range recording.SplitFrames(p) {
+		if seg.Frame {
+			if f := s.frame; f.active && f.up == seg.Up && f.lines == f.up {
+				buf = s.dropFrame(buf)
+			}
+			s.frame = replayFrame{
+				active: true, start: len(buf), up: seg.Up,
+				state: s.state, pending: s.pending, lastSafe: s.lastSafe, prev: s.prev, nsafe: len(s.safe),
+			}
+		}
+		if s.frame.active {
+			s.frame.lines += seg.Lines
+		}
+		buf = s.appendTrim(buf, seg.Data, max)
+	}
+	return buf
+}
+
+// dropFrame cuts the frame at the end of buf off and restores the scanner to its start
+func (s *replayScanner) dropFrame(buf []byte) []byte {
+	f := s.frame
+	n := int64(len(buf) - f.start)
+	k := len(s.drops)
+	for k > 0 && s.drops[k-1].at >= f.start {
+		k--
+		n += s.drops[k].n
+	}
+	s.drops = append(s.drops[:k], replayDrop{at: f.start, n: n})
+	s.state, s.pending, s.lastSafe, s.prev = f.state, f.pending, f.lastSafe, f.prev
+	s.safe = s.safe[:min(f.nsafe, len(s.safe))]
+	s.frame = replayFrame{}
+	return buf[:f.start]
+}
+
+func (s *replayScanner) scan(p []byte, base int) {
+	for k, c := range p {
+		if off := base + k; off > 0 && s.state == scanGround && s.pending == 0 && utf8.RuneStart(c) {
+			d := off - s.lastSafe
+			if s.prev == '\n' && d >= replayLineSpacing || d >= replayMaxSpacing {
+				s.safe = append(s.safe, off)
+				s.lastSafe = off
+			}
+		}
+		s.step(c)
+		s.prev = c
+	}
+}
+
+// step advances the parser by one byte
+func (s *replayScanner) step(c byte) {
+	switch s.state {
+	case scanGround:
+		switch {
+		case c == 0x1b:
+			s.state, s.pending = scanEscape, 0
+		case s.pending > 0 && c&0xc0 == 0x80:
+			s.pending--
+		case c >= 0xf0 && c < 0xf8:
+			s.pending = 3
+		case c >= 0xe0 && c < 0xf0:
+			s.pending = 2
+		case c >= 0xc0 && c < 0xe0:
+			s.pending = 1
+		default:
+			// ASCII or an invalid byte: any unfinished rune is abandoned
+			s.pending = 0
+		}
+	case scanEscape:
+		switch c {
+		case '[':
+			s.state = scanCSI
+		case ']', 'P', 'X', '^', '_':
+			s.state = scanString
+		case '(', ')', '*', '+', '#', '%':
+			s.state = scanCharset
+		case 0x1b:
+			// ESC ESC: the second one starts a new sequence
+		default:
+			s.state = scanGround
+		}
+	case scanCSI:
+		if c >= 0x40 && c <= 0x7e {
+			s.state = scanGround
+		} else if c == 0x1b {
+			s.state = scanEscape
+		}
+	case scanString:
+		if c == 0x07 {
+			s.state = scanGround
+		} else if c == 0x1b {
+			s.state = scanStringEsc
+		}
+	case scanStringEsc:
+		if c == '\\' {
+			s.state = scanGround
+		} else {
+			s.state = scanString
+		}
+	case scanCharset:
+		s.state = scanGround
+	}
+}
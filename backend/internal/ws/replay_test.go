@@ -0,0 +1,90 @@
+package ws
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestReplayScanner_TrimsAtSafeBoundaries(t *testing.T) {
+	const max = 8 * 1024
+	var s replayScanner
+	var buf []byte
+	var chunk bytes.Buffer
+	for i := 0; i < 400; i++ {
+		chunk.WriteString("\x1b[38;5;208mzażółć gęślą jaźń\x1b[0m \x1b]8;;file:///tmp/x\x1b\\link\x1b]8;;\x07 ")
+		if i%7 == 0 {
+			chunk.WriteString("\r\n")
+		}
+	}
+	data := chunk.Bytes()
+	// Feed in odd-sized chunks so sequences and runes straddle reads
+	for len(data) > 0 {
+		n := 37
+		if n > len(data) {
+			n = len(data)
+		}
+		buf = s.appendTrim(buf, data[:n], max)
+		data = data[n:]
+		if len(buf) > max {
+			t.Fatalf("buffer exceeds cap: %d", len(buf))
+		}
+		if len(buf) > 0 && !utf8.RuneStart(buf[0]) {
+			t.Fatalf("buffer starts mid-rune: %q", buf[:8])
+		}
+	}
+	if !strings.HasPrefix(string(buf), "\x1b[38;5;208m") {
+		t.Fatalf("expected snapshot to start at a line start, got %q", buf[:32])
+	}
+	if !utf8.Valid(buf) {
+		t.Fatal("expected valid UTF-8 after trimming")
+	}
+}
+
+func TestReplayScanner_LongLinesWithoutBreaks(t *testing.T) {
+	var s replayScanner
+	line := strings.Repeat("\x1b[2K\x1b[1Gredraw ✓ ", 2000) // no newlines at all
+	buf := s.appendTrim(nil, []byte(line), 4*1024)
+	if len(buf) > 4*1024 || !strings.HasPrefix(string(buf), "\x1b[2K") && !utf8.RuneStart(buf[0]) {
+		t.Fatalf("unexpected trim start %q", buf[:16])
+	}
+	for _, c := range []byte{'[', 'K', 'G'} {
+		if buf[0] == c {
+			t.Fatalf("trimmed inside an escape sequence: %q", buf[:16])
+		}
+	}
+}
+
+func TestReplayScanner_CollapsesSpinnerFrames(t *testing.T) {
+	var s replayScanner
+	var buf []byte
+	var stream int
+	add := func(p string) {
+		buf = s.appendCollapse(buf, []byte(p), 64*1024)
+		stream += len(p)
+	}
+	add("$ build\n")
+	for i := 0; i < 50; i++ {
+		add(fmt.Sprintf("\r\x1b[K⠋ compiling %d", i))
+	}
+	add("\r\x1b[K✓ compiled\n")
+	// A multi-line progress display moving up two lines per frame
+	add("a 0%\nb 0%\n")
+	for i := 1; i <= 20; i++ {
+		add(fmt.Sprintf("\x1b[2A\r\x1b[Ja %d%%\nb %d%%\n", i, i))
+	}
+	add("done\n")
+
+	want := "$ build\n\r\x1b[K✓ compiled\na 0%\nb 0%\n\x1b[2A\r\x1b[Ja 20%\nb 20%\ndone\n"
+	if string(buf) != want {
+		t.Fatalf("got %q, want %q", buf, want)
+	}
+	// The collapsed output counts as trimmed once it leaves the buffer
+	buf = s.appendCollapse(buf, bytes.Repeat([]byte("x\n"), 40*1024), 64*1024)
+	stream += 80 * 1024
+	if got := s.trimmed + int64(len(buf)); got != int64(stream) {
+		t.Fatalf("trimmed %d + buffered %d = %d, want the stream length %d", s.trimmed, len(buf), got, stream)
+	}
+}
@@ -8,48 +8,171 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/example/rovobridge/internal/fileutil"
 	"github.com/example/rovobridge/internal/history"
 	"github.com/example/rovobridge/internal/index"
+	"github.com/example/rovobridge/internal/janitor"
+	"github.com/example/rovobridge/internal/notify"
+	"github.com/example/rovobridge/internal/policy"
+	"github.com/example/rovobridge/internal/recording"
+	"github.com/example/rovobridge/internal/redact"
+	"github.com/example/rovobridge/internal/selfupdate"
 	"github.com/example/rovobridge/internal/session"
+	"github.com/example/rovobridge/internal/tasks"
+	"github.com/example/rovobridge/internal/usage"
 	"github.com/gorilla/websocket"
 )
 
 type Router struct {
-	mu              sync.Mutex
-	sessions        map[string]*session.Session
-	sessionStates   map[string]*sessionState
-	connSessions    map[*websocket.Conn]map[string]bool
+	mu                sync.Mutex
+	sessions          map[string]ptySession
+	sessionStates     map[string]*sessionState
+	connSessions      map[*websocket.Conn]map[string]bool
+	aliases           map[string]string                     // alias each session was opened under, by session id (see sessionids.go)
+	connAliases       map[*websocket.Conn]map[string]string // session ids by the aliases a connection opened them under
+	snapshotEncodings map[*websocket.Conn]string            // snapshot compression negotiated in hello (see compress.go)
+	editorConns       map[*websocket.Conn]bool              // IDE plugin connections (see editor.go)
+	editorContexts    map[string]editorContext              // IDE state per workspace, from setEditorContext
+	snippets          map[string]string                     // in-memory files by virtual path (see snippets.go)
+	clips             *clipHistory                          // opt-in clipboard history (see clips.go)
+	notifier          *notify.Notifier                      // desktop notifications (see notifications.go)
+	server            *Server                               // set by Attach; source of connection stats
+	events            map[string]*sessionEvents             // recent events per session for late joiners (see events.go)
+	confirmations     map[string]*pendingConfirmation       // dangerous operations awaiting confirm, by token (see confirm.go)
+	policy            *policy.Policy                        // executables sessions may launch; nil permits all
+	stdinLimits       StdinLimits                           // bounds on client stdin messages (see stdinlimit.go)
+	stdinRejected     int64                                 // stdin bytes dropped by stdinLimits, for stats
+	maxPayload        int                                   // bound on the payload of send and injectFiles; 0 = none (see payloadlimit.go)
+	promptLint        map[string]bool                       // checks run on send before it is written (see promptlint.go)
+	promptLatency     latencyStats                          // first-output latencies of all sessions, for stats (see promptmetrics.go)
+	timeouts          Timeouts                              // bounds on blocking work in handlers (see timeouts.go)
+	recordDir         string                                // where new sessions are recorded; empty = off (see recordings.go)
+	inputRecordDir    string                                // where the stdin of new sessions is recorded; empty = off
+	gitCheckpoints    bool                                  // commit the work tree to a checkpoint ref before each send (see gitcheckpoints.go)
+	ports             map[int]*forwardedPort                // dev server ports detected in output, for /proxy (see portforward.go)
+	proxyTickets      map[string]proxyTicket                // one-time /proxy grants from openProxy, by ticket
+	proxyBase         string                                // URL of the origin serving /proxy; empty = the UI origin
+
+	// files followed per connection, by tail id (see tailfile.go)
+	tails    map[*websocket.Conn]map[string]*fileTail
+	nextTail uint64
+
+	// clients following index changes, by connection (see indexsubscribe.go)
+	indexSubsMu sync.Mutex
+	indexSubs   map[*websocket.Conn]indexSubscription
+
+	// worker pool and ordering lanes message handlers run on (see dispatch.go)
+	dispatcher *dispatcher
+
+	// panics recovered from message handlers (see crash.go)
+	crashes crashLog
+
+	// per-session JSONL logs of lifecycle events (see eventlog.go)
+	sessionLogs sessionEventLog
+
+	// release manifest for checkUpdate; nil when updates are not configured (see update.go)
+	updater *selfupdate.Updater
+
+	// removes aged recordings, logs and temporary files; nil when off (see janitor.go)
+	janitor *janitor.Janitor
+
+	// capabilities withheld from browser connections (see capabilities.go)
+	browserDenied atomic.Pointer[[]string]
+
+	// serializes the confirmed writes of saveFile, applyCodeBlock and replaceInFiles with
+	// their last check of the files (see quickedit.go)
+	fileWriteMu sync.Mutex
+
+	// drop superseded spinner frames from new sessions' replay and recordings (see recordings.go)
+	collapseSpinners bool
+
+	// replace an unknown TERM asked for by openSession with xterm-256color (see term.go)
+	downgradeTerm bool
+
+	// secret patterns masked in session output, and the filter in effect: redactFilter
+	// while redaction is on, nil while off (see redaction.go)
+	redactFilter *redact.Filter
+	redaction    atomic.Pointer[redact.Filter]
+
+	// sessions offered to another client with transferSession, by token (see transfer.go)
+	transfers map[string]*pendingTransfer
+
+	// processes of the default command started ahead of openSession; nil when off (see warmpool.go)
+	warm *warmPool
+
+	// synthetic workspace served in mock mode; nil otherwise (see mock.go)
+	mock *mockWorkspace
+
+	// session factory and detach grace period; tests substitute fakes and short delays
+	startSession func(context.Context, session.Config) (ptySession, error)
+	orphanGrace  time.Duration
+
+	// injection preamble defaults set with updateInjectionSettings
+	preamble        string
+	noPreamble      bool
 	customCommand   string
-	currentFontSize int // Store the current font size from frontend
+	outputEncoding  string // code page of new sessions' output, set with updateSessionConfig (see outputencoding.go)
+	currentFontSize int    // Store the current font size from frontend
 
 	// file indexer
 	indexer *index.Indexer
 
 	// prompt history manager
 	historyManager *history.HistoryManager
+	// moves seen by the index watches, not yet applied to the history, and whether they
+	// apply at once (see historyrenames.go)
+	pendingRenames     []history.PathRename
+	autoRewriteHistory bool
+
+	// unsent prompt drafts, persisted with a debounce
+	drafts *history.DraftStore
+	// token, request and cost totals per day printed by the agents (see usage.go)
+	usage *usage.Ledger
 }
 
+// defaultOrphanGrace is how long a session survives without a connection before it is closed
+const defaultOrphanGrace = 30 * time.Second
+
 // Max frequency for stdout sends to the client.
 // Adjust as needed; 200ms means up to 5 messages/sec.
 const stdoutThrottleInterval = 200 * time.Millisecond
 
+// stdoutDrainTimeout bounds how long an exited session waits for its remaining output,
+// e.g. when a background child keeps the terminal open
+const stdoutDrainTimeout = 2 * time.Second
+
+// maxNormalizePaths bounds the paths of one normalizePaths request, each checked on disk
+const maxNormalizePaths = 1000
+
 type sessionState struct {
 	mu               sync.Mutex
 	replay           []byte
+	replayScan       replayScanner // safe trim points of replay (see replay.go)
+	collapseFrames   bool          // keep only the latest spinner frame in replay
 	lastSeq          uint64
+	sentBytes        int64 // stream offset of the next stdout message
 	currentConn      *websocket.Conn
 	orphanTimer      *time.Timer
 	suppressNextExit bool
 
+	// lifetime of the current process: cancelled when it exits, is replaced or is
+	// orphaned, so its stdout pipeline and pending flushes stop touching the state
+	ctx    context.Context
+	cancel context.CancelFunc
+	// held across a stdout send so a process switch cannot slip in between the
+	// staleness check and the write; taken before mu
+	sendMu sync.Mutex
+
 	// stdout throttling/buffering
 	outBuf        []byte
 	lastSend      time.Time // last time we sent a stdout message to client
@@ -60,28 +183,164 @@ type sessionState struct {
 	// session working directory for prompt history
 	workingDir string
 
+	// label given in openSession and the last client input, for the dashboard (see dashboard.go)
+	label     string
+	lastInput time.Time
+
+	// openSession message of the current process, which reloadAgent starts again, and the
+	// summary of the previous process to send once it is ready (see reload.go)
+	openRequest map[string]any
+	carryOver   string
+
 	// whether to use system clipboard when injecting files (default: true)
 	useClipboard bool
+
+	// whether sends skip the workspace prompt prefix (see promptprefix.go)
+	promptPrefixOff bool
+
+	// line-oriented output in place of stdout, and the unterminated line last sent with
+	// it (see plaintext.go)
+	plainText    bool
+	plainPending string
+
+	// plain-text view of the output and diagnostics already reported (see diagnostics.go)
+	mirror       plainTextMirror
+	diagSeen     map[string]bool
+	diagSeenKeys []string
+
+	// fenced code blocks printed so far (see codeblocks.go)
+	codeBlocks codeBlockTracker
+
+	// files injected so far and conversation checkpoints (see checkpoint.go)
+	injectedPaths  []string
+	injectedSeen   map[string]bool
+	checkpoints    []checkpoint
+	nextCheckpoint uint64
+
+	// current output run for idle notifications (see notifications.go)
+	runStart   time.Time
+	lastOutput time.Time
+	idleTimer  *time.Timer
+
+	// client stdin rate limiting (see stdinlimit.go)
+	stdinBucket stdinBucket
+
+	// asciicast recording of the current process and the recording of its input, if
+	// enabled (see recordings.go)
+	recorder      *recording.Writer
+	inputRecorder *recording.InputWriter
+
+	// workspace files when the current process started, for sessionDiff (see sessiondiff.go)
+	tree *treeSnapshot
+
+	// build/test task in progress and the last result, for injectTaskResult (see tasks.go)
+	task     *runningTask
+	nextTask uint64
+	lastTask *tasks.Result
+
+	// broadcastSend whose prompt was sent last; its id tags stdout messages (see broadcast.go)
+	broadcast *broadcastTag
+
+	// connection that handed the session to another client; its input is refused until
+	// it resumes the session (see transfer.go)
+	formerConn *websocket.Conn
+
+	// notes and bookmarks attached to output positions, in output order (see notes.go)
+	notes    []note
+	nextNote uint64
+
+	// when the output at each stream offset was read, for resolveTime (see timeline.go)
+	timeline outputTimeline
+
+	// unfinished line held back by redaction and when, released after redactHoldDelay
+	// (see redaction.go)
+	redactor     redact.Stream
+	redactHeldAt time.Time
+	redactTimer  *time.Timer
+
+	// recent output lines, the permission prompt waiting for an answer and the question
+	// last answered, so its redraws are not asked again (see permissions.go)
+	permLines      []string
+	permission     *pendingPermission
+	permAnswered   string
+	nextPermission uint64
+
+	// prompt typed in and waiting for its Enter, when the submitted one was sent, and the
+	// first-output latencies of the session's prompts (see promptmetrics.go)
+	promptPending bool
+	promptID      string
+	promptSentAt  time.Time
+	latency       latencyStats
+
+	// token, request and cost figures printed by the agent (see usage.go)
+	usage usage.Meter
+
+	// wait for the process to finish its startup output (see readiness.go)
+	startup startupState
+
+	// watch for a busy process that stopped answering (see liveness.go)
+	liveness livenessState
 }
 
 func NewRouter(customCommand string) *Router {
-	r := &Router{
-		sessions:        map[string]*session.Session{},
-		sessionStates:   map[string]*sessionState{},
-		connSessions:    map[*websocket.Conn]map[string]bool{},
-		customCommand:   customCommand,
-		currentFontSize: 0, // 0 means no font size change received yet
-		historyManager:  history.NewHistoryManager(),
-	}
+	r := newRouter(customCommand)
 	// initialize indexer for current working directory
 	if cwd, err := os.Getwd(); err == nil {
 		r.indexer = index.New(cwd)
+		r.indexer.SetStatusHandler(r.broadcastIndexStatus)
+		r.indexer.SetRenameHandler(r.onIndexRename)
+		r.indexer.SetChangeHandler(r.onIndexChange)
 		r.indexer.Start()
 	}
 	return r
 }
 
+// newRouter returns a Router without a file indexer
+func newRouter(customCommand string) *Router {
+	return &Router{
+		sessions:          map[string]ptySession{},
+		startSession:      startPTYSession,
+		orphanGrace:       defaultOrphanGrace,
+		sessionStates:     map[string]*sessionState{},
+		aliases:           map[string]string{},
+		connAliases:       map[*websocket.Conn]map[string]string{},
+		snapshotEncodings: map[*websocket.Conn]string{},
+		events:            map[string]*sessionEvents{},
+		confirmations:     map[string]*pendingConfirmation{},
+		transfers:         map[string]*pendingTransfer{},
+		ports:             map[int]*forwardedPort{},
+		proxyTickets:      map[string]proxyTicket{},
+		tails:             map[*websocket.Conn]map[string]*fileTail{},
+		indexSubs:         map[*websocket.Conn]indexSubscription{},
+		stdinLimits:       DefaultStdinLimits(),
+		maxPayload:        DefaultMaxPayloadBytes,
+		promptLint:        map[string]bool{lintEmpty: true, lintPlaceholder: true, lintMissingFile: true, lintStaleFile: true},
+		timeouts:          DefaultTimeouts(),
+		dispatcher:        newDispatcher(0),
+		connSessions:      map[*websocket.Conn]map[string]bool{},
+		editorConns:       map[*websocket.Conn]bool{},
+		editorContexts:    map[string]editorContext{},
+		snippets:          map[string]string{},
+		clips:             newClipHistory(),
+		notifier:          notify.New(),
+		customCommand:     customCommand,
+		currentFontSize:   0, // 0 means no font size change received yet
+		historyManager:    history.NewHistoryManager(),
+		drafts:            history.NewDraftStore(),
+		usage:             usage.NewLedger(usage.DefaultPath()),
+	}
+}
+
+// sessionDefaults returns the command and output encoding of new sessions, as
+// updateSessionConfig last set them
+func (r *Router) sessionDefaults() (customCommand, outputEncoding string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.customCommand, r.outputEncoding
+}
+
 func (r *Router) getSessionConfig() map[string]any {
+	customCommand, outputEncoding := r.sessionDefaults()
 	sessionConfig := map[string]any{
 		"cmd":  "acli",
 		"args": []string{"rovodev", "run"},
@@ -90,9 +349,9 @@ func (r *Router) getSessionConfig() map[string]any {
 	}
 
 	// Override with custom command if provided
-	if r.customCommand != "" {
+	if customCommand != "" {
 		// Parse custom command into cmd and args
-		parts := strings.Fields(r.customCommand)
+		parts := strings.Fields(customCommand)
 		if len(parts) > 0 {
 			sessionConfig["cmd"] = parts[0]
 			if len(parts) > 1 {
@@ -102,32 +361,78 @@ func (r *Router) getSessionConfig() map[string]any {
 			}
 		}
 	}
+	if outputEncoding != "" {
+		sessionConfig["outputEncoding"] = outputEncoding
+	}
 
 	return sessionConfig
 }
 
 func (r *Router) Attach(s *Server) {
-	s.OnMessage = func(conn *websocket.Conn, msg map[string]any) {
-		_ = r.handle(conn, msg)
+	r.mu.Lock()
+	r.server = s
+	r.mu.Unlock()
+	s.OnMessage = func(ctx context.Context, conn *websocket.Conn, msg map[string]any) {
+		r.dispatch(ctx, conn, msg)
 	}
 	s.OnClose = func(conn *websocket.Conn) {
+		r.dispatcher.finish(conn)
 		r.cleanupConn(conn)
 	}
 }
 
-func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
+// handle dispatches one client message. ctx ends when the connection closes; blocking
+// work is further bounded by r.timeouts (see timeouts.go).
+func (r *Router) handle(ctx context.Context, conn *websocket.Conn, m map[string]any) error {
+	if r.mock != nil && r.mockGuard(conn, m) {
+		return nil
+	}
 	switch m["type"] {
 	case "hello":
-		return SendJSON(conn, map[string]any{
+		// { type: "hello", client?: "ide", features?: { batch: bool, compression?: [string], channels?: bool,
+		// channelWindow?: number } } - IDE plugins identify themselves to receive openInEditor. A client
+		// asking for batch must accept JSON array frames from then on, the welcome included;
+		// welcome.features.batch tells whether it was granted. welcome.features.compression names the
+		// snapshot encoding picked from the client's list, if any. A client asking for channels gets
+		// every message tagged with its channel (see channels.go), and the channel IDs and the credit
+		// window in welcome.features. welcome.restricted lists the capabilities withheld from a
+		// browser connection (see capabilities.go).
+		if client, _ := m["client"].(string); client == clientIDE {
+			r.registerEditorConn(conn)
+		}
+		wantBatch, wantChannels, window, encoding := false, false, 0, ""
+		if f, ok := m["features"].(map[string]any); ok {
+			wantBatch, _ = f["batch"].(bool)
+			wantChannels, _ = f["channels"].(bool)
+			window = asInt(f["channelWindow"])
+			encoding = negotiateEncoding(f["compression"])
+		}
+		batch := SetBatching(conn, wantBatch) && wantBatch
+		muxed, window := SetChannels(conn, wantChannels, window)
+		r.setSnapshotEncoding(conn, encoding)
+		features := map[string]any{"streaming": true, "pty": true, "batch": batch}
+		if encoding != "" {
+			features["compression"] = encoding
+		}
+		if muxed && wantChannels {
+			features["channels"] = channelIDs()
+			features["channelWindow"] = window
+		}
+		welcome := map[string]any{
 			"type":          "welcome",
 			"sessionId":     "ctrl",
-			"features":      map[string]bool{"streaming": true, "pty": true},
+			"features":      features,
 			"sessionConfig": r.getSessionConfig(),
-		})
+		}
+		if restricted := r.restrictedFor(conn); len(restricted) > 0 {
+			welcome["restricted"] = restricted
+		}
+		return SendJSON(conn, welcome)
 	case "searchIndex":
-		// { type: "searchIndex", pattern: string, opened: [string], limit: number }
+		// { type: "searchIndex", pattern: string, opened: [string], limit: number, profile?: string }
 		pattern, _ := m["pattern"].(string)
 		limit := asInt(m["limit"])
+		profile, _ := m["profile"].(string)
 		var opened []string
 		if arr, ok := anyToStrings(m["opened"]); ok {
 			opened = arr
@@ -165,7 +470,16 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			return SendJSON(conn, map[string]any{"type": "searchResult", "results": []any{}, "openedResults": []any{}})
 		}
 		snap := r.indexer.Snapshot()
-		res, ores := snap.Search(pattern, limit, opened)
+		type found struct{ res, opened []index.Entry }
+		out, err := withTimeout(ctx, r.getTimeouts().Search, "index search", func(context.Context) (found, error) {
+			res, ores := snap.SearchWithProfile(pattern, limit, opened, index.ProfileByName(profile))
+			return found{res, ores}, nil
+		})
+		if err != nil {
+			replyTimeout(conn, "searchIndex", err)
+			return nil
+		}
+		res, ores := out.res, out.opened
 		pack := func(in []index.Entry) []map[string]any {
 			out := make([]map[string]any, 0, len(in))
 			for _, e := range in {
@@ -182,37 +496,423 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			"results":       pack(res),
 			"openedResults": pack(ores),
 		})
+	case "exportIndex":
+		// { type: "exportIndex" } -> { type: "indexExport", root, count, entries: [{path, name, short, isDir}] }
+		if r.indexer == nil {
+			return SendJSON(conn, map[string]any{"type": "indexExport", "root": "", "count": 0, "entries": []any{}})
+		}
+		r.indexer.RequestRefresh()
+		snap := r.indexer.Snapshot()
+		return SendJSON(conn, map[string]any{
+			"type":    "indexExport",
+			"root":    r.indexer.Root,
+			"count":   len(snap.Entries),
+			"entries": snap.Export(),
+		})
+	case "subscribeIndex":
+		// { type: "subscribeIndex", prefix?: string, limit?: number } -> { type: "indexSubscribed", root,
+		// prefix, count, entries }, then indexDelta { added, removed, renamed, updated } as files change
+		prefix, _ := m["prefix"].(string)
+		return r.subscribeIndex(conn, prefix, asInt(m["limit"]))
+	case "unsubscribeIndex":
+		// { type: "unsubscribeIndex" } -> { type: "indexUnsubscribed" }
+		return r.unsubscribeIndex(conn)
+	case "replaceInFiles":
+		// { type: "replaceInFiles", pattern: string, replacement: string, regex?: bool, caseSensitive?: bool,
+		//   include?: [glob], exclude?: [glob], dryRun?: bool } -> replaceResult; without dryRun after confirm
+		spec := replaceSpec{}
+		spec.Pattern, _ = m["pattern"].(string)
+		spec.Replacement, _ = m["replacement"].(string)
+		spec.Regex, _ = m["regex"].(bool)
+		spec.CaseSensitive, _ = m["caseSensitive"].(bool)
+		spec.Include, _ = anyToStrings(m["include"])
+		spec.Exclude, _ = anyToStrings(m["exclude"])
+		spec.DryRun, _ = m["dryRun"].(bool)
+		return r.replaceInFiles(conn, spec)
+	case "getIndexStatus":
+		// { type: "getIndexStatus" } -> { type: "indexStatus", state, root, since, message?, attempt?, retryInMs? }
+		if r.indexer == nil {
+			return SendJSON(conn, indexStatusMessage(index.Status{State: index.StatusMissing, Message: "no index"}))
+		}
+		return SendJSON(conn, indexStatusMessage(r.indexer.Status()))
+	case "normalizePaths":
+		// { type: "normalizePaths", paths: [string] } -> { type: "normalizedPaths", root, paths:
+		// [{input, path, absolute, inside, exists, isDir, indexed}] } in the order given
+		paths, _ := anyToStrings(m["paths"])
+		if len(paths) > maxNormalizePaths {
+			Errorf(conn, "normalizePaths: at most %d paths at once", maxNormalizePaths)
+			return nil
+		}
+		root, _ := os.Getwd()
+		var snap index.Snapshot
+		if r.indexer != nil {
+			root, snap = r.indexer.Root, r.indexer.Snapshot()
+		}
+		out := make([]index.NormalizedPath, 0, len(paths))
+		for _, p := range paths {
+			out = append(out, snap.NormalizePath(root, p))
+		}
+		return SendJSON(conn, map[string]any{"type": "normalizedPaths", "root": root, "paths": out})
+	case "resolveReferences":
+		// { type: "resolveReferences", serializedContent: string } -> { type: "resolvedReferences",
+		// root, references: [{chip, path, status, resolved, ...}], serializedContent, changed }
+		content, _ := m["serializedContent"].(string)
+		if strings.Count(content, "<[#") > maxNormalizePaths {
+			Errorf(conn, "resolveReferences: at most %d chips at once", maxNormalizePaths)
+			return nil
+		}
+		root, _ := os.Getwd()
+		var snap index.Snapshot
+		if r.indexer != nil {
+			root, snap = r.indexer.Root, r.indexer.Snapshot()
+		}
+		refs, rewritten := resolveReferences(snap, root, content)
+		return SendJSON(conn, map[string]any{
+			"type":              "resolvedReferences",
+			"root":              root,
+			"references":        refs,
+			"serializedContent": rewritten,
+			"changed":           rewritten != content,
+		})
+	case "selectContext":
+		// { type: "selectContext", text: string, budget?: number } -> proposed injection list
+		// for the user to confirm; candidates that do not fit the budget are returned unselected
+		if r.indexer == nil {
+			return SendJSON(conn, map[string]any{"type": "contextSelection", "files": []index.ContextCandidate{}, "totalTokens": 0})
+		}
+		text, _ := m["text"].(string)
+		budget := asInt(m["budget"])
+		if budget <= 0 {
+			budget = index.DefaultContextBudget
+		}
+		r.indexer.RequestRefresh()
+		snap := r.indexer.Snapshot()
+		type selection struct {
+			files []index.ContextCandidate
+			total int
+		}
+		sel, err := withTimeout(ctx, r.getTimeouts().Search, "context selection", func(ctx context.Context) (selection, error) {
+			if r.mock != nil {
+				files, total := snap.SelectContextFS(r.mock.fsys, text, budget, nil)
+				return selection{files, total}, nil
+			}
+			files, total := snap.SelectContext(r.indexer.Root, text, budget, index.GitChangedFilesContext(ctx, r.indexer.Root))
+			return selection{files, total}, nil
+		})
+		if err != nil {
+			replyTimeout(conn, "selectContext", err)
+			return nil
+		}
+		return SendJSON(conn, map[string]any{
+			"type":        "contextSelection",
+			"files":       sel.files,
+			"totalTokens": sel.total,
+			"budget":      budget,
+		})
+	case "updateInjectionSettings":
+		// { type: "updateInjectionSettings", preamble?: string, preambleEnabled?: bool }
+		// Default framing of injected files; "" restores the built-in preamble
+		preamble, _ := m["preamble"].(string)
+		enabled, ok := m["preambleEnabled"].(bool)
+		r.mu.Lock()
+		r.preamble = preamble
+		r.noPreamble = ok && !enabled
+		r.mu.Unlock()
+		return nil
+	case "updateNotifications":
+		// { type: "updateNotifications", enabled: bool, events?: ["idle","exit"], idleAfterMs?, minRunMs?: number }
+		r.notifier.SetConfig(notifyConfig(m))
+		return nil
+	case "setClipHistory":
+		// { type: "setClipHistory", enabled: bool } - opt-in clipboard watcher; disabling forgets all clips
+		enabled, _ := m["enabled"].(bool)
+		r.clips.setEnabled(enabled)
+		return SendJSON(conn, map[string]any{"type": "clips", "enabled": r.clips.enabled(), "clips": r.clips.list()})
+	case "setGitCheckpoints":
+		// { type: "setGitCheckpoints", enabled: bool } - opt-in git checkpoint before each send
+		enabled, _ := m["enabled"].(bool)
+		r.mu.Lock()
+		r.gitCheckpoints = enabled
+		r.mu.Unlock()
+		return SendJSON(conn, map[string]any{"type": "gitCheckpoints", "enabled": enabled})
+	case "listCheckpoints":
+		// { type: "listCheckpoints", sessionId: string } -> { type: "checkpointList", checkpoints: [{id, createdAt, message}] }
+		sid, _ := m["sessionId"].(string)
+		return r.listGitCheckpoints(conn, sid)
+	case "restoreCheckpoint":
+		// { type: "restoreCheckpoint", sessionId: string, checkpointId: string } - needs confirmation
+		sid, _ := m["sessionId"].(string)
+		id, _ := m["checkpointId"].(string)
+		return r.restoreGitCheckpoint(conn, sid, id)
+	case "setPromptPrefix":
+		// { type: "setPromptPrefix", sessionId: string, enabled?: bool } -> { type: "promptPrefix", enabled, prefix }
+		sid, _ := m["sessionId"].(string)
+		var enabled *bool
+		if v, ok := m["enabled"].(bool); ok {
+			enabled = &v
+		}
+		return r.setPromptPrefix(conn, sid, enabled)
+	case "listTasks":
+		// { type: "listTasks", sessionId?: string } -> .rovobridge/tasks.json or detected go/npm tests
+		sid, _ := m["sessionId"].(string)
+		return r.listTasks(conn, sid)
+	case "runTask":
+		// { type: "runTask", sessionId: string, name: string } -> taskStarted, then taskResult
+		sid, _ := m["sessionId"].(string)
+		name, _ := m["name"].(string)
+		return r.runTask(conn, sid, name)
+	case "cancelTask":
+		// { type: "cancelTask", sessionId: string }
+		sid, _ := m["sessionId"].(string)
+		return r.cancelTask(conn, sid)
+	case "listPorts":
+		// { type: "listPorts" } -> { type: "ports", ports: [{port, sessionId, detectedAt}] }
+		return r.listPorts(conn)
+	case "listCodeBlocks":
+		// { type: "listCodeBlocks", sessionId: string, language?: string, afterId?: number }
+		// -> { type: "codeBlocks", sessionId, blocks: [{id, language, content, start, end, ...}] }
+		sid, _ := m["sessionId"].(string)
+		language, _ := m["language"].(string)
+		return r.listCodeBlocks(conn, sid, language, asInt(m["afterId"]))
+	case "applyCodeBlock":
+		// { type: "applyCodeBlock", sessionId: string, blockId: number, path: string, startLine?: number,
+		//   endLine?: number, dryRun?: bool } -> codeBlockPreview; without dryRun codeBlockApplied after confirm
+		sid, _ := m["sessionId"].(string)
+		path, _ := m["path"].(string)
+		dryRun, _ := m["dryRun"].(bool)
+		edit := codeBlockEdit{Path: path, StartLine: asInt(m["startLine"]), EndLine: asInt(m["endLine"])}
+		return r.applyCodeBlock(conn, sid, asInt(m["blockId"]), edit, dryRun)
+	case "readFile":
+		// { type: "readFile", sessionId?: string, path: string } -> fileContent with the hash and mtime
+		// saveFile checks
+		sid, _ := m["sessionId"].(string)
+		path, _ := m["path"].(string)
+		return r.readFile(conn, sid, path)
+	case "saveFile":
+		// { type: "saveFile", sessionId?: string, path: string, content: string, startLine?: number,
+		//   endLine?: number, baseHash?: string, baseMtime?: number, create?: bool } -> fileSaved after confirm,
+		//   or error fileConflict when the file changed since it was read
+		sid, _ := m["sessionId"].(string)
+		var edit quickEdit
+		edit.Path, _ = m["path"].(string)
+		edit.Content, _ = m["content"].(string)
+		edit.StartLine, edit.EndLine = asInt(m["startLine"]), asInt(m["endLine"])
+		edit.BaseHash, _ = m["baseHash"].(string)
+		edit.BaseMtime = int64(asInt(m["baseMtime"]))
+		edit.Create, _ = m["create"].(bool)
+		return r.saveFile(conn, sid, edit)
+	case "openProxy":
+		// { type: "openProxy", port: number } -> { type: "proxyUrl", port, url } (one-time ticket)
+		return r.openProxy(conn, asInt(m["port"]))
+	case "tailFile":
+		// { type: "tailFile", sessionId?: string, path: string, lines?: number } -> tailStarted, then
+		// tailLines as the file grows; the path must be inside the session's working directory
+		sid, _ := m["sessionId"].(string)
+		path, _ := m["path"].(string)
+		return r.startTail(conn, sid, path, asInt(m["lines"]))
+	case "stopTail":
+		// { type: "stopTail", tailId: string }
+		id, _ := m["tailId"].(string)
+		return r.stopTail(conn, id)
+	case "diagnostics":
+		// { type: "diagnostics" } -> { type: "diagnosticsReport", status, report: {os, arch, goVersion, checks: [{name, status, detail}]} }
+		return r.runDoctor(conn)
+	case "checkUpdate":
+		// { type: "checkUpdate" } -> { type: "updateInfo", current, latest, available }
+		return r.checkUpdate(conn)
+	case "transferSession":
+		// { type: "transferSession", sessionId: string, toEditor?: bool } -> transferOffered with a token
+		// for another client to claim; only the connection controlling the session may offer it
+		sid, _ := m["sessionId"].(string)
+		toEditor, _ := m["toEditor"].(bool)
+		return r.offerTransfer(conn, sid, toEditor)
+	case "claimSession":
+		// { type: "claimSession", token: string } -> opened + snapshot; both sides get sessionTransferred
+		token, _ := m["token"].(string)
+		return r.claimTransfer(ctx, conn, token)
+	case "broadcastSend":
+		// { type: "broadcastSend", sessionIds: string[], dataBase64: string, contexts?: {[sessionId]: string},
+		//   tag?: string, ...send options } -> broadcastStarted; each session's output is tagged with broadcastId
+		return r.broadcastSend(ctx, conn, m)
+	case "getStats":
+		// { type: "getStats" } - connection and session counters, including slow-client evictions,
+		// and the disk space the janitor reclaimed
+		r.mu.Lock()
+		s := r.server
+		jan := r.janitor
+		sessions := len(r.sessions)
+		rejected := r.stdinRejected
+		latency := r.promptLatency.summary()
+		r.mu.Unlock()
+		reply := map[string]any{"type": "stats", "sessions": sessions, "stdinRejectedBytes": rejected}
+		if latency != nil {
+			reply["promptLatency"] = latency
+		}
+		if s != nil {
+			reply["connections"] = s.Stats()
+		}
+		if jan != nil {
+			reply["janitor"] = jan.Stats()
+		}
+		return SendJSON(conn, reply)
+	case "dashboard":
+		// { type: "dashboard" } -> every session's state, uptime, activity, resource usage and queues
+		return r.dashboard(conn)
+	case "usageStats":
+		// { type: "usageStats", days?: number } - token, request and cost totals per session and per day
+		return r.sendUsageStats(conn, asInt(m["days"]))
+	case "listClips":
+		return SendJSON(conn, map[string]any{"type": "clips", "enabled": r.clips.enabled(), "clips": r.clips.list()})
+	case "registerSnippet":
+		// { type: "registerSnippet", name: string, text: string } - in-memory file injectable via its path
+		name, _ := m["name"].(string)
+		text, _ := m["text"].(string)
+		path, err := r.registerSnippet(name, text)
+		if err != nil {
+			Errorf(conn, "registerSnippet: %v", err)
+			return nil
+		}
+		return SendJSON(conn, map[string]any{"type": "snippetRegistered", "name": strings.TrimSpace(name), "path": path, "bytes": len(text)})
+	case "removeSnippet":
+		// { type: "removeSnippet", name: string } - name or snippet path
+		name, _ := m["name"].(string)
+		if !r.removeSnippet(name) {
+			Errorf(conn, "removeSnippet: unknown snippet %q", name)
+			return nil
+		}
+		return SendJSON(conn, map[string]any{"type": "snippets", "snippets": r.snippetList()})
+	case "listSnippets":
+		return SendJSON(conn, map[string]any{"type": "snippets", "snippets": r.snippetList()})
+	case "openInEditor":
+		// { type: "openInEditor", sessionId?: string, path: string, line?: number, column?: number }
+		path, _ := m["path"].(string)
+		if path == "" {
+			Errorf(conn, "openInEditor: missing path")
+			return nil
+		}
+		sid, _ := m["sessionId"].(string)
+		if r.openInEditor(sid, path, asInt(m["line"]), asInt(m["column"])) == 0 {
+			Errorf(conn, "openInEditor: no IDE plugin attached")
+		}
+		return nil
+	case "setEditorContext":
+		// { type: "setEditorContext", workspace?: string, file?: string, selection?: {...}, cursor?: {...} }
+		// Pushed by IDE plugins; expanded into {currentFile}/{selection} placeholders on send
+		workspace, _ := m["workspace"].(string)
+		workspace = editorWorkspaceKey(workspace)
+		r.setEditorContext(workspace, parseEditorContext(m, workspace))
+		return nil
 	case "updateSessionConfig":
 		// Allow dynamic updates to session configuration
+		// { type: "updateSessionConfig", customCommand?: string, outputEncoding?: string }
+		newEncoding, setEncoding := m["outputEncoding"].(string)
+		if setEncoding {
+			if _, err := lookupOutputEncoding(newEncoding); err != nil {
+				Errorf(conn, "updateSessionConfig: %v", err)
+				return nil
+			}
+			r.mu.Lock()
+			r.outputEncoding = strings.TrimSpace(newEncoding)
+			r.mu.Unlock()
+		}
 		if newCmd, ok := m["customCommand"].(string); ok {
-			r.customCommand = newCmd
-			// Broadcast updated config to all connected clients
+			apply := func() error {
+				r.mu.Lock()
+				r.customCommand = newCmd
+				r.mu.Unlock()
+				r.warm.reset(r.warmConfig())
+				// Broadcast updated config to all connected clients
+				return SendJSON(conn, map[string]any{
+					"type":          "sessionConfigUpdated",
+					"sessionConfig": r.getSessionConfig(),
+				})
+			}
+			// A different command runs in every new session; clearing it restores the default
+			if current, _ := r.sessionDefaults(); newCmd == current || strings.TrimSpace(newCmd) == "" {
+				return apply()
+			}
+			if err := r.policy.Check("updateSessionConfig", strings.Fields(newCmd)[0]); err != nil {
+				Errorf(conn, "updateSessionConfig: %v", err)
+				return nil
+			}
+			return r.requireConfirmation(conn, "updateSessionConfig", fmt.Sprintf("Run %q in new sessions", newCmd), apply)
+		}
+		if setEncoding {
 			return SendJSON(conn, map[string]any{
 				"type":          "sessionConfigUpdated",
 				"sessionConfig": r.getSessionConfig(),
 			})
 		}
 		return nil
+	case "confirm":
+		// { type: "confirm", token: string, approved?: bool } - answers a confirmationRequired challenge
+		token, _ := m["token"].(string)
+		approved := true
+		if v, ok := m["approved"].(bool); ok {
+			approved = v
+		}
+		return r.resolveConfirmation(conn, token, approved)
+	case "respondPermission":
+		// { type: "respondPermission", sessionId: string, requestId: string, choice: "yes"|"no"|"always" }
+		// Answers a permissionRequest by typing the choice into the session
+		sid, _ := m["sessionId"].(string)
+		requestID, _ := m["requestId"].(string)
+		choice, _ := m["choice"].(string)
+		return r.respondPermission(conn, sid, requestID, choice)
+	case "reloadAgent":
+		// { type: "reloadAgent", sessionId: string, tailLines?: number, reinject?: bool, summaryLines?: number }
+		// Restarts the session's command, answering with opened, then agentReloaded and the transcript
+		sid, _ := m["sessionId"].(string)
+		return r.reloadAgent(ctx, conn, sid, m)
 	case "openSession":
-		id := "s1"
-		if v, ok := m["id"].(string); ok {
-			id = v
+		// id is the client's alias for the session; the session id was picked when the
+		// message was read (see sessionids.go)
+		alias, resumeReq := openSessionAlias(m)
+		id, _ := m["sessionId"].(string)
+		if id == "" {
+			id = r.openSessionTarget(conn, alias, resumeReq)
 		}
 		cmd, _ := m["cmd"].(string)
 		args, _ := anyToStrings(m["args"])
-		resumeReq := false
-		if v, ok := m["resume"].(bool); ok {
-			resumeReq = v
-		}
+		customCommand, defaultEncoding := r.sessionDefaults()
 		cols := asInt(m["cols"])
 		rows := asInt(m["rows"])
+		startup, err := startupOptions(m)
+		if err != nil {
+			Errorf(conn, "openSession: bad readyPattern: %v", err)
+			return nil
+		}
+		live, err := livenessOptions(m, startup.marker, cols, rows)
+		if err != nil {
+			Errorf(conn, "openSession: %v", err)
+			return nil
+		}
+		encName, ok := m["outputEncoding"].(string)
+		if !ok {
+			encName = defaultEncoding
+		}
+		outputEnc, err := lookupOutputEncoding(encName)
+		if err != nil {
+			Errorf(conn, "openSession: %v", err)
+			return nil
+		}
 
 		// If resume requested and session exists, adopt without restarting
 		r.mu.Lock()
 		existing := r.sessions[id]
+		if resumeReq && existing == nil && r.events[id] != nil && r.events[id].exited {
+			// The process exited while the client was away: report that instead of
+			// silently starting a new one; a plain openSession restarts it
+			r.mu.Unlock()
+			r.replayEvents(conn, id)
+			return nil
+		}
 		if _, ok := r.sessionStates[id]; !ok {
 			r.sessionStates[id] = &sessionState{}
 		}
+		if _, ok := r.aliases[id]; !ok {
+			r.aliases[id] = alias
+		}
 		st := r.sessionStates[id]
 		r.mu.Unlock()
 		if resumeReq && existing != nil {
@@ -225,6 +925,9 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			}
 			st.mu.Lock()
 			st.currentConn = conn
+			if st.formerConn == conn {
+				st.formerConn = nil
+			}
 			if st.orphanTimer != nil {
 				st.orphanTimer.Stop()
 				st.orphanTimer = nil
@@ -241,36 +944,45 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 				_ = existing.Resize(cols, rows)
 			}
 
-			// Load prompt history for session resume with enhanced error handling
-			promptHistory, err := r.historyManager.LoadHistory()
-			if err != nil {
-				log.Printf("Failed to load prompt history for session resume: %v", err)
-				promptHistory = []history.PromptHistoryEntry{} // Continue with empty history
-			}
-
-			// Ack opened and proactively send a snapshot; include PID, resumed=true, and prompt history
-			SendJSON(conn, map[string]any{
-				"type":          "opened",
-				"id":            m["id"],
-				"sessionId":     id,
-				"pid":           existing.PID(),
-				"resumed":       true,
-				"promptHistory": promptHistory,
-			})
+			// The newest prompt history (plus the workspace prompt library); the client pages the rest
+			promptHistory := r.openedPromptHistory(ctx, id, asInt(m["historyLimit"]))
+
 			st.mu.Lock()
 			data := make([]byte, len(st.replay))
 			copy(data, st.replay)
 			last := st.lastSeq
+			notes := st.notesUnsafe()
+			starting := st.startup.pending
+			plain := st.plainText
 			st.mu.Unlock()
+			// Ack opened and proactively send a snapshot; include PID, resumed=true, prompt history and notes
+			opened := map[string]any{
+				"type":      "opened",
+				"id":        m["id"],
+				"sessionId": id,
+				"alias":     r.sessionAlias(id),
+				"pid":       existing.PID(),
+				"resumed":   true,
+			}
+			promptHistory.addTo(opened)
+			if len(notes) > 0 {
+				opened["notes"] = notes
+			}
+			if starting {
+				opened["starting"] = true
+			}
+			SendJSON(conn, opened)
+			r.logSessionEvent(id, "resumed", map[string]any{"pid": existing.PID(), "cols": cols, "rows": rows})
 			data = sanitizeSnapshot(data)
-			SendJSON(conn, map[string]any{"type": "snapshot", "sessionId": id, "dataBase64": base64.StdEncoding.EncodeToString(data), "lastSeq": last})
+			SendJSON(conn, r.snapshotMessage(conn, id, data, last, plain))
+			r.replayEvents(conn, id)
 			return nil
 		}
 
 		// Override with custom command if provided via --cmd flag
-		if r.customCommand != "" {
+		if customCommand != "" {
 			// Parse custom command into cmd and args
-			parts := strings.Fields(r.customCommand)
+			parts := strings.Fields(customCommand)
 			if len(parts) > 0 {
 				cmd = parts[0]
 				if len(parts) > 1 {
@@ -281,28 +993,54 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			}
 		}
 
-		env, _ := anyToStrings(m["env"]) // ["KEY=VALUE", ...]
 		dir, _ := m["cwd"].(string)
-		ptyFlag := true
-		if v, ok := m["pty"].(bool); ok {
-			ptyFlag = v
+		// The session starts cmd in dir, so the policy checks, and the session runs, the
+		// executable found from there
+		if r.policy != nil {
+			cmd = policy.Resolve(cmd, dir)
 		}
+		if err := r.policy.Check("openSession", cmd); err != nil {
+			Errorf(conn, "failed to start: %v", err)
+			return nil
+		}
+
+		env, _ := anyToStrings(m["env"]) // ["KEY=VALUE", ...]
+		plain, ptyFlag, env := plainTextOptions(m, env)
+		env, termWarning := r.checkTerm(id, env, m)
 		mode := session.ModeAutoPTY
 		if !ptyFlag {
 			mode = session.ModeNoPTY
 		}
 
+		r.clearEvents(id)
+		root := dir
+		if root == "" {
+			root, _ = os.Getwd()
+		}
+		tree := takeTreeSnapshot(root)
 		ctx, cancel := context.WithCancel(context.Background())
-		sess, err := session.Start(ctx, session.Config{Cmd: cmd, Args: args, Env: env, Dir: dir, Mode: mode})
-		if err != nil {
-			// Ensure we do not leak context when start fails
-			cancel()
-			Errorf(conn, "failed to start: %v", err)
-			return nil
+		cfg := session.Config{Cmd: cmd, Args: args, Env: env, Dir: dir, Mode: mode}
+		// Take over a process the warm pool started ahead, if one matches
+		sess := r.warm.take(cfg)
+		warm := sess != nil
+		if !warm {
+			var err error
+			sess, err = r.startSession(ctx, cfg)
+			if err != nil {
+				// Ensure we do not leak context when start fails
+				cancel()
+				r.logSessionEvent(id, "startFailed", map[string]any{"cmd": cmd, "args": args, "error": err.Error()})
+				Errorf(conn, "failed to start: %v", err)
+				return nil
+			}
 		}
+		sess = transcodeSession(sess, outputEnc)
+		inputRec := r.startInputRecording(id, cmd, args)
+		sess = recordInputSession(sess, inputRec)
 		if cols > 0 && rows > 0 {
 			_ = sess.Resize(cols, rows)
 		}
+		rec := r.startRecording(id, cols, rows, cmd, args)
 		r.mu.Lock()
 		// If a previous session with the same id exists and not resuming, mark to suppress its exit and close it
 		if old := r.sessions[id]; old != nil {
@@ -323,8 +1061,10 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			r.sessionStates[id] = &sessionState{}
 		}
 		st = r.sessionStates[id]
+		collapse := r.collapseSpinners
 		r.mu.Unlock()
 		// initialize/attach state
+		st.sendMu.Lock()
 		st.mu.Lock()
 		// Persist caller-provided useClipboard if present, default true otherwise
 		if v, ok := m["useClipboard"].(bool); ok {
@@ -333,9 +1073,20 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			st.useClipboard = true
 		}
 		st.replay = nil
+		st.replayScan = replayScanner{}
+		st.collapseFrames = collapse
 		st.lastSeq = 0
+		st.sentBytes = 0
 		st.currentConn = conn
 		st.suppressNextExit = false // clear any suppression from the previously replaced session
+		st.formerConn = nil
+		st.notes = nil
+		st.timeline = outputTimeline{}
+		st.redactor = redact.Stream{}
+		if st.redactTimer != nil {
+			st.redactTimer.Stop()
+			st.redactTimer = nil
+		}
 		// Store working directory for prompt history
 		if dir != "" {
 			st.workingDir = dir
@@ -354,30 +1105,68 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			st.throttleTimer = nil
 		}
 		st.outBuf = nil
+		st.mirror = plainTextMirror{}
+		st.plainText, st.plainPending = plain, ""
+		st.codeBlocks = codeBlockTracker{}
+		st.permLines, st.permission, st.permAnswered = nil, nil, ""
+		st.promptPending, st.promptSentAt = false, time.Time{}
 		st.lastSend = time.Time{}
 		st.needImmediate = false
+		st.label, _ = m["label"].(string)
+		st.lastInput = time.Time{}
+		st.openRequest = openRequestOf(m)
+		st.carryOver, _ = m[carryOverKey].(string)
+		prefixOn, ok := m["promptPrefix"].(bool)
+		st.promptPrefixOff = ok && !prefixOn
+		// retire the replaced process's pipeline before this one starts writing
+		if st.cancel != nil {
+			st.cancel()
+		}
+		st.ctx, st.cancel = ctx, cancel
+		r.beginStartupUnsafe(ctx, id, st, startup)
+		st.liveness.stopUnsafe()
+		st.liveness = live
+		st.stopRecordingUnsafe()
+		st.recorder, st.inputRecorder = rec, inputRec
+		st.tree = tree
 		st.mu.Unlock()
+		st.sendMu.Unlock()
 
-		// Load prompt history for session initialization with enhanced error handling
-		promptHistory, err := r.historyManager.LoadHistory()
-		if err != nil {
-			log.Printf("Failed to load prompt history for session initialization: %v", err)
-			promptHistory = []history.PromptHistoryEntry{} // Continue with empty history
-		}
+		// The newest prompt history (plus the workspace prompt library) for session initialization
+		promptHistory := r.openedPromptHistory(ctx, id, asInt(m["historyLimit"]))
 
 		// Send opened with PID, resumed=false, and prompt history
-		SendJSON(conn, map[string]any{
-			"type":          "opened",
-			"id":            m["id"],
-			"sessionId":     id,
-			"pid":           sess.PID(),
-			"resumed":       false,
-			"promptHistory": promptHistory,
+		opened := map[string]any{
+			"type":      "opened",
+			"id":        m["id"],
+			"sessionId": id,
+			"alias":     r.sessionAlias(id),
+			"pid":       sess.PID(),
+			"resumed":   false,
+		}
+		promptHistory.addTo(opened)
+		if warm {
+			opened["warm"] = true
+		}
+		SendJSON(conn, opened)
+		r.logSessionEvent(id, "opened", map[string]any{
+			"pid": sess.PID(), "cmd": cmd, "args": args, "cwd": r.sessionWorkingDir(id), "cols": cols, "rows": rows, "warm": warm,
 		})
-		go r.pipeStdout(id, sess)
-		go func(localID string, localSess *session.Session) {
+		if termWarning != nil {
+			r.logSessionEvent(id, "termUnsupported", map[string]any{"term": termWarning["term"], "downgraded": termWarning["downgraded"]})
+			r.recordEvent(id, termWarning)
+			SendJSON(conn, termWarning)
+		}
+		piped := make(chan struct{})
+		go r.pipeStdout(ctx, id, st, sess, piped)
+		go func(localID string, localSess ptySession) {
 			defer cancel()
 			err := localSess.Wait()
+			// let the pipeline drain what the process wrote before it exited
+			select {
+			case <-piped:
+			case <-time.After(stdoutDrainTimeout):
+			}
 			// check if this session is still the current one; if replaced, do not cleanup or notify
 			r.mu.Lock()
 			current := r.sessions[localID]
@@ -389,6 +1178,7 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			}
 			// notify current connection if present
 			if st != nil {
+				r.flushSession(ctx, localID, st)
 				st.mu.Lock()
 				suppress := st.suppressNextExit
 				if suppress {
@@ -405,35 +1195,73 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 					st.throttleTimer.Stop()
 					st.throttleTimer = nil
 				}
+				if st.idleTimer != nil {
+					st.idleTimer.Stop()
+					st.idleTimer = nil
+				}
+				st.startup.stopUnsafe()
+				st.liveness.stopUnsafe()
+				st.stopRecordingUnsafe()
 				st.mu.Unlock()
-				if c != nil && !suppress {
-					SendJSON(c, map[string]any{"type": "exit", "sessionId": localID, "code": exitCode(err)})
+				code := exitCode(err)
+				r.logSessionEvent(localID, "exit", map[string]any{"code": code, "replaced": suppress})
+				if !suppress {
+					exit := map[string]any{"type": "exit", "sessionId": localID, "code": code}
+					r.recordEvent(localID, exit)
+					if c != nil {
+						SendJSON(c, exit)
+					}
+				}
+				if code != 0 && !suppress {
+					r.notifier.Notify(notify.EventExit, "RovoBridge", fmt.Sprintf("The session exited with code %d", code))
 				}
 			}
-			// cleanup maps (still the same session)
+			// cleanup maps unless a new process took the id over in the meantime
 			r.mu.Lock()
-			delete(r.sessions, localID)
-			delete(r.sessionStates, localID)
+			if r.sessions[localID] == localSess {
+				delete(r.sessions, localID)
+				delete(r.sessionStates, localID)
+				r.forgetSessionPortsUnsafe(localID)
+			}
 			r.mu.Unlock()
 		}(id, sess)
 	case "stdin":
 		sid, _ := m["sessionId"].(string)
 		dataB64, _ := m["dataBase64"].(string)
+		r.mu.Lock()
+		sess := r.sessions[sid]
+		st := r.sessionStates[sid]
+		lim := r.stdinLimits
+		r.mu.Unlock()
+		// Refuse oversized messages before decoding them
+		if n := base64.StdEncoding.DecodedLen(len(dataB64)); lim.MaxMessageBytes > 0 && n > lim.MaxMessageBytes+2 {
+			r.rejectStdin(conn, sid, "stdinTooLarge", n)
+			return nil
+		}
 		b, err := base64.StdEncoding.DecodeString(dataB64)
 		if err != nil {
 			Errorf(conn, "bad base64")
 			return nil
 		}
-		r.mu.Lock()
-		sess := r.sessions[sid]
-		st := r.sessionStates[sid]
-		r.mu.Unlock()
+		if r.rejectHandedOff(conn, sid, st) {
+			return nil
+		}
+		if st != nil {
+			st.mu.Lock()
+			code := st.checkStdinUnsafe(len(b), lim, time.Now())
+			st.mu.Unlock()
+			if code != "" {
+				r.rejectStdin(conn, sid, code, len(b))
+				return nil
+			}
+		}
 
 		// Save history entry first (non-blocking), even if there's no active session
 		if historyData, ok := m["historyEntry"].(map[string]any); ok {
 			// Extract history entry fields
 			id, _ := historyData["id"].(string)
 			serializedContent, _ := historyData["serializedContent"].(string)
+			meta := promptMetadata(historyData, sid)
 
 			// Determine projectCwd using session state if available
 			var projectCwd string
@@ -451,10 +1279,12 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 
 			// Save prompt to history with frontend-provided ID (async to avoid blocking)
 			go func() {
-				if err := r.historyManager.SavePromptWithID(id, serializedContent, projectCwd); err != nil {
+				if _, err := r.historyManager.SavePromptWithMetadata(id, serializedContent, projectCwd, meta); err != nil {
 					log.Printf("Failed to save prompt to history (non-blocking): %v", err)
 				}
 			}()
+			// The prompt is sent, so its draft is no longer needed
+			r.drafts.SaveDraft(projectCwd, sid, "")
 		}
 
 		// If there's no active session, we still saved the history above.
@@ -466,7 +1296,12 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		_, _ = sess.Stdin().Write(b)
 		// Mark that the next stdout should be sent immediately.
 		if st != nil {
+			if st.promptInput(b, m) {
+				r.livenessBusy(sid, st)
+			}
+			r.permissionAnsweredInTerminal(sid, st, b)
 			st.mu.Lock()
+			st.lastInput = time.Now()
 			// If there's already buffered output and an active connection, flush it now; else mark immediate
 			if len(st.outBuf) > 0 && st.currentConn != nil {
 				st.needImmediate = false
@@ -487,9 +1322,19 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		rows := asInt(m["rows"])
 		r.mu.Lock()
 		sess := r.sessions[sid]
+		st := r.sessionStates[sid]
 		r.mu.Unlock()
 		if sess != nil {
 			_ = sess.Resize(cols, rows)
+			r.logSessionEvent(sid, "resized", map[string]any{"cols": cols, "rows": rows})
+			if st != nil && cols > 0 && rows > 0 {
+				st.mu.Lock()
+				if st.recorder != nil {
+					st.recorder.Resize(cols, rows)
+				}
+				st.liveness.cols, st.liveness.rows = cols, rows
+				st.mu.Unlock()
+			}
 		}
 	case "injectFiles":
 		// Inject file contents either directly or via clipboard+paste depending on session preference
@@ -506,8 +1351,17 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			return nil
 		}
 
+		if st != nil {
+			st.mu.Lock()
+			st.rememberInjectedUnsafe(paths)
+			st.mu.Unlock()
+		}
+
 		// Read file contents once
-		contents := fileutil.ReadMultipleFiles(paths)
+		contents, ok := r.readFilesForInjection(ctx, conn, sid, paths, readOptions(m), 0)
+		if !ok {
+			return nil
+		}
 		var b strings.Builder
 		for _, content := range contents {
 			if content == "" {
@@ -532,15 +1386,17 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		}
 		if useClipboard {
 			// 1) backup clipboard, 2) set payload exact as-is, 3) send Ctrl+V, 4) restore clipboard after terminal becomes idle (~1s)
-			prev, prevErr := getClipboard()
-			if err := setClipboard(payload); err == nil {
+			prev, prevErr := r.readClipboard(ctx)
+			r.clips.ignore(payload)
+			if err := r.writeClipboard(ctx, payload); err == nil {
 				// send Ctrl+V (0x16)
 				r.waitStdoutIdle(sid, 2*stdoutThrottleInterval)
 				_, _ = sess.Stdin().Write([]byte{0x16})
 				// Restore previous clipboard content after terminal output becomes idle
 				r.waitStdoutIdle(sid, 1*time.Second)
 				if prevErr == nil {
-					_ = setClipboard(prev)
+					// Restore even if the client left in the meantime
+					_ = r.writeClipboard(context.WithoutCancel(ctx), prev)
 				}
 				return nil
 			}
@@ -600,9 +1456,10 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		data := make([]byte, len(st.replay))
 		copy(data, st.replay)
 		last := st.lastSeq
+		plain := st.plainText
 		st.mu.Unlock()
 		data = sanitizeSnapshot(data)
-		return SendJSON(conn, map[string]any{"type": "snapshot", "sessionId": sid, "dataBase64": base64.StdEncoding.EncodeToString(data), "lastSeq": last})
+		return SendJSON(conn, r.snapshotMessage(conn, sid, data, last, plain))
 	case "fontSizeChanged":
 		// Frontend notifies that font size has changed in the UI
 		fontSize := asInt(m["fontSize"])
@@ -653,6 +1510,7 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 
 			// Determine projectCwd from session state if available
 			sid, _ := m["sessionId"].(string)
+			meta := promptMetadata(historyData, sid)
 			var projectCwd string
 			r.mu.Lock()
 			st := r.sessionStates[sid]
@@ -668,14 +1526,226 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 				}
 			}
 
-			// Save asynchronously; do not block router
+			// Save asynchronously; do not block router. Reply once stored with the entry as
+			// written (final ID, timestamp, normalized projectCwd) so the client can update
+			// its list without reloading the whole history.
 			go func() {
-				if err := r.historyManager.SavePromptWithID(id, serializedContent, projectCwd); err != nil {
+				entry, err := withTimeout(ctx, r.getTimeouts().History, "saving the prompt", func(context.Context) (history.PromptHistoryEntry, error) {
+					return r.historyManager.SavePromptWithMetadata(id, serializedContent, projectCwd, meta)
+				})
+				if err != nil {
 					log.Printf("Failed to save prompt via savePrompt: %v", err)
+					_ = SendJSON(conn, map[string]any{"type": "promptSaved", "id": id, "error": err.Error()})
+					return
 				}
+				if entry.ID == "" {
+					// empty prompt, nothing stored
+					_ = SendJSON(conn, map[string]any{"type": "promptSaved", "id": id})
+					return
+				}
+				_ = SendJSON(conn, map[string]any{"type": "promptSaved", "id": id, "entry": entry})
 			}()
+			return nil
 		}
 		return SendJSON(conn, map[string]any{"type": "promptSaved"})
+	case "saveDraft":
+		// { type: "saveDraft", sessionId?: string, serializedContent: string }; empty content clears the draft
+		sid, _ := m["sessionId"].(string)
+		content, _ := m["serializedContent"].(string)
+		draft := r.drafts.SaveDraft(r.sessionWorkingDir(sid), sid, content)
+		return SendJSON(conn, map[string]any{"type": "draftSaved", "sessionId": sid, "updatedAt": draft.UpdatedAt})
+	case "loadDraft":
+		// { type: "loadDraft", sessionId?: string } -> falls back to the workspace's latest draft
+		sid, _ := m["sessionId"].(string)
+		draft, ok := r.drafts.LoadDraft(r.sessionWorkingDir(sid), sid)
+		if !ok {
+			return SendJSON(conn, map[string]any{"type": "draft", "sessionId": sid})
+		}
+		return SendJSON(conn, map[string]any{"type": "draft", "sessionId": sid, "draft": draft})
+	case "addNote", "removeNote", "listNotes":
+		// { type: "addNote", sessionId: string, text?: string, offset?: number, bookmark?: bool } -> noteAdded
+		// { type: "removeNote", sessionId: string, noteId: string } -> noteRemoved
+		// { type: "listNotes", sessionId: string } -> notes
+		return r.handleNote(conn, m)
+	case "setRedaction":
+		// { type: "setRedaction", enabled: bool } -> redaction; turning it off needs confirmation
+		enabled, _ := m["enabled"].(bool)
+		return r.setRedaction(conn, enabled)
+	case "resolveTime":
+		// { type: "resolveTime", sessionId: string, time: number (Unix ms) | string (RFC 3339) }
+		// -> timeResolved with the stream offset of the first output printed at or after time
+		sid, _ := m["sessionId"].(string)
+		t, ok := parseTime(m["time"])
+		if !ok {
+			Errorf(conn, "resolveTime: bad time")
+			return nil
+		}
+		return r.resolveTime(conn, sid, t)
+	case "createCheckpoint":
+		// { type: "createCheckpoint", sessionId: string, prompt?: string, paths?: [string] }
+		// Without paths, the files injected so far in the session are captured.
+		sid, _ := m["sessionId"].(string)
+		prompt, _ := m["prompt"].(string)
+		paths, _ := anyToStrings(m["paths"])
+		r.mu.Lock()
+		st := r.sessionStates[sid]
+		r.mu.Unlock()
+		if st == nil {
+			Errorf(conn, "no session")
+			return nil
+		}
+		cp := st.createCheckpoint(prompt, paths)
+		return SendJSON(conn, map[string]any{"type": "checkpointCreated", "sessionId": sid, "checkpoint": cp})
+	case "diffSinceCheckpoint":
+		// { type: "diffSinceCheckpoint", sessionId: string, checkpointId?: string } (latest by default)
+		sid, _ := m["sessionId"].(string)
+		cpID, _ := m["checkpointId"].(string)
+		r.mu.Lock()
+		st := r.sessionStates[sid]
+		r.mu.Unlock()
+		if st == nil {
+			Errorf(conn, "no session")
+			return nil
+		}
+		cp, ok := st.findCheckpoint(cpID)
+		if !ok {
+			Errorf(conn, "unknown checkpoint")
+			return nil
+		}
+		st.mu.Lock()
+		workingDir := st.workingDir
+		currentSeq := st.lastSeq
+		st.mu.Unlock()
+		return SendJSON(conn, map[string]any{
+			"type":         "checkpointDiff",
+			"sessionId":    sid,
+			"checkpointId": cp.ID,
+			"seq":          cp.Seq,
+			"currentSeq":   currentSeq,
+			"changed":      diffCheckpoint(cp, workingDir),
+		})
+	case "sessionDiff":
+		// { type: "sessionDiff", sessionId: string } -> files created, modified or deleted
+		// since the session's process started
+		sid, _ := m["sessionId"].(string)
+		r.mu.Lock()
+		st := r.sessionStates[sid]
+		r.mu.Unlock()
+		if st == nil {
+			Errorf(conn, "no session")
+			return nil
+		}
+		st.mu.Lock()
+		tree := st.tree
+		st.mu.Unlock()
+		if tree == nil {
+			Errorf(conn, "sessionDiff: no snapshot for session")
+			return nil
+		}
+		changed, truncated := tree.diff()
+		return SendJSON(conn, map[string]any{
+			"type":      "sessionDiff",
+			"sessionId": sid,
+			"since":     tree.start.UnixMilli(),
+			"changed":   changed,
+			"truncated": truncated,
+		})
+	case "suggestPrompts":
+		// { type: "suggestPrompts", text: string, paths?: [string], limit?: number, sessionId?: string }
+		text, _ := m["text"].(string)
+		paths, _ := anyToStrings(m["paths"])
+		limit := asInt(m["limit"])
+		sid, _ := m["sessionId"].(string)
+		projectCwd := r.sessionWorkingDir(sid)
+		suggestions, err := withTimeout(ctx, r.getTimeouts().History, "prompt suggestions", func(context.Context) ([]history.PromptSuggestion, error) {
+			return r.historyManager.SuggestSimilar(text, paths, projectCwd, limit)
+		})
+		if errors.Is(err, errTimeout) {
+			replyTimeout(conn, "suggestPrompts", err)
+			return nil
+		}
+		if err != nil {
+			log.Printf("Failed to compute prompt suggestions: %v", err)
+			suggestions = []history.PromptSuggestion{}
+		}
+		return SendJSON(conn, map[string]any{"type": "promptSuggestions", "suggestions": suggestions})
+	case "queryHistoryByPath":
+		// { type: "queryHistoryByPath", path: string } -> prompts whose chips referenced that file
+		path, _ := m["path"].(string)
+		if path == "" {
+			Errorf(conn, "missing path")
+			return nil
+		}
+		sid, _ := m["sessionId"].(string)
+		projectCwd := r.sessionWorkingDir(sid)
+		entries, err := withTimeout(ctx, r.getTimeouts().History, "history query", func(context.Context) ([]history.PromptHistoryEntry, error) {
+			entries, err := r.historyManager.FindByReferencedPath(path)
+			if err != nil {
+				log.Printf("Failed to query history by path %s: %v", path, err)
+				entries = []history.PromptHistoryEntry{}
+			}
+			project, err := history.LoadProjectPrompts(projectCwd)
+			if err != nil {
+				log.Printf("Failed to load project prompt library: %v", err)
+			}
+			return history.MergeProjectPrompts(entries, history.FilterByReferencedPath(project, path)), nil
+		})
+		if err != nil {
+			replyTimeout(conn, "queryHistoryByPath", err)
+			return nil
+		}
+		return SendJSON(conn, map[string]any{"type": "historyByPath", "path": path, "entries": entries})
+	case "channelCredit":
+		// { type: "channelCredit", ch: number, bytes: number } - the client consumed bytes of a
+		// flow-controlled channel; handled in the read loop, ahead of queued messages
+		if !grantCredit(conn, asInt(m["ch"]), int64(asInt(m["bytes"]))) {
+			Errorf(conn, "channelCredit: needs a flow-controlled channel and a positive byte count")
+		}
+		return nil
+	case "rewriteHistoryPaths":
+		// { type: "rewriteHistoryPaths", renames?: [{from, to}], dryRun?: bool } -> historyPathsRewritten
+		var renames []history.PathRename
+		list, _ := m["renames"].([]any)
+		for _, v := range list {
+			rn, _ := v.(map[string]any)
+			from, _ := rn["from"].(string)
+			to, _ := rn["to"].(string)
+			renames = append(renames, history.PathRename{From: from, To: to})
+		}
+		dryRun, _ := m["dryRun"].(bool)
+		return r.rewriteHistoryPaths(ctx, conn, renames, dryRun)
+	case "setHistoryRenameRewrite":
+		// { type: "setHistoryRenameRewrite", enabled: bool } - rewrite the history as soon as files move
+		enabled, _ := m["enabled"].(bool)
+		r.SetHistoryRenameRewrite(enabled)
+		return SendJSON(conn, map[string]any{"type": "historyRenameRewrite", "enabled": enabled})
+	case "queryHistory":
+		// { type: "queryHistory", sessionId?, offset?, limit? } -> a page of the prompt history
+		sid, _ := m["sessionId"].(string)
+		return r.queryHistory(ctx, conn, sid, asInt(m["offset"]), asInt(m["limit"]))
+	case "saveProjectPrompt":
+		// { type: "saveProjectPrompt", sessionId?: string, historyEntry: { id?, title?, serializedContent } }
+		// Writes to <workspace>/.rovobridge/prompts.json so the template can be committed
+		historyData, _ := m["historyEntry"].(map[string]any)
+		id, _ := historyData["id"].(string)
+		title, _ := historyData["title"].(string)
+		serializedContent, _ := historyData["serializedContent"].(string)
+		sid, _ := m["sessionId"].(string)
+		entry, err := history.SaveProjectPrompt(r.sessionWorkingDir(sid), id, title, serializedContent)
+		if err != nil {
+			log.Printf("Failed to save project prompt: %v", err)
+			return SendJSON(conn, map[string]any{"type": "projectPromptSaved", "id": id, "error": err.Error()})
+		}
+		return SendJSON(conn, map[string]any{"type": "projectPromptSaved", "id": id, "entry": entry})
+	case "removeProjectPrompt":
+		// { type: "removeProjectPrompt", sessionId?: string, promptId: string }
+		promptId, _ := m["promptId"].(string)
+		sid, _ := m["sessionId"].(string)
+		if err := history.RemoveProjectPrompt(r.sessionWorkingDir(sid), promptId); err != nil {
+			log.Printf("Failed to remove project prompt %s: %v", promptId, err)
+			return SendJSON(conn, map[string]any{"type": "projectPromptRemoved", "promptId": promptId, "error": err.Error()})
+		}
+		return SendJSON(conn, map[string]any{"type": "projectPromptRemoved", "promptId": promptId})
 	case "removePrompt":
 
 		// Remove a prompt from history
@@ -722,12 +1792,58 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		sess := r.sessions[sid]
 		st := r.sessionStates[sid]
 		r.mu.Unlock()
+		if r.rejectHandedOff(conn, sid, st) {
+			return nil
+		}
+
+		// Expand {currentFile} and {selection} from the IDE state of the session's workspace
+		if len(textData) > 0 {
+			if ctx, workspace, ok := r.editorContextFor(r.sessionWorkingDir(sid)); ok {
+				textData = []byte(expandEditorPlaceholders(string(textData), ctx, workspace))
+			}
+		}
+
+		// injectOutputTail: N appends the session's last N output lines (ANSI-stripped), so a
+		// prompt like "fix the error above" carries the actual error text
+		if n := asInt(m["injectOutputTail"]); n > 0 && st != nil {
+			if n > maxOutputTailLines {
+				n = maxOutputTailLines
+			}
+			st.mu.Lock()
+			tail := outputTail(st.replay, n)
+			st.mu.Unlock()
+			if tail != "" {
+				textData = append(textData, "\n\nRecent terminal output:\n```\n"+tail+"\n```\n"...)
+			}
+		}
+
+		// injectTaskResult appends the outcome of the session's last runTask, e.g. the failing tests
+		if inject, _ := m["injectTaskResult"].(bool); inject && st != nil {
+			st.mu.Lock()
+			last := st.lastTask
+			st.mu.Unlock()
+			if last != nil {
+				textData = append(textData, "\n\n"+last.Summary()...)
+			}
+		}
+
+		// Hold back a send with likely mistakes until the client resends it with lintAcknowledged
+		if ack, _ := m["lintAcknowledged"].(bool); !ack {
+			var composedAt time.Time
+			if ms := asInt(m["composedAt"]); ms > 0 {
+				composedAt = time.UnixMilli(int64(ms))
+			}
+			if warnings := r.lintPrompt(string(textData), paths, composedAt); len(warnings) > 0 {
+				return sendPromptLint(conn, sid, warnings)
+			}
+		}
 
 		// Save history entry first (non-blocking), even if there's no active session
 		if historyData, ok := m["historyEntry"].(map[string]any); ok {
 			// Extract history entry fields
 			id, _ := historyData["id"].(string)
 			serializedContent, _ := historyData["serializedContent"].(string)
+			meta := promptMetadata(historyData, sid)
 
 			// Determine projectCwd using session state if available
 			var projectCwd string
@@ -745,7 +1861,7 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 
 			// Save prompt to history with frontend-provided ID (async to avoid blocking)
 			go func() {
-				if err := r.historyManager.SavePromptWithID(id, serializedContent, projectCwd); err != nil {
+				if _, err := r.historyManager.SavePromptWithMetadata(id, serializedContent, projectCwd, meta); err != nil {
 					log.Printf("Failed to save prompt to history (non-blocking): %v", err)
 				}
 			}()
@@ -756,6 +1872,14 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			Errorf(conn, "no session")
 			return nil
 		}
+		r.setBroadcastTag(st, m)
+
+		// Prepend the workspace prompt prefix; the event log records it apart from the text
+		prompt, prefix := textData, ""
+		if len(textData) > 0 || len(paths) > 0 {
+			prefix = r.sessionPromptPrefix(sid, st)
+			textData = withPromptPrefix(prefix, textData)
+		}
 
 		// Build combined payload: text + file contents
 		var combinedPayload strings.Builder
@@ -765,9 +1889,18 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 			combinedPayload.Write(textData)
 		}
 
-		// Add file contents if paths provided
+		// Add file contents if paths provided; read once, the direct-injection fallback reuses them
+		var contents []string
 		if len(paths) > 0 {
-			contents := fileutil.ReadMultipleFiles(paths)
+			if st != nil {
+				st.mu.Lock()
+				st.rememberInjectedUnsafe(paths)
+				st.mu.Unlock()
+			}
+			var ok bool
+			if contents, ok = r.readFilesForInjection(ctx, conn, sid, paths, readOptions(m), len(textData)); !ok {
+				return nil
+			}
 			for _, content := range contents {
 				if content == "" {
 					continue
@@ -783,6 +1916,18 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		if finalPayload == "" {
 			return nil
 		}
+		if len(paths) == 0 && !r.payloadFits(len(finalPayload)) {
+			r.rejectPayload(conn, sid, len(textData), len(finalPayload), nil, readOptions(m))
+			return nil
+		}
+		event := map[string]any{
+			"bytes": len(finalPayload), "digest": digest([]byte(finalPayload)), "textDigest": digest(prompt), "paths": paths,
+		}
+		if prefix != "" {
+			event["promptPrefixDigest"] = digest([]byte(prefix))
+		}
+		r.logSessionEvent(sid, "send", event)
+		r.gitCheckpointBeforeSend(conn, sid, string(prompt))
 
 		// If useClipboard is enabled for this session, perform clipboard-based paste (like injectFiles).
 		useClipboard := false
@@ -793,15 +1938,20 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		}
 		if useClipboard {
 			// 1) backup clipboard, 2) set payload exact as-is, 3) send Ctrl+V, 4) restore clipboard after terminal becomes idle (~1s)
-			prev, prevErr := getClipboard()
-			if err := setClipboard(finalPayload); err == nil {
+			prev, prevErr := r.readClipboard(ctx)
+			r.clips.ignore(finalPayload)
+			if err := r.writeClipboard(ctx, finalPayload); err == nil {
 				// send Ctrl+V (0x16)
 				r.waitStdoutIdle(sid, 2*stdoutThrottleInterval)
 				_, _ = sess.Stdin().Write([]byte{0x16})
+				if st != nil {
+					st.promptQueued(m)
+				}
 				// Restore previous clipboard content after terminal output becomes idle
 				r.waitStdoutIdle(sid, 1*time.Second)
 				if prevErr == nil {
-					_ = setClipboard(prev)
+					// Restore even if the client left in the meantime
+					_ = r.writeClipboard(context.WithoutCancel(ctx), prev)
 				}
 
 				// Mark that the next stdout should be sent immediately.
@@ -838,8 +1988,7 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		}
 
 		// Process file contents if present
-		if len(paths) > 0 {
-			contents := fileutil.ReadMultipleFiles(paths)
+		if len(contents) > 0 {
 			for _, content := range contents {
 				if content == "" {
 					continue
@@ -862,6 +2011,9 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 		w := bufio.NewWriterSize(sess.Stdin(), 64*1024)
 		_, _ = io.WriteString(w, finalProcessedPayload)
 		_ = w.Flush()
+		if st != nil {
+			st.promptQueued(m)
+		}
 
 		// Mark that the next stdout should be sent immediately.
 		if st != nil {
@@ -883,65 +2035,122 @@ func (r *Router) handle(conn *websocket.Conn, m map[string]any) error {
 	return nil
 }
 
-func (r *Router) pipeStdout(sid string, sess *session.Session) {
-	const maxReplay = 256 * 1024 // keep last 256KiB of output for snapshot
+func (r *Router) pipeStdout(ctx context.Context, sid string, st *sessionState, sess ptySession, done chan struct{}) {
+	defer close(done)
 	buf := make([]byte, 32*1024)
 	reader := sess.Stdout()
 	for {
 		n, err := reader.Read(buf)
-		if n > 0 {
-			// Update session state (replay buffer and seq)
-			r.mu.Lock()
-			st := r.sessionStates[sid]
-			r.mu.Unlock()
-			if st != nil {
-				st.mu.Lock()
-				st.replay = append(st.replay, buf[:n]...)
-				if len(st.replay) > maxReplay {
-					// trim from the front to keep within cap
-					st.replay = st.replay[len(st.replay)-maxReplay:]
-				}
-				// Accumulate into throttled buffer
-				st.outBuf = append(st.outBuf, buf[:n]...)
-				st.lastEnqueue = time.Now()
-				// Decide whether to flush now or schedule
-				c := st.currentConn
-				if c != nil {
-					now := time.Now()
-					if st.needImmediate || now.Sub(st.lastSend) >= stdoutThrottleInterval {
-						// flush immediately
-						st.mu.Unlock()
-						r.flushStdout(sid)
-					} else {
-						// schedule flush if not already scheduled
-						if st.throttleTimer == nil {
-							rem := stdoutThrottleInterval - now.Sub(st.lastSend)
-							if rem < 0 {
-								rem = 0
-							}
-							localSid := sid
-							st.throttleTimer = time.AfterFunc(rem, func() {
-								r.flushStdout(localSid)
-							})
-						}
-						st.mu.Unlock()
-					}
-				} else {
-					// No active connection; keep buffering only
-					st.mu.Unlock()
-				}
-				// No state available; continue buffering reads without sending
-			}
+		if n > 0 && !r.ingestOutput(ctx, sid, st, buf[:n], outputRead) {
+			return
 		}
 		if err != nil {
-			if !isExpectedReadError(err) {
+			if !isExpectedReadError(err) && ctx.Err() == nil {
 				log.Printf("stdout err: %v", err)
 			}
+			// pass on what redaction held back for the rest of a line
+			r.ingestOutput(ctx, sid, st, nil, outputEOF)
 			return
 		}
 	}
 }
 
+// ingestOutput takes a chunk of the process's output into the session: redaction,
+// recording, replay buffer and the throttled stdout stream. It reports false once the
+// process no longer owns st.
+func (r *Router) ingestOutput(ctx context.Context, sid string, st *sessionState, p []byte, src outputSource) bool {
+	const maxReplay = 256 * 1024 // keep last 256KiB of output for snapshot
+	// Update session state (replay buffer and seq)
+	st.mu.Lock()
+	if st.staleUnsafe(ctx) {
+		// the process was replaced or orphaned; its output no longer belongs here
+		st.mu.Unlock()
+		return false
+	}
+	p = r.redactUnsafe(ctx, sid, st, p, src)
+	if len(p) == 0 {
+		st.mu.Unlock()
+		return true
+	}
+	if st.recorder != nil {
+		st.recorder.Output(p)
+	}
+	lines := st.mirror.write(p)
+	suppress, ready := r.startupOutputUnsafe(ctx, sid, st, lines)
+	if !suppress {
+		// trim from the front to keep within cap, never mid-rune or mid-sequence
+		if st.collapseFrames {
+			st.replay = st.replayScan.appendCollapse(st.replay, p, maxReplay)
+		} else {
+			st.replay = st.replayScan.appendTrim(st.replay, p, maxReplay)
+		}
+	}
+	r.trackActivityUnsafe(st, time.Now())
+	stallMsg := r.livenessOutputUnsafe(ctx, sid, st, lines)
+	st.timeline.mark(time.Now(), st.sentBytes+int64(len(st.outBuf)))
+	var linesMsg map[string]any
+	if suppress {
+		// startup banner: it takes up its stream offsets but is neither kept nor sent
+		st.sentBytes += int64(len(p))
+		st.startup.suppressed += int64(len(p))
+	} else if st.plainText {
+		linesMsg = st.linesMessageUnsafe(sid, p, lines)
+	} else {
+		// Accumulate into throttled buffer
+		st.outBuf = append(st.outBuf, p...)
+		st.lastEnqueue = time.Now()
+	}
+	// Decide whether to flush now or schedule
+	c := st.currentConn
+	if c != nil && !suppress && !st.plainText {
+		now := time.Now()
+		if st.needImmediate || now.Sub(st.lastSend) >= stdoutThrottleInterval {
+			// flush immediately
+			st.mu.Unlock()
+			r.flushSession(ctx, sid, st)
+		} else {
+			// schedule flush if not already scheduled
+			if st.throttleTimer == nil {
+				rem := stdoutThrottleInterval - now.Sub(st.lastSend)
+				if rem < 0 {
+					rem = 0
+				}
+				st.throttleTimer = time.AfterFunc(rem, func() {
+					r.flushSession(ctx, sid, st)
+				})
+			}
+			st.mu.Unlock()
+		}
+	} else {
+		// No active connection or nothing to send; keep buffering only
+		st.mu.Unlock()
+	}
+	if linesMsg != nil {
+		r.sendLines(ctx, sid, st, linesMsg)
+	}
+	if stallMsg != nil && c != nil {
+		_ = SendJSON(c, stallMsg)
+	}
+	if len(lines) > 0 {
+		r.emitDiagnostics(sid, st, lines)
+		r.emitPathAnnotations(sid, st, lines)
+		r.emitPorts(sid, st, lines)
+		r.recordUsage(st, lines)
+		st.recordCodeBlocks(lines)
+	}
+	r.watchPermissions(sid, st, lines)
+	if ready != nil {
+		r.announceReady(ctx, sid, st, ready)
+	}
+	return true
+}
+
+// staleUnsafe reports whether ctx belongs to a process that no longer owns st, because it
+// exited, was replaced or was orphaned. Caller must hold st.mu.
+func (st *sessionState) staleUnsafe(ctx context.Context) bool {
+	return ctx != st.ctx || ctx != nil && ctx.Err() != nil
+}
+
 // flushStdout flushes the buffered stdout for a session if any, respecting the throttle interval.
 func (r *Router) flushStdout(sid string) {
 	r.mu.Lock()
@@ -951,6 +2160,22 @@ func (r *Router) flushStdout(sid string) {
 		return
 	}
 	st.mu.Lock()
+	ctx := st.ctx
+	st.mu.Unlock()
+	r.flushSession(ctx, sid, st)
+}
+
+// flushSession sends st's buffered stdout on behalf of the process owning ctx. Flushes
+// scheduled by a process that has since gone away are dropped, so they neither send to a
+// closed connection nor clear the throttle timer of the process that replaced it.
+func (r *Router) flushSession(ctx context.Context, sid string, st *sessionState) {
+	st.sendMu.Lock()
+	defer st.sendMu.Unlock()
+	st.mu.Lock()
+	if st.staleUnsafe(ctx) {
+		st.mu.Unlock()
+		return
+	}
 	c := st.currentConn
 	if c == nil || len(st.outBuf) == 0 {
 		// Nothing to send
@@ -964,46 +2189,60 @@ func (r *Router) flushStdout(sid string) {
 	// Advance sequence only when we actually send
 	st.lastSeq++
 	seq := st.lastSeq
+	offset := st.sentBytes
+	st.sentBytes += int64(len(data))
 	st.needImmediate = false
 	if st.throttleTimer != nil {
 		st.throttleTimer.Stop()
 		st.throttleTimer = nil
 	}
+	msg := map[string]any{
+		"type": "stdout", "sessionId": sid, "dataBase64": base64.StdEncoding.EncodeToString(data), "seq": seq, "offset": offset,
+	}
+	st.broadcastFieldsUnsafe(msg)
+	metrics, latency := st.firstOutputUnsafe(sid, time.Now())
 	st.mu.Unlock()
-	if err := SendJSON(c, map[string]any{
-		"type": "stdout", "sessionId": sid, "dataBase64": base64.StdEncoding.EncodeToString(data), "seq": seq,
-	}); err != nil {
+	if err := SendJSON(c, msg); err != nil {
 		log.Printf("ws write error: %v", err)
 	}
-	// Record lastSend after the write completes to better reflect delivery timing
-	r.mu.Lock()
-	st = r.sessionStates[sid]
-	r.mu.Unlock()
-	if st != nil {
-		st.mu.Lock()
-		st.lastSend = time.Now()
-		st.mu.Unlock()
+	if metrics != nil {
+		r.recordPromptLatency(latency)
+		_ = SendJSON(c, metrics)
 	}
+	// Record lastSend after the write completes to better reflect delivery timing
+	st.mu.Lock()
+	st.lastSend = time.Now()
+	st.mu.Unlock()
 }
 
 // waitStdoutIdle waits until stdout for the given session has been fully flushed
 // and no data has been sent for at least the specified idle period.
-// It returns early after a safety timeout to avoid indefinite blocking if the
-// session is closed or no stdout activity occurs.
+// It returns as soon as the session's process exits or is replaced, and after a
+// safety timeout to avoid indefinite blocking if no stdout activity occurs.
 func (r *Router) waitStdoutIdle(sid string, idle time.Duration) {
 	// Safety cap: don't block forever
 	maxWait := 60 * time.Second
 	deadline := time.Now().Add(maxWait)
 	start := time.Now()
 	//log.Printf("waitStdoutIdle: start sid=%s idle=%s", sid, idle)
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	ctx := st.ctx
+	st.mu.Unlock()
+	if ctx == nil {
+		return
+	}
 	for {
-		r.mu.Lock()
-		st := r.sessionStates[sid]
-		r.mu.Unlock()
-		if st == nil {
+		st.mu.Lock()
+		if st.staleUnsafe(ctx) {
+			st.mu.Unlock()
 			return
 		}
-		st.mu.Lock()
 		outEmpty := len(st.outBuf) == 0
 		lastSend := st.lastSend
 		lastEnqueue := st.lastEnqueue
@@ -1047,7 +2286,11 @@ func (r *Router) waitStdoutIdle(sid string, idle time.Duration) {
 				sleep = rem
 			}
 		}
-		time.Sleep(sleep)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sleep):
+		}
 	}
 }
 
@@ -1055,7 +2298,14 @@ func (r *Router) cleanupConn(conn *websocket.Conn) {
 	r.mu.Lock()
 	ids := r.connSessions[conn]
 	delete(r.connSessions, conn)
+	delete(r.connAliases, conn)
+	delete(r.snapshotEncodings, conn)
+	delete(r.editorConns, conn)
 	r.mu.Unlock()
+	r.dropConfirmations(conn)
+	r.dropTransfers(conn)
+	r.dropTails(conn)
+	r.dropIndexSubscription(conn)
 	for sid := range ids {
 		// Detach: clear currentConn and start orphan timer for graceful cleanup
 		r.mu.Lock()
@@ -1066,31 +2316,55 @@ func (r *Router) cleanupConn(conn *websocket.Conn) {
 			if st.currentConn == conn {
 				st.currentConn = nil
 			}
+			r.logSessionEvent(sid, "detached", map[string]any{"graceSeconds": r.orphanGrace.Seconds()})
 			if st.orphanTimer != nil {
 				st.orphanTimer.Stop()
 				st.orphanTimer = nil
 			}
 			localSid := sid
-			st.orphanTimer = time.AfterFunc(30*time.Second, func() {
-				// If not resumed within grace period, terminate and cleanup
+			st2 := st
+			var orphan *time.Timer
+			orphan = time.AfterFunc(r.orphanGrace, func() {
+				// If not resumed within grace period, terminate and cleanup. A resume or
+				// reopen that raced with the timer firing has replaced orphanTimer.
+				st2.mu.Lock()
+				if st2.orphanTimer != orphan {
+					st2.mu.Unlock()
+					return
+				}
+				if st2.cancel != nil {
+					st2.cancel()
+				}
+				if st2.throttleTimer != nil {
+					st2.throttleTimer.Stop()
+					st2.throttleTimer = nil
+				}
+				st2.replay = nil
+				st2.replayScan = replayScanner{}
+				st2.lastSeq = 0
+				st2.outBuf = nil
+				st2.currentConn = nil
+				st2.orphanTimer = nil
+				st2.stopRecordingUnsafe()
+				if st2.task != nil && st2.task.sess != nil {
+					st2.task.stop()
+				}
+				st2.mu.Unlock()
 				r.mu.Lock()
-				sess := r.sessions[localSid]
-				delete(r.sessions, localSid)
-				st2 := r.sessionStates[localSid]
-				delete(r.sessionStates, localSid)
+				var sess ptySession
+				if r.sessionStates[localSid] == st2 {
+					sess = r.sessions[localSid]
+					delete(r.sessions, localSid)
+					delete(r.sessionStates, localSid)
+					r.forgetSessionPortsUnsafe(localSid)
+				}
 				r.mu.Unlock()
 				if sess != nil {
+					r.logSessionEvent(localSid, "orphanClosed", map[string]any{"graceSeconds": r.orphanGrace.Seconds()})
 					_ = sess.Close()
 				}
-				if st2 != nil {
-					st2.mu.Lock()
-					st2.replay = nil
-					st2.lastSeq = 0
-					st2.currentConn = nil
-					st2.orphanTimer = nil
-					st2.mu.Unlock()
-				}
 			})
+			st.orphanTimer = orphan
 			st.mu.Unlock()
 		} else {
 			// No state; best-effort close session after grace
@@ -1099,12 +2373,126 @@ func (r *Router) cleanupConn(conn *websocket.Conn) {
 			r.mu.Unlock()
 			if sess != nil {
 				localSid := sid
-				time.AfterFunc(30*time.Second, func() { _ = sess.Close(); r.mu.Lock(); delete(r.sessions, localSid); r.mu.Unlock() })
+				time.AfterFunc(r.orphanGrace, func() { _ = sess.Close(); r.mu.Lock(); delete(r.sessions, localSid); r.mu.Unlock() })
 			}
 		}
 	}
 }
 
+// sessionWorkingDir returns the working directory of the session, falling back to the
+// process working directory when the session is unknown or has none.
+func (r *Router) sessionWorkingDir(sid string) string {
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	var dir string
+	if st != nil {
+		st.mu.Lock()
+		dir = st.workingDir
+		st.mu.Unlock()
+	}
+	if dir == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			dir = cwd
+		}
+	}
+	return dir
+}
+
+// loadPromptHistory returns the personal prompt history merged with the workspace prompt
+// library of projectCwd. Load failures and timeouts are logged and yield what could be read.
+func (r *Router) loadPromptHistory(ctx context.Context, projectCwd string) []history.PromptHistoryEntry {
+	d := r.getTimeouts().History
+	promptHistory, err := withTimeout(ctx, d, "loading prompt history", func(context.Context) ([]history.PromptHistoryEntry, error) {
+		return r.historyManager.LoadHistory()
+	})
+	if err != nil {
+		log.Printf("Failed to load prompt history: %v", err)
+		promptHistory = []history.PromptHistoryEntry{} // Continue with empty history
+	}
+	project, err := withTimeout(ctx, d, "loading the project prompt library", func(context.Context) ([]history.PromptHistoryEntry, error) {
+		return history.LoadProjectPrompts(projectCwd)
+	})
+	if err != nil {
+		log.Printf("Failed to load project prompt library: %v", err)
+	}
+	return history.MergeProjectPrompts(promptHistory, project)
+}
+
+// readFilesForInjection reads files for injection and replies with an injectResult
+// report (bytes, language, token estimate, truncation or error per path), so the UI
+// can show accurate chip status. Unreadable files are reported instead of injected.
+// When the contents and textBytes of prompt text exceed the payload limit, it replies
+// with a payloadTooLarge error instead and returns false.
+func (r *Router) readFilesForInjection(ctx context.Context, conn *websocket.Conn, sid string, paths []string, opts fileutil.ReadOptions, textBytes int) ([]string, bool) {
+	opts.Snippets = r.snippetContents()
+	if r.mock != nil {
+		opts.ReadFile = r.mock.readFile
+	}
+	if opts.Preamble == "" && !opts.NoPreamble {
+		r.mu.Lock()
+		opts.Preamble, opts.NoPreamble = r.preamble, r.noPreamble
+		r.mu.Unlock()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = r.getTimeouts().FileRead
+	}
+	results := fileutil.ReadFilesContext(ctx, paths, opts)
+	contents := fileutil.FormatFileResults(results, opts)
+	if total := payloadSize(textBytes, contents); !r.payloadFits(total) {
+		r.rejectPayload(conn, sid, textBytes, total, results, opts)
+		return nil, false
+	}
+	report := make([]map[string]any, 0, len(results))
+	for _, res := range results {
+		item := map[string]any{"path": res.Path}
+		if res.Err != nil {
+			log.Printf("Failed to read %q for injection: %v", res.Path, res.Err)
+			item["error"] = res.Err.Error()
+		} else {
+			item["bytes"] = len(res.Content)
+			item["tokens"] = fileutil.EstimateTokens(res.Content)
+			item["language"] = res.Language
+			item["truncated"] = res.Truncated
+		}
+		report = append(report, item)
+	}
+	_ = SendJSON(conn, map[string]any{"type": "injectResult", "sessionId": sid, "files": report})
+	return contents, true
+}
+
+// readOptions parses the optional per-request injection options:
+// { options: { elideDuplicates?, normalizeLineEndings?, stripBOM?, trimTrailingWhitespace?: bool,
+// tabWidth?: number, controlChars?: "escape"|"strip"|"keep", preamble?: string, noPreamble?, rawNotebooks?, fullTabular?: bool, timeoutMs?, concurrency?: number } }
+func readOptions(m map[string]any) fileutil.ReadOptions {
+	var opts fileutil.ReadOptions
+	o, _ := m["options"].(map[string]any)
+	if o == nil {
+		return opts
+	}
+	opts.ElideDuplicates, _ = o["elideDuplicates"].(bool)
+	opts.NormalizeLineEndings, _ = o["normalizeLineEndings"].(bool)
+	opts.StripBOM, _ = o["stripBOM"].(bool)
+	opts.TrimTrailingWhitespace, _ = o["trimTrailingWhitespace"].(bool)
+	opts.TabWidth = asInt(o["tabWidth"])
+	controlChars, _ := o["controlChars"].(string)
+	opts.ControlChars = fileutil.ParseControlCharMode(controlChars)
+	opts.Preamble, _ = o["preamble"].(string)
+	opts.NoPreamble, _ = o["noPreamble"].(bool)
+	opts.RawNotebooks, _ = o["rawNotebooks"].(bool)
+	opts.FullTabular, _ = o["fullTabular"].(bool)
+	opts.Timeout = time.Duration(asInt(o["timeoutMs"])) * time.Millisecond
+	opts.Concurrency = asInt(o["concurrency"])
+	return opts
+}
+
+// promptMetadata extracts the optional agent/model identifiers sent alongside a historyEntry.
+func promptMetadata(historyData map[string]any, sid string) history.PromptMetadata {
+	agent, _ := historyData["agent"].(string)
+	model, _ := historyData["model"].(string)
+	return history.PromptMetadata{SessionID: sid, Agent: agent, Model: model}
+}
+
 func anyToStrings(a any) ([]string, bool) {
 	if a == nil {
 		return nil, true
@@ -1202,6 +2590,46 @@ func isExpectedReadError(err error) bool {
 	return false
 }
 
+// WriteIndexExport writes the current file index to w for the /index HTTP endpoint.
+// format is "ndjson" for one entry per line; anything else produces a single JSON object.
+func (r *Router) WriteIndexExport(w http.ResponseWriter, format string) {
+	var snap index.Snapshot
+	root := ""
+	if r.indexer != nil {
+		r.indexer.RequestRefresh()
+		snap = r.indexer.Snapshot()
+		root = r.indexer.Root
+	}
+	var err error
+	if format == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		err = snap.WriteNDJSON(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = snap.WriteJSON(w, root)
+	}
+	if err != nil {
+		log.Printf("index export error: %v", err)
+	}
+}
+
+// SetPolicy restricts the executables sessions may launch; call it before serving
+func (r *Router) SetPolicy(p *policy.Policy) {
+	r.policy = p
+}
+
+// SetHistoryManager replaces the prompt history store; call it before serving
+func (r *Router) SetHistoryManager(h *history.HistoryManager) {
+	r.historyManager = h
+}
+
+// FlushDrafts writes pending prompt drafts to disk; called on shutdown
+func (r *Router) FlushDrafts() {
+	if err := r.drafts.Flush(); err != nil {
+		log.Printf("Failed to flush prompt drafts: %v", err)
+	}
+}
+
 // GetAndResetFontSize returns the current font size and resets it to 0
 // Returns 0 if no font size change has been received
 func (r *Router) GetAndResetFontSize() int {
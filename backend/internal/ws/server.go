@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -11,26 +12,51 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-var wsWriteMu sync.Map // map[*websocket.Conn]*sync.Mutex
+var wsWriteMu sync.Map // map[*websocket.Conn]*sync.Mutex, for connections not served by a Server
 
 type Server struct {
-	Token     string
-	Upgrader  websocket.Upgrader
-	OnMessage func(conn *websocket.Conn, msg map[string]any)
+	Token    string
+	Upgrader websocket.Upgrader
+	// OnMessage handles each message read from a connection. ctx is cancelled when the
+	// connection closes, so work done for its messages can be abandoned.
+	OnMessage func(ctx context.Context, conn *websocket.Conn, msg map[string]any)
 	// OnClose is called when the websocket connection is about to close.
 	// It can be used by higher layers to perform cleanup tied to this connection.
 	OnClose func(conn *websocket.Conn)
-	seq     uint64
+	// WriteTimeout bounds each frame write and QueueSize the outbound messages buffered
+	// per connection; clients exceeding either are evicted (see writer.go)
+	WriteTimeout time.Duration
+	QueueSize    int
+	// Quota is the soft per-connection traffic limit; the zero value disables it
+	Quota Quota
+	seq   uint64
+
+	mu           sync.Mutex
+	writers      map[*websocket.Conn]*connWriter
+	evictions    uint64
+	lastEviction *Eviction
+	// traffic of closed connections and quota warnings, for Stats
+	closedSent       int64
+	closedReceived   int64
+	quotaWarnings    uint64
+	lastQuotaWarning *QuotaWarning
 }
 
+// DefaultHandshakeTimeout bounds the WebSocket upgrade of a connection
+const DefaultHandshakeTimeout = 10 * time.Second
+
 func NewServer(token string) *Server {
 	return &Server{
-		Token: token,
+		Token:        token,
+		WriteTimeout: DefaultWriteTimeout,
+		QueueSize:    DefaultQueueSize,
 		Upgrader: websocket.Upgrader{
+			HandshakeTimeout: DefaultHandshakeTimeout,
 			// Enforce same-origin from loopback and allow null (JCEF). Cross-site WS blocked.
 			CheckOrigin: func(r *http.Request) bool {
 				// Accept explicit null origin (e.g., JCEF)
@@ -41,11 +67,7 @@ func NewServer(token string) *Server {
 				if origin == "" {
 					// No origin header: allow only if remote addr is loopback
 					host, _, err := net.SplitHostPort(r.RemoteAddr)
-					if err != nil {
-						return false
-					}
-					ip := net.ParseIP(host)
-					return ip != nil && ip.IsLoopback()
+					return err == nil && isLoopbackHost(host)
 				}
 				u, err := url.Parse(origin)
 				if err != nil {
@@ -54,20 +76,24 @@ func NewServer(token string) *Server {
 				if u.Scheme != "http" && u.Scheme != "https" {
 					return false
 				}
-				h := u.Hostname()
-				if h == "localhost" || h == "127.0.0.1" || h == "::1" {
-					return true
-				}
-				// Additional allowance: RFC6874 IPv6 loopback variants like "[::1]"
-				if ip := net.ParseIP(h); ip != nil && ip.IsLoopback() {
-					return true
-				}
-				return false
+				return isLoopbackHost(u.Hostname())
 			},
 		},
 	}
 }
 
+// isLoopbackHost reports whether host is localhost or a loopback address: 127.0.0.0/8,
+// ::1 with or without a zone (RFC 6874), or an IPv4 loopback address mapped into IPv6 as
+// seen on dual-stack sockets
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	host, _, _ = strings.Cut(host, "%")
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 	// 1) Try to authenticate via WebSocket subprotocol: auth.bearer.<token>
 	var respHdr http.Header
@@ -99,17 +125,17 @@ func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 		log.Printf("ws upgrade error: %v", err)
 		return
 	}
+	cw := s.register(c, r.Header.Get("Origin"))
+	ctx, cancel := context.WithCancel(r.Context())
 	defer func() {
+		// abandon work still waiting on behalf of this connection's messages
+		cancel()
 		// notify upper layers first, then close the socket
 		if s.OnClose != nil {
 			s.OnClose(c)
 		}
-		// remove write lock for this connection
-		if v, ok := wsWriteMu.Load(c); ok {
-			wsWriteMu.Delete(c)
-			_ = v // allow GC of the mutex
-		}
-		_ = c.Close()
+		// stop the outbound writer for this connection and close the socket
+		s.unregister(c)
 	}()
 
 	for {
@@ -117,13 +143,14 @@ func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			return
 		}
+		s.account(cw, false, len(data))
 		var m map[string]any
 		if err := json.Unmarshal(data, &m); err != nil {
 			log.Printf("bad json: %v", err)
 			continue
 		}
 		if s.OnMessage != nil {
-			s.OnMessage(c, m)
+			s.OnMessage(ctx, c, m)
 		}
 	}
 }
@@ -133,6 +160,9 @@ func SendJSON(c *websocket.Conn, v any) error {
 	if err != nil {
 		return err
 	}
+	if w, ok := wsWriters.Load(c); ok {
+		return w.(*connWriter).enqueue(buf, channelOf(v))
+	}
 	// serialize writes per connection
 	var mu *sync.Mutex
 	if v, ok := wsWriteMu.Load(c); ok {
@@ -144,6 +174,7 @@ func SendJSON(c *websocket.Conn, v any) error {
 	}
 	mu.Lock()
 	defer mu.Unlock()
+	_ = c.SetWriteDeadline(time.Now().Add(DefaultWriteTimeout))
 	return c.WriteMessage(websocket.TextMessage, buf)
 }
 
@@ -174,3 +205,16 @@ func Errorf(c *websocket.Conn, format string, args ...any) {
 		"message": fmt.Sprintf(format, args...),
 	})
 }
+
+// ErrorCode reports a server-side error with a machine-readable code and extra fields
+func ErrorCode(c *websocket.Conn, code string, fields map[string]any, format string, args ...any) {
+	msg := map[string]any{
+		"type":    "error",
+		"code":    code,
+		"message": fmt.Sprintf(format, args...),
+	}
+	for k, v := range fields {
+		msg[k] = v
+	}
+	_ = SendJSON(c, msg)
+}
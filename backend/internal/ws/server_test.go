@@ -1,10 +1,13 @@
 package ws
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -119,3 +122,193 @@ func TestWS_Origin_EvilDisallowed(t *testing.T) {
 		t.Fatalf("expected 403 Forbidden for bad origin, got %d", resp.StatusCode)
 	}
 }
+
+// dialStalled connects a client that never reads and returns the server side of it
+func dialStalled(t *testing.T, s *Server) (*websocket.Conn, func()) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	s.OnMessage = func(_ context.Context, conn *websocket.Conn, _ map[string]any) { conns <- conn }
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.HandleWS)
+	ts := httptest.NewServer(mux)
+	d := websocket.Dialer{Subprotocols: []string{"auth.bearer." + s.Token}}
+	h := http.Header{}
+	h.Set("Origin", "http://localhost")
+	c, _, err := d.Dial(wsURLFromHTTP(ts.URL, "/ws"), h)
+	if err != nil {
+		ts.Close()
+		t.Fatalf("dial: %v", err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "hello"})
+	return <-conns, func() { c.Close(); ts.Close() }
+}
+
+func TestWS_EvictsClientThatStopsReading(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		server func(*Server)
+		reason string
+	}{
+		{"queue full", func(s *Server) { s.QueueSize = 4; s.WriteTimeout = time.Minute }, "queue full"},
+		{"write timeout", func(s *Server) { s.QueueSize = 1 << 16; s.WriteTimeout = 50 * time.Millisecond }, "timed out"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer("tok")
+			tc.server(s)
+			conn, closeClient := dialStalled(t, s)
+			defer closeClient()
+
+			// Large frames fill the socket buffers, after which writes stall
+			payload := strings.Repeat("x", 64*1024)
+			deadline := time.Now().Add(5 * time.Second)
+			for s.Stats().Evictions == 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("client was not evicted: %+v", s.Stats())
+				}
+				if err := SendJSON(conn, map[string]any{"type": "stdout", "data": payload}); err != nil {
+					break
+				}
+			}
+			for s.Stats().Evictions == 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			stats := s.Stats()
+			if stats.Evictions != 1 || stats.LastEviction == nil || !strings.Contains(stats.LastEviction.Reason, tc.reason) {
+				t.Fatalf("unexpected stats after eviction: %+v", stats)
+			}
+			if err := SendJSON(conn, map[string]any{"type": "ping"}); err == nil {
+				t.Error("expected sends to an evicted client to fail")
+			}
+			for s.Stats().Connections != 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if n := s.Stats().Connections; n != 0 {
+				t.Errorf("expected evicted connection to be cleaned up, %d left", n)
+			}
+		})
+	}
+}
+
+func TestWS_CountsTrafficAndWarnsOverQuota(t *testing.T) {
+	s := NewServer("tok")
+	s.Quota = Quota{SentBytes: 1000}
+	conns := make(chan *websocket.Conn, 1)
+	s.OnMessage = func(_ context.Context, conn *websocket.Conn, _ map[string]any) { conns <- conn }
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.HandleWS)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	d := websocket.Dialer{Subprotocols: []string{"auth.bearer.tok"}}
+	h := http.Header{}
+	h.Set("Origin", "http://localhost")
+	c, _, err := d.Dial(wsURLFromHTTP(ts.URL, "/ws"), h)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	hello := `{"type":"hello"}`
+	_ = c.WriteMessage(websocket.TextMessage, []byte(hello))
+	conn := <-conns
+
+	payload := strings.Repeat("x", 800)
+	for i := 0; i < 2; i++ {
+		_ = SendJSON(conn, map[string]any{"type": "stdout", "data": payload})
+	}
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var warn map[string]any
+	for warn == nil {
+		var m map[string]any
+		if err := c.ReadJSON(&m); err != nil {
+			t.Fatalf("no quotaWarning received: %v", err)
+		}
+		if m["type"] == "quotaWarning" {
+			warn = m
+		}
+	}
+	if warn["direction"] != "sent" || warn["limit"] != float64(1000) || warn["bytes"].(float64) <= 1000 {
+		t.Fatalf("unexpected warning %v", warn)
+	}
+
+	stats := s.Stats()
+	if stats.QuotaWarnings != 1 || stats.LastQuotaWarning == nil || len(stats.Clients) != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	cs := stats.Clients[0]
+	if cs.BytesReceived != int64(len(hello)) || cs.MessagesReceived != 1 || cs.BytesSent < 1600 || cs.FramesSent < 2 || cs.QuotaWarnings != 1 {
+		t.Fatalf("unexpected client stats %+v", cs)
+	}
+	if stats.BytesSent != cs.BytesSent || stats.BytesReceived != cs.BytesReceived {
+		t.Fatalf("totals %d/%d do not match the only client %+v", stats.BytesSent, stats.BytesReceived, cs)
+	}
+}
+
+func TestConnUsage_WarnsOncePerWindow(t *testing.T) {
+	var u connUsage
+	q := Quota{ReceivedBytes: 100, Window: time.Minute}
+	now := time.Now()
+	if w := u.add(false, 100, q, now); w != nil {
+		t.Fatalf("warned at the limit: %+v", w)
+	}
+	if w := u.add(false, 1, q, now); w == nil || w.Direction != "received" || w.Bytes != 101 {
+		t.Fatalf("expected a warning over the limit, got %+v", w)
+	}
+	if w := u.add(false, 50, q, now.Add(time.Second)); w != nil {
+		t.Fatalf("warned twice in one window: %+v", w)
+	}
+	if w := u.add(true, 1<<20, q, now); w != nil {
+		t.Fatalf("warned without a send quota: %+v", w)
+	}
+	if w := u.add(false, 200, q, now.Add(2*time.Minute)); w == nil {
+		t.Fatal("expected a warning in the next window")
+	}
+	if u.bytesRecv.Load() != 351 || u.messagesRecv.Load() != 4 || u.warnings.Load() != 2 {
+		t.Fatalf("unexpected totals: %d bytes, %d messages, %d warnings", u.bytesRecv.Load(), u.messagesRecv.Load(), u.warnings.Load())
+	}
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	cases := map[string]bool{
+		"localhost":        true,
+		"127.0.0.1":        true,
+		"127.1.2.3":        true,
+		"::1":              true,
+		"::1%lo0":          true,
+		"::ffff:127.0.0.1": true,
+		"example.com":      false,
+		"192.168.1.10":     false,
+		"::":               false,
+		"fe80::1%eth0":     false,
+	}
+	for host, want := range cases {
+		if got := isLoopbackHost(host); got != want {
+			t.Errorf("isLoopbackHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestWS_AcceptsIPv6Loopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("no IPv6 loopback:", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", NewServer("tok").HandleWS)
+	ts := httptest.NewUnstartedServer(mux)
+	ts.Listener.Close()
+	ts.Listener = ln
+	ts.Start()
+	defer ts.Close()
+
+	d := websocket.Dialer{Subprotocols: []string{"auth.bearer.tok"}}
+	for _, origin := range []string{ts.URL, ""} {
+		h := http.Header{}
+		if origin != "" {
+			h.Set("Origin", origin)
+		}
+		c, resp, err := d.Dial(wsURLFromHTTP(ts.URL, "/ws"), h)
+		if err != nil {
+			t.Fatalf("origin %q: dial failed: %v %v", origin, err, resp)
+		}
+		c.Close()
+	}
+}
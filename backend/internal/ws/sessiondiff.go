@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/example/rovobridge/internal/index"
+)
+
+const (
+	// maxTreeSnapshotFiles bounds the files remembered when a session starts
+	maxTreeSnapshotFiles = 20000
+	// maxTreeSnapshotFileBytes is the largest file hashed; larger ones are compared by
+	// size and modification time only
+	maxTreeSnapshotFileBytes = 4 << 20
+	// maxTreeSnapshotHashBytes bounds the bytes hashed per snapshot; once spent, the
+	// remaining files are compared by size and modification time only
+	maxTreeSnapshotHashBytes = 256 << 20
+)
+
+// fileStamp is what a tree snapshot remembers about one file
+type fileStamp struct {
+	size   int64
+	mtime  time.Time
+	digest string // empty when the file was not hashed
+}
+
+// treeSnapshot records the workspace files when a session starts, so sessionDiff can
+// report what the run changed. It is taken in the background; ready closes once files
+// and truncated are set.
+type treeSnapshot struct {
+	root      string
+	start     time.Time
+	ready     chan struct{}
+	files     map[string]fileStamp // relative to root
+	truncated bool                 // files were left out because of maxTreeSnapshotFiles
+}
+
+// takeTreeSnapshot starts snapshotting the gitignore-aware file tree under root
+func takeTreeSnapshot(root string) *treeSnapshot {
+	ts := &treeSnapshot{root: root, start: time.Now(), ready: make(chan struct{})}
+	go func() {
+		defer close(ts.ready)
+		paths, truncated := index.ListFiles(root, maxTreeSnapshotFiles)
+		files := make(map[string]fileStamp, len(paths))
+		budget := int64(maxTreeSnapshotHashBytes)
+		for _, p := range paths {
+			fi, err := os.Stat(filepath.Join(root, p))
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			stamp := fileStamp{size: fi.Size(), mtime: fi.ModTime()}
+			if stamp.size <= maxTreeSnapshotFileBytes && stamp.size <= budget {
+				if d, err := hashFile(filepath.Join(root, p)); err == nil {
+					stamp.digest = d
+					budget -= stamp.size
+				}
+			}
+			files[p] = stamp
+		}
+		ts.files, ts.truncated = files, truncated
+	}()
+	return ts
+}
+
+// diff reports the files created, modified and deleted since the snapshot, sorted by
+// path. Files written while the snapshot was being taken count as modified, since their
+// state at session start is unknown. truncated reports that either side hit the file
+// limit, in which case files beyond it are only reported when written after the start.
+func (ts *treeSnapshot) diff() (changes []checkpointChange, truncated bool) {
+	<-ts.ready
+	changes = []checkpointChange{}
+	for p, old := range ts.files {
+		fi, err := os.Stat(filepath.Join(ts.root, p))
+		switch {
+		case err != nil:
+			changes = append(changes, checkpointChange{Path: p, Status: "deleted"})
+		case ts.modified(p, old, fi):
+			changes = append(changes, checkpointChange{Path: p, Status: "modified"})
+		}
+	}
+	paths, more := index.ListFiles(ts.root, maxTreeSnapshotFiles)
+	for _, p := range paths {
+		if _, ok := ts.files[p]; ok {
+			continue
+		}
+		if ts.truncated {
+			// The snapshot may simply not have reached this file
+			if fi, err := os.Stat(filepath.Join(ts.root, p)); err != nil || fi.ModTime().Before(ts.start) {
+				continue
+			}
+		}
+		changes = append(changes, checkpointChange{Path: p, Status: "created"})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, ts.truncated || more
+}
+
+func (ts *treeSnapshot) modified(p string, old fileStamp, fi os.FileInfo) bool {
+	switch {
+	case !old.mtime.Before(ts.start):
+		return true
+	case fi.Size() == old.size && fi.ModTime().Equal(old.mtime):
+		return false
+	case old.digest == "" || fi.Size() != old.size:
+		return true
+	}
+	d, err := hashFile(filepath.Join(ts.root, p))
+	return err != nil || d != old.digest
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,90 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeAged(t *testing.T, dir, name, content string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(p, old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRouter_SessionDiffReportsChangesSinceStart(t *testing.T) {
+	dir := t.TempDir()
+	writeAged(t, dir, ".gitignore", "*.log\n")
+	writeAged(t, dir, "keep.go", "package keep\n")
+	writeAged(t, dir, "edit.go", "package edit\n")
+	writeAged(t, dir, "gone.go", "package gone\n")
+
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir})
+	readType(t, c, "opened")
+	sid := sessionIDOf(t, r, "s1")
+	r.mu.Lock()
+	<-r.sessionStates[sid].tree.ready
+	r.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(dir, "edit.go"), []byte("package edit // changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "gone.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.log"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "sessionDiff", "sessionId": "s1"})
+	msg := readType(t, c, "sessionDiff")
+	got := map[string]string{}
+	for _, ch := range msg["changed"].([]any) {
+		m := ch.(map[string]any)
+		got[m["path"].(string)] = m["status"].(string)
+	}
+	want := map[string]string{"edit.go": "modified", "gone.go": "deleted", "new.go": "created"}
+	if len(got) != len(want) || msg["truncated"] != false {
+		t.Fatalf("expected %v, got %v", want, msg)
+	}
+	for p, s := range want {
+		if got[p] != s {
+			t.Fatalf("expected %s to be %s, got %v", p, s, got)
+		}
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "sessionDiff", "sessionId": "nope"})
+	readType(t, c, "error")
+}
+
+func TestTreeSnapshot_FilesWrittenDuringSnapshotCountAsModified(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fresh.go"), []byte("package fresh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts := &treeSnapshot{root: dir, start: time.Now().Add(-time.Minute), ready: make(chan struct{})}
+	fi, err := os.Stat(filepath.Join(dir, "fresh.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.files = map[string]fileStamp{"fresh.go": {size: fi.Size(), mtime: fi.ModTime()}}
+	close(ts.ready)
+
+	changes, _ := ts.diff()
+	if len(changes) != 1 || changes[0].Status != "modified" {
+		t.Fatalf("expected fresh.go to be modified, got %+v", changes)
+	}
+}
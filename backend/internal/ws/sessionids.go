@@ -0,0 +1,156 @@
+package ws
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// Sessions are keyed by an id the bridge generates, unique for the life of the process.
+// The id a client passes to openSession ("s1") is only an alias, kept per connection: two
+// clients opening "s1" get two sessions instead of replacing each other's. Messages may
+// name a session by its id or by an alias the connection opened; resume finds a session by
+// alias on any connection, so a client reconnecting with the same alias gets its session
+// back.
+
+// newSessionID returns a fresh session id
+func newSessionID() string {
+	return "ses_" + randomHex(8)
+}
+
+// resolveSessionRefs replaces the aliases in a message read from conn by session ids, and
+// picks the session an openSession opens, so that the message is ordered with the other
+// messages of that session. It runs in the read loop of conn, in message order.
+func (r *Router) resolveSessionRefs(conn *websocket.Conn, m map[string]any) {
+	if m["type"] == "openSession" {
+		alias, resume := openSessionAlias(m)
+		m["sessionId"] = r.openSessionTarget(conn, alias, resume)
+		return
+	}
+	if ref, ok := m["sessionId"].(string); ok && ref != "" {
+		m["sessionId"] = r.resolveSessionID(conn, ref)
+	}
+	if refs, ok := m["sessionIds"].([]any); ok {
+		ids := make([]any, len(refs))
+		for i, v := range refs {
+			ids[i] = v
+			if ref, ok := v.(string); ok {
+				ids[i] = r.resolveSessionID(conn, ref)
+			}
+		}
+		m["sessionIds"] = ids
+	}
+	if contexts, ok := m["contexts"].(map[string]any); ok {
+		byID := make(map[string]any, len(contexts))
+		for ref, v := range contexts {
+			byID[r.resolveSessionID(conn, ref)] = v
+		}
+		m["contexts"] = byID
+	}
+}
+
+// openSessionAlias returns the alias and resume flag of an openSession message
+func openSessionAlias(m map[string]any) (alias string, resume bool) {
+	alias = "s1"
+	if v, ok := m["id"].(string); ok {
+		alias = v
+	}
+	resume, _ = m["resume"].(bool)
+	return alias, resume
+}
+
+// resolveSessionID returns the session named ref on conn: a session id as is, else the
+// session the connection opened under the alias ref, else the only session with that
+// alias. An unknown ref is returned unchanged.
+func (r *Router) resolveSessionID(conn *websocket.Conn, ref string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.knownSessionUnsafe(ref) {
+		return ref
+	}
+	if sid, ok := r.connAliases[conn][ref]; ok {
+		return sid
+	}
+	if ids := r.sessionsByAliasUnsafe(ref); len(ids) == 1 {
+		return ids[0]
+	}
+	return ref
+}
+
+// openSessionTarget returns the session an openSession with alias opens on conn: one the
+// connection opened under the alias before, or one named by its id; otherwise, to resume,
+// a session with the alias on any connection, preferring one nobody is attached to, and
+// to start, one nobody is attached to any more. Failing those it is a new session. The
+// alias is registered for conn either way.
+func (r *Router) openSessionTarget(conn *websocket.Conn, alias string, resume bool) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sid, ok := r.connAliases[conn][alias]
+	switch {
+	case ok:
+	case r.knownSessionUnsafe(alias):
+		sid = alias
+	default:
+		var attached string
+		for _, id := range r.sessionsByAliasUnsafe(alias) {
+			if st := r.sessionStates[id]; st != nil && st.attached() {
+				attached = id
+				continue
+			}
+			sid = id
+			break
+		}
+		if sid == "" && resume {
+			sid = attached
+		}
+		if sid == "" {
+			sid = newSessionID()
+			r.aliases[sid] = alias
+		}
+	}
+	if r.connAliases[conn] == nil {
+		r.connAliases[conn] = map[string]string{}
+	}
+	if _, ok := r.aliases[sid]; !ok {
+		// a session opened by its id keeps its alias; make the id usable as one
+		r.aliases[sid] = alias
+	}
+	r.connAliases[conn][alias] = sid
+	return sid
+}
+
+// knownSessionUnsafe reports whether sid is the id of a session, running or recently
+// exited. Caller must hold r.mu.
+func (r *Router) knownSessionUnsafe(sid string) bool {
+	return r.sessionStates[sid] != nil || r.events[sid] != nil
+}
+
+// sessionsByAliasUnsafe returns the known sessions with alias, dropping the aliases of
+// sessions that are gone. Caller must hold r.mu.
+func (r *Router) sessionsByAliasUnsafe(alias string) []string {
+	var ids []string
+	for sid, a := range r.aliases {
+		if !r.knownSessionUnsafe(sid) {
+			if r.sessions[sid] == nil {
+				delete(r.aliases, sid)
+			}
+			continue
+		}
+		if a == alias {
+			ids = append(ids, sid)
+		}
+	}
+	return ids
+}
+
+// sessionAlias returns the alias session sid was opened under
+func (r *Router) sessionAlias(sid string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.aliases[sid]
+}
+
+// attached reports whether a client is attached to the session
+func (st *sessionState) attached() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.currentConn != nil
+}
@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"log"
+	"time"
+)
+
+// drainPoll is how often DrainSessions checks whether the sessions have finished
+const drainPoll = 20 * time.Millisecond
+
+// DrainSessions ends every session on shutdown: each process is closed, and its last
+// output, exit message and recording are flushed to clients and disk as for any exit.
+// It waits up to timeout and reports whether every session finished.
+func (r *Router) DrainSessions(timeout time.Duration) bool {
+	r.mu.Lock()
+	sessions := make(map[string]ptySession, len(r.sessions))
+	for sid, sess := range r.sessions {
+		sessions[sid] = sess
+	}
+	r.mu.Unlock()
+	if len(sessions) == 0 {
+		return true
+	}
+	log.Printf("shutdown: ending %d sessions", len(sessions))
+	for sid, sess := range sessions {
+		r.logSessionEvent(sid, "shutdown", nil)
+		_ = sess.Close()
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		r.mu.Lock()
+		left := 0
+		for sid, sess := range sessions {
+			if r.sessions[sid] == sess {
+				left++
+			}
+		}
+		r.mu.Unlock()
+		if left == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			log.Printf("shutdown: %d sessions still running after %s", left, timeout)
+			return false
+		}
+		time.Sleep(drainPoll)
+	}
+}
@@ -0,0 +1,36 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouter_DrainSessionsFlushesExit(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	sid := readType(t, c, "opened")["sessionId"]
+	fs.last(t).emit("last words\r\n")
+	readStdout(t, c, "last words")
+
+	if !r.DrainSessions(2 * time.Second) {
+		t.Fatal("sessions did not finish")
+	}
+	if !fs.last(t).isClosed() {
+		t.Fatal("session process not closed")
+	}
+	if exit := readType(t, c, "exit"); exit["sessionId"] != sid {
+		t.Fatalf("unexpected exit: %v", exit)
+	}
+	r.mu.Lock()
+	left := len(r.sessions)
+	r.mu.Unlock()
+	if left != 0 {
+		t.Fatalf("%d sessions left after draining", left)
+	}
+	if !r.DrainSessions(time.Second) {
+		t.Fatal("draining without sessions should succeed at once")
+	}
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/example/rovobridge/internal/fileutil"
+)
+
+const (
+	// maxSnippets bounds the number of registered snippets
+	maxSnippets = 100
+	// maxSnippetBytes bounds the size of a single snippet
+	maxSnippetBytes = 1 << 20
+)
+
+// registerSnippet stores text as an in-memory file named name (e.g. "stacktrace.txt")
+// and returns the virtual path used to reference and inject it. Registering an existing
+// name replaces its content.
+func (r *Router) registerSnippet(name, text string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, "/\\:") {
+		return "", fmt.Errorf("invalid snippet name %q", name)
+	}
+	if len(text) > maxSnippetBytes {
+		return "", fmt.Errorf("snippet %s exceeds %d bytes", name, maxSnippetBytes)
+	}
+	path := fileutil.SnippetPath(name)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.snippets[path]; !exists && len(r.snippets) >= maxSnippets {
+		return "", fmt.Errorf("too many snippets (max %d)", maxSnippets)
+	}
+	r.snippets[path] = text
+	return path, nil
+}
+
+// removeSnippet drops a snippet by name or virtual path
+func (r *Router) removeSnippet(name string) bool {
+	path := name
+	if !fileutil.IsSnippetPath(path) {
+		path = fileutil.SnippetPath(name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.snippets[path]
+	delete(r.snippets, path)
+	return ok
+}
+
+// snippetList describes the registered snippets, sorted by path
+func (r *Router) snippetList() []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]map[string]any, 0, len(r.snippets))
+	for path, text := range r.snippets {
+		out = append(out, map[string]any{
+			"name":  strings.TrimPrefix(path, fileutil.SnippetPath("")),
+			"path":  path,
+			"bytes": len(text),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i]["path"].(string) < out[j]["path"].(string) })
+	return out
+}
+
+// snippetContents returns a copy of the snippets for a file read
+func (r *Router) snippetContents() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.snippets) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(r.snippets))
+	for path, text := range r.snippets {
+		out[path] = text
+	}
+	return out
+}
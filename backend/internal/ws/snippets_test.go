@@ -0,0 +1,33 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/example/rovobridge/internal/fileutil"
+)
+
+func TestSnippets_RegisterAndRead(t *testing.T) {
+	r := &Router{snippets: map[string]string{}}
+	if _, err := r.registerSnippet("../etc/passwd", "x"); err == nil {
+		t.Fatal("expected path-like names to be rejected")
+	}
+	path, err := r.registerSnippet("trace.txt", "panic: boom\n")
+	if err != nil || path != "snippet://trace.txt" {
+		t.Fatalf("registerSnippet = %q, %v", path, err)
+	}
+	if list := r.snippetList(); len(list) != 1 || list[0]["name"] != "trace.txt" || list[0]["bytes"] != 12 {
+		t.Fatalf("unexpected snippet list: %v", list)
+	}
+
+	res := fileutil.ReadFiles([]string{path}, fileutil.ReadOptions{Snippets: r.snippetContents()})
+	if res[0].Err != nil || res[0].Language != "text" {
+		t.Fatalf("expected snippet to be read, got %+v", res[0])
+	}
+
+	if !r.removeSnippet(path) || r.removeSnippet("trace.txt") {
+		t.Fatal("expected snippet to be removed once")
+	}
+	if r.snippetContents() != nil {
+		t.Fatal("expected no snippets left")
+	}
+}
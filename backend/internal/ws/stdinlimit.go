@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// StdinLimits bounds the raw input a client can push into a session with stdin messages.
+// Injected files and prompts are produced by the bridge itself and are not counted.
+type StdinLimits struct {
+	MaxMessageBytes int // largest decoded stdin message; 0 disables the check
+	BytesPerSecond  int // sustained rate per session; 0 disables rate limiting
+	BurstBytes      int // bytes a session may send at once before the rate applies
+}
+
+// DefaultStdinLimits allows large pastes while stopping a client from flooding a session
+func DefaultStdinLimits() StdinLimits {
+	return StdinLimits{MaxMessageBytes: 1 << 20, BytesPerSecond: 1 << 20, BurstBytes: 4 << 20}
+}
+
+// stdinBucket is a token bucket of stdin bytes for one session
+type stdinBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes n bytes from the bucket, refilled at lim.BytesPerSecond up to lim.BurstBytes,
+// and reports whether they were available. Rejected writes take nothing.
+func (b *stdinBucket) allow(n int, lim StdinLimits, now time.Time) bool {
+	if lim.BytesPerSecond <= 0 {
+		return true
+	}
+	burst := float64(lim.BurstBytes)
+	if burst < float64(lim.BytesPerSecond) {
+		burst = float64(lim.BytesPerSecond)
+	}
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(lim.BytesPerSecond)
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+	if float64(n) > b.tokens {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// checkStdinUnsafe applies the limits to a stdin message of n decoded bytes and returns
+// the error code of a rejection, or "". Caller must hold st.mu.
+func (st *sessionState) checkStdinUnsafe(n int, lim StdinLimits, now time.Time) string {
+	switch {
+	case lim.MaxMessageBytes > 0 && n > lim.MaxMessageBytes:
+		return "stdinTooLarge"
+	case !st.stdinBucket.allow(n, lim, now):
+		return "stdinRateLimited"
+	}
+	return ""
+}
+
+// rejectStdin counts n rejected stdin bytes and tells the client why they were dropped
+func (r *Router) rejectStdin(conn *websocket.Conn, sid, code string, n int) {
+	r.mu.Lock()
+	r.stdinRejected += int64(n)
+	lim := r.stdinLimits
+	r.mu.Unlock()
+	fields := map[string]any{"sessionId": sid, "bytes": n}
+	r.logSessionEvent(sid, "stdinRejected", map[string]any{"code": code, "bytes": n})
+	if code == "stdinTooLarge" {
+		fields["limit"] = lim.MaxMessageBytes
+		ErrorCode(conn, code, fields, "stdin message of %d bytes exceeds the %d byte limit", n, lim.MaxMessageBytes)
+		return
+	}
+	fields["limit"] = lim.BytesPerSecond
+	ErrorCode(conn, code, fields, "stdin rate limit of %d bytes/s exceeded; %d bytes dropped", lim.BytesPerSecond, n)
+}
+
+// SetStdinLimits replaces the limits applied to stdin messages
+func (r *Router) SetStdinLimits(lim StdinLimits) {
+	r.mu.Lock()
+	r.stdinLimits = lim
+	r.mu.Unlock()
+}
@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdinBucket(t *testing.T) {
+	lim := StdinLimits{BytesPerSecond: 100, BurstBytes: 300}
+	var b stdinBucket
+	now := time.Now()
+	if !b.allow(300, lim, now) {
+		t.Fatal("expected the full burst to be available")
+	}
+	if b.allow(1, lim, now) {
+		t.Fatal("expected an empty bucket to reject")
+	}
+	if b.allow(60, lim, now.Add(500*time.Millisecond)) {
+		t.Fatal("expected only 50 bytes after half a second")
+	}
+	if !b.allow(50, lim, now.Add(500*time.Millisecond)) {
+		t.Fatal("expected the refilled bytes to be available")
+	}
+	if !b.allow(1<<30, StdinLimits{}, now) {
+		t.Fatal("expected no rate limit when disabled")
+	}
+}
+
+func TestRouter_StdinLimits(t *testing.T) {
+	r, fs := newTestRouter(t)
+	r.SetStdinLimits(StdinLimits{MaxMessageBytes: 64, BytesPerSecond: 10, BurstBytes: 100})
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64(strings.Repeat("x", 65))})
+	if e := readType(t, c, "error"); e["code"] != "stdinTooLarge" || e["bytes"] != float64(65) || e["limit"] != float64(64) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	for i := 0; i < 2; i++ {
+		_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64(strings.Repeat("y", 50))})
+	}
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64(strings.Repeat("z", 50))})
+	if e := readType(t, c, "error"); e["code"] != "stdinRateLimited" || e["sessionId"] != sessionIDOf(t, r, "s1") {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	if in := f.stdinString(); in != strings.Repeat("y", 100) {
+		t.Errorf("expected only the permitted writes to reach the session, got %q", in)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "getStats"})
+	if stats := readType(t, c, "stats"); stats["stdinRejectedBytes"] != float64(115) {
+		t.Errorf("expected rejected bytes in stats, got %v", stats)
+	}
+}
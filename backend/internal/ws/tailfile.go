@@ -0,0 +1,264 @@
+package ws
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// tailPollInterval is how often followed files are checked for appended data
+	tailPollInterval = 250 * time.Millisecond
+	// maxTailsPerConn bounds the files one connection can follow at once
+	maxTailsPerConn = 8
+	// maxTailReadBytes bounds the data read from a file per poll; the rest waits for the next one
+	maxTailReadBytes = 256 << 10
+	// maxTailLineBytes splits longer lines so a file without newlines cannot grow the carry
+	maxTailLineBytes = 8 << 10
+	// defaultTailLines and maxTailInitialLines bound the existing lines sent when a tail starts
+	defaultTailLines    = 10
+	maxTailInitialLines = 1000
+)
+
+// errOutsideWorkspace rejects tail paths that resolve outside the session's working directory
+var errOutsideWorkspace = errors.New("path is outside the session's working directory")
+
+// fileTail follows one file for one connection
+type fileTail struct {
+	id   string
+	path string // as requested, reported back to the client
+	abs  string
+	stop chan struct{}
+}
+
+// resolveTailPath resolves p against the session working directory and rejects files
+// that are not inside it, after following symlinks
+func resolveTailPath(workingDir, p string) (string, error) {
+	abs := resolveSessionPath(workingDir, p)
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	root := workingDir
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", errOutsideWorkspace
+	}
+	fi, err := os.Stat(real)
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", errors.New("not a regular file")
+	}
+	return real, nil
+}
+
+// startTail begins streaming the lines appended to path as tailLines messages, after the
+// last n existing lines
+func (r *Router) startTail(conn *websocket.Conn, sid, path string, n int) error {
+	abs, err := resolveTailPath(r.sessionWorkingDir(sid), path)
+	if err != nil {
+		Errorf(conn, "tailFile: %v", err)
+		return nil
+	}
+	if n <= 0 {
+		n = defaultTailLines
+	}
+	if n > maxTailInitialLines {
+		n = maxTailInitialLines
+	}
+
+	r.mu.Lock()
+	if len(r.tails[conn]) >= maxTailsPerConn {
+		r.mu.Unlock()
+		Errorf(conn, "tailFile: at most %d files can be followed at once", maxTailsPerConn)
+		return nil
+	}
+	r.nextTail++
+	t := &fileTail{id: "tail" + strconv.FormatUint(r.nextTail, 10), path: path, abs: abs, stop: make(chan struct{})}
+	if r.tails[conn] == nil {
+		r.tails[conn] = map[string]*fileTail{}
+	}
+	r.tails[conn][t.id] = t
+	r.mu.Unlock()
+
+	f, err := os.Open(abs)
+	if err != nil {
+		r.removeTail(conn, t.id)
+		Errorf(conn, "tailFile: %v", err)
+		return nil
+	}
+	initial, offset, err := lastLines(f, n)
+	if err != nil {
+		f.Close()
+		r.removeTail(conn, t.id)
+		Errorf(conn, "tailFile: %v", err)
+		return nil
+	}
+	if err := SendJSON(conn, map[string]any{"type": "tailStarted", "tailId": t.id, "path": path}); err != nil {
+		f.Close()
+		return err
+	}
+	if len(initial) > 0 {
+		_ = SendJSON(conn, map[string]any{"type": "tailLines", "tailId": t.id, "path": path, "lines": initial})
+	}
+	go r.followTail(conn, t, f, offset)
+	return nil
+}
+
+// followTail polls the file for appended data until the tail is stopped or the file goes
+// away. A file that shrinks was truncated or rotated and is followed again from the start.
+func (r *Router) followTail(conn *websocket.Conn, t *fileTail, f *os.File, offset int64) {
+	defer f.Close()
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	var carry []byte
+	buf := make([]byte, maxTailReadBytes)
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(t.abs)
+		if err != nil {
+			r.endTail(conn, t, "file removed")
+			return
+		}
+		if cur, err := f.Stat(); err == nil && !os.SameFile(fi, cur) || fi.Size() < offset {
+			// Rotated or truncated: reopen and start over
+			nf, err := os.Open(t.abs)
+			if err != nil {
+				r.endTail(conn, t, err.Error())
+				return
+			}
+			f.Close()
+			f, offset, carry = nf, 0, nil
+			_ = SendJSON(conn, map[string]any{"type": "tailLines", "tailId": t.id, "path": t.path, "lines": []string{}, "truncated": true})
+		}
+		if fi.Size() == offset {
+			continue
+		}
+		n, err := f.ReadAt(buf, offset)
+		if n == 0 && err != nil && err != io.EOF {
+			r.endTail(conn, t, err.Error())
+			return
+		}
+		offset += int64(n)
+		var lines []string
+		lines, carry = splitTailLines(carry, buf[:n])
+		if len(lines) > 0 {
+			_ = SendJSON(conn, map[string]any{"type": "tailLines", "tailId": t.id, "path": t.path, "lines": lines})
+		}
+	}
+}
+
+// splitTailLines returns the complete lines in carry+data and the incomplete rest. Lines
+// longer than maxTailLineBytes are split.
+func splitTailLines(carry, data []byte) ([]string, []byte) {
+	data = append(carry, data...)
+	var lines []string
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		for len(data[:i]) > maxTailLineBytes {
+			lines = append(lines, string(data[:maxTailLineBytes]))
+			data, i = data[maxTailLineBytes:], i-maxTailLineBytes
+		}
+		lines = append(lines, strings.TrimSuffix(string(data[:i]), "\r"))
+		data = data[i+1:]
+	}
+	for len(data) > maxTailLineBytes {
+		lines = append(lines, string(data[:maxTailLineBytes]))
+		data = data[maxTailLineBytes:]
+	}
+	return lines, append([]byte(nil), data...)
+}
+
+// lastLines returns up to n complete lines at the end of f and the offset following
+// them, reading at most maxTailReadBytes from the end
+func lastLines(f *os.File, n int) ([]string, int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := fi.Size()
+	start := size - maxTailReadBytes
+	if start < 0 {
+		start = 0
+	}
+	buf := make([]byte, size-start)
+	if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	// Only complete lines; a partial last line is sent once it is finished
+	end := bytes.LastIndexByte(buf, '\n') + 1
+	lines, _ := splitTailLines(nil, buf[:end])
+	if start > 0 && len(lines) > 0 {
+		lines = lines[1:] // the first line was cut by the read window
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, start + int64(end), nil
+}
+
+// endTail reports that a tail stopped on its own and forgets it
+func (r *Router) endTail(conn *websocket.Conn, t *fileTail, reason string) {
+	if r.removeTail(conn, t.id) != nil {
+		_ = SendJSON(conn, map[string]any{"type": "tailStopped", "tailId": t.id, "path": t.path, "reason": reason})
+	}
+}
+
+// removeTail forgets a tail and returns it, or nil if it was already gone
+func (r *Router) removeTail(conn *websocket.Conn, id string) *fileTail {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.tails[conn][id]
+	if t == nil {
+		return nil
+	}
+	delete(r.tails[conn], id)
+	if len(r.tails[conn]) == 0 {
+		delete(r.tails, conn)
+	}
+	return t
+}
+
+// stopTail stops a tail at the client's request
+func (r *Router) stopTail(conn *websocket.Conn, id string) error {
+	t := r.removeTail(conn, id)
+	if t == nil {
+		Errorf(conn, "stopTail: unknown tail %q", id)
+		return nil
+	}
+	close(t.stop)
+	return SendJSON(conn, map[string]any{"type": "tailStopped", "tailId": t.id, "path": t.path, "reason": "stopped"})
+}
+
+// dropTails stops the tails of a closing connection
+func (r *Router) dropTails(conn *websocket.Conn) {
+	r.mu.Lock()
+	tails := r.tails[conn]
+	delete(r.tails, conn)
+	r.mu.Unlock()
+	for _, t := range tails {
+		close(t.stop)
+	}
+}
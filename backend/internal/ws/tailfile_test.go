@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouter_TailFileFollowsAppends(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(log, []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	appendLog := func(s string) {
+		t.Helper()
+		f, err := os.OpenFile(log, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := func(msg map[string]any) []string {
+		var out []string
+		for _, l := range msg["lines"].([]any) {
+			out = append(out, l.(string))
+		}
+		return out
+	}
+
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir})
+	readType(t, c, "opened")
+
+	_ = c.WriteJSON(map[string]any{"type": "tailFile", "sessionId": "s1", "path": "../outside.log"})
+	readType(t, c, "error")
+
+	_ = c.WriteJSON(map[string]any{"type": "tailFile", "sessionId": "s1", "path": "app.log", "lines": 2})
+	id := readType(t, c, "tailStarted")["tailId"]
+	if got := lines(readType(t, c, "tailLines")); !reflect.DeepEqual(got, []string{"two", "three"}) {
+		t.Fatalf("unexpected initial lines: %v", got)
+	}
+
+	appendLog("four\npart")
+	if got := lines(readType(t, c, "tailLines")); !reflect.DeepEqual(got, []string{"four"}) {
+		t.Fatalf("unexpected appended lines: %v", got)
+	}
+	appendLog("ial\n")
+	if got := lines(readType(t, c, "tailLines")); !reflect.DeepEqual(got, []string{"partial"}) {
+		t.Fatalf("expected the completed line, got %v", got)
+	}
+
+	if err := os.WriteFile(log, []byte("fresh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readType(t, c, "tailLines"); msg["truncated"] != true {
+		t.Fatalf("expected truncation to be reported, got %v", msg)
+	}
+	if got := lines(readType(t, c, "tailLines")); !reflect.DeepEqual(got, []string{"fresh"}) {
+		t.Fatalf("expected to follow from the start after truncation, got %v", got)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "stopTail", "tailId": id})
+	if msg := readType(t, c, "tailStopped"); msg["reason"] != "stopped" {
+		t.Fatalf("unexpected stop: %v", msg)
+	}
+	eventually(t, "tail forgotten", func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.tails) == 0
+	})
+}
+
+func TestResolveTailPath_RejectsSymlinksOutOfWorkspace(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	secret := filepath.Join(outside, "secret.log")
+	if err := os.WriteFile(secret, []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(dir, "link.log")); err != nil {
+		t.Skip("symlinks unavailable:", err)
+	}
+	for _, p := range []string{"link.log", secret} {
+		if _, err := resolveTailPath(dir, p); err != errOutsideWorkspace {
+			t.Errorf("resolveTailPath(%q): expected errOutsideWorkspace, got %v", p, err)
+		}
+	}
+}
+
+func TestSplitTailLines_BoundsLongLines(t *testing.T) {
+	long := strings.Repeat("x", maxTailLineBytes+10)
+	lines, rest := splitTailLines([]byte("ab"), []byte("c\r\n"+long+"\nta"))
+	if len(lines) != 3 || lines[0] != "abc" || len(lines[1]) != maxTailLineBytes || lines[2] != "xxxxxxxxxx" || string(rest) != "ta" {
+		t.Fatalf("unexpected split: %q / %q", lines, rest)
+	}
+}
@@ -0,0 +1,151 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/example/rovobridge/internal/policy"
+	"github.com/example/rovobridge/internal/session"
+	"github.com/example/rovobridge/internal/tasks"
+	"github.com/gorilla/websocket"
+)
+
+// taskTimeout bounds a task run; a hung test suite must not hold the session's slot
+const taskTimeout = 15 * time.Minute
+
+// runningTask is the task run in progress for a session
+type runningTask struct {
+	id     string
+	name   string
+	cancel context.CancelFunc
+	sess   ptySession
+}
+
+// stop ends the run; its result is still reported, marked canceled
+func (t *runningTask) stop() {
+	t.cancel()
+	_ = t.sess.Close()
+}
+
+// listTasks answers listTasks with the tasks of the session's workspace
+func (r *Router) listTasks(conn *websocket.Conn, sid string) error {
+	list, err := tasks.Load(r.sessionWorkingDir(sid))
+	if err != nil {
+		Errorf(conn, "listTasks: %v", err)
+		return nil
+	}
+	return SendJSON(conn, map[string]any{"type": "tasks", "sessionId": sid, "tasks": list})
+}
+
+// runTask starts the named task of the session's workspace as an auxiliary process without
+// a PTY. The parsed result is sent as taskResult and kept for injectTaskResult on send.
+func (r *Router) runTask(conn *websocket.Conn, sid, name string) error {
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	pol := r.policy
+	r.mu.Unlock()
+	if st == nil {
+		Errorf(conn, "no session")
+		return nil
+	}
+	dir := r.sessionWorkingDir(sid)
+	list, err := tasks.Load(dir)
+	if err != nil {
+		Errorf(conn, "runTask: %v", err)
+		return nil
+	}
+	task, ok := tasks.Find(list, name)
+	if !ok {
+		Errorf(conn, "runTask: unknown task %q", name)
+		return nil
+	}
+	if pol != nil {
+		task.Cmd = policy.Resolve(task.Cmd, dir)
+	}
+	if err := pol.Check("runTask", task.Cmd); err != nil {
+		Errorf(conn, "runTask: %v", err)
+		return nil
+	}
+
+	st.mu.Lock()
+	if st.task != nil {
+		st.mu.Unlock()
+		Errorf(conn, "runTask: %q is already running", st.task.name)
+		return nil
+	}
+	st.nextTask++
+	run := &runningTask{id: "task" + strconv.FormatUint(st.nextTask, 10), name: task.Name}
+	st.task = run
+	st.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), taskTimeout)
+	sess, err := r.startSession(ctx, session.Config{Cmd: task.Cmd, Args: task.Args, Dir: dir, Mode: session.ModeNoPTY})
+	if err != nil {
+		cancel()
+		st.mu.Lock()
+		st.task = nil
+		st.mu.Unlock()
+		Errorf(conn, "runTask: failed to start: %v", err)
+		return nil
+	}
+	st.mu.Lock()
+	run.cancel, run.sess = cancel, sess
+	st.mu.Unlock()
+	if err := SendJSON(conn, map[string]any{"type": "taskStarted", "sessionId": sid, "runId": run.id, "task": task}); err != nil {
+		return err
+	}
+
+	go func() {
+		defer cancel()
+		start := time.Now()
+		p := tasks.NewParser(task.Name, task.Format)
+		sc := bufio.NewScanner(sess.Stdout())
+		sc.Buffer(make([]byte, 64*1024), 1<<20)
+		for sc.Scan() {
+			p.Line(sc.Text())
+		}
+		code := exitCode(sess.Wait())
+		res := p.Result(code, time.Since(start))
+		res.Canceled = ctx.Err() != nil
+
+		st.mu.Lock()
+		if st.task == run {
+			st.task = nil
+		}
+		st.lastTask = &res
+		target := st.currentConn
+		st.mu.Unlock()
+		msg := map[string]any{"type": "taskResult", "sessionId": sid, "runId": run.id, "result": res}
+		r.mu.Lock()
+		live := r.sessionStates[sid] == st
+		r.mu.Unlock()
+		if live {
+			r.recordEvent(sid, msg)
+		}
+		if target != nil {
+			_ = SendJSON(target, msg)
+		}
+	}()
+	return nil
+}
+
+// cancelTask stops the session's running task, if any
+func (r *Router) cancelTask(conn *websocket.Conn, sid string) error {
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	var run *runningTask
+	if st != nil {
+		st.mu.Lock()
+		run = st.task
+		st.mu.Unlock()
+	}
+	if run == nil || run.sess == nil {
+		Errorf(conn, "cancelTask: no task running")
+		return nil
+	}
+	run.stop()
+	return nil
+}
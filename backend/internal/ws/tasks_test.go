@@ -0,0 +1,72 @@
+package ws
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRouter_RunTaskReportsAndInjectsFailures(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir, "useClipboard": false})
+	readType(t, c, "opened")
+	agent := fs.last(t)
+
+	_ = c.WriteJSON(map[string]any{"type": "listTasks", "sessionId": "s1"})
+	list := readType(t, c, "tasks")["tasks"].([]any)
+	if len(list) != 1 || list[0].(map[string]any)["name"] != "go test" {
+		t.Fatalf("unexpected tasks: %v", list)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "runTask", "sessionId": "s1", "name": "go test"})
+	readType(t, c, "taskStarted")
+	task := fs.last(t)
+	if task == agent || task.cfg.Cmd != "go" || task.cfg.Dir != dir {
+		t.Fatalf("expected an auxiliary go process in the workspace, got %+v", task.cfg)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "runTask", "sessionId": "s1", "name": "go test"})
+	readType(t, c, "error") // one run at a time
+
+	task.emit(`{"Action":"output","Package":"x","Test":"TestAdd","Output":"    add_test.go:9: got 3\n"}` + "\n")
+	task.emit(`{"Action":"fail","Package":"x","Test":"TestAdd"}` + "\n")
+	task.exit(errors.New("exit status 1"))
+	res := readType(t, c, "taskResult")["result"].(map[string]any)
+	if res["success"] != false || res["failed"] != float64(1) {
+		t.Fatalf("unexpected result: %v", res)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "send", "sessionId": "s1", "dataBase64": b64("fix these"), "injectTaskResult": true})
+	eventually(t, "prompt with failing tests", func() bool {
+		in := agent.stdinString()
+		return strings.HasPrefix(in, "fix these") && strings.Contains(in, "- x TestAdd") && strings.Contains(in, "add_test.go:9: got 3")
+	})
+}
+
+func TestRouter_CancelTask(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "cwd": dir})
+	readType(t, c, "opened")
+
+	_ = c.WriteJSON(map[string]any{"type": "runTask", "sessionId": "s1", "name": "nope"})
+	readType(t, c, "error")
+	_ = c.WriteJSON(map[string]any{"type": "runTask", "sessionId": "s1", "name": "go test"})
+	readType(t, c, "taskStarted")
+	_ = c.WriteJSON(map[string]any{"type": "cancelTask", "sessionId": "s1"})
+	if res := readType(t, c, "taskResult")["result"].(map[string]any); res["canceled"] != true || !fs.last(t).isClosed() {
+		t.Fatalf("expected a canceled result, got %v", res)
+	}
+}
@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"strings"
+
+	"github.com/example/rovobridge/internal/terminfo"
+)
+
+// SetDowngradeTerm sets whether sessions asking for an unknown TERM get terminfo.Fallback
+// instead; openSession can override it with downgradeTerm
+func (r *Router) SetDowngradeTerm(on bool) {
+	r.mu.Lock()
+	r.downgradeTerm = on
+	r.mu.Unlock()
+}
+
+// envTerm returns the TERM set in env, the last one winning as it does for the process
+func envTerm(env []string) (string, bool) {
+	term, set := "", false
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "TERM="); ok {
+			term, set = v, true
+		}
+	}
+	return term, set
+}
+
+// checkTerm validates the TERM a client set in the env of openSession against the
+// embedded terminal database. An unknown one is replaced by terminfo.Fallback when the
+// session's downgradeTerm option, or the router's default, asks for it. It returns the
+// env to start the session with and the termWarning to send with opened, nil when the
+// TERM is known or not set.
+func (r *Router) checkTerm(sid string, env []string, m map[string]any) ([]string, map[string]any) {
+	term, set := envTerm(env)
+	if !set {
+		return env, nil
+	}
+	err := terminfo.Check(term)
+	if err == nil {
+		return env, nil
+	}
+	r.mu.Lock()
+	downgrade := r.downgradeTerm
+	r.mu.Unlock()
+	if v, ok := m["downgradeTerm"].(bool); ok {
+		downgrade = v
+	}
+	warning := map[string]any{
+		"type":       "termWarning",
+		"sessionId":  sid,
+		"term":       term,
+		"message":    err.Error(),
+		"fallback":   terminfo.Fallback,
+		"downgraded": downgrade,
+	}
+	if downgrade {
+		out := make([]string, 0, len(env))
+		for _, kv := range env {
+			if !strings.HasPrefix(kv, "TERM=") {
+				out = append(out, kv)
+			}
+		}
+		env = append(out, "TERM="+terminfo.Fallback)
+	}
+	return env, warning
+}
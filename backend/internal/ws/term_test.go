@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOpenSession_UnknownTermWarns(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "env": []string{"TERM=xterm-256color-italic", "LANG=C"}})
+	readType(t, c, "opened")
+	warning := readType(t, c, "termWarning")
+	if warning["term"] != "xterm-256color-italic" || warning["fallback"] != "xterm-256color" || warning["downgraded"] != false {
+		t.Fatalf("unexpected termWarning: %v", warning)
+	}
+	if env := fs.last(t).cfg.Env; !slices.Contains(env, "TERM=xterm-256color-italic") {
+		t.Fatalf("TERM changed without downgradeTerm: %v", env)
+	}
+}
+
+func TestOpenSession_DowngradesUnknownTerm(t *testing.T) {
+	r, fs := newTestRouter(t)
+	r.SetDowngradeTerm(true)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "env": []string{"TERM=hpterm", "LANG=C"}})
+	readType(t, c, "opened")
+	if warning := readType(t, c, "termWarning"); warning["downgraded"] != true {
+		t.Fatalf("unexpected termWarning: %v", warning)
+	}
+	if env := fs.last(t).cfg.Env; !slices.Equal(env, []string{"LANG=C", "TERM=xterm-256color"}) {
+		t.Fatalf("unexpected env: %v", env)
+	}
+
+	// The session's option wins over the router's default, and a known TERM is left alone
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s2", "env": []string{"TERM=hpterm"}, "downgradeTerm": false})
+	if warning := readType(t, c, "termWarning"); warning["downgraded"] != false {
+		t.Fatalf("unexpected termWarning: %v", warning)
+	}
+	env, warning := r.checkTerm("s3", []string{"TERM=tmux-256color"}, map[string]any{})
+	if warning != nil || !slices.Equal(env, []string{"TERM=tmux-256color"}) {
+		t.Fatalf("known TERM changed: %v, %v", env, warning)
+	}
+}
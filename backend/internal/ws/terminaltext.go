@@ -0,0 +1,257 @@
+package ws
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// maxOutputTailLines caps the injectOutputTail line count requested by clients
+const maxOutputTailLines = 500
+
+// plainLine is a line of plain text with, for each of its bytes, the absolute offset
+// of the raw output byte it came from
+type plainLine struct {
+	text    string
+	offsets []int64
+}
+
+// plainTextLines converts raw terminal output into plain text lines. Escape sequences
+// (CSI, OSC, DCS and charset selections) are removed, a lone carriage return restarts
+// the current line the way progress bars redraw it, and backspace erases a cell. Cells
+// are counted the way terminals do: CJK and emoji are two wide and combining marks take
+// none, so cursor-forward and column moves are turned into the right number of spaces.
+func plainTextLines(b []byte) []string {
+	lines := scanPlainText(b, 0, false)
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = l.text
+	}
+	return out
+}
+
+// scanPlainText implements plainTextLines; with offsets, each line also maps its bytes
+// back to raw stream offsets starting at base.
+func scanPlainText(b []byte, base int64, offsets bool) []plainLine {
+	var lines []plainLine
+	var cur []byte
+	var offs []int64
+	back := 0 // cells the cursor has been moved back into the last rune, when it is wide
+	pad := func(n int, off int64) {
+		for ; n > 0; n-- {
+			cur = append(cur, ' ')
+			if offsets {
+				offs = append(offs, off)
+			}
+		}
+	}
+	// settle resolves a cursor left inside a wide rune: writing there blanks the rune
+	settle := func(off int64) {
+		if back == 0 {
+			return
+		}
+		r, size := utf8.DecodeLastRune(cur)
+		cur = cur[:len(cur)-size]
+		if offsets {
+			offs = offs[:len(cur)]
+		}
+		pad(runewidth.RuneWidth(r)-back, off)
+		back = 0
+	}
+	emit := func() {
+		text := strings.TrimRight(string(cur), " \t")
+		l := plainLine{text: text}
+		if offsets {
+			l.offsets = append([]int64(nil), offs[:len(text)]...)
+		}
+		lines = append(lines, l)
+		cur, offs = cur[:0], offs[:0]
+		back = 0
+	}
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == 0x1b:
+			end := skipEscape(b, i)
+			if n, final, ok := cursorMove(b[i : end+1]); ok {
+				settle(base + int64(i))
+				switch final {
+				case 'C': // cursor forward n cells
+					pad(n, base+int64(i))
+				case 'G': // cursor to column n
+					pad(n-1-runewidth.StringWidth(string(cur)), base+int64(i))
+				}
+			}
+			i = end
+		case c == '\n':
+			emit()
+		case c == '\r':
+			if i+1 < len(b) && b[i+1] == '\n' {
+				continue
+			}
+			cur, offs = cur[:0], offs[:0]
+			back = 0
+		case c == '\b':
+			// One cell left: a wide rune goes once the cursor is back at its start, and
+			// combining marks go with the rune they sit on
+			for len(cur) > 0 {
+				r, size := utf8.DecodeLastRune(cur)
+				w := runewidth.RuneWidth(r)
+				if w > 1 && back+1 < w {
+					back++
+					break
+				}
+				cur = cur[:len(cur)-size]
+				if offsets {
+					offs = offs[:len(cur)]
+				}
+				back = 0
+				if w > 0 {
+					break
+				}
+			}
+		case c == '\t' || c >= 0x20 && c != 0x7f:
+			settle(base + int64(i))
+			cur = append(cur, c)
+			if offsets {
+				offs = append(offs, base+int64(i))
+			}
+		}
+	}
+	if len(cur) > 0 {
+		emit()
+	}
+	return lines
+}
+
+// skipEscape returns the index of the last byte of the escape sequence starting at b[i]
+func skipEscape(b []byte, i int) int {
+	if i+1 >= len(b) {
+		return i
+	}
+	switch b[i+1] {
+	case '[': // CSI: parameters and intermediates up to a final byte in 0x40..0x7e
+		for j := i + 2; j < len(b); j++ {
+			if b[j] >= 0x40 && b[j] <= 0x7e {
+				return j
+			}
+		}
+		return len(b) - 1
+	case ']', 'P', 'X', '^', '_': // OSC/DCS/SOS/PM/APC: terminated by BEL or ST (ESC \)
+		for j := i + 2; j < len(b); j++ {
+			if b[j] == 0x07 {
+				return j
+			}
+			if b[j] == 0x1b && j+1 < len(b) && b[j+1] == '\\' {
+				return j + 1
+			}
+		}
+		return len(b) - 1
+	case '(', ')', '*', '+', '#', '%': // charset selection and similar two-byte sequences
+		if i+2 < len(b) {
+			return i + 2
+		}
+		return len(b) - 1
+	}
+	return i + 1
+}
+
+// cursorMove parses a complete CSI cursor-forward (CUF, "ESC [ n C") or column (CHA,
+// "ESC [ n G") sequence and returns its count, defaulting to 1, and final byte
+func cursorMove(seq []byte) (int, byte, bool) {
+	if len(seq) < 3 || seq[1] != '[' {
+		return 0, 0, false
+	}
+	final := seq[len(seq)-1]
+	if final != 'C' && final != 'G' {
+		return 0, 0, false
+	}
+	param := string(seq[2 : len(seq)-1])
+	if param == "" {
+		return 1, final, true
+	}
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 0 || n > 1000 {
+		return 0, 0, false
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n, final, true
+}
+
+// outputTail returns the last n lines of raw terminal output as plain text, ignoring trailing blank lines
+func outputTail(b []byte, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	lines := plainTextLines(b)
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxMirrorPartial bounds the unterminated line kept by plainTextMirror; longer
+// lines (e.g. full-screen redraws without newlines) are dropped
+const maxMirrorPartial = 64 * 1024
+
+// plainTextMirror turns a session's raw output stream into complete plain-text lines,
+// carrying unterminated lines and escape sequences over to the next chunk. Lines keep
+// the absolute stream offsets of their bytes.
+type plainTextMirror struct {
+	partial []byte
+	start   int64 // stream offset of partial[0]
+}
+
+// write consumes a chunk of raw output and returns the lines completed by it
+func (m *plainTextMirror) write(chunk []byte) []plainLine {
+	last := -1
+	for i := len(chunk) - 1; i >= 0; i-- {
+		if chunk[i] == '\n' {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		if len(m.partial)+len(chunk) > maxMirrorPartial {
+			m.start += int64(len(m.partial) + len(chunk))
+			m.partial = m.partial[:0]
+			return nil
+		}
+		m.partial = append(m.partial, chunk...)
+		return nil
+	}
+	data := append(m.partial, chunk[:last+1]...)
+	lines := scanPlainText(data, m.start, true)
+	m.start += int64(len(data))
+	rest := chunk[last+1:]
+	if len(rest) > maxMirrorPartial {
+		m.start += int64(len(rest))
+		rest = nil
+	}
+	m.partial = append(data[:0], rest...)
+	return lines
+}
+
+// maxPendingScan bounds the unterminated line converted by pending; prompts waiting for
+// input are short
+const maxPendingScan = 4 * 1024
+
+// pending returns the plain text of the unterminated line, such as a prompt waiting for
+// input, or "" when it is too long to be one
+func (m *plainTextMirror) pending() string {
+	if len(m.partial) == 0 || len(m.partial) > maxPendingScan {
+		return ""
+	}
+	lines := plainTextLines(m.partial)
+	if len(lines) == 0 {
+		return ""
+	}
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,62 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlainTextLines_StripsTerminalControl(t *testing.T) {
+	raw := "\x1b]0;title\x07\x1b[1;31merror\x1b[0m: boom  \r\n" +
+		"progress 10%\rprogress 100%\n" +
+		"typo\b\b\bext\n" +
+		"\x1b(Bcharset \x1bP1$r0m\x1b\\done"
+	got := plainTextLines([]byte(raw))
+	want := []string{"error: boom", "progress 100%", "text", "charset done"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestPlainTextLines_CountsTerminalCells(t *testing.T) {
+	raw := "ab漢\b\b  \b\bc\n" + // readline erasing a wide rune
+		"漢\b x\n" + // writing over the second half blanks the whole rune
+		"cafe\u0301\b\bfé\n" + // a combining mark goes with its base
+		"🙂\b\bok\n" +
+		"名前\x1b[3Cok\x1b[12Gend\x1b[Cx\n"
+	got := plainTextLines([]byte(raw))
+	want := []string{"abc", "  x", "café", "ok", "名前   ok  end x"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	raw := []byte("one\ntwo\nthree\n\x1b[32mfour\x1b[0m\n\n\n")
+	if got := outputTail(raw, 2); got != "three\nfour" {
+		t.Fatalf("unexpected tail %q", got)
+	}
+	if got := outputTail(raw, 10); got != "one\ntwo\nthree\nfour" {
+		t.Fatalf("unexpected full tail %q", got)
+	}
+	if got := outputTail(raw, 0); got != "" {
+		t.Fatalf("expected empty tail, got %q", got)
+	}
+}
+
+func TestPlainTextMirror_SplitsAcrossChunks(t *testing.T) {
+	var m plainTextMirror
+	var got []plainLine
+	for _, chunk := range []string{"first li", "ne\r\nsec\x1b[3", "1mond\x1b[0m\nthi", "rd"} {
+		got = append(got, m.write([]byte(chunk))...)
+	}
+	if len(got) != 2 || got[0].text != "first line" || got[1].text != "second" {
+		t.Fatalf("unexpected lines %+v", got)
+	}
+	// "second" starts after "first line\r\n" (12 bytes) and "sec\x1b[31m" puts "o" at 20
+	if got[1].offsets[0] != 12 || got[1].offsets[3] != 20 {
+		t.Fatalf("unexpected offsets %v", got[1].offsets)
+	}
+	if got := m.write([]byte("\n")); len(got) != 1 || got[0].text != "third" || got[0].offsets[0] != 28 {
+		t.Fatalf("expected the pending line on newline, got %+v", got)
+	}
+}
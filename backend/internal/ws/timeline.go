@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// timelineSpacing is the minimum time between recorded timeline marks
+	timelineSpacing = time.Second
+	// maxTimelineMarks bounds the marks kept per session; when full, every other mark is
+	// dropped, so long sessions keep coarser marks over their whole run
+	maxTimelineMarks = 4096
+)
+
+// timeMark records when the output at a stream offset was read from the process
+type timeMark struct {
+	at     time.Time
+	offset int64
+}
+
+// outputTimeline maps wall-clock times to stream offsets for "jump to time" navigation
+type outputTimeline struct {
+	marks   []timeMark // ascending in both time and offset
+	spacing time.Duration
+}
+
+// mark notes that the output at offset was read at now. Marks closer than the current
+// spacing to the previous one are skipped.
+func (tl *outputTimeline) mark(now time.Time, offset int64) {
+	if tl.spacing == 0 {
+		tl.spacing = timelineSpacing
+	}
+	if n := len(tl.marks); n > 0 && now.Sub(tl.marks[n-1].at) < tl.spacing {
+		return
+	}
+	if len(tl.marks) >= maxTimelineMarks {
+		kept := tl.marks[:0]
+		for i := 0; i < len(tl.marks); i += 2 {
+			kept = append(kept, tl.marks[i])
+		}
+		tl.marks = kept
+		tl.spacing *= 2
+	}
+	tl.marks = append(tl.marks, timeMark{at: now, offset: offset})
+}
+
+// resolve returns the first mark at or after t. Past the last mark it reports false, as
+// nothing was printed since.
+func (tl *outputTimeline) resolve(t time.Time) (timeMark, bool) {
+	i := sort.Search(len(tl.marks), func(i int) bool { return !tl.marks[i].at.Before(t) })
+	if i == len(tl.marks) {
+		return timeMark{}, false
+	}
+	return tl.marks[i], true
+}
+
+// resolveTime answers resolveTime with the stream offset of the first output printed at
+// or after t, and the offset the snapshot starts at, so the client knows whether it can
+// scroll there
+func (r *Router) resolveTime(conn *websocket.Conn, sid string, t time.Time) error {
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	r.mu.Unlock()
+	if st == nil {
+		Errorf(conn, "no session")
+		return nil
+	}
+	st.mu.Lock()
+	end := st.sentBytes + int64(len(st.outBuf))
+	replayStart := st.replayScan.trimmed
+	mk, ok := st.timeline.resolve(t)
+	var first time.Time
+	if len(st.timeline.marks) > 0 {
+		first = st.timeline.marks[0].at
+	}
+	st.mu.Unlock()
+
+	reply := map[string]any{"type": "timeResolved", "sessionId": sid, "time": t.UnixMilli(), "replayStart": replayStart}
+	if !first.IsZero() {
+		reply["firstOutput"] = first.UnixMilli()
+	}
+	if ok {
+		reply["offset"] = mk.offset
+		reply["at"] = mk.at.UnixMilli()
+	} else {
+		// Nothing printed since t: the position is the end of the output
+		reply["offset"] = end
+	}
+	return SendJSON(conn, reply)
+}
+
+// parseTime reads a time given as Unix milliseconds or as an RFC 3339 string
+func parseTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case float64:
+		return time.UnixMilli(int64(t)), true
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		return parsed, err == nil
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutputTimeline_ResolvesAndCompacts(t *testing.T) {
+	start := time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC)
+	var tl outputTimeline
+	tl.mark(start, 0)
+	tl.mark(start.Add(100*time.Millisecond), 10) // too close to the previous mark
+	tl.mark(start.Add(2*time.Minute), 500)
+	tl.mark(start.Add(5*time.Minute), 900)
+	if len(tl.marks) != 3 {
+		t.Fatalf("expected 3 marks, got %+v", tl.marks)
+	}
+
+	if mk, ok := tl.resolve(start.Add(time.Minute)); !ok || mk.offset != 500 {
+		t.Errorf("14:31 should resolve to the output printed at 14:32, got %+v", mk)
+	}
+	if mk, ok := tl.resolve(start.Add(-time.Hour)); !ok || mk.offset != 0 {
+		t.Errorf("a time before the session should resolve to its start, got %+v", mk)
+	}
+	if _, ok := tl.resolve(start.Add(time.Hour)); ok {
+		t.Error("a time after the last output should not resolve to a mark")
+	}
+
+	// A full timeline halves its resolution instead of forgetting the start
+	for i := 1; len(tl.marks) < maxTimelineMarks; i++ {
+		tl.mark(start.Add(5*time.Minute+time.Duration(i)*time.Second), int64(900+i))
+	}
+	last := tl.marks[len(tl.marks)-1].at
+	tl.mark(last.Add(time.Second), 1<<20)
+	if len(tl.marks) != maxTimelineMarks/2+1 || tl.marks[0].offset != 0 || tl.spacing != 2*timelineSpacing {
+		t.Fatalf("unexpected compaction: %d marks, first %+v, spacing %v", len(tl.marks), tl.marks[0], tl.spacing)
+	}
+}
+
+func TestRouter_ResolveTime(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	before := time.Now()
+	fs.last(t).emit("building...\n")
+	readStdout(t, c, "building")
+
+	_ = c.WriteJSON(map[string]any{"type": "resolveTime", "sessionId": "s1", "time": before.Add(-time.Second).Format(time.RFC3339Nano)})
+	got := readType(t, c, "timeResolved")
+	if got["offset"] != float64(0) || got["replayStart"] != float64(0) || got["firstOutput"] == nil {
+		t.Fatalf("unexpected resolution: %v", got)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "resolveTime", "sessionId": "s1", "time": time.Now().Add(time.Minute).UnixMilli()})
+	if got := readType(t, c, "timeResolved"); got["offset"] != float64(len("building...\n")) || got["at"] != nil {
+		t.Fatalf("a future time should resolve to the end of the output: %v", got)
+	}
+
+	_ = c.WriteJSON(map[string]any{"type": "resolveTime", "sessionId": "s1", "time": "half past two"})
+	readType(t, c, "error")
+}
@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/rovobridge/internal/fileutil"
+	"github.com/gorilla/websocket"
+)
+
+// Timeouts bound the blocking work done while handling a message, so a stuck file
+// system, history store or clipboard tool cannot wedge message processing. A zero field
+// removes that bound; the connection's context still ends the wait when the client
+// disconnects.
+type Timeouts struct {
+	FileRead  time.Duration // reading one file for injection, unless the message sets timeoutMs
+	History   time.Duration // one prompt history load, save or query
+	Clipboard time.Duration // one clipboard read or write
+	Search    time.Duration // an index search or context selection
+}
+
+// DefaultTimeouts returns bounds generous enough for slow disks and large workspaces
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		FileRead:  fileutil.DefaultReadTimeout,
+		History:   5 * time.Second,
+		Clipboard: 3 * time.Second,
+		Search:    5 * time.Second,
+	}
+}
+
+// SetTimeouts replaces the bounds on blocking work in message handlers
+func (r *Router) SetTimeouts(t Timeouts) {
+	r.mu.Lock()
+	r.timeouts = t
+	r.mu.Unlock()
+}
+
+func (r *Router) getTimeouts() Timeouts {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timeouts
+}
+
+// errTimeout marks errors of operations abandoned by withTimeout
+var errTimeout = errors.New("timed out")
+
+// withTimeout runs fn and waits for its result until ctx ends or d elapses (d <= 0 waits
+// for ctx only). fn gets the bounded context for the work that can be cancelled, such as
+// commands; blocking system calls cannot be interrupted, so an abandoned fn keeps running
+// in the background and its result is dropped, like timed out reads in fileutil.ReadFiles.
+// A panic in fn is re-raised in the caller, where handleSafely recovers it.
+func withTimeout[T any](ctx context.Context, d time.Duration, what string, fn func(context.Context) (T, error)) (T, error) {
+	if d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	type outcome struct {
+		v     T
+		err   error
+		panic any
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		var o outcome
+		defer func() {
+			o.panic = recover()
+			done <- o
+		}()
+		o.v, o.err = fn(ctx)
+	}()
+	select {
+	case o := <-done:
+		if o.panic != nil {
+			panic(o.panic)
+		}
+		return o.v, o.err
+	case <-ctx.Done():
+		var zero T
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, fmt.Errorf("%s %w after %s", what, errTimeout, d)
+		}
+		return zero, fmt.Errorf("%s: %w", what, ctx.Err())
+	}
+}
+
+// replyTimeout tells the client a message was abandoned because an operation timed out.
+// Other errors, such as the connection closing, are not reported.
+func replyTimeout(conn *websocket.Conn, messageType string, err error) {
+	if errors.Is(err, errTimeout) {
+		ErrorCode(conn, "timeout", map[string]any{"messageType": messageType}, "%v", err)
+	}
+}
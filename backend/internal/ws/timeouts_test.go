@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/example/rovobridge/internal/history"
+	"github.com/gorilla/websocket"
+)
+
+func TestWithTimeout(t *testing.T) {
+	ctx := context.Background()
+	if v, err := withTimeout(ctx, time.Second, "quick", func(context.Context) (int, error) { return 7, nil }); v != 7 || err != nil {
+		t.Fatalf("quick = %d, %v", v, err)
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	stuck := func(context.Context) (int, error) { <-block; return 1, nil }
+	start := time.Now()
+	_, err := withTimeout(ctx, 20*time.Millisecond, "stuck read", stuck)
+	if !errors.Is(err, errTimeout) || time.Since(start) > time.Second {
+		t.Fatalf("stuck = %v after %s", err, time.Since(start))
+	}
+	if err.Error() != "stuck read timed out after 20ms" {
+		t.Errorf("message = %q", err)
+	}
+
+	// A closed connection cancels the wait without counting as a timeout
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := withTimeout(cancelled, 0, "stuck read", stuck); !errors.Is(err, context.Canceled) || errors.Is(err, errTimeout) {
+		t.Fatalf("cancelled = %v", err)
+	}
+
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Fatalf("recovered %v, want the panic of fn", v)
+		}
+	}()
+	_, _ = withTimeout(ctx, time.Second, "panicking", func(context.Context) (int, error) { panic("boom") })
+}
+
+// stuckStorage is a history backend whose every call blocks until release is closed, like
+// a history file on a dead network mount
+type stuckStorage struct{ release chan struct{} }
+
+func (s stuckStorage) Name() string { return "stuck" }
+func (s stuckStorage) Load() ([]history.PromptHistoryEntry, error) {
+	<-s.release
+	return nil, nil
+}
+func (s stuckStorage) Append(history.PromptHistoryEntry) error { <-s.release; return nil }
+func (s stuckStorage) Remove(string) error                     { <-s.release; return nil }
+func (s stuckStorage) Update([]history.PromptHistoryEntry) error {
+	<-s.release
+	return nil
+}
+func (s stuckStorage) Validate() error { return nil }
+func (s stuckStorage) Close() error    { return nil }
+
+func TestRouter_StuckHistoryTimesOut(t *testing.T) {
+	r, _ := newTestRouter(t)
+	store := stuckStorage{release: make(chan struct{})}
+	t.Cleanup(func() { close(store.release) })
+	r.SetHistoryManager(history.NewHistoryManagerWithStorage(store, ""))
+	r.SetTimeouts(Timeouts{History: 50 * time.Millisecond})
+	c, done := dialRouter(t, r)
+	defer done()
+
+	_ = c.WriteJSON(map[string]any{"type": "suggestPrompts", "text": "fix the login test"})
+	if msg := readType(t, c, "error"); msg["code"] != "timeout" || msg["messageType"] != "suggestPrompts" {
+		t.Fatalf("error = %v", msg)
+	}
+
+	// Sessions still open, without the history that could not be loaded
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	opened := readType(t, c, "opened")
+	if hist, _ := opened["promptHistory"].([]any); len(hist) != 0 {
+		t.Fatalf("promptHistory = %v", opened["promptHistory"])
+	}
+
+	// Messages after the stuck ones are still handled
+	_ = c.WriteJSON(map[string]any{"type": "savePrompt", "historyEntry": map[string]any{"id": "p1", "serializedContent": "hi"}})
+	if msg := readType(t, c, "promptSaved"); msg["error"] != "saving the prompt timed out after 50ms" {
+		t.Fatalf("promptSaved = %v", msg)
+	}
+}
+
+func TestServer_CancelsMessageContextOnClose(t *testing.T) {
+	s := NewServer("tok")
+	ctxs := make(chan context.Context, 1)
+	s.OnMessage = func(ctx context.Context, _ *websocket.Conn, _ map[string]any) { ctxs <- ctx }
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.HandleWS)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	d := websocket.Dialer{Subprotocols: []string{"auth.bearer.tok"}}
+	h := http.Header{}
+	h.Set("Origin", "http://localhost")
+	c, _, err := d.Dial(wsURLFromHTTP(ts.URL, "/ws"), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteJSON(map[string]any{"type": "hello"})
+	ctx := <-ctxs
+	if ctx.Err() != nil {
+		t.Fatal("context ended while the connection is open")
+	}
+	c.Close()
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("context not cancelled when the connection closed")
+	}
+}
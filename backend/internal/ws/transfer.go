@@ -0,0 +1,195 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// transferTimeout is how long a transferOffered token can be claimed
+const transferTimeout = 60 * time.Second
+
+// pendingTransfer is a session offered by its controlling connection to whichever client
+// claims the token first
+type pendingTransfer struct {
+	sid   string
+	from  *websocket.Conn
+	timer *time.Timer
+}
+
+// offerTransfer lets the connection controlling sid hand it over. The token goes back to
+// conn, and to the attached IDE plugins when toEditor is set, so the receiving client can
+// claim the session with claimSession.
+func (r *Router) offerTransfer(conn *websocket.Conn, sid string, toEditor bool) error {
+	r.mu.Lock()
+	st := r.sessionStates[sid]
+	live := r.sessions[sid] != nil
+	r.mu.Unlock()
+	if st == nil || !live {
+		Errorf(conn, "transferSession: no session")
+		return nil
+	}
+	st.mu.Lock()
+	controlling := st.currentConn == conn
+	st.mu.Unlock()
+	if !controlling {
+		ErrorCode(conn, "notController", map[string]any{"sessionId": sid}, "transferSession: this connection does not control session %s", sid)
+		return nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		Errorf(conn, "transferSession: %v", err)
+		return nil
+	}
+	token := hex.EncodeToString(b)
+	p := &pendingTransfer{sid: sid, from: conn}
+	r.mu.Lock()
+	// A new offer replaces the session's previous one
+	for tok, old := range r.transfers {
+		if old.sid == sid {
+			old.timer.Stop()
+			delete(r.transfers, tok)
+		}
+	}
+	r.transfers[token] = p
+	p.timer = time.AfterFunc(transferTimeout, func() {
+		r.mu.Lock()
+		if r.transfers[token] == p {
+			delete(r.transfers, token)
+		}
+		r.mu.Unlock()
+	})
+	r.mu.Unlock()
+
+	offer := map[string]any{
+		"type":        "transferOffered",
+		"sessionId":   sid,
+		"token":       token,
+		"expiresInMs": transferTimeout.Milliseconds(),
+	}
+	if toEditor {
+		for _, c := range r.editorConnections() {
+			if c != conn {
+				_ = SendJSON(c, offer)
+			}
+		}
+	}
+	return SendJSON(conn, offer)
+}
+
+// claimTransfer moves control of the session offered under token to conn. The switch is
+// made under the session's send lock, so every stdout message goes either to the old
+// connection before its sessionTransferred or to the new one after its snapshot.
+func (r *Router) claimTransfer(ctx context.Context, conn *websocket.Conn, token string) error {
+	r.mu.Lock()
+	p := r.transfers[token]
+	if p != nil && p.from != conn {
+		delete(r.transfers, token)
+		p.timer.Stop()
+	} else {
+		p = nil
+	}
+	var st *sessionState
+	var sess ptySession
+	if p != nil {
+		st = r.sessionStates[p.sid]
+		sess = r.sessions[p.sid]
+	}
+	r.mu.Unlock()
+	if p == nil {
+		Errorf(conn, "claimSession: unknown or expired transfer")
+		return nil
+	}
+	if st == nil || sess == nil {
+		Errorf(conn, "claimSession: session %s has ended", p.sid)
+		return nil
+	}
+
+	promptHistory := r.openedPromptHistory(ctx, p.sid, 0)
+
+	st.sendMu.Lock()
+	defer st.sendMu.Unlock()
+	st.mu.Lock()
+	if st.currentConn != p.from {
+		// Someone else resumed the session since the offer was made
+		st.mu.Unlock()
+		ErrorCode(conn, "transferStale", map[string]any{"sessionId": p.sid}, "claimSession: session %s changed hands since the offer", p.sid)
+		return nil
+	}
+	st.currentConn = conn
+	st.formerConn = p.from
+	if st.orphanTimer != nil {
+		st.orphanTimer.Stop()
+		st.orphanTimer = nil
+	}
+	data := make([]byte, len(st.replay))
+	copy(data, st.replay)
+	last := st.lastSeq
+	notes := st.notesUnsafe()
+	plain := st.plainText
+	st.mu.Unlock()
+
+	r.mu.Lock()
+	delete(r.connSessions[p.from], p.sid)
+	if r.connSessions[conn] == nil {
+		r.connSessions[conn] = map[string]bool{}
+	}
+	r.connSessions[conn][p.sid] = true
+	r.mu.Unlock()
+
+	SendJSON(p.from, map[string]any{"type": "sessionTransferred", "sessionId": p.sid, "controlling": false})
+	opened := map[string]any{
+		"type":        "opened",
+		"sessionId":   p.sid,
+		"pid":         sess.PID(),
+		"resumed":     true,
+		"transferred": true,
+	}
+	promptHistory.addTo(opened)
+	if len(notes) > 0 {
+		opened["notes"] = notes
+	}
+	SendJSON(conn, opened)
+	SendJSON(conn, r.snapshotMessage(conn, p.sid, sanitizeSnapshot(data), last, plain))
+	r.replayEvents(conn, p.sid)
+	return SendJSON(conn, map[string]any{"type": "sessionTransferred", "sessionId": p.sid, "controlling": true})
+}
+
+// rejectHandedOff refuses input from a connection that transferred the session away, until
+// it resumes the session again
+func (r *Router) rejectHandedOff(conn *websocket.Conn, sid string, st *sessionState) bool {
+	if st == nil {
+		return false
+	}
+	st.mu.Lock()
+	handedOff := st.formerConn == conn
+	st.mu.Unlock()
+	if handedOff {
+		ErrorCode(conn, "sessionTransferred", map[string]any{"sessionId": sid}, "session %s was transferred to another client", sid)
+	}
+	return handedOff
+}
+
+// dropTransfers withdraws the offers made by a closing connection and forgets it as the
+// former controller of its sessions
+func (r *Router) dropTransfers(conn *websocket.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for token, p := range r.transfers {
+		if p.from == conn {
+			p.timer.Stop()
+			delete(r.transfers, token)
+		}
+	}
+	for _, st := range r.sessionStates {
+		st.mu.Lock()
+		if st.formerConn == conn {
+			st.formerConn = nil
+		}
+		st.mu.Unlock()
+	}
+}
@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouter_TransferSessionHandsOverControl(t *testing.T) {
+	r, fs := newTestRouter(t)
+	browser, closeBrowser := dialRouter(t, r)
+	defer closeBrowser()
+	ide, closeIDE := dialRouter(t, r)
+	defer closeIDE()
+
+	_ = browser.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	sid := readType(t, browser, "opened")["sessionId"]
+	sess := fs.last(t)
+	sess.emit("before")
+	readStdout(t, browser, "before")
+
+	// Only the controlling connection can offer the session
+	_ = ide.WriteJSON(map[string]any{"type": "transferSession", "sessionId": "s1"})
+	if e := readType(t, ide, "error"); e["code"] != "notController" {
+		t.Fatalf("expected notController, got %v", e)
+	}
+
+	_ = browser.WriteJSON(map[string]any{"type": "transferSession", "sessionId": "s1"})
+	token, _ := readType(t, browser, "transferOffered")["token"].(string)
+	if token == "" {
+		t.Fatal("expected a transfer token")
+	}
+
+	_ = ide.WriteJSON(map[string]any{"type": "claimSession", "token": token})
+	if opened := readType(t, ide, "opened"); opened["sessionId"] != sid || opened["transferred"] != true {
+		t.Fatalf("unexpected opened: %v", opened)
+	}
+	if snap := readType(t, ide, "snapshot"); snap["dataBase64"] != b64("before") {
+		t.Fatalf("unexpected snapshot: %v", snap)
+	}
+	if m := readType(t, ide, "sessionTransferred"); m["controlling"] != true {
+		t.Fatalf("claimant not told it controls the session: %v", m)
+	}
+	if m := readType(t, browser, "sessionTransferred"); m["sessionId"] != sid || m["controlling"] != false {
+		t.Fatalf("previous controller not notified: %v", m)
+	}
+
+	// Output follows the new controller and the old one can no longer type
+	sess.emit("after")
+	readStdout(t, ide, "after")
+	_ = browser.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("stray")})
+	if e := readType(t, browser, "error"); e["code"] != "sessionTransferred" {
+		t.Fatalf("expected sessionTransferred, got %v", e)
+	}
+	_ = ide.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("typed")})
+	eventually(t, "stdin from the new controller", func() bool { return strings.Contains(sess.stdinString(), "typed") })
+	if strings.Contains(sess.stdinString(), "stray") {
+		t.Fatalf("handed-off connection wrote to the session: %q", sess.stdinString())
+	}
+
+	// A token is good for one claim
+	_ = browser.WriteJSON(map[string]any{"type": "claimSession", "token": token})
+	readType(t, browser, "error")
+
+	// Resuming takes the session back explicitly
+	_ = browser.WriteJSON(map[string]any{"type": "openSession", "id": "s1", "resume": true})
+	readType(t, browser, "opened")
+	_ = browser.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("back")})
+	eventually(t, "stdin after resume", func() bool { return strings.Contains(sess.stdinString(), "back") })
+}
@@ -0,0 +1,42 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/example/rovobridge/internal/selfupdate"
+	"github.com/gorilla/websocket"
+)
+
+// SetUpdater enables checkUpdate against u's release manifest; nil disables it
+func (r *Router) SetUpdater(u *selfupdate.Updater) {
+	r.mu.Lock()
+	r.updater = u
+	r.mu.Unlock()
+}
+
+// checkUpdate fetches the release manifest in the background and replies with updateInfo.
+// Installing is left to "rovo-bridge self-update", run by the IDE plugin while the bridge
+// is stopped.
+func (r *Router) checkUpdate(conn *websocket.Conn) error {
+	r.mu.Lock()
+	u := r.updater
+	r.mu.Unlock()
+	if u == nil {
+		ErrorCode(conn, "updatesNotConfigured", map[string]any{"current": selfupdate.Version}, "checkUpdate: no release URL and key configured")
+		return nil
+	}
+	go func() {
+		info, err := u.Check(context.Background())
+		if err != nil {
+			ErrorCode(conn, "updateCheckFailed", map[string]any{"current": info.Current}, "checkUpdate: %v", err)
+			return
+		}
+		_ = SendJSON(conn, map[string]any{
+			"type":      "updateInfo",
+			"current":   info.Current,
+			"latest":    info.Latest,
+			"available": info.Available,
+		})
+	}()
+	return nil
+}
@@ -0,0 +1,43 @@
+package ws
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/example/rovobridge/internal/selfupdate"
+)
+
+func TestRouter_CheckUpdate(t *testing.T) {
+	r, _ := newTestRouter(t)
+	c, closeConn := dialRouter(t, r)
+	defer closeConn()
+
+	_ = c.WriteJSON(map[string]any{"type": "checkUpdate"})
+	if msg := readType(t, c, "error"); msg["code"] != "updatesNotConfigured" {
+		t.Fatalf("unexpected reply: %v", msg)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(w).Encode(selfupdate.Manifest{Version: "99.0.0", Assets: map[string]selfupdate.Asset{
+			runtime.GOOS + "-" + runtime.GOARCH: {URL: "rovo-bridge"},
+		}})
+	}))
+	defer ts.Close()
+	pub, _, _ := ed25519.GenerateKey(nil)
+	u, err := selfupdate.New(ts.URL, base64.StdEncoding.EncodeToString(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetUpdater(u)
+
+	_ = c.WriteJSON(map[string]any{"type": "checkUpdate"})
+	msg := readType(t, c, "updateInfo")
+	if msg["latest"] != "99.0.0" || msg["available"] != true || msg["current"] != selfupdate.Version {
+		t.Fatalf("unexpected updateInfo: %v", msg)
+	}
+}
@@ -0,0 +1,72 @@
+package ws
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/example/rovobridge/internal/usage"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultUsageDays is how many days a usageStats reply covers unless it asks otherwise
+	defaultUsageDays = 7
+	// maxUsageDays bounds the days a usageStats reply covers
+	maxUsageDays = 366
+)
+
+// recordUsage totals the token, request and cost figures printed in completed output
+// lines for the session and the day
+func (r *Router) recordUsage(st *sessionState, lines []plainLine) {
+	if r.usage == nil {
+		return
+	}
+	var added usage.Usage
+	st.mu.Lock()
+	for _, line := range lines {
+		if reading, ok := usage.Parse(line.text); ok {
+			added = added.Add(st.usage.Record(reading))
+		}
+	}
+	st.mu.Unlock()
+	r.usage.Add(time.Now(), added)
+}
+
+// sendUsageStats answers usageStats with the usage of every session and of the last days
+func (r *Router) sendUsageStats(conn *websocket.Conn, days int) error {
+	if days <= 0 {
+		days = defaultUsageDays
+	}
+	days = min(days, maxUsageDays)
+	r.mu.Lock()
+	states := make(map[string]*sessionState, len(r.sessionStates))
+	for id, st := range r.sessionStates {
+		states[id] = st
+	}
+	r.mu.Unlock()
+
+	sessions := make([]map[string]any, 0, len(states))
+	for id, st := range states {
+		st.mu.Lock()
+		u := st.usage.Usage()
+		st.mu.Unlock()
+		sessions = append(sessions, map[string]any{"sessionId": id, "usage": u})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i]["sessionId"].(string) < sessions[j]["sessionId"].(string) })
+	reply := map[string]any{"type": "usage", "sessions": sessions, "days": []usage.Day{}}
+	if r.usage != nil {
+		reply["days"] = r.usage.Days(time.Now(), days)
+	}
+	return SendJSON(conn, reply)
+}
+
+// FlushUsage writes the pending daily usage totals to disk; called on shutdown
+func (r *Router) FlushUsage() {
+	if r.usage == nil {
+		return
+	}
+	if err := r.usage.Flush(); err != nil {
+		log.Printf("Failed to flush usage ledger: %v", err)
+	}
+}
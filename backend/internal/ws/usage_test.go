@@ -0,0 +1,37 @@
+package ws
+
+import "testing"
+
+func TestUsageStats(t *testing.T) {
+	r, fs := newTestRouter(t)
+	c, done := dialRouter(t, r)
+	defer done()
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s1"})
+	readType(t, c, "opened")
+	f := fs.last(t)
+
+	f.emit("Done.\r\nTokens used: 1,200 (1 request used)\r\n")
+	f.emit("Session cost: $0.40\r\nSession cost: $0.40\r\nDone again.\r\nTokens used: 300\r\n")
+	readStdout(t, c, "Tokens used: 300")
+
+	var got map[string]any
+	eventually(t, "usage of both responses", func() bool {
+		_ = c.WriteJSON(map[string]any{"type": "usageStats", "days": 2})
+		got = readType(t, c, "usage")
+		sessions, _ := got["sessions"].([]any)
+		if len(sessions) != 1 {
+			return false
+		}
+		u, _ := sessions[0].(map[string]any)["usage"].(map[string]any)
+		return u["tokens"] == float64(1500)
+	})
+	u := got["sessions"].([]any)[0].(map[string]any)["usage"].(map[string]any)
+	if u["requests"] != float64(1) || u["costUsd"] != 0.4 {
+		t.Fatalf("session usage = %v", u)
+	}
+	days := got["days"].([]any)
+	today, _ := days[len(days)-1].(map[string]any)["usage"].(map[string]any)
+	if len(days) != 2 || today["tokens"] != float64(1500) || today["costUsd"] != 0.4 {
+		t.Fatalf("days = %v", days)
+	}
+}
@@ -0,0 +1,322 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/rovobridge/internal/policy"
+	"github.com/example/rovobridge/internal/session"
+)
+
+const (
+	// warmRetryDelay is the wait before replacing a warm process that exited or failed to
+	// start unclaimed; it doubles with each further failure up to maxWarmRetryDelay
+	warmRetryDelay    = 5 * time.Second
+	maxWarmRetryDelay = 5 * time.Minute
+	// maxWarmSessions bounds the processes kept started ahead of openSession
+	maxWarmSessions = 4
+	// warmOutputLimit is the output a warm process may print before it is claimed; beyond
+	// it the process blocks on its terminal until a session takes it over
+	warmOutputLimit = 256 * 1024
+)
+
+// warmPool keeps processes of the default session command started ahead of openSession,
+// so the agent CLI's startup is done by the time the panel opens. A claimed process is
+// replaced in the background.
+type warmPool struct {
+	r    *Router
+	size int
+
+	mu       sync.Mutex
+	cfg      session.Config // what the processes are started with
+	key      string         // warmKey of cfg
+	idle     []*warmSession
+	starting int
+	failures int // consecutive warm processes that exited or failed unclaimed
+	retry    *time.Timer
+	closed   bool
+}
+
+// warmSession is a process started by the pool. Its output is buffered until a session
+// claims it, and Wait is answered from the pool's own wait.
+type warmSession struct {
+	ptySession
+	key    string
+	out    *warmOutput
+	exited chan struct{}
+	err    error
+}
+
+func (w *warmSession) Stdout() io.Reader { return w.out }
+
+func (w *warmSession) Wait() error {
+	<-w.exited
+	return w.err
+}
+
+// discard ends a process no session claimed, along with its buffered output
+func (w *warmSession) discard() {
+	w.out.stop()
+	_ = w.Close()
+}
+
+// SetWarmSessions keeps n processes of the default session command started ahead of
+// openSession (at most maxWarmSessions); call it before serving. 0 leaves them off.
+func (r *Router) SetWarmSessions(n int) {
+	if n <= 0 {
+		return
+	}
+	r.warm = &warmPool{r: r, size: min(n, maxWarmSessions)}
+	r.warm.reset(r.warmConfig())
+}
+
+// CloseWarmSessions ends the processes waiting in the warm pool
+func (r *Router) CloseWarmSessions() {
+	if r.warm != nil {
+		r.warm.close()
+	}
+}
+
+// warmConfig is the session the web UI opens by default: the session config it is sent
+// in welcome, in the bridge's working directory
+func (r *Router) warmConfig() session.Config {
+	sc := r.getSessionConfig()
+	cmd, _ := sc["cmd"].(string)
+	args, _ := sc["args"].([]string)
+	env, _ := sc["env"].([]string)
+	return session.Config{Cmd: cmd, Args: args, Env: env, Mode: session.ModeAutoPTY}
+}
+
+// warmKey identifies the processes a session config starts; an empty Dir is the
+// bridge's working directory, and the command is the executable it resolves to
+func warmKey(cfg session.Config) string {
+	dir := cfg.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return strings.Join([]string{policy.Resolve(cfg.Cmd, dir), strings.Join(cfg.Args, "\x01"), strings.Join(cfg.Env, "\x01"), dir, fmt.Sprint(cfg.Mode)}, "\x00")
+}
+
+// take hands over an idle process started with cfg, or returns nil when there is none.
+// The pool is refilled in the background. Safe on a nil pool.
+func (p *warmPool) take(cfg session.Config) ptySession {
+	if p == nil {
+		return nil
+	}
+	key := warmKey(cfg)
+	p.mu.Lock()
+	var claimed *warmSession
+	for i, w := range p.idle {
+		if w.key == key {
+			claimed = w
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			break
+		}
+	}
+	if claimed != nil {
+		p.failures = 0
+	}
+	p.mu.Unlock()
+	if claimed == nil {
+		return nil
+	}
+	go p.fill()
+	return claimed
+}
+
+// reset replaces the idle processes with ones started with cfg, after the session
+// config changed. Safe on a nil pool.
+func (p *warmPool) reset(cfg session.Config) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.cfg, p.key = cfg, warmKey(cfg)
+	idle := p.idle
+	p.idle = nil
+	p.failures = 0
+	p.mu.Unlock()
+	for _, w := range idle {
+		w.discard()
+	}
+	p.fill()
+}
+
+// fill starts processes until the pool has its size, counting those still starting
+func (p *warmPool) fill() {
+	p.mu.Lock()
+	if p.closed || p.retry != nil {
+		p.mu.Unlock()
+		return
+	}
+	need := p.size - len(p.idle) - p.starting
+	if need <= 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.starting += need
+	cfg := p.cfg
+	p.mu.Unlock()
+	if err := p.r.policy.Check("openSession", cfg.Cmd); err != nil {
+		log.Printf("Not starting warm sessions: %v", err)
+		p.mu.Lock()
+		p.starting -= need
+		p.mu.Unlock()
+		return
+	}
+	for range need {
+		go p.spawn(cfg)
+	}
+}
+
+func (p *warmPool) spawn(cfg session.Config) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sess, err := p.r.startSession(ctx, cfg)
+	if err != nil {
+		cancel()
+		log.Printf("Failed to start warm session: %v", err)
+		p.mu.Lock()
+		p.starting--
+		p.failedUnsafe()
+		p.mu.Unlock()
+		return
+	}
+	w := &warmSession{ptySession: sess, key: warmKey(cfg), out: newWarmOutput(sess.Stdout()), exited: make(chan struct{})}
+	go func() {
+		w.err = sess.Wait()
+		cancel()
+		close(w.exited)
+		p.exited(w)
+	}()
+
+	p.mu.Lock()
+	p.starting--
+	stale := p.closed || w.key != p.key
+	if !stale {
+		p.idle = append(p.idle, w)
+	}
+	p.mu.Unlock()
+	if stale {
+		w.discard()
+	}
+}
+
+// exited drops a process that ended before a session claimed it
+func (p *warmPool) exited(w *warmSession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, idle := range p.idle {
+		if idle == w {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			w.out.stop()
+			if !p.closed {
+				log.Printf("Warm session exited before use: %v", w.err)
+				p.failedUnsafe()
+			}
+			return
+		}
+	}
+}
+
+// failedUnsafe backs off before starting further processes, so a command that fails at
+// once is not restarted in a loop. Caller must hold p.mu.
+func (p *warmPool) failedUnsafe() {
+	p.failures++
+	if p.closed || p.retry != nil {
+		return
+	}
+	delay := min(warmRetryDelay<<min(p.failures-1, 10), maxWarmRetryDelay)
+	p.retry = time.AfterFunc(delay, func() {
+		p.mu.Lock()
+		p.retry = nil
+		p.mu.Unlock()
+		p.fill()
+	})
+}
+
+func (p *warmPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	if p.retry != nil {
+		p.retry.Stop()
+		p.retry = nil
+	}
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, w := range idle {
+		w.discard()
+	}
+}
+
+// warmOutput reads a warm process's output ahead of its session, up to warmOutputLimit,
+// and replays it to the session that claims the process
+type warmOutput struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []byte
+	err     error
+	stopped bool // no session will read the output; it is dropped
+}
+
+func newWarmOutput(src io.Reader) *warmOutput {
+	o := &warmOutput{}
+	o.cond = sync.NewCond(&o.mu)
+	go o.fill(src)
+	return o
+}
+
+func (o *warmOutput) fill(src io.Reader) {
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(chunk)
+		o.mu.Lock()
+		for len(o.buf) >= warmOutputLimit && !o.stopped {
+			o.cond.Wait()
+		}
+		if !o.stopped {
+			o.buf = append(o.buf, chunk[:n]...)
+		}
+		if err != nil {
+			o.err = err
+		}
+		o.cond.Broadcast()
+		o.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stop drops the buffered output and lets the reader run to the end of the process
+func (o *warmOutput) stop() {
+	o.mu.Lock()
+	o.stopped, o.buf = true, nil
+	o.cond.Broadcast()
+	o.mu.Unlock()
+}
+
+func (o *warmOutput) Read(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for len(o.buf) == 0 && o.err == nil {
+		o.cond.Wait()
+	}
+	if len(o.buf) == 0 {
+		return 0, o.err
+	}
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+	o.cond.Broadcast()
+	return n, nil
+}
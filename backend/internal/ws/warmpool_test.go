@@ -0,0 +1,92 @@
+package ws
+
+import "testing"
+
+func (fs *fakeSessions) count() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.started)
+}
+
+func (fs *fakeSessions) at(i int) *fakeSession {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.started[i]
+}
+
+// openDefault opens a session with the session config of welcome, as the web UI does
+func openDefault(r *Router, id string) map[string]any {
+	sc := r.getSessionConfig()
+	return map[string]any{"type": "openSession", "id": id, "cmd": sc["cmd"], "args": sc["args"], "env": sc["env"], "pty": true}
+}
+
+func TestWarmSessions_AttachAndRewarm(t *testing.T) {
+	r, fs := newTestRouter(t)
+	r.SetWarmSessions(1)
+	t.Cleanup(r.CloseWarmSessions)
+	eventually(t, "a warm session", func() bool { return fs.count() == 1 })
+	warm := fs.at(0)
+	warm.emit("agent ready> ")
+
+	c, done := dialRouter(t, r)
+	defer done()
+	_ = c.WriteJSON(openDefault(r, "s1"))
+	if opened := readType(t, c, "opened"); opened["warm"] != true {
+		t.Fatalf("expected the warm process, got %v", opened)
+	}
+	readStdout(t, c, "agent ready> ")
+	eventually(t, "a replacement", func() bool { return fs.count() == 2 })
+
+	_ = c.WriteJSON(map[string]any{"type": "stdin", "sessionId": "s1", "dataBase64": b64("hi")})
+	eventually(t, "input to the warm process", func() bool { return warm.stdinString() == "hi" })
+	warm.exit(nil)
+	readType(t, c, "exit")
+
+	// A different command starts its own process and leaves the pool alone
+	_ = c.WriteJSON(map[string]any{"type": "openSession", "id": "s2", "cmd": "other"})
+	if opened := readType(t, c, "opened"); opened["warm"] != nil {
+		t.Fatalf("expected a new process, got %v", opened)
+	}
+	if fs.count() != 3 || fs.at(1).isClosed() {
+		t.Fatalf("expected the replacement to stay warm, %d started", fs.count())
+	}
+
+	r.CloseWarmSessions()
+	if !fs.at(1).isClosed() {
+		t.Fatal("expected the idle warm process to be closed")
+	}
+}
+
+func TestWarmSessions_DropsExitedAndConfigChanges(t *testing.T) {
+	r, fs := newTestRouter(t)
+	r.SetWarmSessions(1)
+	t.Cleanup(r.CloseWarmSessions)
+	eventually(t, "a warm session", func() bool { return fs.count() == 1 })
+	fs.at(0).exit(nil)
+	eventually(t, "the exited process dropped", func() bool {
+		r.warm.mu.Lock()
+		defer r.warm.mu.Unlock()
+		return len(r.warm.idle) == 0 && r.warm.retry != nil
+	})
+
+	c, done := dialRouter(t, r)
+	defer done()
+	_ = c.WriteJSON(openDefault(r, "s1"))
+	if opened := readType(t, c, "opened"); opened["warm"] != nil {
+		t.Fatalf("expected a new process after the warm one exited, got %v", opened)
+	}
+
+	// A new command replaces the idle processes once the backoff is over
+	r.warm.mu.Lock()
+	r.warm.retry.Stop()
+	r.warm.retry = nil
+	r.warm.mu.Unlock()
+	r.warm.fill()
+	eventually(t, "a warm session", func() bool { return fs.count() == 3 })
+	r.customCommand = "agent --fast"
+	r.warm.reset(r.warmConfig())
+	eventually(t, "a warm session of the new command", func() bool { return fs.count() == 4 })
+	if !fs.at(2).isClosed() || fs.at(3).cfg.Cmd != "agent" {
+		t.Fatalf("expected the old warm process closed and %q started", "agent")
+	}
+}
@@ -0,0 +1,338 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultWriteTimeout bounds a single frame write to a client
+	DefaultWriteTimeout = 10 * time.Second
+	// DefaultQueueSize is the number of outbound messages buffered per connection
+	DefaultQueueSize = 512
+	// batchWindow is how long a batching writer waits for more messages to coalesce
+	batchWindow = 5 * time.Millisecond
+	// maxBatchBytes caps the encoded size of a batch frame
+	maxBatchBytes = 256 * 1024
+)
+
+var (
+	errConnClosed = errors.New("connection closed")
+	errSlowClient = errors.New("client evicted: not keeping up with outbound messages")
+)
+
+// wsWriters holds the outbound writer of every connection served by a Server
+var wsWriters sync.Map // map[*websocket.Conn]*connWriter
+
+// connWriter owns all writes to one connection. Messages are queued and written by a
+// single goroutine with a deadline, so a stalled client never blocks the sender; a client
+// that lets the queue fill up or a write time out is evicted.
+type connWriter struct {
+	c       *websocket.Conn
+	queues  [numChannels]chan []byte // by channel; everything goes to chControl until channels are negotiated
+	done    chan struct{}            // closed to stop the writer
+	exited  chan struct{}            // closed once run has returned
+	stopped sync.Once
+	timeout time.Duration
+	onEvict func(reason string)
+	onWrite func(n int) // called with the size of every frame written
+	batch   atomic.Bool // coalesce messages into JSON array frames, negotiated in hello
+	mux     atomic.Bool // tag messages with their channel and prioritize control, negotiated in hello
+	flow    channelFlow
+	turn    channel // of stream and bulk, the one served next when both are ready
+	usage   connUsage
+	origin  string // Origin header of the upgrade request
+}
+
+func newConnWriter(c *websocket.Conn, origin string, size int, timeout time.Duration, onEvict func(string), onWrite func(int)) *connWriter {
+	w := &connWriter{c: c, origin: origin, done: make(chan struct{}), exited: make(chan struct{}), timeout: timeout, onEvict: onEvict, onWrite: onWrite, turn: chStream}
+	for ch := range w.queues {
+		w.queues[ch] = make(chan []byte, size)
+	}
+	w.flow.credited = make(chan struct{}, 1)
+	w.usage.connectedAt = time.Now()
+	go w.run()
+	return w
+}
+
+// enqueue queues an encoded message on channel ch without blocking. Until channels are
+// negotiated every message goes to chControl, so they are written in the order sent.
+func (w *connWriter) enqueue(buf []byte, ch channel) error {
+	select {
+	case <-w.done:
+		return errConnClosed
+	default:
+	}
+	if w.mux.Load() {
+		buf = tagChannel(buf, ch)
+	} else {
+		ch = chControl
+	}
+	q := w.queues[ch]
+	select {
+	case q <- buf:
+		return nil
+	case <-w.done:
+		return errConnClosed
+	default:
+		w.evict(fmt.Sprintf("outbound %s queue full (%d messages)", channelNames[ch], cap(q)))
+		return errSlowClient
+	}
+}
+
+func (w *connWriter) run() {
+	defer close(w.exited)
+	for {
+		buf, ch, ok := w.next()
+		if !ok {
+			return
+		}
+		if w.batch.Load() {
+			buf = w.collect(buf, w.queues[ch])
+		}
+		_ = w.c.SetWriteDeadline(time.Now().Add(w.timeout))
+		if err := w.c.WriteMessage(websocket.TextMessage, buf); err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				w.evict(fmt.Sprintf("write timed out after %s", w.timeout))
+			} else {
+				w.stop()
+			}
+			return
+		}
+		w.flow.spend(ch, len(buf))
+		if w.onWrite != nil {
+			w.onWrite(len(buf))
+		}
+	}
+}
+
+// next waits for the next message to write: control messages first, then stream and bulk
+// messages in turn, each only while its channel has credit. It reports false once the
+// writer is stopped.
+func (w *connWriter) next() ([]byte, channel, bool) {
+	for {
+		select {
+		case <-w.done:
+			return nil, 0, false
+		case buf := <-w.queues[chControl]:
+			return buf, chControl, true
+		default:
+		}
+		// A nil queue blocks forever, leaving channels without credit out of the selects
+		var ready [numChannels]chan []byte
+		for ch := chStream; ch < numChannels; ch++ {
+			if w.flow.open(ch) {
+				ready[ch] = w.queues[ch]
+			}
+		}
+		first, second := w.turn, chStream+chBulk-w.turn
+		select {
+		case buf := <-ready[first]:
+			w.turn = second
+			return buf, first, true
+		default:
+		}
+		select {
+		case buf := <-ready[second]:
+			w.turn = first
+			return buf, second, true
+		default:
+		}
+		select {
+		case <-w.done:
+			return nil, 0, false
+		case buf := <-w.queues[chControl]:
+			return buf, chControl, true
+		case buf := <-ready[chStream]:
+			w.turn = chBulk
+			return buf, chStream, true
+		case buf := <-ready[chBulk]:
+			w.turn = chStream
+			return buf, chBulk, true
+		case <-w.flow.credited:
+			// look again at the channels that were waiting for it
+		}
+	}
+}
+
+// collect gathers the messages queued on q within batchWindow after first into one JSON
+// array frame. A lone message is written as is, so clients see arrays only when they save
+// frames.
+func (w *connWriter) collect(first []byte, q chan []byte) []byte {
+	msgs := [][]byte{first}
+	size := len(first)
+	timer := time.NewTimer(batchWindow)
+	defer timer.Stop()
+gather:
+	for size < maxBatchBytes {
+		select {
+		case buf := <-q:
+			msgs = append(msgs, buf)
+			size += len(buf) + 1
+		case <-timer.C:
+			break gather
+		case <-w.done:
+			break gather
+		}
+	}
+	if len(msgs) == 1 {
+		return first
+	}
+	frame := make([]byte, 0, size+2)
+	frame = append(frame, '[')
+	for i, m := range msgs {
+		if i > 0 {
+			frame = append(frame, ',')
+		}
+		frame = append(frame, m...)
+	}
+	return append(frame, ']')
+}
+
+// evict drops the client: pending messages are discarded and the socket is closed, which
+// ends the read loop and runs the usual connection cleanup
+func (w *connWriter) evict(reason string) {
+	w.stopped.Do(func() {
+		close(w.done)
+		log.Printf("ws: evicting slow client %s: %s", w.c.RemoteAddr(), reason)
+		if w.onEvict != nil {
+			w.onEvict(reason)
+		}
+		_ = w.c.Close()
+	})
+}
+
+// stop ends the writer, discarding pending messages
+func (w *connWriter) stop() {
+	w.stopped.Do(func() { close(w.done) })
+}
+
+// SetBatching turns array-batched frames on or off for a connection served by a Server,
+// and reports whether the connection supports them
+func SetBatching(c *websocket.Conn, on bool) bool {
+	v, ok := wsWriters.Load(c)
+	if !ok {
+		return false
+	}
+	v.(*connWriter).batch.Store(on)
+	return true
+}
+
+// pending returns the number of queued messages
+func (w *connWriter) pending() int {
+	n := 0
+	for _, q := range w.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// queuedFor returns the number of messages queued to a connection served by a Server
+func queuedFor(c *websocket.Conn) int {
+	v, ok := wsWriters.Load(c)
+	if !ok {
+		return 0
+	}
+	return v.(*connWriter).pending()
+}
+
+// Eviction records why and when a client was dropped
+type Eviction struct {
+	Remote string    `json:"remote"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// Stats summarizes the connections of a Server
+type Stats struct {
+	Connections      int           `json:"connections"`
+	Queued           int           `json:"queued"` // outbound messages not yet written, over all connections
+	Evictions        uint64        `json:"evictions"`
+	LastEviction     *Eviction     `json:"lastEviction,omitempty"`
+	BytesSent        int64         `json:"bytesSent"` // over all connections since start, closed ones included
+	BytesReceived    int64         `json:"bytesReceived"`
+	QuotaWarnings    uint64        `json:"quotaWarnings"`
+	LastQuotaWarning *QuotaWarning `json:"lastQuotaWarning,omitempty"`
+	Clients          []ConnStats   `json:"clients"` // open connections, oldest first
+}
+
+// Stats returns a snapshot of the connection statistics
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Stats{Connections: len(s.writers), Evictions: s.evictions, BytesSent: s.closedSent, BytesReceived: s.closedReceived, QuotaWarnings: s.quotaWarnings}
+	st.Clients = make([]ConnStats, 0, len(s.writers))
+	for _, w := range s.writers {
+		cs := w.stats()
+		st.Queued += cs.Queued
+		st.BytesSent += cs.BytesSent
+		st.BytesReceived += cs.BytesReceived
+		st.Clients = append(st.Clients, cs)
+	}
+	sort.Slice(st.Clients, func(i, j int) bool { return st.Clients[i].ConnectedAt.Before(st.Clients[j].ConnectedAt) })
+	if s.lastEviction != nil {
+		e := *s.lastEviction
+		st.LastEviction = &e
+	}
+	if s.lastQuotaWarning != nil {
+		q := *s.lastQuotaWarning
+		st.LastQuotaWarning = &q
+	}
+	return st
+}
+
+// register starts the outbound writer of a newly accepted connection, opened from origin
+func (s *Server) register(c *websocket.Conn, origin string) *connWriter {
+	size := s.QueueSize
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	timeout := s.WriteTimeout
+	if timeout <= 0 {
+		timeout = DefaultWriteTimeout
+	}
+	var w *connWriter
+	w = newConnWriter(c, origin, size, timeout, func(reason string) {
+		s.mu.Lock()
+		s.evictions++
+		s.lastEviction = &Eviction{Remote: c.RemoteAddr().String(), Reason: reason, At: time.Now()}
+		s.mu.Unlock()
+	}, func(n int) { s.account(w, true, n) })
+	s.mu.Lock()
+	if s.writers == nil {
+		s.writers = map[*websocket.Conn]*connWriter{}
+	}
+	s.writers[c] = w
+	s.mu.Unlock()
+	wsWriters.Store(c, w)
+	return w
+}
+
+// unregister stops the writer of a closing connection and closes the socket. The writer
+// stays registered until its goroutine has returned, so late sends fail instead of
+// racing it with a direct write.
+func (s *Server) unregister(c *websocket.Conn) {
+	s.mu.Lock()
+	w := s.writers[c]
+	delete(s.writers, c)
+	s.mu.Unlock()
+	if w != nil {
+		w.stop()
+		_ = c.Close()
+		<-w.exited
+		s.mu.Lock()
+		s.closedSent += w.usage.bytesSent.Load()
+		s.closedReceived += w.usage.bytesRecv.Load()
+		s.mu.Unlock()
+	}
+	wsWriters.Delete(c)
+}
@@ -25,6 +25,14 @@ set "JETBRAINS_OUT_BASE=%ROOT_DIR%\hosts\jetbrains-plugin\src\main\resources\bin
 set "VSCODE_OUT_BASE=%ROOT_DIR%\hosts\vscode-plugin\resources\bin"
 set "UI_DIR=%ROOT_DIR%\web-ui"
 
+rem Release settings embedded for "rovo-bridge self-update" (all optional):
+rem ROVOBRIDGE_VERSION, ROVOBRIDGE_RELEASE_URL (manifest URL), ROVOBRIDGE_RELEASE_KEY (base64 ed25519 key)
+set "SELFUPDATE_PKG=github.com/example/rovobridge/internal/selfupdate"
+if not defined ROVOBRIDGE_VERSION set "ROVOBRIDGE_VERSION=dev"
+set "LDFLAGS=-s -w -X %SELFUPDATE_PKG%.Version=%ROVOBRIDGE_VERSION%"
+if defined ROVOBRIDGE_RELEASE_URL set "LDFLAGS=!LDFLAGS! -X %SELFUPDATE_PKG%.ReleaseURL=!ROVOBRIDGE_RELEASE_URL!"
+if defined ROVOBRIDGE_RELEASE_KEY set "LDFLAGS=!LDFLAGS! -X %SELFUPDATE_PKG%.PublicKey=!ROVOBRIDGE_RELEASE_KEY!"
+
 if not exist "%BACKEND_DIR%" (
   echo Error: backend directory not found at %BACKEND_DIR% 1>&2
   exit /b 1
@@ -99,7 +107,7 @@ pushd "%BACKEND_DIR%"
 set "CGO_ENABLED=0"
 set "GOOS=!GOOS!"
 set "GOARCH=!GOARCH!"
-go build -trimpath -ldflags="-s -w" -o "!TEMP_BINARY!" ./cmd/rovo-bridge
+go build -trimpath -ldflags="!LDFLAGS!" -o "!TEMP_BINARY!" ./cmd/rovo-bridge
 if errorlevel 1 (
   popd
   exit /b 1
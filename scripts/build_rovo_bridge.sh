@@ -22,6 +22,13 @@ VSCODE_OUT_BASE="$ROOT_DIR/hosts/vscode-plugin/resources/bin"
 
 UI_DIR="$ROOT_DIR/web-ui"
 
+# Release settings embedded for `rovo-bridge self-update` (all optional):
+# ROVOBRIDGE_VERSION, ROVOBRIDGE_RELEASE_URL (manifest URL), ROVOBRIDGE_RELEASE_KEY (base64 ed25519 key)
+SELFUPDATE_PKG="github.com/example/rovobridge/internal/selfupdate"
+LDFLAGS="-s -w -X $SELFUPDATE_PKG.Version=${ROVOBRIDGE_VERSION:-dev}"
+LDFLAGS="$LDFLAGS -X $SELFUPDATE_PKG.ReleaseURL=${ROVOBRIDGE_RELEASE_URL:-}"
+LDFLAGS="$LDFLAGS -X $SELFUPDATE_PKG.PublicKey=${ROVOBRIDGE_RELEASE_KEY:-}"
+
 if [[ ! -d "$BACKEND_DIR" ]]; then
   echo "Error: backend directory not found at $BACKEND_DIR" >&2
   exit 1
@@ -128,7 +135,7 @@ build_one() {
   (
     cd "$BACKEND_DIR"
     CGO_ENABLED=0 GOOS="$goos" GOARCH="$goarch" \
-      go build -trimpath -ldflags="-s -w" -o "$temp_binary" ./cmd/rovo-bridge
+      go build -trimpath -ldflags="$LDFLAGS" -o "$temp_binary" ./cmd/rovo-bridge
   )
   
   # Copy to both plugin locations
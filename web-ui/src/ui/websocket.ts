@@ -184,6 +184,14 @@ export function connect() {
     const m = JSON.parse(ev.data)
     if (m.type === 'welcome' && m.sessionConfig) { updateSessionConfigFromBackend(m.sessionConfig); startSession(state.currentWs!, !state.forceFreshStart); state.forceFreshStart = false }
     if (m.type === 'sessionConfigUpdated' && m.sessionConfig) updateSessionConfigFromBackend(m.sessionConfig)
+    if (m.type === 'confirmationRequired' && m.token) {
+      showBanner(`${m.summary || m.operation}?`, {
+        id: 'confirmation-required',
+        buttonText: 'Allow',
+        onButtonClick: () => { try { ws.send(JSON.stringify({ type: 'confirm', token: m.token })) } catch {} },
+        timeoutMs: typeof m.expiresInMs === 'number' ? m.expiresInMs : 30000,
+      })
+    }
     if (m.type === 'stdout') {
       if (typeof m.seq === 'number') {
         const expected = state.sessionLastSeq ? (state.sessionLastSeq + 1) : m.seq